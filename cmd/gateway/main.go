@@ -2,157 +2,170 @@ package main
 
 import (
     "context"
+    "flag"
+    "fmt"
     "log"
-    "net/http"
+    "log/slog"
     "os"
     "os/signal"
+    "strconv"
     "syscall"
-    "time"
 
-    "github.com/go-chi/chi/v5"
-    chimiddleware "github.com/go-chi/chi/v5/middleware"
     "github.com/jackc/pgx/v5/pgxpool"
-    "github.com/redis/go-redis/v9"
-    "go.opentelemetry.io/otel"
 
     "github.com/vnmchuo/llm-gateway/config"
-    "github.com/vnmchuo/llm-gateway/internal/auth"
-    "github.com/vnmchuo/llm-gateway/internal/billing"
-    "github.com/vnmchuo/llm-gateway/internal/provider"
-    "github.com/vnmchuo/llm-gateway/internal/provider/claude"
-    "github.com/vnmchuo/llm-gateway/internal/provider/gemini"
-    "github.com/vnmchuo/llm-gateway/internal/provider/openai"
-    "github.com/vnmchuo/llm-gateway/internal/proxy"
+    "github.com/vnmchuo/llm-gateway/internal/logging"
+    "github.com/vnmchuo/llm-gateway/internal/migrate"
     "github.com/vnmchuo/llm-gateway/internal/seeder"
+    "github.com/vnmchuo/llm-gateway/internal/server"
     "github.com/vnmchuo/llm-gateway/internal/telemetry"
-    "github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+    "github.com/vnmchuo/llm-gateway/migrations"
 )
 
+// Usage: gateway [serve] [--role=all|api|worker]
+//        gateway migrate [up|status|baseline <version>]
 func main() {
-    // 1. Load config
+    args := os.Args[1:]
+    if len(args) > 0 && args[0] == "migrate" {
+        runMigrate(args[1:])
+        return
+    }
+    if len(args) > 0 && args[0] == "serve" {
+        args = args[1:]
+    }
+    flags := flag.NewFlagSet("serve", flag.ExitOnError)
+    roleFlag := flags.String("role", string(server.RoleAll), "what this process runs: all, api or worker")
+    _ = flags.Parse(args)
+    if flags.NArg() > 0 {
+        fmt.Fprintf(os.Stderr, "unknown command %q\nusage: gateway [serve] [--role=all|api|worker]\n", flags.Arg(0))
+        os.Exit(2)
+    }
+    role, err := server.ParseRole(*roleFlag)
+    if err != nil {
+        log.Fatalf("invalid --role: %v", err)
+    }
+
+    // 1. Load config and install the structured logger; the std log
+    // package writes through it from here on
     cfg, err := config.Load()
     if err != nil {
         log.Fatalf("failed to load config: %v", err)
     }
+    logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+    if err != nil {
+        log.Fatalf("failed to init logger: %v", err)
+    }
+    slog.SetDefault(logger)
 
     // 2. Init telemetry
-    shutdownTracer, err := telemetry.InitTracer("llm-gateway", cfg)
+    serviceName := "llm-gateway"
+    if role == server.RoleWorker {
+        serviceName = "llm-gateway-worker"
+    }
+    shutdownTracer, err := telemetry.InitTracer(serviceName, cfg)
     if err != nil {
-        log.Fatalf("failed to init tracer: %v", err)
+        fatal("failed to init tracer", err)
     }
     defer shutdownTracer()
 
-    // 3. Connect PostgreSQL
-    ctx := context.Background()
-    pool, err := pgxpool.New(ctx, cfg.PostgresDSN)
+    shutdownMeter, err := telemetry.InitMeter(serviceName, cfg)
     if err != nil {
-        log.Fatalf("failed to connect postgres: %v", err)
+        fatal("failed to init meter", err)
     }
-    defer pool.Close()
-
-    if err := pool.Ping(ctx); err != nil {
-        log.Fatalf("failed to ping postgres: %v", err)
-    }
-    log.Println("PostgreSQL connected")
+    defer shutdownMeter()
 
-    // 4. Connect Redis
-    rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
-    defer rdb.Close()
+    // 3. Connect storage and wire the gateway
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    defer stop()
 
-    if err := rdb.Ping(ctx).Err(); err != nil {
-        log.Fatalf("failed to ping redis: %v", err)
+    srv, err := server.New(ctx, cfg, server.WithRole(role))
+    if err != nil {
+        fatal("failed to start gateway", err)
     }
-    log.Println("Redis connected")
+    slog.Info("gateway started", "role", string(role))
 
-    // 5. Init auth
-    authStore := auth.NewPostgresStore(pool)
-    authMiddleware := auth.NewMiddleware(authStore, rdb)
+    // 4. Seed test API key if RUN_SEED=true
+    if os.Getenv("RUN_SEED") == "true" {
+        seeder.SeedTestAPIKey(ctx, srv.AuthStore())
+    }
 
-    // 6. Init billing
-    billingStore := billing.NewPostgresStore(pool)
+    // 5. Serve until SIGINT/SIGTERM, then shut down gracefully
+    if err := srv.Run(ctx); err != nil {
+        slog.Error("server error", "err", err)
+    }
+    slog.Info("server stopped")
+}
 
-    // 7. Init rate limiter
-    limiter := ratelimit.NewLimiter(rdb, cfg.DefaultRateLimitTPM)
+// fatal logs err and exits, for startup failures after the logger is set up.
+func fatal(msg string, err error) {
+    slog.Error(msg, "err", err)
+    os.Exit(1)
+}
 
-    // 8. Init providers
-    providers := []provider.Provider{
-        gemini.New(cfg.GeminiAPIKey),
-        openai.New(cfg.OpenAIAPIKey),
-        claude.New(cfg.AnthropicAPIKey),
+// runMigrate applies or reports the schema migrations embedded in the binary.
+func runMigrate(args []string) {
+    cmd := "up"
+    if len(args) > 0 {
+        cmd = args[0]
     }
 
-    // 9. Init router
-    router := proxy.NewRouter(providers)
+    cfg, err := config.Load()
+    if err != nil {
+        log.Fatalf("failed to load config: %v", err)
+    }
+    logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+    if err != nil {
+        log.Fatalf("failed to init logger: %v", err)
+    }
+    slog.SetDefault(logger)
 
-    // 10. Init handler
-    tracer := otel.GetTracerProvider().Tracer("llm-gateway")
-    handler := proxy.NewHandler(router, billingStore, limiter, tracer)
+    ctx := context.Background()
+    pool, err := pgxpool.New(ctx, cfg.PostgresDSN)
+    if err != nil {
+        fatal("failed to connect postgres", err)
+    }
+    defer pool.Close()
+    migrator, err := migrate.New(pool, migrations.FS)
+    if err != nil {
+        fatal("failed to load migrations", err)
+    }
 
-    // 11. Seed test API key if RUN_SEED=true
-    if os.Getenv("RUN_SEED") == "true" {
-        seeder.SeedTestAPIKey(ctx, authStore)
-    }
-
-    // 12. Init Chi router
-    r := chi.NewRouter()
-    r.Use(chimiddleware.RequestID)
-    r.Use(chimiddleware.Logger)
-    r.Use(chimiddleware.Recoverer)
-
-    // Public routes
-    r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusOK)
-        _, _ = w.Write([]byte(`{"status":"ok","service":"llm-gateway"}`))
-    })
-
-    // Protected routes
-    r.Group(func(r chi.Router) {
-        r.Use(authMiddleware)
-        r.Post("/v1/chat/completions", handler.HandleComplete)
-        r.Post("/v1/chat/completions/stream", handler.HandleCompleteStream)
-        r.Get("/v1/usage", handler.HandleUsage)
-    })
-
-    // Async job routes — Phase 2 placeholder
-    r.Post("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusNotImplemented)
-        _, _ = w.Write([]byte(`{"error":"async jobs coming in phase 2"}`))
-    })
-    r.Get("/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusNotImplemented)
-        _, _ = w.Write([]byte(`{"error":"async jobs coming in phase 2"}`))
-    })
-
-    // 13. Graceful shutdown
-    srv := &http.Server{
-        Addr:         ":" + cfg.Port,
-        Handler:      r,
-        ReadTimeout:  30 * time.Second,
-        WriteTimeout: 90 * time.Second,
-        IdleTimeout:  120 * time.Second,
-    }
-
-    quit := make(chan os.Signal, 1)
-    signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-    go func() {
-        log.Printf("LLM Gateway starting on port %s", cfg.Port)
-        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-            log.Fatalf("server error: %v", err)
+    switch {
+    case cmd == "up" && len(args) <= 1:
+        applied, err := migrator.Up(ctx)
+        for _, m := range applied {
+            slog.Info("migration applied", "version", m.Version, "name", m.Name)
         }
-    }()
-
-    <-quit
-    log.Println("Shutting down gracefully...")
-
-    shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-
-    if err := srv.Shutdown(shutdownCtx); err != nil {
-        log.Fatalf("forced shutdown: %v", err)
+        if err != nil {
+            fatal("migrate failed", err)
+        }
+        if len(applied) == 0 {
+            slog.Info("schema is up to date")
+        }
+    case cmd == "status" && len(args) == 1:
+        status, err := migrator.Status(ctx)
+        if err != nil {
+            fatal("migrate failed", err)
+        }
+        fmt.Printf("current version: %d\nlatest version:  %d\n", status.Current, status.Latest)
+        if status.Untracked {
+            fmt.Println("no migration history: run `gateway migrate baseline <version>`")
+        }
+        for _, m := range status.Pending {
+            fmt.Printf("pending: %03d_%s\n", m.Version, m.Name)
+        }
+    case cmd == "baseline" && len(args) == 2:
+        version, err := strconv.Atoi(args[1])
+        if err != nil || version <= 0 {
+            fmt.Fprintf(os.Stderr, "invalid baseline version %q\n", args[1])
+            os.Exit(2)
+        }
+        if err := migrator.Baseline(ctx, version); err != nil {
+            fatal("migrate failed", err)
+        }
+        slog.Info("migrations recorded as applied", "through_version", version)
+    default:
+        fmt.Fprintln(os.Stderr, "usage: gateway migrate [up|status|baseline <version>]")
+        os.Exit(2)
     }
-    log.Println("Server stopped")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vnmchuo/llm-gateway/config"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/server"
+	"github.com/vnmchuo/llm-gateway/internal/telemetry"
+)
+
+// worker runs async job workers without the tenant or admin APIs; only
+// /healthz is served, for orchestrator probes. Equivalent to
+// `gateway serve --role=worker`.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("failed to init logger: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracer, err := telemetry.InitTracer("llm-gateway-worker", cfg)
+	if err != nil {
+		fatal("failed to init tracer", err)
+	}
+	defer shutdownTracer()
+
+	shutdownMeter, err := telemetry.InitMeter("llm-gateway-worker", cfg)
+	if err != nil {
+		fatal("failed to init meter", err)
+	}
+	defer shutdownMeter()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv, err := server.New(ctx, cfg, server.WithRole(server.RoleWorker))
+	if err != nil {
+		fatal("failed to start worker", err)
+	}
+	slog.Info("worker started")
+	if err := srv.Run(ctx); err != nil {
+		slog.Error("worker error", "err", err)
+	}
+	slog.Info("worker stopped")
+}
+
+// fatal logs err and exits, for startup failures after the logger is set up.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
@@ -1,9 +1,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,38 +20,334 @@ type Config struct {
 	// Server
 	Port string // default: 8080
 
+	// Logs are structured lines on stderr: LogFormat "json" (default) or
+	// "text", at LogLevel "debug", "info" (default), "warn" or "error"
+	LogFormat string
+	LogLevel  string
+
+	// GET /readyz checks Postgres and Redis, each cut off after
+	// ReadinessTimeout; with ReadinessProbeProviders it also requires at
+	// least one provider to answer a model list request
+	ReadinessTimeout        time.Duration // default: 2s
+	ReadinessProbeProviders bool          // default: false
+
+	// ConfigFile is the dotenv file read on start and on every reload;
+	// variables set in the process environment take precedence over it.
+	// ConfigWatchInterval polls it for changes, 0 = reload on SIGHUP only.
+	ConfigFile          string        // default: .env
+	ConfigWatchInterval time.Duration // default: 0
+
 	// Database
 	PostgresDSN string
+	// MigrateOnStart applies pending schema migrations at startup instead of
+	// refusing to start until `gateway migrate` has run; default: false
+	MigrateOnStart bool
 
 	// Cache
 	RedisAddr string
 
+	// Multi-region: StateMode "global" (default) keeps all state in REDIS_ADDR;
+	// "regional" treats REDIS_ADDR as region-local and reconciles rate limits
+	// through GlobalRedisAddr off the request path.
+	StateMode         string
+	Region            string
+	GlobalRedisAddr   string
+	ReconcileInterval time.Duration // default: 5s
+
 	// Providers
 	OpenAIAPIKey    string
 	GeminiAPIKey    string
 	AnthropicAPIKey string
+	// CohereAPIKey enables the Cohere provider; empty leaves it out unless
+	// PROVIDER_API_KEYS pools keys for it
+	CohereAPIKey string
+
+	// GeminiStreamMode is "sse" (default) or "json" for proxies that strip SSE
+	GeminiStreamMode string
+
+	// Self-hosted Ollama/vLLM endpoint; empty OllamaBaseURL disables it
+	OllamaBaseURL string
+	OllamaAPIMode string   // "native" (default) or "openai"
+	OllamaAPIKey  string   // optional bearer token (vLLM --api-key)
+	OllamaModels  []string // models served by the endpoint; empty lists the endpoint's
+
+	// AdminToken guards /admin endpoints; empty disables them unless
+	// AdminClientCA is set
+	AdminToken string
+	// AdminPort moves /admin and /metrics to a listener of their own; empty
+	// serves them on Port. AdminTLSCert and AdminTLSKey serve it over TLS,
+	// and AdminClientCA (requires TLS) admits only clients with a
+	// certificate it signed, in place of or on top of AdminToken.
+	AdminPort     string
+	AdminTLSCert  string
+	AdminTLSKey   string
+	AdminClientCA string
+	// MetricsToken is the bearer token /metrics requires when it shares the
+	// public listener (no AdminPort); empty falls back to AdminToken, and
+	// without either /metrics isn't served there.
+	MetricsToken string
+
+	// JWT authentication next to API keys; empty OIDCJWKSURL disables it.
+	// Tokens carry the tenant ID in OIDCTenantClaim, a rate limit tier
+	// from OIDCRateLimitTiers in OIDCTierClaim and their scopes in
+	// OIDCScopeClaim; tokens granting none get OIDCDefaultScopes.
+	OIDCJWKSURL        string
+	OIDCIssuer         string                   // required "iss" when set
+	OIDCAudience       string                   // required "aud" when set
+	OIDCTenantClaim    string                   // default: tenant_id
+	OIDCTierClaim      string                   // default: tier
+	OIDCRateLimitTiers map[string]RateLimitTier // from "free=10000|60,pro=500000|3000"
+	OIDCJWKSRefresh    time.Duration            // default: 1h
+	OIDCScopeClaim     string                   // default: scope
+	OIDCDefaultScopes  []string                 // default: chat:write,jobs:read,models:read,usage:read
 
 	// Observability
 	OTELExporterType     string // "stdout" or "otlp"
 	OTELExporterEndpoint string // default: "localhost:4317"
+	// TraceIdentities is how spans record tenant and API key IDs: "plain"
+	// (default) or "hash", an HMAC keyed with TraceIdentitySalt
+	TraceIdentities   string
+	TraceIdentitySalt string
 
 	// Rate Limiting
 	DefaultRateLimitTPM int64 // tokens per minute, default: 100000
+	// BatchRateLimitShare sizes the pool X-Priority: batch requests draw
+	// from, as a share of the key's or tenant's limits; default: 0.5
+	BatchRateLimitShare float64
+
+	// Exact-match response cache in Redis
+	ResponseCacheEnabled bool
+	ResponseCacheTTL     time.Duration // default: 1h
+
+	// Streaming
+	StreamUsageTrailers bool // send final usage/cost as HTTP trailers, default: false
+	// MaxStreamDuration ends longer streams, 0 = no limit (tenants may set
+	// their own); StreamCheckpointInterval logs a running stream's usage
+	// this often, 0 = only when it ends. Both default to 0.
+	MaxStreamDuration        time.Duration
+	StreamCheckpointInterval time.Duration
+	// BroadcastStreamTTL is how long a broadcast stream can be subscribed to
+	// after its last frame, default: 10m.
+	BroadcastStreamTTL time.Duration
+
+	// HTTP server
+	MaxHeaderBytes int // request header size limit, default: 1 MiB
+
+	// Usage log writes
+	UsageMaxInFlight  int           // concurrent background writes, default: 256
+	UsageWriteTimeout time.Duration // per write, default: 5s
+
+	// KeyLastUsedInterval batches API key last_used_at writes, default: 1m
+	KeyLastUsedInterval time.Duration
+
+	// ModelPricesRefresh is how often the model_prices table is reloaded, default: 1m
+	ModelPricesRefresh time.Duration
+	// ModelCatalogTTL is how long model lists discovered from upstreams are
+	// cached in Redis; they are refreshed every half TTL, default: 5m
+	ModelCatalogTTL time.Duration
+
+	// Shadow traffic
+	ShadowMaxInFlight int           // concurrent shadow requests, default: 32
+	ShadowTimeout     time.Duration // per shadow request, default: 60s
+
+	// Batch completions
+	BatchMaxItems    int // requests per batch, default: 100
+	BatchConcurrency int // batch items run at once, default: 8
+
+	// Time-to-first-token SLO for streams; a zero threshold disables alerting
+	TTFTSLOThreshold time.Duration            // default: 0
+	TTFTSLOModels    map[string]time.Duration // per-model thresholds, from "gpt-4o=1s,claude-3-opus-20240229=3s"
+	TTFTSLOTarget    float64                  // share of streams within the threshold, default: 0.95
+	TTFTSLOWindow    time.Duration            // default: 5m
+
+	// Share of streams, in percent, whose chunk gaps and sizes are recorded
+	StreamMetricsSamplePercent float64 // default: 10
+
+	// Routing fallback
+	RouterMaxAttempts    int           // providers tried per request, default: 3
+	RouterAttemptTimeout time.Duration // per-attempt timeout, 0 = none; default: 60s
+
+	// Routing strategy: cost, latency, weighted or priority; tenants may override
+	RoutingStrategy string         // default: cost
+	RoutingWeights  map[string]int // provider -> weight, from "openai=3,claude=1"
+	RoutingPriority []string       // provider names, most preferred first
+
+	// Providers whose recent calls fail or slow down past these thresholds
+	// are tried after the others; 0 disables either check
+	RoutingDegradedErrorRate     float64 // EWMA share of failed calls, default: 0.3
+	RoutingDegradedLatencyFactor float64 // recent over normal latency, default: 3
+	// Share routing statistics with other replicas through REDIS_ADDR
+	RoutingStatsShared       bool
+	RoutingStatsSyncInterval time.Duration // default: 10s
+
+	// Virtual model names, e.g. "fast" -> openai/gpt-4o-mini, then gemini/gemini-1.5-flash
+	ModelAliases map[string][]ModelTarget // from "fast=openai/gpt-4o-mini|gemini/gemini-1.5-flash,..."
+
+	// Passthrough payload fields allowed per provider beyond its built-in list
+	ProviderExtraFields map[string][]string // from "openai=logit_bias|seed,claude=top_k"
+
+	// Regions each provider's models are available in, as reported by GET /v1/models/{id}
+	ProviderRegions map[string][]string // from "openai=us|eu,claude=us"
+
+	// Outbound proxy, CA bundle and source IP per provider name; "*" applies
+	// to providers without their own value
+	ProviderEgress map[string]Egress
+
+	// Ordered base URLs per provider; the first is the primary and the rest
+	// take over while it fails
+	ProviderEndpoints map[string][]string // from "openai=https://a/v1|https://b/v1"
+	EndpointCooldown  time.Duration       // failed endpoint skipped for, default: 30s
+	DNSCacheTTL       time.Duration       // upstream DNS cache, 0 = off; default: 0
+
+	// Pools of upstream API keys per provider, used in place of its single
+	// key; requests rotate among them
+	ProviderAPIKeys        map[string][]string // from "openai=sk-a|sk-b,claude=sk-ant-c|sk-ant-d"
+	ProviderKeyStrategy    string              // round_robin or least_used, default: round_robin
+	ProviderKeyMaxFailures int                 // consecutive 401/403/429s that sideline a key, default: 3
+	ProviderKeyCooldown    time.Duration       // sidelined key skipped for, default: 1m
+
+	// Upstream HTTP clients, shared by every provider
+	ProviderConnectTimeout  time.Duration // dial and TLS handshake, default: 10s
+	ProviderReadTimeout     time.Duration // wait for response headers, 0 = none; default: 120s
+	ProviderMaxIdleConns    int           // pooled connections per upstream host, default: 64
+	ProviderIdleConnTimeout time.Duration // default: 90s
+	ProviderMaxRetries      int           // retries on 429/5xx, 0 = off; default: 2
+	ProviderRetryBackoff    time.Duration // first backoff, doubled per retry; default: 500ms
+	ProviderRetryMaxWait    time.Duration // longest wait, incl. Retry-After; default: 10s
+
+	// Request validation
+	MaxConversationTurns int   // max messages per request, 0 = unlimited; default: 100
+	MaxRequestBytes      int64 // completion body size, 0 = unlimited; default: 10 MiB
+	MaxPromptChars       int   // characters across messages, 0 = unlimited
+	MaxOutputTokens      int   // ceiling on max_tokens, 0 = unlimited
+
+	// Retrieval (RAG) stage; per-tenant collections live in rag_configs
+	RAGEnabled bool
+	// RAGEmbeddingCacheTTL is how long query embeddings are reused, 0 = not
+	// cached; default: 24h.
+	RAGEmbeddingCacheTTL time.Duration
+
+	// Async jobs
+	JobWorkers        int // concurrent jobs per process, default: 4
+	JobCallbackSecret string
+	// WebhookAllowedHosts may receive job callbacks and security webhooks
+	// over http and at private addresses, for receivers inside the
+	// operator's network; default: none.
+	WebhookAllowedHosts []string
+	// JobVisibilityTimeout is how long a job may run unacked before another
+	// worker takes it over; JobMaxDeliveries caps attempts before dead-lettering.
+	JobVisibilityTimeout time.Duration
+	JobMaxDeliveries     int
+	// JobResultTTL is how long finished jobs are kept; 0 keeps them forever.
+	JobResultTTL time.Duration
+	// Results larger than JobResultOffloadBytes go to the S3-compatible
+	// bucket when JobResultS3Bucket is set, served via URLs valid for
+	// JobResultURLTTL.
+	JobResultOffloadBytes int
+	JobResultURLTTL       time.Duration
+	JobResultS3Endpoint   string
+	JobResultS3Bucket     string
+	JobResultS3AccessKey  string
+	JobResultS3SecretKey  string
+	JobResultS3Insecure   bool // plain HTTP, e.g. a local MinIO
+
+	// Payload audit log for tenants with audit_payloads set: "postgres",
+	// "s3" (AuditS3* bucket) or "" to disable. Exchanges are purged after
+	// AuditPayloadTTL.
+	AuditPayloadStore string
+	AuditPayloadTTL   time.Duration // default: 720h
+	AuditS3Endpoint   string
+	AuditS3Bucket     string
+	AuditS3AccessKey  string
+	AuditS3SecretKey  string
+	AuditS3Insecure   bool
+
+	// Compliance archive of final responses for tenants with
+	// archive_transcripts set: "s3" (ArchiveS3* bucket) and/or "kafka"
+	// (ArchiveKafka* topic); none disables it. Worker processes deliver
+	// queued transcripts, retrying each up to ArchiveMaxDeliveries times.
+	ArchiveSinks         []string // from "s3,kafka"
+	ArchiveS3Endpoint    string
+	ArchiveS3Bucket      string
+	ArchiveS3AccessKey   string
+	ArchiveS3SecretKey   string
+	ArchiveS3Insecure    bool
+	ArchiveKafkaBrokers  []string // from "host:9092,host:9092"
+	ArchiveKafkaTopic    string
+	ArchiveMaxDeliveries int // default: 5
+
+	// Tool execution
+	ToolHandlers      map[string]string // tool name -> callback URL, from "name=url,name=url"
+	ToolSigningSecret string
+	ToolMaxIterations int // default: 5
+
+	// Usage-based tiering: rules that move tenants between plans, checked by
+	// worker processes every TieringInterval; none disables it
+	TieringRules    []TieringRule // from "free>pro=spend_usd>=20|7|confirm,..."
+	TieringInterval time.Duration // default: 1h
 }
 
 func Load() (*Config, error) {
-	// Load .env file if present (non-fatal if missing)
-	_ = godotenv.Load()
+	// Load the config file if present (non-fatal if missing)
+	configFile := getEnv("CONFIG_FILE", ".env")
+	if err := loadFile(configFile); err != nil {
+		return nil, fmt.Errorf("invalid CONFIG_FILE: %w", err)
+	}
 
 	cfg := &Config{
+		ConfigFile:           configFile,
 		Port:                 getEnv("PORT", "8080"),
+		LogFormat:            getEnv("LOG_FORMAT", "json"),
+		LogLevel:             getEnv("LOG_LEVEL", "info"),
 		PostgresDSN:          os.Getenv("POSTGRES_DSN"),
 		RedisAddr:            os.Getenv("REDIS_ADDR"),
 		OpenAIAPIKey:         os.Getenv("OPENAI_API_KEY"),
 		GeminiAPIKey:         os.Getenv("GEMINI_API_KEY"),
 		AnthropicAPIKey:      os.Getenv("ANTHROPIC_API_KEY"),
+		CohereAPIKey:         os.Getenv("COHERE_API_KEY"),
+		GeminiStreamMode:     getEnv("GEMINI_STREAM_MODE", "sse"),
+		OllamaBaseURL:        os.Getenv("OLLAMA_BASE_URL"),
+		OllamaAPIMode:        getEnv("OLLAMA_API_MODE", "native"),
+		OllamaAPIKey:         os.Getenv("OLLAMA_API_KEY"),
+		AdminToken:           os.Getenv("ADMIN_TOKEN"),
+		AdminPort:            os.Getenv("ADMIN_PORT"),
+		MetricsToken:         os.Getenv("METRICS_TOKEN"),
+		AdminTLSCert:         os.Getenv("ADMIN_TLS_CERT"),
+		AdminTLSKey:          os.Getenv("ADMIN_TLS_KEY"),
+		AdminClientCA:        os.Getenv("ADMIN_CLIENT_CA"),
+		OIDCJWKSURL:          os.Getenv("OIDC_JWKS_URL"),
+		OIDCIssuer:           os.Getenv("OIDC_ISSUER"),
+		OIDCAudience:         os.Getenv("OIDC_AUDIENCE"),
+		OIDCTenantClaim:      getEnv("OIDC_TENANT_CLAIM", "tenant_id"),
+		OIDCTierClaim:        getEnv("OIDC_TIER_CLAIM", "tier"),
+		OIDCScopeClaim:       getEnv("OIDC_SCOPE_CLAIM", "scope"),
+		StateMode:            getEnv("STATE_MODE", "global"),
+		Region:               os.Getenv("REGION"),
+		GlobalRedisAddr:      os.Getenv("GLOBAL_REDIS_ADDR"),
 		OTELExporterType:     getEnv("OTEL_EXPORTER_TYPE", "stdout"),
 		OTELExporterEndpoint: getEnv("OTEL_EXPORTER_ENDPOINT", "localhost:4317"),
+		TraceIdentities:      getEnv("TRACE_IDENTITIES", "plain"),
+		TraceIdentitySalt:    os.Getenv("TRACE_IDENTITY_SALT"),
+	}
+
+	watch, err := time.ParseDuration(getEnv("CONFIG_WATCH_INTERVAL", "0"))
+	if err != nil || watch < 0 {
+		return nil, fmt.Errorf("invalid CONFIG_WATCH_INTERVAL: must be a non-negative duration")
+	}
+	cfg.ConfigWatchInterval = watch
+	cfg.MigrateOnStart = getEnv("MIGRATE_ON_START", "false") == "true"
+
+	if cfg.AdminPort != "" && cfg.AdminPort == cfg.Port {
+		return nil, fmt.Errorf("invalid ADMIN_PORT: must differ from PORT")
+	}
+	if (cfg.AdminTLSCert == "") != (cfg.AdminTLSKey == "") {
+		return nil, fmt.Errorf("invalid ADMIN_TLS_CERT/ADMIN_TLS_KEY: set both or neither")
+	}
+	if cfg.AdminTLSCert != "" && cfg.AdminPort == "" {
+		return nil, fmt.Errorf("invalid ADMIN_TLS_CERT: requires ADMIN_PORT")
+	}
+	if cfg.AdminClientCA != "" && cfg.AdminTLSCert == "" {
+		return nil, fmt.Errorf("invalid ADMIN_CLIENT_CA: requires ADMIN_TLS_CERT and ADMIN_TLS_KEY")
 	}
 
 	// Rate Limiting Default
@@ -53,8 +357,455 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid DEFAULT_RATE_LIMIT_TPM: %w", err)
 	}
 	cfg.DefaultRateLimitTPM = tpm
+	cfg.BatchRateLimitShare, err = strconv.ParseFloat(getEnv("BATCH_RATE_LIMIT_SHARE", "0.5"), 64)
+	if err != nil || cfg.BatchRateLimitShare <= 0 || cfg.BatchRateLimitShare > 1 {
+		return nil, fmt.Errorf("invalid BATCH_RATE_LIMIT_SHARE: must be in (0, 1]")
+	}
+
+	cfg.RouterMaxAttempts, err = strconv.Atoi(getEnv("ROUTER_MAX_ATTEMPTS", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTER_MAX_ATTEMPTS: %w", err)
+	}
+	cfg.RouterAttemptTimeout, err = time.ParseDuration(getEnv("ROUTER_ATTEMPT_TIMEOUT", "60s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTER_ATTEMPT_TIMEOUT: %w", err)
+	}
+
+	cfg.RoutingStrategy = getEnv("ROUTING_STRATEGY", "cost")
+	switch cfg.RoutingStrategy {
+	case "cost", "latency", "weighted", "priority":
+	default:
+		return nil, fmt.Errorf("invalid ROUTING_STRATEGY: %q (want cost, latency, weighted or priority)", cfg.RoutingStrategy)
+	}
+	weights, err := parsePairs(os.Getenv("ROUTING_WEIGHTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTING_WEIGHTS: %w", err)
+	}
+	cfg.RoutingWeights = make(map[string]int, len(weights))
+	for name, raw := range weights {
+		w, err := strconv.Atoi(raw)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid ROUTING_WEIGHTS: weight for %s must be a non-negative integer", name)
+		}
+		cfg.RoutingWeights[name] = w
+	}
+	for _, name := range strings.Split(os.Getenv("ROUTING_PRIORITY"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.RoutingPriority = append(cfg.RoutingPriority, name)
+		}
+	}
+
+	cfg.RoutingDegradedErrorRate, err = strconv.ParseFloat(getEnv("ROUTING_DEGRADED_ERROR_RATE", "0.3"), 64)
+	if err != nil || cfg.RoutingDegradedErrorRate < 0 || cfg.RoutingDegradedErrorRate > 1 {
+		return nil, fmt.Errorf("invalid ROUTING_DEGRADED_ERROR_RATE: must be in [0, 1]")
+	}
+	cfg.RoutingDegradedLatencyFactor, err = strconv.ParseFloat(getEnv("ROUTING_DEGRADED_LATENCY_FACTOR", "3"), 64)
+	if err != nil || (cfg.RoutingDegradedLatencyFactor != 0 && cfg.RoutingDegradedLatencyFactor <= 1) {
+		return nil, fmt.Errorf("invalid ROUTING_DEGRADED_LATENCY_FACTOR: must be 0 or greater than 1")
+	}
+	cfg.RoutingStatsShared = getEnv("ROUTING_STATS_SHARED", "false") == "true"
+	cfg.RoutingStatsSyncInterval, err = time.ParseDuration(getEnv("ROUTING_STATS_SYNC_INTERVAL", "10s"))
+	if err != nil || cfg.RoutingStatsSyncInterval <= 0 {
+		return nil, fmt.Errorf("invalid ROUTING_STATS_SYNC_INTERVAL: must be a positive duration")
+	}
+
+	cfg.ModelAliases, err = parseAliases(os.Getenv("MODEL_ALIASES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MODEL_ALIASES: %w", err)
+	}
+
+	cfg.ProviderEgress, err = parseEgress()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ProviderEndpoints, err = parseEndpoints(os.Getenv("PROVIDER_ENDPOINTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_ENDPOINTS: %w", err)
+	}
+	cfg.ProviderAPIKeys, err = parseKeyPools(os.Getenv("PROVIDER_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_API_KEYS: %w", err)
+	}
+	cfg.ProviderKeyStrategy = getEnv("PROVIDER_KEY_STRATEGY", "round_robin")
+	if cfg.ProviderKeyStrategy != "round_robin" && cfg.ProviderKeyStrategy != "least_used" {
+		return nil, fmt.Errorf("invalid PROVIDER_KEY_STRATEGY: must be round_robin or least_used")
+	}
+	cfg.ProviderKeyMaxFailures, err = strconv.Atoi(getEnv("PROVIDER_KEY_MAX_FAILURES", "3"))
+	if err != nil || cfg.ProviderKeyMaxFailures <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_KEY_MAX_FAILURES: must be a positive integer")
+	}
+	cfg.ProviderKeyCooldown, err = time.ParseDuration(getEnv("PROVIDER_KEY_COOLDOWN", "1m"))
+	if err != nil || cfg.ProviderKeyCooldown <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_KEY_COOLDOWN: must be a positive duration")
+	}
+
+	cfg.EndpointCooldown, err = time.ParseDuration(getEnv("PROVIDER_ENDPOINT_COOLDOWN", "30s"))
+	if err != nil || cfg.EndpointCooldown <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_ENDPOINT_COOLDOWN: must be a positive duration")
+	}
+	cfg.DNSCacheTTL, err = time.ParseDuration(getEnv("PROVIDER_DNS_CACHE_TTL", "0"))
+	if err != nil || cfg.DNSCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_DNS_CACHE_TTL: must be a non-negative duration")
+	}
+	cfg.ProviderConnectTimeout, err = time.ParseDuration(getEnv("PROVIDER_CONNECT_TIMEOUT", "10s"))
+	if err != nil || cfg.ProviderConnectTimeout <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_CONNECT_TIMEOUT: must be a positive duration")
+	}
+	cfg.ProviderReadTimeout, err = time.ParseDuration(getEnv("PROVIDER_READ_TIMEOUT", "120s"))
+	if err != nil || cfg.ProviderReadTimeout < 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_READ_TIMEOUT: must be a non-negative duration")
+	}
+	cfg.ProviderMaxIdleConns, err = strconv.Atoi(getEnv("PROVIDER_MAX_IDLE_CONNS_PER_HOST", "64"))
+	if err != nil || cfg.ProviderMaxIdleConns <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_MAX_IDLE_CONNS_PER_HOST: must be a positive integer")
+	}
+	cfg.ProviderIdleConnTimeout, err = time.ParseDuration(getEnv("PROVIDER_IDLE_CONN_TIMEOUT", "90s"))
+	if err != nil || cfg.ProviderIdleConnTimeout <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_IDLE_CONN_TIMEOUT: must be a positive duration")
+	}
+	cfg.ProviderMaxRetries, err = strconv.Atoi(getEnv("PROVIDER_MAX_RETRIES", "2"))
+	if err != nil || cfg.ProviderMaxRetries < 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_MAX_RETRIES: must be a non-negative integer")
+	}
+	cfg.ProviderRetryBackoff, err = time.ParseDuration(getEnv("PROVIDER_RETRY_BACKOFF", "500ms"))
+	if err != nil || cfg.ProviderRetryBackoff <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_RETRY_BACKOFF: must be a positive duration")
+	}
+	cfg.ProviderRetryMaxWait, err = time.ParseDuration(getEnv("PROVIDER_RETRY_MAX_WAIT", "10s"))
+	if err != nil || cfg.ProviderRetryMaxWait <= 0 {
+		return nil, fmt.Errorf("invalid PROVIDER_RETRY_MAX_WAIT: must be a positive duration")
+	}
+
+	extraFields, err := parsePairs(os.Getenv("PROVIDER_EXTRA_FIELDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_EXTRA_FIELDS: %w", err)
+	}
+	cfg.ProviderExtraFields = make(map[string][]string, len(extraFields))
+	for name, fields := range extraFields {
+		for _, f := range strings.Split(fields, "|") {
+			if f = strings.TrimSpace(f); f != "" {
+				cfg.ProviderExtraFields[name] = append(cfg.ProviderExtraFields[name], f)
+			}
+		}
+	}
+
+	regions, err := parsePairs(os.Getenv("PROVIDER_REGIONS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_REGIONS: %w", err)
+	}
+	cfg.ProviderRegions = make(map[string][]string, len(regions))
+	for name, list := range regions {
+		for _, region := range strings.Split(list, "|") {
+			if region = strings.TrimSpace(region); region != "" {
+				cfg.ProviderRegions[name] = append(cfg.ProviderRegions[name], region)
+			}
+		}
+	}
+
+	cfg.ResponseCacheEnabled = getEnv("RESPONSE_CACHE_ENABLED", "false") == "true"
+	cfg.ResponseCacheTTL, err = time.ParseDuration(getEnv("RESPONSE_CACHE_TTL", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESPONSE_CACHE_TTL: %w", err)
+	}
+
+	cfg.StreamUsageTrailers = getEnv("STREAM_USAGE_TRAILERS", "false") == "true"
+	cfg.MaxStreamDuration, err = time.ParseDuration(getEnv("MAX_STREAM_DURATION", "0"))
+	if err != nil || cfg.MaxStreamDuration < 0 {
+		return nil, fmt.Errorf("invalid MAX_STREAM_DURATION: must be a non-negative duration")
+	}
+	cfg.StreamCheckpointInterval, err = time.ParseDuration(getEnv("STREAM_CHECKPOINT_INTERVAL", "0"))
+	if err != nil || cfg.StreamCheckpointInterval < 0 {
+		return nil, fmt.Errorf("invalid STREAM_CHECKPOINT_INTERVAL: must be a non-negative duration")
+	}
+	cfg.BroadcastStreamTTL, err = time.ParseDuration(getEnv("BROADCAST_STREAM_TTL", "10m"))
+	if err != nil || cfg.BroadcastStreamTTL <= 0 {
+		return nil, fmt.Errorf("invalid BROADCAST_STREAM_TTL: must be a positive duration")
+	}
+	cfg.MaxHeaderBytes, err = strconv.Atoi(getEnv("MAX_HEADER_BYTES", "1048576"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_HEADER_BYTES: %w", err)
+	}
+
+	cfg.UsageMaxInFlight, err = strconv.Atoi(getEnv("USAGE_MAX_IN_FLIGHT", "256"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid USAGE_MAX_IN_FLIGHT: %w", err)
+	}
+	cfg.UsageWriteTimeout, err = time.ParseDuration(getEnv("USAGE_WRITE_TIMEOUT", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid USAGE_WRITE_TIMEOUT: %w", err)
+	}
+
+	cfg.KeyLastUsedInterval, err = time.ParseDuration(getEnv("KEY_LAST_USED_INTERVAL", "1m"))
+	if err != nil || cfg.KeyLastUsedInterval <= 0 {
+		return nil, fmt.Errorf("invalid KEY_LAST_USED_INTERVAL: must be a positive duration")
+	}
+
+	if cfg.OIDCJWKSURL != "" {
+		if u, err := url.Parse(cfg.OIDCJWKSURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid OIDC_JWKS_URL: must be an http or https URL")
+		}
+	}
+	cfg.OIDCRateLimitTiers, err = parseTiers(os.Getenv("OIDC_RATE_LIMIT_TIERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC_RATE_LIMIT_TIERS: %w", err)
+	}
+	cfg.OIDCJWKSRefresh, err = time.ParseDuration(getEnv("OIDC_JWKS_REFRESH", "1h"))
+	if err != nil || cfg.OIDCJWKSRefresh <= 0 {
+		return nil, fmt.Errorf("invalid OIDC_JWKS_REFRESH: must be a positive duration")
+	}
+	for _, scope := range strings.Split(getEnv("OIDC_DEFAULT_SCOPES", "chat:write,jobs:read,models:read,usage:read"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			cfg.OIDCDefaultScopes = append(cfg.OIDCDefaultScopes, scope)
+		}
+	}
+
+	cfg.ModelPricesRefresh, err = time.ParseDuration(getEnv("MODEL_PRICES_REFRESH", "1m"))
+	if err != nil || cfg.ModelPricesRefresh <= 0 {
+		return nil, fmt.Errorf("invalid MODEL_PRICES_REFRESH: must be a positive duration")
+	}
+	cfg.ModelCatalogTTL, err = time.ParseDuration(getEnv("MODEL_CATALOG_TTL", "5m"))
+	if err != nil || cfg.ModelCatalogTTL <= 0 {
+		return nil, fmt.Errorf("invalid MODEL_CATALOG_TTL: must be a positive duration")
+	}
+
+	cfg.ReadinessTimeout, err = time.ParseDuration(getEnv("READINESS_TIMEOUT", "2s"))
+	if err != nil || cfg.ReadinessTimeout <= 0 {
+		return nil, fmt.Errorf("invalid READINESS_TIMEOUT: must be a positive duration")
+	}
+	cfg.ReadinessProbeProviders = getEnv("READINESS_PROBE_PROVIDERS", "false") == "true"
+
+	cfg.ShadowMaxInFlight, err = strconv.Atoi(getEnv("SHADOW_MAX_IN_FLIGHT", "32"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHADOW_MAX_IN_FLIGHT: %w", err)
+	}
+
+	cfg.TieringRules, err = parseTieringRules(os.Getenv("TIERING_RULES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIERING_RULES: %w", err)
+	}
+	cfg.TieringInterval, err = time.ParseDuration(getEnv("TIERING_INTERVAL", "1h"))
+	if err != nil || cfg.TieringInterval <= 0 {
+		return nil, fmt.Errorf("invalid TIERING_INTERVAL: must be a positive duration")
+	}
+	cfg.ShadowTimeout, err = time.ParseDuration(getEnv("SHADOW_TIMEOUT", "60s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHADOW_TIMEOUT: %w", err)
+	}
+
+	cfg.BatchMaxItems, err = strconv.Atoi(getEnv("BATCH_MAX_ITEMS", "100"))
+	if err != nil || cfg.BatchMaxItems <= 0 {
+		return nil, fmt.Errorf("invalid BATCH_MAX_ITEMS: must be a positive integer")
+	}
+	cfg.BatchConcurrency, err = strconv.Atoi(getEnv("BATCH_CONCURRENCY", "8"))
+	if err != nil || cfg.BatchConcurrency <= 0 {
+		return nil, fmt.Errorf("invalid BATCH_CONCURRENCY: must be a positive integer")
+	}
+
+	cfg.TTFTSLOThreshold, err = time.ParseDuration(getEnv("TTFT_SLO_THRESHOLD", "0s"))
+	if err != nil || cfg.TTFTSLOThreshold < 0 {
+		return nil, fmt.Errorf("invalid TTFT_SLO_THRESHOLD: must be a non-negative duration")
+	}
+	ttftModels, err := parsePairs(os.Getenv("TTFT_SLO_MODELS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TTFT_SLO_MODELS: %w", err)
+	}
+	cfg.TTFTSLOModels = make(map[string]time.Duration, len(ttftModels))
+	for model, raw := range ttftModels {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid TTFT_SLO_MODELS: threshold for %s must be a positive duration", model)
+		}
+		cfg.TTFTSLOModels[model] = d
+	}
+	cfg.TTFTSLOTarget, err = strconv.ParseFloat(getEnv("TTFT_SLO_TARGET", "0.95"), 64)
+	if err != nil || cfg.TTFTSLOTarget <= 0 || cfg.TTFTSLOTarget > 1 {
+		return nil, fmt.Errorf("invalid TTFT_SLO_TARGET: must be in (0, 1]")
+	}
+	cfg.TTFTSLOWindow, err = time.ParseDuration(getEnv("TTFT_SLO_WINDOW", "5m"))
+	if err != nil || cfg.TTFTSLOWindow <= 0 {
+		return nil, fmt.Errorf("invalid TTFT_SLO_WINDOW: must be a positive duration")
+	}
+	cfg.StreamMetricsSamplePercent, err = strconv.ParseFloat(getEnv("STREAM_METRICS_SAMPLE_PERCENT", "10"), 64)
+	if err != nil || cfg.StreamMetricsSamplePercent < 0 || cfg.StreamMetricsSamplePercent > 100 {
+		return nil, fmt.Errorf("invalid STREAM_METRICS_SAMPLE_PERCENT: must be between 0 and 100")
+	}
+
+	cfg.ReconcileInterval, err = time.ParseDuration(getEnv("RECONCILE_INTERVAL", "5s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_INTERVAL: %w", err)
+	}
+
+	cfg.MaxConversationTurns, err = strconv.Atoi(getEnv("MAX_CONVERSATION_TURNS", "100"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_CONVERSATION_TURNS: %w", err)
+	}
+	cfg.MaxRequestBytes, err = strconv.ParseInt(getEnv("MAX_REQUEST_BYTES", "10485760"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_REQUEST_BYTES: %w", err)
+	}
+	cfg.MaxPromptChars, err = strconv.Atoi(getEnv("MAX_PROMPT_CHARS", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_PROMPT_CHARS: %w", err)
+	}
+	cfg.MaxOutputTokens, err = strconv.Atoi(getEnv("MAX_OUTPUT_TOKENS", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_OUTPUT_TOKENS: %w", err)
+	}
+
+	cfg.RAGEnabled = getEnv("RAG_ENABLED", "false") == "true"
+	cfg.RAGEmbeddingCacheTTL, err = time.ParseDuration(getEnv("RAG_EMBEDDING_CACHE_TTL", "24h"))
+	if err != nil || cfg.RAGEmbeddingCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid RAG_EMBEDDING_CACHE_TTL: must be a non-negative duration")
+	}
+
+	// Async jobs
+	cfg.JobWorkers, err = strconv.Atoi(getEnv("JOB_WORKERS", "4"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_WORKERS: %w", err)
+	}
+	cfg.JobCallbackSecret = os.Getenv("JOB_CALLBACK_SECRET")
+	for _, host := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			cfg.WebhookAllowedHosts = append(cfg.WebhookAllowedHosts, host)
+		}
+	}
+	cfg.JobVisibilityTimeout, err = time.ParseDuration(getEnv("JOB_VISIBILITY_TIMEOUT", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_VISIBILITY_TIMEOUT: %w", err)
+	}
+	cfg.JobMaxDeliveries, err = strconv.Atoi(getEnv("JOB_MAX_DELIVERIES", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_MAX_DELIVERIES: %w", err)
+	}
+	cfg.JobResultTTL, err = time.ParseDuration(getEnv("JOB_RESULT_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_RESULT_TTL: %w", err)
+	}
+	cfg.JobResultOffloadBytes, err = strconv.Atoi(getEnv("JOB_RESULT_OFFLOAD_BYTES", "262144"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_RESULT_OFFLOAD_BYTES: %w", err)
+	}
+	cfg.JobResultURLTTL, err = time.ParseDuration(getEnv("JOB_RESULT_URL_TTL", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOB_RESULT_URL_TTL: %w", err)
+	}
+	cfg.JobResultS3Endpoint = os.Getenv("JOB_RESULT_S3_ENDPOINT")
+	cfg.JobResultS3Bucket = os.Getenv("JOB_RESULT_S3_BUCKET")
+	cfg.JobResultS3AccessKey = os.Getenv("JOB_RESULT_S3_ACCESS_KEY")
+	cfg.JobResultS3SecretKey = os.Getenv("JOB_RESULT_S3_SECRET_KEY")
+	cfg.JobResultS3Insecure = getEnv("JOB_RESULT_S3_INSECURE", "false") == "true"
+	if cfg.JobResultS3Bucket != "" && cfg.JobResultS3Endpoint == "" {
+		return nil, fmt.Errorf("JOB_RESULT_S3_ENDPOINT is required when JOB_RESULT_S3_BUCKET is set")
+	}
+
+	// Payload audit log
+	cfg.AuditPayloadStore = os.Getenv("AUDIT_PAYLOAD_STORE")
+	cfg.AuditPayloadTTL, err = time.ParseDuration(getEnv("AUDIT_PAYLOAD_TTL", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_PAYLOAD_TTL: %w", err)
+	}
+	cfg.AuditS3Endpoint = os.Getenv("AUDIT_S3_ENDPOINT")
+	cfg.AuditS3Bucket = os.Getenv("AUDIT_S3_BUCKET")
+	cfg.AuditS3AccessKey = os.Getenv("AUDIT_S3_ACCESS_KEY")
+	cfg.AuditS3SecretKey = os.Getenv("AUDIT_S3_SECRET_KEY")
+	cfg.AuditS3Insecure = getEnv("AUDIT_S3_INSECURE", "false") == "true"
+	switch cfg.AuditPayloadStore {
+	case "", "postgres":
+	case "s3":
+		if cfg.AuditS3Endpoint == "" || cfg.AuditS3Bucket == "" {
+			return nil, fmt.Errorf("AUDIT_S3_ENDPOINT and AUDIT_S3_BUCKET are required when AUDIT_PAYLOAD_STORE=s3")
+		}
+	default:
+		return nil, fmt.Errorf("invalid AUDIT_PAYLOAD_STORE: %q (want postgres or s3)", cfg.AuditPayloadStore)
+	}
+
+	// Compliance archive
+	cfg.ArchiveS3Endpoint = os.Getenv("ARCHIVE_S3_ENDPOINT")
+	cfg.ArchiveS3Bucket = os.Getenv("ARCHIVE_S3_BUCKET")
+	cfg.ArchiveS3AccessKey = os.Getenv("ARCHIVE_S3_ACCESS_KEY")
+	cfg.ArchiveS3SecretKey = os.Getenv("ARCHIVE_S3_SECRET_KEY")
+	cfg.ArchiveS3Insecure = getEnv("ARCHIVE_S3_INSECURE", "false") == "true"
+	cfg.ArchiveKafkaTopic = os.Getenv("ARCHIVE_KAFKA_TOPIC")
+	for _, b := range strings.Split(os.Getenv("ARCHIVE_KAFKA_BROKERS"), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			cfg.ArchiveKafkaBrokers = append(cfg.ArchiveKafkaBrokers, b)
+		}
+	}
+	for _, sink := range strings.Split(os.Getenv("ARCHIVE_SINKS"), ",") {
+		switch sink = strings.TrimSpace(sink); sink {
+		case "":
+			continue
+		case "s3":
+			if cfg.ArchiveS3Endpoint == "" || cfg.ArchiveS3Bucket == "" {
+				return nil, fmt.Errorf("ARCHIVE_S3_ENDPOINT and ARCHIVE_S3_BUCKET are required when ARCHIVE_SINKS has s3")
+			}
+		case "kafka":
+			if len(cfg.ArchiveKafkaBrokers) == 0 || cfg.ArchiveKafkaTopic == "" {
+				return nil, fmt.Errorf("ARCHIVE_KAFKA_BROKERS and ARCHIVE_KAFKA_TOPIC are required when ARCHIVE_SINKS has kafka")
+			}
+		default:
+			return nil, fmt.Errorf("invalid ARCHIVE_SINKS: unknown sink %q (want s3 or kafka)", sink)
+		}
+		cfg.ArchiveSinks = append(cfg.ArchiveSinks, sink)
+	}
+	cfg.ArchiveMaxDeliveries, err = strconv.Atoi(getEnv("ARCHIVE_MAX_DELIVERIES", "5"))
+	if err != nil || cfg.ArchiveMaxDeliveries <= 0 {
+		return nil, fmt.Errorf("invalid ARCHIVE_MAX_DELIVERIES: must be a positive integer")
+	}
+
+	// Tool execution
+	cfg.ToolHandlers, err = parsePairs(os.Getenv("TOOL_HANDLERS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOOL_HANDLERS: %w", err)
+	}
+	cfg.ToolSigningSecret = os.Getenv("TOOL_SIGNING_SECRET")
+	cfg.ToolMaxIterations, err = strconv.Atoi(getEnv("TOOL_MAX_ITERATIONS", "5"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOOL_MAX_ITERATIONS: %w", err)
+	}
+	if len(cfg.ToolHandlers) > 0 && cfg.ToolSigningSecret == "" {
+		return nil, fmt.Errorf("TOOL_SIGNING_SECRET is required when TOOL_HANDLERS is set")
+	}
+
+	// Self-hosted models
+	for _, m := range strings.Split(os.Getenv("OLLAMA_MODELS"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			cfg.OllamaModels = append(cfg.OllamaModels, m)
+		}
+	}
+	if cfg.OllamaAPIMode != "native" && cfg.OllamaAPIMode != "openai" {
+		return nil, fmt.Errorf("invalid OLLAMA_API_MODE: %q (want native or openai)", cfg.OllamaAPIMode)
+	}
 
 	// Validation
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		return nil, fmt.Errorf("invalid LOG_FORMAT: %q (want json or text)", cfg.LogFormat)
+	}
+	if err := new(slog.Level).UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %q (want debug, info, warn or error)", cfg.LogLevel)
+	}
+	switch cfg.TraceIdentities {
+	case "plain":
+	case "hash":
+		if cfg.TraceIdentitySalt == "" {
+			return nil, fmt.Errorf("TRACE_IDENTITIES=hash requires TRACE_IDENTITY_SALT")
+		}
+	default:
+		return nil, fmt.Errorf("invalid TRACE_IDENTITIES: %q (want plain or hash)", cfg.TraceIdentities)
+	}
+	if cfg.GeminiStreamMode != "sse" && cfg.GeminiStreamMode != "json" {
+		return nil, fmt.Errorf("invalid GEMINI_STREAM_MODE: %q (want sse or json)", cfg.GeminiStreamMode)
+	}
+	switch cfg.StateMode {
+	case "global":
+	case "regional":
+		if cfg.Region == "" || cfg.GlobalRedisAddr == "" {
+			return nil, fmt.Errorf("REGION and GLOBAL_REDIS_ADDR are required when STATE_MODE=regional")
+		}
+	default:
+		return nil, fmt.Errorf("invalid STATE_MODE: %q (want global or regional)", cfg.StateMode)
+	}
 	if cfg.PostgresDSN == "" {
 		return nil, fmt.Errorf("POSTGRES_DSN is required")
 	}
@@ -65,9 +816,277 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// ModelTarget is a concrete model on a named provider.
+type ModelTarget struct {
+	Provider string
+	Model    string
+}
+
+// RateLimitTier is the per-minute allowance of a JWT rate limit tier; 0
+// leaves the gateway's default in place.
+type RateLimitTier struct {
+	TPM int64
+	RPM int64
+}
+
+// parseTiers parses "tier=tpm|rpm,..." into rate limit tiers; rpm may be
+// left out.
+func parseTiers(raw string) (map[string]RateLimitTier, error) {
+	pairs, err := parsePairs(raw)
+	if err != nil {
+		return nil, err
+	}
+	tiers := make(map[string]RateLimitTier, len(pairs))
+	for name, spec := range pairs {
+		tpm, rpm, _ := strings.Cut(spec, "|")
+		var t RateLimitTier
+		if t.TPM, err = strconv.ParseInt(strings.TrimSpace(tpm), 10, 64); err != nil || t.TPM < 0 {
+			return nil, fmt.Errorf("tier %s: %q is not a token limit", name, tpm)
+		}
+		if rpm != "" {
+			if t.RPM, err = strconv.ParseInt(strings.TrimSpace(rpm), 10, 64); err != nil || t.RPM < 0 {
+				return nil, fmt.Errorf("tier %s: %q is not a request limit", name, rpm)
+			}
+		}
+		tiers[name] = t
+	}
+	return tiers, nil
+}
+
+// TieringRule moves tenants on plan From to plan To once Metric compared
+// with Threshold by Op held on each of the last Days UTC days. Action
+// "move" changes the plan at once; "confirm", the default, proposes the
+// change for an operator to approve, as upgrades that change billing should.
+type TieringRule struct {
+	From      string
+	To        string
+	Metric    string // spend_usd, requests or tokens
+	Op        string // >=, >, <= or <
+	Threshold float64
+	Days      int
+	Action    string
+}
+
+// parseTieringRules parses "from>to=metric<op>threshold|days|action,..."
+// in order; action may be left out.
+func parseTieringRules(raw string) ([]TieringRule, error) {
+	var rules []TieringRule
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		plans, spec, ok := strings.Cut(entry, "=")
+		from, to, okPlans := strings.Cut(plans, ">")
+		r := TieringRule{From: strings.TrimSpace(from), To: strings.TrimSpace(to), Action: "confirm"}
+		if !ok || !okPlans || r.From == "" || r.To == "" || r.From == r.To {
+			return nil, fmt.Errorf("malformed entry %q (want from>to=condition|days)", entry)
+		}
+		parts := strings.Split(spec, "|")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("rule %s: want condition|days[|action], got %q", plans, spec)
+		}
+		cond := strings.TrimSpace(parts[0])
+		i := strings.IndexAny(cond, "<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("rule %s: malformed condition %q", plans, cond)
+		}
+		r.Metric, r.Op = strings.TrimSpace(cond[:i]), cond[i:i+1]
+		if strings.HasPrefix(cond[i+1:], "=") {
+			r.Op += "="
+		}
+		if r.Metric != "spend_usd" && r.Metric != "requests" && r.Metric != "tokens" {
+			return nil, fmt.Errorf("rule %s: unknown metric %q (want spend_usd, requests or tokens)", plans, r.Metric)
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(cond[i+len(r.Op):]), 64)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("rule %s: %q is not a threshold", plans, cond[i+len(r.Op):])
+		}
+		r.Threshold = threshold
+		if r.Days, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || r.Days <= 0 {
+			return nil, fmt.Errorf("rule %s: %q is not a number of days", plans, parts[1])
+		}
+		if len(parts) == 3 {
+			r.Action = strings.TrimSpace(parts[2])
+			if r.Action != "move" && r.Action != "confirm" {
+				return nil, fmt.Errorf("rule %s: unknown action %q (want move or confirm)", plans, r.Action)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Egress is how one provider's traffic leaves the gateway.
+type Egress struct {
+	ProxyURL string
+	CABundle string
+	BindIP   string
+}
+
+// parseEgress reads PROVIDER_PROXY_URLS, PROVIDER_CA_BUNDLES and
+// PROVIDER_BIND_IPS, each "provider=value,...".
+func parseEgress() (map[string]Egress, error) {
+	proxies, err := parsePairs(os.Getenv("PROVIDER_PROXY_URLS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_PROXY_URLS: %w", err)
+	}
+	bundles, err := parsePairs(os.Getenv("PROVIDER_CA_BUNDLES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_CA_BUNDLES: %w", err)
+	}
+	ips, err := parsePairs(os.Getenv("PROVIDER_BIND_IPS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROVIDER_BIND_IPS: %w", err)
+	}
+
+	egress := make(map[string]Egress)
+	for name, raw := range proxies {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return nil, fmt.Errorf("invalid PROVIDER_PROXY_URLS: %s must be an http, https or socks5 URL", name)
+		}
+		e := egress[name]
+		e.ProxyURL = raw
+		egress[name] = e
+	}
+	for name, path := range bundles {
+		e := egress[name]
+		e.CABundle = path
+		egress[name] = e
+	}
+	for name, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid PROVIDER_BIND_IPS: %q for %s is not an IP address", ip, name)
+		}
+		e := egress[name]
+		e.BindIP = ip
+		egress[name] = e
+	}
+	return egress, nil
+}
+
+// parseEndpoints parses "provider=url|url,..." into each provider's base URLs,
+// primary first.
+func parseEndpoints(raw string) (map[string][]string, error) {
+	pairs, err := parsePairs(raw)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make(map[string][]string, len(pairs))
+	for name, list := range pairs {
+		for _, raw := range strings.Split(list, "|") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			u, err := url.Parse(raw)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("%s: %q must be an http or https URL", name, raw)
+			}
+			endpoints[name] = append(endpoints[name], raw)
+		}
+	}
+	return endpoints, nil
+}
+
+// parseKeyPools parses "provider=key|key,..." into each provider's API keys.
+func parseKeyPools(raw string) (map[string][]string, error) {
+	pairs, err := parsePairs(raw)
+	if err != nil {
+		// parsePairs quotes the entry, which would put keys in the logs.
+		return nil, fmt.Errorf("malformed entry (want provider=key|key)")
+	}
+	pools := make(map[string][]string, len(pairs))
+	for name, list := range pairs {
+		for _, key := range strings.Split(list, "|") {
+			if key = strings.TrimSpace(key); key != "" {
+				pools[name] = append(pools[name], key)
+			}
+		}
+	}
+	return pools, nil
+}
+
+// parseAliases parses "alias=provider/model|provider/model,..." into each
+// alias's targets in fallback order.
+func parseAliases(raw string) (map[string][]ModelTarget, error) {
+	pairs, err := parsePairs(raw)
+	if err != nil {
+		return nil, err
+	}
+	aliases := make(map[string][]ModelTarget, len(pairs))
+	for alias, spec := range pairs {
+		seen := make(map[string]bool)
+		for _, target := range strings.Split(spec, "|") {
+			providerName, model, ok := strings.Cut(strings.TrimSpace(target), "/")
+			if !ok || providerName == "" || model == "" {
+				return nil, fmt.Errorf("alias %s: malformed target %q (want provider/model)", alias, target)
+			}
+			if seen[providerName] {
+				return nil, fmt.Errorf("alias %s: provider %s listed twice", alias, providerName)
+			}
+			seen[providerName] = true
+			aliases[alias] = append(aliases[alias], ModelTarget{Provider: providerName, Model: model})
+		}
+	}
+	return aliases, nil
+}
+
+var (
+	fileMu   sync.Mutex
+	fromFile = make(map[string]bool) // variables set by loadFile
+)
+
+// loadFile sets the variables in the dotenv file at path, except those the
+// process environment sets itself. Variables an earlier load set and the
+// file no longer has are unset, so a reload sees them removed.
+func loadFile(path string) error {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		values, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	for key := range fromFile {
+		if _, ok := values[key]; !ok {
+			_ = os.Unsetenv(key)
+			delete(fromFile, key)
+		}
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !fromFile[key] {
+			continue
+		}
+		_ = os.Setenv(key, value)
+		fromFile[key] = true
+	}
+	return nil
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
 	}
 	return fallback
 }
+
+// parsePairs parses "k1=v1,k2=v2" into a map.
+func parsePairs(raw string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("malformed entry %q", pair)
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
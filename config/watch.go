@@ -0,0 +1,231 @@
+package config
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadable are the fields a Watcher applies to the running gateway.
+// Changes to any other field are reported as pending a restart.
+var reloadable = map[string]bool{
+	"OpenAIAPIKey":        true,
+	"GeminiAPIKey":        true,
+	"AnthropicAPIKey":     true,
+	"CohereAPIKey":        true,
+	"OllamaAPIKey":        true,
+	"ProviderAPIKeys":     true,
+	"RoutingStrategy":     true,
+	"RoutingWeights":      true,
+	"RoutingPriority":     true,
+	"DefaultRateLimitTPM": true,
+}
+
+// secrets are the fields Redacted hides.
+var secrets = map[string]bool{
+	"OpenAIAPIKey":         true,
+	"GeminiAPIKey":         true,
+	"AnthropicAPIKey":      true,
+	"CohereAPIKey":         true,
+	"OllamaAPIKey":         true,
+	"AdminToken":           true,
+	"MetricsToken":         true,
+	"JobCallbackSecret":    true,
+	"JobResultS3AccessKey": true,
+	"JobResultS3SecretKey": true,
+	"AuditS3AccessKey":     true,
+	"AuditS3SecretKey":     true,
+	"ArchiveS3AccessKey":   true,
+	"ArchiveS3SecretKey":   true,
+	"ToolSigningSecret":    true,
+}
+
+const redacted = "[redacted]"
+
+var dsnPassword = regexp.MustCompile(`password=\S+`)
+
+// Redacted returns the configuration by field name for display: secrets and
+// credentials in URLs are hidden, durations are written as strings.
+func (c *Config) Redacted() map[string]any {
+	out := make(map[string]any)
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		value := v.Field(i).Interface()
+		switch f := value.(type) {
+		case string:
+			if secrets[name] && f != "" {
+				value = redacted
+			} else if name == "PostgresDSN" {
+				value = redactURL(f)
+			}
+		case time.Duration:
+			value = f.String()
+		case map[string]time.Duration:
+			durations := make(map[string]string, len(f))
+			for k, d := range f {
+				durations[k] = d.String()
+			}
+			value = durations
+		case map[string][]string:
+			if name == "ProviderAPIKeys" {
+				pools := make(map[string][]string, len(f))
+				for k, keys := range f {
+					for range keys {
+						pools[k] = append(pools[k], redacted)
+					}
+				}
+				value = pools
+			}
+		case map[string]Egress:
+			egress := make(map[string]Egress, len(f))
+			for k, e := range f {
+				e.ProxyURL = redactURL(e.ProxyURL)
+				egress[k] = e
+			}
+			value = egress
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// redactURL hides the password of a URL, or of a key=value connection
+// string.
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(raw, "password=xxxxx")
+}
+
+// Status is a Watcher's configuration and how it got there.
+type Status struct {
+	Config         map[string]any `json:"config"` // redacted
+	LoadedAt       time.Time      `json:"loaded_at"`
+	RestartPending []string       `json:"restart_pending"` // changed fields not applied until restart
+	LastError      string         `json:"last_reload_error,omitempty"`
+}
+
+// Watcher reloads the configuration on SIGHUP and, with ConfigWatchInterval,
+// when ConfigFile changes. Reloadable fields are handed to apply; the others
+// keep their startup value.
+type Watcher struct {
+	apply func(ctx context.Context, cfg *Config)
+
+	mu       sync.Mutex
+	current  *Config
+	loadedAt time.Time
+	pending  []string
+	lastErr  error
+}
+
+func NewWatcher(cfg *Config, apply func(ctx context.Context, cfg *Config)) *Watcher {
+	return &Watcher{apply: apply, current: cfg, loadedAt: time.Now()}
+}
+
+// Current is the configuration in effect.
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := Status{Config: w.current.Redacted(), LoadedAt: w.loadedAt, RestartPending: w.pending}
+	if s.RestartPending == nil {
+		s.RestartPending = []string{}
+	}
+	if w.lastErr != nil {
+		s.LastError = w.lastErr.Error()
+	}
+	return s
+}
+
+// Reload loads the configuration again and applies what changed. An invalid
+// configuration is rejected as a whole and the current one kept.
+func (w *Watcher) Reload(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	loaded, err := Load()
+	if err != nil {
+		w.lastErr = err
+		return err
+	}
+
+	next := *w.current
+	var applied, pending []string
+	cur, fresh, out := reflect.ValueOf(w.current).Elem(), reflect.ValueOf(loaded).Elem(), reflect.ValueOf(&next).Elem()
+	for i := 0; i < cur.NumField(); i++ {
+		name := cur.Type().Field(i).Name
+		if reflect.DeepEqual(cur.Field(i).Interface(), fresh.Field(i).Interface()) {
+			continue
+		}
+		if reloadable[name] {
+			out.Field(i).Set(fresh.Field(i))
+			applied = append(applied, name)
+		} else {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+
+	w.apply(ctx, &next)
+	w.current, w.loadedAt, w.pending, w.lastErr = &next, time.Now(), pending, nil
+	log.Printf("config: reloaded, applied %v", applied)
+	if len(pending) > 0 {
+		log.Printf("config: %v changed and take effect on restart", pending)
+	}
+	return nil
+}
+
+// Run reloads on SIGHUP, and on changes to ConfigFile when watching it,
+// until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	cfg := w.Current()
+	var poll <-chan time.Time
+	if cfg.ConfigWatchInterval > 0 {
+		ticker := time.NewTicker(cfg.ConfigWatchInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	modTime := fileModTime(cfg.ConfigFile)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-poll:
+			t := fileModTime(cfg.ConfigFile)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+		}
+		if err := w.Reload(ctx); err != nil {
+			log.Printf("config: reload failed, keeping the current configuration: %v", err)
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
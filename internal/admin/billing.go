@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+)
+
+// maxInvoiceBytes bounds an uploaded usage export.
+const maxInvoiceBytes = 32 << 20
+
+var errEmptyInvoice = errors.New("invoice has no usage rows")
+
+// WithBilling enables reconciling provider invoices against recorded usage.
+func WithBilling(store billing.Store) Option {
+	return func(h *Handler) {
+		h.billing = store
+	}
+}
+
+// HandleReconcile serves POST /admin/billing/reconcile?format=openai&threshold=0.02.
+// The body is the provider's usage export as CSV; the response compares it
+// with gateway-recorded usage per UTC day and model and flags the rows that
+// differ by more than threshold.
+func (h *Handler) HandleReconcile(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	provider, ok := billing.InvoiceProvider(format)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "format must be openai or anthropic"})
+		return
+	}
+	threshold := billing.DefaultReconcileThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t >= 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "threshold must be a fraction between 0 and 1"})
+			return
+		}
+		threshold = t
+	}
+
+	invoice, err := billing.ParseInvoice(format, http.MaxBytesReader(w, r.Body, maxInvoiceBytes))
+	if err == nil && len(invoice) == 0 {
+		err = errEmptyInvoice
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	from, to := billing.InvoicePeriod(invoice)
+	recorded, err := h.billing.GetDailyUsageByModel(r.Context(), provider, from, to)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(billing.Reconcile(provider, invoice, recorded, threshold))
+}
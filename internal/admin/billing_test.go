@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+)
+
+type mockBillingStore struct {
+	billing.Store
+	provider string
+	from, to time.Time
+	recorded []billing.ModelDayUsage
+}
+
+func (m *mockBillingStore) GetDailyUsageByModel(ctx context.Context, provider string, from, to time.Time) ([]billing.ModelDayUsage, error) {
+	m.provider, m.from, m.to = provider, from, to
+	return m.recorded, nil
+}
+
+func TestReconcile_ComparesInvoiceWithRecordedUsage(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &mockBillingStore{recorded: []billing.ModelDayUsage{
+		{Day: day, Provider: "claude", Model: "claude-3-5-sonnet-20241022", InputTokens: 500, OutputTokens: 100, CostUSD: 0.002},
+	}}
+	h := NewHandler(&mockKeyStore{}, WithBilling(store))
+
+	body := "usage_date_utc,model_version,input_tokens,output_tokens,cost_usd\n2025-03-01,claude-3-5-sonnet-20241022,500,100,0.03\n"
+	w := httptest.NewRecorder()
+	h.HandleReconcile(w, httptest.NewRequest("POST", "/admin/billing/reconcile?format=anthropic", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rec billing.Reconciliation
+	if err := json.Unmarshal(w.Body.Bytes(), &rec); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Flagged != 1 || len(rec.Days) != 1 || rec.Days[0].CostDiffUSD <= 0 {
+		t.Errorf("Expected the overbilled day flagged, got %+v", rec)
+	}
+	if store.provider != "claude" || !store.from.Equal(day) || !store.to.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("Expected recorded usage for the invoice period, got %s %v-%v", store.provider, store.from, store.to)
+	}
+}
+
+func TestReconcile_RejectsBadInput(t *testing.T) {
+	h := NewHandler(&mockKeyStore{}, WithBilling(&mockBillingStore{}))
+	for _, target := range []string{
+		"/admin/billing/reconcile?format=azure",
+		"/admin/billing/reconcile?format=openai&threshold=5",
+		"/admin/billing/reconcile?format=openai",
+	} {
+		w := httptest.NewRecorder()
+		h.HandleReconcile(w, httptest.NewRequest("POST", target, strings.NewReader("date,model\n")))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, w.Code)
+		}
+	}
+}
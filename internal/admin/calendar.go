@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+)
+
+// WithCapacityCalendar enables the provider capacity calendar endpoints.
+func WithCapacityCalendar(c *policy.Calendar) Option {
+	return func(h *Handler) {
+		h.calendar = c
+	}
+}
+
+// capacityWindowView is a window and whether the router applies it now.
+type capacityWindowView struct {
+	*policy.CapacityWindow
+	Active bool `json:"active"`
+}
+
+// HandleListCapacityWindows serves GET /admin/provider-calendar: every
+// window that hasn't ended yet.
+func (h *Handler) HandleListCapacityWindows(w http.ResponseWriter, r *http.Request) {
+	list, err := h.calendar.List(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	views := make([]capacityWindowView, 0, len(list))
+	for _, cw := range list {
+		views = append(views, capacityWindowView{CapacityWindow: cw, Active: cw.Active(now)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"windows": views})
+}
+
+// HandleAddCapacityWindow serves POST /admin/provider-calendar, scheduling a
+// provider capacity change. It applies on this replica at once and on the
+// others at their next calendar reload.
+func (h *Handler) HandleAddCapacityWindow(w http.ResponseWriter, r *http.Request) {
+	var cw policy.CapacityWindow
+	if err := json.NewDecoder(r.Body).Decode(&cw); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	err := cw.Validate()
+	if err == nil && h.providers != nil && !h.knownProvider(cw.Provider) {
+		err = fmt.Errorf("unknown provider %q", cw.Provider)
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := h.calendar.Add(r.Context(), &cw); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("admin: scheduled %s capacity for provider %s (window %d)", cw.Capacity, cw.Provider, cw.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(capacityWindowView{CapacityWindow: &cw, Active: cw.Active(time.Now())})
+}
+
+// HandleDeleteCapacityWindow serves DELETE /admin/provider-calendar/{id}.
+func (h *Handler) HandleDeleteCapacityWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid window id"})
+		return
+	}
+	if err := h.calendar.Delete(r.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, policy.ErrCapacityWindowNotFound) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("admin: removed capacity window %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// knownProvider reports whether name is a configured provider.
+func (h *Handler) knownProvider(name string) bool {
+	for _, s := range h.providers.ProviderStatuses() {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
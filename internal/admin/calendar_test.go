@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/proxy"
+)
+
+type mockCalendarStore struct {
+	windows []*policy.CapacityWindow
+}
+
+func (m *mockCalendarStore) List(ctx context.Context) ([]*policy.CapacityWindow, error) {
+	return m.windows, nil
+}
+
+func (m *mockCalendarStore) Add(ctx context.Context, w *policy.CapacityWindow) error {
+	w.ID = int64(len(m.windows) + 1)
+	m.windows = append(m.windows, w)
+	return nil
+}
+
+func (m *mockCalendarStore) Delete(ctx context.Context, id int64) error {
+	return policy.ErrCapacityWindowNotFound
+}
+
+func TestCapacityCalendar_AddAppliesAtOnce(t *testing.T) {
+	calendar := policy.NewCalendar(&mockCalendarStore{}, time.Minute)
+	providers := &mockProviders{statuses: []proxy.ProviderStatus{{Name: "openai"}}}
+	h := NewHandler(&mockKeyStore{}, WithProviders(providers), WithCapacityCalendar(calendar))
+
+	w := httptest.NewRecorder()
+	h.HandleAddCapacityWindow(w, httptest.NewRequest("POST", "/admin/provider-calendar",
+		strings.NewReader(`{"provider":"azure","capacity":"none","start":"17:00","end":"09:00"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown provider, got %d: %s", w.Code, w.Body.String())
+	}
+
+	start, end := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	body, _ := json.Marshal(policy.CapacityWindow{Provider: "openai", Capacity: policy.CapacityNone, StartsAt: &start, EndsAt: &end})
+	w = httptest.NewRecorder()
+	h.HandleAddCapacityWindow(w, httptest.NewRequest("POST", "/admin/provider-calendar", strings.NewReader(string(body))))
+	var view struct {
+		ID     int64 `json:"id"`
+		Active bool  `json:"active"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &view)
+	if w.Code != http.StatusCreated || view.ID != 1 || !view.Active {
+		t.Fatalf("Expected the active window to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	if cw := calendar.Capacity("openai", "gpt-4o", time.Now()); cw == nil {
+		t.Error("Expected the calendar to apply the new window without waiting for a reload")
+	}
+}
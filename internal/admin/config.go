@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/vnmchuo/llm-gateway/config"
+)
+
+// Config is the gateway's live configuration.
+type Config interface {
+	Status() config.Status
+	Reload(ctx context.Context) error
+}
+
+// WithConfig enables the configuration inspection and reload endpoints.
+func WithConfig(c Config) Option {
+	return func(h *Handler) {
+		h.config = c
+	}
+}
+
+// HandleGetConfig serves GET /admin/config: the effective configuration with
+// secrets redacted, when it was loaded and the changes waiting for a restart.
+func (h *Handler) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(h.config.Status())
+}
+
+// HandleReloadConfig serves POST /admin/config/reload, the same reload as
+// SIGHUP. An invalid configuration is rejected and the current one kept.
+func (h *Handler) HandleReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := h.config.Reload(r.Context()); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	h.HandleGetConfig(w, r)
+}
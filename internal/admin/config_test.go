@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/config"
+)
+
+type stubConfig struct {
+	status    config.Status
+	reloadErr error
+}
+
+func (s *stubConfig) Status() config.Status { return s.status }
+
+func (s *stubConfig) Reload(ctx context.Context) error { return s.reloadErr }
+
+func TestHandleReloadConfig(t *testing.T) {
+	c := &stubConfig{status: config.Status{Config: map[string]any{"Port": "8080"}, RestartPending: []string{}}}
+	h := NewHandler(nil, WithConfig(c))
+
+	w := httptest.NewRecorder()
+	h.HandleReloadConfig(w, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	var got config.Status
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil || w.Code != http.StatusOK || got.Config["Port"] != "8080" {
+		t.Fatalf("Expected the reloaded config, got %d: %+v (%v)", w.Code, got, err)
+	}
+
+	c.reloadErr = errors.New("invalid DEFAULT_RATE_LIMIT_TPM")
+	w = httptest.NewRecorder()
+	h.HandleReloadConfig(w, httptest.NewRequest("POST", "/admin/config/reload", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an invalid config, got %d", w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+)
+
+const (
+	defaultDryRunHours = 24
+	maxDryRunHours     = 168
+)
+
+// WithDryRun lets policy changes be previewed with ?dry_run=true: replayed
+// against recorded traffic instead of applied.
+func WithDryRun(r *dryrun.Replayer) Option {
+	return func(h *Handler) {
+		h.replayer = r
+	}
+}
+
+// dryRunResult is the response to a dry run: the change, which was not
+// applied, and what it would have done to the last hours of traffic.
+type dryRunResult struct {
+	DryRun bool           `json:"dry_run"`
+	Hours  int            `json:"hours"`
+	Change any            `json:"change"`
+	Report *dryrun.Report `json:"report"`
+}
+
+// previewed serves a mutation called with ?dry_run=true&hours=N (default
+// 24, at most 168): it replays change over the last N hours and writes the
+// report without applying it. It reports whether it wrote a response; when
+// it did not, the caller applies the change as usual.
+func (h *Handler) previewed(w http.ResponseWriter, r *http.Request, change any, replay func(ctx context.Context, window time.Duration) (*dryrun.Report, error)) bool {
+	q := r.URL.Query()
+	if dry, _ := strconv.ParseBool(q.Get("dry_run")); !dry {
+		return false
+	}
+	if h.replayer == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "dry runs are not enabled"})
+		return true
+	}
+	hours := defaultDryRunHours
+	if v := q.Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDryRunHours {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "hours must be between 1 and 168"})
+			return true
+		}
+		hours = n
+	}
+
+	report, err := replay(r.Context(), time.Duration(hours)*time.Hour)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(dryRunResult{DryRun: true, Hours: hours, Change: change, Report: report})
+	return true
+}
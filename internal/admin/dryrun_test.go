@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+type memTraffic []*billing.UsageLog
+
+func (m memTraffic) GetRequests(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]*billing.UsageLog, error) {
+	return m, nil
+}
+
+func (m memTraffic) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error) {
+	return 0, nil
+}
+
+type servingRouter struct{}
+
+func (servingRouter) Route(ctx context.Context, req *provider.Request) (provider.Provider, error) {
+	return nil, errors.New("no provider")
+}
+
+func (servingRouter) Serves(model string) bool { return true }
+
+func TestRoutingPolicyDryRun(t *testing.T) {
+	store := &memRoutingStore{policies: map[string]*policy.RoutingPolicy{}}
+	tenants := &memTenantStore{settings: map[string]*tenant.Settings{"tenant-1": {Plan: "pro"}}}
+	resolver := policy.NewRoutingResolver(store, "cost")
+	traffic := memTraffic{
+		{RequestID: "r1", TenantID: "tenant-1", Model: "gpt-4o", CostUSD: 0.2},
+		{RequestID: "r2", TenantID: "tenant-1", Model: "gpt-4o-mini", CostUSD: 0.01},
+	}
+	h := NewHandler(&mockKeyStore{},
+		WithRoutingPolicies(store, resolver, tenants),
+		WithDryRun(dryrun.NewReplayer(traffic, tenants, servingRouter{}, resolver, memModelPolicies{})),
+	)
+	r := chi.NewRouter()
+	r.Put("/admin/routing-policies/{level}/{id}", h.HandlePutRoutingPolicy)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(`{"max_cost":{"usd":0.1}}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/admin/routing-policies/plan/pro?dry_run=true&hours=6")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got struct {
+		DryRun bool          `json:"dry_run"`
+		Hours  int           `json:"hours"`
+		Report dryrun.Report `json:"report"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if !got.DryRun || got.Hours != 6 || got.Report.Requests != 2 || got.Report.Blocked != 1 {
+		t.Errorf("Unexpected dry run: %s", w.Body.String())
+	}
+	if len(store.policies) != 0 {
+		t.Errorf("Expected a dry run to store nothing, got %v", store.policies)
+	}
+
+	if w := do("/admin/routing-policies/plan/pro?dry_run=true&hours=500"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a window over a week, got %d", w.Code)
+	}
+	if w := do("/admin/routing-policies/plan/pro"); w.Code != http.StatusOK || len(store.policies) != 1 {
+		t.Errorf("Expected the policy stored without dry_run, got %d", w.Code)
+	}
+}
+
+type memModelPolicies map[string]*policy.ModelPolicy
+
+func (m memModelPolicies) Get(ctx context.Context, tenantID string) (*policy.ModelPolicy, error) {
+	return &policy.ModelPolicy{TenantID: tenantID}, nil
+}
+
+func (m memModelPolicies) Put(ctx context.Context, p *policy.ModelPolicy) error { return nil }
+
+func (m memModelPolicies) Delete(ctx context.Context, tenantID string) error { return nil }
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+)
+
+const (
+	defaultDeadLetterPage = 50
+	maxDeadLetterPage     = 500
+)
+
+// WithDeadLetters enables the dead-lettered job endpoints.
+func WithDeadLetters(dlq worker.DeadLetters) Option {
+	return func(h *Handler) {
+		h.deadLetters = dlq
+	}
+}
+
+// HandleListDeadLetters serves GET /admin/v1/jobs/dead?after=&limit=: the
+// dead-letter depth and a page of entries, oldest first. Pass the returned
+// next cursor as after for the next page.
+func (h *Handler) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterPage
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeadLetterPage {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "limit must be between 1 and 500"})
+			return
+		}
+		limit = n
+	}
+
+	letters, err := h.deadLetters.List(r.Context(), r.URL.Query().Get("after"), int64(limit))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	depth, err := h.deadLetters.Depth(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if letters == nil {
+		letters = []worker.DeadLetter{}
+	}
+
+	var next string
+	if len(letters) == limit {
+		next = letters[len(letters)-1].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"depth":        depth,
+		"dead_letters": letters,
+		"next":         next,
+	})
+}
+
+// HandleRetryJob serves POST /admin/v1/jobs/{id}/retry, putting a failed
+// job back on the queue as pending once its cause is fixed.
+func (h *Handler) HandleRetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "id")
+	if err := h.deadLetters.Retry(r.Context(), jobID); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, worker.ErrJobNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, worker.ErrJobNotRetryable):
+			status = http.StatusConflict
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id": jobID,
+		"status": worker.JobStatusPending,
+	})
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+)
+
+type mockDeadLetters struct {
+	letters []worker.DeadLetter
+	failed  map[string]bool
+	retried []string
+}
+
+func (m *mockDeadLetters) List(ctx context.Context, after string, count int64) ([]worker.DeadLetter, error) {
+	var out []worker.DeadLetter
+	for _, l := range m.letters {
+		if after != "" && l.ID <= after {
+			continue
+		}
+		if int64(len(out)) == count {
+			break
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func (m *mockDeadLetters) Depth(ctx context.Context) (int64, error) {
+	return int64(len(m.letters)), nil
+}
+
+func (m *mockDeadLetters) Retry(ctx context.Context, jobID string) error {
+	failed, ok := m.failed[jobID]
+	if !ok {
+		return worker.ErrJobNotFound
+	}
+	if !failed {
+		return worker.ErrJobNotRetryable
+	}
+	m.failed[jobID] = false
+	m.retried = append(m.retried, jobID)
+	return nil
+}
+
+func TestListDeadLetters_Paginates(t *testing.T) {
+	dlq := &mockDeadLetters{letters: []worker.DeadLetter{
+		{ID: "1-0", JobID: "job-1", Reason: "upstream error"},
+		{ID: "2-0", JobID: "job-2", Reason: "job was delivered 3 times without completing"},
+	}}
+	h := NewHandler(&mockKeyStore{}, WithDeadLetters(dlq))
+
+	w := httptest.NewRecorder()
+	h.HandleListDeadLetters(w, httptest.NewRequest("GET", "/admin/v1/jobs/dead?limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var page struct {
+		Depth       int64               `json:"depth"`
+		DeadLetters []worker.DeadLetter `json:"dead_letters"`
+		Next        string              `json:"next"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if page.Depth != 2 || len(page.DeadLetters) != 1 || page.DeadLetters[0].JobID != "job-1" || page.Next != "1-0" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleListDeadLetters(w, httptest.NewRequest("GET", "/admin/v1/jobs/dead?limit=1&after="+page.Next, nil))
+	_ = json.NewDecoder(w.Body).Decode(&page)
+	if len(page.DeadLetters) != 1 || page.DeadLetters[0].Reason != "job was delivered 3 times without completing" {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	dlq := &mockDeadLetters{failed: map[string]bool{"job-1": true}}
+	h := NewHandler(&mockKeyStore{}, WithDeadLetters(dlq))
+	r := chi.NewRouter()
+	r.Post("/admin/v1/jobs/{id}/retry", h.HandleRetryJob)
+
+	tests := []struct {
+		jobID string
+		want  int
+	}{
+		{"job-1", http.StatusAccepted},
+		{"job-1", http.StatusConflict}, // already pending again
+		{"job-404", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/admin/v1/jobs/"+tt.jobID+"/retry", nil))
+		if w.Code != tt.want {
+			t.Errorf("retry %s: expected %d, got %d: %s", tt.jobID, tt.want, w.Code, w.Body.String())
+		}
+	}
+	if len(dlq.retried) != 1 || dlq.retried[0] != "job-1" {
+		t.Errorf("expected job-1 to be retried once, got %v", dlq.retried)
+	}
+}
@@ -0,0 +1,339 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/tiering"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+)
+
+// exportVersion is bumped when the export document changes incompatibly.
+const exportVersion = 1
+
+// defaultStaleDays is the idle period HandleStaleKeys reports by default.
+const defaultStaleDays = 90
+
+// KeyExport is the document moved between deployments. Only key hashes leave
+// the gateway, so customers keep their existing keys after a migration.
+type KeyExport struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Keys       []*auth.APIKey `json:"keys"`
+}
+
+type Handler struct {
+	keys        auth.Store
+	policies    policy.Store
+	routing     policy.RoutingStore
+	resolver    *policy.RoutingResolver
+	tenants     tenant.Store
+	deadLetters worker.DeadLetters
+	providers   Providers
+	billing     billing.Store
+	prompts     prompts.Store
+	config      Config
+	calendar    *policy.Calendar
+	tiering     *tiering.Engine
+	replayer    *dryrun.Replayer
+	keyCache    auth.KeyCache
+}
+
+// Option configures optional admin features.
+type Option func(*Handler)
+
+// WithModelPolicies enables the per-tenant model policy endpoints.
+func WithModelPolicies(store policy.Store) Option {
+	return func(h *Handler) {
+		h.policies = store
+	}
+}
+
+// WithKeyCache makes key scope changes apply at once by dropping the auth
+// middleware's cached lookup of the key.
+func WithKeyCache(cache auth.KeyCache) Option {
+	return func(h *Handler) {
+		h.keyCache = cache
+	}
+}
+
+func NewHandler(keys auth.Store, opts ...Option) *Handler {
+	h := &Handler{keys: keys}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) HandleExportKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.Export(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if keys == nil {
+		keys = []*auth.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(KeyExport{
+		Version:    exportVersion,
+		ExportedAt: time.Now().UTC(),
+		Keys:       keys,
+	})
+}
+
+func (h *Handler) HandleImportKeys(w http.ResponseWriter, r *http.Request) {
+	var doc KeyExport
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	if doc.Version != exportVersion {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("unsupported export version %d", doc.Version)})
+		return
+	}
+	for i, k := range doc.Keys {
+		if k == nil || k.ID == "" || k.TenantID == "" || len(k.KeyHash) != 64 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("key %d: id, tenant_id and a sha256 key_hash are required", i)})
+			return
+		}
+		if k.CreatedAt.IsZero() {
+			k.CreatedAt = time.Now().UTC()
+		}
+	}
+
+	imported, err := h.keys.Import(r.Context(), doc.Keys)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "imported": imported})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]int{
+		"imported": imported,
+		"skipped":  len(doc.Keys) - imported,
+	})
+}
+
+// staleKey is a key in the stale report; the hash is left out.
+type staleKey struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Key        string     `json:"key"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	IdleDays   int        `json:"idle_days"`
+}
+
+// HandleStaleKeys serves GET /admin/keys/stale?days=N: active keys unused
+// for at least N days (default 90), least recently used first. Recent use may
+// not be reflected until the next last-used flush.
+func (h *Handler) HandleStaleKeys(w http.ResponseWriter, r *http.Request) {
+	days := defaultStaleDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+
+	now := time.Now().UTC()
+	before := now.AddDate(0, 0, -days)
+	keys, err := h.keys.ListUnusedSince(r.Context(), before)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	stale := make([]staleKey, 0, len(keys))
+	for _, k := range keys {
+		last := k.CreatedAt
+		if k.LastUsedAt != nil {
+			last = *k.LastUsedAt
+		}
+		stale = append(stale, staleKey{
+			ID:         k.ID,
+			TenantID:   k.TenantID,
+			Key:        k.Masked(),
+			CreatedAt:  k.CreatedAt,
+			LastUsedAt: k.LastUsedAt,
+			IdleDays:   int(now.Sub(last).Hours() / 24),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"days":         days,
+		"unused_since": before,
+		"keys":         stale,
+	})
+}
+
+// createKeyRequest is the body of POST /admin/keys.
+type createKeyRequest struct {
+	TenantID     string   `json:"tenant_id"`
+	RateLimit    int64    `json:"rate_limit"`
+	RateLimitRPM int64    `json:"rate_limit_rpm"`
+	Scopes       []string `json:"scopes"`
+}
+
+// defaultKeyRateLimit matches the api_keys.rate_limit column default.
+const defaultKeyRateLimit = 100000
+
+// HandleCreateKey issues a key for a tenant. The key itself is only ever
+// returned here; the gateway keeps its hash.
+func (h *Handler) HandleCreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.TenantID == "" || req.RateLimit < 0 || req.RateLimitRPM < 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "tenant_id is required and limits must not be negative"})
+		return
+	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if req.RateLimit == 0 {
+		req.RateLimit = defaultKeyRateLimit
+	}
+
+	key, err := auth.GenerateKey()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	apiKey := &auth.APIKey{
+		TenantID:     req.TenantID,
+		KeyHash:      auth.HashKey(key),
+		KeyHint:      auth.Hint(key),
+		RateLimit:    req.RateLimit,
+		RateLimitRPM: req.RateLimitRPM,
+		Scopes:       req.Scopes,
+		Active:       true,
+	}
+	if err := h.keys.Create(r.Context(), apiKey); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             apiKey.ID,
+		"tenant_id":      apiKey.TenantID,
+		"key":            key,
+		"rate_limit":     apiKey.RateLimit,
+		"rate_limit_rpm": apiKey.RateLimitRPM,
+		"scopes":         scopesOrEmpty(apiKey.Scopes),
+		"created_at":     apiKey.CreatedAt,
+	})
+}
+
+// HandleSetKeyScopes serves PUT /admin/keys/{id}/scopes. The scopes must not
+// be empty: a key without scopes has full access, which "*" grants
+// explicitly. With WithKeyCache the change applies on the key's next
+// request; otherwise the auth middleware's cache can serve the old scopes
+// for up to five minutes.
+func (h *Handler) HandleSetKeyScopes(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": `scopes must not be empty; use ["*"] for full access`})
+		return
+	}
+	if err := auth.ValidateScopes(req.Scopes); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	keyHash, err := h.keys.SetScopes(r.Context(), id, req.Scopes)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if h.keyCache != nil {
+		if err := h.keyCache.Forget(r.Context(), keyHash); err != nil {
+			// The new scopes are stored, but requests may be served with the
+			// old ones until the cached lookup expires; retrying clears it.
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "scopes saved, but the cached key could not be cleared: " + err.Error()})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "scopes": req.Scopes})
+}
+
+// scopesOrEmpty renders a key without scopes as [] rather than null.
+func scopesOrEmpty(scopes []string) []string {
+	if scopes == nil {
+		return []string{}
+	}
+	return scopes
+}
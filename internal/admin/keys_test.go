@@ -0,0 +1,251 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+type mockKeyStore struct {
+	keys []*auth.APIKey
+}
+
+func (m *mockKeyStore) GetByKey(ctx context.Context, key string) (*auth.APIKey, error) {
+	return nil, auth.ErrKeyNotFound
+}
+func (m *mockKeyStore) GetByID(ctx context.Context, keyID string) (*auth.APIKey, error) {
+	for _, k := range m.keys {
+		if k.ID == keyID {
+			return k, nil
+		}
+	}
+	return nil, auth.ErrKeyNotFound
+}
+func (m *mockKeyStore) Create(ctx context.Context, apiKey *auth.APIKey) error {
+	apiKey.ID = fmt.Sprintf("key-%d", len(m.keys)+1)
+	m.keys = append(m.keys, apiKey)
+	return nil
+}
+func (m *mockKeyStore) Revoke(ctx context.Context, keyID string) error { return nil }
+func (m *mockKeyStore) SetScopes(ctx context.Context, keyID string, scopes []string) (string, error) {
+	for _, k := range m.keys {
+		if k.ID == keyID {
+			k.Scopes = scopes
+			return k.KeyHash, nil
+		}
+	}
+	return "", auth.ErrKeyNotFound
+}
+
+// forgetfulCache records the key hashes it is asked to forget.
+type forgetfulCache struct {
+	forgot []string
+}
+
+func (c *forgetfulCache) Forget(ctx context.Context, keyHash string) error {
+	c.forgot = append(c.forgot, keyHash)
+	return nil
+}
+func (m *mockKeyStore) Export(ctx context.Context) ([]*auth.APIKey, error) { return m.keys, nil }
+func (m *mockKeyStore) ListByTenant(ctx context.Context, tenantID string) ([]*auth.APIKey, error) {
+	return nil, nil
+}
+func (m *mockKeyStore) TouchLastUsed(ctx context.Context, used map[string]time.Time) error {
+	return nil
+}
+
+func (m *mockKeyStore) ListUnusedSince(ctx context.Context, before time.Time) ([]*auth.APIKey, error) {
+	var stale []*auth.APIKey
+	for _, k := range m.keys {
+		last := k.CreatedAt
+		if k.LastUsedAt != nil {
+			last = *k.LastUsedAt
+		}
+		if k.Active && last.Before(before) {
+			stale = append(stale, k)
+		}
+	}
+	return stale, nil
+}
+
+func (m *mockKeyStore) Import(ctx context.Context, keys []*auth.APIKey) (int, error) {
+	imported := 0
+	for _, k := range keys {
+		exists := false
+		for _, e := range m.keys {
+			if e.ID == k.ID || e.KeyHash == k.KeyHash {
+				exists = true
+			}
+		}
+		if !exists {
+			m.keys = append(m.keys, k)
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	src := &mockKeyStore{keys: []*auth.APIKey{
+		{ID: "key-1", TenantID: "tenant-1", KeyHash: hash, RateLimit: 1000, Active: true, CreatedAt: time.Now()},
+	}}
+
+	w := httptest.NewRecorder()
+	NewHandler(src).HandleExportKeys(w, httptest.NewRequest("GET", "/admin/keys/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	exported := w.Body.Bytes()
+
+	dst := &mockKeyStore{}
+	h := NewHandler(dst)
+	for i, want := range []int{1, 0} {
+		w = httptest.NewRecorder()
+		h.HandleImportKeys(w, httptest.NewRequest("POST", "/admin/keys/import", bytes.NewReader(exported)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp map[string]int
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp["imported"] != want {
+			t.Errorf("Import %d: expected %d imported, got %v", i, want, resp)
+		}
+	}
+
+	if len(dst.keys) != 1 || dst.keys[0].TenantID != "tenant-1" || dst.keys[0].KeyHash != hash {
+		t.Errorf("Expected key to keep its tenant mapping and hash, got %+v", dst.keys)
+	}
+}
+
+func TestImport_RejectsInvalidKeys(t *testing.T) {
+	body := `{"version":1,"keys":[{"id":"key-1","tenant_id":"tenant-1","key_hash":"short"}]}`
+	w := httptest.NewRecorder()
+	NewHandler(&mockKeyStore{}).HandleImportKeys(w, httptest.NewRequest("POST", "/admin/keys/import", strings.NewReader(body)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestStaleKeys_ReportsIdleActiveKeys(t *testing.T) {
+	now := time.Now().UTC()
+	recent := now.Add(-24 * time.Hour)
+	old := now.AddDate(0, 0, -40)
+	store := &mockKeyStore{keys: []*auth.APIKey{
+		{ID: "used", TenantID: "tenant-1", KeyHint: "aaaa", Active: true, CreatedAt: old, LastUsedAt: &recent},
+		{ID: "idle", TenantID: "tenant-1", KeyHint: "bbbb", Active: true, CreatedAt: old.AddDate(0, 0, -10), LastUsedAt: &old},
+		{ID: "never", TenantID: "tenant-2", KeyHint: "cccc", Active: true, CreatedAt: old},
+		{ID: "new", TenantID: "tenant-2", Active: true, CreatedAt: recent},
+		{ID: "revoked", TenantID: "tenant-2", Active: false, CreatedAt: old},
+	}}
+
+	w := httptest.NewRecorder()
+	NewHandler(store).HandleStaleKeys(w, httptest.NewRequest("GET", "/admin/keys/stale?days=30", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Keys []staleKey `json:"keys"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Keys) != 2 || resp.Keys[0].ID != "idle" || resp.Keys[1].ID != "never" {
+		t.Fatalf("Expected idle and never-used keys, got %+v", resp.Keys)
+	}
+	if resp.Keys[0].Key != "****bbbb" || resp.Keys[0].IdleDays != 40 {
+		t.Errorf("Unexpected report entry: %+v", resp.Keys[0])
+	}
+	if strings.Contains(w.Body.String(), "key_hash") {
+		t.Errorf("Key hashes must not be reported: %s", w.Body.String())
+	}
+}
+
+func TestStaleKeys_InvalidDays(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(&mockKeyStore{}).HandleStaleKeys(w, httptest.NewRequest("GET", "/admin/keys/stale?days=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestCreateKey_IssuesScopedKey(t *testing.T) {
+	store := &mockKeyStore{}
+	body := `{"tenant_id":"tenant-1","scopes":["usage:read","keys:read"]}`
+	w := httptest.NewRecorder()
+	NewHandler(store).HandleCreateKey(w, httptest.NewRequest("POST", "/admin/keys", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ID     string   `json:"id"`
+		Key    string   `json:"key"`
+		Scopes []string `json:"scopes"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(store.keys) != 1 || resp.ID != store.keys[0].ID {
+		t.Fatalf("Expected the key stored, got %+v", store.keys)
+	}
+	k := store.keys[0]
+	if k.KeyHash != auth.HashKey(resp.Key) || k.KeyHint != auth.Hint(resp.Key) {
+		t.Error("Expected only the returned key's hash and hint stored")
+	}
+	if len(k.Scopes) != 2 || k.RateLimit != defaultKeyRateLimit {
+		t.Errorf("Expected scopes and the default rate limit, got %+v", k)
+	}
+}
+
+func TestCreateKey_RejectsUnknownScopes(t *testing.T) {
+	body := `{"tenant_id":"tenant-1","scopes":["usage:write"]}`
+	w := httptest.NewRecorder()
+	NewHandler(&mockKeyStore{}).HandleCreateKey(w, httptest.NewRequest("POST", "/admin/keys", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestSetKeyScopes(t *testing.T) {
+	store := &mockKeyStore{keys: []*auth.APIKey{{ID: "key-1", TenantID: "tenant-1", KeyHash: "hash-1", Active: true}}}
+	cache := &forgetfulCache{}
+	h := NewHandler(store, WithKeyCache(cache))
+	router := chi.NewRouter()
+	router.Put("/admin/keys/{id}/scopes", h.HandleSetKeyScopes)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/keys/key-1/scopes", strings.NewReader(`{"scopes":["chat:*"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := store.keys[0].Scopes; len(got) != 1 || got[0] != "chat:*" {
+		t.Errorf("Expected scopes replaced, got %v", got)
+	}
+	if len(cache.forgot) != 1 || cache.forgot[0] != "hash-1" {
+		t.Errorf("Expected the key's cached lookup dropped, got %v", cache.forgot)
+	}
+
+	// Clearing scopes would grant full access, so it is refused.
+	for _, body := range []string{`{"scopes":[]}`, `{}`} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/keys/key-1/scopes", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: expected 400, got %d", body, w.Code)
+		}
+	}
+	if got := store.keys[0].Scopes; len(got) != 1 {
+		t.Errorf("Expected scopes kept, got %v", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("PUT", "/admin/keys/key-9/scopes", strings.NewReader(`{"scopes":["*"]}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown key, got %d", w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+)
+
+func (h *Handler) HandleGetModelPolicy(w http.ResponseWriter, r *http.Request) {
+	p, err := h.policies.Get(r.Context(), chi.URLParam(r, "tenantID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func (h *Handler) HandlePutModelPolicy(w http.ResponseWriter, r *http.Request) {
+	var p policy.ModelPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	p.TenantID = chi.URLParam(r, "tenantID")
+	if err := p.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if h.previewed(w, r, p, func(ctx context.Context, window time.Duration) (*dryrun.Report, error) {
+		return h.replayer.ModelPolicy(ctx, &p, window)
+	}) {
+		return
+	}
+
+	if err := h.policies.Put(r.Context(), &p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func (h *Handler) HandleDeleteModelPolicy(w http.ResponseWriter, r *http.Request) {
+	deleted := &policy.ModelPolicy{TenantID: chi.URLParam(r, "tenantID")}
+	if h.previewed(w, r, deleted, func(ctx context.Context, window time.Duration) (*dryrun.Report, error) {
+		return h.replayer.ModelPolicy(ctx, deleted, window)
+	}) {
+		return
+	}
+	if err := h.policies.Delete(r.Context(), deleted.TenantID); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
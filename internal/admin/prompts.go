@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+)
+
+// WithSystemPrompts enables the per-tenant system prompt library endpoints.
+func WithSystemPrompts(store prompts.Store) Option {
+	return func(h *Handler) {
+		h.prompts = store
+	}
+}
+
+// promptView is a prompt with the variables its content uses.
+type promptView struct {
+	*prompts.Prompt
+	Variables []string `json:"variables"`
+}
+
+func viewPrompt(p *prompts.Prompt) promptView {
+	vars := p.Variables()
+	if vars == nil {
+		vars = []string{}
+	}
+	return promptView{Prompt: p, Variables: vars}
+}
+
+// HandleListPrompts serves GET /admin/tenants/{tenantID}/prompts: the latest
+// version of each of the tenant's prompts.
+func (h *Handler) HandleListPrompts(w http.ResponseWriter, r *http.Request) {
+	list, err := h.prompts.List(r.Context(), chi.URLParam(r, "tenantID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	views := make([]promptView, 0, len(list))
+	for _, p := range list {
+		views = append(views, viewPrompt(p))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"prompts": views})
+}
+
+// HandleGetPrompt serves GET /admin/tenants/{tenantID}/prompts/{promptID}
+// ?version=: the latest version, or the one asked for.
+func (h *Handler) HandleGetPrompt(w http.ResponseWriter, r *http.Request) {
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "version must be a positive number"})
+			return
+		}
+		version = n
+	}
+
+	p, err := h.prompts.Get(r.Context(), chi.URLParam(r, "tenantID"), chi.URLParam(r, "promptID"), version)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, prompts.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(viewPrompt(p))
+}
+
+// HandleSavePrompt serves POST /admin/tenants/{tenantID}/prompts/{promptID},
+// saving {"content", "defaults"} as the prompt's next version. Earlier
+// versions are kept for requests that pin them.
+func (h *Handler) HandleSavePrompt(w http.ResponseWriter, r *http.Request) {
+	var p prompts.Prompt
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	p.TenantID = chi.URLParam(r, "tenantID")
+	p.ID = chi.URLParam(r, "promptID")
+	if err := p.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := h.prompts.Put(r.Context(), &p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(viewPrompt(&p))
+}
+
+// HandleDeletePrompt serves DELETE /admin/tenants/{tenantID}/prompts/{promptID},
+// removing every version.
+func (h *Handler) HandleDeletePrompt(w http.ResponseWriter, r *http.Request) {
+	if err := h.prompts.Delete(r.Context(), chi.URLParam(r, "tenantID"), chi.URLParam(r, "promptID")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, prompts.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
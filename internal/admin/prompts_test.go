@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+)
+
+// memPromptStore keeps every version of each prompt, keyed by tenant/id.
+type memPromptStore struct {
+	versions map[string][]*prompts.Prompt
+}
+
+func (m *memPromptStore) Get(ctx context.Context, tenantID, id string, version int) (*prompts.Prompt, error) {
+	vs := m.versions[tenantID+"/"+id]
+	if len(vs) == 0 || version > len(vs) {
+		return nil, prompts.ErrNotFound
+	}
+	if version == 0 {
+		version = len(vs)
+	}
+	return vs[version-1], nil
+}
+
+func (m *memPromptStore) Put(ctx context.Context, p *prompts.Prompt) error {
+	key := p.TenantID + "/" + p.ID
+	p.Version = len(m.versions[key]) + 1
+	m.versions[key] = append(m.versions[key], p)
+	return nil
+}
+
+func (m *memPromptStore) List(ctx context.Context, tenantID string) ([]*prompts.Prompt, error) {
+	var out []*prompts.Prompt
+	for key, vs := range m.versions {
+		if strings.HasPrefix(key, tenantID+"/") {
+			out = append(out, vs[len(vs)-1])
+		}
+	}
+	return out, nil
+}
+
+func (m *memPromptStore) Delete(ctx context.Context, tenantID, id string) error {
+	if _, ok := m.versions[tenantID+"/"+id]; !ok {
+		return prompts.ErrNotFound
+	}
+	delete(m.versions, tenantID+"/"+id)
+	return nil
+}
+
+func TestPromptEndpoints(t *testing.T) {
+	h := NewHandler(&mockKeyStore{}, WithSystemPrompts(&memPromptStore{versions: map[string][]*prompts.Prompt{}}))
+	r := chi.NewRouter()
+	r.Get("/admin/tenants/{tenantID}/prompts", h.HandleListPrompts)
+	r.Get("/admin/tenants/{tenantID}/prompts/{promptID}", h.HandleGetPrompt)
+	r.Post("/admin/tenants/{tenantID}/prompts/{promptID}", h.HandleSavePrompt)
+	r.Delete("/admin/tenants/{tenantID}/prompts/{promptID}", h.HandleDeletePrompt)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	const path = "/admin/tenants/tenant-1/prompts/support"
+
+	if w := do("POST", path, `{"content":"  "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected empty content rejected, got %d", w.Code)
+	}
+	do("POST", path, `{"content":"You help with {{product}}.","defaults":{"product":"Acme"}}`)
+	w := do("POST", path, `{"content":"You help {{user}} with {{product}}."}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var saved struct {
+		Version   int      `json:"version"`
+		Variables []string `json:"variables"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &saved)
+	if saved.Version != 2 || len(saved.Variables) != 2 {
+		t.Errorf("Expected version 2 using two variables, got %+v", saved)
+	}
+
+	w = do("GET", path+"?version=1", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version":1`) {
+		t.Errorf("Expected the pinned version, got %d: %s", w.Code, w.Body.String())
+	}
+	w = do("GET", "/admin/tenants/tenant-1/prompts", "")
+	if !strings.Contains(w.Body.String(), `"version":2`) {
+		t.Errorf("Expected the list to show the latest version, got %s", w.Body.String())
+	}
+
+	if w := do("DELETE", path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w := do("GET", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/proxy"
+)
+
+// Providers is the router's view of provider health and its manual controls.
+type Providers interface {
+	ProviderStatuses() []proxy.ProviderStatus
+	DisableProvider(name string) error
+	EnableProvider(name string) error
+}
+
+// WithProviders enables the provider health and drain endpoints.
+func WithProviders(p Providers) Option {
+	return func(h *Handler) {
+		h.providers = p
+	}
+}
+
+// HandleListProviders serves GET /admin/providers: each provider's circuit
+// breaker state, recent failures, latency and whether it is disabled.
+func (h *Handler) HandleListProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": h.providers.ProviderStatuses(),
+	})
+}
+
+// HandleDisableProvider serves POST /admin/providers/{name}/disable, draining
+// the provider during an incident.
+func (h *Handler) HandleDisableProvider(w http.ResponseWriter, r *http.Request) {
+	h.changeProvider(w, r, "disabled", h.providers.DisableProvider)
+}
+
+// HandleEnableProvider serves POST /admin/providers/{name}/enable, restoring
+// the provider with a reset circuit breaker.
+func (h *Handler) HandleEnableProvider(w http.ResponseWriter, r *http.Request) {
+	h.changeProvider(w, r, "enabled", h.providers.EnableProvider)
+}
+
+// changeProvider applies change to the named provider and returns its new
+// status.
+func (h *Handler) changeProvider(w http.ResponseWriter, r *http.Request, action string, change func(name string) error) {
+	name := chi.URLParam(r, "name")
+	if err := change(name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, proxy.ErrUnknownProvider) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("admin: provider %s %s", name, action)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	for _, s := range h.providers.ProviderStatuses() {
+		if s.Name == name {
+			_ = json.NewEncoder(w).Encode(s)
+			return
+		}
+	}
+}
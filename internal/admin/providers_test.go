@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/proxy"
+)
+
+type mockProviders struct {
+	statuses []proxy.ProviderStatus
+}
+
+func (m *mockProviders) ProviderStatuses() []proxy.ProviderStatus { return m.statuses }
+
+func (m *mockProviders) DisableProvider(name string) error { return m.set(name, true) }
+
+func (m *mockProviders) EnableProvider(name string) error { return m.set(name, false) }
+
+func (m *mockProviders) set(name string, disabled bool) error {
+	for i := range m.statuses {
+		if m.statuses[i].Name == name {
+			m.statuses[i].Disabled = disabled
+			return nil
+		}
+	}
+	return proxy.ErrUnknownProvider
+}
+
+func postProvider(h http.HandlerFunc, name, action string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/admin/providers/"+name+"/"+action, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("name", name)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+func TestProviders_DisableAndEnable(t *testing.T) {
+	providers := &mockProviders{statuses: []proxy.ProviderStatus{{Name: "openai", State: "closed"}}}
+	h := NewHandler(&mockKeyStore{}, WithProviders(providers))
+
+	w := postProvider(h.HandleDisableProvider, "openai", "disable")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status proxy.ProviderStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || !status.Disabled {
+		t.Errorf("Expected the disabled status, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleListProviders(w, httptest.NewRequest("GET", "/admin/providers", nil))
+	var list struct {
+		Providers []proxy.ProviderStatus `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Providers) != 1 || !list.Providers[0].Disabled {
+		t.Errorf("Expected the provider listed as disabled, got %s", w.Body.String())
+	}
+
+	if w := postProvider(h.HandleEnableProvider, "openai", "enable"); w.Code != http.StatusOK || providers.statuses[0].Disabled {
+		t.Errorf("Expected the provider enabled, got %d", w.Code)
+	}
+	if w := postProvider(h.HandleDisableProvider, "mistral", "disable"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown provider, got %d", w.Code)
+	}
+}
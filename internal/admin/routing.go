@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// WithRoutingPolicies enables the global, plan and key routing policy
+// endpoints and the effective policy of a key, which also takes in its
+// tenant's settings.
+func WithRoutingPolicies(store policy.RoutingStore, resolver *policy.RoutingResolver, tenants tenant.Store) Option {
+	return func(h *Handler) {
+		h.routing = store
+		h.resolver = resolver
+		h.tenants = tenants
+	}
+}
+
+// HandleListRoutingPolicies serves GET /admin/routing-policies: every
+// stored global, plan and key policy.
+func (h *Handler) HandleListRoutingPolicies(w http.ResponseWriter, r *http.Request) {
+	list, err := h.routing.List(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if list == nil {
+		list = []*policy.RoutingPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"policies": list})
+}
+
+// routingLevel is the level and ID a routing policy route names;
+// /admin/routing-policies/global has neither parameter.
+func routingLevel(r *http.Request) (level, id string) {
+	level = chi.URLParam(r, "level")
+	if level == "" {
+		return policy.LevelGlobal, ""
+	}
+	return level, chi.URLParam(r, "id")
+}
+
+// HandlePutRoutingPolicy serves PUT /admin/routing-policies/global and
+// /admin/routing-policies/{plan|key}/{id}. With ?dry_run=true the policy is
+// replayed against recent traffic instead of stored.
+func (h *Handler) HandlePutRoutingPolicy(w http.ResponseWriter, r *http.Request) {
+	var p policy.RoutingPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	p.Level, p.ID = routingLevel(r)
+	if err := p.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if h.previewed(w, r, p, func(ctx context.Context, window time.Duration) (*dryrun.Report, error) {
+		return h.replayer.RoutingPolicy(ctx, p, window)
+	}) {
+		return
+	}
+
+	if err := h.routing.Put(r.Context(), &p); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// HandleDeleteRoutingPolicy serves DELETE /admin/routing-policies/global
+// and /admin/routing-policies/{plan|key}/{id}; the level then inherits
+// everything. ?dry_run=true previews the deletion.
+func (h *Handler) HandleDeleteRoutingPolicy(w http.ResponseWriter, r *http.Request) {
+	level, id := routingLevel(r)
+	deleted := policy.RoutingPolicy{Level: level, ID: id}
+	if h.previewed(w, r, deleted, func(ctx context.Context, window time.Duration) (*dryrun.Report, error) {
+		return h.replayer.RoutingPolicy(ctx, deleted, window)
+	}) {
+		return
+	}
+	if err := h.routing.Delete(r.Context(), level, id); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// effectiveRouting is the routing policy requests made with a key get.
+type effectiveRouting struct {
+	KeyID    string `json:"key_id"`
+	TenantID string `json:"tenant_id"`
+	Plan     string `json:"plan,omitempty"`
+	*policy.EffectiveRouting
+}
+
+// HandleEffectiveRoutingPolicy serves GET /admin/keys/{id}/routing-policy:
+// the key's policy merged with its tenant's, plan's and the global one,
+// with the level each field comes from.
+func (h *Handler) HandleEffectiveRoutingPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key, err := h.keys.GetByID(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	settings, err := h.tenants.Get(ctx, key.TenantID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	effective, err := h.resolver.Resolve(ctx, settings.Plan, settings.Routing(), key.ID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(effectiveRouting{KeyID: key.ID, TenantID: key.TenantID, Plan: settings.Plan, EffectiveRouting: effective})
+}
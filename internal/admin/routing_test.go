@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+type memRoutingStore struct {
+	policies map[string]*policy.RoutingPolicy
+}
+
+func (m *memRoutingStore) Get(ctx context.Context, level, id string) (*policy.RoutingPolicy, error) {
+	if p, ok := m.policies[level+"/"+id]; ok {
+		return p, nil
+	}
+	return &policy.RoutingPolicy{Level: level, ID: id}, nil
+}
+
+func (m *memRoutingStore) Put(ctx context.Context, p *policy.RoutingPolicy) error {
+	m.policies[p.Level+"/"+p.ID] = p
+	return nil
+}
+
+func (m *memRoutingStore) Delete(ctx context.Context, level, id string) error {
+	delete(m.policies, level+"/"+id)
+	return nil
+}
+
+func (m *memRoutingStore) List(ctx context.Context) ([]*policy.RoutingPolicy, error) {
+	var out []*policy.RoutingPolicy
+	for _, p := range m.policies {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+type memTenantStore struct {
+	settings map[string]*tenant.Settings
+}
+
+func (m *memTenantStore) Get(ctx context.Context, tenantID string) (*tenant.Settings, error) {
+	if s, ok := m.settings[tenantID]; ok {
+		return s, nil
+	}
+	return &tenant.Settings{}, nil
+}
+
+func (m *memTenantStore) Put(ctx context.Context, tenantID string, s *tenant.Settings) error {
+	m.settings[tenantID] = s
+	return nil
+}
+
+func TestRoutingPolicyEndpoints(t *testing.T) {
+	store := &memRoutingStore{policies: map[string]*policy.RoutingPolicy{}}
+	tenants := &memTenantStore{settings: map[string]*tenant.Settings{
+		"tenant-1": {Plan: "pro", RoutingMode: policy.ModeStrict},
+	}}
+	keys := &mockKeyStore{keys: []*auth.APIKey{{ID: "key-1", TenantID: "tenant-1"}}}
+	h := NewHandler(keys, WithRoutingPolicies(store, policy.NewRoutingResolver(store, "cost"), tenants))
+	r := chi.NewRouter()
+	r.Get("/admin/routing-policies", h.HandleListRoutingPolicies)
+	r.Put("/admin/routing-policies/global", h.HandlePutRoutingPolicy)
+	r.Put("/admin/routing-policies/{level}/{id}", h.HandlePutRoutingPolicy)
+	r.Delete("/admin/routing-policies/{level}/{id}", h.HandleDeleteRoutingPolicy)
+	r.Get("/admin/keys/{id}/routing-policy", h.HandleEffectiveRoutingPolicy)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for path, body := range map[string]string{
+		"/admin/routing-policies/global":    `{"max_cost":{"usd":0.5}}`,
+		"/admin/routing-policies/plan/pro":  `{"strategy":"latency","max_cost":{"usd":0.05}}`,
+		"/admin/routing-policies/key/key-1": `{"strategy":"priority","max_cost":{"usd":1,"substitute":true}}`,
+	} {
+		if w := do("PUT", path, body); w.Code != http.StatusOK {
+			t.Fatalf("PUT %s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	if w := do("PUT", "/admin/routing-policies/tenant/tenant-1", `{"strategy":"cost"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for the tenant level, which lives in tenant settings, got %d", w.Code)
+	}
+	if w := do("PUT", "/admin/routing-policies/plan/pro", `{"strategy":"random"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown strategy, got %d", w.Code)
+	}
+
+	w := do("GET", "/admin/keys/key-1/routing-policy", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got struct {
+		Plan     string            `json:"plan"`
+		Strategy string            `json:"strategy"`
+		Mode     string            `json:"mode"`
+		MaxCost  map[string]any    `json:"max_cost"`
+		Sources  map[string]string `json:"sources"`
+		Levels   []json.RawMessage `json:"levels"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Plan != "pro" || got.Strategy != "priority" || got.Mode != policy.ModeStrict {
+		t.Errorf("Unexpected effective policy: %s", w.Body.String())
+	}
+	if got.MaxCost["usd"] != 0.05 || got.MaxCost["substitute"] != true || got.Sources["max_cost"] != policy.LevelPlan {
+		t.Errorf("Expected the plan's lower cap, substituting as the key allows, got %s", w.Body.String())
+	}
+	if got.Sources["strategy"] != policy.LevelKey || got.Sources["mode"] != policy.LevelTenant || len(got.Levels) != 5 {
+		t.Errorf("Unexpected sources: %s", w.Body.String())
+	}
+
+	if w := do("DELETE", "/admin/routing-policies/key/key-1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	w = do("GET", "/admin/keys/key-1/routing-policy", "")
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Strategy != "latency" {
+		t.Errorf("Expected the plan's strategy once the key's policy is gone, got %q", got.Strategy)
+	}
+	if w := do("GET", "/admin/keys/missing/routing-policy", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown key, got %d", w.Code)
+	}
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// WithTenantSettings enables the tenant settings endpoints.
+func WithTenantSettings(store tenant.Store) Option {
+	return func(h *Handler) {
+		h.tenants = store
+	}
+}
+
+// HandleGetTenantSettings serves GET /admin/tenants/{tenantID}/settings.
+func (h *Handler) HandleGetTenantSettings(w http.ResponseWriter, r *http.Request) {
+	s, err := h.tenants.Get(r.Context(), chi.URLParam(r, "tenantID"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// HandlePutTenantSettings serves PUT /admin/tenants/{tenantID}/settings,
+// replacing the tenant's settings document once it validates. With
+// ?dry_run=true it replays the change instead; see previewed.
+func (h *Handler) HandlePutTenantSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID := chi.URLParam(r, "tenantID")
+	var s tenant.Settings
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := s.Validate(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if h.previewed(w, r, s, func(ctx context.Context, window time.Duration) (*dryrun.Report, error) {
+		return h.replayer.TenantSettings(ctx, tenantID, &s, window)
+	}) {
+		return
+	}
+
+	if err := h.tenants.Put(r.Context(), tenantID, &s); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+func TestTenantSettingsEndpoints(t *testing.T) {
+	tenants := &memTenantStore{settings: map[string]*tenant.Settings{}}
+	h := NewHandler(&mockKeyStore{}, WithTenantSettings(tenants))
+	r := chi.NewRouter()
+	r.Get("/admin/tenants/{tenantID}/settings", h.HandleGetTenantSettings)
+	r.Put("/admin/tenants/{tenantID}/settings", h.HandlePutTenantSettings)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/admin/tenants/tenant-1/settings", `{"context_overflow":{"strategy":"truncate_history","retries":3}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if s := tenants.settings["tenant-1"]; s == nil || s.ContextOverflow == nil || s.ContextOverflow.Retries != 3 {
+		t.Errorf("Expected the settings to be stored, got %+v", s)
+	}
+
+	for _, body := range []string{
+		`{"context_overflow":{"strategy":"shrink"}}`,
+		`{"context_overflow":{"strategy":"reduce_max_tokens","retries":9}}`,
+	} {
+		w := do("PUT", "/admin/tenants/tenant-2/settings", body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "context overflow") {
+			t.Errorf("PUT %s: expected 400 naming the context overflow setting, got %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+	if _, ok := tenants.settings["tenant-2"]; ok {
+		t.Error("Expected invalid settings not to be stored")
+	}
+
+	if w := do("GET", "/admin/tenants/tenant-1/settings", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "truncate_history") {
+		t.Errorf("Expected the stored settings, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantSettingsDryRun(t *testing.T) {
+	tenants := &memTenantStore{settings: map[string]*tenant.Settings{"tenant-1": {}}}
+	resolver := policy.NewRoutingResolver(&memRoutingStore{policies: map[string]*policy.RoutingPolicy{}}, "cost")
+	traffic := memTraffic{{RequestID: "r1", TenantID: "tenant-1", Model: "gpt-4o", CostUSD: 0.2, CreatedAt: time.Now()}}
+	h := NewHandler(&mockKeyStore{}, WithTenantSettings(tenants),
+		WithDryRun(dryrun.NewReplayer(traffic, tenants, servingRouter{}, resolver, memModelPolicies{})))
+	r := chi.NewRouter()
+	r.Put("/admin/tenants/{tenantID}/settings", h.HandlePutTenantSettings)
+
+	req := httptest.NewRequest("PUT", "/admin/tenants/tenant-1/settings?dry_run=true", strings.NewReader(`{"max_cost":{"usd":0.1}}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var res struct {
+		DryRun bool          `json:"dry_run"`
+		Report dryrun.Report `json:"report"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !res.DryRun || res.Report.Blocked != 1 {
+		t.Errorf("Expected r1 reported blocked by the cap, got %+v", res)
+	}
+	if s := tenants.settings["tenant-1"]; s.MaxCost != nil {
+		t.Errorf("Expected a dry run not to store the settings, got %+v", s)
+	}
+}
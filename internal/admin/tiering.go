@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/tiering"
+)
+
+const (
+	defaultTierChangePage = 100
+	maxTierChangePage     = 1000
+)
+
+// WithTiering enables the tier change endpoints.
+func WithTiering(e *tiering.Engine) Option {
+	return func(h *Handler) {
+		h.tiering = e
+	}
+}
+
+// HandleListTierChanges serves GET /admin/tier-changes?status=&limit=: plan
+// changes the tiering engine made or proposed, newest first. Pass
+// status=pending for the proposals waiting on an operator.
+func (h *Handler) HandleListTierChanges(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTierChangePage
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxTierChangePage {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", tiering.StatusApplied, tiering.StatusPending, tiering.StatusApproved, tiering.StatusRejected:
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "status must be applied, pending, approved or rejected"})
+		return
+	}
+
+	changes, err := h.tiering.List(r.Context(), status, limit)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if changes == nil {
+		changes = []*tiering.Change{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"changes": changes})
+}
+
+// HandleApproveTierChange serves POST /admin/tier-changes/{id}/approve,
+// moving the tenant to the proposed plan.
+func (h *Handler) HandleApproveTierChange(w http.ResponseWriter, r *http.Request) {
+	h.decideTierChange(w, r, h.tiering.Approve)
+}
+
+// HandleRejectTierChange serves POST /admin/tier-changes/{id}/reject,
+// leaving the tenant on its plan. The engine may propose the change again
+// once the tenant's usage still matches the rule.
+func (h *Handler) HandleRejectTierChange(w http.ResponseWriter, r *http.Request) {
+	h.decideTierChange(w, r, h.tiering.Reject)
+}
+
+func (h *Handler) decideTierChange(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, id int64) (*tiering.Change, error)) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid tier change id"})
+		return
+	}
+	change, err := decide(r.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, tiering.ErrChangeNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, tiering.ErrChangeDecided), errors.Is(err, tiering.ErrPlanChanged):
+			status = http.StatusConflict
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("admin: tier change %d for tenant %s %s (%s -> %s)", change.ID, change.TenantID, change.Status, change.FromPlan, change.ToPlan)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(change)
+}
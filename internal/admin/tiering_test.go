@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/tiering"
+)
+
+type mockTierChanges struct {
+	tiering.Store
+	change *tiering.Change
+}
+
+func (m *mockTierChanges) Get(ctx context.Context, id int64) (*tiering.Change, error) {
+	if m.change == nil || m.change.ID != id {
+		return nil, tiering.ErrChangeNotFound
+	}
+	return m.change, nil
+}
+
+func (m *mockTierChanges) Decide(ctx context.Context, id int64, status string) (*tiering.Change, error) {
+	m.change.Status = status
+	return m.change, nil
+}
+
+func TestTierChanges_ApproveChecksTenantPlan(t *testing.T) {
+	tenants := &memTenantStore{settings: map[string]*tenant.Settings{"tenant-1": {Plan: "team"}}}
+	changes := &mockTierChanges{change: &tiering.Change{ID: 7, TenantID: "tenant-1", FromPlan: "free", ToPlan: "pro", Status: tiering.StatusPending}}
+	h := NewHandler(&mockKeyStore{}, WithTiering(tiering.NewEngine(nil, nil, nil, tenants, changes, time.Hour)))
+	r := chi.NewRouter()
+	r.Get("/admin/tier-changes", h.HandleListTierChanges)
+	r.Post("/admin/tier-changes/{id}/approve", h.HandleApproveTierChange)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := do("GET", "/admin/tier-changes?status=done"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown status, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/tier-changes/8/approve"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown change, got %d", w.Code)
+	}
+	if w := do("POST", "/admin/tier-changes/7/approve"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once the tenant left the plan, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tenants.settings["tenant-1"].Plan = "free"
+	if w := do("POST", "/admin/tier-changes/7/approve"); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if tenants.settings["tenant-1"].Plan != "pro" || changes.change.Status != tiering.StatusApproved {
+		t.Errorf("Expected tenant-1 moved to pro and the change approved, got plan %q, status %q",
+			tenants.settings["tenant-1"].Plan, changes.change.Status)
+	}
+}
@@ -0,0 +1,105 @@
+// Package apierror writes API errors in the OpenAI format, so OpenAI SDKs
+// raise their typed exceptions for gateway errors as they do for OpenAI's:
+//
+//	{"error": {"message": "...", "type": "invalid_request_error", "param": null, "code": "model_not_found"}}
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Error types, as OpenAI names them.
+const (
+	TypeInvalidRequest    = "invalid_request_error"
+	TypeAuthentication    = "authentication_error"
+	TypePermission        = "permission_error"
+	TypeNotFound          = "not_found_error"
+	TypeInsufficientQuota = "insufficient_quota"
+	TypeRateLimit         = "rate_limit_error"
+	TypeServer            = "server_error"
+)
+
+// Codes shared across packages. Others are local to where they're raised.
+const (
+	CodeInvalidAPIKey     = "invalid_api_key"
+	CodeInsufficientScope = "insufficient_scope"
+	CodeRateLimitExceeded = "rate_limit_exceeded"
+)
+
+// Error is one API error. Empty Type is derived from the status; empty Code
+// and Param are sent as null.
+type Error struct {
+	Message string
+	Type    string
+	Code    string
+	Param   string
+	// Details are sent next to "error" for clients that want more than the
+	// message, e.g. the limit a request went over.
+	Details map[string]any
+}
+
+type body struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// TypeFor is the error type for an HTTP status.
+func TypeFor(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return TypeAuthentication
+	case status == http.StatusForbidden:
+		return TypePermission
+	case status == http.StatusNotFound:
+		return TypeNotFound
+	case status == http.StatusPaymentRequired:
+		return TypeInsufficientQuota
+	case status == http.StatusTooManyRequests:
+		return TypeRateLimit
+	case status >= 500:
+		return TypeServer
+	default:
+		return TypeInvalidRequest
+	}
+}
+
+// Write sends an error with code (empty: null) and message.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	WriteError(w, status, Error{Message: message, Code: code})
+}
+
+// WriteError sends e with status.
+func WriteError(w http.ResponseWriter, status int, e Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(e.envelope(status))
+}
+
+// Marshal encodes e as it would be sent with status, for errors delivered
+// other than as a response body, such as stream events.
+func Marshal(status int, e Error) []byte {
+	data, _ := json.Marshal(e.envelope(status))
+	return data
+}
+
+func (e Error) envelope(status int) map[string]any {
+	b := body{Message: e.Message, Type: e.Type}
+	if b.Type == "" {
+		b.Type = TypeFor(status)
+	}
+	if e.Code != "" {
+		b.Code = &e.Code
+	}
+	if e.Param != "" {
+		b.Param = &e.Param
+	}
+	out := make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		out[k] = v
+	}
+	out["error"] = b
+	return out
+}
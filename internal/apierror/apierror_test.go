@@ -0,0 +1,53 @@
+package apierror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError_OpenAIEnvelope(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, http.StatusBadRequest, Error{
+		Message: "max_tokens is too large",
+		Code:    "max_tokens_too_large",
+		Param:   "max_tokens",
+		Details: map[string]any{"limit": 4096},
+	})
+
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected response: %d %v", w.Code, w.Header())
+	}
+	want := `{"error":{"message":"max_tokens is too large","type":"invalid_request_error","param":"max_tokens","code":"max_tokens_too_large"},"limit":4096}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestWrite_NullCodeAndParam(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, http.StatusUnauthorized, "", "unauthorized")
+
+	want := `{"error":{"message":"unauthorized","type":"authentication_error","param":null,"code":null}}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestTypeFor(t *testing.T) {
+	cases := map[int]string{
+		http.StatusBadRequest:            TypeInvalidRequest,
+		http.StatusRequestEntityTooLarge: TypeInvalidRequest,
+		http.StatusUnauthorized:          TypeAuthentication,
+		http.StatusForbidden:             TypePermission,
+		http.StatusNotFound:              TypeNotFound,
+		http.StatusPaymentRequired:       TypeInsufficientQuota,
+		http.StatusTooManyRequests:       TypeRateLimit,
+		http.StatusBadGateway:            TypeServer,
+	}
+	for status, want := range cases {
+		if got := TypeFor(status); got != want {
+			t.Errorf("TypeFor(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package archive delivers the final responses of tenants under archiving
+// obligations to compliance sinks such as S3 and Kafka. Transcripts are
+// queued on the request path and delivered by worker processes.
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Transcript is one completion's final, assembled response.
+type Transcript struct {
+	RequestID      string `json:"request_id"`
+	TenantID       string `json:"tenant_id"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Provider       string `json:"provider"`
+	Model          string `json:"model"`
+	Stream         bool   `json:"stream"`
+	// PromptHash ties the response to the prompt it answered without
+	// archiving the prompt itself; see PromptHash.
+	PromptHash   string              `json:"prompt_hash"`
+	Content      string              `json:"content"`
+	ToolCalls    []provider.ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+	InputTokens  int                 `json:"input_tokens"`
+	OutputTokens int                 `json:"output_tokens"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// Sink stores transcripts. Delivery is at least once: a transcript may reach
+// a sink again when another sink failed, so sinks should key it by request.
+type Sink interface {
+	Name() string
+	Archive(ctx context.Context, t *Transcript) error
+}
+
+// PromptHash is the hex SHA-256 of messages as JSON, so an archived response
+// can be matched against a prompt kept elsewhere.
+func PromptHash(messages []provider.Message) string {
+	h := sha256.New()
+	_ = json.NewEncoder(h).Encode(messages)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// deliver hands t to every sink, returning their failures joined.
+func deliver(ctx context.Context, sinks []Sink, t *Transcript) error {
+	var errs []error
+	for _, s := range sinks {
+		if err := s.Archive(ctx, t); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type fakeSink struct {
+	name string
+	err  error
+	got  []*Transcript
+}
+
+func (f *fakeSink) Name() string { return f.name }
+
+func (f *fakeSink) Archive(ctx context.Context, t *Transcript) error {
+	f.got = append(f.got, t)
+	return f.err
+}
+
+func TestDeliver_ReachesEverySinkAndReportsFailures(t *testing.T) {
+	s3 := &fakeSink{name: "s3"}
+	kafka := &fakeSink{name: "kafka", err: errors.New("broker unavailable")}
+	err := deliver(context.Background(), []Sink{kafka, s3}, &Transcript{RequestID: "req-1"})
+	if err == nil || !strings.Contains(err.Error(), "kafka: broker unavailable") {
+		t.Errorf("Expected the kafka failure, got %v", err)
+	}
+	if len(s3.got) != 1 {
+		t.Error("Expected the s3 sink to get the transcript despite the kafka failure")
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	a := PromptHash([]provider.Message{{Role: "user", Content: "hi"}})
+	if a != PromptHash([]provider.Message{{Role: "user", Content: "hi"}}) {
+		t.Error("Expected the same prompt to hash the same")
+	}
+	if a == PromptHash([]provider.Message{{Role: "user", Content: "hi!"}}) || len(a) != 64 {
+		t.Errorf("Expected a distinct hex SHA-256, got %s", a)
+	}
+}
@@ -0,0 +1,218 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	streamKey     = "archive:stream"
+	deadLetterKey = "archive:dead"
+	consumerGroup = "archivers"
+
+	// writeTimeout bounds queueing a transcript and acking it.
+	writeTimeout = 5 * time.Second
+)
+
+// Queue carries transcripts from the request path to the sinks through a
+// Redis Stream consumer group, like the async job queue. A transcript is
+// acked once every sink has it; until then it is delivered again after the
+// visibility timeout, and after maxDeliveries attempts it is moved to the
+// archive:dead stream for an operator to replay.
+type Queue struct {
+	rdb           *redis.Client
+	sinks         []Sink
+	consumer      string
+	visibility    time.Duration
+	maxDeliveries int64
+	transcripts   metric.Int64Counter
+}
+
+func NewQueue(rdb *redis.Client, sinks []Sink, visibility time.Duration, maxDeliveries int) *Queue {
+	host, _ := os.Hostname()
+	q := &Queue{
+		rdb:           rdb,
+		sinks:         sinks,
+		consumer:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		visibility:    visibility,
+		maxDeliveries: int64(maxDeliveries),
+	}
+	var err error
+	q.transcripts, err = otel.Meter("github.com/vnmchuo/llm-gateway/internal/archive").Int64Counter("gateway.archive.transcripts",
+		metric.WithDescription("Transcripts delivered to archive sinks or dead-lettered"))
+	if err != nil {
+		log.Printf("archive: failed to create transcript counter: %v", err)
+	}
+	return q
+}
+
+// Record queues t without blocking the caller. A transcript that can't be
+// queued is logged, not retried.
+func (q *Queue) Record(t *Transcript) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		defer cancel()
+		if err := q.Enqueue(ctx, t); err != nil {
+			log.Printf("archive: failed to queue transcript %s for tenant %s: %v", t.RequestID, t.TenantID, err)
+		}
+	}()
+}
+
+// Enqueue adds t to the stream.
+func (q *Queue) Enqueue(ctx context.Context, t *Transcript) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode transcript: %w", err)
+	}
+	return q.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		Values: map[string]interface{}{"transcript": data},
+	}).Err()
+}
+
+// Process delivers queued transcripts until ctx is cancelled.
+func (q *Queue) Process(ctx context.Context) {
+	err := q.rdb.XGroupCreateMkStream(ctx, streamKey, consumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		log.Printf("archive: failed to create consumer group: %v", err)
+		return
+	}
+	reclaim := time.NewTicker(q.visibility / 2)
+	defer reclaim.Stop()
+	for ctx.Err() == nil {
+		select {
+		case <-reclaim.C:
+			q.reclaim(ctx)
+		default:
+		}
+		streams, err := q.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    consumerGroup,
+			Consumer: q.consumer,
+			Streams:  []string{streamKey, ">"},
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("archive: read error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				q.handle(ctx, msg, 1)
+			}
+		}
+	}
+}
+
+// reclaim takes over transcripts left unacked past the visibility timeout.
+func (q *Queue) reclaim(ctx context.Context) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		msgs, next, err := q.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   streamKey,
+			Group:    consumerGroup,
+			Consumer: q.consumer,
+			MinIdle:  q.visibility,
+			Start:    start,
+			Count:    10,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("archive: reclaim error: %v", err)
+			}
+			return
+		}
+		for _, msg := range msgs {
+			q.handle(ctx, msg, q.deliveries(ctx, msg.ID))
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// handle delivers one message's transcript, on its deliveries-th attempt,
+// and acks it once every sink has it or it is dead-lettered.
+func (q *Queue) handle(ctx context.Context, msg redis.XMessage, deliveries int64) {
+	raw, _ := msg.Values["transcript"].(string)
+	var t Transcript
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		q.deadLetter(ctx, msg, fmt.Sprintf("undecodable transcript: %v", err))
+		return
+	}
+	if deliveries > q.maxDeliveries {
+		q.deadLetter(ctx, msg, fmt.Sprintf("not archived after %d attempts", deliveries-1))
+		return
+	}
+	if err := deliver(ctx, q.sinks, &t); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("archive: failed to archive transcript %s for tenant %s (attempt %d): %v", t.RequestID, t.TenantID, deliveries, err)
+		}
+		return
+	}
+	q.count(ctx, "archived")
+	q.ack(ctx, msg.ID)
+}
+
+// deliveries reports how many times the message has been handed out,
+// including this delivery.
+func (q *Queue) deliveries(ctx context.Context, msgID string) int64 {
+	pending, err := q.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: streamKey,
+		Group:  consumerGroup,
+		Start:  msgID,
+		End:    msgID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return pending[0].RetryCount
+}
+
+// deadLetter moves msg to archive:dead with reason, keeping the transcript.
+func (q *Queue) deadLetter(ctx context.Context, msg redis.XMessage, reason string) {
+	dlCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	err := q.rdb.XAdd(dlCtx, &redis.XAddArgs{
+		Stream: deadLetterKey,
+		Values: map[string]interface{}{"transcript": msg.Values["transcript"], "reason": reason},
+	}).Err()
+	if err != nil {
+		log.Printf("archive: failed to dead-letter message %s: %v", msg.ID, err)
+		return
+	}
+	log.Printf("archive: dead-lettered message %s: %s", msg.ID, reason)
+	q.count(ctx, "dead_lettered")
+	q.ack(ctx, msg.ID)
+}
+
+func (q *Queue) ack(ctx context.Context, msgID string) {
+	ackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if err := q.rdb.XAck(ackCtx, streamKey, consumerGroup, msgID).Err(); err != nil {
+		log.Printf("archive: failed to ack message %s: %v", msgID, err)
+	}
+}
+
+func (q *Queue) count(ctx context.Context, outcome string) {
+	if q.transcripts != nil {
+		q.transcripts.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	}
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/segmentio/kafka-go"
+)
+
+// S3Sink writes each transcript as a JSON object in an S3-compatible bucket,
+// under transcripts/{tenant_id}/{yyyy}/{mm}/{dd}/{request_id}.json.
+type S3Sink struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Sink(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*S3Sink, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage client: %w", err)
+	}
+	return &S3Sink{client: client, bucket: bucket}, nil
+}
+
+func (s *S3Sink) Name() string { return "s3" }
+
+func (s *S3Sink) Archive(ctx context.Context, t *Transcript) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode transcript: %w", err)
+	}
+	key := "transcripts/" + t.TenantID + "/" + t.CreatedAt.UTC().Format("2006/01/02") + "/" + t.RequestID + ".json"
+	_, err = s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to upload transcript: %w", err)
+	}
+	return nil
+}
+
+// KafkaSink publishes each transcript as a JSON message keyed by tenant ID,
+// so one tenant's transcripts stay in order on a partition. Messages carry
+// the request ID in a request_id header for deduplication.
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}}
+}
+
+func (s *KafkaSink) Name() string { return "kafka" }
+
+func (s *KafkaSink) Archive(ctx context.Context, t *Transcript) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to encode transcript: %w", err)
+	}
+	err = s.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(t.TenantID),
+		Value:   data,
+		Headers: []kafka.Header{{Key: "request_id", Value: []byte(t.RequestID)}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish transcript: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the producer.
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
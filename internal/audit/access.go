@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// PurposeHeader lets callers annotate why they are reading data, e.g.
+// "invoice_dispute" or "support_ticket:1234".
+const PurposeHeader = "X-Access-Purpose"
+
+const defaultPurpose = "self_service"
+
+// AccessEvent records one read of tenant data.
+type AccessEvent struct {
+	ID             string
+	ActorKeyID     string // API key that performed the read
+	ActorTenantID  string // tenant owning that key
+	TargetTenantID string // tenant whose data was read
+	Resource       string // e.g. "usage"
+	Action         string // e.g. "read"
+	Purpose        string
+	RequestID      string
+	Method         string
+	Path           string
+	Query          string
+	RemoteIP       string
+	StatusCode     int
+	CreatedAt      time.Time
+}
+
+type AccessStore interface {
+	RecordAccess(ctx context.Context, event *AccessEvent) error
+}
+
+// AccessLogger writes access events off the request path with a bounded timeout.
+type AccessLogger struct {
+	store   AccessStore
+	timeout time.Duration
+}
+
+func NewAccessLogger(store AccessStore) *AccessLogger {
+	return &AccessLogger{store: store, timeout: 5 * time.Second}
+}
+
+// TargetFunc resolves whose data a request reads. The default is the caller's own tenant.
+type TargetFunc func(r *http.Request) string
+
+// Middleware records every request to the wrapped routes as an access to
+// resource: a read for GET and HEAD, a write otherwise. Pass a TargetFunc
+// for admin routes that reach other tenants' data.
+func (l *AccessLogger) Middleware(resource string, target TargetFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			ctx := r.Context()
+			event := &AccessEvent{
+				ActorKeyID:     auth.GetAPIKeyID(ctx),
+				ActorTenantID:  auth.GetTenantID(ctx),
+				TargetTenantID: auth.GetTenantID(ctx),
+				Resource:       resource,
+				Action:         actionFor(r.Method),
+				Purpose:        r.Header.Get(PurposeHeader),
+				RequestID:      auth.GetRequestID(ctx),
+				Method:         r.Method,
+				Path:           r.URL.Path,
+				Query:          r.URL.RawQuery,
+				RemoteIP:       remoteIP(r),
+				StatusCode:     rec.status,
+			}
+			if target != nil {
+				event.TargetTenantID = target(r)
+			}
+			if event.Purpose == "" {
+				event.Purpose = defaultPurpose
+			}
+			l.Record(event)
+		})
+	}
+}
+
+// Record persists event asynchronously; failures are logged, never surfaced to the caller.
+func (l *AccessLogger) Record(event *AccessEvent) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+		defer cancel()
+		if err := l.store.RecordAccess(ctx, event); err != nil {
+			log.Printf("audit: failed to record access to %s by key %s: %v", event.Resource, event.ActorKeyID, err)
+		}
+	}()
+}
+
+func actionFor(method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return "read"
+	}
+	return "write"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type mockAccessStore struct {
+	events chan *AccessEvent
+}
+
+func (m *mockAccessStore) RecordAccess(ctx context.Context, e *AccessEvent) error {
+	m.events <- e
+	return nil
+}
+
+func TestMiddleware_RecordsPurposeAndTarget(t *testing.T) {
+	store := &mockAccessStore{events: make(chan *AccessEvent, 1)}
+	logger := NewAccessLogger(store)
+
+	h := logger.Middleware("usage", func(r *http.Request) string { return "target-tenant" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+
+	req := httptest.NewRequest("GET", "/admin/usage?from=2024-01-01", nil)
+	req.Header.Set(PurposeHeader, "invoice_dispute")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case e := <-store.events:
+		if e.Purpose != "invoice_dispute" || e.TargetTenantID != "target-tenant" || e.Resource != "usage" || e.Action != "read" {
+			t.Errorf("Unexpected event: %+v", e)
+		}
+		if e.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", e.StatusCode)
+		}
+		if e.Query != "from=2024-01-01" {
+			t.Errorf("Expected query to be recorded, got %q", e.Query)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected access event to be recorded")
+	}
+}
+
+func TestMiddleware_DefaultPurpose(t *testing.T) {
+	store := &mockAccessStore{events: make(chan *AccessEvent, 1)}
+	h := NewAccessLogger(store).Middleware("usage", nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/usage", nil))
+
+	select {
+	case e := <-store.events:
+		if e.Purpose != defaultPurpose {
+			t.Errorf("Expected default purpose, got %q", e.Purpose)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected access event to be recorded")
+	}
+}
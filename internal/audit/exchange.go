@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrExchangeNotFound means no exchange is stored for the request, or it expired.
+var ErrExchangeNotFound = errors.New("exchange not found")
+
+const redacted = "[REDACTED]"
+
+// Exchange is one completion's full request and response, kept for
+// debugging and compliance when the tenant opts in.
+type Exchange struct {
+	RequestID string `json:"request_id"`
+	TenantID  string `json:"tenant_id"`
+	// ConversationID groups the turns of one conversation, as tagged by the
+	// client; empty for untagged requests.
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Provider       string          `json:"provider,omitempty"`
+	Model          string          `json:"model,omitempty"`
+	Request        json.RawMessage `json:"request"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+}
+
+type ExchangeStore interface {
+	SaveExchange(ctx context.Context, e *Exchange) error
+	GetExchange(ctx context.Context, tenantID, requestID string) (*Exchange, error)
+	// ListConversation returns the unexpired exchanges of a conversation,
+	// oldest first; none is not an error.
+	ListConversation(ctx context.Context, tenantID, conversationID string) ([]*Exchange, error)
+	// PurgeExpired deletes exchanges whose ExpiresAt is before now and
+	// returns how many it removed.
+	PurgeExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// RedactionRule masks stored payload data. Field replaces the value of every
+// JSON key with that name (case-insensitive); Pattern replaces regular
+// expression matches inside string values.
+type RedactionRule struct {
+	Field   string `json:"field,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Redact applies rules to a JSON payload.
+func Redact(payload json.RawMessage, rules []RedactionRule) (json.RawMessage, error) {
+	if len(rules) == 0 || len(payload) == 0 {
+		return payload, nil
+	}
+	var fields []string
+	var patterns []*regexp.Regexp
+	for _, rule := range rules {
+		if rule.Field != "" {
+			fields = append(fields, rule.Field)
+		}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redaction pattern %q: %w", rule.Pattern, err)
+			}
+			patterns = append(patterns, re)
+		}
+	}
+
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	return json.Marshal(redactValue(v, fields, patterns))
+}
+
+func redactValue(v any, fields []string, patterns []*regexp.Regexp) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if matchesField(k, fields) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactValue(child, fields, patterns)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child, fields, patterns)
+		}
+		return v
+	case string:
+		for _, re := range patterns {
+			v = re.ReplaceAllString(v, redacted)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func matchesField(key string, fields []string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// PayloadLogger stores exchanges off the request path with a bounded timeout.
+type PayloadLogger struct {
+	store   ExchangeStore
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+// NewPayloadLogger keeps exchanges for ttl.
+func NewPayloadLogger(store ExchangeStore, ttl time.Duration) *PayloadLogger {
+	return &PayloadLogger{store: store, ttl: ttl, timeout: 5 * time.Second}
+}
+
+// Store returns the store exchanges are read back from.
+func (l *PayloadLogger) Store() ExchangeStore {
+	return l.store
+}
+
+// Record redacts and persists e asynchronously. An exchange whose redaction
+// fails is dropped rather than stored unredacted.
+func (l *PayloadLogger) Record(e *Exchange, rules []RedactionRule) {
+	go func() {
+		var err error
+		if e.Request, err = Redact(e.Request, rules); err == nil {
+			e.Response, err = Redact(e.Response, rules)
+		}
+		if err != nil {
+			log.Printf("audit: dropped exchange %s: %v", e.RequestID, err)
+			return
+		}
+		e.CreatedAt = time.Now().UTC()
+		e.ExpiresAt = e.CreatedAt.Add(l.ttl)
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+		defer cancel()
+		if err := l.store.SaveExchange(ctx, e); err != nil {
+			log.Printf("audit: failed to store exchange %s for tenant %s: %v", e.RequestID, e.TenantID, err)
+		}
+	}()
+}
+
+// Purger deletes expired exchanges periodically.
+type Purger struct {
+	store    ExchangeStore
+	interval time.Duration
+}
+
+func NewPurger(store ExchangeStore, interval time.Duration) *Purger {
+	return &Purger{store: store, interval: interval}
+}
+
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.store.PurgeExpired(ctx, time.Now()); err != nil {
+				log.Printf("audit: failed to purge expired exchanges: %v", err)
+			}
+		}
+	}
+}
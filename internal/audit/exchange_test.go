@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRedact_FieldsAndPatterns(t *testing.T) {
+	payload := json.RawMessage(`{"Messages":[{"Role":"user","Content":"mail me at jane@example.com"}],"api_key":"sk-123","nested":{"API_KEY":"x"}}`)
+
+	out, err := Redact(payload, []RedactionRule{
+		{Field: "api_key"},
+		{Pattern: `[\w.]+@[\w.]+`},
+	})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+
+	var got struct {
+		Messages []struct{ Content string }
+		APIKey   string `json:"api_key"`
+		Nested   map[string]string
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid output %s: %v", out, err)
+	}
+	if got.Messages[0].Content != "mail me at [REDACTED]" {
+		t.Errorf("Expected the address to be masked, got %q", got.Messages[0].Content)
+	}
+	if got.APIKey != redacted || got.Nested["API_KEY"] != redacted {
+		t.Errorf("Expected api_key fields to be masked, got %s", out)
+	}
+}
+
+func TestRedact_InvalidPattern(t *testing.T) {
+	if _, err := Redact(json.RawMessage(`{}`), []RedactionRule{{Pattern: "("}}); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+}
+
+type mockExchangeStore struct {
+	saved chan *Exchange
+}
+
+func (m *mockExchangeStore) SaveExchange(ctx context.Context, e *Exchange) error {
+	m.saved <- e
+	return nil
+}
+
+func (m *mockExchangeStore) GetExchange(ctx context.Context, tenantID, requestID string) (*Exchange, error) {
+	return nil, ErrExchangeNotFound
+}
+
+func (m *mockExchangeStore) ListConversation(ctx context.Context, tenantID, conversationID string) ([]*Exchange, error) {
+	return nil, nil
+}
+
+func (m *mockExchangeStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func TestPayloadLogger_RedactsAndSetsExpiry(t *testing.T) {
+	store := &mockExchangeStore{saved: make(chan *Exchange, 1)}
+	logger := NewPayloadLogger(store, 24*time.Hour)
+
+	logger.Record(&Exchange{
+		RequestID: "req-1",
+		TenantID:  "tenant-1",
+		Request:   json.RawMessage(`{"secret":"s"}`),
+		Response:  json.RawMessage(`{"content":"ok"}`),
+	}, []RedactionRule{{Field: "secret"}})
+
+	select {
+	case e := <-store.saved:
+		if string(e.Request) != `{"secret":"[REDACTED]"}` {
+			t.Errorf("Expected a redacted request, got %s", e.Request)
+		}
+		if ttl := e.ExpiresAt.Sub(e.CreatedAt); ttl != 24*time.Hour {
+			t.Errorf("Expected a 24h retention, got %v", ttl)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the exchange to be stored")
+	}
+}
+
+func TestPayloadLogger_DropsUnredactable(t *testing.T) {
+	store := &mockExchangeStore{saved: make(chan *Exchange, 1)}
+	NewPayloadLogger(store, time.Hour).Record(&Exchange{Request: json.RawMessage(`{"a":"b"}`)},
+		[]RedactionRule{{Pattern: "("}})
+
+	select {
+	case e := <-store.saved:
+		t.Errorf("Expected the exchange to be dropped, got %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) RecordAccess(ctx context.Context, e *AccessEvent) error {
+	query := `
+		INSERT INTO access_audit_log (actor_key_id, actor_tenant_id, target_tenant_id, resource, action, purpose,
+			request_id, method, path, query, remote_ip, status_code)
+		VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRow(ctx, query,
+		e.ActorKeyID, e.ActorTenantID, e.TargetTenantID, e.Resource, e.Action, e.Purpose,
+		e.RequestID, e.Method, e.Path, e.Query, e.RemoteIP, e.StatusCode,
+	).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record access event: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) SaveExchange(ctx context.Context, e *Exchange) error {
+	query := `
+		INSERT INTO request_exchanges (request_id, tenant_id, provider, model, request, response, error, created_at, expires_at, conversation_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		ON CONFLICT (tenant_id, request_id) DO NOTHING
+	`
+	_, err := s.db.Exec(ctx, query,
+		e.RequestID, e.TenantID, e.Provider, e.Model, []byte(e.Request), []byte(e.Response), e.Error, e.CreatedAt, e.ExpiresAt, e.ConversationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store exchange: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetExchange(ctx context.Context, tenantID, requestID string) (*Exchange, error) {
+	query := `
+		SELECT request_id, tenant_id, COALESCE(conversation_id, ''), provider, model, request, response, error, created_at, expires_at
+		FROM request_exchanges
+		WHERE tenant_id = $1 AND request_id = $2 AND expires_at > NOW()
+	`
+	var e Exchange
+	var request, response []byte
+	err := s.db.QueryRow(ctx, query, tenantID, requestID).Scan(
+		&e.RequestID, &e.TenantID, &e.ConversationID, &e.Provider, &e.Model, &request, &response, &e.Error, &e.CreatedAt, &e.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrExchangeNotFound
+		}
+		return nil, fmt.Errorf("failed to get exchange: %w", err)
+	}
+	e.Request, e.Response = request, response
+	return &e, nil
+}
+
+func (s *PostgresStore) ListConversation(ctx context.Context, tenantID, conversationID string) ([]*Exchange, error) {
+	query := `
+		SELECT request_id, tenant_id, conversation_id, provider, model, request, response, error, created_at, expires_at
+		FROM request_exchanges
+		WHERE tenant_id = $1 AND conversation_id = $2 AND expires_at > NOW()
+		ORDER BY created_at, request_id
+	`
+	rows, err := s.db.Query(ctx, query, tenantID, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversation: %w", err)
+	}
+	defer rows.Close()
+
+	var exchanges []*Exchange
+	for rows.Next() {
+		var e Exchange
+		var request, response []byte
+		if err := rows.Scan(
+			&e.RequestID, &e.TenantID, &e.ConversationID, &e.Provider, &e.Model, &request, &response, &e.Error, &e.CreatedAt, &e.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange: %w", err)
+		}
+		e.Request, e.Response = request, response
+		exchanges = append(exchanges, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating conversation: %w", err)
+	}
+	return exchanges, nil
+}
+
+func (s *PostgresStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM request_exchanges WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge exchanges: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	exchangePrefix     = "exchanges/"
+	conversationPrefix = "conversations/"
+)
+
+// S3ExchangeStore keeps exchanges as JSON objects in an S3-compatible
+// bucket, one per request under exchanges/{tenant_id}/{request_id}.json.
+// Exchanges that belong to a conversation are also written under
+// conversations/{tenant_id}/{conversation_id}/, keyed so that listing
+// returns them oldest first.
+// Objects are written once, so purging goes by their modification time plus
+// the retention they were stored with.
+type S3ExchangeStore struct {
+	client    *minio.Client
+	bucket    string
+	retention time.Duration
+}
+
+func NewS3ExchangeStore(endpoint, accessKey, secretKey, bucket string, useSSL bool, retention time.Duration) (*S3ExchangeStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object storage client: %w", err)
+	}
+	return &S3ExchangeStore{client: client, bucket: bucket, retention: retention}, nil
+}
+
+func exchangeKey(tenantID, requestID string) string {
+	return exchangePrefix + tenantID + "/" + requestID + ".json"
+}
+
+func conversationKey(tenantID, conversationID string, createdAt time.Time, requestID string) string {
+	return conversationPrefix + tenantID + "/" + conversationID + "/" +
+		createdAt.UTC().Format("20060102T150405.000000000Z") + "-" + requestID + ".json"
+}
+
+func (s *S3ExchangeStore) SaveExchange(ctx context.Context, e *Exchange) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode exchange: %w", err)
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, exchangeKey(e.TenantID, e.RequestID), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to upload exchange: %w", err)
+	}
+	if e.ConversationID == "" {
+		return nil
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, conversationKey(e.TenantID, e.ConversationID, e.CreatedAt, e.RequestID), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to upload conversation exchange: %w", err)
+	}
+	return nil
+}
+
+func (s *S3ExchangeStore) GetExchange(ctx context.Context, tenantID, requestID string) (*Exchange, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, exchangeKey(tenantID, requestID), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange: %w", err)
+	}
+	defer obj.Close()
+
+	var e Exchange
+	if err := json.NewDecoder(obj).Decode(&e); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrExchangeNotFound
+		}
+		return nil, fmt.Errorf("failed to decode exchange: %w", err)
+	}
+	if !e.ExpiresAt.After(time.Now()) {
+		return nil, ErrExchangeNotFound
+	}
+	return &e, nil
+}
+
+func (s *S3ExchangeStore) ListConversation(ctx context.Context, tenantID, conversationID string) ([]*Exchange, error) {
+	now := time.Now()
+	var exchanges []*Exchange
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    conversationPrefix + tenantID + "/" + conversationID + "/",
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list conversation: %w", info.Err)
+		}
+		obj, err := s.client.GetObject(ctx, s.bucket, info.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get exchange: %w", err)
+		}
+		var e Exchange
+		err = json.NewDecoder(obj).Decode(&e)
+		obj.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode exchange: %w", err)
+		}
+		if e.ExpiresAt.After(now) {
+			exchanges = append(exchanges, &e)
+		}
+	}
+	return exchanges, nil
+}
+
+// PurgeExpired deletes expired exchanges along with their conversation
+// copies; each copy counts as one deletion.
+func (s *S3ExchangeStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	deleted := 0
+	for _, prefix := range []string{exchangePrefix, conversationPrefix} {
+		for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+			Prefix:    prefix,
+			Recursive: true,
+		}) {
+			if obj.Err != nil {
+				return deleted, fmt.Errorf("failed to list exchanges: %w", obj.Err)
+			}
+			if obj.LastModified.Add(s.retention).After(now) {
+				continue
+			}
+			if err := s.client.RemoveObject(ctx, s.bucket, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+				return deleted, fmt.Errorf("failed to delete %s: %w", obj.Key, err)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
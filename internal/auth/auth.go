@@ -2,29 +2,61 @@ package auth
 
 import (
 	"context"
-	"crypto/sha256"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
 )
 
 var ErrKeyNotFound = errors.New("api key not found")
 
 type APIKey struct {
-	ID        string    `json:"id"`
-	TenantID  string    `json:"tenant_id"`
-	KeyHash   string    `json:"key_hash"`
-	RateLimit int64     `json:"rate_limit"` // max tokens per minute
-	Active    bool      `json:"active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           string     `json:"id"`
+	TenantID     string     `json:"tenant_id"`
+	KeyHash      string     `json:"key_hash"`
+	KeyHint      string     `json:"key_hint,omitempty"` // last characters of the key, see Hint
+	RateLimit    int64      `json:"rate_limit"`         // max tokens per minute
+	RateLimitRPM int64      `json:"rate_limit_rpm"`     // max requests per minute, 0 = unlimited
+	Scopes       []string   `json:"scopes,omitempty"`   // see HasScope; none means full access
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// hintLength is how many trailing characters of a key are kept for display.
+const hintLength = 4
+
+// Hint returns the part of key stored in KeyHint.
+func Hint(key string) string {
+	if len(key) <= hintLength {
+		return ""
+	}
+	return key[len(key)-hintLength:]
+}
+
+// GenerateKey returns a new random API key. Only its hash and hint are stored.
+func GenerateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return "sk-" + hex.EncodeToString(b), nil
+}
+
+// Masked identifies the key to its owner without revealing it.
+func (a *APIKey) Masked() string {
+	return "****" + a.KeyHint
 }
 
 // MarshalBinary implements encoding.BinaryMarshaler for Redis
@@ -39,21 +71,81 @@ func (a *APIKey) UnmarshalBinary(data []byte) error {
 
 type Store interface {
 	GetByKey(ctx context.Context, key string) (*APIKey, error)
+	// GetByID returns the key with the given ID, active or revoked.
+	GetByID(ctx context.Context, keyID string) (*APIKey, error)
 	Create(ctx context.Context, apiKey *APIKey) error
 	Revoke(ctx context.Context, keyID string) error
+	// SetScopes replaces the key's scopes, which must not be empty, and
+	// returns the key's hash.
+	SetScopes(ctx context.Context, keyID string, scopes []string) (keyHash string, err error)
+	// Export returns every key (hashes only) for migration to another deployment.
+	Export(ctx context.Context) ([]*APIKey, error)
+	// Import inserts keys as-is, preserving IDs and tenant mappings. Keys whose
+	// ID or hash already exist are skipped; it returns how many were inserted.
+	Import(ctx context.Context, keys []*APIKey) (int, error)
+	// ListByTenant returns the tenant's keys, active and revoked, newest first.
+	ListByTenant(ctx context.Context, tenantID string) ([]*APIKey, error)
+	// TouchLastUsed advances last_used_at for each key ID; older times are ignored.
+	TouchLastUsed(ctx context.Context, used map[string]time.Time) error
+	// ListUnusedSince returns active keys not used since before, including
+	// never-used keys created before it, least recently used first.
+	ListUnusedSince(ctx context.Context, before time.Time) ([]*APIKey, error)
 }
 
 type Middleware func(next http.Handler) http.Handler
 
+// MiddlewareOption configures optional Middleware features.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	lastUsed *LastUsedTracker
+	jwt      *JWTVerifier
+	status   TenantStatus
+}
+
+// TenantStatus reports whether a tenant may authenticate.
+type TenantStatus func(ctx context.Context, tenantID string) (active bool, err error)
+
+// WithTenantStatus rejects API keys and JWTs of tenants status reports
+// inactive, e.g. suspended ones. Answers are kept for five minutes per
+// instance, like cached keys, so requests don't cost a lookup each.
+func WithTenantStatus(status TenantStatus) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.status = status
+	}
+}
+
+// WithLastUsed records each successful authentication in tracker.
+func WithLastUsed(tracker *LastUsedTracker) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.lastUsed = tracker
+	}
+}
+
+// WithJWT also accepts JWTs that verifier validates, on the same routes and
+// header as API keys. They authenticate as the tenant in their claims, with
+// their tier's rate limits and their scopes; the tenant needs no API key.
+func WithJWT(verifier *JWTVerifier) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.jwt = verifier
+	}
+}
+
 type contextKey string
 
 const (
 	tenantIDKey  contextKey = "tenant_id"
 	apiKeyIDKey  contextKey = "api_key_id"
+	limitsKey    contextKey = "rate_limits"
 	requestIDKey contextKey = "request_id"
 )
 
-func NewMiddleware(store Store, cache *redis.Client) Middleware {
+func NewMiddleware(store Store, cache *redis.Client, opts ...MiddlewareOption) Middleware {
+	var cfg middlewareConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	tenants := newTenantCheck(cfg.status, 5*time.Minute)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -61,55 +153,190 @@ func NewMiddleware(store Store, cache *redis.Client) Middleware {
 			// Generate RequestID
 			requestID := uuid.New().String()
 			ctx = context.WithValue(ctx, requestIDKey, requestID)
+			ctx = logging.With(ctx, "request_id", requestID)
 			w.Header().Set("X-Request-ID", requestID)
 
-			// Extract Authorization header
+			// Extract Authorization header; Anthropic SDKs send the key as
+			// x-api-key instead.
 			authHeader := r.Header.Get("Authorization")
+			if key := r.Header.Get("X-API-Key"); authHeader == "" && key != "" {
+				authHeader = "Bearer " + key
+			}
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized: missing or invalid Authorization header", http.StatusUnauthorized)
+				apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "missing or invalid Authorization header")
 				return
 			}
 			key := strings.TrimPrefix(authHeader, "Bearer ")
 
-			// Hash key for Redis lookup
-			h := sha256.New()
-			h.Write([]byte(key))
-			keyHash := hex.EncodeToString(h.Sum(nil))
-			redisKey := fmt.Sprintf("auth:%s", keyHash)
+			if cfg.jwt != nil && LooksLikeJWT(key) {
+				id, err := cfg.jwt.Verify(ctx, key)
+				if err != nil {
+					if errors.Is(err, ErrInvalidToken) {
+						apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, err.Error())
+						return
+					}
+					logging.FromContext(ctx).Error("auth: jwt verification failed", "err", err)
+					apierror.Write(w, http.StatusInternalServerError, "", "internal server error")
+					return
+				}
+				if !tenants.admit(w, ctx, id.TenantID) {
+					return
+				}
+				ctx = context.WithValue(ctx, tenantIDKey, id.TenantID)
+				ctx = WithRateLimits(ctx, id.Limits)
+				ctx = WithScopes(ctx, id.Scopes)
+				ctx = logging.With(ctx, "tenant_id", id.TenantID)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			redisKey := keyCacheKey(HashKey(key))
 
 			var apiKey APIKey
 			err := cache.Get(ctx, redisKey).Scan(&apiKey)
 			if err == nil {
 				// Cache hit
+				if !tenants.admit(w, ctx, apiKey.TenantID) {
+					return
+				}
+				cfg.lastUsed.Touch(apiKey.ID)
 				ctx = context.WithValue(ctx, tenantIDKey, apiKey.TenantID)
 				ctx = context.WithValue(ctx, apiKeyIDKey, apiKey.ID)
+				ctx = WithRateLimits(ctx, RateLimits{TPM: apiKey.RateLimit, RPM: apiKey.RateLimitRPM})
+				ctx = WithScopes(ctx, apiKey.Scopes)
+				ctx = logging.With(ctx, "tenant_id", apiKey.TenantID, "api_key_id", apiKey.ID)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			} else if err != redis.Nil {
-				log.Printf("auth: redis error: %v", err)
+				logging.FromContext(ctx).Warn("auth: key cache lookup failed", "err", err)
 			}
 
 			// Cache miss or error: lookup in store
 			apiK, err := store.GetByKey(ctx, key)
 			if err != nil {
 				if errors.Is(err, ErrKeyNotFound) {
-					http.Error(w, "Unauthorized: invalid API key", http.StatusUnauthorized)
+					apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "invalid API key")
 					return
 				}
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				apierror.Write(w, http.StatusInternalServerError, "", "internal server error")
 				return
 			}
 
 			// Cache the result for 5 minutes
 			_ = cache.Set(ctx, redisKey, apiK, 5*time.Minute).Err()
 
+			if !tenants.admit(w, ctx, apiK.TenantID) {
+				return
+			}
+			cfg.lastUsed.Touch(apiK.ID)
 			ctx = context.WithValue(ctx, tenantIDKey, apiK.TenantID)
 			ctx = context.WithValue(ctx, apiKeyIDKey, apiK.ID)
+			ctx = WithRateLimits(ctx, RateLimits{TPM: apiK.RateLimit, RPM: apiK.RateLimitRPM})
+			ctx = WithScopes(ctx, apiK.Scopes)
+			ctx = logging.With(ctx, "tenant_id", apiK.TenantID, "api_key_id", apiK.ID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// keyCacheKey is where the middleware caches the lookup of the key hashing
+// to keyHash, for five minutes.
+func keyCacheKey(keyHash string) string {
+	return "auth:" + keyHash
+}
+
+// KeyCache forgets cached key lookups, so a change to a key applies on its
+// next request instead of when the cached lookup expires.
+type KeyCache interface {
+	Forget(ctx context.Context, keyHash string) error
+}
+
+// RedisKeyCache is the Redis cache NewMiddleware keeps key lookups in.
+type RedisKeyCache struct {
+	rdb *redis.Client
+}
+
+func NewRedisKeyCache(rdb *redis.Client) *RedisKeyCache {
+	return &RedisKeyCache{rdb: rdb}
+}
+
+func (c *RedisKeyCache) Forget(ctx context.Context, keyHash string) error {
+	return c.rdb.Del(ctx, keyCacheKey(keyHash)).Err()
+}
+
+// tenantCheck caches a TenantStatus's answers for ttl. Without a status,
+// every tenant is active.
+type tenantCheck struct {
+	status TenantStatus
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	seen map[string]tenantState
+}
+
+type tenantState struct {
+	active    bool
+	expiresAt time.Time
+}
+
+func newTenantCheck(status TenantStatus, ttl time.Duration) *tenantCheck {
+	return &tenantCheck{status: status, ttl: ttl, seen: make(map[string]tenantState)}
+}
+
+// admit reports whether tenantID is active, writing 401 when it isn't and
+// 500 when its status can't be read.
+func (c *tenantCheck) admit(w http.ResponseWriter, ctx context.Context, tenantID string) bool {
+	active, err := c.active(ctx, tenantID)
+	if err != nil {
+		logging.FromContext(ctx).Error("auth: tenant status lookup failed", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, "", "internal server error")
+		return false
+	}
+	if !active {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidAPIKey, "tenant is not active")
+		return false
+	}
+	return true
+}
+
+func (c *tenantCheck) active(ctx context.Context, tenantID string) (bool, error) {
+	if c.status == nil {
+		return true, nil
+	}
+	c.mu.Lock()
+	s, ok := c.seen[tenantID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(s.expiresAt) {
+		return s.active, nil
+	}
+
+	active, err := c.status(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	s = tenantState{active: active, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Lock()
+	c.seen[tenantID] = s
+	c.mu.Unlock()
+	return s.active, nil
+}
+
+// NewAdminMiddleware guards operator endpoints with a static bearer token.
+func NewAdminMiddleware(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			given := strings.TrimPrefix(authHeader, "Bearer ")
+			if token == "" || !strings.HasPrefix(authHeader, "Bearer ") ||
+				subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				http.Error(w, "Unauthorized: invalid admin token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetTenantID Helpers to extract from context
 func GetTenantID(ctx context.Context) string {
 	if id, ok := ctx.Value(tenantIDKey).(string); ok {
@@ -125,6 +352,18 @@ func GetAPIKeyID(ctx context.Context) string {
 	return ""
 }
 
+// RateLimits are the authenticated key's per-minute allowances; zero values
+// mean the key sets no limit of its own.
+type RateLimits struct {
+	TPM int64
+	RPM int64
+}
+
+func GetRateLimits(ctx context.Context) RateLimits {
+	limits, _ := ctx.Value(limitsKey).(RateLimits)
+	return limits
+}
+
 func GetRequestID(ctx context.Context) string {
 	if id, ok := ctx.Value(requestIDKey).(string); ok {
 		return id
@@ -144,3 +383,7 @@ func WithRequestID(ctx context.Context, requestID string) context.Context {
 func WithAPIKeyID(ctx context.Context, apiKeyID string) context.Context {
 	return context.WithValue(ctx, apiKeyIDKey, apiKeyID)
 }
+
+func WithRateLimits(ctx context.Context, limits RateLimits) context.Context {
+	return context.WithValue(ctx, limitsKey, limits)
+}
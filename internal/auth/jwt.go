@@ -0,0 +1,386 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrInvalidToken is wrapped by every reason a JWT is rejected.
+var ErrInvalidToken = errors.New("invalid token")
+
+const (
+	// clockSkew is how far exp and nbf may be off before a token is rejected.
+	clockSkew = time.Minute
+	// jwksMinRefetch spaces out JWKS fetches for key IDs the set lacks, so
+	// tokens with made-up kids can't hammer the identity provider.
+	jwksMinRefetch = time.Minute
+)
+
+// JWTConfig is how JWTVerifier checks tokens and maps their claims.
+type JWTConfig struct {
+	JWKSURL  string
+	Issuer   string // required "iss" when set
+	Audience string // required among "aud" when set
+	// TenantClaim holds the tenant ID, a UUID; default "tenant_id".
+	TenantClaim string
+	// TierClaim names a rate limit tier in Tiers; tokens without it get the
+	// gateway's default limits. Default "tier".
+	TierClaim string
+	Tiers     map[string]RateLimits
+	// ScopeClaim holds the token's scopes, a space-separated string or a
+	// list; default "scope". Scopes the gateway doesn't know, such as
+	// "openid", are ignored.
+	ScopeClaim string
+	// DefaultScopes apply to tokens granting no gateway scopes. Tokens are
+	// rejected when both are empty, since no scopes would mean full access.
+	DefaultScopes []string
+	// JWKSRefresh is how long fetched keys are used before fetching them
+	// again; default 1h.
+	JWKSRefresh time.Duration
+	Client      *http.Client
+}
+
+// Identity is what a verified token authenticates.
+type Identity struct {
+	Subject  string
+	TenantID string
+	Limits   RateLimits
+	Scopes   []string
+}
+
+// JWTVerifier validates JWTs signed by keys from an OIDC provider's JWKS
+// endpoint. RS256/384/512 and ES256/384/512 are accepted.
+type JWTVerifier struct {
+	cfg JWTConfig
+	now func() time.Time
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	fetches   singleflight.Group
+}
+
+func NewJWTVerifier(cfg JWTConfig) *JWTVerifier {
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant_id"
+	}
+	if cfg.TierClaim == "" {
+		cfg.TierClaim = "tier"
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	if cfg.JWKSRefresh <= 0 {
+		cfg.JWKSRefresh = time.Hour
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWTVerifier{cfg: cfg, now: time.Now}
+}
+
+// LooksLikeJWT tells JWTs from API keys, which never contain dots.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks token's signature and claims. Rejected tokens return an
+// error wrapping ErrInvalidToken; other errors mean the keys couldn't be
+// fetched.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	return v.identity(claims)
+}
+
+// identity checks the registered claims and maps the rest.
+func (v *JWTVerifier) identity(claims map[string]any) (*Identity, error) {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.cfg.Audience != "" && !hasAudience(claims["aud"], v.cfg.Audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	id := &Identity{}
+	id.Subject, _ = claims["sub"].(string)
+	id.TenantID, _ = claims[v.cfg.TenantClaim].(string)
+	if _, err := uuid.Parse(id.TenantID); err != nil {
+		return nil, fmt.Errorf("%w: %s must be a tenant ID", ErrInvalidToken, v.cfg.TenantClaim)
+	}
+	if tier, ok := claims[v.cfg.TierClaim].(string); ok {
+		limits, known := v.cfg.Tiers[tier]
+		if !known {
+			return nil, fmt.Errorf("%w: unknown rate limit tier %q", ErrInvalidToken, tier)
+		}
+		id.Limits = limits
+	}
+	for _, s := range scopeList(claims[v.cfg.ScopeClaim]) {
+		if ValidateScopes([]string{s}) == nil {
+			id.Scopes = append(id.Scopes, s)
+		}
+	}
+	if len(id.Scopes) == 0 {
+		id.Scopes = slices.Clone(v.cfg.DefaultScopes)
+	}
+	if len(id.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: grants no gateway scopes", ErrInvalidToken)
+	}
+	return id, nil
+}
+
+// scopeList reads a scope claim, either OAuth's space-separated string or a
+// list of strings.
+func scopeList(claim any) []string {
+	switch c := claim.(type) {
+	case string:
+		return strings.Fields(c)
+	case []any:
+		var out []string
+		for _, s := range c {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		for _, s := range a {
+			if s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(len(alg), 2):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") || rsa.VerifyPKCS1v15(k, hash, digest, sig) != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported key", ErrInvalidToken)
+	}
+	return nil
+}
+
+// key returns the signing key kid names, fetching the JWKS when the cached
+// one is stale or lacks it.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	age := v.now().Sub(v.fetchedAt)
+	key, ok := v.keys[kid]
+	fetched := v.keys != nil
+	v.mu.RUnlock()
+	if ok && age < v.cfg.JWKSRefresh {
+		return key, nil
+	}
+	if ok || !fetched || age >= jwksMinRefetch {
+		keys, err := v.refreshKeys(ctx)
+		if err != nil {
+			if ok {
+				// Keep using a known key while the provider is unreachable.
+				return key, nil
+			}
+			return nil, err
+		}
+		if key, ok = keys[kid]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+}
+
+// refreshKeys fetches and caches the JWKS, once for all callers waiting on
+// it. Verification doesn't wait on the lock while the provider answers, and
+// one caller going away doesn't fail the fetch for the others.
+func (v *JWTVerifier) refreshKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	keys, err, _ := v.fetches.Do("jwks", func() (any, error) {
+		keys, err := v.fetchKeys(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		v.mu.Lock()
+		v.keys, v.fetchedAt = keys, v.now()
+		v.mu.Unlock()
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys.(map[string]crypto.PublicKey), nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *JWTVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys the gateway can't use, e.g. other key types, are skipped.
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
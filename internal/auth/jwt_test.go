@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTenant = "00000000-0000-0000-0000-000000000001"
+
+type testIssuer struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func (iss *testIssuer) fetchCount() int {
+	return int(iss.fetches.Load())
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{rsa: rsaKey, ec: ecKey}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	iss.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kid": "rsa", "kty": "RSA", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kid": "ec", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	}))
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (iss *testIssuer) verifier() *JWTVerifier {
+	return NewJWTVerifier(JWTConfig{
+		JWKSURL:  iss.server.URL,
+		Issuer:   "https://idp.test",
+		Audience: "llm-gateway",
+		Tiers:    map[string]RateLimits{"standard": {TPM: 1000, RPM: 10}},
+	})
+}
+
+func testClaims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":       "https://idp.test",
+		"aud":       []string{"other", "llm-gateway"},
+		"sub":       "billing-service",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"tenant_id": testTenant,
+		"tier":      "standard",
+		"scope":     "openid chat:write usage:read",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier()
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa", "ES256": "ec"}[alg]
+		id, err := v.Verify(context.Background(), iss.sign(t, alg, kid, testClaims(nil)))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if id.TenantID != testTenant || id.Subject != "billing-service" || id.Limits != (RateLimits{TPM: 1000, RPM: 10}) {
+			t.Errorf("%s: unexpected identity %+v", alg, id)
+		}
+		if !slices.Equal(id.Scopes, []string{ScopeChatWrite, ScopeUsageRead}) {
+			t.Errorf("%s: expected the token's gateway scopes, got %v", alg, id.Scopes)
+		}
+	}
+	if n := iss.fetchCount(); n != 1 {
+		t.Errorf("Expected the keys to be fetched once, got %d", n)
+	}
+
+	if id, err := v.Verify(context.Background(), iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"tier": nil}))); err != nil || id.Limits != (RateLimits{}) {
+		t.Errorf("Expected default limits without a tier, got %+v, %v", id, err)
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier()
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"no exp", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"exp": nil}))},
+		{"issuer", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"iss": "https://evil.test"}))},
+		{"audience", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"aud": "other"}))},
+		{"tenant", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"tenant_id": "acme"}))},
+		{"tier", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"tier": "unlimited"}))},
+		{"no scopes", iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"scope": "openid profile"}))},
+		{"unknown key", iss.sign(t, "RS256", "other", testClaims(nil))},
+		{"alg mismatch", iss.sign(t, "ES256", "rsa", testClaims(nil))},
+		{"alg none", strings.Join(strings.Split(iss.sign(t, "none", "rsa", testClaims(nil)), ".")[:2], ".") + "."},
+		{"tampered", func() string {
+			parts := strings.Split(iss.sign(t, "RS256", "rsa", testClaims(nil)), ".")
+			claims, _ := json.Marshal(testClaims(map[string]any{"tier": nil}))
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(claims) + "." + parts[2]
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(context.Background(), tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+	// Unknown key IDs refetch the keys at most once a minute.
+	if n := iss.fetchCount(); n != 1 {
+		t.Errorf("Expected one fetch, got %d", n)
+	}
+}
+
+func TestJWTVerifier_DefaultScopes(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier()
+	v.cfg.DefaultScopes = []string{ScopeChatWrite}
+
+	id, err := v.Verify(context.Background(), iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"scope": nil})))
+	if err != nil || !slices.Equal(id.Scopes, []string{ScopeChatWrite}) {
+		t.Errorf("Expected the default scopes without a scope claim, got %+v, %v", id, err)
+	}
+	id, err = v.Verify(context.Background(), iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"scope": []string{"jobs:read"}})))
+	if err != nil || !slices.Equal(id.Scopes, []string{ScopeJobsRead}) {
+		t.Errorf("Expected a listed scope claim to replace the defaults, got %+v, %v", id, err)
+	}
+}
+
+func TestJWTVerifier_FetchesKeysOnceForConcurrentTokens(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := iss.verifier()
+	token := iss.sign(t, "RS256", "rsa", testClaims(nil))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), token); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := iss.fetchCount(); n != 1 {
+		t.Errorf("Expected one JWKS fetch, got %d", n)
+	}
+}
+
+// suspended is a TenantStatus under which only the given tenants are
+// inactive.
+func suspended(tenantIDs ...string) TenantStatus {
+	return func(ctx context.Context, tenantID string) (bool, error) {
+		return !slices.Contains(tenantIDs, tenantID), nil
+	}
+}
+
+func TestMiddleware_AcceptsJWT(t *testing.T) {
+	iss := newTestIssuer(t)
+	var tenantID string
+	var limits RateLimits
+	var scopes []string
+	// The tenant has no API key: tokens don't need one.
+	mw := NewMiddleware(nil, nil, WithJWT(iss.verifier()), WithTenantStatus(suspended("other-tenant")))
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, limits, scopes = GetTenantID(r.Context()), GetRateLimits(r.Context()), GetScopes(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer "+iss.sign(t, "RS256", "rsa", testClaims(nil)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || tenantID != testTenant || limits.TPM != 1000 {
+		t.Errorf("Expected the token's tenant and tier, got %d, %q, %+v", w.Code, tenantID, limits)
+	}
+	if HasScope(scopes, ScopeKeysRead) || !HasScope(scopes, ScopeUsageRead) {
+		t.Errorf("Expected only the token's scopes, got %v", scopes)
+	}
+
+	// Anthropic SDKs send credentials as x-api-key.
+	req.Header.Del("Authorization")
+	req.Header.Set("X-API-Key", iss.sign(t, "RS256", "rsa", testClaims(nil)))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || tenantID != testTenant {
+		t.Errorf("Expected x-api-key to be accepted, got %d, %q", w.Code, tenantID)
+	}
+	req.Header.Del("X-API-Key")
+
+	req.Header.Set("Authorization", "Bearer "+iss.sign(t, "RS256", "rsa", testClaims(map[string]any{"aud": "other"})))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a rejected token, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_RejectsJWTForInactiveTenant(t *testing.T) {
+	iss := newTestIssuer(t)
+	lookups := 0
+	status := func(ctx context.Context, tenantID string) (bool, error) {
+		lookups++
+		return tenantID != testTenant, nil
+	}
+	mw := NewMiddleware(nil, nil, WithJWT(iss.verifier()), WithTenantStatus(status))
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 2 {
+		req := httptest.NewRequest("GET", "/v1/usage", nil)
+		req.Header.Set("Authorization", "Bearer "+iss.sign(t, "RS256", "rsa", testClaims(nil)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "tenant is not active") {
+			t.Errorf("Expected 401 for a suspended tenant, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Expected the tenant's status to be cached, got %d lookups", lookups)
+	}
+}
+
+func TestMiddleware_TenantStatusErrorFailsClosed(t *testing.T) {
+	iss := newTestIssuer(t)
+	status := func(ctx context.Context, tenantID string) (bool, error) {
+		return false, errors.New("database down")
+	}
+	mw := NewMiddleware(nil, nil, WithJWT(iss.verifier()), WithTenantStatus(status))
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer "+iss.sign(t, "RS256", "rsa", testClaims(nil)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 when the status can't be read, got %d", w.Code)
+	}
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// lastUsedKey is the Redis hash of key ID -> last use (Unix ms) shared by
+// every instance until the next flush.
+const lastUsedKey = "auth:last_used"
+
+// touchScript keeps the later of the stored and given time for each key, so
+// instances flushing out of order never move a timestamp backwards.
+var touchScript = redis.NewScript(`
+for i = 1, #ARGV, 2 do
+	local cur = tonumber(redis.call('HGET', KEYS[1], ARGV[i]) or '0')
+	if tonumber(ARGV[i + 1]) > cur then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+return 1
+`)
+
+// claimScript moves the shared hash aside so exactly one instance writes it.
+var claimScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+return 1
+`)
+
+// LastUsedTracker buffers successful authentications and writes each key's
+// latest use at most once per interval, instead of once per request.
+type LastUsedTracker struct {
+	store    Store
+	rdb      *redis.Client
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+// LastUsedOption configures a LastUsedTracker.
+type LastUsedOption func(*LastUsedTracker)
+
+// WithRedisBuffer collects touches from every instance in Redis, so each
+// interval one instance writes them all to the store.
+func WithRedisBuffer(rdb *redis.Client) LastUsedOption {
+	return func(t *LastUsedTracker) {
+		t.rdb = rdb
+	}
+}
+
+func NewLastUsedTracker(store Store, interval time.Duration, opts ...LastUsedOption) *LastUsedTracker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := &LastUsedTracker{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Touch records that keyID authenticated now. A nil tracker does nothing.
+func (t *LastUsedTracker) Touch(keyID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.pending[keyID] = t.now()
+	t.mu.Unlock()
+}
+
+func (t *LastUsedTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), t.interval)
+			t.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+func (t *LastUsedTracker) flush(ctx context.Context) {
+	pending := t.take()
+	if t.rdb == nil {
+		if len(pending) > 0 {
+			if err := t.store.TouchLastUsed(ctx, pending); err != nil {
+				slog.ErrorContext(ctx, "auth: failed to update last_used_at", "keys", len(pending), "err", err)
+				t.restore(pending)
+			}
+		}
+		return
+	}
+
+	if len(pending) > 0 {
+		if err := t.buffer(ctx, pending); err != nil {
+			slog.ErrorContext(ctx, "auth: failed to buffer last_used_at", "keys", len(pending), "err", err)
+			t.restore(pending)
+			return
+		}
+	}
+	t.drain(ctx)
+}
+
+func (t *LastUsedTracker) take() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := t.pending
+	t.pending = make(map[string]time.Time)
+	return pending
+}
+
+// restore keeps times from a failed flush for the next one unless newer ones
+// arrived meanwhile.
+func (t *LastUsedTracker) restore(pending map[string]time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, at := range pending {
+		if cur, ok := t.pending[id]; !ok || cur.Before(at) {
+			t.pending[id] = at
+		}
+	}
+}
+
+func (t *LastUsedTracker) buffer(ctx context.Context, used map[string]time.Time) error {
+	args := make([]interface{}, 0, 2*len(used))
+	for id, at := range used {
+		args = append(args, id, at.UnixMilli())
+	}
+	return touchScript.Run(ctx, t.rdb, []string{lastUsedKey}, args...).Err()
+}
+
+// drain writes the shared buffer to the store if no other instance claimed
+// it first. Touches that fail to write go back into the buffer.
+func (t *LastUsedTracker) drain(ctx context.Context) {
+	claim := lastUsedKey + ":flush:" + uuid.New().String()
+	claimed, err := claimScript.Run(ctx, t.rdb, []string{lastUsedKey, claim}).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "auth: failed to claim buffered last_used_at", "err", err)
+		return
+	}
+	if claimed == 0 {
+		return
+	}
+	defer t.rdb.Del(context.WithoutCancel(ctx), claim)
+
+	vals, err := t.rdb.HGetAll(ctx, claim).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "auth: failed to read buffered last_used_at", "err", err)
+		return
+	}
+	used := make(map[string]time.Time, len(vals))
+	for id, v := range vals {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		used[id] = time.UnixMilli(ms).UTC()
+	}
+	if err := t.store.TouchLastUsed(ctx, used); err != nil {
+		slog.ErrorContext(ctx, "auth: failed to update last_used_at", "keys", len(used), "err", err)
+		if err := t.buffer(context.WithoutCancel(ctx), used); err != nil {
+			slog.ErrorContext(ctx, "auth: dropped last_used_at", "keys", len(used), "err", err)
+		}
+	}
+}
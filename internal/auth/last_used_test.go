@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type touchStore struct {
+	Store
+	fail    bool
+	batches []map[string]time.Time
+}
+
+func (s *touchStore) TouchLastUsed(ctx context.Context, used map[string]time.Time) error {
+	if s.fail {
+		return errors.New("db down")
+	}
+	s.batches = append(s.batches, used)
+	return nil
+}
+
+func TestLastUsedTracker_BatchesTouches(t *testing.T) {
+	store := &touchStore{}
+	tracker := NewLastUsedTracker(store, time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Touch("a")
+	now = now.Add(time.Second)
+	tracker.Touch("a")
+	tracker.Touch("b")
+	tracker.flush(context.Background())
+	tracker.flush(context.Background())
+
+	if len(store.batches) != 1 {
+		t.Fatalf("Expected one write, got %d", len(store.batches))
+	}
+	if got := store.batches[0]; len(got) != 2 || !got["a"].Equal(now) {
+		t.Errorf("Expected the latest use per key, got %v", got)
+	}
+}
+
+func TestLastUsedTracker_RetriesFailedFlush(t *testing.T) {
+	store := &touchStore{fail: true}
+	tracker := NewLastUsedTracker(store, time.Minute)
+
+	tracker.Touch("a")
+	tracker.flush(context.Background())
+	store.fail = false
+	tracker.flush(context.Background())
+
+	if len(store.batches) != 1 || len(store.batches[0]) != 1 {
+		t.Errorf("Expected the failed touch to be written on the next flush, got %v", store.batches)
+	}
+}
+
+func TestLastUsedTracker_NilIsNoop(t *testing.T) {
+	var tracker *LastUsedTracker
+	tracker.Touch("a")
+}
+
+func TestHint(t *testing.T) {
+	if got := Hint("test-api-key-12345"); got != "2345" {
+		t.Errorf("Hint = %q, want 2345", got)
+	}
+	if got := Hint("abc"); got != "" {
+		t.Errorf("Hint of a short key = %q, want empty", got)
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
 }
@@ -24,23 +26,46 @@ func NewPostgresStore(db DB) Store {
 	return &PostgresStore{db: db}
 }
 
-func hashKey(key string) string {
+// HashKey returns the stored form of key.
+func HashKey(key string) string {
 	h := sha256.New()
 	h.Write([]byte(key))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
 func (s *PostgresStore) GetByKey(ctx context.Context, key string) (*APIKey, error) {
-	keyHash := hashKey(key)
+	keyHash := HashKey(key)
 	query := `
-		SELECT id, tenant_id, key_hash, rate_limit, active, created_at
+		SELECT id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at
 		FROM api_keys
 		WHERE key_hash = $1 AND active = true
 	`
 
 	var k APIKey
 	err := s.db.QueryRow(ctx, query, keyHash).Scan(
-		&k.ID, &k.TenantID, &k.KeyHash, &k.RateLimit, &k.Active, &k.CreatedAt,
+		&k.ID, &k.TenantID, &k.KeyHash, &k.KeyHint, &k.RateLimit, &k.RateLimitRPM, &k.Scopes, &k.Active, &k.CreatedAt, &k.LastUsedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &k, nil
+}
+
+func (s *PostgresStore) GetByID(ctx context.Context, keyID string) (*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at
+		FROM api_keys
+		WHERE id = $1
+	`
+
+	var k APIKey
+	err := s.db.QueryRow(ctx, query, keyID).Scan(
+		&k.ID, &k.TenantID, &k.KeyHash, &k.KeyHint, &k.RateLimit, &k.RateLimitRPM, &k.Scopes, &k.Active, &k.CreatedAt, &k.LastUsedAt,
 	)
 
 	if err != nil {
@@ -59,13 +84,13 @@ func (s *PostgresStore) Create(ctx context.Context, apiKey *APIKey) error {
 	}
 
 	query := `
-		INSERT INTO api_keys (tenant_id, key_hash, rate_limit, active)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO api_keys (tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at
 	`
 
 	err := s.db.QueryRow(ctx, query,
-		apiKey.TenantID, apiKey.KeyHash, apiKey.RateLimit, apiKey.Active,
+		apiKey.TenantID, apiKey.KeyHash, apiKey.KeyHint, apiKey.RateLimit, apiKey.RateLimitRPM, apiKey.Scopes, apiKey.Active,
 	).Scan(&apiKey.ID, &apiKey.CreatedAt)
 
 	if err != nil {
@@ -88,3 +113,113 @@ func (s *PostgresStore) Revoke(ctx context.Context, keyID string) error {
 
 	return nil
 }
+
+func (s *PostgresStore) SetScopes(ctx context.Context, keyID string, scopes []string) (string, error) {
+	query := `UPDATE api_keys SET scopes = $2 WHERE id = $1 RETURNING key_hash`
+	var keyHash string
+	err := s.db.QueryRow(ctx, query, keyID, scopes).Scan(&keyHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrKeyNotFound
+		}
+		return "", fmt.Errorf("failed to set api key scopes: %w", err)
+	}
+
+	return keyHash, nil
+}
+
+func (s *PostgresStore) Export(ctx context.Context) ([]*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at
+		FROM api_keys
+		ORDER BY created_at
+	`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export api keys: %w", err)
+	}
+	return scanKeys(rows)
+}
+
+func (s *PostgresStore) ListByTenant(ctx context.Context, tenantID string) ([]*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at
+		FROM api_keys
+		WHERE tenant_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := s.db.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return scanKeys(rows)
+}
+
+func (s *PostgresStore) ListUnusedSince(ctx context.Context, before time.Time) ([]*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at
+		FROM api_keys
+		WHERE active = true AND COALESCE(last_used_at, created_at) < $1
+		ORDER BY COALESCE(last_used_at, created_at)
+	`
+	rows, err := s.db.Query(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unused api keys: %w", err)
+	}
+	return scanKeys(rows)
+}
+
+func scanKeys(rows pgx.Rows) ([]*APIKey, error) {
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.TenantID, &k.KeyHash, &k.KeyHint, &k.RateLimit, &k.RateLimitRPM, &k.Scopes, &k.Active, &k.CreatedAt, &k.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, &k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *PostgresStore) TouchLastUsed(ctx context.Context, used map[string]time.Time) error {
+	ids := make([]string, 0, len(used))
+	times := make([]time.Time, 0, len(used))
+	for id, at := range used {
+		ids = append(ids, id)
+		times = append(times, at)
+	}
+	query := `
+		UPDATE api_keys k
+		SET last_used_at = u.at
+		FROM unnest($1::uuid[], $2::timestamptz[]) AS u(id, at)
+		WHERE k.id = u.id AND (k.last_used_at IS NULL OR k.last_used_at < u.at)
+	`
+	if _, err := s.db.Exec(ctx, query, ids, times); err != nil {
+		return fmt.Errorf("failed to update last_used_at: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Import(ctx context.Context, keys []*APIKey) (int, error) {
+	query := `
+		INSERT INTO api_keys (id, tenant_id, key_hash, key_hint, rate_limit, rate_limit_rpm, scopes, active, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT DO NOTHING
+	`
+	imported := 0
+	for _, k := range keys {
+		tag, err := s.db.Exec(ctx, query, k.ID, k.TenantID, k.KeyHash, k.KeyHint, k.RateLimit, k.RateLimitRPM, k.Scopes, k.Active, k.CreatedAt, k.LastUsedAt)
+		if err != nil {
+			return imported, fmt.Errorf("failed to import api key %s: %w", k.ID, err)
+		}
+		imported += int(tag.RowsAffected())
+	}
+
+	return imported, nil
+}
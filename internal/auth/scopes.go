@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+)
+
+// Scopes an API key can be granted. A scope "<resource>:*" grants every
+// action on the resource and "*" grants everything.
+const (
+	ScopeChatWrite    = "chat:write"    // completions, embeddings and async jobs
+	ScopeJobsRead     = "jobs:read"     // async job status and events
+	ScopeModelsRead   = "models:read"   // model metadata
+	ScopeUsageRead    = "usage:read"    // usage, forecast and budget
+	ScopeKeysRead     = "keys:read"     // the tenant's key listing
+	ScopeRequestsRead = "requests:read" // audited request payloads
+	// ScopeSynthetic lets a key mark its traffic as synthetic monitoring,
+	// which isn't billed; only an explicit grant allows it (see GrantedExactly).
+	ScopeSynthetic = "traffic:synthetic"
+	// Priority scopes limit a key to the X-Priority values they name; keys
+	// granted neither may send either.
+	ScopePriorityInteractive = "priority:interactive"
+	ScopePriorityBatch       = "priority:batch"
+	ScopeAll                 = "*"
+)
+
+// KnownScopes lists every grantable scope other than wildcards.
+var KnownScopes = []string{ScopeChatWrite, ScopeJobsRead, ScopeModelsRead, ScopeUsageRead, ScopeKeysRead, ScopeRequestsRead, ScopeSynthetic, ScopePriorityInteractive, ScopePriorityBatch}
+
+const scopesKey contextKey = "scopes"
+
+// ValidateScopes rejects scopes that grant nothing, so typos don't silently
+// lock a key out.
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if s == ScopeAll || slices.Contains(KnownScopes, s) {
+			continue
+		}
+		if resource, ok := strings.CutSuffix(s, ":*"); ok && slices.ContainsFunc(KnownScopes, func(k string) bool {
+			return strings.HasPrefix(k, resource+":")
+		}) {
+			continue
+		}
+		return fmt.Errorf("unknown scope %q", s)
+	}
+	return nil
+}
+
+// HasScope reports whether granted allows scope. Keys issued before scopes
+// existed have none and keep full access.
+func HasScope(granted []string, scope string) bool {
+	if len(granted) == 0 {
+		return true
+	}
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, g := range granted {
+		if g == ScopeAll || g == scope || g == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantedExactly reports whether granted names scope itself. Unlike
+// HasScope, wildcards and keys without scopes don't count, for scopes that
+// must never be granted by accident.
+func GrantedExactly(granted []string, scope string) bool {
+	return slices.Contains(granted, scope)
+}
+
+// RequireScope rejects requests whose API key wasn't granted scope. It runs
+// after the auth middleware, which puts the key's scopes on the context.
+func RequireScope(scope string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(GetScopes(r.Context()), scope) {
+				apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientScope, fmt.Sprintf("API key lacks the %s scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey).([]string)
+	return scopes
+}
+
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasScope(t *testing.T) {
+	cases := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{nil, ScopeChatWrite, true},
+		{[]string{ScopeUsageRead}, ScopeUsageRead, true},
+		{[]string{ScopeUsageRead}, ScopeChatWrite, false},
+		{[]string{"chat:*"}, ScopeChatWrite, true},
+		{[]string{"chat:*"}, ScopeJobsRead, false},
+		{[]string{ScopeAll}, ScopeRequestsRead, true},
+	}
+	for _, c := range cases {
+		if got := HasScope(c.granted, c.scope); got != c.want {
+			t.Errorf("HasScope(%v, %q) = %v, want %v", c.granted, c.scope, got, c.want)
+		}
+	}
+}
+
+func TestGrantedExactly(t *testing.T) {
+	if !GrantedExactly([]string{ScopeChatWrite, ScopeSynthetic}, ScopeSynthetic) {
+		t.Error("Expected an explicit grant to count")
+	}
+	for _, granted := range [][]string{nil, {ScopeAll}, {"traffic:*"}} {
+		if GrantedExactly(granted, ScopeSynthetic) {
+			t.Errorf("Expected %v not to grant %s", granted, ScopeSynthetic)
+		}
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	if err := ValidateScopes([]string{ScopeChatWrite, "usage:*", ScopeAll}); err != nil {
+		t.Errorf("Expected valid scopes, got %v", err)
+	}
+	for _, s := range []string{"chat:read", "admin:*", "usage"} {
+		if err := ValidateScopes([]string{s}); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	h := RequireScope(ScopeChatWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req.WithContext(WithScopes(req.Context(), []string{ScopeUsageRead})))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a reporting key, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected keys without scopes to keep access, got %d", w.Code)
+	}
+}
@@ -8,6 +8,7 @@ import (
 type UsageLog struct {
 	ID           string
 	TenantID     string
+	APIKeyID     string // empty when the request wasn't made with a key
 	RequestID    string
 	Provider     string
 	Model        string
@@ -15,11 +16,115 @@ type UsageLog struct {
 	OutputTokens int
 	CostUSD      float64
 	LatencyMs    int64
-	CreatedAt    time.Time
+	// Cached marks responses served from the response cache (billed at zero)
+	Cached bool
+	// RetrievedDocIDs lists documents injected by the retrieval stage, if any
+	RetrievedDocIDs []string
+	// FinishReason is set when the response was cut short, e.g.
+	// FinishReasonClientDisconnect; empty for completed responses
+	FinishReason string
+	// Stage names the gateway stage that made this extra upstream call for
+	// the request, e.g. StageTranslation, or StageStreamCheckpoint; empty
+	// for the request's own call
+	Stage string
+	// Synthetic marks monitoring traffic: it is stored apart from the
+	// tenant's usage and never billed, but counts toward provider invoices
+	Synthetic bool
+	CreatedAt time.Time
+}
+
+// FinishReasonClientDisconnect marks a stream billed for the tokens generated
+// before the client went away.
+const FinishReasonClientDisconnect = "client_disconnect"
+
+// FinishReasonMaxDuration marks a stream ended by its duration cap.
+const FinishReasonMaxDuration = "max_duration"
+
+// FinishReasonToolLoopFailed marks the rounds of a managed tool loop billed
+// after a later round failed.
+const FinishReasonToolLoopFailed = "tool_loop_failed"
+
+// Stages that bill extra upstream calls made on a request's behalf.
+const (
+	StageLanguageRetry = "language_retry"
+	StageTranslation   = "translation"
+	StageJSONRetry     = "json_retry"
+	// StageStreamCheckpoint bills a stream's usage while it is still
+	// running; its final log carries only the rest.
+	StageStreamCheckpoint = "stream_checkpoint"
+)
+
+// DailyCost is one day's spend rollup; Day is midnight UTC.
+type DailyCost struct {
+	Day     time.Time
+	CostUSD float64
+}
+
+// TenantDayUsage is one tenant's usage on one UTC day.
+type TenantDayUsage struct {
+	TenantID string
+	Day      time.Time
+	Requests int64
+	Tokens   int64 // input and output
+	CostUSD  float64
+}
+
+// KeyUsage totals one API key's usage over a period.
+type KeyUsage struct {
+	APIKeyID     string
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+}
+
+// Dimensions usage summaries can be grouped by.
+const (
+	GroupByDay      = "day" // UTC
+	GroupByModel    = "model"
+	GroupByProvider = "provider"
+)
+
+// UsageSummary totals usage sharing the dimensions it was grouped by; the
+// others are left zero.
+type UsageSummary struct {
+	Day          time.Time
+	Model        string
+	Provider     string
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
 }
 
 type Store interface {
 	LogUsage(ctx context.Context, log *UsageLog) error
-	GetUsageByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]*UsageLog, error)
-	GetTotalCostByTenant(ctx context.Context, tenantID string, from, to time.Time) (float64, error)
+	// GetUsageByTenant and GetTotalCostByTenant read the tenant's usage in
+	// [from, to] as it was recorded by asOf, leaving out rows backfilled or
+	// corrected since; a zero asOf reads current usage.
+	GetUsageByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]*UsageLog, error)
+	GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error)
+	// GetUsagePage returns up to limit of the tenant's usage logs in
+	// [from, to] as recorded by asOf, newest first, continuing after the log
+	// with ID after (empty: from the newest).
+	GetUsagePage(ctx context.Context, tenantID string, from, to, asOf time.Time, after string, limit int) ([]*UsageLog, error)
+	// GetUsageSummary totals the tenant's usage in [from, to] as recorded by
+	// asOf per combination of groupBy dimensions (GroupByDay, GroupByModel,
+	// GroupByProvider); without any it returns a single total.
+	GetUsageSummary(ctx context.Context, tenantID string, from, to, asOf time.Time, groupBy []string) ([]UsageSummary, error)
+	// GetUsageByRequests returns the tenant's usage logs for the given
+	// request IDs, oldest first; a request may have several, one per stage.
+	GetUsageByRequests(ctx context.Context, tenantID string, requestIDs []string) ([]*UsageLog, error)
+	// GetDailyCostByTenant returns per-day spend in [from, to], omitting days without usage.
+	GetDailyCostByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]DailyCost, error)
+	// GetUsageByKey totals the tenant's usage in [from, to] per API key, as
+	// recorded by asOf (zero: now), omitting usage not attributed to a key.
+	GetUsageByKey(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]KeyUsage, error)
+	// GetDailyUsageByModel totals what the gateway sent one provider in
+	// [from, to) per UTC day and model, across tenants, for reconciliation
+	// against the provider's invoice.
+	GetDailyUsageByModel(ctx context.Context, provider string, from, to time.Time) ([]ModelDayUsage, error)
+	// GetDailyUsageByTenants totals every tenant's usage in [from, to) per
+	// UTC day, omitting days a tenant had none.
+	GetDailyUsageByTenants(ctx context.Context, from, to time.Time) ([]TenantDayUsage, error)
 }
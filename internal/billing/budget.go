@@ -0,0 +1,168 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Spend is a tenant's running cost for the current UTC day and month.
+type Spend struct {
+	DayUSD   float64
+	MonthUSD float64
+}
+
+// Budget holds a tenant's spend caps; zero means uncapped.
+type Budget struct {
+	DailyUSD   float64
+	MonthlyUSD float64
+}
+
+// Exceeded returns "daily" or "monthly" when spend has reached that cap,
+// or "" while the tenant is within budget.
+func (b Budget) Exceeded(s Spend) string {
+	switch {
+	case b.DailyUSD > 0 && s.DayUSD >= b.DailyUSD:
+		return "daily"
+	case b.MonthlyUSD > 0 && s.MonthUSD >= b.MonthlyUSD:
+		return "monthly"
+	}
+	return ""
+}
+
+// SpendCounter tracks running spend per tenant so budget checks on the
+// request path don't aggregate usage_logs.
+type SpendCounter interface {
+	Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error
+	Get(ctx context.Context, tenantID string, at time.Time) (Spend, error)
+}
+
+// CostSource reports a tenant's logged spend, to seed counters from.
+type CostSource interface {
+	GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error)
+}
+
+// RedisSpendCounter keeps day and month totals in Redis keys that expire
+// after their window. Counters are a fast approximation of usage_logs.
+type RedisSpendCounter struct {
+	rdb  *redis.Client
+	seed CostSource
+}
+
+type SpendOption func(*RedisSpendCounter)
+
+// WithSeed loads a window's spend from usage_logs the first time its
+// counter is missing, so enforcement doesn't restart from zero after a
+// deploy to a fresh Redis or a flush mid-window.
+func WithSeed(src CostSource) SpendOption {
+	return func(c *RedisSpendCounter) {
+		c.seed = src
+	}
+}
+
+func NewRedisSpendCounter(rdb *redis.Client, opts ...SpendOption) *RedisSpendCounter {
+	c := &RedisSpendCounter{rdb: rdb}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func spendKeys(tenantID string, at time.Time) (day, month string) {
+	at = at.UTC()
+	return fmt.Sprintf("budget:%s:day:%s", tenantID, at.Format("2006-01-02")),
+		fmt.Sprintf("budget:%s:month:%s", tenantID, at.Format("2006-01"))
+}
+
+// spendWindow is one of a tenant's budget windows as of a moment.
+type spendWindow struct {
+	tenantID string
+	key      string
+	from     time.Time // the window's start
+	ttl      time.Duration
+}
+
+func spendWindows(tenantID string, at time.Time) (day, month spendWindow) {
+	at = at.UTC()
+	dayKey, monthKey := spendKeys(tenantID, at)
+	day = spendWindow{tenantID: tenantID, key: dayKey, from: at.Truncate(24 * time.Hour), ttl: 48 * time.Hour}
+	month = spendWindow{tenantID: tenantID, key: monthKey, from: time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC), ttl: 32 * 24 * time.Hour}
+	return day, month
+}
+
+func (c *RedisSpendCounter) Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error {
+	day, month := spendKeys(tenantID, at)
+	pipe := c.rdb.TxPipeline()
+	pipe.IncrByFloat(ctx, day, costUSD)
+	pipe.Expire(ctx, day, 48*time.Hour)
+	pipe.IncrByFloat(ctx, month, costUSD)
+	pipe.Expire(ctx, month, 32*24*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisSpendCounter) Get(ctx context.Context, tenantID string, at time.Time) (Spend, error) {
+	day, month := spendKeys(tenantID, at)
+	vals, err := c.rdb.MGet(ctx, day, month).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Spend{}, err
+	}
+	if c.seed != nil && len(vals) == 2 && (vals[0] == nil || vals[1] == nil) {
+		dayWindow, monthWindow := spendWindows(tenantID, at)
+		for i, w := range []spendWindow{dayWindow, monthWindow} {
+			if vals[i] != nil {
+				continue
+			}
+			if err := c.seedWindow(ctx, w, at); err != nil {
+				return Spend{}, err
+			}
+		}
+		if vals, err = c.rdb.MGet(ctx, day, month).Result(); err != nil && !errors.Is(err, redis.Nil) {
+			return Spend{}, err
+		}
+	}
+	var s Spend
+	if s.DayUSD, err = parseSpend(vals, 0); err != nil {
+		return Spend{}, err
+	}
+	if s.MonthUSD, err = parseSpend(vals, 1); err != nil {
+		return Spend{}, err
+	}
+	return s, nil
+}
+
+// seedWindow adds the window's logged spend to its counter, once per
+// window: a marker key claims the seed so concurrent readers don't each add
+// it, and increments made meanwhile are kept.
+func (c *RedisSpendCounter) seedWindow(ctx context.Context, w spendWindow, at time.Time) error {
+	marker := w.key + ":seeded"
+	claimed, err := c.rdb.SetNX(ctx, marker, 1, w.ttl).Result()
+	if err != nil || !claimed {
+		return err
+	}
+	total, err := c.seed.GetTotalCostByTenant(ctx, w.tenantID, w.from, at, time.Time{})
+	if err != nil {
+		c.rdb.Del(ctx, marker)
+		return fmt.Errorf("failed to seed spend for tenant %s: %w", w.tenantID, err)
+	}
+	pipe := c.rdb.TxPipeline()
+	pipe.IncrByFloat(ctx, w.key, total)
+	pipe.Expire(ctx, w.key, w.ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func parseSpend(vals []interface{}, i int) (float64, error) {
+	if i >= len(vals) || vals[i] == nil {
+		return 0, nil
+	}
+	str, ok := vals[i].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected spend counter value %T", vals[i])
+	}
+	return strconv.ParseFloat(str, 64)
+}
@@ -0,0 +1,80 @@
+package billing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBudget_Exceeded(t *testing.T) {
+	b := Budget{DailyUSD: 10, MonthlyUSD: 100}
+	tests := []struct {
+		spend Spend
+		want  string
+	}{
+		{Spend{DayUSD: 9.99, MonthUSD: 50}, ""},
+		{Spend{DayUSD: 10, MonthUSD: 50}, "daily"},
+		{Spend{DayUSD: 1, MonthUSD: 100}, "monthly"},
+	}
+	for _, tt := range tests {
+		if got := b.Exceeded(tt.spend); got != tt.want {
+			t.Errorf("Exceeded(%+v) = %q, want %q", tt.spend, got, tt.want)
+		}
+	}
+	if got := (Budget{}).Exceeded(Spend{DayUSD: 1e6, MonthUSD: 1e6}); got != "" {
+		t.Errorf("Expected zero budget to be uncapped, got %q", got)
+	}
+}
+
+func TestSpendKeys_UTCWindows(t *testing.T) {
+	// 23:30 in UTC-5 is already the next day, and month, in UTC.
+	at := time.Date(2024, 5, 31, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	day, month := spendKeys("t1", at)
+	if day != "budget:t1:day:2024-06-01" || month != "budget:t1:month:2024-06" {
+		t.Errorf("Unexpected keys %s, %s", day, month)
+	}
+}
+
+type memorySpend struct {
+	mu    sync.Mutex
+	added map[string]float64
+}
+
+func (m *memorySpend) Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.added[tenantID] += costUSD
+	return nil
+}
+
+func (m *memorySpend) Get(ctx context.Context, tenantID string, at time.Time) (Spend, error) {
+	return Spend{}, nil
+}
+
+type nopStore struct{ Store }
+
+func (nopStore) LogUsage(ctx context.Context, log *UsageLog) error { return nil }
+
+func TestRecorder_AddsSpend(t *testing.T) {
+	spend := &memorySpend{added: make(map[string]float64)}
+	r := NewRecorder(nopStore{}, 4, time.Second, WithSpendCounter(spend))
+
+	r.Record(context.Background(), &UsageLog{TenantID: "t1", CostUSD: 0.25})
+	r.Record(context.Background(), &UsageLog{TenantID: "t1", CostUSD: 0.5})
+	r.Record(context.Background(), &UsageLog{TenantID: "t2", Cached: true})
+	r.Record(context.Background(), &UsageLog{TenantID: "t3", CostUSD: 0.1, Synthetic: true})
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if spend.added["t1"] != 0.75 {
+		t.Errorf("Expected 0.75 spent by t1, got %v", spend.added["t1"])
+	}
+	if _, ok := spend.added["t2"]; ok {
+		t.Error("Expected zero-cost usage to leave spend untouched")
+	}
+	if _, ok := spend.added["t3"]; ok {
+		t.Error("Expected synthetic usage to leave spend untouched")
+	}
+}
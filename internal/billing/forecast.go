@@ -0,0 +1,121 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ForecastLinear extrapolates the month-to-date average daily spend.
+	ForecastLinear = "linear"
+	// ForecastSeasonal projects each remaining day from the average spend on
+	// the same weekday over the history window.
+	ForecastSeasonal = "seasonal"
+)
+
+// SeasonalHistory is how far back the seasonal method looks for weekday averages.
+const SeasonalHistory = 28 * 24 * time.Hour
+
+// Forecast is a projection of a tenant's spend for the current calendar month (UTC).
+type Forecast struct {
+	Method         string
+	MonthStart     time.Time
+	MonthEnd       time.Time
+	MonthToDateUSD float64
+	ProjectedUSD   float64
+	// BudgetExhaustedAt is the first day the projection crosses budget, nil if it doesn't.
+	BudgetExhaustedAt *time.Time
+}
+
+// HistoryStart returns where daily rollups passed to ProjectMonth should begin.
+func HistoryStart(now time.Time) time.Time {
+	start := monthStart(now)
+	if h := startOfDay(now.Add(-SeasonalHistory)); h.Before(start) {
+		return h
+	}
+	return start
+}
+
+// ProjectMonth projects end-of-month spend from daily rollups covering
+// [HistoryStart(now), now]. A budget of 0 disables exhaustion tracking.
+func ProjectMonth(daily []DailyCost, now time.Time, method string, budget float64) (*Forecast, error) {
+	now = now.UTC()
+	start := monthStart(now)
+	end := start.AddDate(0, 1, 0)
+	today := startOfDay(now)
+
+	f := &Forecast{Method: method, MonthStart: start, MonthEnd: end}
+	for _, d := range daily {
+		if !d.Day.Before(start) {
+			f.MonthToDateUSD += d.CostUSD
+		}
+	}
+
+	var perDay func(day time.Time) float64
+	switch method {
+	case ForecastLinear:
+		elapsed := now.Sub(start).Hours() / 24
+		if elapsed < 1.0/24 {
+			elapsed = 1.0 / 24
+		}
+		rate := f.MonthToDateUSD / elapsed
+		perDay = func(time.Time) float64 { return rate }
+	case ForecastSeasonal:
+		avg := weekdayAverages(daily, HistoryStart(now), today)
+		perDay = func(day time.Time) float64 { return avg[day.Weekday()] }
+	default:
+		return nil, fmt.Errorf("unknown forecast method %q", method)
+	}
+
+	spent := f.MonthToDateUSD
+	if budget > 0 && spent >= budget {
+		t := today
+		f.BudgetExhaustedAt = &t
+	}
+	// The rest of today counts pro rata, then every remaining whole day.
+	remainingToday := today.Add(24*time.Hour).Sub(now).Hours() / 24
+	spent += perDay(today) * remainingToday
+	for day := today; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.After(today) {
+			spent += perDay(day)
+		}
+		if budget > 0 && f.BudgetExhaustedAt == nil && spent >= budget {
+			t := day
+			f.BudgetExhaustedAt = &t
+		}
+	}
+	f.ProjectedUSD = spent
+	return f, nil
+}
+
+// weekdayAverages averages spend per weekday over the complete days in
+// [from, to); days without usage count as zero.
+func weekdayAverages(daily []DailyCost, from, to time.Time) [7]float64 {
+	var sums [7]float64
+	var counts [7]int
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		counts[day.Weekday()]++
+	}
+	for _, d := range daily {
+		if !d.Day.Before(from) && d.Day.Before(to) {
+			sums[d.Day.Weekday()] += d.CostUSD
+		}
+	}
+	var avg [7]float64
+	for i := range avg {
+		if counts[i] > 0 {
+			avg[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return avg
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,63 @@
+package billing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectMonth_Linear(t *testing.T) {
+	// Noon on June 11th: 10.5 days elapsed at $10/day.
+	now := time.Date(2024, 6, 11, 12, 0, 0, 0, time.UTC)
+	var daily []DailyCost
+	for d := 1; d <= 11; d++ {
+		cost := 10.0
+		if d == 11 {
+			cost = 5
+		}
+		daily = append(daily, DailyCost{Day: time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC), CostUSD: cost})
+	}
+
+	f, err := ProjectMonth(daily, now, ForecastLinear, 250)
+	if err != nil {
+		t.Fatalf("ProjectMonth failed: %v", err)
+	}
+	if f.MonthToDateUSD != 105 {
+		t.Errorf("Expected month-to-date 105, got %v", f.MonthToDateUSD)
+	}
+	if diff := f.ProjectedUSD - 300; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected projection 300, got %v", f.ProjectedUSD)
+	}
+	want := time.Date(2024, 6, 25, 0, 0, 0, 0, time.UTC)
+	if f.BudgetExhaustedAt == nil || !f.BudgetExhaustedAt.Equal(want) {
+		t.Errorf("Expected budget exhausted at %v, got %v", want, f.BudgetExhaustedAt)
+	}
+}
+
+func TestProjectMonth_SeasonalWeightsWeekdays(t *testing.T) {
+	// Start of Saturday June 1st; history only has weekday spend.
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var daily []DailyCost
+	for d := HistoryStart(now); d.Before(now); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			daily = append(daily, DailyCost{Day: d, CostUSD: 10})
+		}
+	}
+
+	f, err := ProjectMonth(daily, now, ForecastSeasonal, 0)
+	if err != nil {
+		t.Fatalf("ProjectMonth failed: %v", err)
+	}
+	// June 2024 has 20 weekdays.
+	if diff := f.ProjectedUSD - 200; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected projection 200, got %v", f.ProjectedUSD)
+	}
+	if f.BudgetExhaustedAt != nil {
+		t.Errorf("Expected no budget tracking, got %v", f.BudgetExhaustedAt)
+	}
+}
+
+func TestProjectMonth_UnknownMethod(t *testing.T) {
+	if _, err := ProjectMonth(nil, time.Now(), "magic", 0); err == nil {
+		t.Error("Expected error for unknown method")
+	}
+}
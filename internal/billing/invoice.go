@@ -0,0 +1,169 @@
+package billing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Invoice export formats ParseInvoice understands.
+const (
+	InvoiceOpenAI    = "openai"    // usage export from the OpenAI platform
+	InvoiceAnthropic = "anthropic" // usage export from the Anthropic console
+)
+
+// invoiceFormat names, per field, the CSV headers a format may use; the
+// first one present wins.
+type invoiceFormat struct {
+	provider string // gateway provider name the export bills for
+	day      []string
+	model    []string
+	input    []string
+	output   []string
+	cost     []string
+}
+
+var invoiceFormats = map[string]invoiceFormat{
+	InvoiceOpenAI: {
+		provider: "openai",
+		day:      []string{"date", "start_time", "bucket_start_time"},
+		model:    []string{"model", "snapshot_id"},
+		input:    []string{"input_tokens", "n_context_tokens_total"},
+		output:   []string{"output_tokens", "n_generated_tokens_total"},
+		cost:     []string{"cost_usd", "cost", "amount_value"},
+	},
+	InvoiceAnthropic: {
+		provider: "claude",
+		day:      []string{"usage_date_utc", "date"},
+		model:    []string{"model_version", "model"},
+		input:    []string{"input_tokens", "uncached_input_tokens"},
+		output:   []string{"output_tokens"},
+		cost:     []string{"cost_usd", "cost", "amount"},
+	},
+}
+
+// InvoiceProvider returns the gateway provider an export format bills for.
+func InvoiceProvider(format string) (string, bool) {
+	f, ok := invoiceFormats[format]
+	return f.provider, ok
+}
+
+// ParseInvoice reads a provider usage export in CSV and totals it per UTC
+// day and model. Exports may break a day down further, e.g. per project or
+// API key; those rows are summed.
+func ParseInvoice(format string, r io.Reader) ([]ModelDayUsage, error) {
+	f, ok := invoiceFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown invoice format %q", format)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	column := func(field string, names []string) (int, error) {
+		for _, n := range names {
+			if i, ok := cols[n]; ok {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("invoice has no %s column (expected one of %s)", field, strings.Join(names, ", "))
+	}
+	var idx [5]int
+	for i, c := range []struct {
+		field string
+		names []string
+	}{{"day", f.day}, {"model", f.model}, {"input tokens", f.input}, {"output tokens", f.output}, {"cost", f.cost}} {
+		if idx[i], err = column(c.field, c.names); err != nil {
+			return nil, err
+		}
+	}
+
+	type key struct {
+		day   time.Time
+		model string
+	}
+	totals := make(map[key]*ModelDayUsage)
+	var order []key
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invoice line %d: %w", line, err)
+		}
+		field := func(i int) string {
+			if idx[i] < len(rec) {
+				return strings.TrimSpace(rec[idx[i]])
+			}
+			return ""
+		}
+		model := field(1)
+		if model == "" {
+			continue // subtotal rows
+		}
+		day, err := parseInvoiceDay(field(0))
+		if err != nil {
+			return nil, fmt.Errorf("invoice line %d: %w", line, err)
+		}
+		input, err1 := parseInvoiceNumber(field(2))
+		output, err2 := parseInvoiceNumber(field(3))
+		cost, err3 := parseInvoiceNumber(field(4))
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("invoice line %d: %w", line, err)
+		}
+
+		k := key{day, model}
+		if totals[k] == nil {
+			totals[k] = &ModelDayUsage{Day: day, Provider: f.provider, Model: model}
+			order = append(order, k)
+		}
+		totals[k].InputTokens += int64(input)
+		totals[k].OutputTokens += int64(output)
+		totals[k].CostUSD += cost
+	}
+
+	usage := make([]ModelDayUsage, 0, len(order))
+	for _, k := range order {
+		usage = append(usage, *totals[k])
+	}
+	return usage, nil
+}
+
+// parseInvoiceDay accepts dates, timestamps and Unix seconds, truncated to
+// the UTC day.
+func parseInvoiceDay(v string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339, time.DateTime} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC().Truncate(24 * time.Hour), nil
+		}
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC().Truncate(24 * time.Hour), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", v)
+}
+
+// parseInvoiceNumber accepts plain and formatted amounts ("$1,234.50");
+// empty cells count as zero.
+func parseInvoiceNumber(v string) (float64, error) {
+	v = strings.NewReplacer("$", "", ",", "").Replace(v)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", v)
+	}
+	return n, nil
+}
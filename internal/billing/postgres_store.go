@@ -3,6 +3,7 @@ package billing
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -19,19 +20,23 @@ type PostgresStore struct {
 	db DB
 }
 
-func NewPostgresStore(db DB) Store {
+func NewPostgresStore(db DB) *PostgresStore {
 	return &PostgresStore{db: db}
 }
 
 func (s *PostgresStore) LogUsage(ctx context.Context, log *UsageLog) error {
+	table := "usage_logs"
+	if log.Synthetic {
+		table = "synthetic_usage_logs"
+	}
 	query := `
-		INSERT INTO usage_logs (tenant_id, request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO ` + table + ` (tenant_id, request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, cached, retrieved_doc_ids, api_key_id, finish_reason, stage)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, '')::uuid, $12, $13)
 		RETURNING id, created_at
 	`
 	err := s.db.QueryRow(ctx, query,
 		log.TenantID, log.RequestID, log.Provider, log.Model,
-		log.InputTokens, log.OutputTokens, log.CostUSD, log.LatencyMs,
+		log.InputTokens, log.OutputTokens, log.CostUSD, log.LatencyMs, log.Cached, log.RetrievedDocIDs, log.APIKeyID, log.FinishReason, log.Stage,
 	).Scan(&log.ID, &log.CreatedAt)
 
 	if err != nil {
@@ -41,14 +46,14 @@ func (s *PostgresStore) LogUsage(ctx context.Context, log *UsageLog) error {
 	return nil
 }
 
-func (s *PostgresStore) GetUsageByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]*UsageLog, error) {
+func (s *PostgresStore) GetUsageByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]*UsageLog, error) {
 	query := `
-		SELECT id, tenant_id, request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, created_at
-		FROM usage_logs
+		SELECT id, tenant_id, request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, cached, retrieved_doc_ids, finish_reason, stage, created_at
+		FROM usage_logs_as_of($4)
 		WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3
 		ORDER BY created_at DESC
 	`
-	rows, err := s.db.Query(ctx, query, tenantID, from, to)
+	rows, err := s.db.Query(ctx, query, tenantID, from, to, asOfArg(asOf))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query usage logs: %w", err)
 	}
@@ -59,7 +64,7 @@ func (s *PostgresStore) GetUsageByTenant(ctx context.Context, tenantID string, f
 		var l UsageLog
 		err := rows.Scan(
 			&l.ID, &l.TenantID, &l.RequestID, &l.Provider, &l.Model,
-			&l.InputTokens, &l.OutputTokens, &l.CostUSD, &l.LatencyMs, &l.CreatedAt,
+			&l.InputTokens, &l.OutputTokens, &l.CostUSD, &l.LatencyMs, &l.Cached, &l.RetrievedDocIDs, &l.FinishReason, &l.Stage, &l.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan usage log: %w", err)
@@ -74,17 +79,328 @@ func (s *PostgresStore) GetUsageByTenant(ctx context.Context, tenantID string, f
 	return logs, nil
 }
 
-func (s *PostgresStore) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to time.Time) (float64, error) {
+func (s *PostgresStore) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error) {
 	query := `
 		SELECT COALESCE(SUM(cost_usd), 0)
-		FROM usage_logs
+		FROM usage_logs_as_of($4)
 		WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3
 	`
 	var total float64
-	err := s.db.QueryRow(ctx, query, tenantID, from, to).Scan(&total)
+	err := s.db.QueryRow(ctx, query, tenantID, from, to, asOfArg(asOf)).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get total cost: %w", err)
 	}
 
 	return total, nil
 }
+
+func (s *PostgresStore) GetUsagePage(ctx context.Context, tenantID string, from, to, asOf time.Time, after string, limit int) ([]*UsageLog, error) {
+	// Keyset pagination on (created_at, id): pages stay stable while new
+	// usage is logged, and deep pages cost no more than the first.
+	query := `
+		SELECT id, tenant_id, COALESCE(api_key_id::text, ''), request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, cached, retrieved_doc_ids, finish_reason, stage, created_at
+		FROM usage_logs_as_of($4)
+		WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3
+			AND ($5::uuid IS NULL OR (created_at, id) < (SELECT created_at, id FROM usage_logs WHERE id = $5::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $6
+	`
+	var cursor any
+	if after != "" {
+		cursor = after
+	}
+	rows, err := s.db.Query(ctx, query, tenantID, from, to, asOfArg(asOf), cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*UsageLog
+	for rows.Next() {
+		var l UsageLog
+		err := rows.Scan(
+			&l.ID, &l.TenantID, &l.APIKeyID, &l.RequestID, &l.Provider, &l.Model,
+			&l.InputTokens, &l.OutputTokens, &l.CostUSD, &l.LatencyMs, &l.Cached, &l.RetrievedDocIDs, &l.FinishReason, &l.Stage, &l.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// GetRequests returns up to limit requests logged in [from, to) for
+// tenantID (empty: every tenant), newest first, leaving out the extra calls
+// made by gateway stages. Policy dry runs replay them.
+func (s *PostgresStore) GetRequests(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]*UsageLog, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(api_key_id::text, ''), request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, cached, retrieved_doc_ids, finish_reason, stage, created_at
+		FROM usage_logs
+		WHERE ($1::uuid IS NULL OR tenant_id = $1::uuid) AND created_at >= $2 AND created_at < $3 AND stage = ''
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+	`
+	var tenant any
+	if tenantID != "" {
+		tenant = tenantID
+	}
+	rows, err := s.db.Query(ctx, query, tenant, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*UsageLog
+	for rows.Next() {
+		var l UsageLog
+		err := rows.Scan(
+			&l.ID, &l.TenantID, &l.APIKeyID, &l.RequestID, &l.Provider, &l.Model,
+			&l.InputTokens, &l.OutputTokens, &l.CostUSD, &l.LatencyMs, &l.Cached, &l.RetrievedDocIDs, &l.FinishReason, &l.Stage, &l.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (s *PostgresStore) GetUsageByRequests(ctx context.Context, tenantID string, requestIDs []string) ([]*UsageLog, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(api_key_id::text, ''), request_id, provider, model, input_tokens, output_tokens, cost_usd, latency_ms, cached, retrieved_doc_ids, finish_reason, stage, created_at
+		FROM usage_logs
+		WHERE tenant_id = $1 AND request_id = ANY($2)
+		ORDER BY created_at, id
+	`
+	rows, err := s.db.Query(ctx, query, tenantID, requestIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*UsageLog
+	for rows.Next() {
+		var l UsageLog
+		err := rows.Scan(
+			&l.ID, &l.TenantID, &l.APIKeyID, &l.RequestID, &l.Provider, &l.Model,
+			&l.InputTokens, &l.OutputTokens, &l.CostUSD, &l.LatencyMs, &l.Cached, &l.RetrievedDocIDs, &l.FinishReason, &l.Stage, &l.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (s *PostgresStore) GetUsageSummary(ctx context.Context, tenantID string, from, to, asOf time.Time, groupBy []string) ([]UsageSummary, error) {
+	// Dimensions not grouped by are selected as constants so every row
+	// scans the same way.
+	day, model, provider := "NULL::timestamp", "''", "''"
+	var groups []string
+	for _, g := range groupBy {
+		switch g {
+		case GroupByDay:
+			day = "date_trunc('day', created_at AT TIME ZONE 'UTC')"
+			groups = append(groups, "1")
+		case GroupByModel:
+			model = "model"
+			groups = append(groups, "2")
+		case GroupByProvider:
+			provider = "provider"
+			groups = append(groups, "3")
+		default:
+			return nil, fmt.Errorf("unknown usage grouping %q", g)
+		}
+	}
+	groupClause := ""
+	if len(groups) > 0 {
+		groupClause = "GROUP BY " + strings.Join(groups, ", ")
+	}
+	query := fmt.Sprintf(`
+		SELECT %s, %s, %s, COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM usage_logs_as_of($4)
+		WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3
+		%s
+		ORDER BY 1, 2, 3
+	`, day, model, provider, groupClause)
+	rows, err := s.db.Query(ctx, query, tenantID, from, to, asOfArg(asOf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage summary: %w", err)
+	}
+	defer rows.Close()
+
+	var summary []UsageSummary
+	for rows.Next() {
+		var u UsageSummary
+		var day *time.Time
+		if err := rows.Scan(&day, &u.Model, &u.Provider, &u.Requests, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan usage summary: %w", err)
+		}
+		if day != nil {
+			u.Day = day.UTC()
+		}
+		summary = append(summary, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (s *PostgresStore) GetDailyCostByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]DailyCost, error) {
+	query := `
+		SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, SUM(cost_usd)
+		FROM usage_logs
+		WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3
+		GROUP BY day
+		ORDER BY day
+	`
+	rows, err := s.db.Query(ctx, query, tenantID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily cost: %w", err)
+	}
+	defer rows.Close()
+
+	var days []DailyCost
+	for rows.Next() {
+		var d DailyCost
+		if err := rows.Scan(&d.Day, &d.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan daily cost: %w", err)
+		}
+		d.Day = d.Day.UTC()
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily cost: %w", err)
+	}
+
+	return days, nil
+}
+
+func (s *PostgresStore) GetUsageByKey(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]KeyUsage, error) {
+	query := `
+		SELECT api_key_id, COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM usage_logs_as_of($4)
+		WHERE tenant_id = $1 AND api_key_id IS NOT NULL AND created_at BETWEEN $2 AND $3
+		GROUP BY api_key_id
+	`
+	rows, err := s.db.Query(ctx, query, tenantID, from, to, asOfArg(asOf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage by key: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []KeyUsage
+	for rows.Next() {
+		var u KeyUsage
+		if err := rows.Scan(&u.APIKeyID, &u.Requests, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan key usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating key usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (s *PostgresStore) GetDailyUsageByModel(ctx context.Context, provider string, from, to time.Time) ([]ModelDayUsage, error) {
+	// Cache hits and coalesced requests never reached the provider. Shadow
+	// and synthetic traffic did, though neither is billed to tenants.
+	query := `
+		SELECT day, model, SUM(input_tokens), SUM(output_tokens), SUM(cost_usd)
+		FROM (
+			SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, model,
+				input_tokens, output_tokens, cost_usd
+			FROM usage_logs
+			WHERE provider = $1 AND NOT cached AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT date_trunc('day', created_at AT TIME ZONE 'UTC'), model,
+				input_tokens, output_tokens, cost_usd
+			FROM synthetic_usage_logs
+			WHERE provider = $1 AND NOT cached AND created_at >= $2 AND created_at < $3
+			UNION ALL
+			SELECT date_trunc('day', created_at AT TIME ZONE 'UTC'), shadow_model,
+				shadow_input_tokens, shadow_output_tokens, shadow_cost_usd
+			FROM shadow_comparisons
+			WHERE shadow_provider = $1 AND created_at >= $2 AND created_at < $3
+		) upstream
+		GROUP BY day, model
+		ORDER BY day, model
+	`
+	rows, err := s.db.Query(ctx, query, provider, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily usage by model: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ModelDayUsage
+	for rows.Next() {
+		u := ModelDayUsage{Provider: provider}
+		if err := rows.Scan(&u.Day, &u.Model, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+		u.Day = u.Day.UTC()
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+func (s *PostgresStore) GetDailyUsageByTenants(ctx context.Context, from, to time.Time) ([]TenantDayUsage, error) {
+	query := `
+		SELECT tenant_id, date_trunc('day', created_at AT TIME ZONE 'UTC') AS day,
+			COUNT(DISTINCT request_id), COALESCE(SUM(input_tokens + output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM usage_logs
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY tenant_id, day
+		ORDER BY tenant_id, day
+	`
+	rows, err := s.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily usage by tenant: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []TenantDayUsage
+	for rows.Next() {
+		var u TenantDayUsage
+		if err := rows.Scan(&u.TenantID, &u.Day, &u.Requests, &u.Tokens, &u.CostUSD); err != nil {
+			return nil, fmt.Errorf("failed to scan daily tenant usage: %w", err)
+		}
+		u.Day = u.Day.UTC()
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily tenant usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// asOfArg passes a zero asOf to usage_logs_as_of as NULL (now).
+func asOfArg(asOf time.Time) any {
+	if asOf.IsZero() {
+		return nil
+	}
+	return asOf
+}
@@ -0,0 +1,126 @@
+package billing
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultReconcileThreshold flags days where invoice and gateway differ by
+// more than 2%.
+const DefaultReconcileThreshold = 0.02
+
+// minFlaggedCostUSD keeps rounding on near-idle days from being flagged.
+const minFlaggedCostUSD = 0.01
+
+// ModelDayUsage is one provider model's usage on one UTC day, as recorded by
+// the gateway or as billed on the provider's invoice.
+type ModelDayUsage struct {
+	Day          time.Time `json:"day"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+}
+
+// ReconciledDay compares one day and model between invoice and gateway.
+type ReconciledDay struct {
+	Day                  time.Time `json:"day"`
+	Model                string    `json:"model"`
+	InvoiceInputTokens   int64     `json:"invoice_input_tokens"`
+	RecordedInputTokens  int64     `json:"recorded_input_tokens"`
+	InvoiceOutputTokens  int64     `json:"invoice_output_tokens"`
+	RecordedOutputTokens int64     `json:"recorded_output_tokens"`
+	InvoiceCostUSD       float64   `json:"invoice_cost_usd"`
+	RecordedCostUSD      float64   `json:"recorded_cost_usd"`
+	// CostDiffUSD is invoice minus gateway: positive when the provider
+	// billed more than the gateway recorded.
+	CostDiffUSD float64 `json:"cost_diff_usd"`
+	Flagged     bool    `json:"flagged"`
+}
+
+// Reconciliation is the result of matching a provider's invoice against
+// gateway-recorded usage.
+type Reconciliation struct {
+	Provider        string          `json:"provider"`
+	From            time.Time       `json:"from"`
+	To              time.Time       `json:"to"`
+	Threshold       float64         `json:"threshold"`
+	InvoiceCostUSD  float64         `json:"invoice_cost_usd"`
+	RecordedCostUSD float64         `json:"recorded_cost_usd"`
+	Flagged         int             `json:"flagged"`
+	Days            []ReconciledDay `json:"days"`
+}
+
+// InvoicePeriod returns the UTC days invoice covers as [from, to).
+func InvoicePeriod(invoice []ModelDayUsage) (from, to time.Time) {
+	for i, u := range invoice {
+		if i == 0 || u.Day.Before(from) {
+			from = u.Day
+		}
+		if i == 0 || u.Day.After(to) {
+			to = u.Day
+		}
+	}
+	return from, to.AddDate(0, 0, 1)
+}
+
+// Reconcile matches invoice and recorded usage by day and model. A row is
+// flagged when cost differs by more than threshold (a fraction of the larger
+// side) and by at least a cent, or either token count differs by more than
+// threshold.
+func Reconcile(provider string, invoice, recorded []ModelDayUsage, threshold float64) *Reconciliation {
+	type key struct {
+		day   time.Time
+		model string
+	}
+	rows := make(map[key]*ReconciledDay)
+	row := func(u ModelDayUsage) *ReconciledDay {
+		k := key{u.Day.UTC(), u.Model}
+		if rows[k] == nil {
+			rows[k] = &ReconciledDay{Day: k.day, Model: k.model}
+		}
+		return rows[k]
+	}
+	for _, u := range invoice {
+		r := row(u)
+		r.InvoiceInputTokens += u.InputTokens
+		r.InvoiceOutputTokens += u.OutputTokens
+		r.InvoiceCostUSD += u.CostUSD
+	}
+	for _, u := range recorded {
+		r := row(u)
+		r.RecordedInputTokens += u.InputTokens
+		r.RecordedOutputTokens += u.OutputTokens
+		r.RecordedCostUSD += u.CostUSD
+	}
+
+	from, to := InvoicePeriod(invoice)
+	rec := &Reconciliation{Provider: provider, From: from, To: to, Threshold: threshold, Days: make([]ReconciledDay, 0, len(rows))}
+	for _, r := range rows {
+		r.CostDiffUSD = r.InvoiceCostUSD - r.RecordedCostUSD
+		r.Flagged = (exceeds(r.InvoiceCostUSD, r.RecordedCostUSD, threshold) && math.Abs(r.CostDiffUSD) >= minFlaggedCostUSD) ||
+			exceeds(float64(r.InvoiceInputTokens), float64(r.RecordedInputTokens), threshold) ||
+			exceeds(float64(r.InvoiceOutputTokens), float64(r.RecordedOutputTokens), threshold)
+		if r.Flagged {
+			rec.Flagged++
+		}
+		rec.InvoiceCostUSD += r.InvoiceCostUSD
+		rec.RecordedCostUSD += r.RecordedCostUSD
+		rec.Days = append(rec.Days, *r)
+	}
+	sort.Slice(rec.Days, func(i, j int) bool {
+		if !rec.Days[i].Day.Equal(rec.Days[j].Day) {
+			return rec.Days[i].Day.Before(rec.Days[j].Day)
+		}
+		return rec.Days[i].Model < rec.Days[j].Model
+	})
+	return rec
+}
+
+// exceeds reports whether a and b differ by more than threshold of the larger.
+func exceeds(a, b, threshold float64) bool {
+	larger := math.Max(math.Abs(a), math.Abs(b))
+	return larger > 0 && math.Abs(a-b)/larger > threshold
+}
@@ -0,0 +1,93 @@
+package billing
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func TestParseInvoice_OpenAI(t *testing.T) {
+	csv := "date,project_id,model,n_context_tokens_total,n_generated_tokens_total,cost\n" +
+		"2025-03-01,proj_a,gpt-4o-2024-08-06,1000,200,$0.0045\n" +
+		"2025-03-01,proj_b,gpt-4o-2024-08-06,1000,300,0.0055\n" +
+		"2025-03-02T00:00:00Z,proj_a,gpt-4o-mini,\"1,000\",100,0.0002\n" +
+		",,,,,0.0102\n"
+	usage, err := ParseInvoice(InvoiceOpenAI, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParseInvoice: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("Expected projects summed per day and model, got %+v", usage)
+	}
+	u := usage[0]
+	if !u.Day.Equal(day("2025-03-01")) || u.Provider != "openai" || u.InputTokens != 2000 || u.OutputTokens != 500 || math.Abs(u.CostUSD-0.01) > 1e-9 {
+		t.Errorf("Unexpected first row %+v", u)
+	}
+	if usage[1].InputTokens != 1000 {
+		t.Errorf("Expected formatted numbers parsed, got %+v", usage[1])
+	}
+}
+
+func TestParseInvoice_Anthropic(t *testing.T) {
+	csv := "usage_date_utc,model_version,workspace,input_tokens,output_tokens,cost_usd\n" +
+		"2025-03-01,claude-3-5-sonnet-20241022,default,500,100,0.003\n"
+	usage, err := ParseInvoice(InvoiceAnthropic, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParseInvoice: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Provider != "claude" || usage[0].Model != "claude-3-5-sonnet-20241022" {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	if _, err := ParseInvoice(InvoiceAnthropic, strings.NewReader("date,model,cost\n")); err == nil {
+		t.Error("Expected an error for missing token columns")
+	}
+	if _, err := ParseInvoice(InvoiceAnthropic, strings.NewReader(csv+"yesterday,claude-3-haiku,default,1,1,0\n")); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
+
+func TestReconcile_FlagsDiscrepancies(t *testing.T) {
+	invoice := []ModelDayUsage{
+		{Day: day("2025-03-01"), Model: "gpt-4o", InputTokens: 10000, OutputTokens: 2000, CostUSD: 10.00},
+		{Day: day("2025-03-01"), Model: "gpt-4o-mini", InputTokens: 5000, OutputTokens: 500, CostUSD: 1.00},
+		{Day: day("2025-03-02"), Model: "gpt-4o", InputTokens: 100, OutputTokens: 10, CostUSD: 0.50},
+	}
+	recorded := []ModelDayUsage{
+		{Day: day("2025-03-01"), Model: "gpt-4o", InputTokens: 10000, OutputTokens: 2000, CostUSD: 9.95},
+		{Day: day("2025-03-01"), Model: "gpt-4o-mini", InputTokens: 5000, OutputTokens: 500, CostUSD: 0.80},
+		{Day: day("2025-03-03"), Model: "gpt-4o", InputTokens: 100, OutputTokens: 10, CostUSD: 0.50},
+	}
+	rec := Reconcile("openai", invoice, recorded, DefaultReconcileThreshold)
+
+	if len(rec.Days) != 4 {
+		t.Fatalf("Expected 4 day/model rows, got %+v", rec.Days)
+	}
+	flagged := map[string]bool{}
+	for _, d := range rec.Days {
+		flagged[d.Day.Format(time.DateOnly)+" "+d.Model] = d.Flagged
+	}
+	want := map[string]bool{
+		"2025-03-01 gpt-4o":      false, // 0.5% off
+		"2025-03-01 gpt-4o-mini": true,  // 20% off
+		"2025-03-02 gpt-4o":      true,  // invoiced, not recorded
+		"2025-03-03 gpt-4o":      true,  // recorded, not invoiced
+	}
+	for k, v := range want {
+		if flagged[k] != v {
+			t.Errorf("%s: expected flagged=%v", k, v)
+		}
+	}
+	if rec.Flagged != 3 || rec.InvoiceCostUSD != 11.5 {
+		t.Errorf("Unexpected totals %+v", rec)
+	}
+	if !rec.From.Equal(day("2025-03-01")) || !rec.To.Equal(day("2025-03-03")) {
+		t.Errorf("Expected the invoice period, got %v - %v", rec.From, rec.To)
+	}
+}
@@ -0,0 +1,90 @@
+package billing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Recorder writes usage logs off the request path with bounded concurrency.
+// Each write gets its own timeout on a context detached from the request's
+// cancellation (so trace and request values still propagate). When all slots
+// are busy the caller writes inline, pushing back instead of queueing
+// unbounded goroutines behind a slow database.
+type Recorder struct {
+	store   Store
+	spend   SpendCounter
+	timeout time.Duration
+	slots   chan struct{}
+	wg      sync.WaitGroup
+}
+
+type RecorderOption func(*Recorder)
+
+// WithSpendCounter adds each usage's cost to the tenant's running spend.
+func WithSpendCounter(c SpendCounter) RecorderOption {
+	return func(r *Recorder) {
+		r.spend = c
+	}
+}
+
+func NewRecorder(store Store, maxInFlight int, timeout time.Duration, opts ...RecorderOption) *Recorder {
+	if maxInFlight <= 0 {
+		maxInFlight = 256
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	r := &Recorder{store: store, timeout: timeout, slots: make(chan struct{}, maxInFlight)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Record persists usage asynchronously when a slot is free, otherwise inline.
+func (r *Recorder) Record(ctx context.Context, usage *UsageLog) {
+	ctx = context.WithoutCancel(ctx)
+	select {
+	case r.slots <- struct{}{}:
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-r.slots }()
+			r.write(ctx, usage)
+		}()
+	default:
+		r.write(ctx, usage)
+	}
+}
+
+func (r *Recorder) write(ctx context.Context, usage *UsageLog) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	if err := r.store.LogUsage(ctx, usage); err != nil {
+		log.Printf("billing: failed to log usage for request %s: %v", usage.RequestID, err)
+	}
+	// The spend happened whether or not the log row was written. Synthetic
+	// traffic isn't the tenant's to pay for.
+	if r.spend != nil && usage.CostUSD > 0 && !usage.Synthetic {
+		if err := r.spend.Add(ctx, usage.TenantID, usage.CostUSD, time.Now()); err != nil {
+			log.Printf("billing: failed to update spend for tenant %s: %v", usage.TenantID, err)
+		}
+	}
+}
+
+// Flush waits for in-flight writes, giving up when ctx is done.
+func (r *Recorder) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type slowStore struct {
+	Store
+	release  chan struct{}
+	written  atomic.Int32
+	deadline atomic.Bool
+}
+
+func (s *slowStore) LogUsage(ctx context.Context, log *UsageLog) error {
+	if _, ok := ctx.Deadline(); ok {
+		s.deadline.Store(true)
+	}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.written.Add(1)
+	return nil
+}
+
+func TestRecorder_BoundsInFlightWrites(t *testing.T) {
+	store := &slowStore{release: make(chan struct{})}
+	r := NewRecorder(store, 1, 50*time.Millisecond)
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	r.Record(reqCtx, &UsageLog{RequestID: "a"})
+	cancel() // request finishing must not cancel the write
+
+	// Second write finds no free slot and runs inline until it times out.
+	start := time.Now()
+	r.Record(context.Background(), &UsageLog{RequestID: "b"})
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("Expected inline write when no slot is free")
+	}
+
+	close(store.release)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if !store.deadline.Load() {
+		t.Error("Expected writes to carry a deadline")
+	}
+}
+
+func TestRecorder_FlushHonoursContext(t *testing.T) {
+	store := &slowStore{release: make(chan struct{})}
+	r := NewRecorder(store, 4, time.Second)
+	r.Record(context.Background(), &UsageLog{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Flush(ctx); err == nil {
+		t.Error("Expected Flush to give up when its context expires")
+	}
+	close(store.release)
+	_ = r.Flush(context.Background())
+}
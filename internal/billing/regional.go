@@ -0,0 +1,230 @@
+package billing
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// seedField holds, in a shared window total, spend from usage_logs that no
+// region's counter had seen when the total was first read.
+const seedField = "seed"
+
+// RegionalSpendCounter counts spend in the region's own Redis and reconciles
+// it with the other regions through a shared Redis, as ratelimit.Reconciler
+// does for rate limits. Every interval each window's regional additions are
+// flushed to a hash in the shared Redis with a field per region, and the
+// other regions' fields are read back; Get adds them to the local count.
+//
+// The shared Redis is never on the request path. Budgets can be overspent by
+// what the other regions spend during one interval, and for one interval
+// after a restart, before the other regions' spend is first read.
+type RegionalSpendCounter struct {
+	local    SpendCounter
+	global   *redis.Client
+	region   string
+	seed     CostSource
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]float64     // shared window key -> cost not yet flushed
+	windows map[string]spendWindow // shared window key -> window, for windows added to or read
+	seen    map[string]regionalSpend
+}
+
+// regionalSpend is a shared window total as last read.
+type regionalSpend struct {
+	own    float64 // this region's field
+	others float64 // every other field, seed included
+	at     time.Time
+}
+
+// NewRegionalSpendCounter counts in local and reconciles through global.
+// With a non-nil seed, a window total no region has reported to yet is
+// seeded from usage_logs.
+func NewRegionalSpendCounter(local SpendCounter, global *redis.Client, region string, seed CostSource, interval time.Duration) *RegionalSpendCounter {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &RegionalSpendCounter{
+		local:    local,
+		global:   global,
+		region:   region,
+		seed:     seed,
+		interval: interval,
+		now:      time.Now,
+		pending:  make(map[string]float64),
+		windows:  make(map[string]spendWindow),
+		seen:     make(map[string]regionalSpend),
+	}
+}
+
+// globalWindows are the tenant's windows keyed in the shared Redis.
+func globalWindows(tenantID string, at time.Time) (day, month spendWindow) {
+	day, month = spendWindows(tenantID, at)
+	day.key = "budget:global:" + strings.TrimPrefix(day.key, "budget:")
+	month.key = "budget:global:" + strings.TrimPrefix(month.key, "budget:")
+	return day, month
+}
+
+func (c *RegionalSpendCounter) Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error {
+	day, month := globalWindows(tenantID, at)
+	c.mu.Lock()
+	for _, w := range []spendWindow{day, month} {
+		c.pending[w.key] += costUSD
+		c.windows[w.key] = w
+	}
+	c.mu.Unlock()
+	return c.local.Add(ctx, tenantID, costUSD, at)
+}
+
+// Get is the regional count plus the other regions' spend as last read. If
+// the regional count fell behind what this region reported, say after its
+// Redis was flushed, the reported figure is used instead.
+func (c *RegionalSpendCounter) Get(ctx context.Context, tenantID string, at time.Time) (Spend, error) {
+	s, err := c.local.Get(ctx, tenantID, at)
+	if err != nil {
+		return Spend{}, err
+	}
+	day, month := globalWindows(tenantID, at)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.DayUSD = c.withOthers(day, s.DayUSD)
+	s.MonthUSD = c.withOthers(month, s.MonthUSD)
+	return s, nil
+}
+
+// withOthers adds the other regions' spend in w to local and has w read at
+// the next flush. The caller holds c.mu.
+func (c *RegionalSpendCounter) withOthers(w spendWindow, local float64) float64 {
+	c.windows[w.key] = w
+	seen, ok := c.seen[w.key]
+	if !ok {
+		return local
+	}
+	return max(local, seen.own+c.pending[w.key]) + seen.others
+}
+
+// Run flushes on every interval until ctx is cancelled, then flushes once more.
+func (c *RegionalSpendCounter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), c.interval)
+			c.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			c.flush(ctx)
+		}
+	}
+}
+
+func (c *RegionalSpendCounter) flush(ctx context.Context) {
+	pending, windows := c.take()
+	now := c.now()
+
+	for key, w := range windows {
+		cost := pending[key]
+		pipe := c.global.TxPipeline()
+		if cost != 0 {
+			pipe.HIncrByFloat(ctx, key, c.region, cost)
+			pipe.Expire(ctx, key, w.ttl)
+		}
+		fields := pipe.HGetAll(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			slog.Warn("billing: reconcile spend failed", "tenant_id", w.tenantID, "err", err)
+			c.requeue(w, cost)
+			continue
+		}
+		totals := fields.Val()
+		if _, ok := totals[seedField]; !ok && c.seed != nil {
+			seed, err := c.seedWindow(ctx, w, totals, now)
+			if err != nil {
+				slog.Warn("billing: seed spend failed", "tenant_id", w.tenantID, "err", err)
+			} else {
+				totals[seedField] = strconv.FormatFloat(seed, 'f', -1, 64)
+			}
+		}
+		c.observe(key, totals, now)
+	}
+	c.prune(now)
+}
+
+// seedWindow records, once per shared window total, the logged spend in w
+// that no region has reported, and returns the seed in place.
+func (c *RegionalSpendCounter) seedWindow(ctx context.Context, w spendWindow, totals map[string]string, now time.Time) (float64, error) {
+	logged, err := c.seed.GetTotalCostByTenant(ctx, w.tenantID, w.from, now, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	var reported float64
+	for _, v := range totals {
+		f, _ := strconv.ParseFloat(v, 64)
+		reported += f
+	}
+	pipe := c.global.TxPipeline()
+	pipe.HSetNX(ctx, w.key, seedField, max(logged-reported, 0))
+	pipe.Expire(ctx, w.key, w.ttl)
+	seed := pipe.HGet(ctx, w.key, seedField)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return seed.Float64()
+}
+
+func (c *RegionalSpendCounter) take() (map[string]float64, map[string]spendWindow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending, windows := c.pending, c.windows
+	c.pending = make(map[string]float64)
+	c.windows = make(map[string]spendWindow)
+	return pending, windows
+}
+
+// requeue returns an unflushed addition to the next flush.
+func (c *RegionalSpendCounter) requeue(w spendWindow, cost float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[w.key] += cost
+	c.windows[w.key] = w
+}
+
+// observe records a shared window total as read at now.
+func (c *RegionalSpendCounter) observe(key string, totals map[string]string, now time.Time) {
+	seen := regionalSpend{at: now}
+	for region, v := range totals {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			slog.Warn("billing: unexpected spend total", "value", v, "key", key)
+			continue
+		}
+		if region == c.region {
+			seen.own = f
+		} else {
+			seen.others += f
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[key] = seen
+}
+
+// prune forgets totals no request has read or added to for two days.
+func (c *RegionalSpendCounter) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, seen := range c.seen {
+		if now.Sub(seen.at) > 48*time.Hour {
+			delete(c.seen, key)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package billing
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCounter is a SpendCounter for one region's Redis.
+type memoryCounter struct {
+	mu    sync.Mutex
+	spend map[string]float64
+}
+
+func (m *memoryCounter) Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spend[tenantID] += costUSD
+	return nil
+}
+
+func (m *memoryCounter) Get(ctx context.Context, tenantID string, at time.Time) (Spend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Spend{DayUSD: m.spend[tenantID], MonthUSD: m.spend[tenantID]}, nil
+}
+
+// reconcile does what flush does against the shared Redis, with shared as
+// the window totals by region.
+func reconcile(shared map[string]map[string]float64, counters ...*RegionalSpendCounter) {
+	now := time.Now()
+	for _, c := range counters {
+		pending, _ := c.take()
+		for key, cost := range pending {
+			if shared[key] == nil {
+				shared[key] = map[string]float64{}
+			}
+			shared[key][c.region] += cost
+		}
+	}
+	for _, c := range counters {
+		for key, byRegion := range shared {
+			totals := map[string]string{}
+			for region, v := range byRegion {
+				totals[region] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			c.observe(key, totals, now)
+		}
+	}
+}
+
+func TestRegionalSpendCounter_CountsOtherRegions(t *testing.T) {
+	ctx := context.Background()
+	at := time.Now()
+	euLocal := &memoryCounter{spend: map[string]float64{}}
+	eu := NewRegionalSpendCounter(euLocal, nil, "eu", nil, time.Second)
+	us := NewRegionalSpendCounter(&memoryCounter{spend: map[string]float64{}}, nil, "us", nil, time.Second)
+
+	_ = eu.Add(ctx, "t1", 6, at)
+	_ = us.Add(ctx, "t1", 5, at)
+	if s, _ := eu.Get(ctx, "t1", at); s.DayUSD != 6 {
+		t.Errorf("Expected only eu's spend before reconciling, got %+v", s)
+	}
+
+	shared := map[string]map[string]float64{}
+	reconcile(shared, eu, us)
+	for _, c := range []*RegionalSpendCounter{eu, us} {
+		if s, _ := c.Get(ctx, "t1", at); s.DayUSD != 11 || s.MonthUSD != 11 {
+			t.Errorf("%s: expected both regions' spend, got %+v", c.region, s)
+		}
+	}
+	if s := (Budget{DailyUSD: 10}).Exceeded(mustGet(t, us, "t1", at)); s != "daily" {
+		t.Errorf("Expected the combined spend over a budget neither region reached alone, got %q", s)
+	}
+
+	// eu's Redis loses its counters; what eu reported still counts.
+	euLocal.spend = map[string]float64{}
+	if s, _ := eu.Get(ctx, "t1", at); s.DayUSD != 11 {
+		t.Errorf("Expected eu's reported spend after its counters were lost, got %+v", s)
+	}
+}
+
+func mustGet(t *testing.T, c SpendCounter, tenantID string, at time.Time) Spend {
+	t.Helper()
+	s, err := c.Get(context.Background(), tenantID, at)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Cache stores complete responses for exact-match prompts.
+type Cache interface {
+	Get(ctx context.Context, key string) (*provider.Response, bool, error)
+	// Set stores resp for ttl, or the cache's default TTL when ttl is 0.
+	Set(ctx context.Context, key string, resp *provider.Response, ttl time.Duration) error
+}
+
+// Key hashes everything that shapes a completion. The tenant is part of the
+// key so cached answers never cross tenants.
+func Key(tenantID string, req *provider.Request) string {
+	h := sha256.New()
+	_ = json.NewEncoder(h).Encode(struct {
+		TenantID        string
+		Model           string
+		Messages        []provider.Message
+		Temperature     float64
+		MaxTokens       int
+		Tools           []provider.Tool
+		ToolChoice      json.RawMessage
+		ResponseFormat  *provider.ResponseFormat
+		ExtraBody       map[string]json.RawMessage
+		ProviderOptions map[string]map[string]json.RawMessage
+		// Provider keeps pinned requests' answers apart; omitted otherwise,
+		// so unpinned keys are unchanged.
+		Provider string `json:",omitempty"`
+	}{tenantID, req.Model, req.Messages, req.Temperature, req.MaxTokens, req.Tools, req.ToolChoice, req.ResponseFormat,
+		req.ExtraBody, req.ProviderOptions, req.Provider})
+	return "cache:response:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Directives are the client's Cache-Control opt-outs: "no-cache" skips the
+// lookup, "no-store" keeps the response out of the cache.
+type Directives struct {
+	NoCache bool
+	NoStore bool
+}
+
+func ParseDirectives(h http.Header) Directives {
+	var d Directives
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(part)) {
+			case "no-cache":
+				d.NoCache = true
+			case "no-store":
+				d.NoStore = true
+			}
+		}
+	}
+	return d
+}
+
+// ParseOptions reads per-call cache options from the X-Cache-Mode and
+// X-Cache-TTL (seconds) headers, for clients that can't add body fields. It
+// returns nil when neither is set.
+func ParseOptions(h http.Header) (*provider.CacheOptions, error) {
+	mode, ttl := h.Get("X-Cache-Mode"), h.Get("X-Cache-TTL")
+	if mode == "" && ttl == "" {
+		return nil, nil
+	}
+	opts := &provider.CacheOptions{Mode: strings.ToLower(strings.TrimSpace(mode))}
+	if ttl != "" {
+		seconds, err := strconv.Atoi(strings.TrimSpace(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid X-Cache-TTL: %q", ttl)
+		}
+		opts.TTL = seconds
+	}
+	return opts, nil
+}
+
+type RedisCache struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewRedisCache(rdb *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{rdb: rdb, ttl: ttl}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (*provider.Response, bool, error) {
+	raw, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read response cache: %w", err)
+	}
+	var resp provider.Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, false, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return &resp, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, resp *provider.Response, ttl time.Duration) error {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode response for cache: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if err := c.rdb.Set(ctx, key, raw, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write response cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestKey_ScopedByTenantAndPrompt(t *testing.T) {
+	req := &provider.Request{Model: "gpt-4o", Messages: []provider.Message{{Role: "user", Content: "hi"}}}
+	same := &provider.Request{Model: "gpt-4o", Messages: []provider.Message{{Role: "user", Content: "hi"}}, TenantID: "ignored"}
+	warmer := &provider.Request{Model: "gpt-4o", Messages: req.Messages, Temperature: 0.7}
+
+	if Key("t1", req) != Key("t1", same) {
+		t.Error("Expected identical prompts to share a key")
+	}
+	if Key("t1", req) == Key("t2", req) {
+		t.Error("Expected keys to differ across tenants")
+	}
+	if Key("t1", req) == Key("t1", warmer) {
+		t.Error("Expected temperature to be part of the key")
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cache-Control", "No-Cache, max-age=0")
+	if d := ParseDirectives(h); !d.NoCache || d.NoStore {
+		t.Errorf("Unexpected directives: %+v", d)
+	}
+}
@@ -0,0 +1,152 @@
+// Package catalog keeps the models each provider serves. Providers that
+// discover theirs from the upstream (provider.ModelLister) are listed in the
+// background and the lists cached in Redis, so replicas share one upstream
+// lookup per TTL and requests never wait on one.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Cache holds providers' model lists between lookups.
+type Cache interface {
+	Get(ctx context.Context, provider string) ([]string, bool, error)
+	Set(ctx context.Context, provider string, models []string) error
+}
+
+type RedisCache struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewRedisCache(rdb *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{rdb: rdb, ttl: ttl}
+}
+
+func cacheKey(provider string) string {
+	return "catalog:models:" + provider
+}
+
+func (c *RedisCache) Get(ctx context.Context, provider string) ([]string, bool, error) {
+	raw, err := c.rdb.Get(ctx, cacheKey(provider)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read model list: %w", err)
+	}
+	var models []string
+	if err := json.Unmarshal(raw, &models); err != nil {
+		return nil, false, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	return models, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, provider string, models []string) error {
+	raw, err := json.Marshal(models)
+	if err != nil {
+		return err
+	}
+	if err := c.rdb.Set(ctx, cacheKey(provider), raw, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write model list: %w", err)
+	}
+	return nil
+}
+
+// Catalog serves providers' model lists from memory. Refresh reloads the
+// lists of discovering providers from the cache, asking the upstreams whose
+// cached list has expired.
+type Catalog struct {
+	providers []provider.Provider
+	cache     Cache
+	interval  time.Duration
+
+	mu     sync.RWMutex
+	models map[string][]string // provider -> discovered models
+}
+
+func New(providers []provider.Provider, cache Cache, interval time.Duration) *Catalog {
+	return &Catalog{providers: providers, cache: cache, interval: interval, models: make(map[string][]string)}
+}
+
+// Models returns the models p serves: its discovered list once one has
+// loaded, otherwise SupportedModels. A nil Catalog discovers nothing.
+func (c *Catalog) Models(p provider.Provider) []string {
+	if c == nil {
+		return p.SupportedModels()
+	}
+	c.mu.RLock()
+	models, ok := c.models[p.Name()]
+	c.mu.RUnlock()
+	if !ok {
+		return p.SupportedModels()
+	}
+	return models
+}
+
+// Refresh reloads every discovering provider's list. A provider whose
+// lookup fails keeps its previous list; the failures are returned together.
+func (c *Catalog) Refresh(ctx context.Context) error {
+	var errs []error
+	for _, p := range c.providers {
+		lister, ok := p.(provider.ModelLister)
+		if !ok {
+			continue
+		}
+		models, err := c.lookup(ctx, p.Name(), lister)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		c.mu.Lock()
+		c.models[p.Name()] = models
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// lookup reads name's list from the cache, or from the upstream when the
+// cache has none (or can't be read), caching what it gets.
+func (c *Catalog) lookup(ctx context.Context, name string, lister provider.ModelLister) ([]string, error) {
+	models, ok, err := c.cache.Get(ctx, name)
+	if err != nil {
+		log.Printf("catalog: %s: %v", name, err)
+	}
+	if ok {
+		return models, nil
+	}
+	models, err = lister.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cache.Set(ctx, name, models); err != nil {
+		log.Printf("catalog: %s: %v", name, err)
+	}
+	return models, nil
+}
+
+// Run refreshes the lists every interval until ctx is done.
+func (c *Catalog) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Refresh(ctx); err != nil {
+				log.Printf("catalog: failed to refresh model lists: %v", err)
+			}
+		}
+	}
+}
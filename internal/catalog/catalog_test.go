@@ -0,0 +1,101 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type fakeProvider struct {
+	name   string
+	models []string
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakeProvider) Name() string                { return p.name }
+func (p *fakeProvider) CostPerInputToken() float64  { return 0 }
+func (p *fakeProvider) CostPerOutputToken() float64 { return 0 }
+func (p *fakeProvider) SupportedModels() []string   { return p.models }
+
+// listingProvider discovers its models from the upstream.
+type listingProvider struct {
+	fakeProvider
+	listed []string
+	err    error
+	calls  int
+}
+
+func (p *listingProvider) ListModels(ctx context.Context) ([]string, error) {
+	p.calls++
+	return p.listed, p.err
+}
+
+type mapCache map[string][]string
+
+func (c mapCache) Get(ctx context.Context, provider string) ([]string, bool, error) {
+	models, ok := c[provider]
+	return models, ok, nil
+}
+
+func (c mapCache) Set(ctx context.Context, provider string, models []string) error {
+	c[provider] = models
+	return nil
+}
+
+func TestCatalog_Refresh(t *testing.T) {
+	fixed := &fakeProvider{name: "openai", models: []string{"gpt-4o"}}
+	local := &listingProvider{fakeProvider: fakeProvider{name: "ollama"}, listed: []string{"llama3.1:8b"}}
+	cache := mapCache{}
+	c := New([]provider.Provider{fixed, local}, cache, time.Minute)
+
+	if models := c.Models(local); len(models) != 0 {
+		t.Errorf("Expected no models before the first refresh, got %v", models)
+	}
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if models := c.Models(local); !slices.Equal(models, []string{"llama3.1:8b"}) {
+		t.Errorf("Expected the listed models, got %v", models)
+	}
+	if !slices.Equal(cache["ollama"], []string{"llama3.1:8b"}) {
+		t.Errorf("Expected the list cached, got %v", cache)
+	}
+	if models := c.Models(fixed); !slices.Equal(models, []string{"gpt-4o"}) {
+		t.Errorf("Expected providers that don't list models to serve their own, got %v", models)
+	}
+
+	// Another replica's cached list is used without asking the upstream.
+	cache["ollama"] = []string{"llama3.1:8b", "qwen2.5:7b"}
+	_ = c.Refresh(context.Background())
+	if local.calls != 1 || len(c.Models(local)) != 2 {
+		t.Errorf("Expected the cached list and no upstream call, got %v after %d calls", c.Models(local), local.calls)
+	}
+
+	delete(cache, "ollama")
+	local.err = errors.New("connection refused")
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Error("Expected the failed lookup reported")
+	}
+	if len(c.Models(local)) != 2 {
+		t.Errorf("Expected a failed lookup to keep the previous list, got %v", c.Models(local))
+	}
+}
+
+func TestCatalog_NilServesProviderModels(t *testing.T) {
+	var c *Catalog
+	p := &fakeProvider{name: "openai", models: []string{"gpt-4o"}}
+	if models := c.Models(p); !slices.Equal(models, p.models) {
+		t.Errorf("Expected the provider's models, got %v", models)
+	}
+}
@@ -0,0 +1,121 @@
+package conversation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Error describes why a conversation was rejected, pointing at the offending message.
+type Error struct {
+	Index  int // -1 when the problem isn't tied to one message
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.Index < 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("messages[%d]: %s", e.Index, e.Reason)
+}
+
+// Options control validation for a single request.
+type Options struct {
+	MaxTurns int  // 0 disables the limit
+	Repair   bool // fix what can be fixed instead of rejecting
+}
+
+var validRoles = map[string]bool{"system": true, "user": true, "assistant": true, "tool": true}
+
+// Validate checks messages against the target provider's rules and returns
+// the (possibly repaired) messages to send upstream.
+func Validate(messages []provider.Message, rules provider.ConversationRules, opts Options) ([]provider.Message, error) {
+	if opts.MaxTurns > 0 && len(messages) > opts.MaxTurns {
+		return nil, &Error{Index: -1, Reason: fmt.Sprintf("conversation has %d messages, maximum is %d", len(messages), opts.MaxTurns)}
+	}
+
+	var system []string
+	var rest []provider.Message
+	for i, m := range messages {
+		if !validRoles[m.Role] {
+			return nil, &Error{Index: i, Reason: fmt.Sprintf("unknown role %q (want system, user, assistant or tool)", m.Role)}
+		}
+		if strings.TrimSpace(m.Content) == "" && len(m.ToolCalls) == 0 {
+			if opts.Repair {
+				continue
+			}
+			return nil, &Error{Index: i, Reason: "content must not be empty"}
+		}
+		if m.Role == "tool" && m.ToolCallID == "" {
+			return nil, &Error{Index: i, Reason: "tool messages require tool_call_id"}
+		}
+		if m.Role == "system" {
+			if len(system) > 0 && rules.SingleSystem && !opts.Repair {
+				return nil, &Error{Index: i, Reason: "only one system message is supported by this provider"}
+			}
+			if len(rest) > 0 && rules.SingleSystem && !opts.Repair {
+				return nil, &Error{Index: i, Reason: "system message must come before the conversation for this provider"}
+			}
+			system = append(system, m.Content)
+			if !rules.SingleSystem {
+				rest = append(rest, m)
+			}
+			continue
+		}
+		rest = append(rest, m)
+	}
+
+	if rules.RequireUserFirst {
+		first := firstNonSystem(rest)
+		if first < 0 {
+			return nil, &Error{Index: -1, Reason: "conversation must contain at least one user message"}
+		}
+		if rest[first].Role != "user" {
+			if !opts.Repair {
+				return nil, &Error{Index: originalIndex(messages, rest[first]), Reason: "conversation must start with a user message for this provider"}
+			}
+			rest = append(rest[:first:first], append([]provider.Message{{Role: "user", Content: "Continue."}}, rest[first:]...)...)
+		}
+	}
+
+	if rules.RequireAlternation {
+		merged := rest[:0:0]
+		for _, m := range rest {
+			n := len(merged)
+			if n > 0 && merged[n-1].Role == m.Role && m.Role != "system" && m.Role != "tool" && len(m.ToolCalls) == 0 && len(merged[n-1].ToolCalls) == 0 {
+				if !opts.Repair {
+					return nil, &Error{Index: originalIndex(messages, m), Reason: fmt.Sprintf("consecutive %s messages are not supported by this provider", m.Role)}
+				}
+				merged[n-1].Content += "\n\n" + m.Content
+				continue
+			}
+			merged = append(merged, m)
+		}
+		rest = merged
+	}
+
+	if rules.SingleSystem && len(system) > 0 {
+		rest = append([]provider.Message{{Role: "system", Content: strings.Join(system, "\n\n")}}, rest...)
+	}
+	return rest, nil
+}
+
+func firstNonSystem(messages []provider.Message) int {
+	for i, m := range messages {
+		if m.Role != "system" {
+			return i
+		}
+	}
+	return -1
+}
+
+// originalIndex maps a message back to its position in the client's request.
+func originalIndex(messages []provider.Message, m provider.Message) int {
+	for i, o := range messages {
+		if o.Role == m.Role && o.Content == m.Content {
+			return i
+		}
+	}
+	return -1
+}
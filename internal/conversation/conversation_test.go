@@ -0,0 +1,106 @@
+package conversation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+var strict = provider.ConversationRules{RequireUserFirst: true, RequireAlternation: true, SingleSystem: true}
+
+func TestValidate_EmptyContentRejected(t *testing.T) {
+	_, err := Validate([]provider.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "  "},
+	}, provider.ConversationRules{}, Options{})
+
+	var verr *Error
+	if !errors.As(err, &verr) || verr.Index != 1 {
+		t.Fatalf("Expected error at messages[1], got %v", err)
+	}
+}
+
+func TestValidate_UnknownRole(t *testing.T) {
+	_, err := Validate([]provider.Message{{Role: "bot", Content: "hi"}}, provider.ConversationRules{}, Options{})
+	if err == nil || !strings.Contains(err.Error(), `unknown role "bot"`) {
+		t.Errorf("Expected unknown role error, got %v", err)
+	}
+}
+
+func TestValidate_MaxTurns(t *testing.T) {
+	msgs := []provider.Message{{Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}, {Role: "user", Content: "c"}}
+	if _, err := Validate(msgs, provider.ConversationRules{}, Options{MaxTurns: 2}); err == nil {
+		t.Error("Expected max turns error")
+	}
+	if _, err := Validate(msgs, provider.ConversationRules{}, Options{MaxTurns: 3}); err != nil {
+		t.Errorf("Expected no error at the limit, got %v", err)
+	}
+}
+
+func TestValidate_StrictProviderRejectsAssistantFirst(t *testing.T) {
+	_, err := Validate([]provider.Message{
+		{Role: "system", Content: "be nice"},
+		{Role: "assistant", Content: "hello"},
+	}, strict, Options{})
+	if err == nil || !strings.Contains(err.Error(), "messages[1]") {
+		t.Errorf("Expected assistant-first error at messages[1], got %v", err)
+	}
+}
+
+func TestValidate_StrictProviderRejectsDuplicateSystem(t *testing.T) {
+	_, err := Validate([]provider.Message{
+		{Role: "system", Content: "a"},
+		{Role: "system", Content: "b"},
+		{Role: "user", Content: "hi"},
+	}, strict, Options{})
+	if err == nil || !strings.Contains(err.Error(), "only one system message") {
+		t.Errorf("Expected duplicate system error, got %v", err)
+	}
+}
+
+func TestValidate_Repair(t *testing.T) {
+	got, err := Validate([]provider.Message{
+		{Role: "system", Content: "a"},
+		{Role: "assistant", Content: "hello"},
+		{Role: "system", Content: "b"},
+		{Role: "user", Content: ""},
+		{Role: "user", Content: "one"},
+		{Role: "user", Content: "two"},
+	}, strict, Options{Repair: true})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	want := []provider.Message{
+		{Role: "system", Content: "a\n\nb"},
+		{Role: "user", Content: "Continue."},
+		{Role: "assistant", Content: "hello"},
+		{Role: "user", Content: "one\n\ntwo"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+			t.Errorf("messages[%d]: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestValidate_LenientProviderKeepsOrder(t *testing.T) {
+	msgs := []provider.Message{
+		{Role: "assistant", Content: "hello"},
+		{Role: "system", Content: "a"},
+		{Role: "system", Content: "b"},
+		{Role: "user", Content: "hi"},
+	}
+	got, err := Validate(msgs, provider.ConversationRules{}, Options{})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(got) != 4 || got[0].Role != "assistant" {
+		t.Errorf("Expected messages to pass through unchanged, got %+v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package conversation
+
+import (
+	"fmt"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Ways an OverflowPolicy shrinks a request that overflowed the model's
+// context window.
+const (
+	// OverflowReduceMaxTokens halves max_tokens, down to MinMaxTokens.
+	OverflowReduceMaxTokens = "reduce_max_tokens"
+	// OverflowTruncateHistory drops the oldest half of the conversation,
+	// keeping system messages and the latest user turn.
+	OverflowTruncateHistory = "truncate_history"
+)
+
+// Bounds for OverflowPolicy.
+const (
+	DefaultOverflowRetries = 2
+	MaxOverflowRetries     = 5
+	DefaultMinMaxTokens    = 256
+)
+
+// OverflowPolicy retries requests the upstream rejected for exceeding the
+// model's context window, shrinking them each time, before the error
+// reaches the client.
+type OverflowPolicy struct {
+	Strategy     string `json:"strategy"`                 // reduce_max_tokens or truncate_history
+	Retries      int    `json:"retries,omitempty"`        // default 2
+	MinMaxTokens int    `json:"min_max_tokens,omitempty"` // reduce_max_tokens floor, default 256
+}
+
+// Validate reports settings the gateway can't act on.
+func (p *OverflowPolicy) Validate() error {
+	switch p.Strategy {
+	case OverflowReduceMaxTokens, OverflowTruncateHistory:
+	default:
+		return fmt.Errorf("unknown context overflow strategy %q", p.Strategy)
+	}
+	if p.Retries < 0 || p.Retries > MaxOverflowRetries {
+		return fmt.Errorf("context overflow retries must be between 0 and %d", MaxOverflowRetries)
+	}
+	if p.MinMaxTokens < 0 {
+		return fmt.Errorf("context overflow min_max_tokens must not be negative")
+	}
+	return nil
+}
+
+// MaxRetries is how many shrunk retries follow the first attempt, capped at
+// MaxOverflowRetries for settings stored without going through Validate.
+func (p *OverflowPolicy) MaxRetries() int {
+	if p.Retries == 0 {
+		return DefaultOverflowRetries
+	}
+	return min(p.Retries, MaxOverflowRetries)
+}
+
+// Shrink returns a copy of req made smaller by the policy's strategy, with a
+// note of what changed for the log, or false when it can't shrink further.
+// Requests without max_tokens can't be reduced: the upstream's default is
+// unknown.
+func (p *OverflowPolicy) Shrink(req *provider.Request) (*provider.Request, string, bool) {
+	out := *req
+	switch p.Strategy {
+	case OverflowReduceMaxTokens:
+		floor := p.MinMaxTokens
+		if floor == 0 {
+			floor = DefaultMinMaxTokens
+		}
+		if req.MaxTokens <= floor {
+			return nil, "", false
+		}
+		out.MaxTokens = max(req.MaxTokens/2, floor)
+		return &out, fmt.Sprintf("max_tokens %d -> %d", req.MaxTokens, out.MaxTokens), true
+	case OverflowTruncateHistory:
+		messages, dropped := TruncateHistory(req.Messages)
+		if dropped == 0 {
+			return nil, "", false
+		}
+		out.Messages = messages
+		return &out, fmt.Sprintf("dropped %d of %d messages", dropped, len(req.Messages)), true
+	}
+	return nil, "", false
+}
+
+// TruncateHistory drops about the oldest half of messages, cutting just
+// before a user message so no tool result loses its call. System messages
+// are kept, and so is the last user message and what follows it. dropped is
+// 0 when there is nothing left to drop.
+func TruncateHistory(messages []provider.Message) (kept []provider.Message, dropped int) {
+	var system, rest []provider.Message
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+
+	// Cut at the first user message past the midpoint, else the last one.
+	cut := -1
+	for i := 1; i < len(rest); i++ {
+		if rest[i].Role != "user" {
+			continue
+		}
+		cut = i
+		if i >= len(rest)/2 {
+			break
+		}
+	}
+	if cut < 0 {
+		return messages, 0
+	}
+	return append(system, rest[cut:]...), cut
+}
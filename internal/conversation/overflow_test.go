@@ -0,0 +1,56 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestTruncateHistory_CutsBeforeAUserTurn(t *testing.T) {
+	messages := []provider.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call-1"}}},
+		{Role: "tool", ToolCallID: "call-1", Content: "result"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "a2"},
+		{Role: "user", Content: "q3"},
+	}
+
+	kept, dropped := TruncateHistory(messages)
+	if dropped != 4 {
+		t.Fatalf("expected the first exchange and its tool call dropped, got %d dropped: %+v", dropped, kept)
+	}
+	if len(kept) != 4 || kept[0].Role != "system" || kept[1].Content != "q2" {
+		t.Errorf("expected the system message and the later turns kept, got %+v", kept)
+	}
+}
+
+func TestTruncateHistory_KeepsTheLastUserTurn(t *testing.T) {
+	messages := []provider.Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "q1"}}
+	if kept, dropped := TruncateHistory(messages); dropped != 0 || len(kept) != 2 {
+		t.Errorf("expected nothing dropped from a single turn, got %d dropped: %+v", dropped, kept)
+	}
+}
+
+func TestOverflowPolicy_ShrinksMaxTokensToTheFloor(t *testing.T) {
+	p := &OverflowPolicy{Strategy: OverflowReduceMaxTokens, MinMaxTokens: 300}
+	req := &provider.Request{MaxTokens: 1000}
+
+	var got []int
+	for {
+		shrunk, _, ok := p.Shrink(req)
+		if !ok {
+			break
+		}
+		got = append(got, shrunk.MaxTokens)
+		req = shrunk
+	}
+	if len(got) != 2 || got[0] != 500 || got[1] != 300 {
+		t.Errorf("expected max_tokens 500 then 300, got %v", got)
+	}
+	if _, _, ok := p.Shrink(&provider.Request{}); ok {
+		t.Error("expected a request without max_tokens left alone")
+	}
+}
@@ -0,0 +1,415 @@
+// Package dryrun replays recorded traffic against a proposed policy change
+// and reports the requests the change would have routed or blocked
+// differently, before an operator applies it.
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// MaxRequests is the most recorded requests one replay reads; older ones
+// in the window are left out and the report marked truncated.
+const MaxRequests = 10000
+
+// maxDifferences is how many changed requests a report lists.
+const maxDifferences = 50
+
+// What a replayed request would have got.
+const (
+	OutcomeServed      = "served"
+	OutcomeBlocked     = "blocked"
+	OutcomeSubstituted = "substituted" // over its cost cap, served by a cheaper model
+)
+
+// Traffic is the recorded traffic a replay reads.
+type Traffic interface {
+	// GetRequests returns up to limit requests logged in [from, to) for
+	// tenantID (empty: every tenant), newest first: each request's own
+	// upstream call, without calls made by gateway stages.
+	GetRequests(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]*billing.UsageLog, error)
+	// GetTotalCostByTenant sums the tenant's spend logged in [from, to].
+	GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error)
+}
+
+// Router is where replayed requests are routed.
+type Router interface {
+	Route(ctx context.Context, req *provider.Request) (provider.Provider, error)
+	Serves(model string) bool
+}
+
+// Decision is what the gateway would do with a replayed request.
+type Decision struct {
+	Outcome  string `json:"outcome"`
+	Provider string `json:"provider,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Difference is a recorded request the change would have handled differently.
+type Difference struct {
+	RequestID string    `json:"request_id"`
+	TenantID  string    `json:"tenant_id"`
+	APIKeyID  string    `json:"api_key_id,omitempty"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Before    Decision  `json:"before"`
+	After     Decision  `json:"after"`
+}
+
+// Report sums up a replay.
+type Report struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Requests int       `json:"requests"` // replayed: the recorded requests the change applies to
+	// Truncated means the window held more than MaxRequests requests and
+	// only the newest were replayed.
+	Truncated   bool `json:"truncated,omitempty"`
+	Changed     int  `json:"changed"`
+	Blocked     int  `json:"blocked"`   // served now, blocked after the change
+	Unblocked   int  `json:"unblocked"` // blocked now, served after the change
+	Substituted int  `json:"substituted"`
+	Rerouted    int  `json:"rerouted"` // served by another provider
+	// Unchecked counts requests a guardrail change couldn't be replayed
+	// against because their payload wasn't stored (see WithExchanges).
+	Unchecked int `json:"unchecked,omitempty"`
+	// Differences lists up to 50 changed requests, newest first.
+	Differences []Difference `json:"differences"`
+}
+
+// Replayer evaluates policy changes against the last hours of traffic.
+// Requests are replayed with today's provider health, prices and routing
+// statistics, so before and after differ only by the change itself.
+type Replayer struct {
+	traffic   Traffic
+	tenants   tenant.Store
+	router    Router
+	routing   *policy.RoutingResolver
+	models    policy.Store
+	exchanges audit.ExchangeStore
+}
+
+// Option configures a Replayer.
+type Option func(*Replayer)
+
+// WithExchanges replays guardrail changes against the payloads tenants with
+// audit_payloads store. Without it, or for requests whose payload wasn't
+// stored, guardrail changes are reported as unchecked.
+func WithExchanges(store audit.ExchangeStore) Option {
+	return func(r *Replayer) {
+		r.exchanges = store
+	}
+}
+
+func NewReplayer(traffic Traffic, tenants tenant.Store, router Router, routing *policy.RoutingResolver, models policy.Store, opts ...Option) *Replayer {
+	r := &Replayer{traffic: traffic, tenants: tenants, router: router, routing: routing, models: models}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// scenario is the policies requests are replayed under.
+type scenario struct {
+	modelPolicy func(ctx context.Context, tenantID string) (*policy.ModelPolicy, error)
+	routing     *policy.RoutingResolver
+	// settings, when set, replaces the stored settings of the tenant being
+	// replayed.
+	settings *tenant.Settings
+}
+
+// replayed is a recorded request with what budgets and guardrails are
+// checked against besides its usage log.
+type replayed struct {
+	*billing.UsageLog
+	spend billing.Spend // the tenant's spend before the request
+	// texts are the stored input messages and output, nil when guardrails
+	// aren't replayed or the payload wasn't stored.
+	texts  []string
+	output string
+}
+
+// ModelPolicy replays the tenant's traffic over the last window with p in
+// place of its current model policy.
+func (r *Replayer) ModelPolicy(ctx context.Context, p *policy.ModelPolicy, window time.Duration) (*Report, error) {
+	after := scenario{
+		modelPolicy: func(context.Context, string) (*policy.ModelPolicy, error) { return p, nil },
+		routing:     r.routing,
+	}
+	return r.replay(ctx, p.TenantID, window, func(*billing.UsageLog, *tenant.Settings) bool { return true }, after)
+}
+
+// RoutingPolicy replays the traffic p's level covers over the last window
+// with p in place of the policy stored at that level; an empty p replays
+// deleting it.
+func (r *Replayer) RoutingPolicy(ctx context.Context, p policy.RoutingPolicy, window time.Duration) (*Report, error) {
+	covers := func(l *billing.UsageLog, s *tenant.Settings) bool {
+		switch p.Level {
+		case policy.LevelPlan:
+			return s.Plan == p.ID
+		case policy.LevelKey:
+			return l.APIKeyID == p.ID
+		}
+		return true
+	}
+	after := scenario{modelPolicy: r.models.Get, routing: r.routing.With(p)}
+	return r.replay(ctx, "", window, covers, after)
+}
+
+// TenantSettings replays the tenant's traffic over the last window with s in
+// place of its stored settings: its routing settings and cost cap, its
+// daily and monthly budgets, checked against the spend recorded before each
+// request, and its guardrails, checked against stored payloads. Guardrails
+// see payloads as stored, after any redaction, and moderation rules are not
+// replayed.
+func (r *Replayer) TenantSettings(ctx context.Context, tenantID string, s *tenant.Settings, window time.Duration) (*Report, error) {
+	after := scenario{modelPolicy: r.models.Get, routing: r.routing, settings: s}
+	return r.replay(ctx, tenantID, window, func(*billing.UsageLog, *tenant.Settings) bool { return true }, after)
+}
+
+func (r *Replayer) replay(ctx context.Context, tenantID string, window time.Duration, covers func(*billing.UsageLog, *tenant.Settings) bool, after scenario) (*Report, error) {
+	to := time.Now().UTC()
+	report := &Report{From: to.Add(-window), To: to, Differences: []Difference{}}
+	logs, err := r.traffic.GetRequests(ctx, tenantID, report.From, report.To, MaxRequests)
+	if err != nil {
+		return nil, err
+	}
+	report.Truncated = len(logs) == MaxRequests
+
+	before := scenario{modelPolicy: r.models.Get, routing: r.routing}
+	settings := make(map[string]*tenant.Settings)
+	var spends []billing.Spend
+	var guardrails bool
+	if after.settings != nil && len(logs) > 0 {
+		current, err := r.tenants.Get(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s settings: %w", tenantID, err)
+		}
+		if budgetOf(current) != (billing.Budget{}) || budgetOf(after.settings) != (billing.Budget{}) {
+			if spends, err = r.spends(ctx, tenantID, logs); err != nil {
+				return nil, err
+			}
+		}
+		guardrails = !reflect.DeepEqual(current.Guardrails, after.settings.Guardrails)
+	}
+	pipelines := make(map[*tenant.Settings]pipeline)
+	for i, l := range logs {
+		s, ok := settings[l.TenantID]
+		if !ok {
+			if s, err = r.tenants.Get(ctx, l.TenantID); err != nil {
+				return nil, fmt.Errorf("tenant %s settings: %w", l.TenantID, err)
+			}
+			settings[l.TenantID] = s
+		}
+		if !covers(l, s) {
+			continue
+		}
+		report.Requests++
+
+		req := replayed{UsageLog: l}
+		if spends != nil {
+			req.spend = spends[i]
+		}
+		if guardrails {
+			if err := r.loadPayload(ctx, &req); err != nil {
+				return nil, err
+			}
+			if req.texts == nil {
+				report.Unchecked++
+			}
+		}
+		sAfter := s
+		if after.settings != nil {
+			sAfter = after.settings
+		}
+		was, err := r.decide(ctx, req, s, before, pipelines)
+		if err != nil {
+			return nil, err
+		}
+		now, err := r.decide(ctx, req, sAfter, after, pipelines)
+		if err != nil {
+			return nil, err
+		}
+		if was == now {
+			continue
+		}
+		report.count(was, now)
+		if len(report.Differences) < maxDifferences {
+			report.Differences = append(report.Differences, Difference{
+				RequestID: l.RequestID, TenantID: l.TenantID, APIKeyID: l.APIKeyID,
+				Model: l.Model, CreatedAt: l.CreatedAt, Before: was, After: now,
+			})
+		}
+	}
+	return report, nil
+}
+
+func (r *Report) count(was, now Decision) {
+	r.Changed++
+	switch {
+	case now.Outcome == OutcomeBlocked && was.Outcome != OutcomeBlocked:
+		r.Blocked++
+	case was.Outcome == OutcomeBlocked && now.Outcome != OutcomeBlocked:
+		r.Unblocked++
+	case now.Outcome == OutcomeSubstituted && was.Outcome != OutcomeSubstituted:
+		r.Substituted++
+	case now.Provider != was.Provider:
+		r.Rerouted++
+	}
+}
+
+func budgetOf(s *tenant.Settings) billing.Budget {
+	return billing.Budget{DailyUSD: s.DailyBudgetUSD, MonthlyUSD: s.MonthlyBudgetUSD}
+}
+
+// spends returns the tenant's spend before each of logs (newest first):
+// what was logged earlier in the oldest request's UTC day and month, plus
+// the recorded cost of the requests replayed before it.
+func (r *Replayer) spends(ctx context.Context, tenantID string, logs []*billing.UsageLog) ([]billing.Spend, error) {
+	oldest := logs[len(logs)-1].CreatedAt.UTC()
+	day := time.Date(oldest.Year(), oldest.Month(), oldest.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// Spend is summed through the instant before the oldest request, which
+	// is counted below.
+	until := oldest.Add(-time.Microsecond)
+	var spend billing.Spend
+	var err error
+	if spend.DayUSD, err = r.traffic.GetTotalCostByTenant(ctx, tenantID, day, until, time.Time{}); err != nil {
+		return nil, err
+	}
+	if spend.MonthUSD, err = r.traffic.GetTotalCostByTenant(ctx, tenantID, month, until, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	out := make([]billing.Spend, len(logs))
+	prev := oldest
+	for i := len(logs) - 1; i >= 0; i-- {
+		at := logs[i].CreatedAt.UTC()
+		if at.Year() != prev.Year() || at.Month() != prev.Month() {
+			spend.MonthUSD = 0
+		}
+		if at.Year() != prev.Year() || at.YearDay() != prev.YearDay() {
+			spend.DayUSD = 0
+		}
+		prev = at
+		out[i] = spend
+		spend.DayUSD += logs[i].CostUSD
+		spend.MonthUSD += logs[i].CostUSD
+	}
+	return out, nil
+}
+
+// loadPayload reads req's stored input messages and output, leaving
+// req.texts nil when there is none.
+func (r *Replayer) loadPayload(ctx context.Context, req *replayed) error {
+	if r.exchanges == nil {
+		return nil
+	}
+	e, err := r.exchanges.GetExchange(ctx, req.TenantID, req.RequestID)
+	if errors.Is(err, audit.ErrExchangeNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("request %s payload: %w", req.RequestID, err)
+	}
+	var in provider.Request
+	if err := json.Unmarshal(e.Request, &in); err != nil {
+		return nil
+	}
+	req.texts = make([]string, len(in.Messages))
+	for i, m := range in.Messages {
+		req.texts[i] = m.Content
+	}
+	var out provider.Response
+	if len(e.Response) > 0 && json.Unmarshal(e.Response, &out) == nil {
+		req.output = out.Content
+	}
+	return nil
+}
+
+// pipeline is a tenant's compiled guardrails, or why they don't compile.
+type pipeline struct {
+	p   *guardrail.Pipeline
+	err error
+}
+
+// checkGuardrails is the decision s's guardrails make on req's stored
+// payload, and whether they block it. Rules that don't compile block every
+// request, as the gateway fails closed on them.
+func checkGuardrails(ctx context.Context, req replayed, s *tenant.Settings, pipelines map[*tenant.Settings]pipeline) (Decision, bool) {
+	if req.texts == nil || len(s.Guardrails) == 0 {
+		return Decision{}, false
+	}
+	pl, ok := pipelines[s]
+	if !ok {
+		pl.p, pl.err = guardrail.New(s.Guardrails, nil)
+		pipelines[s] = pl
+	}
+	if pl.err != nil {
+		return Decision{Outcome: OutcomeBlocked, Reason: "invalid guardrail configuration: " + pl.err.Error()}, true
+	}
+	res := pl.p.Check(ctx, guardrail.StageInput, req.texts)
+	if !res.Blocked && req.output != "" {
+		res = pl.p.Check(ctx, guardrail.StageOutput, []string{req.output})
+	}
+	if !res.Blocked {
+		return Decision{}, false
+	}
+	v := res.Violations[len(res.Violations)-1]
+	return Decision{Outcome: OutcomeBlocked, Reason: fmt.Sprintf("%s blocked by guardrail %q", v.Stage, v.Rule)}, true
+}
+
+// decide is what the gateway would do with the recorded request req under
+// sc and the tenant settings s. The recorded cost stands in for the
+// estimate cost caps are checked against.
+func (r *Replayer) decide(ctx context.Context, req replayed, s *tenant.Settings, sc scenario, pipelines map[*tenant.Settings]pipeline) (Decision, error) {
+	l := req.UsageLog
+	mp, err := sc.modelPolicy(ctx, l.TenantID)
+	if err != nil {
+		return Decision{}, err
+	}
+	if err := mp.Check(l.Model); err != nil {
+		return Decision{Outcome: OutcomeBlocked, Reason: err.Error()}, nil
+	}
+	budget := budgetOf(s)
+	switch budget.Exceeded(req.spend) {
+	case "daily":
+		return Decision{Outcome: OutcomeBlocked, Reason: fmt.Sprintf("daily budget of $%.2f reached", budget.DailyUSD)}, nil
+	case "monthly":
+		return Decision{Outcome: OutcomeBlocked, Reason: fmt.Sprintf("monthly budget of $%.2f reached", budget.MonthlyUSD)}, nil
+	}
+	if d, blocked := checkGuardrails(ctx, req, s, pipelines); blocked {
+		return d, nil
+	}
+	routing, err := sc.routing.Resolve(ctx, s.Plan, s.Routing(), l.APIKeyID)
+	if err != nil {
+		return Decision{}, err
+	}
+	if routing.Mode == policy.ModeStrict && !r.router.Serves(l.Model) {
+		return Decision{Outcome: OutcomeBlocked, Reason: "model_not_found"}, nil
+	}
+	if routing.MaxCost != nil && l.CostUSD > routing.MaxCost.USD {
+		reason := fmt.Sprintf("cost $%.6f over max_cost $%.6f", l.CostUSD, routing.MaxCost.USD)
+		if routing.MaxCost.Substitute {
+			return Decision{Outcome: OutcomeSubstituted, Reason: reason}, nil
+		}
+		return Decision{Outcome: OutcomeBlocked, Reason: reason}, nil
+	}
+
+	d := Decision{Outcome: OutcomeServed}
+	p, err := r.router.Route(ctx, &provider.Request{Model: l.Model, TenantID: l.TenantID, RoutingStrategy: routing.Strategy})
+	if err == nil {
+		d.Provider = p.Name()
+	}
+	return d, nil
+}
@@ -0,0 +1,303 @@
+package dryrun
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+type memTraffic []*billing.UsageLog
+
+func (m memTraffic) GetRequests(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]*billing.UsageLog, error) {
+	var out []*billing.UsageLog
+	for _, l := range m {
+		if tenantID == "" || l.TenantID == tenantID {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (m memTraffic) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error) {
+	var total float64
+	for _, l := range m {
+		if l.TenantID == tenantID && !l.CreatedAt.Before(from) && !l.CreatedAt.After(to) {
+			total += l.CostUSD
+		}
+	}
+	return total, nil
+}
+
+type memExchanges map[string]*audit.Exchange
+
+func (m memExchanges) SaveExchange(ctx context.Context, e *audit.Exchange) error {
+	m[e.RequestID] = e
+	return nil
+}
+
+func (m memExchanges) GetExchange(ctx context.Context, tenantID, requestID string) (*audit.Exchange, error) {
+	if e, ok := m[requestID]; ok && e.TenantID == tenantID {
+		return e, nil
+	}
+	return nil, audit.ErrExchangeNotFound
+}
+
+func (m memExchanges) ListConversation(ctx context.Context, tenantID, conversationID string) ([]*audit.Exchange, error) {
+	return nil, nil
+}
+
+func (m memExchanges) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+type memTenants map[string]*tenant.Settings
+
+func (m memTenants) Get(ctx context.Context, tenantID string) (*tenant.Settings, error) {
+	if s, ok := m[tenantID]; ok {
+		return s, nil
+	}
+	return &tenant.Settings{}, nil
+}
+
+func (m memTenants) Put(ctx context.Context, tenantID string, s *tenant.Settings) error {
+	m[tenantID] = s
+	return nil
+}
+
+type memRouting map[string]*policy.RoutingPolicy
+
+func (m memRouting) Get(ctx context.Context, level, id string) (*policy.RoutingPolicy, error) {
+	if p, ok := m[level+"/"+id]; ok {
+		return p, nil
+	}
+	return &policy.RoutingPolicy{Level: level, ID: id}, nil
+}
+
+func (m memRouting) Put(ctx context.Context, p *policy.RoutingPolicy) error {
+	m[p.Level+"/"+p.ID] = p
+	return nil
+}
+
+func (m memRouting) Delete(ctx context.Context, level, id string) error {
+	delete(m, level+"/"+id)
+	return nil
+}
+
+func (m memRouting) List(ctx context.Context) ([]*policy.RoutingPolicy, error) {
+	return nil, nil
+}
+
+type memModels map[string]*policy.ModelPolicy
+
+func (m memModels) Get(ctx context.Context, tenantID string) (*policy.ModelPolicy, error) {
+	if p, ok := m[tenantID]; ok {
+		return p, nil
+	}
+	return &policy.ModelPolicy{TenantID: tenantID}, nil
+}
+
+func (m memModels) Put(ctx context.Context, p *policy.ModelPolicy) error {
+	m[p.TenantID] = p
+	return nil
+}
+
+func (m memModels) Delete(ctx context.Context, tenantID string) error {
+	delete(m, tenantID)
+	return nil
+}
+
+type named struct {
+	provider.Provider
+	name string
+}
+
+func (n named) Name() string { return n.name }
+
+// strategyRouter sends cost-routed requests to openai and latency-routed
+// ones to gemini, and serves only gpt-4o and gemini-1.5-pro.
+type strategyRouter struct{}
+
+func (strategyRouter) Route(ctx context.Context, req *provider.Request) (provider.Provider, error) {
+	if req.RoutingStrategy == "latency" {
+		return named{name: "gemini"}, nil
+	}
+	return named{name: "openai"}, nil
+}
+
+func (strategyRouter) Serves(model string) bool {
+	return model == "gpt-4o" || model == "gemini-1.5-pro"
+}
+
+func newTestReplayer() (*Replayer, memModels) {
+	now := time.Now()
+	traffic := memTraffic{
+		{RequestID: "r1", TenantID: "t1", APIKeyID: "k1", Model: "gpt-4o", CostUSD: 0.01, CreatedAt: now},
+		{RequestID: "r2", TenantID: "t1", Model: "gpt-4o-mini", CostUSD: 0.002, CreatedAt: now},
+		{RequestID: "r3", TenantID: "t2", Model: "gpt-4o", CostUSD: 0.2, CreatedAt: now},
+		{RequestID: "r4", TenantID: "t2", Model: "llama3.1", CostUSD: 0.001, CreatedAt: now},
+	}
+	tenants := memTenants{"t1": {Plan: "free"}, "t2": {Plan: "pro"}}
+	models := memModels{}
+	resolver := policy.NewRoutingResolver(memRouting{}, "cost")
+	return NewReplayer(traffic, tenants, strategyRouter{}, resolver, models), models
+}
+
+func TestReplayer_ModelPolicy(t *testing.T) {
+	r, _ := newTestReplayer()
+
+	report, err := r.ModelPolicy(context.Background(), &policy.ModelPolicy{TenantID: "t1", Deny: []string{"gpt-4o-mini"}}, time.Hour)
+	if err != nil {
+		t.Fatalf("ModelPolicy: %v", err)
+	}
+	if report.Requests != 2 || report.Changed != 1 || report.Blocked != 1 {
+		t.Errorf("Expected 1 of t1's 2 requests blocked, got %+v", report)
+	}
+	if len(report.Differences) != 1 || report.Differences[0].RequestID != "r2" {
+		t.Fatalf("Expected r2 to differ, got %+v", report.Differences)
+	}
+	d := report.Differences[0]
+	if d.Before.Outcome != OutcomeServed || d.Before.Provider != "openai" || d.After.Outcome != OutcomeBlocked || d.After.Reason == "" {
+		t.Errorf("Unexpected difference: %+v", d)
+	}
+}
+
+func TestReplayer_RoutingPolicy(t *testing.T) {
+	r, _ := newTestReplayer()
+	ctx := context.Background()
+
+	// The pro plan's traffic: r3 goes over the cap, r4's model is served by
+	// no provider and strict mode would reject it.
+	report, err := r.RoutingPolicy(ctx, policy.RoutingPolicy{Level: policy.LevelPlan, ID: "pro", Mode: policy.ModeStrict, MaxCost: &provider.CostCap{USD: 0.1}}, time.Hour)
+	if err != nil {
+		t.Fatalf("RoutingPolicy: %v", err)
+	}
+	if report.Requests != 2 || report.Blocked != 2 {
+		t.Errorf("Expected both pro requests blocked, got %+v", report)
+	}
+
+	// A key's strategy only reroutes that key's requests.
+	report, err = r.RoutingPolicy(ctx, policy.RoutingPolicy{Level: policy.LevelKey, ID: "k1", Strategy: "latency"}, time.Hour)
+	if err != nil {
+		t.Fatalf("RoutingPolicy: %v", err)
+	}
+	if report.Requests != 1 || report.Rerouted != 1 {
+		t.Errorf("Expected k1's request rerouted, got %+v", report)
+	}
+	if d := report.Differences[0]; d.Before.Provider != "openai" || d.After.Provider != "gemini" {
+		t.Errorf("Expected openai -> gemini, got %+v", d)
+	}
+
+	// A substituting global cap serves r3 with a cheaper model instead.
+	report, err = r.RoutingPolicy(ctx, policy.RoutingPolicy{Level: policy.LevelGlobal, MaxCost: &provider.CostCap{USD: 0.1, Substitute: true}}, time.Hour)
+	if err != nil {
+		t.Fatalf("RoutingPolicy: %v", err)
+	}
+	if report.Requests != 4 || report.Changed != 1 || report.Substituted != 1 {
+		t.Errorf("Expected only r3 substituted, got %+v", report)
+	}
+}
+
+func TestReplayer_RoutingPolicyDeletion(t *testing.T) {
+	traffic := memTraffic{{RequestID: "r1", TenantID: "t1", Model: "gpt-4o", CostUSD: 0.2, CreatedAt: time.Now()}}
+	routing := memRouting{"global/": {Level: policy.LevelGlobal, MaxCost: &provider.CostCap{USD: 0.1}}}
+	r := NewReplayer(traffic, memTenants{}, strategyRouter{}, policy.NewRoutingResolver(routing, "cost"), memModels{})
+
+	report, err := r.RoutingPolicy(context.Background(), policy.RoutingPolicy{Level: policy.LevelGlobal}, time.Hour)
+	if err != nil {
+		t.Fatalf("RoutingPolicy: %v", err)
+	}
+	if report.Unblocked != 1 {
+		t.Errorf("Expected deleting the cap to unblock r1, got %+v", report)
+	}
+	if _, ok := routing["global/"]; !ok {
+		t.Error("Expected the stored policy to be left alone")
+	}
+}
+
+func TestReplayer_TenantSettingsBudget(t *testing.T) {
+	// Two requests of $0.04 today, after $0.05 spent earlier in the day but
+	// before the window.
+	now := time.Now().UTC().Truncate(time.Second)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	older, newer := now.Add(-2*time.Minute), now.Add(-time.Minute)
+	if older.Before(today) {
+		t.Skip("too close to midnight UTC")
+	}
+	traffic := memTraffic{
+		{RequestID: "r2", TenantID: "t1", Model: "gpt-4o", CostUSD: 0.04, CreatedAt: newer},
+		{RequestID: "r1", TenantID: "t1", Model: "gpt-4o", CostUSD: 0.04, CreatedAt: older},
+		{RequestID: "r0", TenantID: "t1", Model: "gpt-4o", CostUSD: 0.05, CreatedAt: today},
+	}
+	windowed := memTrafficFrom{traffic, older}
+	r := NewReplayer(windowed, memTenants{"t1": {}}, strategyRouter{}, policy.NewRoutingResolver(memRouting{}, "cost"), memModels{})
+
+	// $0.09 a day admits r1, after $0.05, but not r2, after $0.09.
+	report, err := r.TenantSettings(context.Background(), "t1", &tenant.Settings{DailyBudgetUSD: 0.09}, time.Hour)
+	if err != nil {
+		t.Fatalf("TenantSettings: %v", err)
+	}
+	if report.Requests != 2 || report.Blocked != 1 {
+		t.Fatalf("Expected one of 2 requests blocked, got %+v", report)
+	}
+	if d := report.Differences[0]; d.RequestID != "r2" || d.After.Reason != "daily budget of $0.09 reached" {
+		t.Errorf("Expected r2 blocked by the daily budget, got %+v", d)
+	}
+}
+
+// memTrafficFrom serves only the requests logged from a point on, as a
+// window that started after some of the day's spend.
+type memTrafficFrom struct {
+	memTraffic
+	from time.Time
+}
+
+func (m memTrafficFrom) GetRequests(ctx context.Context, tenantID string, from, to time.Time, limit int) ([]*billing.UsageLog, error) {
+	var out []*billing.UsageLog
+	for _, l := range m.memTraffic {
+		if l.TenantID == tenantID && !l.CreatedAt.Before(m.from) {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func TestReplayer_TenantSettingsGuardrails(t *testing.T) {
+	now := time.Now()
+	traffic := memTraffic{
+		{RequestID: "r1", TenantID: "t1", Model: "gpt-4o", CreatedAt: now},
+		{RequestID: "r2", TenantID: "t1", Model: "gpt-4o", CreatedAt: now},
+		{RequestID: "r3", TenantID: "t1", Model: "gpt-4o", CreatedAt: now},
+	}
+	payload := func(id, prompt, answer string) *audit.Exchange {
+		req, _ := json.Marshal(provider.Request{Messages: []provider.Message{{Role: "user", Content: prompt}}})
+		resp, _ := json.Marshal(provider.Response{Content: answer})
+		return &audit.Exchange{RequestID: id, TenantID: "t1", Request: req, Response: resp}
+	}
+	exchanges := memExchanges{
+		"r1": payload("r1", "what is project falcon?", "I can't say."),
+		"r2": payload("r2", "hello", "the falcon launch is on friday"),
+	}
+	r := NewReplayer(traffic, memTenants{"t1": {}}, strategyRouter{}, policy.NewRoutingResolver(memRouting{}, "cost"), memModels{}, WithExchanges(exchanges))
+
+	s := &tenant.Settings{Guardrails: []guardrail.Rule{{Name: "codenames", Type: guardrail.TypeKeywords, Keywords: []string{"falcon"}, Action: guardrail.ActionBlock}}}
+	report, err := r.TenantSettings(context.Background(), "t1", s, time.Hour)
+	if err != nil {
+		t.Fatalf("TenantSettings: %v", err)
+	}
+	if report.Requests != 3 || report.Blocked != 2 || report.Unchecked != 1 {
+		t.Fatalf("Expected r1 and r2 blocked and r3 unchecked, got %+v", report)
+	}
+	for _, d := range report.Differences {
+		if d.After.Reason == "" {
+			t.Errorf("Expected a reason naming the guardrail, got %+v", d)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Rule types.
+const (
+	TypeRegex      = "regex"
+	TypeKeywords   = "keywords"
+	TypePII        = "pii"
+	TypeModeration = "moderation" // needs a Moderator
+)
+
+// What a rule does on a match.
+const (
+	ActionBlock    = "block"    // reject the request or response
+	ActionRedact   = "redact"   // mask the matched text and carry on
+	ActionAnnotate = "annotate" // report the match and carry on
+)
+
+// How serious a match is, as reported to tenants' security webhooks. Rules
+// without one default to high for block, medium for redact and low for
+// annotate.
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+var defaultSeverity = map[string]string{
+	ActionBlock:    SeverityHigh,
+	ActionRedact:   SeverityMedium,
+	ActionAnnotate: SeverityLow,
+}
+
+// Where a rule applies.
+const (
+	StageInput  = "input"  // the prompt's messages
+	StageOutput = "output" // the model's response
+)
+
+// Rule is one guardrail in a tenant's pipeline. Rules run in order.
+type Rule struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Pattern  string   `json:"pattern,omitempty"`  // regex
+	Keywords []string `json:"keywords,omitempty"` // keywords, matched case-insensitively as whole words
+	// PII lists the kinds a pii rule detects (see PIIKinds); empty means all.
+	PII []string `json:"pii,omitempty"`
+	// Categories limits a moderation rule to these flagged categories;
+	// empty means any.
+	Categories []string `json:"categories,omitempty"`
+	Action     string   `json:"action"`
+	Severity   string   `json:"severity,omitempty"`
+	// Stages the rule applies to; empty means both.
+	Stages []string `json:"stages,omitempty"`
+}
+
+// Violation reports a rule that matched. The matched text is not included,
+// since it may be what the rule protects.
+type Violation struct {
+	Rule     string `json:"rule"`
+	Type     string `json:"type"`
+	Action   string `json:"action"`
+	Severity string `json:"severity"`
+	Stage    string `json:"stage"`
+	Category string `json:"category,omitempty"` // PII kind, keyword or moderation category
+	Message  *int   `json:"message,omitempty"`  // index of the input message
+}
+
+// Moderator classifies text with an external moderation service and returns
+// the categories it flagged.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) ([]string, error)
+}
+
+// span is a matched range of text in bytes.
+type span struct {
+	start, end int
+	category   string
+}
+
+type compiled struct {
+	Rule
+	re       *regexp.Regexp
+	detector func(text string) []span
+}
+
+// Pipeline applies a tenant's rules to requests and responses.
+type Pipeline struct {
+	rules     []compiled
+	moderator Moderator
+}
+
+// New compiles rules. moderator may be nil, in which case moderation rules
+// are skipped.
+func New(rules []Rule, moderator Moderator) (*Pipeline, error) {
+	p := &Pipeline{moderator: moderator}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch r.Action {
+		case ActionBlock, ActionRedact, ActionAnnotate:
+		default:
+			return nil, fmt.Errorf("guardrail %s: unknown action %q", r.Name, r.Action)
+		}
+		switch r.Severity {
+		case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		case "":
+			r.Severity = defaultSeverity[r.Action]
+		default:
+			return nil, fmt.Errorf("guardrail %s: unknown severity %q", r.Name, r.Severity)
+		}
+		for _, s := range r.Stages {
+			if s != StageInput && s != StageOutput {
+				return nil, fmt.Errorf("guardrail %s: unknown stage %q", r.Name, s)
+			}
+		}
+		c := compiled{Rule: r}
+		switch r.Type {
+		case TypeRegex:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail %s: invalid pattern: %w", r.Name, err)
+			}
+			c.detector = regexDetector(re)
+		case TypeKeywords:
+			if len(r.Keywords) == 0 {
+				return nil, fmt.Errorf("guardrail %s: no keywords", r.Name)
+			}
+			quoted := make([]string, len(r.Keywords))
+			for i, k := range r.Keywords {
+				quoted[i] = regexp.QuoteMeta(k)
+			}
+			re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+			c.detector = func(text string) []span {
+				var spans []span
+				for _, m := range re.FindAllStringIndex(text, -1) {
+					spans = append(spans, span{m[0], m[1], strings.ToLower(text[m[0]:m[1]])})
+				}
+				return spans
+			}
+		case TypePII:
+			detect, err := piiDetector(r.PII)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail %s: %w", r.Name, err)
+			}
+			c.detector = detect
+		case TypeModeration:
+			if r.Action == ActionRedact {
+				return nil, fmt.Errorf("guardrail %s: moderation rules can block or annotate, not redact", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("guardrail %s: unknown type %q", r.Name, r.Type)
+		}
+		p.rules = append(p.rules, c)
+	}
+	return p, nil
+}
+
+func regexDetector(re *regexp.Regexp) func(string) []span {
+	return func(text string) []span {
+		var spans []span
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			if m[0] < m[1] {
+				spans = append(spans, span{m[0], m[1], ""})
+			}
+		}
+		return spans
+	}
+}
+
+// Result is the outcome of running a pipeline over some texts.
+type Result struct {
+	// Texts are the inputs with redactions applied.
+	Texts      []string
+	Violations []Violation
+	// Blocked is set when a block rule matched; Texts are then unchanged
+	// from the point of the blocking rule on.
+	Blocked bool
+}
+
+// Check runs the rules for stage over texts (the input messages, or the one
+// response). A moderation service error skips that rule, so an outage
+// doesn't take the gateway down with it.
+func (p *Pipeline) Check(ctx context.Context, stage string, texts []string) *Result {
+	res := &Result{Texts: slices.Clone(texts)}
+	for _, r := range p.rules {
+		if len(r.Stages) > 0 && !slices.Contains(r.Stages, stage) {
+			continue
+		}
+		for i, text := range res.Texts {
+			if text == "" {
+				continue
+			}
+			spans, err := p.match(ctx, r, text)
+			if err != nil {
+				log.Printf("guardrail: %s skipped: %v", r.Name, err)
+				break
+			}
+			if len(spans) == 0 {
+				continue
+			}
+			for _, category := range categories(spans) {
+				v := Violation{Rule: r.Name, Type: r.Type, Action: r.Action, Severity: r.Severity, Stage: stage, Category: category}
+				if stage == StageInput {
+					v.Message = &i
+				}
+				res.Violations = append(res.Violations, v)
+			}
+			switch r.Action {
+			case ActionBlock:
+				res.Blocked = true
+				return res
+			case ActionRedact:
+				res.Texts[i] = redact(text, spans)
+			}
+		}
+	}
+	return res
+}
+
+func (p *Pipeline) match(ctx context.Context, r compiled, text string) ([]span, error) {
+	if r.Type != TypeModeration {
+		return r.detector(text), nil
+	}
+	if p.moderator == nil {
+		return nil, fmt.Errorf("no moderation service configured")
+	}
+	flagged, err := p.moderator.Moderate(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	var spans []span
+	for _, c := range flagged {
+		if len(r.Categories) == 0 || slices.Contains(r.Categories, c) {
+			spans = append(spans, span{0, len(text), c})
+		}
+	}
+	return spans, nil
+}
+
+// categories returns the distinct categories among spans, in order.
+func categories(spans []span) []string {
+	var out []string
+	for _, s := range spans {
+		if !slices.Contains(out, s.category) {
+			out = append(out, s.category)
+		}
+	}
+	return out
+}
+
+// redact replaces spans with a marker naming what was removed.
+func redact(text string, spans []span) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue // overlaps the previous match
+		}
+		b.WriteString(text[last:s.start])
+		if s.category != "" {
+			b.WriteString("[REDACTED:" + strings.ToUpper(s.category) + "]")
+		} else {
+			b.WriteString("[REDACTED]")
+		}
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew_RejectsInvalidRules(t *testing.T) {
+	for _, r := range []Rule{
+		{Type: TypeRegex, Pattern: "(", Action: ActionBlock},
+		{Type: TypeKeywords, Action: ActionBlock},
+		{Type: TypePII, PII: []string{"passport"}, Action: ActionRedact},
+		{Type: TypeModeration, Action: ActionRedact},
+		{Type: TypeRegex, Pattern: "x", Action: "delete"},
+		{Type: TypeRegex, Pattern: "x", Action: ActionBlock, Stages: []string{"both"}},
+		{Type: "llm", Action: ActionBlock},
+	} {
+		if _, err := New([]Rule{r}, nil); err == nil {
+			t.Errorf("Expected %+v to be rejected", r)
+		}
+	}
+}
+
+func TestCheck_RedactsPII(t *testing.T) {
+	p, err := New([]Rule{{Name: "pii", Type: TypePII, Action: ActionRedact}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.Check(context.Background(), StageInput, []string{
+		"mail bob@example.org or call 555-123-4567",
+		"card 4111 1111 1111 1111, order 1234 5678 9012 3456",
+	})
+	if res.Blocked {
+		t.Fatal("Expected redaction, not a block")
+	}
+	if got := res.Texts[0]; got != "mail [REDACTED:EMAIL] or call [REDACTED:PHONE]" {
+		t.Errorf("Unexpected redaction: %q", got)
+	}
+	if got := res.Texts[1]; got != "card [REDACTED:CREDIT_CARD], order 1234 5678 9012 3456" {
+		t.Errorf("Expected only the Luhn-valid number redacted, got %q", got)
+	}
+	if len(res.Violations) != 3 || *res.Violations[2].Message != 1 {
+		t.Errorf("Expected a violation per kind and message, got %+v", res.Violations)
+	}
+}
+
+func TestCheck_StopsAtBlock(t *testing.T) {
+	p, err := New([]Rule{
+		{Name: "internal", Type: TypeRegex, Pattern: `PRJ-\d+`, Action: ActionBlock},
+		{Name: "later", Type: TypeKeywords, Keywords: []string{"launch"}, Action: ActionAnnotate},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.Check(context.Background(), StageOutput, []string{"PRJ-42 launch date"})
+	if !res.Blocked || len(res.Violations) != 1 || res.Violations[0].Rule != "internal" {
+		t.Errorf("Expected the first rule to block, got %+v", res)
+	}
+	if res.Violations[0].Message != nil {
+		t.Error("Expected no message index on output violations")
+	}
+}
+
+func TestCheck_SkipsRulesForOtherStages(t *testing.T) {
+	p, _ := New([]Rule{{Type: TypeKeywords, Keywords: []string{"secret"}, Action: ActionBlock, Stages: []string{StageOutput}}}, nil)
+	if res := p.Check(context.Background(), StageInput, []string{"a secret"}); res.Blocked {
+		t.Error("Expected an output rule not to block input")
+	}
+	if res := p.Check(context.Background(), StageOutput, []string{"secretary"}); res.Blocked {
+		t.Error("Expected keywords to match whole words only")
+	}
+}
+
+func TestCheck_Moderation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct{ Input string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		flagged := body.Input == "something violent"
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": []any{map[string]any{
+			"flagged":    flagged,
+			"categories": map[string]bool{"violence": flagged, "harassment": false},
+		}}})
+	}))
+	defer srv.Close()
+	m := NewOpenAIModerator("sk-test")
+	m.url = srv.URL
+
+	p, err := New([]Rule{{Name: "mod", Type: TypeModeration, Categories: []string{"violence"}, Action: ActionBlock}}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.Check(context.Background(), StageInput, []string{"hello", "something violent"})
+	if !res.Blocked || res.Violations[0].Category != "violence" || *res.Violations[0].Message != 1 {
+		t.Errorf("Expected the flagged message blocked, got %+v", res)
+	}
+
+	m.apiKey = "wrong"
+	if res := p.Check(context.Background(), StageInput, []string{"something violent"}); res.Blocked {
+		t.Error("Expected moderation errors to fail open")
+	}
+}
@@ -0,0 +1,84 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	openAIModerationURL   = "https://api.openai.com/v1/moderations"
+	openAIModerationModel = "omni-moderation-latest"
+)
+
+// OpenAIModerator classifies text with OpenAI's moderation endpoint.
+type OpenAIModerator struct {
+	apiKey string
+	url    string
+	client *http.Client
+}
+
+// NewOpenAIModerator returns a moderator authenticating with apiKey.
+func NewOpenAIModerator(apiKey string) *OpenAIModerator {
+	return &OpenAIModerator{
+		apiKey: apiKey,
+		url:    openAIModerationURL,
+		// Moderation sits on the request path, so it gets a much shorter
+		// budget than completions do.
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate returns the categories OpenAI flagged text for.
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) ([]string, error) {
+	body, err := json.Marshal(map[string]string{"model": openAIModerationModel, "input": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("moderation api returned %d: %s", resp.StatusCode, b)
+	}
+
+	var mr moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mr); err != nil {
+		return nil, err
+	}
+	var flagged []string
+	for _, r := range mr.Results {
+		if !r.Flagged {
+			continue
+		}
+		for c, ok := range r.Categories {
+			if ok {
+				flagged = append(flagged, c)
+			}
+		}
+	}
+	slices.Sort(flagged)
+	return flagged, nil
+}
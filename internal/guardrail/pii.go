@@ -0,0 +1,73 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PII kinds a pii rule can detect.
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIICreditCard = "credit_card" // Luhn-checked, so order numbers aren't caught
+	PIISSN        = "ssn"         // US social security numbers
+	PIIIPAddress  = "ip_address"  // IPv4
+)
+
+// PIIKinds lists every detectable PII kind.
+var PIIKinds = []string{PIIEmail, PIIPhone, PIICreditCard, PIISSN, PIIIPAddress}
+
+var piiPatterns = map[string]*regexp.Regexp{
+	PIIEmail:      regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	PIIPhone:      regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)|\b\d{3})[\s.-]\d{3}[\s.-]\d{4}\b`),
+	PIICreditCard: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	PIISSN:        regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	PIIIPAddress:  regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+}
+
+// piiDetector returns a detector for kinds, or all of them when empty.
+func piiDetector(kinds []string) (func(string) []span, error) {
+	if len(kinds) == 0 {
+		kinds = PIIKinds
+	}
+	for _, k := range kinds {
+		if piiPatterns[k] == nil {
+			return nil, fmt.Errorf("unknown pii kind %q", k)
+		}
+	}
+	return func(text string) []span {
+		var spans []span
+		for _, k := range kinds {
+			for _, m := range piiPatterns[k].FindAllStringIndex(text, -1) {
+				if k == PIICreditCard && !luhn(text[m[0]:m[1]]) {
+					continue
+				}
+				spans = append(spans, span{m[0], m[1], k})
+			}
+		}
+		return spans
+	}, nil
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	sum := 0
+	for i := range len(digits) {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
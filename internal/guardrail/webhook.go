@@ -0,0 +1,99 @@
+package guardrail
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+)
+
+// Webhook is a tenant's security webhook, notified when guardrails block or
+// redact content.
+type Webhook struct {
+	URL string `json:"url"`
+	// Secret signs deliveries: X-Gateway-Signature is
+	// sha256=HMAC(secret, "<timestamp>.<body>") with X-Gateway-Timestamp.
+	Secret string `json:"secret,omitempty"`
+	// IncludeContent adds the checked text to events. Off by default, since
+	// the text may be what the rule protects.
+	IncludeContent bool `json:"include_content,omitempty"`
+}
+
+// Outcomes of a checked request or response, in events.
+const (
+	OutcomeBlocked  = "blocked"
+	OutcomeRedacted = "redacted"
+)
+
+// Event is what a security webhook receives for a checked request or
+// response.
+type Event struct {
+	Type       string      `json:"type"` // "guardrail.violation"
+	TenantID   string      `json:"tenant_id"`
+	RequestID  string      `json:"request_id"`
+	Stage      string      `json:"stage"`
+	Outcome    string      `json:"outcome"`
+	Severity   string      `json:"severity"` // the highest among Violations
+	Violations []Violation `json:"violations"`
+	// Content is the checked text, before redaction, when the webhook opts
+	// in: the prompt's messages for input, the response for output.
+	Content   []string  `json:"content,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// EventFor builds the event for res, or reports false when nothing was
+// blocked or redacted. texts are what was checked.
+func EventFor(tenantID, requestID, stage string, res *Result, texts []string, hook *Webhook) (*Event, bool) {
+	outcome := ""
+	if res.Blocked {
+		outcome = OutcomeBlocked
+	}
+	for _, v := range res.Violations {
+		if outcome == "" && v.Action == ActionRedact {
+			outcome = OutcomeRedacted
+		}
+	}
+	if outcome == "" {
+		return nil, false
+	}
+	ev := &Event{
+		Type:       "guardrail.violation",
+		TenantID:   tenantID,
+		RequestID:  requestID,
+		Stage:      stage,
+		Outcome:    outcome,
+		Violations: res.Violations,
+		Timestamp:  time.Now().UTC(),
+	}
+	for _, v := range res.Violations {
+		if severityRank[v.Severity] > severityRank[ev.Severity] {
+			ev.Severity = v.Severity
+		}
+	}
+	if hook.IncludeContent {
+		ev.Content = texts
+	}
+	return ev, true
+}
+
+var severityRank = map[string]int{SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3, SeverityCritical: 4}
+
+// Notifier delivers events to security webhooks.
+type Notifier struct {
+	sender *webhook.Sender
+}
+
+func NewNotifier(sender *webhook.Sender) *Notifier {
+	return &Notifier{sender: sender}
+}
+
+// Notify posts ev to hook in the background, retrying with backoff.
+func (n *Notifier) Notify(hook *Webhook, ev *Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("guardrail: failed to encode webhook event", "request_id", ev.RequestID, "err", err)
+		return
+	}
+	n.sender.Send(hook.URL, hook.Secret, body, "security webhook for request "+ev.RequestID)
+}
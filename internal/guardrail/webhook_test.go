@@ -0,0 +1,70 @@
+package guardrail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+)
+
+func TestEventFor(t *testing.T) {
+	annotated := &Result{Violations: []Violation{{Rule: "topics", Action: ActionAnnotate, Severity: SeverityLow}}}
+	if _, ok := EventFor("t", "r", StageOutput, annotated, []string{"text"}, &Webhook{URL: "x"}); ok {
+		t.Error("Expected no event when nothing was blocked or redacted")
+	}
+
+	redacted := &Result{Violations: []Violation{
+		{Rule: "topics", Action: ActionAnnotate, Severity: SeverityLow},
+		{Rule: "pii", Action: ActionRedact, Severity: SeverityMedium},
+	}}
+	ev, ok := EventFor("t", "r", StageInput, redacted, []string{"mail jane@example.com"}, &Webhook{URL: "x"})
+	if !ok || ev.Outcome != OutcomeRedacted || ev.Severity != SeverityMedium || ev.Content != nil {
+		t.Errorf("Expected a redacted event at the highest severity without content, got %+v", ev)
+	}
+	ev, _ = EventFor("t", "r", StageInput, redacted, []string{"mail jane@example.com"}, &Webhook{URL: "x", IncludeContent: true})
+	if len(ev.Content) != 1 || ev.Content[0] != "mail jane@example.com" {
+		t.Errorf("Expected the checked text when opted in, got %v", ev.Content)
+	}
+}
+
+func TestNew_DefaultsSeverityByAction(t *testing.T) {
+	p, err := New([]Rule{{Name: "secrets", Type: TypeKeywords, Keywords: []string{"password"}, Action: ActionBlock}}, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if res := p.Check(t.Context(), StageInput, []string{"password"}); res.Violations[0].Severity != SeverityHigh {
+		t.Errorf("Expected block rules to default to high, got %+v", res.Violations)
+	}
+	if _, err := New([]Rule{{Type: TypeKeywords, Keywords: []string{"x"}, Action: ActionBlock, Severity: "severe"}}, nil); err == nil {
+		t.Error("Expected an unknown severity to be rejected")
+	}
+}
+
+func TestNotifier_SignsDeliveries(t *testing.T) {
+	delivered := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(r.Header.Get("X-Gateway-Timestamp") + "."))
+		mac.Write(body)
+		delivered <- r.Header.Get("X-Gateway-Signature") == "sha256="+hex.EncodeToString(mac.Sum(nil))
+	}))
+	defer srv.Close()
+
+	sender := webhook.NewSender(webhook.NewGuard([]string{"127.0.0.1"}))
+	NewNotifier(sender).Notify(&Webhook{URL: srv.URL, Secret: "s3cret"}, &Event{Type: "guardrail.violation", RequestID: "req-1"})
+	select {
+	case ok := <-delivered:
+		if !ok {
+			t.Error("Expected a valid signature")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a delivery")
+	}
+}
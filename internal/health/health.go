@@ -0,0 +1,122 @@
+// Package health serves the gateway's Kubernetes probes: /livez reports the
+// process is up, /readyz that the dependencies a replica needs to serve
+// traffic answer.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check is one dependency /readyz verifies.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Result is a check's outcome in the /readyz body.
+type Result struct {
+	Status    string `json:"status"` // "ok" or "error"
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+// New returns a Checker running checks, each cut off after timeout.
+func New(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Run runs every check concurrently and reports whether all of them passed.
+func (c *Checker) Run(ctx context.Context) (bool, map[string]Result) {
+	results := make(map[string]Result, len(c.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			start := time.Now()
+			err := check.Probe(probeCtx)
+			res := Result{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status, res.Error = "error", err.Error()
+			}
+			mu.Lock()
+			results[check.Name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, res := range results {
+		if res.Status != "ok" {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+// HandleReady serves /readyz: 200 when every check passes, 503
+// otherwise, with each check's result.
+func (c *Checker) HandleReady(w http.ResponseWriter, r *http.Request) {
+	ready, results := c.Run(r.Context())
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": results})
+}
+
+// HandleLive serves /livez. It checks nothing beyond the process answering,
+// so a dependency outage takes replicas out of rotation without restarting
+// them.
+func HandleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"status":"ok"}`))
+}
+
+// AnyOf is a check that passes when at least one of probes does, e.g. when
+// any configured provider can serve. Its error names every failure.
+func AnyOf(probes map[string]func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var failed []string
+		for name, probe := range probes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := probe(ctx); err != nil {
+					mu.Lock()
+					failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if len(probes) > 0 && len(failed) == len(probes) {
+			slices.Sort(failed)
+			return errors.New(strings.Join(failed, "; "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestHandleReady(t *testing.T) {
+	c := New(time.Second, Check{Name: "postgres", Probe: ok}, Check{Name: "redis", Probe: ok})
+	w := httptest.NewRecorder()
+	c.HandleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with every check passing, got %d", w.Code)
+	}
+
+	c = New(time.Second, Check{Name: "postgres", Probe: ok}, Check{Name: "redis", Probe: down})
+	w = httptest.NewRecorder()
+	c.HandleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with Redis down, got %d", w.Code)
+	}
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]Result `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Status != "unavailable" || body.Checks["postgres"].Status != "ok" || body.Checks["redis"].Error != "connection refused" {
+		t.Errorf("Expected each check's result, got %+v", body)
+	}
+}
+
+func TestRun_TimesOutSlowChecks(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ready, results := New(10*time.Millisecond, Check{Name: "postgres", Probe: hang}).Run(context.Background())
+	if ready || results["postgres"].Status != "error" {
+		t.Errorf("Expected a hung check to fail, got %+v", results)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	if err := AnyOf(map[string]func(context.Context) error{"openai": down, "claude": ok})(context.Background()); err != nil {
+		t.Errorf("Expected one reachable provider to pass, got %v", err)
+	}
+	err := AnyOf(map[string]func(context.Context) error{"openai": down, "claude": down})(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "claude: connection refused") || !strings.Contains(err.Error(), "openai:") {
+		t.Errorf("Expected every failure named, got %v", err)
+	}
+}
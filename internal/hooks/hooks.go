@@ -0,0 +1,124 @@
+// Package hooks runs operator-supplied transformations on every completion,
+// whichever provider serves it: on the request before it is routed, on a
+// complete response and on each streamed delta. Hooks are registered in
+// code when the server is built (server.WithHooks).
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Hook transforms completions. Implementations need not do something in
+// every method; Funcs adapts plain functions.
+type Hook interface {
+	Name() string
+	// OnRequest may rewrite req before it is validated against the
+	// tenant's policies and routed. An error rejects the request with 400
+	// and the error's message.
+	OnRequest(ctx context.Context, req *provider.Request) error
+	// OnResponse may rewrite a non-streamed response before it is returned.
+	// resp is the caller's copy, but its slices are shared: replace them
+	// rather than modify them. An error fails the request with 500.
+	OnResponse(ctx context.Context, req *provider.Request, resp *provider.Response) error
+	// OnChunk returns the text to relay in place of one streamed content
+	// delta. It sees deltas one at a time, as relayed: text split across
+	// deltas reaches it in pieces.
+	OnChunk(ctx context.Context, req *provider.Request, delta string) string
+}
+
+// Funcs is a Hook built from functions; nil ones do nothing.
+type Funcs struct {
+	HookName string
+	Request  func(ctx context.Context, req *provider.Request) error
+	Response func(ctx context.Context, req *provider.Request, resp *provider.Response) error
+	Chunk    func(ctx context.Context, req *provider.Request, delta string) string
+}
+
+func (f Funcs) Name() string { return f.HookName }
+
+func (f Funcs) OnRequest(ctx context.Context, req *provider.Request) error {
+	if f.Request == nil {
+		return nil
+	}
+	return f.Request(ctx, req)
+}
+
+func (f Funcs) OnResponse(ctx context.Context, req *provider.Request, resp *provider.Response) error {
+	if f.Response == nil {
+		return nil
+	}
+	return f.Response(ctx, req, resp)
+}
+
+func (f Funcs) OnChunk(ctx context.Context, req *provider.Request, delta string) string {
+	if f.Chunk == nil {
+		return delta
+	}
+	return f.Chunk(ctx, req, delta)
+}
+
+// Chain runs hooks in registration order, each seeing the previous one's
+// output. A nil Chain does nothing.
+type Chain []Hook
+
+// OnRequest stops at the first hook that rejects req.
+func (c Chain) OnRequest(ctx context.Context, req *provider.Request) error {
+	for _, h := range c {
+		if err := h.OnRequest(ctx, req); err != nil {
+			return fmt.Errorf("%s: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
+
+// OnResponse stops at the first hook that fails.
+func (c Chain) OnResponse(ctx context.Context, req *provider.Request, resp *provider.Response) error {
+	for _, h := range c {
+		if err := h.OnResponse(ctx, req, resp); err != nil {
+			return fmt.Errorf("%s: %w", h.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c Chain) OnChunk(ctx context.Context, req *provider.Request, delta string) string {
+	for _, h := range c {
+		delta = h.OnChunk(ctx, req, delta)
+	}
+	return delta
+}
+
+// SystemPrompt prepends text to every request's system message, adding
+// one when the request has none, e.g. for an organization-wide policy.
+func SystemPrompt(name, text string) Hook {
+	return Funcs{HookName: name, Request: func(ctx context.Context, req *provider.Request) error {
+		for i, m := range req.Messages {
+			if m.Role == "system" {
+				req.Messages[i].Content = text + "\n\n" + m.Content
+				return nil
+			}
+		}
+		req.Messages = append([]provider.Message{{Role: "system", Content: text}}, req.Messages...)
+		return nil
+	}}
+}
+
+// StripMarkers removes text matching pattern from responses and streamed
+// deltas, e.g. internal annotations a model was told to emit. A marker
+// split across two deltas is not removed from a stream.
+func StripMarkers(name string, pattern *regexp.Regexp) Hook {
+	return Funcs{
+		HookName: name,
+		Response: func(ctx context.Context, req *provider.Request, resp *provider.Response) error {
+			resp.Content = pattern.ReplaceAllString(resp.Content, "")
+			return nil
+		},
+		Chunk: func(ctx context.Context, req *provider.Request, delta string) string {
+			return pattern.ReplaceAllString(delta, "")
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	upper := Funcs{HookName: "upper", Chunk: func(ctx context.Context, req *provider.Request, delta string) string {
+		return strings.ToUpper(delta)
+	}}
+	chain := Chain{StripMarkers("markers", regexp.MustCompile(`<internal>.*?</internal>`)), upper}
+
+	req := &provider.Request{}
+	resp := &provider.Response{Content: "answer<internal>draft</internal>"}
+	if err := chain.OnResponse(ctx, req, resp); err != nil || resp.Content != "answer" {
+		t.Errorf("OnResponse() = %q, %v", resp.Content, err)
+	}
+	if got := chain.OnChunk(ctx, req, "a<internal>x</internal>b"); got != "AB" {
+		t.Errorf("Expected hooks applied in order, got %q", got)
+	}
+	if got := Chain(nil).OnChunk(ctx, req, "as is"); got != "as is" {
+		t.Errorf("Expected a nil chain to leave deltas alone, got %q", got)
+	}
+
+	reject := Funcs{HookName: "reject", Request: func(ctx context.Context, req *provider.Request) error {
+		return errors.New("no")
+	}}
+	err := Chain{upper, reject}.OnRequest(ctx, req)
+	if err == nil || err.Error() != "reject: no" {
+		t.Errorf("Expected the rejecting hook named, got %v", err)
+	}
+}
+
+func TestSystemPrompt(t *testing.T) {
+	h := SystemPrompt("org", "Follow the acceptable use policy.")
+
+	req := &provider.Request{Messages: []provider.Message{{Role: "user", Content: "hi"}}}
+	_ = h.OnRequest(context.Background(), req)
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[0].Content != "Follow the acceptable use policy." {
+		t.Errorf("Expected a system message added, got %+v", req.Messages)
+	}
+
+	req = &provider.Request{Messages: []provider.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "hi"}}}
+	_ = h.OnRequest(context.Background(), req)
+	if len(req.Messages) != 2 || req.Messages[0].Content != "Follow the acceptable use policy.\n\nBe brief." {
+		t.Errorf("Expected the tenant's system message kept after the prompt, got %+v", req.Messages)
+	}
+}
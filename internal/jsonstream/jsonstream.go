@@ -0,0 +1,316 @@
+// Package jsonstream checks streamed model output against a response_format
+// as it arrives, so a generation that can no longer become valid JSON for
+// the declared schema can be stopped instead of running to max_tokens.
+package jsonstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// maxDepth bounds nesting; no useful structured output gets close.
+const maxDepth = 256
+
+// Error reports where output derailed, in characters from its start.
+type Error struct {
+	Offset int
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("output derailed at character %d: %s", e.Offset, e.Reason)
+}
+
+// Container states.
+const (
+	wantValueOrEnd = iota // after [
+	wantKeyOrEnd          // after {
+	wantKey               // after , in an object
+	wantColon
+	wantValue
+	wantComma // after a value: , or the closing bracket
+)
+
+type frame struct {
+	array  bool
+	schema *schema
+	state  int
+	value  *schema // schema of the current property's value
+	seen   map[string]bool
+}
+
+// Validator accepts output for as long as it is a prefix of some document
+// the schema allows. It is not safe for concurrent use.
+type Validator struct {
+	root    *schema
+	stack   []*frame
+	scalar  *scalar
+	started bool // the top-level value has begun
+	done    bool // the top-level value is complete
+	offset  int
+	err     *Error
+}
+
+// NewValidator returns a Validator for documents matching schema, a JSON
+// Schema document.
+func NewValidator(schema json.RawMessage) *Validator {
+	return &Validator{root: compile(schema)}
+}
+
+// Started reports whether anything but leading whitespace has been accepted.
+func (v *Validator) Started() bool {
+	return v.started
+}
+
+// Complete reports whether the accepted output is a whole document.
+func (v *Validator) Complete() bool {
+	if v.scalar != nil && len(v.stack) == 0 && v.scalar.kind == kindNumber {
+		return v.scalar.complete()
+	}
+	return v.done
+}
+
+// WriteString checks the next piece of output. On derailment it returns
+// the bytes of s accepted before it and an *Error; later writes keep
+// returning that error.
+func (v *Validator) WriteString(s string) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	for i, r := range s {
+		if err := v.next(r); err != nil {
+			v.err = &Error{Offset: v.offset, Reason: err.Error()}
+			return i, v.err
+		}
+		v.offset++
+	}
+	return len(s), nil
+}
+
+func (v *Validator) next(r rune) error {
+	if sc := v.scalar; sc != nil {
+		done, reprocess, err := sc.next(r)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return v.checkPartial(sc)
+		}
+		v.scalar = nil
+		if err := v.finish(sc); err != nil {
+			return err
+		}
+		if !reprocess {
+			return nil
+		}
+	}
+	return v.structural(r)
+}
+
+func (v *Validator) structural(r rune) error {
+	if isSpace(r) {
+		return nil
+	}
+	if len(v.stack) == 0 {
+		if v.done {
+			return fmt.Errorf("unexpected %q after the JSON value", r)
+		}
+		if err := v.begin(r, v.root); err != nil {
+			return err
+		}
+		v.started = true
+		return nil
+	}
+
+	f := v.stack[len(v.stack)-1]
+	switch f.state {
+	case wantValueOrEnd:
+		if r == ']' {
+			return v.close()
+		}
+		f.state = wantComma
+		return v.begin(r, f.schema.elem())
+	case wantKeyOrEnd, wantKey:
+		if r == '}' && f.state == wantKeyOrEnd {
+			return v.close()
+		}
+		if r != '"' {
+			return fmt.Errorf("expected a property name, got %q", r)
+		}
+		f.state = wantColon
+		v.scalar = &scalar{kind: kindString, key: true, schema: f.schema}
+		return nil
+	case wantColon:
+		if r != ':' {
+			return fmt.Errorf("expected ':', got %q", r)
+		}
+		f.state = wantValue
+		return nil
+	case wantValue:
+		f.state = wantComma
+		if f.array {
+			return v.begin(r, f.schema.elem())
+		}
+		return v.begin(r, f.value)
+	default: // wantComma
+		switch {
+		case r == ',' && f.array:
+			f.state = wantValue
+		case r == ',':
+			f.state = wantKey
+		case r == ']' && f.array, r == '}' && !f.array:
+			return v.close()
+		default:
+			return fmt.Errorf("unexpected %q", r)
+		}
+		return nil
+	}
+}
+
+// begin starts a value of schema s at r.
+func (v *Validator) begin(r rune, s *schema) error {
+	kind := kindOf(r)
+	if kind == "" {
+		return fmt.Errorf("unexpected %q where a value should start", r)
+	}
+	if !s.allows(kind) {
+		return fmt.Errorf("%s where the schema expects %s", kind, strings.Join(s.types, " or "))
+	}
+	switch r {
+	case '{', '[':
+		if len(v.stack) >= maxDepth {
+			return fmt.Errorf("nested deeper than %d", maxDepth)
+		}
+		f := &frame{array: r == '[', schema: s, state: wantValueOrEnd}
+		if !f.array {
+			f.state = wantKeyOrEnd
+			f.seen = make(map[string]bool)
+		}
+		v.stack = append(v.stack, f)
+	case '"':
+		v.scalar = &scalar{kind: kindString, schema: s}
+	case 't', 'f', 'n':
+		v.scalar = &scalar{kind: kindLiteral, schema: s, literal: literals[r]}
+		v.scalar.raw.WriteRune(r)
+	default:
+		v.scalar = &scalar{kind: kindNumber, schema: s}
+		_, _, err := v.scalar.next(r)
+		return err
+	}
+	return nil
+}
+
+// checkPartial rejects a string no allowed property name or enum value
+// starts with. Escaped strings are only checked once complete.
+func (v *Validator) checkPartial(sc *scalar) error {
+	if sc.kind != kindString || sc.escaped {
+		return nil
+	}
+	text := sc.raw.String()
+	if sc.key && !sc.schema.canStartProperty(text) {
+		return fmt.Errorf("no property allowed here starts with %q", text)
+	}
+	if !sc.key && !sc.schema.canStartString(text) {
+		return fmt.Errorf("no allowed value starts with %q", text)
+	}
+	return nil
+}
+
+// finish checks a completed scalar against its schema.
+func (v *Validator) finish(sc *scalar) error {
+	var value any
+	switch sc.kind {
+	case kindString:
+		var s string
+		if err := json.Unmarshal([]byte(`"`+sc.raw.String()+`"`), &s); err != nil {
+			return fmt.Errorf("invalid string: %v", err)
+		}
+		value = s
+	case kindNumber:
+		var n float64
+		if err := json.Unmarshal([]byte(sc.raw.String()), &n); err != nil {
+			return fmt.Errorf("invalid number: %v", err)
+		}
+		if sc.schema.integerOnly() && n != math.Trunc(n) {
+			return fmt.Errorf("%s where the schema expects an integer", sc.raw.String())
+		}
+		value = n
+	case kindLiteral:
+		switch sc.literal {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		}
+	}
+
+	if sc.key {
+		f := v.stack[len(v.stack)-1]
+		name := value.(string)
+		s, ok := f.schema.property(name)
+		if !ok {
+			return fmt.Errorf("unexpected property %q", name)
+		}
+		f.value = s
+		f.seen[name] = true
+		return nil
+	}
+	if sc.schema != nil && len(sc.schema.enum) > 0 && !contains(sc.schema.enum, value) {
+		return fmt.Errorf("%v is not one of the allowed values", value)
+	}
+	if len(v.stack) == 0 {
+		v.done = true
+	}
+	return nil
+}
+
+// close ends the innermost container.
+func (v *Validator) close() error {
+	f := v.stack[len(v.stack)-1]
+	if !f.array && f.schema != nil {
+		for _, name := range f.schema.required {
+			if !f.seen[name] {
+				return fmt.Errorf("missing required property %q", name)
+			}
+		}
+	}
+	v.stack = v.stack[:len(v.stack)-1]
+	if len(v.stack) == 0 {
+		v.done = true
+	}
+	return nil
+}
+
+func contains(values []any, value any) bool {
+	for _, e := range values {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func kindOf(r rune) string {
+	switch {
+	case r == '{':
+		return "object"
+	case r == '[':
+		return "array"
+	case r == '"':
+		return "string"
+	case r == 't', r == 'f':
+		return "boolean"
+	case r == 'n':
+		return "null"
+	case r == '-', r >= '0' && r <= '9':
+		return "number"
+	}
+	return ""
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,98 @@
+package jsonstream
+
+import (
+	"errors"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer"},
+		"role": {"type": "string", "enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"nickname": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+	},
+	"required": ["name"],
+	"additionalProperties": false
+}`
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		chunks []string
+		ok     bool // every chunk accepted
+	}{
+		{"valid in pieces", personSchema, []string{` {"na`, `me": "Ada", "age`, `": 36, "role": "adm`, `in", "tags": ["x", "y\"z"]}`, "\n"}, true},
+		{"unconstrained subschema", personSchema, []string{`{"name": "a", "nickname": null}`}, true},
+		{"escaped property name", personSchema, []string{`{"n\u0061me": "a"}`}, true},
+		{"prose before JSON", personSchema, []string{`Sure! Here it is: {`}, false},
+		{"code fence", personSchema, []string{"```json\n{}"}, false},
+		{"text after the document", personSchema, []string{`{"name": "a"}`, ` Hope this helps`}, false},
+		{"unknown property", personSchema, []string{`{"name": "a", "emai`}, false},
+		{"wrong type", personSchema, []string{`{"name": 42`}, false},
+		{"fractional integer", personSchema, []string{`{"name": "a", "age": 3.5,`}, false},
+		{"value outside enum", personSchema, []string{`{"name": "a", "role": "ro`}, false},
+		{"missing required property", personSchema, []string{`{"age": 3}`}, false},
+		{"array items", personSchema, []string{`{"name": "a", "tags": ["x", 1]`}, false},
+		{"json_object", `{"type": "object"}`, []string{`{"anything": [1, {"goes": true}]}`}, true},
+		{"json_object given an array", `{"type": "object"}`, []string{`[1]`}, false},
+		{"malformed number", `{}`, []string{`{"a": 01}`}, false},
+		{"malformed literal", `{}`, []string{`{"a": tru }`}, false},
+		{"unescaped newline", `{}`, []string{"{\"a\": \"x\ny\"}"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator([]byte(tt.schema))
+			var err error
+			for _, c := range tt.chunks {
+				if _, err = v.WriteString(c); err != nil {
+					break
+				}
+			}
+			if (err == nil) != tt.ok {
+				t.Errorf("Expected ok=%v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestValidator_AcceptedPrefix(t *testing.T) {
+	v := NewValidator([]byte(personSchema))
+	n, err := v.WriteString(`{"name": "Ada"} Done!`)
+	var derailed *Error
+	if !errors.As(err, &derailed) || derailed.Offset != 16 {
+		t.Fatalf("Expected a derailment at character 16, got %v", err)
+	}
+	if n != 16 || !v.Complete() {
+		t.Errorf("Expected the whole document accepted, got %d bytes (complete=%v)", n, v.Complete())
+	}
+	if _, again := v.WriteString("}"); again != err {
+		t.Errorf("Expected later writes to keep failing, got %v", again)
+	}
+}
+
+func TestValidator_Started(t *testing.T) {
+	v := NewValidator([]byte(`{"type": "object"}`))
+	if _, err := v.WriteString("\n  "); err != nil || v.Started() {
+		t.Fatalf("Expected leading whitespace not to start the document, got %v", err)
+	}
+	if _, err := v.WriteString("Sure"); err == nil || v.Started() {
+		t.Fatalf("Expected derailing text not to start the document, got %v", err)
+	}
+	v = NewValidator([]byte(`{"type": "object"}`))
+	if _, err := v.WriteString("{"); err != nil || !v.Started() {
+		t.Errorf("Expected the document started, got %v", err)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	if err := (&Policy{Retries: MaxRetries + 1}).Validate(); err == nil {
+		t.Error("Expected too many retries to be rejected")
+	}
+	if got := (&Policy{}).MaxAttempts(); got != 1+DefaultRetries {
+		t.Errorf("Expected %d attempts by default, got %d", 1+DefaultRetries, got)
+	}
+}
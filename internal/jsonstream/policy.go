@@ -0,0 +1,33 @@
+package jsonstream
+
+import "fmt"
+
+// Retry bounds for Policy.Retries.
+const (
+	DefaultRetries = 1
+	MaxRetries     = 3
+)
+
+// Policy turns on validation of a tenant's streamed structured output.
+// Streams that derail before anything but whitespace was relayed are
+// restarted up to Retries times (default 1); later derailments end the
+// stream with an error.
+type Policy struct {
+	Retries int `json:"retries,omitempty"`
+}
+
+// Validate reports settings the gateway can't act on.
+func (p *Policy) Validate() error {
+	if p.Retries < 0 || p.Retries > MaxRetries {
+		return fmt.Errorf("stream validation retries must be between 0 and %d", MaxRetries)
+	}
+	return nil
+}
+
+// MaxAttempts is how many times a stream may be started, counting retries.
+func (p *Policy) MaxAttempts() int {
+	if p.Retries == 0 {
+		return 1 + DefaultRetries
+	}
+	return 1 + p.Retries
+}
@@ -0,0 +1,162 @@
+package jsonstream
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	kindString = iota
+	kindNumber
+	kindLiteral
+)
+
+var literals = map[rune]string{'t': "true", 'f': "false", 'n': "null"}
+
+// Number grammar states.
+const (
+	numSign     = iota // after -
+	numZero            // a leading 0
+	numInt             // integer digits
+	numDot             // after .
+	numFrac            // fraction digits
+	numExp             // after e
+	numExpSign         // after the exponent's sign
+	numExpDigit        // exponent digits
+)
+
+// scalar is a string, number or literal being scanned.
+type scalar struct {
+	kind   int
+	key    bool    // an object's property name
+	schema *schema // for keys, the object's schema
+	raw    strings.Builder
+
+	// strings
+	escape  int  // 0 outside an escape, 1 after \, 2-5 reading \u hex digits
+	escaped bool // held an escape, so raw isn't the decoded text
+
+	literal string
+	num     int
+}
+
+// next consumes r. done reports the scalar is complete; reprocess that r
+// ended a number and belongs to what follows it.
+func (s *scalar) next(r rune) (done, reprocess bool, err error) {
+	switch s.kind {
+	case kindString:
+		return s.nextString(r)
+	case kindLiteral:
+		want := s.literal[s.raw.Len()]
+		if r != rune(want) {
+			return false, false, fmt.Errorf("unexpected %q in %s", r, s.literal)
+		}
+		s.raw.WriteRune(r)
+		return s.raw.Len() == len(s.literal), false, nil
+	default:
+		return s.nextNumber(r)
+	}
+}
+
+func (s *scalar) nextString(r rune) (bool, bool, error) {
+	switch {
+	case s.escape == 1:
+		switch r {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			s.escape = 0
+		case 'u':
+			s.escape = 2
+		default:
+			return false, false, fmt.Errorf("invalid escape \\%c", r)
+		}
+	case s.escape > 1:
+		if !isHex(r) {
+			return false, false, fmt.Errorf("invalid \\u escape")
+		}
+		if s.escape++; s.escape == 6 {
+			s.escape = 0
+		}
+	case r == '\\':
+		s.escape = 1
+		s.escaped = true
+	case r == '"':
+		return true, false, nil
+	case r < 0x20:
+		return false, false, fmt.Errorf("unescaped control character in string")
+	}
+	s.raw.WriteRune(r)
+	return false, false, nil
+}
+
+func (s *scalar) nextNumber(r rune) (bool, bool, error) {
+	digit := r >= '0' && r <= '9'
+	if s.raw.Len() == 0 {
+		if r == '-' {
+			s.num = numSign
+		} else if r == '0' {
+			s.num = numZero
+		} else {
+			s.num = numInt
+		}
+		s.raw.WriteRune(r)
+		return false, false, nil
+	}
+
+	next := -1
+	switch s.num {
+	case numSign:
+		if r == '0' {
+			next = numZero
+		} else if digit {
+			next = numInt
+		}
+	case numZero, numInt:
+		switch {
+		case digit && s.num == numInt:
+			next = numInt
+		case r == '.':
+			next = numDot
+		case r == 'e' || r == 'E':
+			next = numExp
+		}
+	case numDot, numFrac:
+		switch {
+		case digit:
+			next = numFrac
+		case (r == 'e' || r == 'E') && s.num == numFrac:
+			next = numExp
+		}
+	case numExp:
+		if r == '+' || r == '-' {
+			next = numExpSign
+		} else if digit {
+			next = numExpDigit
+		}
+	case numExpSign, numExpDigit:
+		if digit {
+			next = numExpDigit
+		}
+	}
+	if next >= 0 {
+		s.num = next
+		s.raw.WriteRune(r)
+		return false, false, nil
+	}
+	if !s.complete() {
+		return false, false, fmt.Errorf("unexpected %q in number %s", r, s.raw.String())
+	}
+	return true, true, nil
+}
+
+// complete reports whether a number scanned so far could end here.
+func (s *scalar) complete() bool {
+	switch s.num {
+	case numZero, numInt, numFrac, numExpDigit:
+		return true
+	}
+	return false
+}
+
+func isHex(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+}
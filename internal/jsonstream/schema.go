@@ -0,0 +1,148 @@
+package jsonstream
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// schema is the subset of JSON Schema a stream can be checked against as it
+// arrives: types, properties, required, additionalProperties, items and
+// enum. Anything it can't follow (anyOf, $ref, ...) leaves that part of the
+// document unconstrained, so the validator never rejects output the schema
+// would have accepted. A nil *schema accepts any value.
+type schema struct {
+	types      []string
+	properties map[string]*schema
+	required   []string
+	closed     bool    // additionalProperties: false
+	extra      *schema // additionalProperties as a schema
+	items      *schema
+	enum       []any
+}
+
+// unsupported are keywords that make a subschema unconstrained.
+var unsupported = []string{"$ref", "anyOf", "oneOf", "allOf", "not", "if", "const", "prefixItems"}
+
+func compile(raw json.RawMessage) *schema {
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(raw, &doc) != nil {
+		return nil
+	}
+	for _, k := range unsupported {
+		if _, ok := doc[k]; ok {
+			return nil
+		}
+	}
+
+	s := &schema{}
+	if t, ok := doc["type"]; ok {
+		var one string
+		if json.Unmarshal(t, &one) == nil {
+			s.types = []string{one}
+		} else if json.Unmarshal(t, &s.types) != nil {
+			return nil
+		}
+	}
+	if props, ok := doc["properties"]; ok {
+		var m map[string]json.RawMessage
+		if json.Unmarshal(props, &m) == nil {
+			s.properties = make(map[string]*schema, len(m))
+			for name, p := range m {
+				s.properties[name] = compile(p)
+			}
+		}
+	}
+	if req, ok := doc["required"]; ok {
+		_ = json.Unmarshal(req, &s.required)
+	}
+	if add, ok := doc["additionalProperties"]; ok {
+		if strings.TrimSpace(string(add)) == "false" {
+			s.closed = true
+		} else {
+			s.extra = compile(add)
+		}
+	}
+	if items, ok := doc["items"]; ok {
+		s.items = compile(items)
+	}
+	if enum, ok := doc["enum"]; ok {
+		_ = json.Unmarshal(enum, &s.enum)
+	}
+	return s
+}
+
+// allows reports whether the schema permits a value of kind, one of
+// "object", "array", "string", "number", "boolean" or "null".
+func (s *schema) allows(kind string) bool {
+	if s == nil || len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == kind || (kind == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// integerOnly reports whether numbers must be whole.
+func (s *schema) integerOnly() bool {
+	if s == nil {
+		return false
+	}
+	integer := false
+	for _, t := range s.types {
+		switch t {
+		case "number":
+			return false
+		case "integer":
+			integer = true
+		}
+	}
+	return integer
+}
+
+// property is the schema of an object's value under name, and whether the
+// object may have it at all.
+func (s *schema) property(name string) (*schema, bool) {
+	if s == nil {
+		return nil, true
+	}
+	if p, ok := s.properties[name]; ok {
+		return p, true
+	}
+	return s.extra, !s.closed
+}
+
+// canStartProperty reports whether some allowed property name starts with prefix.
+func (s *schema) canStartProperty(prefix string) bool {
+	if s == nil || !s.closed {
+		return true
+	}
+	for name := range s.properties {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// canStartString reports whether some allowed string starts with prefix.
+func (s *schema) canStartString(prefix string) bool {
+	if s == nil || len(s.enum) == 0 {
+		return true
+	}
+	for _, e := range s.enum {
+		if str, ok := e.(string); ok && strings.HasPrefix(str, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schema) elem() *schema {
+	if s == nil {
+		return nil
+	}
+	return s.items
+}
@@ -0,0 +1,156 @@
+// Package language detects the natural language of model output. Detection
+// is deliberately conservative: it reports a language only when the text
+// clearly matches one, so callers can treat "" as "don't act".
+package language
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// minLetters is the least text worth classifying; shorter replies ("OK",
+// a number, a name) carry too little signal. Han, kana and Hangul
+// characters count as wideLetter letters since each carries a syllable or
+// a word.
+const (
+	minLetters = 20
+	wideLetter = 3
+)
+
+// names are the languages Detect can report, by ISO 639-1 code.
+var names = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"zh": "Chinese",
+}
+
+// Name returns the English name of a supported language code.
+func Name(code string) (string, bool) {
+	name, ok := names[code]
+	return name, ok
+}
+
+// scripts identify languages written in their own script. Japanese is
+// checked before Chinese since Japanese text mixes kana with Han.
+var scripts = []struct {
+	code   string
+	tables []*unicode.RangeTable
+}{
+	{"ja", []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana}},
+	{"ko", []*unicode.RangeTable{unicode.Hangul}},
+	{"zh", []*unicode.RangeTable{unicode.Han}},
+	{"ru", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"ar", []*unicode.RangeTable{unicode.Arabic}},
+	{"he", []*unicode.RangeTable{unicode.Hebrew}},
+	{"el", []*unicode.RangeTable{unicode.Greek}},
+	{"th", []*unicode.RangeTable{unicode.Thai}},
+	{"hi", []*unicode.RangeTable{unicode.Devanagari}},
+}
+
+// stopwords are frequent function words of the Latin-script languages.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "with", "for", "this", "was", "you", "not", "be", "have", "on", "what", "can"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "no", "se", "del", "lo", "como", "más", "pero", "está"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "que", "en", "du", "pour", "pas", "dans", "ce", "qui", "sur", "avec", "au", "il", "je", "vous"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des", "auf", "für", "es", "ich", "sie", "dem", "auch"},
+	"it": {"il", "lo", "la", "di", "che", "e", "è", "un", "una", "per", "non", "con", "del", "della", "sono", "gli", "le", "si", "come", "più"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "é", "um", "uma", "do", "da", "em", "não", "para", "com", "por", "se", "mais", "no", "na"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "op", "te", "zijn", "met", "voor", "ik", "je", "die", "er", "ook", "maar"},
+}
+
+var (
+	words     map[string][]string // word -> languages it is a stopword of
+	codeBlock = regexp.MustCompile("(?s)```.*?(```|$)")
+	inline    = regexp.MustCompile("`[^`]*`")
+	url       = regexp.MustCompile(`https?://\S+`)
+)
+
+func init() {
+	words = make(map[string][]string)
+	for code, list := range stopwords {
+		for _, w := range list {
+			words[w] = append(words[w], code)
+		}
+	}
+}
+
+// Detect returns the ISO 639-1 code of text's language, or "" when the text
+// is too short or doesn't clearly match a supported language. Code and URLs
+// are ignored.
+func Detect(text string) string {
+	text = codeBlock.ReplaceAllString(text, " ")
+	text = inline.ReplaceAllString(text, " ")
+	text = url.ReplaceAllString(text, " ")
+
+	letters, size := 0, 0
+	counts := make([]int, len(scripts))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		size++
+		for i, s := range scripts {
+			if unicode.In(r, s.tables...) {
+				counts[i]++
+				if s.code == "ja" || s.code == "ko" || s.code == "zh" {
+					size += wideLetter - 1
+				}
+				break
+			}
+		}
+	}
+	if size < minLetters {
+		return ""
+	}
+	// Any kana makes Han text Japanese.
+	if counts[0] > 0 && counts[0]+counts[2] > letters*3/10 {
+		return "ja"
+	}
+	for i, s := range scripts[1:] {
+		if counts[i+1] > letters*3/10 {
+			return s.code
+		}
+	}
+	return detectLatin(text)
+}
+
+// detectLatin scores text by stopword hits and reports the best language
+// only when it leads the runner-up clearly.
+func detectLatin(text string) string {
+	scores := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for _, code := range words[w] {
+			scores[code]++
+		}
+	}
+	best, first, second := "", 0, 0
+	for code, n := range scores {
+		switch {
+		case n > first:
+			best, first, second = code, n, first
+		case n > second:
+			second = n
+		}
+	}
+	if first < 3 || first*2 < second*3 {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The quick answer is that you can cancel the order from your account page.", "en"},
+		{"La respuesta corta es que puedes cancelar el pedido desde la página de tu cuenta.", "es"},
+		{"La réponse courte est que vous pouvez annuler la commande dans votre compte.", "fr"},
+		{"Die kurze Antwort ist, dass Sie die Bestellung in Ihrem Konto stornieren können und das ist nicht schwer.", "de"},
+		{"ご注文はアカウントページからキャンセルできます。", "ja"},
+		{"您可以在帐户页面取消订单，操作非常简单。", "zh"},
+		{"Вы можете отменить заказ на странице своего аккаунта.", "ru"},
+		{"OK", ""},
+		{"```go\nfunc main() { fmt.Println(\"hello\") }\n```", ""},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	valid := []Policy{
+		{Language: "en"},
+		{Language: "de", Mode: ModeRetry},
+		{Language: "fr", Mode: ModeTranslate, TranslationModel: "gpt-4o-mini"},
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", p, err)
+		}
+	}
+	invalid := []Policy{
+		{Language: "xx"},
+		{Language: "en", Mode: ModeTranslate},
+		{Language: "en", Mode: "rewrite"},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", p)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package language
+
+import "fmt"
+
+// Ways a Policy brings a response into the required language.
+const (
+	// ModeRetry re-asks the same model once with a firmer instruction.
+	ModeRetry = "retry"
+	// ModeTranslate passes the response through TranslationModel.
+	ModeTranslate = "translate"
+)
+
+// Policy requires a tenant's responses in one language.
+type Policy struct {
+	Language         string `json:"language"`                    // ISO 639-1, e.g. "en"
+	Mode             string `json:"mode,omitempty"`              // retry (default) or translate
+	TranslationModel string `json:"translation_model,omitempty"` // required for translate
+}
+
+// Validate reports settings Enforce can't act on.
+func (p *Policy) Validate() error {
+	if _, ok := names[p.Language]; !ok {
+		return fmt.Errorf("unsupported output language %q", p.Language)
+	}
+	switch p.Mode {
+	case "", ModeRetry:
+	case ModeTranslate:
+		if p.TranslationModel == "" {
+			return fmt.Errorf("output language mode %q needs a translation_model", ModeTranslate)
+		}
+	default:
+		return fmt.Errorf("unknown output language mode %q", p.Mode)
+	}
+	return nil
+}
+
+// Instruction is the system prompt a retry adds.
+func Instruction(code string) string {
+	return fmt.Sprintf("You must respond only in %s, whatever language the user writes in.", names[code])
+}
+
+// TranslationPrompt is the system prompt for translating a response.
+func TranslationPrompt(code string) string {
+	return fmt.Sprintf("Translate the user's message into %s. Reply with the translation only, keeping its formatting, code and links unchanged.", names[code])
+}
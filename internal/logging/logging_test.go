@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := NewContext(context.Background(), logger)
+	ctx = With(ctx, "request_id", "req-1")
+	ctx = With(ctx, "tenant_id", "tenant-1")
+
+	FromContext(ctx).Debug("dropped")
+	FromContext(ctx).Info("completion", "status", 200)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON line at info, got %q", buf.String())
+	}
+	if line["msg"] != "completion" || line["request_id"] != "req-1" || line["tenant_id"] != "tenant-1" || line["status"] != float64(200) {
+		t.Errorf("Expected the request's fields on the line, got %v", line)
+	}
+}
+
+func TestNew_RejectsUnknownSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+	if _, err := New(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
@@ -0,0 +1,260 @@
+// Package migrate applies the SQL migrations embedded in the gateway binary
+// and records each in schema_migrations, so every deployment builds its
+// schema the same way and the gateway can tell at startup whether the
+// database matches the build.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrPending means the database is behind the binary's migrations.
+	ErrPending = errors.New("database schema is out of date")
+	// ErrUntracked means the database has the gateway's tables but no
+	// migration history, e.g. it was migrated by hand before; it needs a
+	// baseline before migrations can be applied.
+	ErrUntracked = errors.New("database has no migration history")
+)
+
+// lockKey serializes migrations between gateway processes starting at once.
+const lockKey = 7242010
+
+var fileName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.sql$`)
+
+// Migration is one schema change, applied in Version order.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Load reads the NNN_name.sql files at the root of fsys in version order.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	seen := make(map[int]string)
+	var out []Migration
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %q (want NNN_name.sql)", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", e.Name())
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, e.Name(), version)
+		}
+		seen[version] = e.Name()
+		sql, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Name(), err)
+		}
+		out = append(out, Migration{Version: version, Name: m[2], SQL: string(sql)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+type DB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type Migrator struct {
+	db         DB
+	migrations []Migration
+}
+
+// New loads the migrations in fsys to apply to db.
+func New(db DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Status is how the database compares to the binary's migrations.
+type Status struct {
+	Current int         // highest applied version, 0 for none
+	Latest  int         // highest version the binary has
+	Pending []Migration // not yet applied, in order
+	// Untracked is set when the database has tables but no history.
+	Untracked bool
+}
+
+// Status reports which migrations have been applied.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{}
+	for v := range applied {
+		s.Current = max(s.Current, v)
+	}
+	for _, mig := range m.migrations {
+		s.Latest = max(s.Latest, mig.Version)
+		if !applied[mig.Version] {
+			s.Pending = append(s.Pending, mig)
+		}
+	}
+	if len(applied) == 0 {
+		if s.Untracked, err = m.hasTables(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Check returns ErrPending or ErrUntracked, wrapped with the versions
+// involved, unless every migration has been applied. A database ahead of
+// the binary, e.g. during a rolling deploy, passes.
+func (m *Migrator) Check(ctx context.Context) error {
+	s, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.Untracked {
+		return fmt.Errorf("%w: run `gateway migrate baseline <version>` with the last migration applied by hand", ErrUntracked)
+	}
+	if len(s.Pending) > 0 {
+		return fmt.Errorf("%w: at version %d, %d migration(s) pending up to %d; run `gateway migrate`",
+			ErrPending, s.Current, len(s.Pending), s.Latest)
+	}
+	return nil
+}
+
+// Up applies the pending migrations in order, each in its own transaction,
+// and returns those it applied. It stops at the first that fails.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	s, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.Untracked {
+		return nil, fmt.Errorf("%w: run `gateway migrate baseline <version>` first", ErrUntracked)
+	}
+	var done []Migration
+	for _, mig := range s.Pending {
+		ok, err := m.apply(ctx, mig, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases whose schema was created by hand.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, err := m.apply(ctx, mig, false); err != nil {
+			return fmt.Errorf("migration %03d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply records mig, running its SQL first when run is set. It reports
+// false when another process recorded it first.
+func (m *Migrator) apply(ctx context.Context, mig Migration, run bool) (bool, error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, lockKey); err != nil {
+		return false, err
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, mig.Version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if run {
+		// Files hold several statements, which only the simple protocol runs.
+		if _, err := tx.Exec(ctx, mig.SQL, pgx.QueryExecModeSimpleProtocol); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version     INT PRIMARY KEY,
+			name        TEXT NOT NULL,
+			applied_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`
+	if _, err := m.db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]bool, error) {
+	rows, err := m.db.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// hasTables reports whether the gateway's first table exists already.
+func (m *Migrator) hasTables(ctx context.Context) (bool, error) {
+	var exists bool
+	if err := m.db.QueryRow(ctx, `SELECT to_regclass('api_keys') IS NOT NULL`).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return exists, nil
+}
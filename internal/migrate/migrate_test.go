@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/vnmchuo/llm-gateway/migrations"
+)
+
+func TestLoad_OrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_add_b.sql":    {Data: []byte("ALTER TABLE b ADD COLUMN c INT;")},
+		"002_create_b.sql": {Data: []byte("CREATE TABLE b (id INT);")},
+		"001_create_a.sql": {Data: []byte("CREATE TABLE a (id INT);")},
+		"README.md":        {Data: []byte("not a migration")},
+	}
+	list, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(list) != 3 || list[0].Version != 1 || list[1].Version != 2 || list[2].Version != 10 {
+		t.Fatalf("Expected versions 1, 2, 10, got %+v", list)
+	}
+	if list[2].Name != "add_b" || list[2].SQL != "ALTER TABLE b ADD COLUMN c INT;" {
+		t.Errorf("Unexpected migration %+v", list[2])
+	}
+}
+
+func TestLoad_RejectsBadFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version": {"create_a.sql": {}},
+		"zero":       {"000_create_a.sql": {}},
+		"duplicate":  {"001_create_a.sql": {}, "1_create_b.sql": {}},
+	}
+	for name, fsys := range tests {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	list, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for i, m := range list {
+		if m.Version != i+1 {
+			t.Fatalf("Expected migrations numbered without gaps, %03d_%s is at position %d", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCapacityWindowNotFound is returned when deleting a window that doesn't exist.
+var ErrCapacityWindowNotFound = errors.New("capacity window not found")
+
+// Capacity levels a calendar window declares for a provider.
+const (
+	// CapacityNone keeps the router off the provider, e.g. for maintenance.
+	CapacityNone = "none"
+	// CapacityReduced tries the provider after those at full capacity.
+	CapacityReduced = "reduced"
+)
+
+// CapacityWindow is a known change in a provider's capacity. It is either a
+// one-off period from StartsAt to EndsAt, e.g. maintenance or a quota cut,
+// or recurs weekly on Days from Start to End as a ModelWindow does, e.g.
+// reserved throughput that only exists during office hours. The router
+// applies it LeadMinutes before it starts, moving traffic ahead of time
+// instead of waiting for the provider to fail.
+type CapacityWindow struct {
+	ID       int64  `json:"id"`
+	Provider string `json:"provider"`
+	// Model limits the window to one of the provider's models; empty
+	// covers all of them.
+	Model    string `json:"model,omitempty"`
+	Capacity string `json:"capacity"`
+	Reason   string `json:"reason,omitempty"`
+
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start,omitempty"`
+	End      string   `json:"end,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+
+	LeadMinutes int       `json:"lead_minutes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate rejects windows the router couldn't apply.
+func (w *CapacityWindow) Validate() error {
+	if w.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if w.Capacity != CapacityNone && w.Capacity != CapacityReduced {
+		return fmt.Errorf("invalid capacity %q: want %s or %s", w.Capacity, CapacityNone, CapacityReduced)
+	}
+	if w.LeadMinutes < 0 {
+		return fmt.Errorf("lead_minutes must not be negative")
+	}
+
+	oneOff := w.StartsAt != nil || w.EndsAt != nil
+	recurring := w.Start != "" || w.End != "" || len(w.Days) > 0 || w.Timezone != ""
+	switch {
+	case oneOff && recurring:
+		return fmt.Errorf("set either starts_at and ends_at or a recurring start and end, not both")
+	case oneOff:
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(*w.StartsAt) {
+			return fmt.Errorf("ends_at must be after starts_at")
+		}
+		return nil
+	case !recurring:
+		return fmt.Errorf("set starts_at and ends_at, or a recurring start and end")
+	}
+
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	for _, d := range w.Days {
+		if !isWeekday(d) {
+			return fmt.Errorf("invalid day %q: want mon..sun", d)
+		}
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q", w.Timezone)
+		}
+	}
+	return nil
+}
+
+// Covers reports whether the window applies to model on provider.
+func (w *CapacityWindow) Covers(provider, model string) bool {
+	return w.Provider == provider && (w.Model == "" || w.Model == model)
+}
+
+// Active reports whether the router applies the window at now: it is in
+// progress or starts within its lead time.
+func (w *CapacityWindow) Active(now time.Time) bool {
+	lead := time.Duration(w.LeadMinutes) * time.Minute
+	if w.StartsAt != nil && w.EndsAt != nil {
+		return !now.Before(w.StartsAt.Add(-lead)) && now.Before(*w.EndsAt)
+	}
+
+	mw := w.recurrence()
+	if open, err := mw.contains(now); err != nil || open {
+		return open
+	}
+	return lead > 0 && mw.opensWithin(now, lead)
+}
+
+func (w *CapacityWindow) recurrence() ModelWindow {
+	return ModelWindow{Days: w.Days, Start: w.Start, End: w.End, Timezone: w.Timezone}
+}
+
+// opensWithin reports whether the window starts after now and no later than
+// now+lead.
+func (w ModelWindow) opensWithin(now time.Time, lead time.Duration) bool {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false
+		}
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	local := now.In(loc)
+	for d := 0; d <= int(lead/(24*time.Hour))+1; d++ {
+		at := time.Date(local.Year(), local.Month(), local.Day()+d, start/60, start%60, 0, 0, loc)
+		if at.After(now) && !at.After(now.Add(lead)) && w.allowsDay(at.Weekday()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWeekday(d string) bool {
+	switch strings.ToLower(d) {
+	case "mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return true
+	}
+	return false
+}
+
+type CalendarStore interface {
+	// List returns the windows that haven't ended yet.
+	List(ctx context.Context) ([]*CapacityWindow, error)
+	Add(ctx context.Context, w *CapacityWindow) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// Calendar serves the provider capacity calendar from memory. Run reloads
+// it from the store, so windows added on another replica apply here too.
+type Calendar struct {
+	store    CalendarStore
+	interval time.Duration
+
+	mu      sync.RWMutex
+	windows []*CapacityWindow
+}
+
+func NewCalendar(store CalendarStore, interval time.Duration) *Calendar {
+	return &Calendar{store: store, interval: interval}
+}
+
+// Capacity returns the window that limits model on provider at now, the
+// most restrictive one when several do, or nil. A nil Calendar has none.
+func (c *Calendar) Capacity(provider, model string, now time.Time) *CapacityWindow {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var found *CapacityWindow
+	for _, w := range c.windows {
+		if !w.Covers(provider, model) || !w.Active(now) {
+			continue
+		}
+		if w.Capacity == CapacityNone {
+			return w
+		}
+		if found == nil {
+			found = w
+		}
+	}
+	return found
+}
+
+// List returns the stored windows that haven't ended yet.
+func (c *Calendar) List(ctx context.Context) ([]*CapacityWindow, error) {
+	return c.store.List(ctx)
+}
+
+// Add stores w and reloads the calendar.
+func (c *Calendar) Add(ctx context.Context, w *CapacityWindow) error {
+	if err := c.store.Add(ctx, w); err != nil {
+		return err
+	}
+	return c.Reload(ctx)
+}
+
+// Delete removes the window with id and reloads the calendar.
+func (c *Calendar) Delete(ctx context.Context, id int64) error {
+	if err := c.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	return c.Reload(ctx)
+}
+
+// Reload replaces the calendar's windows with the store's. On error the
+// previous windows stay in place.
+func (c *Calendar) Reload(ctx context.Context) error {
+	windows, err := c.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.windows = windows
+	c.mu.Unlock()
+	return nil
+}
+
+// Run reloads the calendar every interval until ctx is done.
+func (c *Calendar) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Reload(ctx); err != nil {
+				log.Printf("policy: failed to reload capacity calendar: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCapacityWindow_OneOffAppliesFromLeadTime(t *testing.T) {
+	start := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	w := &CapacityWindow{Provider: "openai", Capacity: CapacityNone, StartsAt: &start, EndsAt: &end, LeadMinutes: 15}
+	if err := w.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{start.Add(-time.Hour), false},
+		{start.Add(-10 * time.Minute), true},
+		{start.Add(time.Hour), true},
+		{end, false},
+	}
+	for _, tt := range tests {
+		if got := w.Active(tt.at); got != tt.want {
+			t.Errorf("Active(%s) = %v, want %v", tt.at.Format(time.Kitchen), got, tt.want)
+		}
+	}
+}
+
+func TestCapacityWindow_Recurring(t *testing.T) {
+	// Reserved capacity exists 09:00-17:00 Jakarta time on weekdays, so the
+	// provider runs reduced from 17:00 to 09:00.
+	w := &CapacityWindow{
+		Provider: "azure", Capacity: CapacityReduced,
+		Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "17:00", End: "09:00",
+		Timezone: "Asia/Jakarta", LeadMinutes: 30,
+	}
+	if err := w.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"wednesday noon", time.Date(2024, 6, 5, 5, 0, 0, 0, time.UTC), false},
+		{"within lead time", time.Date(2024, 6, 5, 9, 40, 0, 0, time.UTC), true},
+		{"wednesday night", time.Date(2024, 6, 5, 15, 0, 0, 0, time.UTC), true},
+		{"thursday early morning", time.Date(2024, 6, 5, 23, 0, 0, 0, time.UTC), true},
+		{"saturday evening", time.Date(2024, 6, 8, 11, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := w.Active(tt.at); got != tt.want {
+			t.Errorf("%s: Active = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCapacityWindow_Validate(t *testing.T) {
+	start := time.Now()
+	tests := []CapacityWindow{
+		{Capacity: CapacityNone, Start: "09:00", End: "17:00"},
+		{Provider: "openai", Capacity: "half", Start: "09:00", End: "17:00"},
+		{Provider: "openai", Capacity: CapacityNone},
+		{Provider: "openai", Capacity: CapacityNone, StartsAt: &start},
+		{Provider: "openai", Capacity: CapacityNone, Start: "9am", End: "17:00"},
+		{Provider: "openai", Capacity: CapacityNone, Start: "09:00", End: "17:00", Days: []string{"monday"}},
+		{Provider: "openai", Capacity: CapacityNone, Start: "09:00", End: "17:00", LeadMinutes: -5},
+	}
+	for i, w := range tests {
+		if err := w.Validate(); err == nil {
+			t.Errorf("case %d: expected %+v to be rejected", i, w)
+		}
+	}
+}
+
+type memoryCalendar struct {
+	windows []*CapacityWindow
+}
+
+func (m *memoryCalendar) List(ctx context.Context) ([]*CapacityWindow, error) { return m.windows, nil }
+
+func (m *memoryCalendar) Add(ctx context.Context, w *CapacityWindow) error {
+	w.ID = int64(len(m.windows) + 1)
+	m.windows = append(m.windows, w)
+	return nil
+}
+
+func (m *memoryCalendar) Delete(ctx context.Context, id int64) error { return nil }
+
+func TestCalendar_CapacityPrefersNone(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	end := start.Add(time.Hour)
+	c := NewCalendar(&memoryCalendar{}, time.Minute)
+	ctx := context.Background()
+	_ = c.Add(ctx, &CapacityWindow{Provider: "openai", Capacity: CapacityReduced, StartsAt: &start, EndsAt: &end})
+	_ = c.Add(ctx, &CapacityWindow{Provider: "openai", Model: "gpt-4o", Capacity: CapacityNone, StartsAt: &start, EndsAt: &end})
+
+	if w := c.Capacity("openai", "gpt-4o", time.Now()); w == nil || w.Capacity != CapacityNone {
+		t.Errorf("Expected no capacity for gpt-4o, got %+v", w)
+	}
+	if w := c.Capacity("openai", "gpt-4o-mini", time.Now()); w == nil || w.Capacity != CapacityReduced {
+		t.Errorf("Expected reduced capacity for gpt-4o-mini, got %+v", w)
+	}
+	if w := c.Capacity("claude", "", time.Now()); w != nil {
+		t.Errorf("Expected no window for claude, got %+v", w)
+	}
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+)
+
+// ErrModelNotAllowed is wrapped by ModelPolicy.Check rejections.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// ModelPolicy restricts which models a tenant may call. Entries are exact
+// names or path.Match patterns such as "gpt-4o*". Deny wins over allow; an
+// empty Allow list permits everything not denied.
+type ModelPolicy struct {
+	TenantID  string    `json:"tenant_id"`
+	Allow     []string  `json:"allow"`
+	Deny      []string  `json:"deny"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Check returns an error describing why model is not permitted, or nil.
+func (p *ModelPolicy) Check(model string) error {
+	if p == nil {
+		return nil
+	}
+	if model == "" {
+		if len(p.Allow) > 0 {
+			return fmt.Errorf("%w: model is required, tenant may only use %v", ErrModelNotAllowed, p.Allow)
+		}
+		return nil
+	}
+	if matchAny(p.Deny, model) {
+		return fmt.Errorf("%w: %s is denied for this tenant", ErrModelNotAllowed, model)
+	}
+	if len(p.Allow) > 0 && !matchAny(p.Allow, model) {
+		return fmt.Errorf("%w: %s is not in this tenant's allow list %v", ErrModelNotAllowed, model, p.Allow)
+	}
+	return nil
+}
+
+// Validate rejects malformed patterns before they are stored.
+func (p *ModelPolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid model pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type Store interface {
+	// Get returns the tenant's policy, or an unrestricted policy if none is stored.
+	Get(ctx context.Context, tenantID string) (*ModelPolicy, error)
+	Put(ctx context.Context, policy *ModelPolicy) error
+	Delete(ctx context.Context, tenantID string) error
+}
+
+// CachedStore keeps policies in memory for ttl to keep lookups off the hot path.
+type CachedStore struct {
+	store Store
+	ttl   time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	policy    *ModelPolicy
+	expiresAt time.Time
+}
+
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{store: store, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (c *CachedStore) Get(ctx context.Context, tenantID string) (*ModelPolicy, error) {
+	c.mu.RLock()
+	e, ok := c.entries[tenantID]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.policy, nil
+	}
+
+	p, err := c.store.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[tenantID] = cacheEntry{policy: p, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return p, nil
+}
+
+func (c *CachedStore) Put(ctx context.Context, policy *ModelPolicy) error {
+	if err := c.store.Put(ctx, policy); err != nil {
+		return err
+	}
+	c.invalidate(policy.TenantID)
+	return nil
+}
+
+func (c *CachedStore) Delete(ctx context.Context, tenantID string) error {
+	if err := c.store.Delete(ctx, tenantID); err != nil {
+		return err
+	}
+	c.invalidate(tenantID)
+	return nil
+}
+
+func (c *CachedStore) invalidate(tenantID string) {
+	c.mu.Lock()
+	delete(c.entries, tenantID)
+	c.mu.Unlock()
+}
@@ -0,0 +1,38 @@
+package policy
+
+import "testing"
+
+func TestModelPolicy_Check(t *testing.T) {
+	trial := &ModelPolicy{Deny: []string{"gpt-4o"}}
+	restricted := &ModelPolicy{Allow: []string{"gpt-4o-mini", "gemini-*"}, Deny: []string{"gemini-1.5-pro"}}
+
+	tests := []struct {
+		name    string
+		policy  *ModelPolicy
+		model   string
+		allowed bool
+	}{
+		{"no policy", nil, "gpt-4o", true},
+		{"denied exact", trial, "gpt-4o", false},
+		{"deny is exact, not prefix", trial, "gpt-4o-mini", true},
+		{"allowed by pattern", restricted, "gemini-2.0-flash", true},
+		{"deny wins over allow", restricted, "gemini-1.5-pro", false},
+		{"not in allow list", restricted, "claude-3", false},
+		{"empty model with allow list", restricted, "", false},
+		{"empty model without allow list", trial, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.model)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Check(%q) = %v, want allowed=%v", tt.model, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestModelPolicy_ValidateRejectsBadPattern(t *testing.T) {
+	if err := (&ModelPolicy{Allow: []string{"gpt-["}}).Validate(); err == nil {
+		t.Error("Expected malformed pattern to be rejected")
+	}
+}
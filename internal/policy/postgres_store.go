@@ -0,0 +1,224 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, tenantID string) (*ModelPolicy, error) {
+	query := `
+		SELECT tenant_id, allowed_models, denied_models, updated_at
+		FROM tenant_model_policies
+		WHERE tenant_id = $1
+	`
+	var p ModelPolicy
+	err := s.db.QueryRow(ctx, query, tenantID).Scan(&p.TenantID, &p.Allow, &p.Deny, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &ModelPolicy{TenantID: tenantID}, nil
+		}
+		return nil, fmt.Errorf("failed to get model policy: %w", err)
+	}
+	return &p, nil
+}
+
+func (s *PostgresStore) Put(ctx context.Context, p *ModelPolicy) error {
+	query := `
+		INSERT INTO tenant_model_policies (tenant_id, allowed_models, denied_models, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET allowed_models = EXCLUDED.allowed_models, denied_models = EXCLUDED.denied_models, updated_at = NOW()
+		RETURNING updated_at
+	`
+	allow, deny := p.Allow, p.Deny
+	if allow == nil {
+		allow = []string{}
+	}
+	if deny == nil {
+		deny = []string{}
+	}
+	if err := s.db.QueryRow(ctx, query, p.TenantID, allow, deny).Scan(&p.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save model policy: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, tenantID string) error {
+	query := `DELETE FROM tenant_model_policies WHERE tenant_id = $1`
+	if _, err := s.db.Exec(ctx, query, tenantID); err != nil {
+		return fmt.Errorf("failed to delete model policy: %w", err)
+	}
+	return nil
+}
+
+type PostgresRoutingStore struct {
+	db DB
+}
+
+func NewPostgresRoutingStore(db DB) RoutingStore {
+	return &PostgresRoutingStore{db: db}
+}
+
+func (s *PostgresRoutingStore) Get(ctx context.Context, level, id string) (*RoutingPolicy, error) {
+	query := `
+		SELECT level, id, strategy, mode, max_cost_usd, substitute, updated_at
+		FROM routing_policies
+		WHERE level = $1 AND id = $2
+	`
+	p, err := scanRoutingPolicy(s.db.QueryRow(ctx, query, level, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &RoutingPolicy{Level: level, ID: id}, nil
+		}
+		return nil, fmt.Errorf("failed to get routing policy: %w", err)
+	}
+	return p, nil
+}
+
+func (s *PostgresRoutingStore) Put(ctx context.Context, p *RoutingPolicy) error {
+	query := `
+		INSERT INTO routing_policies (level, id, strategy, mode, max_cost_usd, substitute, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (level, id) DO UPDATE
+		SET strategy = EXCLUDED.strategy, mode = EXCLUDED.mode, max_cost_usd = EXCLUDED.max_cost_usd,
+			substitute = EXCLUDED.substitute, updated_at = NOW()
+		RETURNING updated_at
+	`
+	var maxCost *float64
+	var substitute bool
+	if p.MaxCost != nil {
+		maxCost, substitute = &p.MaxCost.USD, p.MaxCost.Substitute
+	}
+	if err := s.db.QueryRow(ctx, query, p.Level, p.ID, p.Strategy, p.Mode, maxCost, substitute).Scan(&p.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save routing policy: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresRoutingStore) Delete(ctx context.Context, level, id string) error {
+	query := `DELETE FROM routing_policies WHERE level = $1 AND id = $2`
+	if _, err := s.db.Exec(ctx, query, level, id); err != nil {
+		return fmt.Errorf("failed to delete routing policy: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresRoutingStore) List(ctx context.Context) ([]*RoutingPolicy, error) {
+	query := `
+		SELECT level, id, strategy, mode, max_cost_usd, substitute, updated_at
+		FROM routing_policies
+		ORDER BY level, id
+	`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routing policies: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*RoutingPolicy
+	for rows.Next() {
+		p, err := scanRoutingPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan routing policy: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func scanRoutingPolicy(row pgx.Row) (*RoutingPolicy, error) {
+	var p RoutingPolicy
+	var maxCost *float64
+	var substitute bool
+	if err := row.Scan(&p.Level, &p.ID, &p.Strategy, &p.Mode, &maxCost, &substitute, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if maxCost != nil {
+		p.MaxCost = &provider.CostCap{USD: *maxCost, Substitute: substitute}
+	}
+	return &p, nil
+}
+
+type PostgresCalendarStore struct {
+	db DB
+}
+
+func NewPostgresCalendarStore(db DB) CalendarStore {
+	return &PostgresCalendarStore{db: db}
+}
+
+func (s *PostgresCalendarStore) List(ctx context.Context) ([]*CapacityWindow, error) {
+	query := `
+		SELECT id, provider, model, capacity, reason, starts_at, ends_at, days, start_time, end_time,
+			timezone, lead_minutes, created_at
+		FROM provider_capacity_windows
+		WHERE ends_at IS NULL OR ends_at > NOW()
+		ORDER BY provider, id
+	`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list capacity windows: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*CapacityWindow
+	for rows.Next() {
+		var w CapacityWindow
+		if err := rows.Scan(&w.ID, &w.Provider, &w.Model, &w.Capacity, &w.Reason, &w.StartsAt, &w.EndsAt,
+			&w.Days, &w.Start, &w.End, &w.Timezone, &w.LeadMinutes, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan capacity window: %w", err)
+		}
+		out = append(out, &w)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresCalendarStore) Add(ctx context.Context, w *CapacityWindow) error {
+	query := `
+		INSERT INTO provider_capacity_windows (provider, model, capacity, reason, starts_at, ends_at, days,
+			start_time, end_time, timezone, lead_minutes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`
+	days := w.Days
+	if days == nil {
+		days = []string{}
+	}
+	if err := s.db.QueryRow(ctx, query, w.Provider, w.Model, w.Capacity, w.Reason, w.StartsAt, w.EndsAt, days,
+		w.Start, w.End, w.Timezone, w.LeadMinutes).Scan(&w.ID, &w.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save capacity window: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresCalendarStore) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM provider_capacity_windows WHERE id = $1`
+	tag, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete capacity window: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCapacityWindowNotFound
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Levels routing policies are set at, least specific first. LevelGateway is
+// the configuration (ROUTING_STRATEGY) and LevelTenant the tenant's
+// settings; the others are stored in a RoutingStore.
+const (
+	LevelGateway = "gateway"
+	LevelGlobal  = "global"
+	LevelPlan    = "plan"
+	LevelTenant  = "tenant"
+	LevelKey     = "key"
+)
+
+// Routing modes. Strict rejects models no provider serves with 404; lenient,
+// the default, routes them to the cheapest provider.
+const (
+	ModeStrict  = "strict"
+	ModeLenient = "lenient"
+)
+
+// Strategies are the routing strategies a policy can name.
+var Strategies = []string{"cost", "latency", "weighted", "priority"}
+
+// RoutingPolicy is how a level routes requests. Fields left unset inherit
+// from the level above; see MergeRouting.
+type RoutingPolicy struct {
+	Level     string            `json:"level"`
+	ID        string            `json:"id,omitempty"` // plan name or API key ID; empty for global
+	Strategy  string            `json:"strategy,omitempty"`
+	Mode      string            `json:"mode,omitempty"`
+	MaxCost   *provider.CostCap `json:"max_cost,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at,omitzero"`
+}
+
+// Validate rejects policies that can't be stored.
+func (p *RoutingPolicy) Validate() error {
+	switch p.Level {
+	case LevelGlobal:
+		if p.ID != "" {
+			return fmt.Errorf("the global routing policy has no id")
+		}
+	case LevelPlan, LevelKey:
+		if p.ID == "" {
+			return fmt.Errorf("a %s routing policy needs an id", p.Level)
+		}
+	default:
+		return fmt.Errorf("invalid level %q: want global, plan or key", p.Level)
+	}
+	if p.Strategy != "" && !slices.Contains(Strategies, p.Strategy) {
+		return fmt.Errorf("invalid strategy %q: want one of %v", p.Strategy, Strategies)
+	}
+	if p.Mode != "" && p.Mode != ModeStrict && p.Mode != ModeLenient {
+		return fmt.Errorf("invalid mode %q: want strict or lenient", p.Mode)
+	}
+	if p.MaxCost != nil && !(p.MaxCost.USD > 0) {
+		return fmt.Errorf("max_cost.usd must be greater than 0")
+	}
+	return nil
+}
+
+// EffectiveRouting is the routing policy a request gets once its levels are
+// merged, with the level each field came from.
+type EffectiveRouting struct {
+	Strategy string            `json:"strategy,omitempty"`
+	Mode     string            `json:"mode"`
+	MaxCost  *provider.CostCap `json:"max_cost,omitempty"`
+	// Sources maps each field set to the level that set it; for max_cost,
+	// the level with the lowest cap.
+	Sources map[string]string `json:"sources"`
+	// Levels are the policies merged, least specific first.
+	Levels []RoutingPolicy `json:"levels"`
+}
+
+// MergeRouting merges policies, least specific first. Strategy and mode
+// come from the most specific level that sets them. max_cost only ever
+// tightens: the lowest cap applies, substituting if any level allows it.
+func MergeRouting(levels ...RoutingPolicy) *EffectiveRouting {
+	e := &EffectiveRouting{Mode: ModeLenient, Sources: map[string]string{}, Levels: levels}
+	for _, p := range levels {
+		if p.Strategy != "" {
+			e.Strategy, e.Sources["strategy"] = p.Strategy, p.Level
+		}
+		if p.Mode != "" {
+			e.Mode, e.Sources["mode"] = p.Mode, p.Level
+		}
+		if p.MaxCost == nil {
+			continue
+		}
+		if e.MaxCost == nil {
+			e.MaxCost = &provider.CostCap{USD: p.MaxCost.USD}
+			e.Sources["max_cost"] = p.Level
+		} else if p.MaxCost.USD < e.MaxCost.USD {
+			e.MaxCost.USD = p.MaxCost.USD
+			e.Sources["max_cost"] = p.Level
+		}
+		e.MaxCost.Substitute = e.MaxCost.Substitute || p.MaxCost.Substitute
+	}
+	return e
+}
+
+type RoutingStore interface {
+	// Get returns the policy set at level for id, or an empty one.
+	Get(ctx context.Context, level, id string) (*RoutingPolicy, error)
+	Put(ctx context.Context, p *RoutingPolicy) error
+	Delete(ctx context.Context, level, id string) error
+	// List returns every stored policy by level and ID.
+	List(ctx context.Context) ([]*RoutingPolicy, error)
+}
+
+// RoutingResolver merges the routing policies that apply to a request.
+type RoutingResolver struct {
+	store RoutingStore
+
+	mu       sync.RWMutex
+	strategy string // the gateway's default
+}
+
+func NewRoutingResolver(store RoutingStore, defaultStrategy string) *RoutingResolver {
+	return &RoutingResolver{store: store, strategy: defaultStrategy}
+}
+
+// SetDefaultStrategy changes the gateway level's strategy, e.g. on a
+// configuration reload.
+func (r *RoutingResolver) SetDefaultStrategy(name string) {
+	r.mu.Lock()
+	r.strategy = name
+	r.mu.Unlock()
+}
+
+// With returns a resolver that resolves as if p were stored at its level,
+// e.g. to preview a change before storing it. An empty p previews deleting
+// the level's policy.
+func (r *RoutingResolver) With(p RoutingPolicy) *RoutingResolver {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return &RoutingResolver{store: overlayRoutingStore{RoutingStore: r.store, policy: p}, strategy: r.strategy}
+}
+
+// overlayRoutingStore reads policy in place of the stored one at its level.
+type overlayRoutingStore struct {
+	RoutingStore
+	policy RoutingPolicy
+}
+
+func (s overlayRoutingStore) Get(ctx context.Context, level, id string) (*RoutingPolicy, error) {
+	if level == s.policy.Level && id == s.policy.ID {
+		p := s.policy
+		return &p, nil
+	}
+	return s.RoutingStore.Get(ctx, level, id)
+}
+
+// Resolve merges, from least to most specific, the gateway's defaults and
+// the global, plan, tenant and key policies. plan and keyID may be empty.
+func (r *RoutingResolver) Resolve(ctx context.Context, plan string, tenant RoutingPolicy, keyID string) (*EffectiveRouting, error) {
+	r.mu.RLock()
+	levels := []RoutingPolicy{{Level: LevelGateway, Strategy: r.strategy}}
+	r.mu.RUnlock()
+
+	global, err := r.store.Get(ctx, LevelGlobal, "")
+	if err != nil {
+		return nil, err
+	}
+	levels = append(levels, *global)
+	if plan != "" {
+		p, err := r.store.Get(ctx, LevelPlan, plan)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, *p)
+	}
+	tenant.Level = LevelTenant
+	levels = append(levels, tenant)
+	if keyID != "" {
+		k, err := r.store.Get(ctx, LevelKey, keyID)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, *k)
+	}
+	return MergeRouting(levels...), nil
+}
+
+// CachedRoutingStore keeps policies in memory for ttl to keep lookups off
+// the hot path.
+type CachedRoutingStore struct {
+	store RoutingStore
+	ttl   time.Duration
+
+	mu      sync.RWMutex
+	entries map[routingKey]routingEntry
+}
+
+type routingKey struct {
+	level, id string
+}
+
+type routingEntry struct {
+	policy    *RoutingPolicy
+	expiresAt time.Time
+}
+
+func NewCachedRoutingStore(store RoutingStore, ttl time.Duration) *CachedRoutingStore {
+	return &CachedRoutingStore{store: store, ttl: ttl, entries: make(map[routingKey]routingEntry)}
+}
+
+func (c *CachedRoutingStore) Get(ctx context.Context, level, id string) (*RoutingPolicy, error) {
+	key := routingKey{level, id}
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.policy, nil
+	}
+
+	p, err := c.store.Get(ctx, level, id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = routingEntry{policy: p, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return p, nil
+}
+
+func (c *CachedRoutingStore) Put(ctx context.Context, p *RoutingPolicy) error {
+	if err := c.store.Put(ctx, p); err != nil {
+		return err
+	}
+	c.invalidate(p.Level, p.ID)
+	return nil
+}
+
+func (c *CachedRoutingStore) Delete(ctx context.Context, level, id string) error {
+	if err := c.store.Delete(ctx, level, id); err != nil {
+		return err
+	}
+	c.invalidate(level, id)
+	return nil
+}
+
+func (c *CachedRoutingStore) List(ctx context.Context) ([]*RoutingPolicy, error) {
+	return c.store.List(ctx)
+}
+
+func (c *CachedRoutingStore) invalidate(level, id string) {
+	c.mu.Lock()
+	delete(c.entries, routingKey{level, id})
+	c.mu.Unlock()
+}
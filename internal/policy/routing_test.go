@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestMergeRouting(t *testing.T) {
+	e := MergeRouting(
+		RoutingPolicy{Level: LevelGateway, Strategy: "cost"},
+		RoutingPolicy{Level: LevelGlobal, MaxCost: &provider.CostCap{USD: 0.5}},
+		RoutingPolicy{Level: LevelPlan, Strategy: "latency", Mode: ModeStrict, MaxCost: &provider.CostCap{USD: 0.1}},
+		RoutingPolicy{Level: LevelTenant, Mode: ModeLenient, MaxCost: &provider.CostCap{USD: 1, Substitute: true}},
+		RoutingPolicy{Level: LevelKey},
+	)
+	if e.Strategy != "latency" || e.Mode != ModeLenient {
+		t.Errorf("Expected the most specific strategy and mode, got %q, %q", e.Strategy, e.Mode)
+	}
+	// A looser cap further down can't lift the plan's, but its substitution still applies.
+	if e.MaxCost == nil || *e.MaxCost != (provider.CostCap{USD: 0.1, Substitute: true}) {
+		t.Errorf("Expected the lowest cap with substitution, got %+v", e.MaxCost)
+	}
+	want := map[string]string{"strategy": LevelPlan, "mode": LevelTenant, "max_cost": LevelPlan}
+	for field, level := range want {
+		if e.Sources[field] != level {
+			t.Errorf("Expected %s from %s, got %q", field, level, e.Sources[field])
+		}
+	}
+
+	if e := MergeRouting(RoutingPolicy{Level: LevelGateway}); e.Mode != ModeLenient || e.MaxCost != nil {
+		t.Errorf("Expected lenient routing without a cap by default, got %+v", e)
+	}
+}
+
+func TestRoutingPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     RoutingPolicy
+		valid bool
+	}{
+		{"global", RoutingPolicy{Level: LevelGlobal, Strategy: "weighted"}, true},
+		{"global with id", RoutingPolicy{Level: LevelGlobal, ID: "pro"}, false},
+		{"plan", RoutingPolicy{Level: LevelPlan, ID: "pro", Mode: ModeStrict}, true},
+		{"key without id", RoutingPolicy{Level: LevelKey}, false},
+		{"tenant", RoutingPolicy{Level: LevelTenant, ID: "acme"}, false},
+		{"unknown strategy", RoutingPolicy{Level: LevelGlobal, Strategy: "random"}, false},
+		{"unknown mode", RoutingPolicy{Level: LevelGlobal, Mode: "loose"}, false},
+		{"zero cap", RoutingPolicy{Level: LevelGlobal, MaxCost: &provider.CostCap{}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid=%v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Windows name IANA zones; don't depend on the host having tzdata.
+	_ "time/tzdata"
+)
+
+// ModelWindow restricts a model to certain days and hours. Outside the window
+// requests are rewritten to Fallback, or rejected when Fallback is empty.
+type ModelWindow struct {
+	Model string `json:"model"`
+	// Days are lower-case three-letter weekdays ("mon".."sun"); empty means every day.
+	Days []string `json:"days,omitempty"`
+	// Start and End are "HH:MM" wall-clock times; End before Start wraps past midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is an IANA name such as "Asia/Jakarta"; empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// Decision describes what a window policy did to a request.
+type Decision struct {
+	RequestedModel string
+	Model          string // model to route; empty when the request is denied
+	Reason         string
+}
+
+// Denied reports whether the request must be rejected.
+func (d *Decision) Denied() bool {
+	return d.Model == ""
+}
+
+// ApplyWindows checks model against windows at now. It returns nil when no
+// window governs the model or the model is inside its window.
+func ApplyWindows(windows []ModelWindow, model string, now time.Time) (*Decision, error) {
+	for _, w := range windows {
+		if w.Model != model {
+			continue
+		}
+		open, err := w.contains(now)
+		if err != nil {
+			return nil, fmt.Errorf("model window for %s: %w", model, err)
+		}
+		if open {
+			return nil, nil
+		}
+		d := &Decision{RequestedModel: model, Model: w.Fallback}
+		if w.Fallback != "" {
+			d.Reason = fmt.Sprintf("%s is restricted to %s; using %s", model, w.describe(), w.Fallback)
+		} else {
+			d.Reason = fmt.Sprintf("%s is restricted to %s", model, w.describe())
+		}
+		return d, nil
+	}
+	return nil, nil
+}
+
+func (w ModelWindow) contains(now time.Time) (bool, error) {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false, err
+		}
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false, err
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+	if end <= start && minute < end {
+		// Early-morning part of a window that started yesterday.
+		day = (day + 6) % 7
+	}
+	if !w.allowsDay(day) {
+		return false, nil
+	}
+	if end > start {
+		return minute >= start && minute < end, nil
+	}
+	return minute >= start || minute < end, nil
+}
+
+func (w ModelWindow) allowsDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	name := strings.ToLower(day.String()[:3])
+	for _, d := range w.Days {
+		if strings.ToLower(d) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (w ModelWindow) describe() string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ",")
+	}
+	tz := w.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	return fmt.Sprintf("%s %s-%s %s", days, w.Start, w.End, tz)
+}
+
+// parseClock converts "HH:MM" to minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
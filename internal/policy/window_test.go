@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+var businessHours = []ModelWindow{{
+	Model:    "gpt-4o",
+	Days:     []string{"mon", "tue", "wed", "thu", "fri"},
+	Start:    "09:00",
+	End:      "17:00",
+	Timezone: "Asia/Jakarta",
+	Fallback: "gpt-4o-mini",
+}}
+
+func TestApplyWindows_InsideWindow(t *testing.T) {
+	// Wednesday 10:00 in Jakarta (UTC+7).
+	now := time.Date(2024, 6, 5, 3, 0, 0, 0, time.UTC)
+	d, err := ApplyWindows(businessHours, "gpt-4o", now)
+	if err != nil {
+		t.Fatalf("ApplyWindows failed: %v", err)
+	}
+	if d != nil {
+		t.Errorf("Expected no decision inside window, got %+v", d)
+	}
+}
+
+func TestApplyWindows_FallsBackOutsideWindow(t *testing.T) {
+	// Saturday 10:00 in Jakarta.
+	now := time.Date(2024, 6, 8, 3, 0, 0, 0, time.UTC)
+	d, err := ApplyWindows(businessHours, "gpt-4o", now)
+	if err != nil {
+		t.Fatalf("ApplyWindows failed: %v", err)
+	}
+	if d == nil || d.Model != "gpt-4o-mini" || d.Denied() {
+		t.Errorf("Expected fallback to gpt-4o-mini, got %+v", d)
+	}
+}
+
+func TestApplyWindows_DeniesWithoutFallback(t *testing.T) {
+	windows := []ModelWindow{{Model: "o1", Start: "22:00", End: "06:00"}}
+
+	d, err := ApplyWindows(windows, "o1", time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ApplyWindows failed: %v", err)
+	}
+	if d == nil || !d.Denied() {
+		t.Errorf("Expected denial at noon, got %+v", d)
+	}
+
+	d, _ = ApplyWindows(windows, "o1", time.Date(2024, 6, 5, 2, 0, 0, 0, time.UTC))
+	if d != nil {
+		t.Errorf("Expected overnight window to be open at 02:00, got %+v", d)
+	}
+}
+
+func TestApplyWindows_UngovernedModel(t *testing.T) {
+	d, err := ApplyWindows(businessHours, "claude-3", time.Now())
+	if err != nil || d != nil {
+		t.Errorf("Expected no decision for ungoverned model, got %+v, %v", d, err)
+	}
+}
@@ -0,0 +1,155 @@
+package postprocess
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+const (
+	OutputFormatPlainText = "plain_text"
+	CitationStyleBrackets = "brackets"
+)
+
+// maxBuffer bounds how much streamed text a Stream holds back waiting for a
+// construct (e.g. a link) to close.
+const maxBuffer = 2048
+
+// lineStartMarker stands in for "previous segment ended mid-line" so
+// line-anchored rules don't fire on a segment that doesn't start a line.
+const lineStartMarker = "\x00"
+
+var (
+	codeFence     = regexp.MustCompile("(?m)^```[\\w-]*[ \\t]*\\n?")
+	heading       = regexp.MustCompile(`(?m)^#{1,6}[ \t]+`)
+	bullet        = regexp.MustCompile(`(?m)^([ \t]*)[*+][ \t]+`)
+	image         = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	link          = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	emphasisOpen  = regexp.MustCompile(`(^|[\s\x00])\*(\S)`)
+	emphasisClose = regexp.MustCompile(`(\S)\*($|[\s.,;:!?)])`)
+
+	footnoteCitation  = regexp.MustCompile(`\[\^(\d+)\]`)
+	fullwidthCitation = regexp.MustCompile(`【(\d+)[^】]*】`)
+)
+
+// Processor rewrites model output according to tenant settings.
+type Processor struct {
+	stripMarkdown bool
+	citations     bool
+}
+
+// New returns a Processor for the tenant, or nil when no rewriting applies.
+func New(settings *tenant.Settings) *Processor {
+	if settings == nil {
+		return nil
+	}
+	p := &Processor{
+		stripMarkdown: settings.OutputFormat == OutputFormatPlainText,
+		citations:     settings.CitationStyle == CitationStyleBrackets,
+	}
+	if !p.stripMarkdown && !p.citations {
+		return nil
+	}
+	return p
+}
+
+// Process rewrites a complete response.
+func (p *Processor) Process(s string) string {
+	return p.apply(s)
+}
+
+func (p *Processor) apply(s string) string {
+	if p.citations {
+		s = footnoteCitation.ReplaceAllString(s, "[$1]")
+		s = fullwidthCitation.ReplaceAllString(s, "[$1]")
+	}
+	if p.stripMarkdown {
+		s = codeFence.ReplaceAllString(s, "")
+		s = heading.ReplaceAllString(s, "")
+		s = bullet.ReplaceAllString(s, "$1- ")
+		s = image.ReplaceAllString(s, "$1 ($2)")
+		s = link.ReplaceAllString(s, "$1 ($2)")
+		s = strings.NewReplacer("**", "", "__", "", "`", "").Replace(s)
+		s = emphasisOpen.ReplaceAllString(s, "$1$2")
+		s = emphasisClose.ReplaceAllString(s, "$1$2")
+	}
+	return s
+}
+
+// Stream applies a Processor to streamed deltas. Text is only released at
+// whitespace boundaries outside open links/citations, so a construct split
+// across chunks is rewritten exactly as it would be in a complete response.
+type Stream struct {
+	p         *Processor
+	buf       strings.Builder
+	lineStart bool
+}
+
+func (p *Processor) NewStream() *Stream {
+	return &Stream{p: p, lineStart: true}
+}
+
+// Write buffers delta and returns the text that is safe to emit now.
+func (s *Stream) Write(delta string) string {
+	s.buf.WriteString(delta)
+	pending := s.buf.String()
+
+	cut := safeCut(pending)
+	if cut == 0 && len(pending) > maxBuffer {
+		cut = len(pending)
+	}
+	if cut == 0 {
+		return ""
+	}
+
+	s.buf.Reset()
+	s.buf.WriteString(pending[cut:])
+	return s.emit(pending[:cut])
+}
+
+// Flush returns whatever is still buffered; call it when the stream ends.
+func (s *Stream) Flush() string {
+	pending := s.buf.String()
+	s.buf.Reset()
+	if pending == "" {
+		return ""
+	}
+	return s.emit(pending)
+}
+
+func (s *Stream) emit(segment string) string {
+	if s.lineStart {
+		out := s.p.apply(segment)
+		s.lineStart = strings.HasSuffix(segment, "\n")
+		return out
+	}
+	out := strings.TrimPrefix(s.p.apply(lineStartMarker+segment), lineStartMarker)
+	s.lineStart = strings.HasSuffix(segment, "\n")
+	return out
+}
+
+// safeCut returns the length of the longest prefix of s that ends at
+// whitespace and doesn't leave a link or citation open.
+func safeCut(s string) int {
+	cut := strings.LastIndexAny(s, " \t\n") + 1
+	if cut == 0 {
+		return 0
+	}
+	head := s[:cut]
+	if i := strings.LastIndex(head, "【"); i >= 0 && !strings.Contains(head[i:], "】") {
+		cut = i
+		head = s[:cut]
+	}
+	if i := strings.LastIndex(head, "["); i >= 0 {
+		rest := head[i:]
+		closeIdx := strings.Index(rest, "]")
+		switch {
+		case closeIdx < 0:
+			cut = i
+		case closeIdx+1 < len(rest) && rest[closeIdx+1] == '(' && !strings.Contains(rest[closeIdx:], ")"):
+			cut = i
+		}
+	}
+	return cut
+}
@@ -0,0 +1,69 @@
+package postprocess
+
+import (
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+const sample = "# Title\n\nSee **bold** and *soft* text with `code`.\n* item one\n+ item two\nRead [the docs](https://example.com/docs) now [^1] and 【2†source】.\n```go\nfmt.Println()\n```\nuse # for comments\n"
+
+func TestNew_NoSettings(t *testing.T) {
+	if New(&tenant.Settings{}) != nil {
+		t.Error("Expected nil processor when nothing is enabled")
+	}
+	if New(nil) != nil {
+		t.Error("Expected nil processor for nil settings")
+	}
+}
+
+func TestProcess_PlainTextAndCitations(t *testing.T) {
+	p := New(&tenant.Settings{OutputFormat: OutputFormatPlainText, CitationStyle: CitationStyleBrackets})
+
+	got := p.Process(sample)
+	want := "Title\n\nSee bold and soft text with code.\n- item one\n- item two\nRead the docs (https://example.com/docs) now [1] and [2].\nfmt.Println()\nuse # for comments\n"
+	if got != want {
+		t.Errorf("Unexpected output:\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestProcess_CitationsOnlyKeepsMarkdown(t *testing.T) {
+	p := New(&tenant.Settings{CitationStyle: CitationStyleBrackets})
+
+	got := p.Process("**Yes** [^3]")
+	if got != "**Yes** [3]" {
+		t.Errorf("Expected markdown preserved, got %q", got)
+	}
+}
+
+// Every way of splitting the sample into two or three chunks must produce
+// exactly the same output as processing it whole.
+func TestStream_ChunkBoundarySafety(t *testing.T) {
+	p := New(&tenant.Settings{OutputFormat: OutputFormatPlainText, CitationStyle: CitationStyleBrackets})
+	want := p.Process(sample)
+
+	for i := 0; i <= len(sample); i++ {
+		for j := i; j <= len(sample); j += 7 {
+			s := p.NewStream()
+			got := s.Write(sample[:i]) + s.Write(sample[i:j]) + s.Write(sample[j:]) + s.Flush()
+			if got != want {
+				t.Fatalf("Split at %d/%d produced different output:\ngot:  %q\nwant: %q", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestStream_ByteAtATime(t *testing.T) {
+	p := New(&tenant.Settings{OutputFormat: OutputFormatPlainText, CitationStyle: CitationStyleBrackets})
+	want := p.Process(sample)
+
+	s := p.NewStream()
+	var got string
+	for _, r := range sample {
+		got += s.Write(string(r))
+	}
+	got += s.Flush()
+	if got != want {
+		t.Errorf("Unexpected output:\ngot:  %q\nwant: %q", got, want)
+	}
+}
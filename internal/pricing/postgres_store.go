@@ -0,0 +1,47 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]ModelPrice, error) {
+	query := `
+		SELECT provider, model, input_usd_per_million, output_usd_per_million
+		FROM model_prices
+	`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list model prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []ModelPrice
+	for rows.Next() {
+		var mp ModelPrice
+		var input, output float64
+		if err := rows.Scan(&mp.Provider, &mp.Model, &input, &output); err != nil {
+			return nil, fmt.Errorf("failed to scan model price: %w", err)
+		}
+		mp.Input, mp.Output = input/1e6, output/1e6
+		prices = append(prices, mp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list model prices: %w", err)
+	}
+	return prices, nil
+}
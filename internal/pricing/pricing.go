@@ -0,0 +1,87 @@
+package pricing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Price is what a model costs in USD per token.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// ModelPrice is the price of one model on one provider.
+type ModelPrice struct {
+	Provider string
+	Model    string
+	Price
+}
+
+type Store interface {
+	List(ctx context.Context) ([]ModelPrice, error)
+}
+
+type key struct {
+	provider, model string
+}
+
+// Registry serves model prices from memory. Run reloads them from the store,
+// so price changes take effect without a restart.
+type Registry struct {
+	store    Store
+	interval time.Duration
+
+	mu     sync.RWMutex
+	prices map[key]Price
+}
+
+func NewRegistry(store Store, interval time.Duration) *Registry {
+	return &Registry{store: store, interval: interval, prices: make(map[key]Price)}
+}
+
+// Lookup returns the price of model on provider. A nil Registry knows no prices.
+func (r *Registry) Lookup(provider, model string) (Price, bool) {
+	if r == nil {
+		return Price{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.prices[key{provider, model}]
+	return p, ok
+}
+
+// Reload replaces the registry's prices with the store's. On error the
+// previous prices stay in place.
+func (r *Registry) Reload(ctx context.Context) error {
+	list, err := r.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	prices := make(map[key]Price, len(list))
+	for _, mp := range list {
+		prices[key{mp.Provider, mp.Model}] = mp.Price
+	}
+	r.mu.Lock()
+	r.prices = prices
+	r.mu.Unlock()
+	return nil
+}
+
+// Run reloads prices every interval until ctx is done.
+func (r *Registry) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(ctx); err != nil {
+				log.Printf("pricing: failed to reload model prices: %v", err)
+			}
+		}
+	}
+}
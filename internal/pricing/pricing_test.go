@@ -0,0 +1,52 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	prices []ModelPrice
+	err    error
+}
+
+func (s *fakeStore) List(ctx context.Context) ([]ModelPrice, error) {
+	return s.prices, s.err
+}
+
+func TestRegistry_ReloadReplacesPrices(t *testing.T) {
+	store := &fakeStore{prices: []ModelPrice{{Provider: "openai", Model: "gpt-4o", Price: Price{Input: 2.5e-6, Output: 1e-5}}}}
+	r := NewRegistry(store, time.Minute)
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if p, ok := r.Lookup("openai", "gpt-4o"); !ok || p.Input != 2.5e-6 {
+		t.Errorf("Expected gpt-4o's price, got %+v %v", p, ok)
+	}
+	if _, ok := r.Lookup("claude", "gpt-4o"); ok {
+		t.Error("Expected prices to be keyed by provider too")
+	}
+
+	store.prices = []ModelPrice{{Provider: "openai", Model: "gpt-4o-mini", Price: Price{Input: 1.5e-7}}}
+	_ = r.Reload(context.Background())
+	if _, ok := r.Lookup("openai", "gpt-4o"); ok {
+		t.Error("Expected removed rows to be dropped on reload")
+	}
+
+	store.err = errors.New("db down")
+	if err := r.Reload(context.Background()); err == nil {
+		t.Error("Expected the store error")
+	}
+	if _, ok := r.Lookup("openai", "gpt-4o-mini"); !ok {
+		t.Error("Expected a failed reload to keep the previous prices")
+	}
+}
+
+func TestRegistry_NilKnowsNoPrices(t *testing.T) {
+	var r *Registry
+	if _, ok := r.Lookup("openai", "gpt-4o"); ok {
+		t.Error("Expected no price from a nil registry")
+	}
+}
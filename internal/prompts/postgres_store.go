@@ -0,0 +1,114 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, tenantID, id string, version int) (*Prompt, error) {
+	query := `
+		SELECT tenant_id, prompt_id, version, content, defaults, created_at
+		FROM system_prompts
+		WHERE tenant_id = $1 AND prompt_id = $2 AND ($3 = 0 OR version = $3)
+		ORDER BY version DESC
+		LIMIT 1
+	`
+	p, err := scanPrompt(s.db.QueryRow(ctx, query, tenantID, id, version))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get system prompt: %w", err)
+	}
+	return p, nil
+}
+
+func (s *PostgresStore) Put(ctx context.Context, p *Prompt) error {
+	if p.Defaults == nil {
+		p.Defaults = map[string]string{}
+	}
+	defaults, err := json.Marshal(p.Defaults)
+	if err != nil {
+		return fmt.Errorf("failed to encode prompt defaults: %w", err)
+	}
+	query := `
+		INSERT INTO system_prompts (tenant_id, prompt_id, version, content, defaults)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4
+		FROM system_prompts
+		WHERE tenant_id = $1 AND prompt_id = $2
+		RETURNING version, created_at
+	`
+	if err := s.db.QueryRow(ctx, query, p.TenantID, p.ID, p.Content, defaults).Scan(&p.Version, &p.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save system prompt: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, tenantID string) ([]*Prompt, error) {
+	query := `
+		SELECT DISTINCT ON (prompt_id) tenant_id, prompt_id, version, content, defaults, created_at
+		FROM system_prompts
+		WHERE tenant_id = $1
+		ORDER BY prompt_id, version DESC
+	`
+	rows, err := s.db.Query(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list system prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Prompt
+	for rows.Next() {
+		p, err := scanPrompt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan system prompt: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, tenantID, id string) error {
+	query := `DELETE FROM system_prompts WHERE tenant_id = $1 AND prompt_id = $2`
+	tag, err := s.db.Exec(ctx, query, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete system prompt: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanPrompt(row pgx.Row) (*Prompt, error) {
+	var p Prompt
+	var defaults []byte
+	if err := row.Scan(&p.TenantID, &p.ID, &p.Version, &p.Content, &defaults, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(defaults) > 0 {
+		if err := json.Unmarshal(defaults, &p.Defaults); err != nil {
+			return nil, fmt.Errorf("failed to decode prompt defaults: %w", err)
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,189 @@
+// Package prompts is each tenant's library of reusable system prompts.
+// Requests reference a prompt by ID as system_ref and the gateway expands
+// it, so large prompts aren't sent with every request. Saving a prompt adds
+// a version; references pin one ("support@3") or follow the latest.
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for prompts or versions that don't exist.
+	ErrNotFound = errors.New("system prompt not found")
+	// ErrInvalidRef is wrapped by ParseRef errors.
+	ErrInvalidRef = errors.New("invalid system_ref")
+	// ErrMissingVariable is wrapped when rendering a prompt a variable
+	// has no value for.
+	ErrMissingVariable = errors.New("missing prompt variable")
+)
+
+var (
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	variable  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Prompt is one version of a system prompt. Content may hold variables
+// written {{name}}, filled from the request's system_vars or Defaults.
+type Prompt struct {
+	TenantID  string            `json:"tenant_id"`
+	ID        string            `json:"id"`
+	Version   int               `json:"version"`
+	Content   string            `json:"content"`
+	Defaults  map[string]string `json:"defaults,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Validate rejects prompts that can't be stored or referenced.
+func (p *Prompt) Validate() error {
+	if !idPattern.MatchString(p.ID) {
+		return fmt.Errorf("invalid prompt id %q: use up to 64 letters, digits, '.', '_' or '-'", p.ID)
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return fmt.Errorf("prompt content is required")
+	}
+	return nil
+}
+
+// Variables lists the variables Content uses, sorted.
+func (p *Prompt) Variables() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range variable.FindAllStringSubmatch(p.Content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render fills Content's variables from vars, falling back to Defaults.
+func (p *Prompt) Render(vars map[string]string) (string, error) {
+	var missing []string
+	out := variable.ReplaceAllStringFunc(p.Content, func(m string) string {
+		name := variable.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if v, ok := p.Defaults[name]; ok {
+			return v
+		}
+		missing = append(missing, name)
+		return m
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// ParseRef splits a system_ref into a prompt ID and version, 0 for the
+// latest.
+func ParseRef(ref string) (id string, version int, err error) {
+	id, v, pinned := strings.Cut(ref, "@")
+	if !idPattern.MatchString(id) {
+		return "", 0, fmt.Errorf("%w %q", ErrInvalidRef, ref)
+	}
+	if !pinned {
+		return id, 0, nil
+	}
+	version, err = strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return "", 0, fmt.Errorf("%w %q: version must be a positive number", ErrInvalidRef, ref)
+	}
+	return id, version, nil
+}
+
+type Store interface {
+	// Get returns a version of a prompt, the latest when version is 0.
+	Get(ctx context.Context, tenantID, id string, version int) (*Prompt, error)
+	// Put saves p as the next version of its ID, setting Version and CreatedAt.
+	Put(ctx context.Context, p *Prompt) error
+	// List returns the latest version of each of the tenant's prompts.
+	List(ctx context.Context, tenantID string) ([]*Prompt, error)
+	// Delete removes every version of a prompt.
+	Delete(ctx context.Context, tenantID, id string) error
+}
+
+// CachedStore keeps looked-up prompts in memory for ttl to keep expansion
+// off the hot path.
+type CachedStore struct {
+	store Store
+	ttl   time.Duration
+
+	mu      sync.RWMutex
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	tenantID, id string
+	version      int
+}
+
+type cacheEntry struct {
+	prompt    *Prompt
+	expiresAt time.Time
+}
+
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{store: store, ttl: ttl, entries: make(map[cacheKey]cacheEntry)}
+}
+
+func (c *CachedStore) Get(ctx context.Context, tenantID, id string, version int) (*Prompt, error) {
+	key := cacheKey{tenantID, id, version}
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.prompt, nil
+	}
+
+	p, err := c.store.Get(ctx, tenantID, id, version)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{prompt: p, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return p, nil
+}
+
+func (c *CachedStore) Put(ctx context.Context, p *Prompt) error {
+	if err := c.store.Put(ctx, p); err != nil {
+		return err
+	}
+	c.invalidate(p.TenantID, p.ID)
+	return nil
+}
+
+func (c *CachedStore) List(ctx context.Context, tenantID string) ([]*Prompt, error) {
+	return c.store.List(ctx, tenantID)
+}
+
+func (c *CachedStore) Delete(ctx context.Context, tenantID, id string) error {
+	if err := c.store.Delete(ctx, tenantID, id); err != nil {
+		return err
+	}
+	c.invalidate(tenantID, id)
+	return nil
+}
+
+func (c *CachedStore) invalidate(tenantID, id string) {
+	c.mu.Lock()
+	for key := range c.entries {
+		if key.tenantID == tenantID && key.id == id {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+}
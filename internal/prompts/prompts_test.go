@@ -0,0 +1,93 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPrompt_Render(t *testing.T) {
+	p := &Prompt{
+		Content:  "You are {{ product }}'s support agent. Answer in {{tone}} tone. {{product}} is {{ product }}.",
+		Defaults: map[string]string{"tone": "a friendly"},
+	}
+	if got := p.Variables(); !reflect.DeepEqual(got, []string{"product", "tone"}) {
+		t.Errorf("Expected product and tone, got %v", got)
+	}
+
+	got, err := p.Render(map[string]string{"product": "Acme"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	want := "You are Acme's support agent. Answer in a friendly tone. Acme is Acme."
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if _, err := p.Render(nil); !errors.Is(err, ErrMissingVariable) {
+		t.Errorf("Expected a missing variable error, got %v", err)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		id      string
+		version int
+		ok      bool
+	}{
+		{"support", "support", 0, true},
+		{"support.v2@3", "support.v2", 3, true},
+		{"support@0", "", 0, false},
+		{"support@latest", "", 0, false},
+		{"../etc", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		id, version, err := ParseRef(tt.ref)
+		if (err == nil) != tt.ok || id != tt.id || version != tt.version {
+			t.Errorf("ParseRef(%q) = %q, %d, %v", tt.ref, id, version, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("ParseRef(%q): expected ErrInvalidRef, got %v", tt.ref, err)
+		}
+	}
+}
+
+type countingStore struct {
+	Store
+	gets   int
+	latest *Prompt
+}
+
+func (s *countingStore) Get(ctx context.Context, tenantID, id string, version int) (*Prompt, error) {
+	s.gets++
+	return s.latest, nil
+}
+
+func (s *countingStore) Put(ctx context.Context, p *Prompt) error {
+	p.Version = s.latest.Version + 1
+	s.latest = p
+	return nil
+}
+
+func TestCachedStore_InvalidatesOnPut(t *testing.T) {
+	inner := &countingStore{latest: &Prompt{TenantID: "t", ID: "support", Version: 1}}
+	c := NewCachedStore(inner, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if p, _ := c.Get(context.Background(), "t", "support", 0); p.Version != 1 {
+			t.Fatalf("Expected version 1, got %d", p.Version)
+		}
+	}
+	if inner.gets != 1 {
+		t.Errorf("Expected one lookup, got %d", inner.gets)
+	}
+
+	_ = c.Put(context.Background(), &Prompt{TenantID: "t", ID: "support", Content: "new"})
+	if p, _ := c.Get(context.Background(), "t", "support", 0); p.Version != 2 {
+		t.Errorf("Expected the new version after a save, got %d", p.Version)
+	}
+}
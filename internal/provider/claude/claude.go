@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
 )
@@ -16,31 +17,75 @@ import (
 type ClaudeProvider struct {
 	apiKey  string
 	baseURL string
+	client  *http.Client
+
+	keyMu sync.RWMutex // guards apiKey, replaced by SetAPIKey
+}
+
+type Option func(*ClaudeProvider)
+
+// WithBaseURL overrides the API base URL, e.g. the primary of a list of
+// regional endpoints.
+func WithBaseURL(baseURL string) Option {
+	return func(p *ClaudeProvider) {
+		if baseURL != "" {
+			p.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sends upstream requests through client, e.g. one built from
+// provider.Egress (default: http.DefaultClient).
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *ClaudeProvider) {
+		p.client = client
+	}
 }
 
 type claudeRequest struct {
-	Model     string          `json:"model"`
-	MaxTokens int             `json:"max_tokens"`
-	System    string          `json:"system,omitempty"`
-	Messages  []claudeMessage `json:"messages"`
-	Stream    bool            `json:"stream,omitempty"`
+	Model      string            `json:"model"`
+	MaxTokens  int               `json:"max_tokens"`
+	System     string            `json:"system,omitempty"`
+	Messages   []claudeMessage   `json:"messages"`
+	Stream     bool              `json:"stream,omitempty"`
+	Tools      []claudeTool      `json:"tools,omitempty"`
+	ToolChoice *claudeToolChoice `json:"tool_choice,omitempty"`
 }
 
 type claudeMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string          `json:"role"`
+	Content []claudeContent `json:"content"`
+}
+
+type claudeTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type claudeToolChoice struct {
+	Type string `json:"type"` // auto, any, tool, none
+	Name string `json:"name,omitempty"`
 }
 
 type claudeResponse struct {
-	ID      string          `json:"id"`
-	Content []claudeContent `json:"content"`
-	Model   string          `json:"model"`
-	Usage   claudeUsage     `json:"usage"`
+	ID         string          `json:"id"`
+	Content    []claudeContent `json:"content"`
+	Model      string          `json:"model"`
+	Usage      claudeUsage     `json:"usage"`
+	StopReason string          `json:"stop_reason"`
 }
 
+// claudeContent is a content block: text, tool_use (ID, Name, Input) or
+// tool_result (ToolUseID, Content).
 type claudeContent struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
 }
 
 type claudeUsage struct {
@@ -49,14 +94,22 @@ type claudeUsage struct {
 }
 
 type claudeStreamDelta struct {
-	Type  string        `json:"type"`
-	Delta claudeDelta   `json:"delta,omitempty"`
-	Error *claudeError `json:"error,omitempty"`
+	Type  string      `json:"type"`
+	Index int         `json:"index"`
+	Delta claudeDelta `json:"delta,omitempty"`
+	// ContentBlock is set on content_block_start.
+	ContentBlock *claudeContent `json:"content_block,omitempty"`
+	Error        *claudeError   `json:"error,omitempty"`
+	// Message is set on message_start (input usage), Usage on message_delta (output usage).
+	Message *claudeResponse `json:"message,omitempty"`
+	Usage   *claudeUsage    `json:"usage,omitempty"`
 }
 
 type claudeDelta struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"` // on message_delta
 }
 
 type claudeError struct {
@@ -64,11 +117,35 @@ type claudeError struct {
 	Message string `json:"message"`
 }
 
-func New(apiKey string) provider.Provider {
-	return &ClaudeProvider{
+func New(apiKey string, opts ...Option) provider.Provider {
+	p := &ClaudeProvider{
 		apiKey:  apiKey,
 		baseURL: "https://api.anthropic.com/v1",
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *ClaudeProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return http.DefaultClient
+}
+
+// SetAPIKey replaces the key sent with subsequent requests.
+func (p *ClaudeProvider) SetAPIKey(key string) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+	p.apiKey = key
+}
+
+func (p *ClaudeProvider) key() string {
+	p.keyMu.RLock()
+	defer p.keyMu.RUnlock()
+	return p.apiKey
 }
 
 func (p *ClaudeProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
@@ -77,6 +154,10 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req *provider.Request) (*
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
 	url := fmt.Sprintf("%s/messages", p.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
@@ -84,10 +165,10 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req *provider.Request) (*
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("x-api-key", p.key())
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := p.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +176,7 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req *provider.Request) (*
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("claude api error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
 	}
 
 	var claudeResp claudeResponse
@@ -107,13 +188,31 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req *provider.Request) (*
 		return nil, fmt.Errorf("claude api returned no content")
 	}
 
+	var text strings.Builder
+	var toolCalls []provider.ToolCall
+	for _, c := range claudeResp.Content {
+		switch c.Type {
+		case "text":
+			text.WriteString(c.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, toolCall(c.ID, c.Name, string(c.Input)))
+		}
+	}
+
+	content := text.String()
+	if req.ResponseFormat.WantsJSON() {
+		content = stripCodeFence(content)
+	}
+
 	return &provider.Response{
-		ID:           claudeResp.ID,
-		Content:      claudeResp.Content[0].Text,
-		InputTokens:  claudeResp.Usage.InputTokens,
-		OutputTokens: claudeResp.Usage.OutputTokens,
-		Model:        claudeResp.Model,
-		Provider:     p.Name(),
+		ID:              claudeResp.ID,
+		Content:         content,
+		ToolCalls:       toolCalls,
+		InputTokens:     claudeResp.Usage.InputTokens,
+		OutputTokens:    claudeResp.Usage.OutputTokens,
+		Model:           claudeResp.Model,
+		Provider:        p.Name(),
+		RawFinishReason: claudeResp.StopReason,
 	}, nil
 }
 
@@ -132,23 +231,112 @@ func (p *ClaudeProvider) mapRequest(req *provider.Request) claudeRequest {
 		} else {
 			role = "user"
 		}
+
+		var blocks []claudeContent
+		if m.Role == "tool" {
+			blocks = append(blocks, claudeContent{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		} else if m.Content != "" {
+			blocks = append(blocks, claudeContent{Type: "text", Text: m.Content})
+		}
+		for _, tc := range m.ToolCalls {
+			input := json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			blocks = append(blocks, claudeContent{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+		}
+
+		// Tool results arrive as separate OpenAI messages but Claude wants
+		// them in one user turn, so consecutive same-role turns are merged.
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			continue
+		}
 		messages = append(messages, claudeMessage{
 			Role:    role,
-			Content: m.Content,
+			Content: blocks,
 		})
 	}
 
+	if req.ResponseFormat.WantsJSON() {
+		system = strings.TrimSpace(system + "\n\n" + jsonInstruction(req.ResponseFormat.Schema()))
+	}
+
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
 	return claudeRequest{
-		Model:     req.Model,
-		MaxTokens: maxTokens,
-		System:    system,
-		Messages:  messages,
-		Stream:    req.Stream,
+		Model:      req.Model,
+		MaxTokens:  maxTokens,
+		System:     system,
+		Messages:   messages,
+		Stream:     req.Stream,
+		Tools:      mapTools(req.Tools),
+		ToolChoice: mapToolChoice(req),
+	}
+}
+
+// jsonInstruction emulates response_format, which Claude has no native
+// equivalent for, through the system prompt.
+func jsonInstruction(schema json.RawMessage) string {
+	instruction := "Respond only with a single valid JSON object. Do not wrap it in Markdown code fences or add any text before or after it."
+	if len(schema) > 0 {
+		instruction += " The object must conform to this JSON Schema:\n" + string(schema)
+	}
+	return instruction
+}
+
+// stripCodeFence unwraps a ```json fenced block, which Claude sometimes
+// emits despite the instruction.
+func stripCodeFence(s string) string {
+	t := strings.TrimSpace(s)
+	if !strings.HasPrefix(t, "```") || !strings.HasSuffix(t, "```") || len(t) < 6 {
+		return s
+	}
+	t = strings.TrimSuffix(t[3:], "```")
+	if nl := strings.IndexByte(t, '\n'); nl >= 0 && !strings.ContainsAny(t[:nl], "{[") {
+		t = t[nl+1:]
+	}
+	return strings.TrimSpace(t)
+}
+
+func mapTools(tools []provider.Tool) []claudeTool {
+	var out []claudeTool
+	for _, t := range tools {
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		out = append(out, claudeTool{Name: t.Function.Name, Description: t.Function.Description, InputSchema: schema})
+	}
+	return out
+}
+
+func mapToolChoice(req *provider.Request) *claudeToolChoice {
+	mode, name := req.ToolChoiceMode()
+	switch {
+	case name != "":
+		return &claudeToolChoice{Type: "tool", Name: name}
+	case mode == "required":
+		return &claudeToolChoice{Type: "any"}
+	case mode == "none":
+		return &claudeToolChoice{Type: "none"}
+	case mode == "auto":
+		return &claudeToolChoice{Type: "auto"}
+	}
+	return nil
+}
+
+func toolCall(id, name, arguments string) provider.ToolCall {
+	if arguments == "" {
+		arguments = "{}"
+	}
+	return provider.ToolCall{
+		ID:       id,
+		Type:     "function",
+		Function: provider.ToolCallFunction{Name: name, Arguments: arguments},
 	}
 }
 
@@ -159,6 +347,10 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
 	url := fmt.Sprintf("%s/messages", p.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
@@ -166,15 +358,15 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("x-api-key", p.key())
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
 	ch := make(chan *provider.Chunk)
 
-	go func() {
+	provider.Streams.Go("claude", func() {
 		defer close(ch)
 
-		resp, err := http.DefaultClient.Do(httpReq)
+		resp, err := p.httpClient().Do(httpReq)
 		if err != nil {
 			select {
 			case ch <- &provider.Chunk{Err: err}:
@@ -187,7 +379,7 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 		if resp.StatusCode != http.StatusOK {
 			respBody, _ := io.ReadAll(resp.Body)
 			select {
-			case ch <- &provider.Chunk{Err: fmt.Errorf("claude api error (status %d): %s", resp.StatusCode, string(respBody))}:
+			case ch <- &provider.Chunk{Err: provider.NewAPIError(p.Name(), resp.StatusCode, respBody)}:
 			case <-ctx.Done():
 			}
 			return
@@ -195,13 +387,18 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 
 		reader := bufio.NewReader(resp.Body)
 		var currentEvent string
+		var usage *provider.Usage
+		var stopReason string
+		// tool_use blocks stream their input as JSON fragments; they are
+		// assembled per block index and emitted on content_block_stop.
+		toolUses := make(map[int]*provider.ToolCall)
 
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err == io.EOF {
 					select {
-					case ch <- &provider.Chunk{Done: true}:
+					case ch <- &provider.Chunk{Done: true, Usage: usage, RawFinishReason: stopReason}:
 					case <-ctx.Done():
 					}
 					return
@@ -227,11 +424,26 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 				data := strings.TrimPrefix(line, "data: ")
 
 				switch currentEvent {
+				case "content_block_start":
+					var start claudeStreamDelta
+					if err := json.Unmarshal([]byte(data), &start); err == nil && start.ContentBlock != nil && start.ContentBlock.Type == "tool_use" {
+						toolUses[start.Index] = &provider.ToolCall{
+							ID:       start.ContentBlock.ID,
+							Type:     "function",
+							Function: provider.ToolCallFunction{Name: start.ContentBlock.Name},
+						}
+					}
 				case "content_block_delta":
 					var delta claudeStreamDelta
 					if err := json.Unmarshal([]byte(data), &delta); err != nil {
 						continue
 					}
+					if delta.Delta.Type == "input_json_delta" {
+						if tc, ok := toolUses[delta.Index]; ok {
+							tc.Function.Arguments += delta.Delta.PartialJSON
+						}
+						continue
+					}
 					if delta.Delta.Type == "text_delta" && delta.Delta.Text != "" {
 						select {
 						case ch <- &provider.Chunk{Delta: delta.Delta.Text}:
@@ -239,9 +451,50 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 							return
 						}
 					}
+				case "content_block_stop":
+					var stop claudeStreamDelta
+					if err := json.Unmarshal([]byte(data), &stop); err != nil {
+						continue
+					}
+					tc, ok := toolUses[stop.Index]
+					if !ok {
+						continue
+					}
+					delete(toolUses, stop.Index)
+					if tc.Function.Arguments == "" {
+						tc.Function.Arguments = "{}"
+					}
+					select {
+					case ch <- &provider.Chunk{ToolCalls: []provider.ToolCall{*tc}}:
+					case <-ctx.Done():
+						return
+					}
+				case "message_start":
+					var start claudeStreamDelta
+					if err := json.Unmarshal([]byte(data), &start); err == nil && start.Message != nil {
+						usage = &provider.Usage{
+							InputTokens:  start.Message.Usage.InputTokens,
+							OutputTokens: start.Message.Usage.OutputTokens,
+						}
+					}
+				case "message_delta":
+					var delta claudeStreamDelta
+					if err := json.Unmarshal([]byte(data), &delta); err != nil {
+						continue
+					}
+					if delta.Delta.StopReason != "" {
+						stopReason = delta.Delta.StopReason
+					}
+					if delta.Usage != nil {
+						if usage == nil {
+							usage = &provider.Usage{}
+						}
+						// output_tokens here is cumulative for the message.
+						usage.OutputTokens = delta.Usage.OutputTokens
+					}
 				case "message_stop":
 					select {
-					case ch <- &provider.Chunk{Done: true}:
+					case ch <- &provider.Chunk{Done: true, Usage: usage, RawFinishReason: stopReason}:
 					case <-ctx.Done():
 					}
 					return
@@ -257,15 +510,31 @@ func (p *ClaudeProvider) CompleteStream(ctx context.Context, req *provider.Reque
 				}
 			}
 		}
-	}()
+	})
 
 	return ch, nil
 }
 
+// ExtraFields lists Messages API parameters the gateway passes through.
+func (p *ClaudeProvider) ExtraFields() []string {
+	return []string{"top_k", "top_p", "stop_sequences", "metadata", "thinking", "service_tier"}
+}
+
 func (p *ClaudeProvider) Name() string {
 	return "claude"
 }
 
+// Ping lists the upstream's models, which costs nothing.
+func (p *ClaudeProvider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", p.baseURL), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("x-api-key", p.key())
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	return provider.Ping(p.httpClient(), httpReq)
+}
+
 func (p *ClaudeProvider) CostPerInputToken() float64 {
 	return 0.0000008
 }
@@ -283,3 +552,25 @@ func (p *ClaudeProvider) SupportedModels() []string {
 		"claude-3-haiku-20240307",
 	}
 }
+
+var (
+	chatCapabilities   = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools}
+	visionCapabilities = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools, provider.CapabilityVision}
+)
+
+var models = map[string]provider.ModelInfo{
+	"claude-3-5-sonnet-20241022": {ContextWindow: 200000, MaxOutputTokens: 8192, Capabilities: visionCapabilities, RetiresOn: "2025-10-22", Replacement: "claude-sonnet-4-20250514"},
+	"claude-3-5-haiku-20241022":  {ContextWindow: 200000, MaxOutputTokens: 8192, Capabilities: chatCapabilities},
+	"claude-3-opus-20240229":     {ContextWindow: 200000, MaxOutputTokens: 4096, Capabilities: visionCapabilities, RetiresOn: "2026-01-05", Replacement: "claude-opus-4-1-20250805"},
+	"claude-3-sonnet-20240229":   {ContextWindow: 200000, MaxOutputTokens: 4096, Capabilities: visionCapabilities, RetiresOn: "2025-07-21", Replacement: "claude-3-5-sonnet-20241022"},
+	"claude-3-haiku-20240307":    {ContextWindow: 200000, MaxOutputTokens: 4096, Capabilities: visionCapabilities},
+}
+
+func (p *ClaudeProvider) ModelInfo(model string) (provider.ModelInfo, bool) {
+	info, ok := models[model]
+	return info, ok
+}
+
+func (p *ClaudeProvider) ConversationRules() provider.ConversationRules {
+	return provider.ConversationRules{RequireUserFirst: true, RequireAlternation: true, SingleSystem: true}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
@@ -184,3 +185,177 @@ func TestSystemMessageExtraction(t *testing.T) {
 		t.Errorf("Expected first message role to be 'user', got %s", capturedReq.Messages[0].Role)
 	}
 }
+
+func TestCompleteStream_ReportsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\n")
+		fmt.Fprint(w, `data: {"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}`+"\n\n")
+		fmt.Fprint(w, "event: content_block_delta\n")
+		fmt.Fprint(w, `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}`+"\n\n")
+		fmt.Fprint(w, "event: message_delta\n")
+		fmt.Fprint(w, `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`+"\n\n")
+		fmt.Fprint(w, "event: message_stop\n")
+		fmt.Fprint(w, `data: {"type":"message_stop"}`+"\n\n")
+	}))
+	defer server.Close()
+
+	p := &ClaudeProvider{apiKey: "test-key", baseURL: server.URL}
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []provider.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var usage *provider.Usage
+	var stopReason string
+	for chunk := range ch {
+		if chunk.Done {
+			usage = chunk.Usage
+			stopReason = chunk.RawFinishReason
+		}
+	}
+	if usage == nil || usage.InputTokens != 25 || usage.OutputTokens != 15 {
+		t.Errorf("Expected usage 25/15, got %+v", usage)
+	}
+	if stopReason != "end_turn" {
+		t.Errorf("Expected the upstream stop reason, got %q", stopReason)
+	}
+}
+
+func TestMapRequest_Tools(t *testing.T) {
+	p := &ClaudeProvider{}
+	req := &provider.Request{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []provider.Message{
+			{Role: "user", Content: "weather in Paris and Rome?"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{
+				{ID: "toolu_1", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "toolu_2", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "18C"},
+			{Role: "tool", ToolCallID: "toolu_2", Content: "24C"},
+		},
+		Tools: []provider.Tool{{Type: "function", Function: provider.ToolFunction{
+			Name:       "get_weather",
+			Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}}},
+		ToolChoice: json.RawMessage(`{"type":"function","function":{"name":"get_weather"}}`),
+	}
+
+	got := p.mapRequest(req)
+
+	if len(got.Tools) != 1 || got.Tools[0].Name != "get_weather" || !strings.Contains(string(got.Tools[0].InputSchema), "city") {
+		t.Errorf("unexpected tools: %+v", got.Tools)
+	}
+	if got.ToolChoice == nil || got.ToolChoice.Type != "tool" || got.ToolChoice.Name != "get_weather" {
+		t.Errorf("unexpected tool_choice: %+v", got.ToolChoice)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d messages", len(got.Messages))
+	}
+	if blocks := got.Messages[1].Content; len(blocks) != 2 || blocks[0].Type != "tool_use" || blocks[0].ID != "toolu_1" || string(blocks[0].Input) != `{"city":"Paris"}` {
+		t.Errorf("unexpected assistant blocks: %+v", blocks)
+	}
+	results := got.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 || results.Content[1].Type != "tool_result" || results.Content[1].ToolUseID != "toolu_2" || results.Content[1].Content != "24C" {
+		t.Errorf("expected merged tool_result turn, got %+v", results)
+	}
+}
+
+func TestComplete_ToolUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","model":"claude-3-5-sonnet-20241022","content":[
+			{"type":"text","text":"Checking."},
+			{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}
+		],"usage":{"input_tokens":5,"output_tokens":7}}`)
+	}))
+	defer server.Close()
+
+	p := &ClaudeProvider{apiKey: "test-key", baseURL: server.URL}
+	resp, err := p.Complete(context.Background(), &provider.Request{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []provider.Message{{Role: "user", Content: "weather?"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Content != "Checking." {
+		t.Errorf("expected text content, got %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || resp.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+}
+
+func TestCompleteStream_ToolUse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: content_block_start\n")
+		fmt.Fprint(w, `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`+"\n\n")
+		fmt.Fprint(w, "event: content_block_delta\n")
+		fmt.Fprint(w, `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`+"\n\n")
+		fmt.Fprint(w, "event: content_block_delta\n")
+		fmt.Fprint(w, `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`+"\n\n")
+		fmt.Fprint(w, "event: content_block_stop\n")
+		fmt.Fprint(w, `data: {"type":"content_block_stop","index":1}`+"\n\n")
+		fmt.Fprint(w, "event: message_stop\n")
+		fmt.Fprint(w, `data: {"type":"message_stop"}`+"\n\n")
+	}))
+	defer server.Close()
+
+	p := &ClaudeProvider{apiKey: "test-key", baseURL: server.URL}
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "claude-3-5-sonnet-20241022",
+		Messages: []provider.Message{{Role: "user", Content: "weather?"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var calls []provider.ToolCall
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Received error from chunk: %v", chunk.Err)
+		}
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if len(calls) != 1 || calls[0].ID != "toolu_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
+
+func TestResponseFormat_EmulatedWithSystemPrompt(t *testing.T) {
+	var sent claudeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{\"id\":\"msg_1\",\"content\":[{\"type\":\"text\",\"text\":\"```json\\n{\\\"city\\\":\\\"Paris\\\"}\\n```\"}]}")
+	}))
+	defer server.Close()
+
+	p := &ClaudeProvider{apiKey: "test-key", baseURL: server.URL}
+	resp, err := p.Complete(context.Background(), &provider.Request{
+		Model: "claude-3-5-sonnet-20241022",
+		Messages: []provider.Message{
+			{Role: "system", Content: "You are a travel agent."},
+			{Role: "user", Content: "Pick a city."},
+		},
+		ResponseFormat: &provider.ResponseFormat{Type: "json_schema", JSONSchema: &provider.JSONSchema{
+			Name:   "city",
+			Schema: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if !strings.HasPrefix(sent.System, "You are a travel agent.") || !strings.Contains(sent.System, `"city"`) {
+		t.Errorf("expected the schema appended to the system prompt, got %q", sent.System)
+	}
+	if resp.Content != `{"city":"Paris"}` {
+		t.Errorf("expected the code fence stripped, got %q", resp.Content)
+	}
+}
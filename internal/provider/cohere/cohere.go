@@ -0,0 +1,501 @@
+package cohere
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// CohereProvider serves Command R models through Cohere's chat API, which
+// takes the latest user turn as message and everything before it as
+// chat_history, with roles named USER, CHATBOT, SYSTEM and TOOL.
+type CohereProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+
+	keyMu sync.RWMutex // guards apiKey, replaced by SetAPIKey
+}
+
+type Option func(*CohereProvider)
+
+// WithBaseURL overrides the API base URL, e.g. the primary of a list of
+// regional endpoints.
+func WithBaseURL(baseURL string) Option {
+	return func(p *CohereProvider) {
+		if baseURL != "" {
+			p.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sends upstream requests through client, e.g. one built from
+// provider.Egress (default: http.DefaultClient).
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *CohereProvider) {
+		p.client = client
+	}
+}
+
+type chatRequest struct {
+	Model string `json:"model"`
+	// Message is the latest user turn; empty when ToolResults answer the
+	// previous turn's tool calls instead.
+	Message        string          `json:"message"`
+	Preamble       string          `json:"preamble,omitempty"`
+	ChatHistory    []chatMessage   `json:"chat_history,omitempty"`
+	ToolResults    []toolResult    `json:"tool_results,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Temperature    float64         `json:"temperature,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	Tools          []tool          `json:"tools,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+// Cohere's names for chat_history roles.
+const (
+	roleUser    = "USER"
+	roleChatbot = "CHATBOT"
+	roleTool    = "TOOL"
+)
+
+// chatMessage is a chat_history entry: a USER or CHATBOT message, with the
+// CHATBOT's tool calls, or a TOOL turn carrying their results.
+type chatMessage struct {
+	Role        string       `json:"role"`
+	Message     string       `json:"message,omitempty"`
+	ToolCalls   []toolCall   `json:"tool_calls,omitempty"`
+	ToolResults []toolResult `json:"tool_results,omitempty"`
+}
+
+// toolCall is Cohere's tool call. It has no ID: results name the call they
+// answer by repeating it.
+type toolCall struct {
+	Name       string          `json:"name"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+type toolResult struct {
+	Call    toolCall          `json:"call"`
+	Outputs []json.RawMessage `json:"outputs"`
+}
+
+type tool struct {
+	Name                 string                         `json:"name"`
+	Description          string                         `json:"description"`
+	ParameterDefinitions map[string]parameterDefinition `json:"parameter_definitions,omitempty"`
+}
+
+type parameterDefinition struct {
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type responseFormat struct {
+	Type   string          `json:"type"` // json_object
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+type chatResponse struct {
+	ResponseID   string     `json:"response_id"`
+	GenerationID string     `json:"generation_id"`
+	Text         string     `json:"text"`
+	ToolCalls    []toolCall `json:"tool_calls"`
+	FinishReason string     `json:"finish_reason"`
+	Meta         struct {
+		BilledUnits billedUnits `json:"billed_units"`
+	} `json:"meta"`
+}
+
+type billedUnits struct {
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+}
+
+// streamEvent is one line of a chat stream, which is newline-delimited JSON
+// rather than SSE: stream-start, text-generation deltas, tool-calls-generation
+// once the calls are complete, and stream-end with the finish reason and the
+// whole response, usage included.
+type streamEvent struct {
+	EventType    string        `json:"event_type"`
+	GenerationID string        `json:"generation_id"`
+	Text         string        `json:"text"`
+	ToolCalls    []toolCall    `json:"tool_calls"`
+	FinishReason string        `json:"finish_reason"`
+	Response     *chatResponse `json:"response"`
+}
+
+func New(apiKey string, opts ...Option) provider.Provider {
+	p := &CohereProvider{
+		apiKey:  apiKey,
+		baseURL: "https://api.cohere.com/v1",
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *CohereProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return http.DefaultClient
+}
+
+// SetAPIKey replaces the key sent with subsequent requests.
+func (p *CohereProvider) SetAPIKey(key string) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+	p.apiKey = key
+}
+
+func (p *CohereProvider) key() string {
+	p.keyMu.RLock()
+	defer p.keyMu.RUnlock()
+	return p.apiKey
+}
+
+func (p *CohereProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, err
+	}
+	return p.response(req.Model, &chatResp), nil
+}
+
+// response converts a chat response, which doesn't name the model.
+func (p *CohereProvider) response(model string, r *chatResponse) *provider.Response {
+	return &provider.Response{
+		ID:              r.ResponseID,
+		Content:         r.Text,
+		ToolCalls:       toolCalls(r.GenerationID, r.ToolCalls),
+		InputTokens:     int(r.Meta.BilledUnits.InputTokens),
+		OutputTokens:    int(r.Meta.BilledUnits.OutputTokens),
+		Model:           model,
+		Provider:        p.Name(),
+		RawFinishReason: r.FinishReason,
+	}
+}
+
+// toolCalls converts a generation's tool calls, giving each an ID from the
+// generation, since Cohere assigns none.
+func toolCalls(generationID string, calls []toolCall) []provider.ToolCall {
+	var out []provider.ToolCall
+	for i, c := range calls {
+		args := "{}"
+		if len(c.Parameters) > 0 && string(c.Parameters) != "null" {
+			args = string(c.Parameters)
+		}
+		out = append(out, provider.ToolCall{
+			ID:       fmt.Sprintf("call_%s_%d", generationID, i),
+			Type:     "function",
+			Function: provider.ToolCallFunction{Name: c.Name, Arguments: args},
+		})
+	}
+	return out
+}
+
+func (p *CohereProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk)
+
+	provider.Streams.Go("cohere", func() {
+		defer close(ch)
+
+		send := func(c *provider.Chunk) bool {
+			select {
+			case ch <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		resp, err := p.post(ctx, req, true)
+		if err != nil {
+			send(&provider.Chunk{Err: err})
+			return
+		}
+		defer resp.Body.Close()
+		p.readStream(resp.Body, send)
+	})
+
+	return ch, nil
+}
+
+// readStream relays a chat stream's events until stream-end.
+func (p *CohereProvider) readStream(body io.Reader, send func(*provider.Chunk) bool) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var generationID string
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ev streamEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			send(&provider.Chunk{Err: fmt.Errorf("cohere stream: %w", err)})
+			return
+		}
+
+		switch ev.EventType {
+		case "stream-start":
+			generationID = ev.GenerationID
+		case "text-generation":
+			if ev.Text != "" && !send(&provider.Chunk{Delta: ev.Text}) {
+				return
+			}
+		case "tool-calls-generation":
+			if calls := toolCalls(generationID, ev.ToolCalls); len(calls) > 0 && !send(&provider.Chunk{ToolCalls: calls}) {
+				return
+			}
+		case "stream-end":
+			if ev.FinishReason == "ERROR" {
+				send(&provider.Chunk{Err: fmt.Errorf("cohere stream error")})
+				return
+			}
+			done := &provider.Chunk{Done: true, RawFinishReason: ev.FinishReason}
+			if ev.Response != nil {
+				done.Usage = &provider.Usage{
+					InputTokens:  int(ev.Response.Meta.BilledUnits.InputTokens),
+					OutputTokens: int(ev.Response.Meta.BilledUnits.OutputTokens),
+				}
+			}
+			send(done)
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		send(&provider.Chunk{Err: err})
+		return
+	}
+	send(&provider.Chunk{Done: true})
+}
+
+// post sends req to the chat API and returns the response once the upstream
+// has accepted it.
+func (p *CohereProvider) post(ctx context.Context, req *provider.Request, stream bool) (*http.Response, error) {
+	chatReq := mapRequest(req)
+	chatReq.Stream = stream
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/chat", p.baseURL), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
+
+	resp, err := p.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+// mapRequest splits OpenAI-style messages into Cohere's preamble, history
+// and latest turn. Tool results are keyed by the call they answer, which is
+// looked up from the assistant turns by tool_call_id.
+func mapRequest(req *provider.Request) chatRequest {
+	calls := make(map[string]provider.ToolCall)
+	for _, m := range req.Messages {
+		for _, tc := range m.ToolCalls {
+			calls[tc.ID] = tc
+		}
+	}
+
+	var system []string
+	var history []chatMessage
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "assistant":
+			msg := chatMessage{Role: roleChatbot, Message: m.Content}
+			for _, tc := range m.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, mapToolCall(tc))
+			}
+			history = append(history, msg)
+		case "tool":
+			result := toolResult{Call: mapToolCall(calls[m.ToolCallID]), Outputs: []json.RawMessage{toolOutput(m.Content)}}
+			// Parallel tool results come back in a single TOOL turn.
+			if n := len(history); n > 0 && history[n-1].Role == roleTool {
+				history[n-1].ToolResults = append(history[n-1].ToolResults, result)
+				continue
+			}
+			history = append(history, chatMessage{Role: roleTool, ToolResults: []toolResult{result}})
+		default:
+			history = append(history, chatMessage{Role: roleUser, Message: m.Content})
+		}
+	}
+
+	out := chatRequest{
+		Model:       req.Model,
+		Preamble:    strings.Join(system, "\n\n"),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Tools:       mapTools(req.Tools),
+	}
+	// The latest turn travels outside chat_history.
+	if n := len(history); n > 0 {
+		switch last := history[n-1]; last.Role {
+		case roleUser:
+			out.Message = last.Message
+			history = history[:n-1]
+		case roleTool:
+			out.ToolResults = last.ToolResults
+			history = history[:n-1]
+		}
+	}
+	out.ChatHistory = history
+	if req.ResponseFormat.WantsJSON() {
+		out.ResponseFormat = &responseFormat{Type: "json_object", Schema: req.ResponseFormat.Schema()}
+	}
+	return out
+}
+
+func mapToolCall(tc provider.ToolCall) toolCall {
+	params := json.RawMessage(tc.Function.Arguments)
+	if !json.Valid(params) {
+		params = json.RawMessage("{}")
+	}
+	return toolCall{Name: tc.Function.Name, Parameters: params}
+}
+
+// toolOutput is a tool message as a Cohere output object: the content when
+// it is one, else the content wrapped in one.
+func toolOutput(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	b, _ := json.Marshal(map[string]string{"content": content})
+	return b
+}
+
+// schemaTypes maps JSON Schema types to the Python-style names Cohere's
+// parameter definitions use.
+var schemaTypes = map[string]string{
+	"string":  "str",
+	"integer": "int",
+	"number":  "float",
+	"boolean": "bool",
+	"array":   "list",
+	"object":  "dict",
+}
+
+// mapTools converts each tool's JSON Schema parameters into Cohere's flat
+// parameter definitions. Nested schemas are described by their top-level
+// type only.
+func mapTools(tools []provider.Tool) []tool {
+	var out []tool
+	for _, t := range tools {
+		var schema struct {
+			Properties map[string]struct {
+				Type        string `json:"type"`
+				Description string `json:"description"`
+			} `json:"properties"`
+			Required []string `json:"required"`
+		}
+		_ = json.Unmarshal(t.Function.Parameters, &schema)
+
+		var defs map[string]parameterDefinition
+		for name, prop := range schema.Properties {
+			if defs == nil {
+				defs = make(map[string]parameterDefinition)
+			}
+			typ, ok := schemaTypes[prop.Type]
+			if !ok {
+				typ = "str"
+			}
+			defs[name] = parameterDefinition{Description: prop.Description, Type: typ}
+		}
+		for _, name := range schema.Required {
+			if def, ok := defs[name]; ok {
+				def.Required = true
+				defs[name] = def
+			}
+		}
+		out = append(out, tool{Name: t.Function.Name, Description: t.Function.Description, ParameterDefinitions: defs})
+	}
+	return out
+}
+
+// ExtraFields lists chat API parameters the gateway passes through.
+func (p *CohereProvider) ExtraFields() []string {
+	return []string{"p", "k", "seed", "stop_sequences", "frequency_penalty", "presence_penalty",
+		"documents", "citation_quality", "safety_mode", "prompt_truncation", "force_single_step"}
+}
+
+func (p *CohereProvider) Name() string {
+	return "cohere"
+}
+
+// Ping lists the upstream's models, which costs nothing.
+func (p *CohereProvider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", p.baseURL), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
+	return provider.Ping(p.httpClient(), httpReq)
+}
+
+func (p *CohereProvider) CostPerInputToken() float64 {
+	return 0.00000015
+}
+
+func (p *CohereProvider) CostPerOutputToken() float64 {
+	return 0.0000006
+}
+
+func (p *CohereProvider) SupportedModels() []string {
+	return []string{
+		"command-r-plus-08-2024",
+		"command-r-plus",
+		"command-r-08-2024",
+		"command-r",
+	}
+}
+
+var chatCapabilities = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools, provider.CapabilityJSONMode}
+
+var models = map[string]provider.ModelInfo{
+	"command-r-plus-08-2024": {ContextWindow: 128000, MaxOutputTokens: 4000, Capabilities: chatCapabilities},
+	"command-r-plus":         {ContextWindow: 128000, MaxOutputTokens: 4000, Capabilities: chatCapabilities},
+	"command-r-08-2024":      {ContextWindow: 128000, MaxOutputTokens: 4000, Capabilities: chatCapabilities},
+	"command-r":              {ContextWindow: 128000, MaxOutputTokens: 4000, Capabilities: chatCapabilities},
+}
+
+func (p *CohereProvider) ModelInfo(model string) (provider.ModelInfo, bool) {
+	info, ok := models[model]
+	return info, ok
+}
@@ -0,0 +1,136 @@
+package cohere
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestComplete_Mock(t *testing.T) {
+	var got chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat" || r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("unexpected request %s with auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"response_id":"resp-1","generation_id":"gen-1","text":"Hello from Cohere!","finish_reason":"COMPLETE",
+			"meta":{"billed_units":{"input_tokens":10,"output_tokens":20}}}`)
+	}))
+	defer server.Close()
+
+	p := New("test-key", WithBaseURL(server.URL))
+	resp, err := p.Complete(context.Background(), &provider.Request{
+		Model: "command-r",
+		Messages: []provider.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+			{Role: "user", Content: "how are you?"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if resp.Content != "Hello from Cohere!" || resp.InputTokens != 10 || resp.OutputTokens != 20 || resp.Model != "command-r" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if provider.NormalizeFinishReason(resp.RawFinishReason, false) != provider.FinishStop {
+		t.Errorf("expected COMPLETE to normalize to stop, got %q", resp.RawFinishReason)
+	}
+	if got.Preamble != "be brief" || got.Message != "how are you?" {
+		t.Errorf("expected the system prompt as preamble and the last turn as message, got %+v", got)
+	}
+	if len(got.ChatHistory) != 2 || got.ChatHistory[0].Role != "USER" || got.ChatHistory[1].Role != "CHATBOT" {
+		t.Errorf("expected USER and CHATBOT history, got %+v", got.ChatHistory)
+	}
+}
+
+func TestMapRequest_ToolResultsAnswerTheirCalls(t *testing.T) {
+	req := mapRequest(&provider.Request{
+		Model: "command-r-plus",
+		Messages: []provider.Message{
+			{Role: "user", Content: "weather in Paris and Rome?"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{
+				{ID: "call_a", Function: provider.ToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_b", Function: provider.ToolCallFunction{Name: "weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_a", Content: `{"temp":18}`},
+			{Role: "tool", ToolCallID: "call_b", Content: "21 degrees"},
+		},
+		Tools: []provider.Tool{{Type: "function", Function: provider.ToolFunction{
+			Name:       "weather",
+			Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string","description":"City name"}},"required":["city"]}`),
+		}}},
+	})
+
+	if req.Message != "" || len(req.ToolResults) != 2 {
+		t.Fatalf("expected the tool results as the latest turn, got %+v", req)
+	}
+	if string(req.ToolResults[1].Call.Parameters) != `{"city":"Rome"}` || string(req.ToolResults[1].Outputs[0]) != `{"content":"21 degrees"}` {
+		t.Errorf("expected the second result tied to the Rome call, got %+v", req.ToolResults[1])
+	}
+	if len(req.ChatHistory) != 2 || len(req.ChatHistory[1].ToolCalls) != 2 {
+		t.Errorf("expected the user turn and the CHATBOT's calls in history, got %+v", req.ChatHistory)
+	}
+	def := req.Tools[0].ParameterDefinitions["city"]
+	if def.Type != "str" || !def.Required || def.Description != "City name" {
+		t.Errorf("unexpected parameter definition: %+v", def)
+	}
+}
+
+func TestCompleteStream_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("expected stream=true")
+		}
+		fmt.Fprintln(w, `{"is_finished":false,"event_type":"stream-start","generation_id":"gen-1"}`)
+		fmt.Fprintln(w, `{"is_finished":false,"event_type":"text-generation","text":"Hello"}`)
+		fmt.Fprintln(w, `{"is_finished":false,"event_type":"text-generation","text":" world!"}`)
+		fmt.Fprintln(w, `{"is_finished":false,"event_type":"tool-calls-generation","tool_calls":[{"name":"weather","parameters":{"city":"Paris"}}]}`)
+		fmt.Fprintln(w, `{"is_finished":true,"event_type":"stream-end","finish_reason":"MAX_TOKENS","response":{"meta":{"billed_units":{"input_tokens":5,"output_tokens":3}}}}`)
+	}))
+	defer server.Close()
+
+	p := New("test-key", WithBaseURL(server.URL))
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "command-r",
+		Messages: []provider.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var text string
+	var calls []provider.ToolCall
+	var last *provider.Chunk
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		text += chunk.Delta
+		calls = append(calls, chunk.ToolCalls...)
+		last = chunk
+	}
+
+	if text != "Hello world!" {
+		t.Errorf("expected 'Hello world!', got %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_gen-1_0" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+	if last == nil || !last.Done || last.Usage == nil || last.Usage.InputTokens != 5 || last.Usage.OutputTokens != 3 {
+		t.Fatalf("expected a done chunk with usage, got %+v", last)
+	}
+	if provider.NormalizeFinishReason(last.RawFinishReason, false) != provider.FinishLength {
+		t.Errorf("expected MAX_TOKENS to normalize to length, got %q", last.RawFinishReason)
+	}
+}
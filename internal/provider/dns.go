@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCache resolves upstream hostnames at most once per ttl. When a refresh
+// fails, the last known addresses keep being used, so a resolver outage
+// doesn't take the providers down with it.
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		now:      time.Now,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		if ok {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// forget drops host so the next dial resolves it again.
+func (c *dnsCache) forget(host string) {
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
+// dialContext wraps dial to connect to cached addresses in order.
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		ips, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		// The records may have moved; resolve afresh next time.
+		c.forget(host)
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Egress controls how one provider's traffic leaves the gateway.
+type Egress struct {
+	// ProxyURL is an http, https or socks5 proxy. Empty falls back to
+	// HTTP_PROXY/HTTPS_PROXY from the environment.
+	ProxyURL string
+	// CABundle is a PEM file trusted in addition to the system roots, e.g.
+	// the CA of a TLS-inspecting proxy.
+	CABundle string
+	// BindIP is the local source address for outbound connections.
+	BindIP string
+	// DNSCacheTTL caches upstream hostname lookups for this long; zero
+	// resolves on every new connection.
+	DNSCacheTTL time.Duration
+
+	// ConnectTimeout bounds dialing and the TLS handshake; zero keeps Go's
+	// defaults (30s and 10s).
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds the wait for response headers after the request is
+	// sent, so a hung upstream fails instead of holding the handler. It
+	// doesn't limit how long a stream's body may run. Zero waits forever.
+	ReadTimeout time.Duration
+	// MaxIdleConnsPerHost is how many keep-alive connections are pooled per
+	// upstream host; zero keeps Go's default of 2, which forces most
+	// concurrent requests to open new connections.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes pooled connections unused for this long; zero
+	// keeps Go's default (90s).
+	IdleConnTimeout time.Duration
+}
+
+// HTTPClient builds a client honouring e. The zero Egress returns
+// http.DefaultClient.
+func (e Egress) HTTPClient() (*http.Client, error) {
+	if e == (Egress{}) {
+		return http.DefaultClient, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = e.ReadTimeout
+	if e.ConnectTimeout > 0 {
+		transport.TLSHandshakeTimeout = e.ConnectTimeout
+	}
+	if e.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = e.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, e.MaxIdleConnsPerHost)
+	}
+	if e.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = e.IdleConnTimeout
+	}
+
+	if e.ProxyURL != "" {
+		proxyURL, err := url.Parse(e.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if e.CABundle != "" {
+		pem, err := os.ReadFile(e.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", e.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	if e.BindIP != "" || e.DNSCacheTTL > 0 || e.ConnectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		if e.ConnectTimeout > 0 {
+			dialer.Timeout = e.ConnectTimeout
+		}
+		if e.BindIP != "" {
+			ip := net.ParseIP(e.BindIP)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid bind ip %q", e.BindIP)
+			}
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+		transport.DialContext = dialer.DialContext
+		if e.DNSCacheTTL > 0 {
+			transport.DialContext = newDNSCache(e.DNSCacheTTL).dialContext(dialer.DialContext)
+		}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEgress_ZeroUsesDefaultClient(t *testing.T) {
+	client, err := Egress{}.HTTPClient()
+	if err != nil || client != http.DefaultClient {
+		t.Errorf("expected http.DefaultClient, got %v (%v)", client, err)
+	}
+}
+
+func TestEgress_RoutesThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	client, err := Egress{ProxyURL: proxy.URL, BindIP: "127.0.0.1"}.HTTPClient()
+	if err != nil {
+		t.Fatalf("HTTPClient failed: %v", err)
+	}
+	resp, err := client.Get("http://upstream.invalid/v1/chat/completions")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://upstream.invalid/v1/chat/completions" {
+		t.Errorf("expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestEgress_TrustsCABundle(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := http.DefaultClient.Get(upstream.URL); err == nil {
+		t.Fatal("expected the default client to reject the test certificate")
+	}
+	client, err := Egress{CABundle: bundle}.HTTPClient()
+	if err != nil {
+		t.Fatalf("HTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("expected the bundle to be trusted: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestEgress_InvalidSettings(t *testing.T) {
+	if _, err := (Egress{CABundle: filepath.Join(t.TempDir(), "missing.pem")}).HTTPClient(); err == nil {
+		t.Error("expected a missing CA bundle to fail")
+	}
+	if _, err := (Egress{BindIP: "not-an-ip"}).HTTPClient(); err == nil {
+		t.Error("expected an invalid bind IP to fail")
+	}
+}
+
+func TestEgress_ReadTimeoutFailsHungUpstream(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	client, err := Egress{ReadTimeout: 50 * time.Millisecond, MaxIdleConnsPerHost: 16}.HTTPClient()
+	if err != nil {
+		t.Fatalf("HTTPClient failed: %v", err)
+	}
+	if transport := client.Transport.(*http.Transport); transport.MaxIdleConnsPerHost != 16 {
+		t.Errorf("expected 16 pooled connections per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if _, err := client.Get(upstream.URL); err == nil {
+		t.Error("expected the hung upstream to time out")
+	}
+}
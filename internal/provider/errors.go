@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrRateLimited means the upstream rejected the request with 429.
+	ErrRateLimited = errors.New("upstream rate limited")
+	// ErrContentFiltered means the upstream refused the prompt or output on safety grounds.
+	ErrContentFiltered = errors.New("content filtered by upstream")
+	// ErrBadRequest means the upstream rejected the request as invalid (4xx).
+	ErrBadRequest = errors.New("upstream rejected request")
+	// ErrContextLength means the prompt, or the prompt plus max_tokens,
+	// exceeded the model's context window. It is also an ErrBadRequest.
+	ErrContextLength = fmt.Errorf("context length exceeded: %w", ErrBadRequest)
+	// ErrUpstream means the upstream failed (5xx or an unexpected status).
+	ErrUpstream = errors.New("upstream error")
+)
+
+// APIError is a non-2xx response from a provider API. It unwraps to one of
+// the sentinels above so callers can branch with errors.Is.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func NewAPIError(providerName string, statusCode int, body []byte) *APIError {
+	return &APIError{Provider: providerName, StatusCode: statusCode, Body: string(body)}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s api error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case isContentFilter(e.Body):
+		return ErrContentFiltered
+	case e.StatusCode >= 400 && e.StatusCode < 500 && isContextLength(e.Body):
+		return ErrContextLength
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return ErrBadRequest
+	default:
+		return ErrUpstream
+	}
+}
+
+// isContentFilter recognises the safety-rejection markers used by OpenAI
+// ("content_filter", "content_policy_violation") and Gemini ("SAFETY").
+func isContentFilter(body string) bool {
+	return strings.Contains(body, "content_filter") ||
+		strings.Contains(body, "content_policy") ||
+		strings.Contains(body, "SAFETY")
+}
+
+// contextLengthMarkers are how OpenAI (and OpenAI-compatible servers),
+// Claude, Gemini and Cohere word a request too long for the model's context window.
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"prompt is too long",
+	"exceed context limit",
+	"exceeds the maximum number of tokens",
+	"total number of tokens in the prompt cannot exceed",
+}
+
+func isContextLength(body string) bool {
+	body = strings.ToLower(body)
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
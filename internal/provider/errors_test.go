@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAPIError_Classification(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusTooManyRequests, `{"error":"rate limit"}`, ErrRateLimited},
+		{http.StatusBadRequest, `{"error":{"code":"content_filter"}}`, ErrContentFiltered},
+		{http.StatusBadRequest, `{"error":"bad model"}`, ErrBadRequest},
+		{http.StatusBadRequest, `{"error":{"code":"context_length_exceeded"}}`, ErrContextLength},
+		{http.StatusBadRequest, `{"error":{"message":"prompt is too long: 210000 tokens > 200000 maximum"}}`, ErrContextLength},
+		{http.StatusBadRequest, `{"error":{"code":"context_length_exceeded"}}`, ErrBadRequest},
+		{http.StatusServiceUnavailable, `overloaded`, ErrUpstream},
+	}
+	for _, tt := range tests {
+		err := NewAPIError("test", tt.status, []byte(tt.body))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d body %q: expected %v, got %v", tt.status, tt.body, tt.want, err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("Expected *APIError with status %d, got %v", tt.status, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ExtraFieldsProvider is implemented by providers that accept passthrough
+// fields in their upstream payload. ExtraFields lists the top-level payload
+// fields tenants may set through extra_body or provider_options.
+type ExtraFieldsProvider interface {
+	ExtraFields() []string
+}
+
+// MergeExtra sets extra's fields on the JSON object body. An object merged
+// onto an existing object is merged key by key, so e.g. {"options":{"top_k":5}}
+// keeps the options the provider already mapped; other values replace.
+func MergeExtra(body []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return body, nil
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode payload for extra fields: %w", err)
+	}
+	for field, value := range extra {
+		merged, err := mergeObject(payload[field], value)
+		if err != nil {
+			return nil, fmt.Errorf("extra field %s: %w", field, err)
+		}
+		payload[field] = merged
+	}
+	return json.Marshal(payload)
+}
+
+func mergeObject(base, extra json.RawMessage) (json.RawMessage, error) {
+	var baseObj, extraObj map[string]json.RawMessage
+	if len(base) == 0 || json.Unmarshal(base, &baseObj) != nil || json.Unmarshal(extra, &extraObj) != nil || extraObj == nil {
+		if !json.Valid(extra) {
+			return nil, fmt.Errorf("invalid JSON value")
+		}
+		return extra, nil
+	}
+	for k, v := range extraObj {
+		baseObj[k] = v
+	}
+	return json.Marshal(baseObj)
+}
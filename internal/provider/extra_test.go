@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergeExtra(t *testing.T) {
+	body := []byte(`{"model":"m","options":{"num_predict":100,"temperature":0.5}}`)
+	merged, err := MergeExtra(body, map[string]json.RawMessage{
+		"options":    json.RawMessage(`{"top_k":5,"temperature":0.1}`),
+		"keep_alive": json.RawMessage(`"5m"`),
+	})
+	if err != nil {
+		t.Fatalf("MergeExtra failed: %v", err)
+	}
+
+	var got struct {
+		Model     string             `json:"model"`
+		Options   map[string]float64 `json:"options"`
+		KeepAlive string             `json:"keep_alive"`
+	}
+	if err := json.Unmarshal(merged, &got); err != nil {
+		t.Fatalf("invalid merged payload: %v", err)
+	}
+	if got.Model != "m" || got.KeepAlive != "5m" {
+		t.Errorf("unexpected payload: %s", merged)
+	}
+	want := map[string]float64{"num_predict": 100, "temperature": 0.1, "top_k": 5}
+	for k, v := range want {
+		if got.Options[k] != v {
+			t.Errorf("options[%s] = %v, want %v (payload %s)", k, got.Options[k], v, merged)
+		}
+	}
+}
+
+func TestMergeExtra_InvalidValue(t *testing.T) {
+	if _, err := MergeExtra([]byte(`{}`), map[string]json.RawMessage{"seed": json.RawMessage(`{`)}); err == nil {
+		t.Error("expected invalid JSON to be rejected")
+	}
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// Failover is an http.RoundTripper that spreads one provider over an ordered
+// list of base URLs, e.g. regional endpoints of the same API. Requests for
+// any of the base URLs go to the first healthy one; connection errors and
+// 5xx responses move on to the next.
+//
+// Each endpoint has its own circuit breaker: a failure takes it out of
+// rotation for the cooldown, after which one request checks whether it is
+// back.
+type Failover struct {
+	endpoints []*endpoint
+	next      http.RoundTripper
+}
+
+type endpoint struct {
+	base    string
+	breaker *gobreaker.CircuitBreaker
+}
+
+// errEndpointUnavailable marks a gateway-style response as an endpoint failure.
+var errEndpointUnavailable = errors.New("endpoint unavailable")
+
+// NewFailover routes over baseURLs, primary first, sending requests through
+// next (http.DefaultTransport when nil).
+func NewFailover(baseURLs []string, cooldown time.Duration, next http.RoundTripper) (*Failover, error) {
+	if len(baseURLs) == 0 {
+		return nil, fmt.Errorf("failover needs at least one endpoint")
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	f := &Failover{next: next}
+	for _, raw := range baseURLs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q", raw)
+		}
+		base := strings.TrimRight(raw, "/")
+		f.endpoints = append(f.endpoints, &endpoint{
+			base: base,
+			breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+				Name:        base,
+				MaxRequests: 1,
+				Timeout:     cooldown,
+				ReadyToTrip: func(counts gobreaker.Counts) bool {
+					return counts.ConsecutiveFailures >= 1
+				},
+				// A client giving up says nothing about the endpoint.
+				IsSuccessful: func(err error) bool {
+					return err == nil || errors.Is(err, context.Canceled)
+				},
+				OnStateChange: func(name string, from, to gobreaker.State) {
+					log.Printf("provider: endpoint %s %s -> %s", name, from, to)
+				},
+			}),
+		})
+	}
+	return f, nil
+}
+
+// EndpointStatus is an endpoint's circuit breaker as seen by Failover.
+type EndpointStatus struct {
+	URL   string `json:"url"`
+	State string `json:"state"` // closed, half-open (checking) or open (cooling down)
+	// Counts cover the breaker's current state.
+	Requests            uint32 `json:"requests"`
+	Failures            uint32 `json:"failures"`
+	ConsecutiveFailures uint32 `json:"consecutive_failures"`
+}
+
+// Statuses reports each endpoint's breaker, primary first.
+func (f *Failover) Statuses() []EndpointStatus {
+	out := make([]EndpointStatus, len(f.endpoints))
+	for i, ep := range f.endpoints {
+		counts := ep.breaker.Counts()
+		out[i] = EndpointStatus{
+			URL:                 ep.base,
+			State:               ep.breaker.State().String(),
+			Requests:            counts.Requests,
+			Failures:            counts.TotalFailures,
+			ConsecutiveFailures: counts.ConsecutiveFailures,
+		}
+	}
+	return out
+}
+
+// Primary is the base URL providers should build request URLs from.
+func (f *Failover) Primary() string {
+	return f.endpoints[0].base
+}
+
+func (f *Failover) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := f.relative(req.URL.String())
+	if !ok {
+		return f.next.RoundTrip(req)
+	}
+	// Without GetBody the body can only be sent once.
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	var lastErr error
+	// failed is the latest 5xx, kept as the upstream's answer until a later
+	// endpoint answers in its place.
+	var failed *http.Response
+	attempted := false
+	for i, ep := range f.endpoints {
+		if attempted && !replayable {
+			break
+		}
+		out, err := f.rewrite(req, ep.base+path, attempted)
+		if err != nil {
+			return nil, err
+		}
+		last := i == len(f.endpoints)-1
+		result, err := ep.breaker.Execute(func() (interface{}, error) {
+			resp, err := f.next.RoundTrip(out)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return resp, fmt.Errorf("%w: status %d", errEndpointUnavailable, resp.StatusCode)
+			}
+			return resp, nil
+		})
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			continue
+		}
+		attempted = true
+		resp, _ := result.(*http.Response)
+		if resp != nil && failed != nil {
+			failed.Body.Close()
+			failed = nil
+		}
+		if err == nil || (errors.Is(err, errEndpointUnavailable) && last) {
+			return resp, nil
+		}
+		if resp != nil {
+			failed = resp
+		}
+		if req.Context().Err() != nil {
+			if failed != nil {
+				failed.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+		lastErr = fmt.Errorf("%s: %w", ep.base, err)
+	}
+	if failed != nil {
+		// No later endpoint answered; pass on the upstream's own error.
+		return failed, nil
+	}
+	if attempted {
+		return nil, lastErr
+	}
+	// Every endpoint is cooling down; trying the primary beats failing outright.
+	out, err := f.rewrite(req, f.endpoints[0].base+path, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.next.RoundTrip(out)
+}
+
+// relative returns raw's path and query below whichever endpoint it targets.
+func (f *Failover) relative(raw string) (string, bool) {
+	for _, ep := range f.endpoints {
+		if rest, ok := strings.CutPrefix(raw, ep.base); ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// rewrite clones req for target, with a fresh body for retries.
+func (f *Failover) rewrite(req *http.Request, target string, retry bool) (*http.Request, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	out := req.Clone(req.Context())
+	out.URL = u
+	out.Host = ""
+	if retry && req.GetBody != nil {
+		if out.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFailover_MovesToNextEndpoint(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	var gotPath, gotBody string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.RequestURI(), string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer up.Close()
+
+	f, err := NewFailover([]string{down.URL + "/v1", up.URL + "/v1"}, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("NewFailover failed: %v", err)
+	}
+	client := &http.Client{Transport: f}
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPost, f.Primary()+"/chat/completions?x=1", bytes.NewReader([]byte(`{"a":1}`)))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: expected 200 from the second endpoint, got %d", i, resp.StatusCode)
+		}
+	}
+	if gotPath != "/v1/chat/completions?x=1" || gotBody != `{"a":1}` {
+		t.Errorf("expected path and body to be replayed, got %q %q", gotPath, gotBody)
+	}
+}
+
+func TestFailover_SkipsEndpointDuringCooldown(t *testing.T) {
+	hits := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	f, _ := NewFailover([]string{down.URL, up.URL}, time.Minute, nil)
+	client := &http.Client{Transport: f}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(down.URL + "/models")
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if hits != 1 {
+		t.Errorf("expected the failed endpoint to be tried once, got %d", hits)
+	}
+}
+
+func TestFailover_ServerErrorOpensEndpointBreaker(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	f, _ := NewFailover([]string{down.URL, up.URL}, time.Minute, nil)
+	resp, err := (&http.Client{Transport: f}).Get(down.URL + "/chat")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a 500 to fail over, got %d", resp.StatusCode)
+	}
+
+	statuses := f.Statuses()
+	if len(statuses) != 2 || statuses[0].URL != down.URL || statuses[0].State != "open" || statuses[1].State != "closed" {
+		t.Errorf("expected the failed endpoint's breaker open and the other closed, got %+v", statuses)
+	}
+}
+
+func TestFailover_PassesThroughLastEndpointResponse(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	f, _ := NewFailover([]string{"http://127.0.0.1:1", down.URL}, time.Minute, nil)
+	resp, err := (&http.Client{Transport: f}).Get("http://127.0.0.1:1/chat")
+	if err != nil {
+		t.Fatalf("expected the last endpoint's response, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestFailover_KeepsServerErrorWhenLaterEndpointsAreCoolingDown(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":{"message":"model overloaded"}}`))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer secondary.Close()
+
+	f, _ := NewFailover([]string{primary.URL, secondary.URL}, time.Minute, nil)
+	// Open the secondary's breaker; the primary stays closed.
+	_, _ = f.endpoints[1].breaker.Execute(func() (interface{}, error) { return nil, errEndpointUnavailable })
+
+	resp, err := (&http.Client{Transport: f}).Get(primary.URL + "/chat")
+	if err != nil {
+		t.Fatalf("expected the primary's response, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusInternalServerError || !bytes.Contains(body, []byte("model overloaded")) {
+		t.Errorf("expected the primary's 500 and its body, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestFailover_KeepsServerErrorWhenBodyCantBeReplayed(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secondary.Close()
+
+	f, _ := NewFailover([]string{primary.URL, secondary.URL}, time.Minute, nil)
+	// A body without GetBody can only be sent once.
+	req, _ := http.NewRequest(http.MethodPost, primary.URL+"/chat", io.MultiReader(bytes.NewReader([]byte(`{}`))))
+	resp, err := (&http.Client{Transport: f}).Do(req)
+	if err != nil {
+		t.Fatalf("expected the primary's response, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the primary's 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestDNSCache_KeepsStaleAddressesOnFailure(t *testing.T) {
+	now := time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC)
+	c := newDNSCache(time.Minute)
+	c.now = func() time.Time { return now }
+	c.entries["api.example.com"] = dnsEntry{addrs: []string{"192.0.2.1"}, expires: now.Add(time.Second)}
+
+	addrs, err := c.lookup(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Fatalf("expected the cached address, got %v (%v)", addrs, err)
+	}
+
+	// Expired, and the resolver can't answer: the stale entry still serves.
+	now = now.Add(time.Hour)
+	c.resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("resolver down")
+	}}
+	addrs, err = c.lookup(context.Background(), "api.example.com")
+	if err != nil || len(addrs) != 1 {
+		t.Errorf("expected the stale address, got %v (%v)", addrs, err)
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import "strings"
+
+// OpenAI-style finish reasons that every upstream's stop reason maps to.
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool_calls"
+	FinishContentFilter = "content_filter"
+)
+
+// finishReasons maps upstream stop reasons, lowercased, to finish reasons.
+var finishReasons = map[string]string{
+	// OpenAI and OpenAI-compatible servers (vLLM, Ollama)
+	"stop":           FinishStop,
+	"length":         FinishLength,
+	"tool_calls":     FinishToolCalls,
+	"function_call":  FinishToolCalls,
+	"content_filter": FinishContentFilter,
+	// Anthropic
+	"end_turn":      FinishStop,
+	"stop_sequence": FinishStop,
+	"pause_turn":    FinishStop,
+	"max_tokens":    FinishLength,
+	"tool_use":      FinishToolCalls,
+	"refusal":       FinishContentFilter,
+	// Cohere
+	"complete":    FinishStop,
+	"error_toxic": FinishContentFilter,
+	"error_limit": FinishLength,
+	// Gemini
+	"safety":             FinishContentFilter,
+	"recitation":         FinishContentFilter,
+	"blocklist":          FinishContentFilter,
+	"prohibited_content": FinishContentFilter,
+	"spii":               FinishContentFilter,
+	"image_safety":       FinishContentFilter,
+}
+
+// NormalizeFinishReason maps an upstream's stop reason to an OpenAI-style
+// finish reason. Upstreams that don't say they stopped for a tool call
+// (Gemini reports STOP) get tool_calls when the reply has some; reasons
+// without an equivalent, or none at all, become stop.
+func NormalizeFinishReason(raw string, toolCalls bool) string {
+	reason, ok := finishReasons[strings.ToLower(raw)]
+	if !ok {
+		reason = FinishStop
+	}
+	if toolCalls && reason == FinishStop {
+		return FinishToolCalls
+	}
+	return reason
+}
@@ -0,0 +1,30 @@
+package provider
+
+import "testing"
+
+func TestNormalizeFinishReason(t *testing.T) {
+	tests := []struct {
+		raw       string
+		toolCalls bool
+		want      string
+	}{
+		{"stop", false, FinishStop},
+		{"end_turn", false, FinishStop},
+		{"STOP", false, FinishStop},
+		{"max_tokens", false, FinishLength},
+		{"MAX_TOKENS", false, FinishLength},
+		{"length", false, FinishLength},
+		{"tool_use", true, FinishToolCalls},
+		{"SAFETY", false, FinishContentFilter},
+		{"refusal", false, FinishContentFilter},
+		// Gemini reports STOP when it calls a function.
+		{"STOP", true, FinishToolCalls},
+		{"", false, FinishStop},
+		{"OTHER", false, FinishStop},
+	}
+	for _, tt := range tests {
+		if got := NormalizeFinishReason(tt.raw, tt.toolCalls); got != tt.want {
+			t.Errorf("NormalizeFinishReason(%q, %v) = %q, want %q", tt.raw, tt.toolCalls, got, tt.want)
+		}
+	}
+}
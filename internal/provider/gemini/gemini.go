@@ -9,41 +9,130 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
 )
 
+// StreamMode selects how streamGenerateContent responses are read.
+type StreamMode string
+
+const (
+	// StreamModeSSE requests alt=sse and reads "data:" frames.
+	StreamModeSSE StreamMode = "sse"
+	// StreamModeJSON reads the default chunked JSON array response, for
+	// networks whose proxies strip or buffer text/event-stream.
+	StreamModeJSON StreamMode = "json"
+)
+
 type GeminiProvider struct {
-	apiKey  string
-	baseURL string
+	apiKey     string
+	baseURL    string
+	streamMode StreamMode
+	client     *http.Client
+
+	keyMu sync.RWMutex // guards apiKey, replaced by SetAPIKey
+}
+
+type Option func(*GeminiProvider)
+
+// WithBaseURL overrides the API base URL, e.g. the primary of a list of
+// regional endpoints.
+func WithBaseURL(baseURL string) Option {
+	return func(p *GeminiProvider) {
+		if baseURL != "" {
+			p.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sends upstream requests through client, e.g. one built from
+// provider.Egress (default: http.DefaultClient).
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *GeminiProvider) {
+		p.client = client
+	}
+}
+
+// WithStreamMode overrides the streaming wire format (default: SSE).
+func WithStreamMode(mode StreamMode) Option {
+	return func(p *GeminiProvider) {
+		if mode != "" {
+			p.streamMode = mode
+		}
+	}
 }
 
 type geminiRequest struct {
-	Contents         []geminiContent  `json:"contents"`
-	GenerationConfig generationConfig `json:"generationConfig,omitempty"`
+	Contents         []geminiContent   `json:"contents"`
+	GenerationConfig generationConfig  `json:"generationConfig,omitempty"`
+	Tools            []geminiTool      `json:"tools,omitempty"`
+	ToolConfig       *geminiToolConfig `json:"toolConfig,omitempty"`
 }
 
 type geminiContent struct {
-	Role  string       `json:"role"`
+	Role  string       `json:"role,omitempty"`
 	Parts []geminiPart `json:"parts"`
 }
 
 type geminiPart struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// geminiFunctionResponse returns a tool result, keyed by function name;
+// Response must be a JSON object.
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig geminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+type geminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"` // AUTO, ANY, NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 type generationConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int             `json:"maxOutputTokens,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	ResponseMimeType string          `json:"responseMimeType,omitempty"`
+	ResponseSchema   json.RawMessage `json:"responseSchema,omitempty"`
 }
 
 type geminiResponse struct {
-	Candidates    []geminiCandidate   `json:"candidates"`
-	UsageMetadata geminiUsageMetadata `json:"usageMetadata"`
+	Candidates     []geminiCandidate    `json:"candidates"`
+	UsageMetadata  geminiUsageMetadata  `json:"usageMetadata"`
+	PromptFeedback geminiPromptFeedback `json:"promptFeedback"`
 }
 
 type geminiCandidate struct {
-	Content geminiContent `json:"content"`
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason string `json:"blockReason,omitempty"`
 }
 
 type geminiUsageMetadata struct {
@@ -51,11 +140,45 @@ type geminiUsageMetadata struct {
 	CandidatesTokenCount int `json:"candidatesTokenCount"`
 }
 
-func New(apiKey string) provider.Provider {
-	return &GeminiProvider{
-		apiKey:  apiKey,
-		baseURL: "https://generativelanguage.googleapis.com",
+// usage converts streamed metadata, which is cumulative per chunk, or returns
+// nil when the chunk carried none.
+func (m geminiUsageMetadata) usage() *provider.Usage {
+	if m.PromptTokenCount == 0 && m.CandidatesTokenCount == 0 {
+		return nil
+	}
+	return &provider.Usage{InputTokens: m.PromptTokenCount, OutputTokens: m.CandidatesTokenCount}
+}
+
+func New(apiKey string, opts ...Option) provider.Provider {
+	p := &GeminiProvider{
+		apiKey:     apiKey,
+		baseURL:    "https://generativelanguage.googleapis.com",
+		streamMode: StreamModeSSE,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *GeminiProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
 	}
+	return http.DefaultClient
+}
+
+// SetAPIKey replaces the key sent with subsequent requests.
+func (p *GeminiProvider) SetAPIKey(key string) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+	p.apiKey = key
+}
+
+func (p *GeminiProvider) key() string {
+	p.keyMu.RLock()
+	defer p.keyMu.RUnlock()
+	return p.apiKey
 }
 
 func (p *GeminiProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
@@ -64,15 +187,19 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *provider.Request) (*
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
-	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", p.baseURL, req.Model, p.apiKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", p.baseURL, req.Model, p.key())
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := p.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +207,7 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *provider.Request) (*
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("gemini api error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
 	}
 
 	var geminiResp geminiResponse
@@ -88,39 +215,210 @@ func (p *GeminiProvider) Complete(ctx context.Context, req *provider.Request) (*
 		return nil, err
 	}
 
+	if reason := geminiResp.PromptFeedback.BlockReason; reason != "" {
+		return nil, fmt.Errorf("gemini blocked the prompt (%s): %w", reason, provider.ErrContentFiltered)
+	}
+	if len(geminiResp.Candidates) > 0 && geminiResp.Candidates[0].FinishReason == "SAFETY" {
+		return nil, fmt.Errorf("gemini blocked the response: %w", provider.ErrContentFiltered)
+	}
 	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
 		return nil, fmt.Errorf("gemini api returned no candidates")
 	}
 
+	text, calls := geminiResp.output()
+	var toolCalls []provider.ToolCall
+	for i, fc := range calls {
+		toolCalls = append(toolCalls, fc.toolCall(i))
+	}
+
 	return &provider.Response{
-		Content:      geminiResp.Candidates[0].Content.Parts[0].Text,
-		InputTokens:  geminiResp.UsageMetadata.PromptTokenCount,
-		OutputTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
-		Model:        req.Model,
-		Provider:     p.Name(),
+		Content:         text,
+		ToolCalls:       toolCalls,
+		InputTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+		OutputTokens:    geminiResp.UsageMetadata.CandidatesTokenCount,
+		Model:           req.Model,
+		Provider:        p.Name(),
+		RawFinishReason: geminiResp.Candidates[0].FinishReason,
 	}, nil
 }
 
+// finishReason is the first candidate's, set on a stream's last response.
+func (r geminiResponse) finishReason() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	return r.Candidates[0].FinishReason
+}
+
+// output joins the first candidate's text parts and collects its function calls.
+func (r geminiResponse) output() (string, []geminiFunctionCall) {
+	if len(r.Candidates) == 0 {
+		return "", nil
+	}
+	var text strings.Builder
+	var calls []geminiFunctionCall
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+		if part.FunctionCall != nil {
+			calls = append(calls, *part.FunctionCall)
+		}
+	}
+	return text.String(), calls
+}
+
+// toolCall converts a function call; Gemini doesn't always assign call IDs,
+// so the i'th call in a response gets a generated one.
+func (fc geminiFunctionCall) toolCall(i int) provider.ToolCall {
+	id := fc.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%s", i, fc.Name)
+	}
+	args := "{}"
+	if len(fc.Args) > 0 {
+		args = string(fc.Args)
+	}
+	return provider.ToolCall{
+		ID:       id,
+		Type:     "function",
+		Function: provider.ToolCallFunction{Name: fc.Name, Arguments: args},
+	}
+}
+
 func (p *GeminiProvider) mapRequest(req *provider.Request) geminiRequest {
-	contents := make([]geminiContent, len(req.Messages))
-	for i, m := range req.Messages {
+	names := provider.ToolNames(req.Messages)
+
+	var contents []geminiContent
+	for _, m := range req.Messages {
 		role := "user"
 		if m.Role == "assistant" {
 			role = "model"
 		}
-		contents[i] = geminiContent{
-			Role:  role,
-			Parts: []geminiPart{{Text: m.Content}},
+
+		var parts []geminiPart
+		switch {
+		case m.Role == "tool":
+			parts = append(parts, geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     names[m.ToolCallID],
+				Response: functionResponse(m.Content),
+			}})
+		case m.Content != "" || len(m.ToolCalls) == 0:
+			parts = append(parts, geminiPart{Text: m.Content})
+		}
+		for _, tc := range m.ToolCalls {
+			fc := &geminiFunctionCall{Name: tc.Function.Name}
+			if json.Valid([]byte(tc.Function.Arguments)) {
+				fc.Args = json.RawMessage(tc.Function.Arguments)
+			}
+			parts = append(parts, geminiPart{FunctionCall: fc})
 		}
+
+		// Parallel tool results must come back in a single turn.
+		if n := len(contents); n > 0 && m.Role == "tool" && contents[n-1].Role == role && contents[n-1].Parts[0].FunctionResponse != nil {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			continue
+		}
+		contents = append(contents, geminiContent{
+			Role:  role,
+			Parts: parts,
+		})
+	}
+
+	genConfig := generationConfig{
+		MaxOutputTokens: req.MaxTokens,
+		Temperature:     req.Temperature,
+	}
+	if req.ResponseFormat.WantsJSON() {
+		genConfig.ResponseMimeType = "application/json"
+		genConfig.ResponseSchema = responseSchema(req.ResponseFormat.Schema())
 	}
 
 	return geminiRequest{
-		Contents: contents,
-		GenerationConfig: generationConfig{
-			MaxOutputTokens: req.MaxTokens,
-			Temperature:     req.Temperature,
-		},
+		Contents:         contents,
+		GenerationConfig: genConfig,
+		Tools:            mapTools(req.Tools),
+		ToolConfig:       mapToolConfig(req),
+	}
+}
+
+// unsupportedSchemaKeys are JSON Schema keywords Gemini's OpenAPI-style
+// responseSchema rejects.
+var unsupportedSchemaKeys = []string{"$schema", "additionalProperties"}
+
+// responseSchema strips keywords Gemini rejects from a JSON schema.
+func responseSchema(schema json.RawMessage) json.RawMessage {
+	if len(schema) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(schema, &v); err != nil {
+		return schema
+	}
+	out, err := json.Marshal(stripSchemaKeys(v))
+	if err != nil {
+		return schema
+	}
+	return out
+}
+
+func stripSchemaKeys(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for _, k := range unsupportedSchemaKeys {
+			delete(t, k)
+		}
+		for k, child := range t {
+			t[k] = stripSchemaKeys(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = stripSchemaKeys(child)
+		}
+	}
+	return v
+}
+
+// functionResponse wraps a tool result as the JSON object Gemini requires.
+func functionResponse(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	b, _ := json.Marshal(map[string]string{"content": content})
+	return b
+}
+
+func mapTools(tools []provider.Tool) []geminiTool {
+	if len(tools) == 0 {
+		return nil
 	}
+	decls := make([]geminiFunctionDeclaration, len(tools))
+	for i, t := range tools {
+		decls[i] = geminiFunctionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		}
+	}
+	return []geminiTool{{FunctionDeclarations: decls}}
+}
+
+func mapToolConfig(req *provider.Request) *geminiToolConfig {
+	mode, name := req.ToolChoiceMode()
+	cfg := geminiFunctionCallingConfig{}
+	switch mode {
+	case "auto":
+		cfg.Mode = "AUTO"
+	case "none":
+		cfg.Mode = "NONE"
+	case "required":
+		cfg.Mode = "ANY"
+		if name != "" {
+			cfg.AllowedFunctionNames = []string{name}
+		}
+	default:
+		return nil
+	}
+	return &geminiToolConfig{FunctionCallingConfig: cfg}
 }
 
 func (p *GeminiProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
@@ -129,8 +427,15 @@ func (p *GeminiProvider) CompleteStream(ctx context.Context, req *provider.Reque
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
-	url := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?key=%s&alt=sse", p.baseURL, req.Model, p.apiKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?key=%s", p.baseURL, req.Model, p.key())
+	if p.streamMode != StreamModeJSON {
+		url += "&alt=sse"
+	}
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
@@ -139,10 +444,10 @@ func (p *GeminiProvider) CompleteStream(ctx context.Context, req *provider.Reque
 
 	ch := make(chan *provider.Chunk)
 
-	go func() {
+	provider.Streams.Go("gemini", func() {
 		defer close(ch)
 
-		resp, err := http.DefaultClient.Do(httpReq)
+		resp, err := p.httpClient().Do(httpReq)
 		if err != nil {
 			select {
 			case ch <- &provider.Chunk{Err: err}:
@@ -155,65 +460,44 @@ func (p *GeminiProvider) CompleteStream(ctx context.Context, req *provider.Reque
 		if resp.StatusCode != http.StatusOK {
 			respBody, _ := io.ReadAll(resp.Body)
 			select {
-			case ch <- &provider.Chunk{Err: fmt.Errorf("gemini api error (status %d): %s", resp.StatusCode, string(respBody))}:
+			case ch <- &provider.Chunk{Err: provider.NewAPIError(p.Name(), resp.StatusCode, respBody)}:
 			case <-ctx.Done():
 			}
 			return
 		}
 
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					select {
-					case ch <- &provider.Chunk{Done: true}:
-					case <-ctx.Done():
-					}
-					return
-				}
-				select {
-				case ch <- &provider.Chunk{Err: err}:
-				case <-ctx.Done():
-				}
-				return
-			}
-
-			line = strings.TrimSpace(line)
-			if line == "" || !strings.HasPrefix(line, "data: ") {
-				continue
-			}
-
-			data := strings.TrimPrefix(line, "data: ")
-			var geminiResp geminiResponse
-			if err := json.Unmarshal([]byte(data), &geminiResp); err != nil {
-				select {
-				case ch <- &provider.Chunk{Err: err}:
-				case <-ctx.Done():
-				}
-				return
-			}
-
-			if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-				text := geminiResp.Candidates[0].Content.Parts[0].Text
-				if text != "" {
-					select {
-					case ch <- &provider.Chunk{Delta: text}:
-					case <-ctx.Done():
-						return
-					}
-				}
-			}
+		// Fall back to array parsing when SSE was requested but an intermediary
+		// answered with plain JSON.
+		if p.streamMode == StreamModeJSON || strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			readJSONArray(ctx, resp.Body, ch)
+			return
 		}
-	}()
+		readSSE(ctx, resp.Body, ch)
+	})
 
 	return ch, nil
 }
 
+// ExtraFields lists generateContent fields the gateway passes through;
+// generationConfig is merged into the config the gateway maps.
+func (p *GeminiProvider) ExtraFields() []string {
+	return []string{"generationConfig", "safetySettings", "cachedContent", "labels"}
+}
+
 func (p *GeminiProvider) Name() string {
 	return "gemini"
 }
 
+// Ping lists the upstream's models, which costs nothing.
+func (p *GeminiProvider) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1beta/models?key=%s", p.baseURL, p.key())
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	return provider.Ping(p.httpClient(), httpReq)
+}
+
 func (p *GeminiProvider) CostPerInputToken() float64 {
 	return 0.000000125
 }
@@ -225,3 +509,218 @@ func (p *GeminiProvider) CostPerOutputToken() float64 {
 func (p *GeminiProvider) SupportedModels() []string {
 	return []string{"gemini-1.5-pro", "gemini-1.5-flash", "gemini-2.0-flash"}
 }
+
+var chatCapabilities = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools, provider.CapabilityJSONMode, provider.CapabilityVision}
+
+var models = map[string]provider.ModelInfo{
+	"gemini-1.5-pro":       {ContextWindow: 2097152, MaxOutputTokens: 8192, Capabilities: chatCapabilities, RetiresOn: "2025-09-24", Replacement: "gemini-2.0-flash"},
+	"gemini-1.5-flash":     {ContextWindow: 1048576, MaxOutputTokens: 8192, Capabilities: chatCapabilities, RetiresOn: "2025-09-24", Replacement: "gemini-2.0-flash"},
+	"gemini-2.0-flash":     {ContextWindow: 1048576, MaxOutputTokens: 8192, Capabilities: chatCapabilities},
+	"text-embedding-004":   {ContextWindow: 2048, Capabilities: []string{provider.CapabilityEmbeddings}},
+	"gemini-embedding-001": {ContextWindow: 2048, Capabilities: []string{provider.CapabilityEmbeddings}},
+}
+
+func (p *GeminiProvider) ModelInfo(model string) (provider.ModelInfo, bool) {
+	info, ok := models[model]
+	return info, ok
+}
+
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContentRequest `json:"requests"`
+}
+
+type geminiEmbedContentRequest struct {
+	Model   string        `json:"model"`
+	Content geminiContent `json:"content"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+func (p *GeminiProvider) Embed(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	embReq := geminiEmbedRequest{Requests: make([]geminiEmbedContentRequest, len(req.Input))}
+	inputTokens := 0
+	for i, text := range req.Input {
+		embReq.Requests[i] = geminiEmbedContentRequest{
+			Model:   "models/" + req.Model,
+			Content: geminiContent{Parts: []geminiPart{{Text: text}}},
+		}
+		// batchEmbedContents reports no usage; estimate ~4 chars per token.
+		inputTokens += (len(text) + 3) / 4
+	}
+	body, err := json.Marshal(embReq)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:batchEmbedContents?key=%s", p.baseURL, req.Model, p.key())
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
+	}
+
+	var embResp geminiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, err
+	}
+	if len(embResp.Embeddings) != len(req.Input) {
+		return nil, fmt.Errorf("gemini api returned %d embeddings for %d inputs", len(embResp.Embeddings), len(req.Input))
+	}
+
+	embeddings := make([][]float32, len(embResp.Embeddings))
+	for i, e := range embResp.Embeddings {
+		embeddings[i] = e.Values
+	}
+
+	return &provider.EmbeddingResponse{
+		Embeddings:  embeddings,
+		InputTokens: inputTokens,
+		Model:       req.Model,
+		Provider:    p.Name(),
+	}, nil
+}
+
+func (p *GeminiProvider) EmbeddingModels() []string {
+	return []string{"text-embedding-004", "gemini-embedding-001"}
+}
+
+func (p *GeminiProvider) CostPerEmbeddingToken() float64 {
+	return 0.00000015
+}
+
+func (p *GeminiProvider) ConversationRules() provider.ConversationRules {
+	return provider.ConversationRules{RequireUserFirst: true, RequireAlternation: true, SingleSystem: true}
+}
+
+// readSSE emits chunks from an alt=sse response body.
+func readSSE(ctx context.Context, body io.Reader, ch chan<- *provider.Chunk) {
+	var usage *provider.Usage
+	var finishReason string
+	var toolCalls int
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				select {
+				case ch <- &provider.Chunk{Done: true, Usage: usage, RawFinishReason: finishReason}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			select {
+			case ch <- &provider.Chunk{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		var geminiResp geminiResponse
+		if err := json.Unmarshal([]byte(data), &geminiResp); err != nil {
+			select {
+			case ch <- &provider.Chunk{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if u := geminiResp.UsageMetadata.usage(); u != nil {
+			usage = u
+		}
+		if fr := geminiResp.finishReason(); fr != "" {
+			finishReason = fr
+		}
+
+		if chunk := streamChunk(geminiResp, &toolCalls); chunk != nil {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// streamChunk converts one streamed response into a chunk, or nil when it
+// carried neither text nor function calls. Gemini sends each function call
+// whole, so calls are emitted as they arrive; seen numbers them across chunks.
+func streamChunk(resp geminiResponse, seen *int) *provider.Chunk {
+	text, calls := resp.output()
+	if text == "" && len(calls) == 0 {
+		return nil
+	}
+	chunk := &provider.Chunk{Delta: text}
+	for _, fc := range calls {
+		chunk.ToolCalls = append(chunk.ToolCalls, fc.toolCall(*seen))
+		*seen++
+	}
+	return chunk
+}
+
+// readJSONArray emits chunks from the default streamGenerateContent body,
+// a JSON array whose elements arrive incrementally.
+func readJSONArray(ctx context.Context, body io.Reader, ch chan<- *provider.Chunk) {
+	send := func(c *provider.Chunk) bool {
+		select {
+		case ch <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	dec := json.NewDecoder(body)
+	if tok, err := dec.Token(); err != nil {
+		send(&provider.Chunk{Err: err})
+		return
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		send(&provider.Chunk{Err: fmt.Errorf("gemini stream: expected JSON array, got %v", tok)})
+		return
+	}
+
+	var usage *provider.Usage
+	var finishReason string
+	var toolCalls int
+	for dec.More() {
+		var geminiResp geminiResponse
+		if err := dec.Decode(&geminiResp); err != nil {
+			send(&provider.Chunk{Err: err})
+			return
+		}
+		if u := geminiResp.UsageMetadata.usage(); u != nil {
+			usage = u
+		}
+		if fr := geminiResp.finishReason(); fr != "" {
+			finishReason = fr
+		}
+		if chunk := streamChunk(geminiResp, &toolCalls); chunk != nil && !send(chunk) {
+			return
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		send(&provider.Chunk{Err: err})
+		return
+	}
+	send(&provider.Chunk{Done: true, Usage: usage, RawFinishReason: finishReason})
+}
@@ -117,3 +117,197 @@ func TestCompleteStream_Mock(t *testing.T) {
 		t.Errorf("Expected 'Hello world!', got %s", content)
 	}
 }
+
+func TestCompleteStream_JSONArrayMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "" {
+			t.Errorf("Expected no alt parameter in JSON mode, got %q", r.URL.Query().Get("alt"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		flusher := w.(http.Flusher)
+		chunks := []string{"Hello", " array", "!"}
+		fmt.Fprint(w, "[")
+		for i, chunk := range chunks {
+			resp := geminiResponse{
+				Candidates: []geminiCandidate{
+					{Content: geminiContent{Parts: []geminiPart{{Text: chunk}}}},
+				},
+			}
+			data, _ := json.Marshal(resp)
+			if i > 0 {
+				fmt.Fprint(w, ",\r\n")
+			}
+			fmt.Fprint(w, string(data))
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer server.Close()
+
+	p := &GeminiProvider{
+		apiKey:     "test-key",
+		baseURL:    server.URL,
+		streamMode: StreamModeJSON,
+	}
+
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "gemini-pro",
+		Messages: []provider.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var content string
+	var done bool
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Received error from chunk: %v", chunk.Err)
+		}
+		if chunk.Done {
+			done = true
+			continue
+		}
+		content += chunk.Delta
+	}
+
+	if !done {
+		t.Error("Expected stream to be done")
+	}
+	if content != "Hello array!" {
+		t.Errorf("Expected 'Hello array!', got %s", content)
+	}
+}
+
+func TestEmbed_Mock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/text-embedding-004:batchEmbedContents" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var req geminiEmbedRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Requests) != 2 || req.Requests[0].Model != "models/text-embedding-004" {
+			t.Errorf("Unexpected embed request: %+v", req)
+		}
+		_, _ = fmt.Fprint(w, `{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3,0.4]}]}`)
+	}))
+	defer server.Close()
+
+	p := &GeminiProvider{apiKey: "test-key", baseURL: server.URL}
+
+	resp, err := p.Embed(context.Background(), &provider.EmbeddingRequest{
+		Model: "text-embedding-004",
+		Input: []string{"hello", "world"},
+	})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[1][0] != 0.3 {
+		t.Errorf("Unexpected embeddings: %v", resp.Embeddings)
+	}
+	if resp.InputTokens == 0 {
+		t.Errorf("Expected estimated input tokens")
+	}
+}
+
+func TestMapRequest_Tools(t *testing.T) {
+	p := &GeminiProvider{}
+	req := &provider.Request{
+		Model: "gemini-2.0-flash",
+		Messages: []provider.Message{
+			{Role: "user", Content: "weather in Paris?"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{
+				{ID: "call_0_get_weather", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_0_get_weather", Content: "18C"},
+		},
+		Tools: []provider.Tool{{Type: "function", Function: provider.ToolFunction{
+			Name:        "get_weather",
+			Description: "Current weather",
+			Parameters:  json.RawMessage(`{"type":"object"}`),
+		}}},
+		ToolChoice: json.RawMessage(`"required"`),
+	}
+
+	got := p.mapRequest(req)
+
+	if len(got.Tools) != 1 || len(got.Tools[0].FunctionDeclarations) != 1 || got.Tools[0].FunctionDeclarations[0].Name != "get_weather" {
+		t.Errorf("unexpected tools: %+v", got.Tools)
+	}
+	if got.ToolConfig == nil || got.ToolConfig.FunctionCallingConfig.Mode != "ANY" {
+		t.Errorf("unexpected toolConfig: %+v", got.ToolConfig)
+	}
+	if len(got.Contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d", len(got.Contents))
+	}
+	call := got.Contents[1]
+	if call.Role != "model" || len(call.Parts) != 1 || call.Parts[0].FunctionCall == nil || string(call.Parts[0].FunctionCall.Args) != `{"city":"Paris"}` {
+		t.Errorf("unexpected function call turn: %+v", call)
+	}
+	result := got.Contents[2].Parts[0].FunctionResponse
+	if result == nil || result.Name != "get_weather" || string(result.Response) != `{"content":"18C"}` {
+		t.Errorf("unexpected function response: %+v", result)
+	}
+}
+
+func TestCompleteStream_FunctionCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"parts":[{"text":"Checking."}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]}}]}`+"\n\n")
+	}))
+	defer server.Close()
+
+	p := &GeminiProvider{apiKey: "test-key", baseURL: server.URL}
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "gemini-2.0-flash",
+		Messages: []provider.Message{{Role: "user", Content: "weather?"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var content string
+	var calls []provider.ToolCall
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Received error from chunk: %v", chunk.Err)
+		}
+		content += chunk.Delta
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if content != "Checking." {
+		t.Errorf("expected text content, got %q", content)
+	}
+	if len(calls) != 1 || calls[0].ID == "" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
+
+func TestMapRequest_ResponseFormat(t *testing.T) {
+	p := &GeminiProvider{}
+	got := p.mapRequest(&provider.Request{
+		Messages: []provider.Message{{Role: "user", Content: "Pick a city."}},
+		ResponseFormat: &provider.ResponseFormat{Type: "json_schema", JSONSchema: &provider.JSONSchema{
+			Name:   "city",
+			Schema: json.RawMessage(`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"city":{"type":"string"}},"additionalProperties":false}`),
+		}},
+	})
+
+	if got.GenerationConfig.ResponseMimeType != "application/json" {
+		t.Errorf("expected responseMimeType application/json, got %q", got.GenerationConfig.ResponseMimeType)
+	}
+	want := `{"properties":{"city":{"type":"string"}},"type":"object"}`
+	if string(got.GenerationConfig.ResponseSchema) != want {
+		t.Errorf("expected unsupported keywords stripped, got %s", got.GenerationConfig.ResponseSchema)
+	}
+
+	got = p.mapRequest(&provider.Request{
+		Messages:       []provider.Message{{Role: "user", Content: "hi"}},
+		ResponseFormat: &provider.ResponseFormat{Type: "json_object"},
+	})
+	if got.GenerationConfig.ResponseMimeType != "application/json" || got.GenerationConfig.ResponseSchema != nil {
+		t.Errorf("unexpected json_object mapping: %+v", got.GenerationConfig)
+	}
+}
@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Key selection strategies for a KeyPool.
+const (
+	KeyRoundRobin = "round_robin"
+	KeyLeastUsed  = "least_used" // fewest requests in flight, then fewest sent
+)
+
+// Credential sets key on an upstream request where the provider's API
+// expects it.
+type Credential func(req *http.Request, key string)
+
+// BearerToken sends the key as "Authorization: Bearer" (OpenAI, vLLM).
+func BearerToken(req *http.Request, key string) {
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+// HeaderKey sends the key in the named header, e.g. Anthropic's x-api-key.
+func HeaderKey(name string) Credential {
+	return func(req *http.Request, key string) {
+		req.Header.Set(name, key)
+	}
+}
+
+// QueryKey sends the key as the named query parameter, e.g. Gemini's ?key=.
+func QueryKey(name string) Credential {
+	return func(req *http.Request, key string) {
+		q := req.URL.Query()
+		q.Set(name, key)
+		req.URL.RawQuery = q.Encode()
+	}
+}
+
+// KeyPoolConfig is how a KeyPool spreads requests over its keys.
+type KeyPoolConfig struct {
+	Strategy string // KeyRoundRobin (default) or KeyLeastUsed
+	// MaxFailures consecutive 401, 403 or 429 responses sideline a key for
+	// Cooldown.
+	MaxFailures int
+	Cooldown    time.Duration
+}
+
+// KeyStatus is one pooled key's health and the quota its upstream last
+// reported. Keys are identified by their last four characters.
+type KeyStatus struct {
+	Key                 string     `json:"key"`
+	Requests            int64      `json:"requests"`
+	InFlight            int        `json:"in_flight"`
+	AuthFailures        int64      `json:"auth_failures"`
+	RateLimited         int64      `json:"rate_limited"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	SidelinedUntil      *time.Time `json:"sidelined_until,omitempty"`
+	RemainingRequests   *int64     `json:"remaining_requests,omitempty"`
+	RemainingTokens     *int64     `json:"remaining_tokens,omitempty"`
+}
+
+// KeyPool is an http.RoundTripper that sends each upstream request with one
+// of several API keys of the same provider, e.g. keys of different OpenAI
+// organizations, to spread their quota. A key answered with 401, 403 or 429
+// is retried on the next key when the body can be replayed; one that keeps
+// failing is sidelined for the cooldown. If every key is sidelined, the one
+// due back soonest is used rather than failing outright.
+type KeyPool struct {
+	cfg        KeyPoolConfig
+	credential Credential
+	next       http.RoundTripper
+
+	mu   sync.Mutex
+	keys []*pooledKey
+	turn int // next round-robin position
+}
+
+type pooledKey struct {
+	key    string
+	status KeyStatus
+	until  time.Time // sidelined until
+}
+
+// NewKeyPool rotates keys, setting them with credential, and sends requests
+// through next (http.DefaultTransport when nil).
+func NewKeyPool(keys []string, credential Credential, cfg KeyPoolConfig, next http.RoundTripper) (*KeyPool, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key pool needs at least one key")
+	}
+	switch cfg.Strategy {
+	case "":
+		cfg.Strategy = KeyRoundRobin
+	case KeyRoundRobin, KeyLeastUsed:
+	default:
+		return nil, fmt.Errorf("unknown key strategy %q", cfg.Strategy)
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = time.Minute
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	p := &KeyPool{cfg: cfg, credential: credential, next: next}
+	for _, k := range keys {
+		p.keys = append(p.keys, &pooledKey{key: k, status: KeyStatus{Key: maskKey(k)}})
+	}
+	return p, nil
+}
+
+func (p *KeyPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Without GetBody the body can only be sent once.
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	tried := make(map[*pooledKey]bool)
+	for {
+		k := p.pick(tried)
+		tried[k] = true
+		out := req.Clone(req.Context())
+		if len(tried) > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out.Body = body
+		}
+		p.credential(out, k.key)
+
+		resp, err := p.next.RoundTrip(out)
+		p.done(k, resp, err)
+		if err != nil || !keyRejected(resp.StatusCode) || !replayable || !p.available(tried) {
+			return resp, err
+		}
+		resp.Body.Close()
+	}
+}
+
+// keyRejected reports whether status is about the key rather than the
+// request: revoked or invalid keys, and exhausted quota.
+func keyRejected(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusTooManyRequests
+}
+
+// pick takes the next key not yet tried for this request, preferring keys
+// that aren't sidelined.
+func (p *KeyPool) pick(tried map[*pooledKey]bool) *pooledKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+
+	var best *pooledKey
+	n, at := len(p.keys), 0
+	for i := 0; i < n; i++ {
+		k := p.keys[(p.turn+i)%n]
+		if tried[k] || now.Before(k.until) {
+			continue
+		}
+		if best == nil || (p.cfg.Strategy == KeyLeastUsed && lessUsed(k, best)) {
+			best, at = k, (p.turn+i)%n
+		}
+		if p.cfg.Strategy == KeyRoundRobin {
+			break
+		}
+	}
+	if best == nil {
+		for i, k := range p.keys {
+			if !tried[k] && (best == nil || k.until.Before(best.until)) {
+				best, at = k, i
+			}
+		}
+	}
+	p.turn = (at + 1) % n
+	best.status.Requests++
+	best.status.InFlight++
+	return best
+}
+
+func lessUsed(a, b *pooledKey) bool {
+	if a.status.InFlight != b.status.InFlight {
+		return a.status.InFlight < b.status.InFlight
+	}
+	return a.status.Requests < b.status.Requests
+}
+
+// available reports whether a key outside tried is in rotation.
+func (p *KeyPool) available(tried map[*pooledKey]bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, k := range p.keys {
+		if !tried[k] && !now.Before(k.until) {
+			return true
+		}
+	}
+	return false
+}
+
+// done records the outcome of a request sent with k.
+func (p *KeyPool) done(k *pooledKey, resp *http.Response, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.status.InFlight--
+	if err != nil {
+		return
+	}
+	if v, ok := headerInt(resp.Header, "x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining"); ok {
+		k.status.RemainingRequests = &v
+	}
+	if v, ok := headerInt(resp.Header, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining"); ok {
+		k.status.RemainingTokens = &v
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		k.status.AuthFailures++
+	case http.StatusTooManyRequests:
+		k.status.RateLimited++
+	default:
+		k.status.ConsecutiveFailures = 0
+		return
+	}
+	k.status.ConsecutiveFailures++
+	if k.status.ConsecutiveFailures >= p.cfg.MaxFailures && !time.Now().Before(k.until) {
+		k.until = time.Now().Add(p.cfg.Cooldown)
+		log.Printf("provider: key %s sidelined for %s after %d consecutive failures (last status %d)",
+			k.status.Key, p.cfg.Cooldown, k.status.ConsecutiveFailures, resp.StatusCode)
+	}
+}
+
+// SetKeys replaces the pool's keys, e.g. on a config reload. Keys that stay
+// keep their counters and cooldown; requests in flight finish on the key
+// they were sent with.
+func (p *KeyPool) SetKeys(keys []string) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("key pool needs at least one key")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current := make(map[string]*pooledKey, len(p.keys))
+	for _, k := range p.keys {
+		current[k.key] = k
+	}
+	next := make([]*pooledKey, 0, len(keys))
+	for _, key := range keys {
+		k, ok := current[key]
+		if !ok {
+			k = &pooledKey{key: key, status: KeyStatus{Key: maskKey(key)}}
+		}
+		next = append(next, k)
+	}
+	p.keys = next
+	p.turn = 0
+	return nil
+}
+
+// Statuses reports every key in configuration order.
+func (p *KeyPool) Statuses() []KeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	out := make([]KeyStatus, len(p.keys))
+	for i, k := range p.keys {
+		out[i] = k.status
+		if now.Before(k.until) {
+			until := k.until
+			out[i].SidelinedUntil = &until
+		}
+	}
+	return out
+}
+
+// headerInt reads the first of names that is set as an integer.
+func headerInt(h http.Header, names ...string) (int64, bool) {
+	for _, name := range names {
+		if raw := h.Get(name); raw != "" {
+			v, err := strconv.ParseInt(raw, 10, 64)
+			return v, err == nil
+		}
+	}
+	return 0, false
+}
+
+// maskKey keeps only enough of a key to tell it apart from the others.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
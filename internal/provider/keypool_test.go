@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyServer answers 429 for the keys in limited and records the key and
+// body of every request.
+func keyServer(t *testing.T, limited map[string]bool) (*httptest.Server, *[]string, *[]string) {
+	t.Helper()
+	var keys, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		body, _ := io.ReadAll(r.Body)
+		keys, bodies = append(keys, key), append(bodies, string(body))
+		if limited[key] {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("x-ratelimit-remaining-requests", "42")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &keys, &bodies
+}
+
+func send(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(`{"a":1}`)))
+	req.Header.Set("Authorization", "Bearer configured")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestKeyPool_RoundRobin(t *testing.T) {
+	srv, keys, _ := keyServer(t, nil)
+	pool, err := NewKeyPool([]string{"key-a", "key-b"}, BearerToken, KeyPoolConfig{}, nil)
+	if err != nil {
+		t.Fatalf("NewKeyPool failed: %v", err)
+	}
+	client := &http.Client{Transport: pool}
+
+	for i := 0; i < 3; i++ {
+		send(t, client, srv.URL)
+	}
+	if got := strings.Join(*keys, ","); got != "key-a,key-b,key-a" {
+		t.Errorf("Expected keys in rotation, got %s", got)
+	}
+	statuses := pool.Statuses()
+	if statuses[0].Key != "...ey-a" || statuses[0].Requests != 2 {
+		t.Errorf("Unexpected status: %+v", statuses[0])
+	}
+	if statuses[0].RemainingRequests == nil || *statuses[0].RemainingRequests != 42 {
+		t.Errorf("Expected the upstream's remaining quota, got %+v", statuses[0])
+	}
+}
+
+func TestKeyPool_RetriesRateLimitedKeyAndSidelinesIt(t *testing.T) {
+	srv, keys, bodies := keyServer(t, map[string]bool{"key-a": true})
+	pool, _ := NewKeyPool([]string{"key-a", "key-b"}, BearerToken, KeyPoolConfig{MaxFailures: 2, Cooldown: time.Minute}, nil)
+	client := &http.Client{Transport: pool}
+
+	for i := 0; i < 4; i++ {
+		if status := send(t, client, srv.URL); status != http.StatusOK {
+			t.Fatalf("request %d: expected the other key to answer, got %d", i, status)
+		}
+	}
+	// key-a is tried first on the first two requests, then sidelined.
+	if got := strings.Join(*keys, ","); got != "key-a,key-b,key-a,key-b,key-b,key-b" {
+		t.Errorf("Unexpected key order: %s", got)
+	}
+	for _, body := range *bodies {
+		if body != `{"a":1}` {
+			t.Errorf("Expected the body to be replayed, got %q", body)
+		}
+	}
+	a := pool.Statuses()[0]
+	if a.RateLimited != 2 || a.SidelinedUntil == nil {
+		t.Errorf("Expected key-a sidelined after two 429s, got %+v", a)
+	}
+}
+
+func TestKeyPool_AllKeysRejected(t *testing.T) {
+	srv, keys, _ := keyServer(t, map[string]bool{"key-a": true, "key-b": true})
+	pool, _ := NewKeyPool([]string{"key-a", "key-b"}, BearerToken, KeyPoolConfig{}, nil)
+
+	if status := send(t, &http.Client{Transport: pool}, srv.URL); status != http.StatusTooManyRequests {
+		t.Errorf("Expected the last 429 once every key was tried, got %d", status)
+	}
+	if len(*keys) != 2 {
+		t.Errorf("Expected each key tried once, got %v", *keys)
+	}
+}
+
+func TestKeyPool_QueryKey(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RawQuery
+	}))
+	defer srv.Close()
+	pool, _ := NewKeyPool([]string{"pooled"}, QueryKey("key"), KeyPoolConfig{}, nil)
+
+	resp, err := (&http.Client{Transport: pool}).Get(srv.URL + "/v1beta/models/m:generateContent?alt=sse&key=configured")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got != "alt=sse&key=pooled" {
+		t.Errorf("Expected the pooled key in the query, got %q", got)
+	}
+}
+
+func TestNewKeyPool_Validates(t *testing.T) {
+	if _, err := NewKeyPool(nil, BearerToken, KeyPoolConfig{}, nil); err == nil {
+		t.Error("Expected an error for an empty pool")
+	}
+	if _, err := NewKeyPool([]string{"k"}, BearerToken, KeyPoolConfig{Strategy: "random"}, nil); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
+
+func TestKeyPool_SetKeys(t *testing.T) {
+	srv, keys, _ := keyServer(t, nil)
+	pool, _ := NewKeyPool([]string{"key-a", "key-b"}, BearerToken, KeyPoolConfig{}, nil)
+	client := &http.Client{Transport: pool}
+	send(t, client, srv.URL)
+
+	if err := pool.SetKeys([]string{"key-c", "key-a"}); err != nil {
+		t.Fatalf("SetKeys failed: %v", err)
+	}
+	send(t, client, srv.URL)
+	send(t, client, srv.URL)
+	if got := strings.Join(*keys, ","); got != "key-a,key-c,key-a" {
+		t.Errorf("Expected the new keys in rotation, got %s", got)
+	}
+	statuses := pool.Statuses()
+	if len(statuses) != 2 || statuses[1].Key != "...ey-a" || statuses[1].Requests != 2 {
+		t.Errorf("Expected key-a to keep its counters, got %+v", statuses)
+	}
+	if err := pool.SetKeys(nil); err == nil {
+		t.Error("Expected an empty key list to be rejected")
+	}
+}
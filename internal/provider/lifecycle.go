@@ -0,0 +1,70 @@
+package provider
+
+import "sync"
+
+// StreamRegistry tracks the goroutines that pump stream chunks so leaks
+// (e.g. a client disconnecting mid-stream) are observable.
+type StreamRegistry struct {
+	mu     sync.Mutex
+	counts map[string]StreamCounts
+}
+
+// StreamCounts are the stream goroutines registered under one name.
+type StreamCounts struct {
+	Active   int64
+	Started  int64
+	Finished int64
+}
+
+// Streams is the process-wide registry used by providers and the router.
+var Streams = NewStreamRegistry()
+
+func NewStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{counts: make(map[string]StreamCounts)}
+}
+
+// Go runs fn in a new goroutine registered under name until fn returns.
+func (r *StreamRegistry) Go(name string, fn func()) {
+	r.update(name, func(c *StreamCounts) {
+		c.Active++
+		c.Started++
+	})
+
+	go func() {
+		defer r.update(name, func(c *StreamCounts) {
+			c.Active--
+			c.Finished++
+		})
+		fn()
+	}()
+}
+
+func (r *StreamRegistry) update(name string, fn func(*StreamCounts)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.counts[name]
+	fn(&c)
+	r.counts[name] = c
+}
+
+// Active returns the number of running stream goroutines.
+func (r *StreamRegistry) Active() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, c := range r.counts {
+		n += c.Active
+	}
+	return n
+}
+
+// Snapshot returns the stream goroutines registered so far, by name.
+func (r *StreamRegistry) Snapshot() map[string]StreamCounts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]StreamCounts, len(r.counts))
+	for k, v := range r.counts {
+		out[k] = v
+	}
+	return out
+}
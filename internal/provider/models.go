@@ -0,0 +1,39 @@
+package provider
+
+import "context"
+
+// Model capabilities reported in ModelInfo.
+const (
+	CapabilityChat       = "chat"
+	CapabilityStreaming  = "streaming"
+	CapabilityTools      = "tools"
+	CapabilityJSONMode   = "json_mode" // native response_format; others are emulated
+	CapabilityVision     = "vision"
+	CapabilityEmbeddings = "embeddings"
+)
+
+// ModelInfo is what an upstream publishes about one of its models.
+type ModelInfo struct {
+	ContextWindow   int // tokens, prompt and output combined
+	MaxOutputTokens int
+	Capabilities    []string
+	// RetiresOn is the upstream's announced retirement date (YYYY-MM-DD) and
+	// Replacement the model it recommends instead; both are empty while the
+	// model is current.
+	RetiresOn   string
+	Replacement string
+}
+
+// ModelInfoProvider is implemented by providers that describe their models.
+// Models it doesn't know are listed without limits or capabilities.
+type ModelInfoProvider interface {
+	ModelInfo(model string) (ModelInfo, bool)
+}
+
+// ModelLister is implemented by providers whose models are discovered from
+// the upstream rather than fixed in code, e.g. a self-hosted server. The
+// router reads its list from a catalog that calls ListModels in the
+// background; SupportedModels is what the provider serves until then.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
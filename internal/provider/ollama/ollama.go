@@ -0,0 +1,502 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// APIMode selects which HTTP API the self-hosted server is spoken to with.
+type APIMode string
+
+const (
+	// APIModeNative uses Ollama's /api/chat with newline-delimited JSON streaming.
+	APIModeNative APIMode = "native"
+	// APIModeOpenAI uses the OpenAI-compatible /v1/chat/completions endpoint
+	// served by vLLM and by Ollama's compatibility layer.
+	APIModeOpenAI APIMode = "openai"
+)
+
+// OllamaProvider serves models from a self-hosted Ollama or vLLM endpoint.
+// Local inference has no per-token price, so cost-based routing prefers it
+// and the router's fallback only reaches commercial APIs when it fails.
+type OllamaProvider struct {
+	baseURL string
+	apiKey  string
+	mode    APIMode
+	models  []string
+	client  *http.Client
+
+	keyMu sync.RWMutex // guards apiKey, replaced by SetAPIKey
+}
+
+type Option func(*OllamaProvider)
+
+// WithAPIMode overrides the wire API (default: native).
+func WithAPIMode(mode APIMode) Option {
+	return func(p *OllamaProvider) {
+		if mode != "" {
+			p.mode = mode
+		}
+	}
+}
+
+// WithHTTPClient sends upstream requests through client, e.g. one built from
+// provider.Egress (default: http.DefaultClient).
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *OllamaProvider) {
+		p.client = client
+	}
+}
+
+// WithAPIKey sends a bearer token, for vLLM deployments started with --api-key.
+func WithAPIKey(apiKey string) Option {
+	return func(p *OllamaProvider) {
+		p.apiKey = apiKey
+	}
+}
+
+func New(baseURL string, models []string, opts ...Option) provider.Provider {
+	p := &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		mode:    APIModeNative,
+		models:  models,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *OllamaProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return http.DefaultClient
+}
+
+// SetAPIKey replaces the key sent with subsequent requests.
+func (p *OllamaProvider) SetAPIKey(key string) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+	p.apiKey = key
+}
+
+func (p *OllamaProvider) key() string {
+	p.keyMu.RLock()
+	defer p.keyMu.RUnlock()
+	return p.apiKey
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type nativeRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  nativeOptions `json:"options,omitempty"`
+	// Format is "json" or a JSON schema for structured outputs.
+	Format json.RawMessage `json:"format,omitempty"`
+}
+
+type nativeOptions struct {
+	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+}
+
+// nativeResponse is a full /api/chat response or one streamed line; token
+// counts are only set once Done is true.
+type nativeResponse struct {
+	Model           string      `json:"model"`
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error,omitempty"`
+}
+
+type compatRequest struct {
+	Model          string                   `json:"model"`
+	Messages       []chatMessage            `json:"messages"`
+	MaxTokens      int                      `json:"max_tokens,omitempty"`
+	Temperature    float64                  `json:"temperature,omitempty"`
+	Stream         bool                     `json:"stream,omitempty"`
+	StreamOptions  *compatStreamOptions     `json:"stream_options,omitempty"`
+	ResponseFormat *provider.ResponseFormat `json:"response_format,omitempty"`
+}
+
+type compatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type compatResponse struct {
+	ID      string         `json:"id"`
+	Model   string         `json:"model"`
+	Choices []compatChoice `json:"choices"`
+	Usage   *compatUsage   `json:"usage"`
+}
+
+type compatChoice struct {
+	Message      chatMessage `json:"message"`
+	Delta        chatMessage `json:"delta"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type compatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// nativeFormat maps response_format onto /api/chat's format field.
+func nativeFormat(f *provider.ResponseFormat) json.RawMessage {
+	if !f.WantsJSON() {
+		return nil
+	}
+	if schema := f.Schema(); schema != nil {
+		return schema
+	}
+	return json.RawMessage(`"json"`)
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if p.mode == APIModeOpenAI {
+		var compatResp compatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&compatResp); err != nil {
+			return nil, err
+		}
+		if len(compatResp.Choices) == 0 {
+			return nil, fmt.Errorf("ollama api returned no choices")
+		}
+		out := &provider.Response{
+			ID:              compatResp.ID,
+			Content:         compatResp.Choices[0].Message.Content,
+			Model:           compatResp.Model,
+			Provider:        p.Name(),
+			RawFinishReason: compatResp.Choices[0].FinishReason,
+		}
+		if compatResp.Usage != nil {
+			out.InputTokens = compatResp.Usage.PromptTokens
+			out.OutputTokens = compatResp.Usage.CompletionTokens
+		}
+		return out, nil
+	}
+
+	var nativeResp nativeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&nativeResp); err != nil {
+		return nil, err
+	}
+	return &provider.Response{
+		Content:         nativeResp.Message.Content,
+		InputTokens:     nativeResp.PromptEvalCount,
+		OutputTokens:    nativeResp.EvalCount,
+		Model:           nativeResp.Model,
+		Provider:        p.Name(),
+		RawFinishReason: nativeResp.DoneReason,
+	}, nil
+}
+
+func (p *OllamaProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk)
+
+	provider.Streams.Go("ollama", func() {
+		defer close(ch)
+
+		send := func(c *provider.Chunk) bool {
+			select {
+			case ch <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		resp, err := p.post(ctx, req, true)
+		if err != nil {
+			send(&provider.Chunk{Err: err})
+			return
+		}
+		defer resp.Body.Close()
+
+		if p.mode == APIModeOpenAI {
+			p.readSSE(resp.Body, send)
+			return
+		}
+		p.readNDJSON(resp.Body, send)
+	})
+
+	return ch, nil
+}
+
+// post sends req to the configured API and returns the response once the
+// upstream has accepted it.
+func (p *OllamaProvider) post(ctx context.Context, req *provider.Request, stream bool) (*http.Response, error) {
+	messages := make([]chatMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = chatMessage{Role: m.Role, Content: m.Content}
+	}
+	// Cost-based routing sends model-less requests here; serve them with the
+	// first configured model rather than letting the server reject them.
+	model := req.Model
+	if model == "" && len(p.models) > 0 {
+		model = p.models[0]
+	}
+
+	var (
+		url     string
+		payload any
+	)
+	if p.mode == APIModeOpenAI {
+		url = fmt.Sprintf("%s/v1/chat/completions", p.baseURL)
+		compatReq := compatRequest{
+			Model:          model,
+			Messages:       messages,
+			MaxTokens:      req.MaxTokens,
+			Temperature:    req.Temperature,
+			Stream:         stream,
+			ResponseFormat: req.ResponseFormat,
+		}
+		if stream {
+			compatReq.StreamOptions = &compatStreamOptions{IncludeUsage: true}
+		}
+		payload = compatReq
+	} else {
+		url = fmt.Sprintf("%s/api/chat", p.baseURL)
+		payload = nativeRequest{
+			Model:    model,
+			Messages: messages,
+			Stream:   stream,
+			Options:  nativeOptions{NumPredict: req.MaxTokens, Temperature: req.Temperature},
+			Format:   nativeFormat(req.ResponseFormat),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if key := p.key(); key != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
+	}
+
+	resp, err := p.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+// readNDJSON relays a native /api/chat stream: one JSON object per line,
+// the last with done=true and the token counts.
+func (p *OllamaProvider) readNDJSON(body io.Reader, send func(*provider.Chunk) bool) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk nativeResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			send(&provider.Chunk{Err: err})
+			return
+		}
+		if chunk.Error != "" {
+			send(&provider.Chunk{Err: fmt.Errorf("ollama stream error: %s", chunk.Error)})
+			return
+		}
+		if chunk.Message.Content != "" {
+			if !send(&provider.Chunk{Delta: chunk.Message.Content}) {
+				return
+			}
+		}
+		if chunk.Done {
+			send(&provider.Chunk{Done: true, RawFinishReason: chunk.DoneReason, Usage: &provider.Usage{
+				InputTokens:  chunk.PromptEvalCount,
+				OutputTokens: chunk.EvalCount,
+			}})
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		send(&provider.Chunk{Err: err})
+		return
+	}
+	send(&provider.Chunk{Done: true})
+}
+
+// readSSE relays an OpenAI-compatible event stream.
+func (p *OllamaProvider) readSSE(body io.Reader, send func(*provider.Chunk) bool) {
+	var usage *provider.Usage
+	var finishReason string
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				send(&provider.Chunk{Done: true, Usage: usage, RawFinishReason: finishReason})
+				return
+			}
+			send(&provider.Chunk{Err: err})
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			send(&provider.Chunk{Done: true, Usage: usage, RawFinishReason: finishReason})
+			return
+		}
+
+		var chunk compatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			send(&provider.Chunk{Err: err})
+			return
+		}
+		if chunk.Usage != nil {
+			usage = &provider.Usage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+			}
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+			finishReason = chunk.Choices[0].FinishReason
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if !send(&provider.Chunk{Delta: chunk.Choices[0].Delta.Content}) {
+				return
+			}
+		}
+	}
+}
+
+// ExtraFields lists request fields the gateway passes through for the
+// configured API mode; native options are merged into the mapped ones.
+func (p *OllamaProvider) ExtraFields() []string {
+	if p.mode == APIModeOpenAI {
+		return []string{"frequency_penalty", "presence_penalty", "seed", "stop", "top_p"}
+	}
+	return []string{"options", "keep_alive"}
+}
+
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+func (p *OllamaProvider) CostPerInputToken() float64 {
+	return 0
+}
+
+func (p *OllamaProvider) CostPerOutputToken() float64 {
+	return 0
+}
+
+func (p *OllamaProvider) SupportedModels() []string {
+	return p.models
+}
+
+// ListModels returns the configured models, or when none are configured the
+// ones the server has: /api/tags in native mode, /v1/models in OpenAI mode.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	if len(p.models) > 0 {
+		return p.models, nil
+	}
+	return p.listModels(ctx)
+}
+
+// Ping lists the server's models, even when OLLAMA_MODELS fixes them.
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	httpReq, err := p.listRequest(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.Ping(p.httpClient(), httpReq)
+}
+
+// listRequest asks the server for its models: /api/tags in native mode,
+// /v1/models in OpenAI mode.
+func (p *OllamaProvider) listRequest(ctx context.Context) (*http.Request, error) {
+	url := fmt.Sprintf("%s/api/tags", p.baseURL)
+	if p.mode == APIModeOpenAI {
+		url = fmt.Sprintf("%s/v1/models", p.baseURL)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := p.key(); key != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
+	}
+	return httpReq, nil
+}
+
+func (p *OllamaProvider) listModels(ctx context.Context) ([]string, error) {
+	httpReq, err := p.listRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
+	}
+
+	var list struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"` // native
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"` // OpenAI-compatible
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	models := make([]string, 0, len(list.Models)+len(list.Data))
+	for _, m := range list.Models {
+		models = append(models, m.Name)
+	}
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,158 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestComplete_Native(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Expected /api/chat, got %s", r.URL.Path)
+		}
+		var req nativeRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "llama3.1" || req.Stream {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		_ = json.NewEncoder(w).Encode(nativeResponse{
+			Model:           "llama3.1",
+			Message:         chatMessage{Role: "assistant", Content: "Hello from Ollama!"},
+			Done:            true,
+			PromptEvalCount: 12,
+			EvalCount:       4,
+		})
+	}))
+	defer server.Close()
+
+	// An empty model falls back to the first configured one.
+	p := New(server.URL, []string{"llama3.1"})
+	resp, err := p.Complete(context.Background(), &provider.Request{
+		Messages: []provider.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if resp.Content != "Hello from Ollama!" || resp.InputTokens != 12 || resp.OutputTokens != 4 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestCompleteStream_Native(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":9,"eval_count":2}`)
+	}))
+	defer server.Close()
+
+	p := New(server.URL, []string{"llama3.1"})
+	content, usage := drain(t, p, &provider.Request{Model: "llama3.1"})
+	if content != "Hello" {
+		t.Errorf("Expected 'Hello', got %q", content)
+	}
+	if usage == nil || usage.InputTokens != 9 || usage.OutputTokens != 2 {
+		t.Errorf("Expected usage 9/2, got %+v", usage)
+	}
+}
+
+func TestCompleteStream_OpenAICompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer local-key" {
+			t.Errorf("Expected bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := New(server.URL+"/", []string{"qwen2.5"}, WithAPIMode(APIModeOpenAI), WithAPIKey("local-key"))
+	content, usage := drain(t, p, &provider.Request{Model: "qwen2.5"})
+	if content != "Hi" {
+		t.Errorf("Expected 'Hi', got %q", content)
+	}
+	if usage == nil || usage.InputTokens != 5 || usage.OutputTokens != 1 {
+		t.Errorf("Expected usage 5/1, got %+v", usage)
+	}
+}
+
+func drain(t *testing.T, p provider.Provider, req *provider.Request) (string, *provider.Usage) {
+	t.Helper()
+	ch, err := p.CompleteStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+	var content string
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Stream error: %v", chunk.Err)
+		}
+		if chunk.Done {
+			return content, chunk.Usage
+		}
+		content += chunk.Delta
+	}
+	t.Fatal("Stream closed without a Done chunk")
+	return "", nil
+}
+
+func TestComplete_NativeJSONFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req nativeRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if string(req.Format) != `"json"` {
+			t.Errorf("expected format json, got %s", req.Format)
+		}
+		_ = json.NewEncoder(w).Encode(nativeResponse{
+			Message: chatMessage{Role: "assistant", Content: `{"ok":true}`},
+			Done:    true,
+		})
+	}))
+	defer server.Close()
+
+	p := New(server.URL, []string{"llama3.1"})
+	_, err := p.Complete(context.Background(), &provider.Request{
+		Messages:       []provider.Message{{Role: "user", Content: "hi"}},
+		ResponseFormat: &provider.ResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.1:8b"},{"name":"qwen2.5:7b"}]}`)
+		case "/v1/models":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"meta-llama/Llama-3.1-8B-Instruct"}]}`)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	native := New(server.URL, nil).(provider.ModelLister)
+	if models, err := native.ListModels(context.Background()); err != nil || len(models) != 2 || models[0] != "llama3.1:8b" {
+		t.Errorf("Expected the server's tags, got %v, %v", models, err)
+	}
+	compat := New(server.URL, nil, WithAPIMode(APIModeOpenAI)).(provider.ModelLister)
+	if models, err := compat.ListModels(context.Background()); err != nil || len(models) != 1 || models[0] != "meta-llama/Llama-3.1-8B-Instruct" {
+		t.Errorf("Expected the server's model list, got %v, %v", models, err)
+	}
+	configured := New(server.URL, []string{"llama3.1"}).(provider.ModelLister)
+	if models, _ := configured.ListModels(context.Background()); len(models) != 1 || models[0] != "llama3.1" {
+		t.Errorf("Expected configured models to take precedence, got %v", models)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
 )
@@ -16,6 +17,29 @@ import (
 type OpenAIProvider struct {
 	apiKey  string
 	baseURL string
+	client  *http.Client
+
+	keyMu sync.RWMutex // guards apiKey, replaced by SetAPIKey
+}
+
+type Option func(*OpenAIProvider)
+
+// WithBaseURL overrides the API base URL, e.g. the primary of a list of
+// regional endpoints.
+func WithBaseURL(baseURL string) Option {
+	return func(p *OpenAIProvider) {
+		if baseURL != "" {
+			p.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sends upstream requests through client, e.g. one built from
+// provider.Egress (default: http.DefaultClient).
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *OpenAIProvider) {
+		p.client = client
+	}
 }
 
 type openAIRequest struct {
@@ -24,11 +48,29 @@ type openAIRequest struct {
 	MaxTokens   int             `json:"max_tokens,omitempty"`
 	Temperature float64         `json:"temperature,omitempty"`
 	Stream      bool            `json:"stream,omitempty"`
+	// StreamOptions asks for a final chunk carrying token usage.
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+	Tools         []provider.Tool      `json:"tools,omitempty"`
+	ToolChoice    json.RawMessage      `json:"tool_choice,omitempty"`
+	// ResponseFormat is passed through; OpenAI enforces it natively.
+	ResponseFormat *provider.ResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIStreamChunk is one streamed event; Usage is only set on the last one.
+type openAIStreamChunk struct {
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string              `json:"role"`
+	Content    string              `json:"content"`
+	ToolCalls  []provider.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string              `json:"tool_call_id,omitempty"`
 }
 
 type openAIResponse struct {
@@ -39,12 +81,25 @@ type openAIResponse struct {
 }
 
 type openAIChoice struct {
-	Message openAIMessage `json:"message"`
-	Delta   openAIDelta   `json:"delta"`
+	Message      openAIMessage `json:"message"`
+	Delta        openAIDelta   `json:"delta"`
+	FinishReason string        `json:"finish_reason"`
 }
 
 type openAIDelta struct {
-	Content string `json:"content"`
+	Content   string                `json:"content"`
+	ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+}
+
+// openAIToolCallDelta is a fragment of a streamed tool call. The first
+// fragment for an index carries the ID and name; later ones append arguments.
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAIUsage struct {
@@ -52,11 +107,35 @@ type openAIUsage struct {
 	CompletionTokens int `json:"completion_tokens"`
 }
 
-func New(apiKey string) provider.Provider {
-	return &OpenAIProvider{
+func New(apiKey string, opts ...Option) provider.Provider {
+	p := &OpenAIProvider{
 		apiKey:  apiKey,
 		baseURL: "https://api.openai.com/v1",
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *OpenAIProvider) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return http.DefaultClient
+}
+
+// SetAPIKey replaces the key sent with subsequent requests.
+func (p *OpenAIProvider) SetAPIKey(key string) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+	p.apiKey = key
+}
+
+func (p *OpenAIProvider) key() string {
+	p.keyMu.RLock()
+	defer p.keyMu.RUnlock()
+	return p.apiKey
 }
 
 func (p *OpenAIProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
@@ -65,6 +144,10 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *provider.Request) (*
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
 	url := fmt.Sprintf("%s/chat/completions", p.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
@@ -72,9 +155,9 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *provider.Request) (*
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := p.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +165,7 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *provider.Request) (*
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("openai api error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
 	}
 
 	var openAIResp openAIResponse
@@ -95,12 +178,14 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req *provider.Request) (*
 	}
 
 	return &provider.Response{
-		ID:           openAIResp.ID,
-		Content:      openAIResp.Choices[0].Message.Content,
-		InputTokens:  openAIResp.Usage.PromptTokens,
-		OutputTokens: openAIResp.Usage.CompletionTokens,
-		Model:        openAIResp.Model,
-		Provider:     p.Name(),
+		ID:              openAIResp.ID,
+		Content:         openAIResp.Choices[0].Message.Content,
+		ToolCalls:       openAIResp.Choices[0].Message.ToolCalls,
+		InputTokens:     openAIResp.Usage.PromptTokens,
+		OutputTokens:    openAIResp.Usage.CompletionTokens,
+		Model:           openAIResp.Model,
+		Provider:        p.Name(),
+		RawFinishReason: openAIResp.Choices[0].FinishReason,
 	}, nil
 }
 
@@ -108,27 +193,37 @@ func (p *OpenAIProvider) mapRequest(req *provider.Request) openAIRequest {
 	messages := make([]openAIMessage, len(req.Messages))
 	for i, m := range req.Messages {
 		messages[i] = openAIMessage{
-			Role:    m.Role,
-			Content: m.Content,
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
 		}
 	}
 
 	return openAIRequest{
-		Model:       req.Model,
-		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Stream:      req.Stream,
+		Model:          req.Model,
+		Messages:       messages,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+		Stream:         req.Stream,
+		Tools:          req.Tools,
+		ToolChoice:     req.ToolChoice,
+		ResponseFormat: req.ResponseFormat,
 	}
 }
 
 func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
 	openAIReq := p.mapRequest(req)
 	openAIReq.Stream = true
+	openAIReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
 	body, err := json.Marshal(openAIReq)
 	if err != nil {
 		return nil, err
 	}
+	body, err = provider.MergeExtra(body, req.ExtraBody)
+	if err != nil {
+		return nil, err
+	}
 
 	url := fmt.Sprintf("%s/chat/completions", p.baseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
@@ -136,14 +231,14 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Reque
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
 
 	ch := make(chan *provider.Chunk)
 
-	go func() {
+	provider.Streams.Go("openai", func() {
 		defer close(ch)
 
-		resp, err := http.DefaultClient.Do(httpReq)
+		resp, err := p.httpClient().Do(httpReq)
 		if err != nil {
 			select {
 			case ch <- &provider.Chunk{Err: err}:
@@ -156,21 +251,36 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Reque
 		if resp.StatusCode != http.StatusOK {
 			respBody, _ := io.ReadAll(resp.Body)
 			select {
-			case ch <- &provider.Chunk{Err: fmt.Errorf("openai api error (status %d): %s", resp.StatusCode, string(respBody))}:
+			case ch <- &provider.Chunk{Err: provider.NewAPIError(p.Name(), resp.StatusCode, respBody)}:
 			case <-ctx.Done():
 			}
 			return
 		}
 
+		var usage *provider.Usage
+		var toolCalls []provider.ToolCall
+		var finishReason string
+		// finish flushes assembled tool calls ahead of the Done chunk.
+		finish := func() {
+			if len(toolCalls) > 0 {
+				select {
+				case ch <- &provider.Chunk{ToolCalls: toolCalls}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case ch <- &provider.Chunk{Done: true, Usage: usage, RawFinishReason: finishReason}:
+			case <-ctx.Done():
+			}
+		}
+
 		reader := bufio.NewReader(resp.Body)
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err == io.EOF {
-					select {
-					case ch <- &provider.Chunk{Done: true}:
-					case <-ctx.Done():
-					}
+					finish()
 					return
 				}
 				select {
@@ -187,14 +297,11 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Reque
 
 			data := strings.TrimPrefix(line, "data: ")
 			if data == "[DONE]" {
-				select {
-				case ch <- &provider.Chunk{Done: true}:
-				case <-ctx.Done():
-				}
+				finish()
 				return
 			}
 
-			var openAIResp openAIResponse
+			var openAIResp openAIStreamChunk
 			if err := json.Unmarshal([]byte(data), &openAIResp); err != nil {
 				select {
 				case ch <- &provider.Chunk{Err: err}:
@@ -202,8 +309,18 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Reque
 				}
 				return
 			}
+			if openAIResp.Usage != nil {
+				usage = &provider.Usage{
+					InputTokens:  openAIResp.Usage.PromptTokens,
+					OutputTokens: openAIResp.Usage.CompletionTokens,
+				}
+			}
 
 			if len(openAIResp.Choices) > 0 {
+				if fr := openAIResp.Choices[0].FinishReason; fr != "" {
+					finishReason = fr
+				}
+				toolCalls = appendToolCallDeltas(toolCalls, openAIResp.Choices[0].Delta.ToolCalls)
 				content := openAIResp.Choices[0].Delta.Content
 				if content != "" {
 					select {
@@ -214,15 +331,50 @@ func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *provider.Reque
 				}
 			}
 		}
-	}()
+	})
 
 	return ch, nil
 }
 
+// appendToolCallDeltas merges streamed tool call fragments into calls by index.
+func appendToolCallDeltas(calls []provider.ToolCall, deltas []openAIToolCallDelta) []provider.ToolCall {
+	for _, d := range deltas {
+		for len(calls) <= d.Index {
+			calls = append(calls, provider.ToolCall{Type: "function"})
+		}
+		tc := &calls[d.Index]
+		if d.ID != "" {
+			tc.ID = d.ID
+		}
+		tc.Function.Name += d.Function.Name
+		tc.Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
+
+// ExtraFields lists Chat Completions parameters the gateway passes through.
+func (p *OpenAIProvider) ExtraFields() []string {
+	return []string{
+		"frequency_penalty", "presence_penalty", "logit_bias", "logprobs", "top_logprobs",
+		"seed", "stop", "top_p", "user", "parallel_tool_calls", "service_tier",
+		"reasoning_effort", "metadata", "store", "prediction",
+	}
+}
+
 func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
+// Ping lists the upstream's models, which costs nothing.
+func (p *OpenAIProvider) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models", p.baseURL), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
+	return provider.Ping(p.httpClient(), httpReq)
+}
+
 func (p *OpenAIProvider) CostPerInputToken() float64 {
 	return 0.00000015
 }
@@ -234,3 +386,96 @@ func (p *OpenAIProvider) CostPerOutputToken() float64 {
 func (p *OpenAIProvider) SupportedModels() []string {
 	return []string{"gpt-4o", "gpt-4o-mini", "gpt-4", "gpt-3.5-turbo"}
 }
+
+var (
+	chatCapabilities   = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools, provider.CapabilityJSONMode}
+	visionCapabilities = []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools, provider.CapabilityJSONMode, provider.CapabilityVision}
+)
+
+var models = map[string]provider.ModelInfo{
+	"gpt-4o":                 {ContextWindow: 128000, MaxOutputTokens: 16384, Capabilities: visionCapabilities},
+	"gpt-4o-mini":            {ContextWindow: 128000, MaxOutputTokens: 16384, Capabilities: visionCapabilities},
+	"gpt-4":                  {ContextWindow: 8192, MaxOutputTokens: 8192, Capabilities: []string{provider.CapabilityChat, provider.CapabilityStreaming, provider.CapabilityTools}},
+	"gpt-3.5-turbo":          {ContextWindow: 16385, MaxOutputTokens: 4096, Capabilities: chatCapabilities},
+	"text-embedding-3-small": {ContextWindow: 8191, Capabilities: []string{provider.CapabilityEmbeddings}},
+	"text-embedding-3-large": {ContextWindow: 8191, Capabilities: []string{provider.CapabilityEmbeddings}},
+	"text-embedding-ada-002": {ContextWindow: 8191, Capabilities: []string{provider.CapabilityEmbeddings}},
+}
+
+func (p *OpenAIProvider) ModelInfo(model string) (provider.ModelInfo, bool) {
+	info, ok := models[model]
+	return info, ok
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data  []openAIEmbedding `json:"data"`
+	Model string            `json:"model"`
+	Usage openAIUsage       `json:"usage"`
+}
+
+type openAIEmbedding struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func (p *OpenAIProvider) Embed(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	body, err := json.Marshal(openAIEmbeddingRequest{Model: req.Model, Input: req.Input})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/embeddings", p.baseURL)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.key()))
+
+	resp, err := p.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, provider.NewAPIError(p.Name(), resp.StatusCode, respBody)
+	}
+
+	var embResp openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, err
+	}
+	if len(embResp.Data) != len(req.Input) {
+		return nil, fmt.Errorf("openai api returned %d embeddings for %d inputs", len(embResp.Data), len(req.Input))
+	}
+
+	embeddings := make([][]float32, len(embResp.Data))
+	for _, d := range embResp.Data {
+		if d.Index < 0 || d.Index >= len(embeddings) {
+			return nil, fmt.Errorf("openai api returned out-of-range embedding index %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+
+	return &provider.EmbeddingResponse{
+		Embeddings:  embeddings,
+		InputTokens: embResp.Usage.PromptTokens,
+		Model:       embResp.Model,
+		Provider:    p.Name(),
+	}, nil
+}
+
+func (p *OpenAIProvider) EmbeddingModels() []string {
+	return []string{"text-embedding-3-small", "text-embedding-3-large", "text-embedding-ada-002"}
+}
+
+func (p *OpenAIProvider) CostPerEmbeddingToken() float64 {
+	return 0.00000002
+}
@@ -138,3 +138,77 @@ func TestSupportedModels(t *testing.T) {
 		t.Error("gpt-4o-mini should be in supported models")
 	}
 }
+
+func TestCompleteStream_ReportsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("Expected stream_options.include_usage to be requested")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"Hi"}}],"usage":null}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := &OpenAIProvider{apiKey: "test-key", baseURL: server.URL}
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:    "gpt-4o-mini",
+		Messages: []provider.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var usage *provider.Usage
+	for chunk := range ch {
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 3 {
+		t.Errorf("Expected usage 12/3, got %+v", usage)
+	}
+}
+
+func TestCompleteStream_ToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openAIRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if string(body.ToolChoice) != `"auto"` {
+			t.Errorf("expected tool_choice to pass through, got %s", body.ToolChoice)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_weather","arguments":""}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := &OpenAIProvider{apiKey: "test-key", baseURL: server.URL}
+	ch, err := p.CompleteStream(context.Background(), &provider.Request{
+		Model:      "gpt-4o",
+		Messages:   []provider.Message{{Role: "user", Content: "weather?"}},
+		ToolChoice: json.RawMessage(`"auto"`),
+	})
+	if err != nil {
+		t.Fatalf("CompleteStream failed: %v", err)
+	}
+
+	var calls []provider.ToolCall
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("Received error from chunk: %v", chunk.Err)
+		}
+		if len(chunk.ToolCalls) > 0 && chunk.Done {
+			t.Error("tool calls should be flushed before the Done chunk")
+		}
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
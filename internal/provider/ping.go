@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Pinger is implemented by providers that can cheaply check their upstream
+// is reachable, for readiness probes. Ping must not generate tokens.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping sends req, typically a GET of the upstream's model list, through
+// client and reports whether the upstream answered. Any response below 500
+// counts: a 401 or 404 still shows the upstream is reachable.
+func Ping(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("upstream answered %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	status := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/models", nil)
+	if err := Ping(srv.Client(), req); err != nil {
+		t.Errorf("Expected a 401 to count as reachable, got %v", err)
+	}
+	status = http.StatusBadGateway
+	req, _ = http.NewRequest("GET", srv.URL+"/models", nil)
+	if err := Ping(srv.Client(), req); err == nil {
+		t.Error("Expected a 502 to fail the ping")
+	}
+}
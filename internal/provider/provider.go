@@ -2,38 +2,288 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 )
 
 type Request struct {
 	Model       string
 	Messages    []Message
-	MaxTokens   int
+	MaxTokens   int `json:"max_tokens,omitempty"`
 	Temperature float64
 	Stream      bool
+	Tools       []Tool `json:"tools,omitempty"`
+	// ToolChoice is OpenAI's tool_choice: "auto", "none", "required" or
+	// {"type":"function","function":{"name":...}}. Empty leaves it to the upstream.
+	ToolChoice json.RawMessage `json:"tool_choice,omitempty"`
+	// Functions and FunctionCall are the legacy spellings of Tools and
+	// ToolChoice; NormalizeTools folds them in.
+	Functions    []ToolFunction  `json:"functions,omitempty"`
+	FunctionCall json.RawMessage `json:"function_call,omitempty"`
+	// ResponseFormat asks for JSON output; providers without native support
+	// emulate it.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Cache controls the gateway's response cache for this call; it never
+	// reaches upstreams.
+	Cache *CacheOptions `json:"cache,omitempty"`
+	// ExtraBody is merged into the payload of whichever upstream serves the
+	// request, ProviderOptions[name] only into that provider's. The router
+	// drops fields outside the serving provider's allowlist, so providers
+	// receive only ExtraBody.
+	ExtraBody       map[string]json.RawMessage            `json:"extra_body,omitempty"`
+	ProviderOptions map[string]map[string]json.RawMessage `json:"provider_options,omitempty"`
+	// SystemRef names a prompt from the tenant's library ("id" or
+	// "id@version") that the handler expands into the system message,
+	// filling its variables from SystemVars. Neither reaches upstreams.
+	SystemRef  string            `json:"system_ref,omitempty"`
+	SystemVars map[string]string `json:"system_vars,omitempty"`
+	// MaxCost caps the call's estimated cost; providers estimated above it
+	// are skipped. The handler narrows it to the tenant's cap.
+	MaxCost *CostCap `json:"max_cost,omitempty"`
 	// Metadata for routing decisions
-	TenantID    string
-	RequestID   string
+	TenantID        string
+	APIKeyID        string
+	RequestID       string
+	RetrievedDocIDs []string `json:"-"` // set by the retrieval stage
+	EmbeddingCache  string   `json:"-"` // "hit" or "miss", set by the retrieval stage's cache
+	RoutingStrategy string   `json:"-"` // tenant override, set by the handler
+	Provider        string   `json:"-"` // the only provider to try, set by the handler
+	// Synthetic marks monitoring traffic, kept out of the tenant's usage;
+	// async jobs carry it with the request.
+	Synthetic bool
+	// Priority is PriorityInteractive or PriorityBatch, set by the handler;
+	// async jobs carry it with the request.
+	Priority string
+}
+
+// Request priorities. Batch requests draw from rate limit pools of their
+// own and aren't cut off by the router's per-attempt timeout.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// CostCap is the most a request may be estimated to cost: its prompt, plus
+// max_tokens of output (a default when unset), at the serving model's prices.
+type CostCap struct {
+	USD float64 `json:"usd"`
+	// Substitute routes to the most expensive model within the cap when
+	// the requested model has no provider within it, instead of failing.
+	Substitute bool `json:"substitute,omitempty"`
 }
 
 type Message struct {
-	Role    string // "user", "assistant", "system"
-	Content string
+	Role       string // "user", "assistant", "system", "tool"
+	Content    string
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // set on assistant messages
+	ToolCallID string     `json:"tool_call_id,omitempty"` // set on tool messages
+}
+
+// Tool is an OpenAI-style function definition offered to the model.
+type Tool struct {
+	Type     string       `json:"type"` // "function"
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON Schema
+}
+
+// ToolCall is a model's request to invoke a tool.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"` // "function"
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// ResponseFormat is OpenAI's response_format.
+type ResponseFormat struct {
+	Type       string      `json:"type"` // "text", "json_object" or "json_schema"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// Validate rejects unknown types and json_schema formats without a schema.
+func (f *ResponseFormat) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch f.Type {
+	case "text", "json_object":
+		return nil
+	case "json_schema":
+		if f.JSONSchema == nil || len(f.JSONSchema.Schema) == 0 {
+			return fmt.Errorf("response_format json_schema requires json_schema.schema")
+		}
+		if !json.Valid(f.JSONSchema.Schema) {
+			return fmt.Errorf("response_format json_schema.schema is not valid JSON")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported response_format type %q", f.Type)
+}
+
+// Response cache modes for CacheOptions.Mode.
+const (
+	CacheReadWrite = "read_write"
+	CacheReadOnly  = "read_only"
+	CacheOff       = "off"
+)
+
+// CacheOptions override the tenant's response cache defaults for one call.
+type CacheOptions struct {
+	Mode string `json:"mode,omitempty"` // read_write, read_only or off
+	TTL  int    `json:"ttl,omitempty"`  // seconds a stored response is kept
+}
+
+// Validate rejects unknown modes and negative TTLs.
+func (o *CacheOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	switch o.Mode {
+	case "", CacheReadWrite, CacheReadOnly, CacheOff:
+	default:
+		return fmt.Errorf("unsupported cache mode %q", o.Mode)
+	}
+	if o.TTL < 0 {
+		return fmt.Errorf("cache ttl must not be negative")
+	}
+	return nil
+}
+
+// WantsJSON reports whether the client asked for JSON output.
+func (f *ResponseFormat) WantsJSON() bool {
+	return f != nil && (f.Type == "json_object" || f.Type == "json_schema")
+}
+
+// Schema returns the requested JSON schema, or nil for free-form JSON.
+func (f *ResponseFormat) Schema() json.RawMessage {
+	if f == nil || f.Type != "json_schema" || f.JSONSchema == nil {
+		return nil
+	}
+	return f.JSONSchema.Schema
+}
+
+// NormalizeTools rewrites legacy functions/function_call into Tools and
+// ToolChoice so providers only map one shape.
+func (r *Request) NormalizeTools() {
+	for _, f := range r.Functions {
+		r.Tools = append(r.Tools, Tool{Type: "function", Function: f})
+	}
+	r.Functions = nil
+
+	if len(r.FunctionCall) > 0 && len(r.ToolChoice) == 0 {
+		var named struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(r.FunctionCall, &named) == nil && named.Name != "" {
+			r.ToolChoice, _ = json.Marshal(map[string]any{
+				"type":     "function",
+				"function": map[string]string{"name": named.Name},
+			})
+		} else {
+			r.ToolChoice = r.FunctionCall
+		}
+	}
+	r.FunctionCall = nil
+}
+
+// ToolChoiceMode reads ToolChoice as "", "auto", "none" or "required";
+// name is set when the client forces one specific function.
+func (r *Request) ToolChoiceMode() (mode, name string) {
+	if len(r.ToolChoice) == 0 {
+		return "", ""
+	}
+	if json.Unmarshal(r.ToolChoice, &mode) == nil {
+		return mode, ""
+	}
+	var forced struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(r.ToolChoice, &forced) == nil && forced.Function.Name != "" {
+		return "required", forced.Function.Name
+	}
+	return "", ""
 }
 
 type Response struct {
 	ID           string
 	Content      string
+	ToolCalls    []ToolCall
 	InputTokens  int
 	OutputTokens int
 	Model        string
 	Provider     string
 	LatencyMs    int64
+	// FinishReason is the OpenAI-style reason the model stopped, set by the
+	// router from RawFinishReason, the upstream's own value.
+	FinishReason    string
+	RawFinishReason string
 }
 
 type Chunk struct {
 	Delta string
 	Done  bool
 	Err   error
+	// ToolCalls carries tool calls once fully assembled from the stream.
+	ToolCalls []ToolCall
+	// Usage is set on the Done chunk when the upstream reported token counts.
+	Usage *Usage
+	// FinishReason and RawFinishReason are set on the Done chunk, as on Response.
+	FinishReason    string
+	RawFinishReason string
+}
+
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// EstimateTokens approximates the token count of s (~4 bytes per token) for
+// billing when an upstream doesn't report usage.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// ToolNames maps tool call IDs to function names across the assistant turns
+// in messages, for upstreams whose tool results are keyed by name.
+func ToolNames(messages []Message) map[string]string {
+	names := make(map[string]string)
+	for _, m := range messages {
+		for _, tc := range m.ToolCalls {
+			names[tc.ID] = tc.Function.Name
+		}
+	}
+	return names
+}
+
+// ConversationRules describe message-ordering constraints an upstream API enforces.
+type ConversationRules struct {
+	RequireUserFirst   bool // first non-system message must be from the user
+	RequireAlternation bool // user and assistant turns must alternate
+	SingleSystem       bool // at most one system prompt, before the conversation
+}
+
+// RuleProvider is implemented by providers with conversation constraints.
+// Providers that don't implement it accept any well-formed conversation.
+type RuleProvider interface {
+	ConversationRules() ConversationRules
 }
 
 type Provider interface {
@@ -44,3 +294,33 @@ type Provider interface {
 	CostPerOutputToken() float64
 	SupportedModels() []string
 }
+
+// KeySetter is implemented by providers whose API key can be replaced while
+// they serve requests, e.g. on a config reload.
+type KeySetter interface {
+	SetAPIKey(key string)
+}
+
+type EmbeddingRequest struct {
+	Model string
+	Input []string
+}
+
+type EmbeddingResponse struct {
+	Embeddings  [][]float32 // one per input, in order
+	InputTokens int
+	Model       string
+	Provider    string
+}
+
+// Embedder is an optional capability for providers that serve embedding models.
+type Embedder interface {
+	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// EmbeddingsProvider is an Embedder the router can select for /v1/embeddings and bill for.
+type EmbeddingsProvider interface {
+	Embedder
+	EmbeddingModels() []string
+	CostPerEmbeddingToken() float64
+}
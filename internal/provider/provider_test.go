@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeTools_LegacyFunctions(t *testing.T) {
+	req := &Request{
+		Functions:    []ToolFunction{{Name: "get_weather"}},
+		FunctionCall: json.RawMessage(`{"name":"get_weather"}`),
+	}
+	req.NormalizeTools()
+
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("expected functions folded into tools, got %+v", req.Tools)
+	}
+	if req.Functions != nil || req.FunctionCall != nil {
+		t.Error("expected legacy fields to be cleared")
+	}
+	if mode, name := req.ToolChoiceMode(); mode != "required" || name != "get_weather" {
+		t.Errorf("expected forced get_weather, got mode=%q name=%q", mode, name)
+	}
+}
+
+func TestToolChoiceMode(t *testing.T) {
+	tests := []struct {
+		choice string
+		mode   string
+	}{
+		{"", ""},
+		{`"auto"`, "auto"},
+		{`"none"`, "none"},
+		{`"required"`, "required"},
+	}
+	for _, tt := range tests {
+		req := &Request{ToolChoice: json.RawMessage(tt.choice)}
+		if mode, name := req.ToolChoiceMode(); mode != tt.mode || name != "" {
+			t.Errorf("ToolChoiceMode(%s) = %q, %q; want %q", tt.choice, mode, name, tt.mode)
+		}
+	}
+}
+
+func TestResponseFormat_Validate(t *testing.T) {
+	tests := []struct {
+		format  *ResponseFormat
+		wantErr bool
+	}{
+		{nil, false},
+		{&ResponseFormat{Type: "json_object"}, false},
+		{&ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchema{Name: "x", Schema: json.RawMessage(`{"type":"object"}`)}}, false},
+		{&ResponseFormat{Type: "json_schema"}, true},
+		{&ResponseFormat{Type: "yaml"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.format.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+		}
+	}
+}
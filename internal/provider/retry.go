@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy is how Retry repeats rate-limited and failed upstream calls.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; 0 disables Retry
+	Backoff    time.Duration // first wait, doubled per retry with jitter
+	// MaxWait caps a single wait. A Retry-After beyond it is returned to the
+	// caller at once so routing can try another provider instead.
+	MaxWait time.Duration
+}
+
+// Retry is an http.RoundTripper that repeats a request answered with 429 or
+// a 5xx status, waiting for the upstream's Retry-After when it sends one and
+// backing off exponentially otherwise. Requests whose body can't be replayed
+// are sent once.
+type Retry struct {
+	policy RetryPolicy
+	next   http.RoundTripper
+}
+
+// NewRetry retries through next (http.DefaultTransport when nil).
+func NewRetry(policy RetryPolicy, next http.RoundTripper) *Retry {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 500 * time.Millisecond
+	}
+	if policy.MaxWait <= 0 {
+		policy.MaxWait = 10 * time.Second
+	}
+	return &Retry{policy: policy, next: next}
+}
+
+func (r *Retry) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		out := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out = req.Clone(req.Context())
+			out.Body = body
+		}
+		resp, err := r.next.RoundTrip(out)
+		if err != nil || !retryable(resp.StatusCode) || attempt >= r.policy.MaxRetries || !replayable {
+			return resp, err
+		}
+		wait, ok := r.wait(resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// wait returns how long to back off before retrying after resp, and false
+// when the upstream asked for longer than MaxWait.
+func (r *Retry) wait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+		return after, after <= r.policy.MaxWait
+	}
+	backoff := r.policy.Backoff << attempt
+	// Jitter over the upper half keeps replicas from retrying in step.
+	backoff = backoff/2 + rand.N(backoff/2+1)
+	return min(backoff, r.policy.MaxWait), true
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
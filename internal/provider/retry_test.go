@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry_RepeatsRateLimitedRequests(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewRetry(RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}, nil)}
+	resp, err := client.Post(upstream.URL, "application/json", strings.NewReader(`{"model":"gpt-4"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("expected success on the second attempt, got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+	if len(bodies) != 2 || bodies[1] != `{"model":"gpt-4"}` {
+		t.Errorf("expected the body replayed on retry, got %q", bodies)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	status, retryAfter := http.StatusServiceUnavailable, ""
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewRetry(RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond, MaxWait: time.Second}, nil)}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != status || calls.Load() != 3 {
+		t.Errorf("expected the last 503 after 3 attempts, got %d after %d", resp.StatusCode, calls.Load())
+	}
+
+	// A Retry-After beyond MaxWait goes back to the caller at once.
+	calls.Store(0)
+	status, retryAfter = http.StatusTooManyRequests, "120"
+	resp, err = client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || calls.Load() != 1 {
+		t.Errorf("expected no retry past MaxWait, got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+
+	// Client errors are not retried.
+	calls.Store(0)
+	status, retryAfter = http.StatusBadRequest, ""
+	resp, _ = client.Get(upstream.URL)
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("expected a 400 to be returned as-is, got %d calls", calls.Load())
+	}
+}
+
+func TestRetry_StopsWhenContextEnds(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewRetry(RetryPolicy{MaxRetries: 2}, nil)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+	start := time.Now()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected the request to fail with its context")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the wait to end with the context, took %v", time.Since(start))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v %v", d, ok)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Errorf("expected about an hour, got %v %v", d, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("expected an invalid header to be ignored")
+	}
+}
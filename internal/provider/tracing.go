@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is an http.RoundTripper that records each upstream HTTP call as a
+// client span, ending when the response headers arrive. Nothing is
+// propagated to the upstream: its headers are left alone, so tenant baggage
+// never leaves the gateway.
+type Tracing struct {
+	tracer   trace.Tracer
+	provider string
+	next     http.RoundTripper
+}
+
+// NewTracing traces provider's calls through next (http.DefaultTransport
+// when nil).
+func NewTracing(tracer trace.Tracer, provider string, next http.RoundTripper) *Tracing {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Tracing{tracer: tracer, provider: provider, next: next}
+}
+
+func (t *Tracing) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), "provider.http", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	// The URL is left out: Gemini's carries the API key.
+	span.SetAttributes(
+		attribute.String("provider", t.provider),
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+	)
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_RecordsClientSpanWithoutPropagating(t *testing.T) {
+	var headers http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	client := &http.Client{Transport: NewTracing(tracer, "openai", nil)}
+	resp, err := client.Get(upstream.URL + "/v1/models?key=secret")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if headers.Get("Traceparent") != "" || headers.Get("Baggage") != "" {
+		t.Errorf("expected no trace headers upstream, got %v", headers)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "provider.http" {
+		t.Fatalf("expected one provider.http span, got %d", len(spans))
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["provider"] != "openai" || attrs["http.response.status_code"] != "503" {
+		t.Errorf("unexpected span attributes: %v", attrs)
+	}
+	for _, v := range attrs {
+		if v == upstream.URL+"/v1/models?key=secret" {
+			t.Errorf("expected the URL, which may carry a key, left out: %v", attrs)
+		}
+	}
+}
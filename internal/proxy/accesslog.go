@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+)
+
+// Cache outcomes on the access line of a non-streaming completion.
+const (
+	cacheHit       = "hit"
+	cacheCoalesced = "coalesced" // shared another in-flight request's response
+	cacheMiss      = "miss"
+)
+
+// accessLine is what the access log records about one finished completion.
+type accessLine struct {
+	status       int
+	provider     string // the provider that served it, after any fallback
+	model        string
+	inputTokens  int
+	outputTokens int
+	costUSD      float64
+	cache        string // empty for streams, which aren't cached
+	stream       bool
+	start        time.Time
+}
+
+// logCompletion writes the single access-log line for a completion. The
+// context's logger adds the tenant and request IDs.
+func logCompletion(ctx context.Context, c accessLine) {
+	attrs := []slog.Attr{
+		slog.Int("status", c.status),
+		slog.String("provider", c.provider),
+		slog.String("model", c.model),
+		slog.Bool("stream", c.stream),
+		slog.Int("input_tokens", c.inputTokens),
+		slog.Int("output_tokens", c.outputTokens),
+		slog.Float64("cost_usd", c.costUSD),
+		slog.Int64("latency_ms", time.Since(c.start).Milliseconds()),
+	}
+	if c.cache != "" {
+		attrs = append(attrs, slog.String("cache", c.cache))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelInfo, "completion", attrs...)
+}
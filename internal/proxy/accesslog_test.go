@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestHandleComplete_LogsCompletion(t *testing.T) {
+	p := &MockProvider{name: "test-provider", cost: 0.01, supportedModels: []string{"gpt-4"}}
+	h, _ := setupTest([]provider.Provider{p}, true)
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("logging.New failed: %v", err)
+	}
+	req := completionRequest("gpt-4")
+	ctx := logging.With(logging.NewContext(req.Context(), logger), "tenant_id", "tenant-1")
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req.WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one access line, got %q", buf.String())
+	}
+	want := map[string]any{
+		"msg":       "completion",
+		"tenant_id": "tenant-1",
+		"status":    float64(http.StatusOK),
+		"provider":  "test-provider",
+		"model":     "gpt-4",
+		"cache":     cacheMiss,
+		"stream":    false,
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("Expected %s=%v, got %v", k, v, line[k])
+		}
+	}
+	for _, k := range []string{"input_tokens", "output_tokens", "cost_usd", "latency_ms"} {
+		if _, ok := line[k]; !ok {
+			t.Errorf("Expected %s on the access line, got %v", k, line)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import "github.com/vnmchuo/llm-gateway/internal/provider"
+
+// ModelTarget is a concrete model on a named provider.
+type ModelTarget struct {
+	Provider string
+	Model    string
+}
+
+// WithModelAliases defines virtual model names, such as "fast", that resolve
+// to the first healthy target and fall back down the list. Each provider may
+// appear once per alias.
+func WithModelAliases(aliases map[string][]ModelTarget) RouterOption {
+	return func(r *Router) {
+		r.aliases = aliases
+	}
+}
+
+// aliasCandidates returns the healthy providers behind an alias in target
+// order; routing strategies don't reorder an operator's explicit list.
+func (r *Router) aliasCandidates(targets []ModelTarget) []provider.Provider {
+	var candidates []provider.Provider
+	for _, t := range targets {
+		for _, p := range r.providers {
+			if p.Name() == t.Provider && r.available(p.Name()) {
+				candidates = append(candidates, p)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+// ModelFor returns the model p runs for req: the alias target when req names
+// an alias, req.Model otherwise.
+func (r *Router) ModelFor(req *provider.Request, p provider.Provider) string {
+	for _, t := range r.aliases[req.Model] {
+		if t.Provider == p.Name() {
+			return t.Model
+		}
+	}
+	return req.Model
+}
+
+// AliasTargets returns the concrete models an alias runs as, in target
+// order, or nil when model isn't an alias.
+func (r *Router) AliasTargets(model string) []string {
+	targets := r.aliases[model]
+	if len(targets) == 0 {
+		return nil
+	}
+	models := make([]string, len(targets))
+	for i, t := range targets {
+		models[i] = t.Model
+	}
+	return models
+}
+
+// resolve rewrites req for p: an alias becomes the concrete model p serves
+// and passthrough fields are narrowed to what p allows (see extraFor). The
+// caller's request is unchanged so fallbacks can resolve it again.
+func (r *Router) resolve(req *provider.Request, p provider.Provider) *provider.Request {
+	model := r.ModelFor(req, p)
+	if model == req.Model && len(req.ExtraBody) == 0 && len(req.ProviderOptions) == 0 {
+		return req
+	}
+	resolved := *req
+	resolved.Model = model
+	resolved.ExtraBody = r.extraFor(req, p)
+	resolved.ProviderOptions = nil
+	return &resolved
+}
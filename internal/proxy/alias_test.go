@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRoute_AliasResolvesToTarget(t *testing.T) {
+	openai := &MockProvider{name: "openai", cost: 5, supportedModels: []string{"gpt-4o-mini"}}
+	gemini := &MockProvider{name: "gemini", cost: 1, supportedModels: []string{"gemini-1.5-flash"}}
+	router := NewRouter([]provider.Provider{gemini, openai}, WithModelAliases(map[string][]ModelTarget{
+		"fast": {{Provider: "openai", Model: "gpt-4o-mini"}, {Provider: "gemini", Model: "gemini-1.5-flash"}},
+	}))
+
+	if !router.Serves("fast") {
+		t.Error("Expected the alias to be served")
+	}
+	req := &provider.Request{Model: "fast"}
+	p, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if p.Name() != "openai" {
+		t.Errorf("Expected the first target despite its cost, got %s", p.Name())
+	}
+	resp, err := router.Execute(context.Background(), req, p)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if resp.Model != "gpt-4o-mini" {
+		t.Errorf("Expected the concrete model, got %s", resp.Model)
+	}
+	if req.Model != "fast" {
+		t.Errorf("Expected the caller's request to keep the alias, got %s", req.Model)
+	}
+}
+
+func TestExecuteWithFallback_AliasFallsBackToNextTarget(t *testing.T) {
+	openai := &MockProvider{name: "openai", completeErr: errors.New("upstream 500")}
+	gemini := &MockProvider{name: "gemini"}
+	router := NewRouter([]provider.Provider{openai, gemini},
+		WithFallback(2, time.Second),
+		WithModelAliases(map[string][]ModelTarget{
+			"fast": {{Provider: "openai", Model: "gpt-4o-mini"}, {Provider: "gemini", Model: "gemini-1.5-flash"}},
+		}))
+
+	req := &provider.Request{Model: "fast"}
+	first, _ := router.Route(context.Background(), req)
+	resp, served, err := router.ExecuteWithFallback(context.Background(), req, first)
+	if err != nil {
+		t.Fatalf("ExecuteWithFallback failed: %v", err)
+	}
+	if served.Name() != "gemini" || resp.Model != "gemini-1.5-flash" {
+		t.Errorf("Expected gemini-1.5-flash on gemini, got %s on %s", resp.Model, served.Name())
+	}
+}
+
+func TestHandleComplete_AliasToDeniedModel(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4o", "gpt-4o-mini"}}
+	router := NewRouter([]provider.Provider{p}, WithModelAliases(map[string][]ModelTarget{
+		"fast": {{Provider: "test-provider", Model: "gpt-4o-mini"}, {Provider: "test-provider", Model: "gpt-4o"}},
+	}))
+	policies := &mockPolicyStore{policy: &policy.ModelPolicy{Deny: []string{"gpt-4o"}}}
+	h := NewHandler(router, &mockBillingStore{}, ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"), WithModelPolicies(policies))
+
+	body := `{"model":"fast","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "trial-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "gpt-4o") {
+		t.Errorf("Expected the error to name the denied target, got %s", w.Body.String())
+	}
+}
+
+func TestHandleComplete_AliasToModelOutsideWindow(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4o"}}
+	router := NewRouter([]provider.Provider{p}, WithModelAliases(map[string][]ModelTarget{
+		"fast": {{Provider: "test-provider", Model: "gpt-4o"}},
+	}))
+	// Open all day, but only the day after tomorrow.
+	day := strings.ToLower(time.Now().UTC().AddDate(0, 0, 2).Weekday().String()[:3])
+	settings := &tenant.Settings{ModelWindows: []policy.ModelWindow{
+		{Model: "gpt-4o", Days: []string{day}, Start: "00:00", End: "23:59"},
+	}}
+	h := NewHandler(router, &mockBillingStore{}, ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"), WithTenantSettings(&mockTenantStore{settings: settings}))
+
+	body := `{"model":"fast","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/archive"
+)
+
+// TranscriptArchive takes completed responses for compliance archiving.
+type TranscriptArchive interface {
+	// Record queues t without blocking.
+	Record(t *archive.Transcript)
+}
+
+// WithTranscriptArchive sends the final responses of tenants with
+// archive_transcripts set to a.
+func WithTranscriptArchive(a TranscriptArchive) Option {
+	return func(h *Handler) {
+		h.archive = a
+	}
+}
+
+// archiveTranscript fills in t's request fields and records it when the
+// tenant archives transcripts.
+func (h *Handler) archiveTranscript(c *call, t *archive.Transcript) {
+	if h.archive == nil || !c.settings.ArchiveTranscripts {
+		return
+	}
+	t.RequestID = c.requestID
+	t.TenantID = c.tenantID
+	t.ConversationID = c.conversationID
+	t.PromptHash = archive.PromptHash(c.req.Messages)
+	t.CreatedAt = time.Now().UTC()
+	h.archive.Record(t)
+}
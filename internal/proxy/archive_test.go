@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/archive"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+type memArchive struct {
+	mu          sync.Mutex
+	transcripts []*archive.Transcript
+}
+
+func (m *memArchive) Record(t *archive.Transcript) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transcripts = append(m.transcripts, t)
+}
+
+func TestHandleCompleteStream_ArchivesAssembledResponse(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "hello"}, {Delta: " world"}, {Done: true, FinishReason: provider.FinishStop}},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+	archived := &memArchive{}
+	WithTranscriptArchive(archived)(h)
+	WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{ArchiveTranscripts: true}})(h)
+
+	body := []byte(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req = req.WithContext(auth.WithRequestID(auth.WithTenantID(req.Context(), "tenant-1"), "req-1"))
+	h.HandleCompleteStream(httptest.NewRecorder(), req)
+
+	if len(archived.transcripts) != 1 {
+		t.Fatalf("Expected one transcript, got %d", len(archived.transcripts))
+	}
+	got := archived.transcripts[0]
+	if got.Content != "hello world" || !got.Stream || got.RequestID != "req-1" || got.TenantID != "tenant-1" {
+		t.Errorf("Unexpected transcript: %+v", got)
+	}
+	want := archive.PromptHash([]provider.Message{{Role: "user", Content: "hi"}})
+	if got.PromptHash != want {
+		t.Errorf("Expected prompt hash %s, got %s", want, got.PromptHash)
+	}
+
+	WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{}})(h)
+	h.HandleComplete(httptest.NewRecorder(), completionRequest("gpt-4"))
+	if len(archived.transcripts) != 1 {
+		t.Errorf("Expected no transcript for a tenant without archive_transcripts, got %d", len(archived.transcripts))
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// Defaults for WithBatchLimits.
+const (
+	defaultBatchMaxItems    = 100
+	defaultBatchConcurrency = 8
+)
+
+// WithBatchLimits caps how many completions one batch may hold and how many
+// of them run at once. Zero values keep the defaults.
+func WithBatchLimits(maxItems, concurrency int) Option {
+	return func(h *Handler) {
+		h.batchMaxItems = maxItems
+		h.batchConcurrency = concurrency
+	}
+}
+
+// batchResult is one item's outcome, in request order. Response is the body
+// POST /v1/chat/completions would have returned for the item.
+type batchResult struct {
+	Index    int             `json:"index"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`
+}
+
+// HandleCompleteBatch serves POST /v1/chat/completions/batch: an array of
+// chat completion bodies under "requests", run concurrently. Each item goes
+// through the same pipeline as a single completion, including its own rate
+// limit charge, so a batch draws on the tenant's limits like the requests
+// it replaces; items that don't fit fail with 429 while the rest complete.
+func (h *Handler) HandleCompleteBatch(w http.ResponseWriter, r *http.Request) {
+	if auth.GetTenantID(r.Context()) == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+
+	var batch struct {
+		Requests []json.RawMessage `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", "invalid request body")
+		return
+	}
+	maxItems := h.batchMaxItems
+	if maxItems <= 0 {
+		maxItems = defaultBatchMaxItems
+	}
+	if len(batch.Requests) == 0 || len(batch.Requests) > maxItems {
+		apierror.Write(w, http.StatusBadRequest, "", fmt.Sprintf("a batch holds 1 to %d requests", maxItems))
+		return
+	}
+	concurrency := h.batchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	results := make([]batchResult, len(batch.Requests))
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(batch.Requests)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				results[i] = h.completeBatchItem(r, i, batch.Requests[i])
+			}
+		}()
+	}
+	for i := range batch.Requests {
+		items <- i
+	}
+	close(items)
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":  "chat.completion.batch",
+		"results": results,
+	})
+}
+
+// completeBatchItem runs item i through HandleComplete under its own
+// request ID (the batch's, suffixed with the index), so usage, audit and
+// payload records stay per item.
+func (h *Handler) completeBatchItem(r *http.Request, i int, body json.RawMessage) batchResult {
+	ctx := r.Context()
+	if id := auth.GetRequestID(ctx); id != "" {
+		ctx = auth.WithRequestID(ctx, fmt.Sprintf("%s-%d", id, i))
+	}
+	item := r.Clone(ctx)
+	item.Body = io.NopCloser(bytes.NewReader(body))
+	item.ContentLength = int64(len(body))
+
+	rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	h.HandleComplete(rec, item)
+
+	res := batchResult{Index: i, Status: rec.status}
+	out := bytes.TrimSpace(rec.body.Bytes())
+	if !json.Valid(out) {
+		// Plain-text errors, e.g. from http.Error.
+		out = apierror.Marshal(rec.status, apierror.Error{Message: string(out)})
+	}
+	if rec.status == http.StatusOK {
+		res.Response = out
+	} else {
+		res.Error = out
+	}
+	return res
+}
+
+// bufferedResponse collects a handler's response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// slowEchoProvider echoes like echoProvider and records how many calls
+// overlap.
+type slowEchoProvider struct {
+	echoProvider
+	running, peak atomic.Int32
+}
+
+func (p *slowEchoProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return p.echoProvider.Complete(ctx, req)
+}
+
+func TestHandleCompleteBatch_RunsItemsConcurrently(t *testing.T) {
+	p := &slowEchoProvider{echoProvider: echoProvider{MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}}}
+	var mu sync.Mutex
+	requestIDs := map[string]bool{}
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		requestIDs[log.RequestID] = true
+		return nil
+	}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), b,
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithBatchLimits(10, 2))
+
+	var items []string
+	for i := range 6 {
+		items = append(items, fmt.Sprintf(`{"model":"gpt-4","messages":[{"role":"user","content":"item %d"}]}`, i))
+	}
+	items = append(items, `{"model":"gpt-4","messages":"not a list"}`)
+	req := httptest.NewRequest("POST", "/v1/chat/completions/batch", strings.NewReader(`{"requests":[`+strings.Join(items, ",")+`]}`))
+	ctx := auth.WithRequestID(auth.WithTenantID(req.Context(), "test-tenant"), "batch-1")
+	w := httptest.NewRecorder()
+	h.HandleCompleteBatch(w, req.WithContext(ctx))
+	_ = h.usage.Flush(context.Background())
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Results) != 7 {
+		t.Fatalf("Expected a result per item, got %d", len(resp.Results))
+	}
+	for i, res := range resp.Results[:6] {
+		var body map[string]any
+		_ = json.Unmarshal(res.Response, &body)
+		if res.Index != i || res.Status != http.StatusOK || messageContent(body) != fmt.Sprintf("item %d", i) {
+			t.Errorf("Expected item %d's own completion, got %+v", i, res)
+		}
+	}
+	if last := resp.Results[6]; last.Status != http.StatusBadRequest || last.Error == nil {
+		t.Errorf("Expected the malformed item to fail alone, got %+v", last)
+	}
+	if peak := p.peak.Load(); peak > 2 {
+		t.Errorf("Expected at most 2 items at once, got %d", peak)
+	}
+	if len(requestIDs) != 6 || !requestIDs["batch-1-0"] {
+		t.Errorf("Expected each item billed under its own request ID, got %v", requestIDs)
+	}
+}
+
+func TestHandleCompleteBatch_RateLimitedItemsFail(t *testing.T) {
+	h, _ := setupTest([]provider.Provider{&MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}}, false)
+	body := `{"requests":[{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/batch", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleCompleteBatch(w, req)
+
+	var resp struct {
+		Results []batchResult `json:"results"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Results) != 1 || resp.Results[0].Status != http.StatusTooManyRequests {
+		t.Errorf("Expected the item rejected by the tenant's rate limit, got %s", w.Body.String())
+	}
+}
+
+func TestHandleCompleteBatch_RejectsOversizedBatch(t *testing.T) {
+	h, _ := setupTest(nil, true)
+	h.batchMaxItems = 1
+	for _, body := range []string{`{"requests":[]}`, `{"requests":[{},{}]}`, `[]`} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions/batch", strings.NewReader(body))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		h.HandleCompleteBatch(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+)
+
+// headerBroadcast opts a streamed completion into broadcasting: other
+// clients of the tenant can then follow it at
+// GET /v1/chat/completions/stream/{request_id}.
+const headerBroadcast = "X-Broadcast"
+
+// ErrBroadcastNotFound is returned when subscribing to a stream that wasn't
+// broadcast or has expired.
+var ErrBroadcastNotFound = errors.New("broadcast stream not found")
+
+// Broadcasts fan a streamed completion's frames out to subscribers, on any
+// replica. Frames are the server-sent events as written to the client that
+// started the stream.
+type Broadcasts interface {
+	// Publish appends frame to the tenant's stream id. end marks the stream
+	// finished; frame may then be empty.
+	Publish(ctx context.Context, tenantID, id string, frame []byte, end bool) error
+	// Subscribe returns the stream's frames from its start, so late
+	// subscribers catch up. The channel closes after the last frame or once
+	// ctx is done.
+	Subscribe(ctx context.Context, tenantID, id string) (<-chan []byte, error)
+}
+
+// WithBroadcasts lets streamed completions sent with X-Broadcast: true be
+// followed by other clients.
+func WithBroadcasts(b Broadcasts) Option {
+	return func(h *Handler) {
+		h.broadcasts = b
+	}
+}
+
+// broadcastKeepalive is how often an idle subscriber is sent a comment so
+// proxies don't time it out.
+const broadcastKeepalive = 15 * time.Second
+
+// HandleSubscribeStream relays a broadcast stream to another client: the
+// frames sent so far, then each new one until the stream ends.
+func (h *Handler) HandleSubscribeStream(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.broadcasts == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "broadcast streams are not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, http.StatusInternalServerError, "", "streaming unsupported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	frames, err := h.broadcasts.Subscribe(ctx, tenantID, chi.URLParam(r, "request_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrBroadcastNotFound) {
+			status = http.StatusNotFound
+		}
+		apierror.Write(w, status, "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	flusher.Flush()
+
+	keepalive := time.NewTicker(broadcastKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			_, _ = w.Write(frame)
+			flusher.Flush()
+		}
+	}
+}
+
+// broadcaster publishes one stream's frames. Publishing is best effort: the
+// first error is logged and the rest of the stream isn't broadcast, so
+// subscribers see it end early rather than the origin seeing it fail.
+type broadcaster struct {
+	b        Broadcasts
+	ctx      context.Context
+	tenantID string
+	id       string
+	ended    bool
+}
+
+// newBroadcaster returns nil unless r asked for its stream to be broadcast.
+func (h *Handler) newBroadcaster(r *http.Request, c *call) *broadcaster {
+	if h.broadcasts == nil || r.Header.Get(headerBroadcast) != "true" || c.requestID == "" {
+		return nil
+	}
+	// Publish the end of the stream even if the origin disconnects.
+	return &broadcaster{b: h.broadcasts, ctx: context.WithoutCancel(r.Context()), tenantID: c.tenantID, id: c.requestID}
+}
+
+func (bc *broadcaster) publish(frame []byte, end bool) {
+	if bc == nil || bc.ended {
+		return
+	}
+	if err := bc.b.Publish(bc.ctx, bc.tenantID, bc.id, frame, end); err != nil {
+		logging.FromContext(bc.ctx).Warn("proxy: failed to broadcast stream", "err", err)
+		end = true
+	}
+	bc.ended = end
+}
+
+// close ends the broadcast if the stream didn't, e.g. on a disconnect.
+func (bc *broadcaster) close() {
+	bc.publish(nil, true)
+}
+
+// RedisBroadcasts keeps broadcast streams in Redis streams, which
+// subscribers on any replica read from the start.
+type RedisBroadcasts struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewRedisBroadcasts keeps each stream for ttl after its last frame.
+func NewRedisBroadcasts(rdb *redis.Client, ttl time.Duration) *RedisBroadcasts {
+	return &RedisBroadcasts{rdb: rdb, ttl: ttl}
+}
+
+const (
+	broadcastKeyPrefix = "streams:broadcast:"
+	// broadcastMaxFrames bounds a stream's length in Redis.
+	broadcastMaxFrames = 100_000
+	// broadcastPoll is how long a subscriber blocks per read, after which
+	// it checks the stream hasn't expired without ending.
+	broadcastPoll = 5 * time.Second
+)
+
+func broadcastKey(tenantID, id string) string {
+	return broadcastKeyPrefix + tenantID + ":" + id
+}
+
+func (b *RedisBroadcasts) Publish(ctx context.Context, tenantID, id string, frame []byte, end bool) error {
+	key := broadcastKey(tenantID, id)
+	values := map[string]any{"f": frame}
+	if end {
+		values["end"] = 1
+	}
+	pipe := b.rdb.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, MaxLen: broadcastMaxFrames, Approx: true, Values: values})
+	pipe.Expire(ctx, key, b.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisBroadcasts) Subscribe(ctx context.Context, tenantID, id string) (<-chan []byte, error) {
+	key := broadcastKey(tenantID, id)
+	n, err := b.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrBroadcastNotFound
+	}
+
+	frames := make(chan []byte)
+	go func() {
+		defer close(frames)
+		last := "0"
+		for {
+			streams, err := b.rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{key, last}, Block: broadcastPoll}).Result()
+			if errors.Is(err, redis.Nil) {
+				if n, err := b.rdb.Exists(ctx, key).Result(); err != nil || n == 0 {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					logging.FromContext(ctx).Warn("proxy: failed to read broadcast stream", "broadcast_id", id, "err", err)
+				}
+				return
+			}
+			for _, s := range streams {
+				for _, msg := range s.Messages {
+					last = msg.ID
+					if f, _ := msg.Values["f"].(string); f != "" {
+						select {
+						case frames <- []byte(f):
+						case <-ctx.Done():
+							return
+						}
+					}
+					if _, end := msg.Values["end"]; end {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return frames, nil
+}
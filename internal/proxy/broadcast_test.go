@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// memBroadcasts keeps streams in memory; subscribers poll for new frames.
+type memBroadcasts struct {
+	mu      sync.Mutex
+	streams map[string]*memStream
+}
+
+type memStream struct {
+	frames [][]byte
+	ended  bool
+}
+
+func (b *memBroadcasts) Publish(ctx context.Context, tenantID, id string, frame []byte, end bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streams == nil {
+		b.streams = map[string]*memStream{}
+	}
+	s := b.streams[tenantID+":"+id]
+	if s == nil {
+		s = &memStream{}
+		b.streams[tenantID+":"+id] = s
+	}
+	if len(frame) > 0 {
+		s.frames = append(s.frames, frame)
+	}
+	s.ended = s.ended || end
+	return nil
+}
+
+func (b *memBroadcasts) Subscribe(ctx context.Context, tenantID, id string) (<-chan []byte, error) {
+	b.mu.Lock()
+	s := b.streams[tenantID+":"+id]
+	b.mu.Unlock()
+	if s == nil {
+		return nil, ErrBroadcastNotFound
+	}
+	frames := make(chan []byte)
+	go func() {
+		defer close(frames)
+		for next := 0; ; {
+			b.mu.Lock()
+			pending, ended := s.frames[next:], s.ended
+			b.mu.Unlock()
+			for _, f := range pending {
+				select {
+				case frames <- f:
+				case <-ctx.Done():
+					return
+				}
+			}
+			next += len(pending)
+			if ended && len(pending) == 0 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	return frames, nil
+}
+
+func subscribeRequest(tenantID, id string) *http.Request {
+	req := httptest.NewRequest("GET", "/v1/chat/completions/stream/"+id, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("request_id", id)
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(auth.WithTenantID(ctx, tenantID))
+}
+
+func TestHandleCompleteStream_Broadcast(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "Hello"}, {Delta: " world"}, {Done: true}},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+	broadcasts := &memBroadcasts{}
+	WithBroadcasts(broadcasts)(h)
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req.Header.Set("X-Broadcast", "true")
+	ctx := auth.WithRequestID(auth.WithTenantID(req.Context(), "test-tenant"), "req-1")
+	origin := httptest.NewRecorder()
+	h.HandleCompleteStream(origin, req.WithContext(ctx))
+
+	// A subscriber joining after the stream ended still gets all of it.
+	w := httptest.NewRecorder()
+	h.HandleSubscribeStream(w, subscribeRequest("test-tenant", "req-1"))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != origin.Body.String() || !strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("Expected the origin's frames, got %q, want %q", w.Body.String(), origin.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleSubscribeStream(w, subscribeRequest("other-tenant", "req-1"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another tenant's stream, got %d", w.Code)
+	}
+}
+
+func TestHandleCompleteStream_NotBroadcastByDefault(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "Hello"}, {Done: true}},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+	WithBroadcasts(&memBroadcasts{})(h)
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	ctx := auth.WithRequestID(auth.WithTenantID(req.Context(), "test-tenant"), "req-1")
+	h.HandleCompleteStream(httptest.NewRecorder(), req.WithContext(ctx))
+
+	w := httptest.NewRecorder()
+	h.HandleSubscribeStream(w, subscribeRequest("test-tenant", "req-1"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a stream sent without X-Broadcast, got %d", w.Code)
+	}
+}
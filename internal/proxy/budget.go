@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// WithSpendLimits enforces tenants' daily/monthly budgets against spend.
+// The usage recorder must feed the same counter (billing.WithSpendCounter).
+func WithSpendLimits(spend billing.SpendCounter) Option {
+	return func(h *Handler) {
+		h.spend = spend
+	}
+}
+
+func budgetOf(settings *tenant.Settings) billing.Budget {
+	return billing.Budget{DailyUSD: settings.DailyBudgetUSD, MonthlyUSD: settings.MonthlyBudgetUSD}
+}
+
+// enforceBudget writes 402 and returns false once the tenant has spent its
+// daily or monthly budget; otherwise it returns the X-Budget-Warning value
+// for budgets nearly spent.
+func (h *Handler) enforceBudget(w http.ResponseWriter, ctx context.Context, tenantID string, settings *tenant.Settings) (string, bool) {
+	b := h.checkBudget(ctx, tenantID, settings)
+	if !b.Allowed {
+		apierror.Write(w, http.StatusPaymentRequired, "budget_exceeded", b.Reason)
+		return "", false
+	}
+	return b.Warning, true
+}
+
+type budgetCheck struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// checkBudget reports whether the tenant's budget admits a request now.
+// Counter errors fail open so a Redis outage doesn't take completions down
+// with it.
+func (h *Handler) checkBudget(ctx context.Context, tenantID string, settings *tenant.Settings) budgetCheck {
+	budget := budgetOf(settings)
+	if h.spend == nil || budget == (billing.Budget{}) {
+		return budgetCheck{Allowed: true}
+	}
+	spend, err := h.spend.Get(ctx, tenantID, time.Now())
+	if err != nil {
+		logging.FromContext(ctx).Warn("budget: failed to read spend", "err", err)
+		return budgetCheck{Allowed: true}
+	}
+	window := budget.Exceeded(spend)
+	if window == "" {
+		return budgetCheck{Allowed: true, Warning: budgetWarning(budget, spend)}
+	}
+	limit := budget.MonthlyUSD
+	if window == "daily" {
+		limit = budget.DailyUSD
+	}
+	return budgetCheck{Reason: fmt.Sprintf("%s budget of $%.2f reached", window, limit)}
+}
+
+type budgetWindow struct {
+	LimitUSD     *float64  `json:"limit_usd"` // null when uncapped
+	SpentUSD     float64   `json:"spent_usd"`
+	RemainingUSD *float64  `json:"remaining_usd"`
+	ResetsAt     time.Time `json:"resets_at"`
+}
+
+func newBudgetWindow(limit, spent float64, resetsAt time.Time) budgetWindow {
+	bw := budgetWindow{SpentUSD: spent, ResetsAt: resetsAt}
+	if limit > 0 {
+		remaining := max(limit-spent, 0)
+		bw.LimitUSD, bw.RemainingUSD = &limit, &remaining
+	}
+	return bw
+}
+
+// HandleBudget serves GET /v1/budget: the tenant's caps, spend so far and
+// what remains for the current UTC day and month.
+func (h *Handler) HandleBudget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.spend == nil {
+		apierror.Write(w, http.StatusNotFound, "", "spend limits are not enabled")
+		return
+	}
+
+	settings := &tenant.Settings{}
+	if h.tenants != nil {
+		s, err := h.tenants.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load tenant settings")
+			return
+		}
+		settings = s
+	}
+
+	now := time.Now().UTC()
+	spend, err := h.spend.Get(ctx, tenantID, now)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", "failed to read spend")
+		return
+	}
+
+	budget := budgetOf(settings)
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id": tenantID,
+		"daily":     newBudgetWindow(budget.DailyUSD, spend.DayUSD, dayStart.AddDate(0, 0, 1)),
+		"monthly":   newBudgetWindow(budget.MonthlyUSD, spend.MonthUSD, monthStart.AddDate(0, 1, 0)),
+		"exceeded":  budget.Exceeded(spend) != "",
+	})
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type mockSpendCounter struct {
+	spend billing.Spend
+}
+
+func (m *mockSpendCounter) Add(ctx context.Context, tenantID string, costUSD float64, at time.Time) error {
+	return nil
+}
+
+func (m *mockSpendCounter) Get(ctx context.Context, tenantID string, at time.Time) (billing.Spend, error) {
+	return m.spend, nil
+}
+
+func setupBudgetTest(settings *tenant.Settings, spend billing.Spend) *Handler {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	return NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: settings}),
+		WithSpendLimits(&mockSpendCounter{spend: spend}),
+	)
+}
+
+func TestHandleComplete_BudgetExceeded(t *testing.T) {
+	h := setupBudgetTest(&tenant.Settings{DailyBudgetUSD: 5}, billing.Spend{DayUSD: 5.01, MonthUSD: 40})
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "capped-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusPaymentRequired {
+		t.Fatalf("Expected 402, got %d", w.Code)
+	}
+	resp := decodeAPIError(t, w.Body.Bytes())
+	if resp.Error.Code != "budget_exceeded" || resp.Error.Type != "insufficient_quota" || !strings.Contains(resp.Error.Message, "daily") {
+		t.Errorf("Unexpected error body: %s", w.Body.String())
+	}
+}
+
+func TestHandleBudget(t *testing.T) {
+	h := setupBudgetTest(&tenant.Settings{MonthlyBudgetUSD: 100}, billing.Spend{DayUSD: 2, MonthUSD: 30})
+
+	req := httptest.NewRequest("GET", "/v1/budget", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "capped-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleBudget(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Daily    budgetWindow `json:"daily"`
+		Monthly  budgetWindow `json:"monthly"`
+		Exceeded bool         `json:"exceeded"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Daily.LimitUSD != nil || resp.Daily.SpentUSD != 2 {
+		t.Errorf("Expected uncapped daily window with $2 spent, got %+v", resp.Daily)
+	}
+	if resp.Monthly.RemainingUSD == nil || *resp.Monthly.RemainingUSD != 70 {
+		t.Errorf("Expected $70 remaining this month, got %+v", resp.Monthly)
+	}
+	if resp.Exceeded {
+		t.Error("Expected tenant to be within budget")
+	}
+}
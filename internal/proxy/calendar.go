@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// CapacitySchedule is the calendar of known provider capacity changes.
+type CapacitySchedule interface {
+	// Capacity returns the window limiting model on provider at now, or nil.
+	Capacity(provider, model string, now time.Time) *policy.CapacityWindow
+}
+
+// WithCapacityCalendar consults c while routing: providers with no
+// scheduled capacity are left out and those with reduced capacity are tried
+// last, from each window's lead time on.
+func WithCapacityCalendar(c CapacitySchedule) RouterOption {
+	return func(r *Router) {
+		r.calendar = c
+	}
+}
+
+// scheduledCapacity returns the window limiting name running model now, or nil.
+func (r *Router) scheduledCapacity(name, model string) *policy.CapacityWindow {
+	if r.calendar == nil {
+		return nil
+	}
+	return r.calendar.Capacity(name, model, time.Now())
+}
+
+// applyCalendar drops providers scheduled to have no capacity and moves
+// those with reduced capacity to the end, keeping the order within each
+// group.
+func (r *Router) applyCalendar(req *provider.Request, ordered []provider.Provider) []provider.Provider {
+	if r.calendar == nil {
+		return ordered
+	}
+	full := make([]provider.Provider, 0, len(ordered))
+	var reduced []provider.Provider
+	for _, p := range ordered {
+		switch w := r.scheduledCapacity(p.Name(), r.ModelFor(req, p)); {
+		case w == nil:
+			full = append(full, p)
+		case w.Capacity == policy.CapacityReduced:
+			reduced = append(reduced, p)
+		}
+	}
+	return append(full, reduced...)
+}
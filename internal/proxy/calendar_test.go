@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type fixedSchedule map[string]string // provider -> capacity
+
+func (s fixedSchedule) Capacity(name, model string, now time.Time) *policy.CapacityWindow {
+	if c, ok := s[name]; ok {
+		return &policy.CapacityWindow{Provider: name, Capacity: c}
+	}
+	return nil
+}
+
+func TestRoute_CapacityCalendar(t *testing.T) {
+	cheapest := &MockProvider{name: "cheapest", cost: 0.1}
+	cheap := &MockProvider{name: "cheap", cost: 1}
+	pricey := &MockProvider{name: "pricey", cost: 10}
+	schedule := fixedSchedule{"cheapest": policy.CapacityNone, "cheap": policy.CapacityReduced}
+	router := NewRouter([]provider.Provider{cheapest, cheap, pricey}, WithCapacityCalendar(schedule))
+
+	got := router.candidates(&provider.Request{})
+	if len(got) != 2 || got[0].Name() != "pricey" || got[1].Name() != "cheap" {
+		t.Fatalf("Expected [pricey cheap], got %v", names(got))
+	}
+
+	var scheduled *policy.CapacityWindow
+	for _, s := range router.ProviderStatuses() {
+		if s.Name == "cheapest" {
+			scheduled = s.Scheduled
+		}
+	}
+	if scheduled == nil || scheduled.Capacity != policy.CapacityNone {
+		t.Errorf("Expected the status to show the scheduled window, got %+v", scheduled)
+	}
+
+	health := router.Health()
+	if health[0].Health != HealthRed || health[1].Health != HealthYellow || health[2].Health != HealthGreen {
+		t.Errorf("Expected red, yellow, green, got %s, %s, %s", health[0].Health, health[1].Health, health[2].Health)
+	}
+
+	// With nothing else left, a pinned provider under maintenance isn't used.
+	if _, err := router.Route(context.Background(), &provider.Request{Provider: "cheapest"}); err == nil {
+		t.Error("Expected routing to a provider with no capacity to fail")
+	}
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// streamChunk is an OpenAI chat.completion.chunk, the payload of each SSE
+// frame of a streamed completion. CostUSD and Guardrails are the gateway's
+// own additions.
+type streamChunk struct {
+	ID         string           `json:"id"`
+	Object     string           `json:"object"`
+	Created    int64            `json:"created"`
+	Model      string           `json:"model"`
+	Choices    []chunkChoice    `json:"choices"`
+	Usage      *chunkUsage      `json:"usage,omitempty"`
+	CostUSD    *float64         `json:"cost_usd,omitempty"`
+	Guardrails *chunkGuardrails `json:"guardrails,omitempty"`
+}
+
+type chunkChoice struct {
+	Index int        `json:"index"`
+	Delta chunkDelta `json:"delta"`
+	// FinishReason is null until the choice's last chunk.
+	FinishReason     *string           `json:"finish_reason"`
+	ProviderMetadata map[string]string `json:"provider_metadata,omitempty"`
+}
+
+type chunkDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []chunkToolCall `json:"tool_calls,omitempty"`
+}
+
+type chunkToolCall struct {
+	Index    int                       `json:"index"`
+	ID       string                    `json:"id"`
+	Type     string                    `json:"type"`
+	Function provider.ToolCallFunction `json:"function"`
+}
+
+type chunkUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chunkGuardrails struct {
+	Violations []guardrail.Violation `json:"violations"`
+}
+
+// chunkWriter writes a stream's chunks as SSE frames, all with the same ID,
+// creation time and model. The first delta carries the assistant role.
+type chunkWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	id      string
+	created int64
+	model   string // the model served, which a restart may change
+	started bool
+	// broadcast, if set, gets every frame written too.
+	broadcast *broadcaster
+}
+
+func newChunkWriter(w http.ResponseWriter, flusher http.Flusher, id, model string) *chunkWriter {
+	return &chunkWriter{w: w, flusher: flusher, id: id, created: time.Now().Unix(), model: model}
+}
+
+func (cw *chunkWriter) write(chunk streamChunk) {
+	chunk.ID, chunk.Object, chunk.Created, chunk.Model = cw.id, "chat.completion.chunk", cw.created, cw.model
+	if chunk.Choices == nil {
+		chunk.Choices = []chunkChoice{}
+	}
+	frame, _ := json.Marshal(chunk)
+	cw.send(fmt.Appendf(nil, "data: %s\n\n", frame), false)
+}
+
+// send writes a raw frame; end marks the stream's last.
+func (cw *chunkWriter) send(frame []byte, end bool) {
+	_, _ = cw.w.Write(frame)
+	cw.broadcast.publish(frame, end)
+}
+
+// fail writes an error event, which ends the stream.
+func (cw *chunkWriter) fail(payload []byte) {
+	cw.send(fmt.Appendf(nil, "event: error\ndata: %s\n\n", payload), true)
+}
+
+// done writes the [DONE] sentinel that ends a finished stream.
+func (cw *chunkWriter) done() {
+	cw.send([]byte("data: [DONE]\n\n"), true)
+}
+
+// delta writes a chunk of content or tool calls.
+func (cw *chunkWriter) delta(d chunkDelta) {
+	if !cw.started {
+		d.Role, cw.started = "assistant", true
+	}
+	cw.write(streamChunk{Choices: []chunkChoice{{Delta: d}}})
+}
+
+// finish writes the choice's last chunk.
+func (cw *chunkWriter) finish(reason, raw string) {
+	choice := chunkChoice{FinishReason: &reason}
+	if raw != "" {
+		choice.ProviderMetadata = map[string]string{"finish_reason": raw}
+	}
+	cw.write(streamChunk{Choices: []chunkChoice{choice}})
+}
+
+// usage writes the final usage chunk, which has no choices.
+func (cw *chunkWriter) usage(usage *provider.Usage, costUSD float64) {
+	cw.write(streamChunk{
+		Usage: &chunkUsage{
+			PromptTokens:     usage.InputTokens,
+			CompletionTokens: usage.OutputTokens,
+			TotalTokens:      usage.InputTokens + usage.OutputTokens,
+		},
+		CostUSD: &costUSD,
+	})
+}
+
+func (cw *chunkWriter) flush() {
+	cw.flusher.Flush()
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// WithStreamSampling records the cadence of percent (0-100) of streams: the
+// gap before and size of every chunk, and each stream's longest stall, so
+// providers whose streams stall can be spotted even when the streams finish.
+func WithStreamSampling(percent float64) Option {
+	return func(h *Handler) {
+		h.streamSamplePercent = percent
+	}
+}
+
+// chunkSampler records one sampled stream's chunks. A nil sampler, for
+// streams that weren't sampled, records nothing.
+type chunkSampler struct {
+	m        *metrics
+	provider string
+	model    string
+	last     time.Time // when the previous chunk arrived; zero before the first
+	gaps     int
+	maxGap   time.Duration
+}
+
+// sampleStream returns a sampler for a stream picked for sampling, or nil.
+func (h *Handler) sampleStream() *chunkSampler {
+	if h.streamSamplePercent <= 0 || rand.Float64()*100 >= h.streamSamplePercent {
+		return nil
+	}
+	return &chunkSampler{m: h.metrics}
+}
+
+// chunk records a content chunk of size bytes arriving at now from
+// providerName. The gap is measured from the previous chunk, so the first
+// one, covered by time to first token, only records its size.
+func (s *chunkSampler) chunk(ctx context.Context, providerName, model string, size int, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.provider, s.model = providerName, model
+	s.m.recordChunkSize(ctx, providerName, model, size)
+	if !s.last.IsZero() {
+		gap := now.Sub(s.last)
+		s.m.recordChunkGap(ctx, providerName, model, gap)
+		s.gaps++
+		s.maxGap = max(s.maxGap, gap)
+	}
+	s.last = now
+}
+
+// restart forgets the previous chunk when the stream moves to a new attempt,
+// so the retry's latency isn't counted as a stall.
+func (s *chunkSampler) restart() {
+	if s != nil {
+		s.last = time.Time{}
+	}
+}
+
+// finish records the stream's longest gap once it has had two chunks.
+func (s *chunkSampler) finish(ctx context.Context) {
+	if s == nil || s.gaps == 0 {
+		return
+	}
+	s.m.recordMaxChunkGap(ctx, s.provider, s.model, s.maxGap)
+}
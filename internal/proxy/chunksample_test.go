@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHandleCompleteStream_SamplesChunks(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		percent float64
+		sampled bool
+	}{
+		{"sampled", 100, true},
+		{"disabled", 0, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &MockStreamProvider{
+				MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+				chunks:       []*provider.Chunk{{Delta: "hello"}, {Delta: " wor"}, {Delta: "ld"}, {Done: true}},
+			}
+			h, reader := setupMetricsTest(t, p, true)
+			h.streamSamplePercent = tt.percent
+
+			body, _ := json.Marshal(map[string]any{"model": "gpt-4", "stream": true})
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+			h.HandleCompleteStream(httptest.NewRecorder(), req)
+
+			got := collect(t, reader)
+			if !tt.sampled {
+				if hist, ok := got["gateway.stream.chunk_size"].(metricdata.Histogram[int64]); ok && len(hist.DataPoints) > 0 {
+					t.Errorf("Expected no samples, got %+v", hist.DataPoints)
+				}
+				return
+			}
+
+			sizes := got["gateway.stream.chunk_size"].(metricdata.Histogram[int64]).DataPoints
+			if len(sizes) != 1 || sizes[0].Count != 3 || sizes[0].Sum != 11 {
+				t.Errorf("Expected 3 chunks of 11 bytes in all, got %+v", sizes)
+			}
+			if attr(sizes[0].Attributes, "provider") != "test-provider" || attr(sizes[0].Attributes, "model") != "gpt-4" {
+				t.Errorf("Unexpected attributes: %v", sizes[0].Attributes)
+			}
+			gaps := got["gateway.stream.chunk_gap"].(metricdata.Histogram[float64]).DataPoints
+			if len(gaps) != 1 || gaps[0].Count != 2 {
+				t.Errorf("Expected a gap between each pair of chunks, got %+v", gaps)
+			}
+			if maxGaps := got["gateway.stream.max_chunk_gap"].(metricdata.Histogram[float64]).DataPoints; len(maxGaps) != 1 || maxGaps[0].Count != 1 {
+				t.Errorf("Expected one longest gap per stream, got %+v", maxGaps)
+			}
+		})
+	}
+}
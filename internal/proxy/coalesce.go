@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/vnmchuo/llm-gateway/internal/cache"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// completion is the upstream result of a non-streaming request, shared by
+// identical concurrent requests when they are coalesced.
+type completion struct {
+	resp     *provider.Response
+	served   provider.Provider
+	language *languageOutcome // nil unless the output language was enforced
+}
+
+// complete runs c upstream, shrinking it if it overflows the model's context
+// window and the tenant allows that, bills the call and enforces the
+// tenant's output language on the result.
+func (h *Handler) complete(ctx context.Context, c *call) (*completion, error) {
+	resp, served, err := fitContext(ctx, c.req, c.settings.ContextOverflow, func(req *provider.Request) (*provider.Response, provider.Provider, error) {
+		return h.execute(ctx, req, c.provider, c.settings.ManagedTools)
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.logUsage(ctx, c.req, served, resp, "")
+	resp, lang := h.enforceLanguage(ctx, c, resp)
+	return &completion{resp: resp, served: served, language: lang}, nil
+}
+
+// executeCoalesced runs c like complete. When c's tenant enabled coalescing,
+// identical requests already in flight for the tenant share one upstream
+// call; follower reports that c rode along on another request's call and
+// was not billed for it.
+//
+// The shared call doesn't inherit the first caller's cancellation, so one
+// client going away doesn't fail the others; attempt timeouts still bound it.
+func (h *Handler) executeCoalesced(ctx context.Context, c *call) (done *completion, follower bool, err error) {
+	if !c.settings.CoalesceRequests {
+		done, err = h.complete(ctx, c)
+		return done, false, err
+	}
+
+	leader := false
+	ch := h.inflight.DoChan(cache.Key(c.tenantID, c.req), func() (any, error) {
+		leader = true
+		return h.complete(context.WithoutCancel(ctx), c)
+	})
+	select {
+	case res := <-ch:
+		if !leader {
+			h.metrics.recordCoalesced(ctx, c.tenantID, c.req.Model)
+		}
+		if res.Err != nil {
+			return nil, !leader, res.Err
+		}
+		shared := *res.Val.(*completion)
+		// Each caller post-processes its own copy.
+		copied := *shared.resp
+		shared.resp = &copied
+		return &shared, !leader, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// gatedProvider holds every completion until release is closed.
+type gatedProvider struct {
+	MockProvider
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *gatedProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	if p.calls.Add(1) == 1 {
+		close(p.entered)
+	}
+	<-p.release
+	return p.MockProvider.Complete(ctx, req)
+}
+
+func TestHandleComplete_CoalescesIdenticalRequests(t *testing.T) {
+	p := &gatedProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		entered:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	logged := make(chan *billing.UsageLog, 3)
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		logged <- log
+		return nil
+	}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), b,
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{CoalesceRequests: true}}))
+
+	send := func() int {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		h.HandleComplete(w, req)
+		return w.Code
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+	wg.Add(1)
+	go func() { defer wg.Done(); codes[0] = send() }()
+	<-p.entered
+	for i := 1; i < 3; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); codes[i] = send() }()
+	}
+	// Give the followers time to join the in-flight call.
+	time.Sleep(100 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	if n := p.calls.Load(); n != 1 {
+		t.Errorf("Expected one upstream call, got %d", n)
+	}
+	cached := 0
+	for i := 0; i < 3; i++ {
+		select {
+		case log := <-logged:
+			if log.Cached {
+				cached++
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected usage to be logged")
+		}
+	}
+	if cached != 2 {
+		t.Errorf("Expected the two followers logged as unbilled, got %d", cached)
+	}
+}
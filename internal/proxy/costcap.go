@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// CostCapError means every provider able to serve a request is estimated to
+// cost more than its max_cost.
+type CostCapError struct {
+	Model       string
+	MaxUSD      float64
+	CheapestUSD float64 // lowest estimate among the providers ruled out
+}
+
+func (e *CostCapError) Error() string {
+	return fmt.Sprintf("no provider can serve %q within max_cost of $%.6f: the cheapest is estimated at $%.6f",
+		e.Model, e.MaxUSD, e.CheapestUSD)
+}
+
+// effectiveCostCap merges the request's and the tenant's cap: the lower
+// amount applies, so a request can lower its tenant's cap but not raise it,
+// and substitution is allowed when either allows it.
+func effectiveCostCap(req, tenant *provider.CostCap) *provider.CostCap {
+	if req == nil || tenant == nil {
+		return cmp.Or(req, tenant)
+	}
+	return &provider.CostCap{USD: min(req.USD, tenant.USD), Substitute: req.Substitute || tenant.Substitute}
+}
+
+// validateCostCap rejects caps no request could meet.
+func validateCostCap(c *provider.CostCap) error {
+	if c != nil && !(c.USD > 0) {
+		return fmt.Errorf("max_cost.usd must be greater than 0")
+	}
+	return nil
+}
+
+// route picks req's provider. When req's cost cap rules out every provider
+// and allows substitution, req.Model is replaced by the best model within
+// the cap that mp permits, and requested is the model asked for.
+func (h *Handler) route(ctx context.Context, req *provider.Request, mp *policy.ModelPolicy) (p provider.Provider, requested string, err error) {
+	p, err = h.router.Route(ctx, req)
+	var capErr *CostCapError
+	if !errors.As(err, &capErr) || !req.MaxCost.Substitute {
+		return p, "", err
+	}
+	model, ok := h.router.SubstituteUnderCap(req, func(m string) bool { return h.checkModel(mp, m) == nil })
+	if !ok {
+		return nil, "", err
+	}
+	requested = req.Model
+	req.Model = model
+	if p, err = h.router.Route(ctx, req); err != nil {
+		return nil, "", err
+	}
+	logging.FromContext(ctx).Info("proxy: over max_cost, substituted a cheaper model",
+		"max_cost_usd", req.MaxCost.USD, "requested_model", requested, "model", model)
+	return p, requested, nil
+}
+
+// estimateCost is what req is expected to cost on p: promptTokens plus
+// max_tokens of output, or defaultOutputTokens when it sets none.
+func (r *Router) estimateCost(req *provider.Request, p provider.Provider, model string, promptTokens int) float64 {
+	output := req.MaxTokens
+	if output <= 0 {
+		output = defaultOutputTokens
+	}
+	return r.cost(p, model, promptTokens, output)
+}
+
+// underCostCap drops the providers whose estimate exceeds req's cap,
+// keeping the order of the rest.
+func (r *Router) underCostCap(req *provider.Request, candidates []provider.Provider) []provider.Provider {
+	if req.MaxCost == nil || len(candidates) == 0 {
+		return candidates
+	}
+	prompt := promptTokens(req)
+	out := make([]provider.Provider, 0, len(candidates))
+	for _, p := range candidates {
+		if r.estimateCost(req, p, r.ModelFor(req, p), prompt) <= req.MaxCost.USD {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// costCapError explains why no provider is left for req once its cap is
+// applied: a *CostCapError with the cheapest estimate, or ErrNoProviders
+// when none could serve it anyway.
+func (r *Router) costCapError(req *provider.Request) error {
+	eligible := r.eligible(req)
+	if len(eligible) == 0 {
+		return ErrNoProviders
+	}
+	prompt := promptTokens(req)
+	capErr := &CostCapError{Model: req.Model, MaxUSD: req.MaxCost.USD, CheapestUSD: math.Inf(1)}
+	for _, p := range eligible {
+		capErr.CheapestUSD = min(capErr.CheapestUSD, r.estimateCost(req, p, r.ModelFor(req, p), prompt))
+	}
+	return capErr
+}
+
+// SubstituteUnderCap returns the model to serve req with in place of one
+// its cost cap rules out: the most expensive model within the cap that
+// allowed accepts. Price stands in for capability, so that is the best
+// model the cap affords. ok is false when no model fits.
+func (r *Router) SubstituteUnderCap(req *provider.Request, allowed func(model string) bool) (model string, ok bool) {
+	if req.MaxCost == nil {
+		return "", false
+	}
+	prompt := promptTokens(req)
+	best := -1.0
+	for _, p := range r.providers {
+		if !r.available(p.Name()) {
+			continue
+		}
+		for _, m := range r.modelsOf(p) {
+			if cost := r.estimateCost(req, p, m, prompt); cost <= req.MaxCost.USD && cost > best && allowed(m) {
+				model, best = m, cost
+			}
+		}
+	}
+	return model, best >= 0
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// An empty request is estimated at a few prompt tokens, so these providers
+// cost roughly 0.3, 0.03 and 0.003 per request.
+func costCapProviders() []provider.Provider {
+	return []provider.Provider{
+		&MockProvider{name: "large", cost: 0.1, supportedModels: []string{"gpt-4o"}},
+		&MockProvider{name: "medium", cost: 0.01, supportedModels: []string{"gpt-4o-mini"}},
+		&MockProvider{name: "small", cost: 0.001, supportedModels: []string{"nano"}},
+	}
+}
+
+func TestRouter_ExcludesProvidersOverCostCap(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 0.001, supportedModels: []string{"gpt-4o"}}
+	pricey := &MockProvider{name: "pricey", cost: 0.1, supportedModels: []string{"gpt-4o"}}
+	router := NewRouter([]provider.Provider{cheap, pricey})
+	router.SetRoutingStrategy("priority", NewPriorityStrategy([]string{"pricey", "cheap"}))
+
+	req := &provider.Request{Model: "gpt-4o", RoutingStrategy: "priority", MaxCost: &provider.CostCap{USD: 0.05}}
+	p, err := router.Route(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if p.Name() != "cheap" {
+		t.Errorf("Expected the provider over the cap to be skipped, got %s", p.Name())
+	}
+}
+
+func TestRouter_CostCapError(t *testing.T) {
+	router := NewRouter(costCapProviders())
+
+	_, err := router.Route(context.Background(), &provider.Request{Model: "gpt-4o", MaxCost: &provider.CostCap{USD: 0.05}})
+	var capErr *CostCapError
+	if !errors.As(err, &capErr) {
+		t.Fatalf("Expected a CostCapError, got %v", err)
+	}
+	if capErr.MaxUSD != 0.05 || capErr.CheapestUSD <= 0.05 {
+		t.Errorf("Unexpected error: %+v", capErr)
+	}
+
+	_, err = router.Route(context.Background(), &provider.Request{Model: "unknown", MaxCost: &provider.CostCap{USD: 0.05}})
+	if !errors.Is(err, ErrNoProviders) {
+		t.Errorf("Expected ErrNoProviders for a model nobody serves, got %v", err)
+	}
+}
+
+func TestRouter_SubstituteUnderCap(t *testing.T) {
+	router := NewRouter(costCapProviders())
+	req := &provider.Request{Model: "gpt-4o", MaxCost: &provider.CostCap{USD: 0.05, Substitute: true}}
+
+	if model, _ := router.SubstituteUnderCap(req, func(string) bool { return true }); model != "gpt-4o-mini" {
+		t.Errorf("Expected the best model within the cap, got %q", model)
+	}
+	if model, _ := router.SubstituteUnderCap(req, func(m string) bool { return m != "gpt-4o-mini" }); model != "nano" {
+		t.Errorf("Expected the best allowed model, got %q", model)
+	}
+	req.MaxCost.USD = 0.0001
+	if model, ok := router.SubstituteUnderCap(req, func(string) bool { return true }); ok {
+		t.Errorf("Expected no model within the cap, got %q", model)
+	}
+}
+
+func TestEffectiveCostCap(t *testing.T) {
+	low, high := &provider.CostCap{USD: 0.01}, &provider.CostCap{USD: 1, Substitute: true}
+	tests := []struct {
+		req, tenant, want *provider.CostCap
+	}{
+		{nil, nil, nil},
+		{low, nil, low},
+		{nil, high, high},
+		{low, high, &provider.CostCap{USD: 0.01, Substitute: true}},
+		{high, low, &provider.CostCap{USD: 0.01, Substitute: true}},
+	}
+	for _, tt := range tests {
+		if got := effectiveCostCap(tt.req, tt.tenant); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("effectiveCostCap(%v, %v) = %v, want %v", tt.req, tt.tenant, got, tt.want)
+		}
+	}
+}
+
+func TestHandleComplete_CostCap(t *testing.T) {
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	policies := &mockPolicyStore{policy: &policy.ModelPolicy{Deny: []string{"gpt-4o-mini"}}}
+	tenants := &mockTenantStore{settings: &tenant.Settings{MaxCost: &provider.CostCap{USD: 0.05}}}
+	h := NewHandler(NewRouter(costCapProviders()), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(tenants), WithModelPolicies(policies))
+
+	complete := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		h.HandleComplete(w, req)
+		return w
+	}
+
+	w := complete(`{"model":"gpt-4o"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 over the tenant's cap, got %d", w.Code)
+	}
+	if e := decodeAPIError(t, w.Body.Bytes()); e.Error.Code != "cost_cap_exceeded" || e.Error.Param == nil || *e.Error.Param != "max_cost" {
+		t.Errorf("Unexpected error: %s", w.Body.String())
+	}
+
+	// A request can't raise its tenant's cap, and substitution skips models
+	// the tenant's policy denies.
+	w = complete(`{"model":"gpt-4o","max_cost":{"usd":10,"substitute":true}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(headerModelSubstituted); got != "gpt-4o" {
+		t.Errorf("Expected %s to name the requested model, got %q", headerModelSubstituted, got)
+	}
+	if !strings.Contains(w.Body.String(), `"nano"`) {
+		t.Errorf("Expected nano to serve the request, got %s", w.Body.String())
+	}
+
+	if w := complete(`{"model":"gpt-4o","max_cost":{"usd":0}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a zero cap, got %d", w.Code)
+	}
+}
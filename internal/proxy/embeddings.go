@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/tokenizer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type embeddingsRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// inputs accepts OpenAI's "input": "text" or "input": ["a", "b"].
+func (r *embeddingsRequest) inputs() []string {
+	var one string
+	if err := json.Unmarshal(r.Input, &one); err == nil {
+		if one == "" {
+			return nil
+		}
+		return []string{one}
+	}
+	var many []string
+	if err := json.Unmarshal(r.Input, &many); err == nil {
+		return many
+	}
+	return nil
+}
+
+// HandleEmbeddings serves POST /v1/embeddings through the same rate limiting
+// and usage billing as chat completions.
+func (h *Handler) HandleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+
+	requestID := auth.GetRequestID(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	synthetic, err := syntheticTraffic(r)
+	if err != nil {
+		writeSyntheticError(w, err)
+		return
+	}
+	priority, err := requestPriority(r)
+	if err != nil {
+		writePriorityError(w, err)
+		return
+	}
+
+	var body embeddingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", "invalid request body")
+		return
+	}
+	input := body.inputs()
+	if len(input) == 0 {
+		apierror.Write(w, http.StatusBadRequest, "", "input must be a non-empty string or array of strings")
+		return
+	}
+	req := &provider.EmbeddingRequest{Model: body.Model, Input: input}
+
+	warnings := limitWarnings{}
+	if h.tenants != nil {
+		settings, err := h.tenants.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load tenant settings")
+			return
+		}
+		budgetWarn, ok := h.enforceBudget(w, ctx, tenantID, settings)
+		if !ok {
+			return
+		}
+		if budgetWarn != "" {
+			warnings[headerBudgetWarning] = budgetWarn
+		}
+	}
+
+	if h.policies != nil {
+		mp, err := h.policies.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load model policy")
+			return
+		}
+		if err := h.checkModel(mp, req.Model); err != nil {
+			apierror.Write(w, http.StatusForbidden, "", err.Error())
+			return
+		}
+	}
+
+	_, span := h.tracer.Start(ctx, "proxy.embeddings")
+	defer span.End()
+	// tenant_id comes from the request's baggage, as the trace records it.
+	span.SetAttributes(
+		attribute.String("request_id", requestID),
+		attribute.String("model", req.Model),
+		attribute.Int("inputs", len(input)),
+	)
+
+	inputTokens := 0
+	tk := tokenizer.ForModel(req.Model)
+	for _, s := range input {
+		inputTokens += tk.Count(s)
+	}
+	headroom, allowed, err := h.limiter.Admit(ctx, rateLimitSubject(ctx, tenantID, priority), max(inputTokens, 1))
+	if err != nil || !allowed {
+		writeRateLimited(w)
+		return
+	}
+	if warn := rateLimitWarning(headroom); warn != "" {
+		warnings[headerRateLimitWarning] = warn
+	}
+
+	p, err := h.router.RouteEmbeddings(ctx, req)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	start := time.Now()
+	response, err := h.router.ExecuteEmbeddings(ctx, req, p)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	latency := time.Since(start).Milliseconds()
+
+	model := req.Model
+	if model == "" {
+		model = response.Model
+	}
+	cost := h.router.cost(p, model, response.InputTokens, 0)
+	h.usage.Record(ctx, &billing.UsageLog{
+		TenantID:    tenantID,
+		APIKeyID:    auth.GetAPIKeyID(ctx),
+		RequestID:   requestID,
+		Provider:    p.Name(),
+		Model:       response.Model,
+		InputTokens: response.InputTokens,
+		CostUSD:     cost,
+		LatencyMs:   latency,
+		Synthetic:   synthetic,
+	})
+
+	data := make([]map[string]interface{}, len(response.Embeddings))
+	for i, e := range response.Embeddings {
+		data[i] = map[string]interface{}{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": e,
+		}
+	}
+
+	warnings.apply(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":   "list",
+		"data":     data,
+		"model":    response.Model,
+		"provider": p.Name(),
+		"usage": map[string]int{
+			"prompt_tokens": response.InputTokens,
+			"total_tokens":  response.InputTokens,
+		},
+	})
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// dryRun reports whether r asks for an estimate in place of a completion
+// (?dry_run=true).
+func dryRun(r *http.Request) bool {
+	ok, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return ok
+}
+
+type estimateUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	MaxCompletionTokens int `json:"max_completion_tokens"`
+}
+
+type estimateCost struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Total  float64 `json:"total"`
+}
+
+type estimateRateLimit struct {
+	Allowed           bool  `json:"allowed"`
+	TokensRemaining   int64 `json:"tokens_remaining,omitempty"`
+	TokensLimit       int64 `json:"tokens_limit,omitempty"`
+	RequestsRemaining int64 `json:"requests_remaining,omitempty"`
+	RequestsLimit     int64 `json:"requests_limit,omitempty"`
+}
+
+type estimate struct {
+	Object         string            `json:"object"`
+	Provider       string            `json:"provider"`
+	Model          string            `json:"model"`
+	RequestedModel string            `json:"requested_model,omitempty"` // set when a cost cap substituted the model
+	Usage          estimateUsage     `json:"usage"`
+	CostUSD        estimateCost      `json:"cost_usd"`
+	RateLimit      estimateRateLimit `json:"rate_limit"`
+	Budget         budgetCheck       `json:"budget"`
+}
+
+// HandleEstimate serves POST /v1/estimate, and completion requests with
+// ?dry_run=true: it counts the prompt, routes the request as a completion
+// would be and returns the provider and model picked, the estimated cost at
+// max_tokens (or the default output allowance) and whether the request would
+// pass the rate limit and budget right now. Nothing is sent upstream, taken
+// from the rate limit windows or billed. Guardrails and retrieval don't run,
+// so a completion can still be rejected, or cost more, than estimated.
+func (h *Handler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	requestID := auth.GetRequestID(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	priority, err := requestPriority(r)
+	if err != nil {
+		writePriorityError(w, err)
+		return
+	}
+	req, _, err := h.decodeRequest(w, r, tenantID, requestID)
+	if err != nil {
+		return
+	}
+	req.Priority = priority
+	settings := &tenant.Settings{}
+	if h.tenants != nil {
+		s, err := h.tenants.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load tenant settings")
+			return
+		}
+		settings = s
+	}
+
+	_, span := h.tracer.Start(ctx, "proxy.estimate")
+	defer span.End()
+	// tenant_id comes from the request's baggage, as the trace records it.
+	span.SetAttributes(
+		attribute.String("request_id", requestID),
+		attribute.String("model", req.Model),
+	)
+
+	warnings := limitWarnings{}
+	p, err := h.routeRequest(w, r, req, settings, warnings, span)
+	if err != nil {
+		return
+	}
+
+	headroom, allowed, err := h.limiter.Check(ctx, rateLimitSubject(ctx, tenantID, priority), rateLimitTokens(req))
+	if err != nil {
+		log.Printf("estimate: failed to check rate limit for tenant %s: %v", tenantID, err)
+	}
+
+	model := h.router.ModelFor(req, p)
+	in := promptTokens(req)
+	out := req.MaxTokens
+	if out <= 0 {
+		out = defaultOutputTokens
+	}
+	inputCost, outputCost := h.router.cost(p, model, in, 0), h.router.cost(p, model, 0, out)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(estimate{
+		Object:         "chat.completion.estimate",
+		Provider:       p.Name(),
+		Model:          model,
+		RequestedModel: warnings[headerModelSubstituted],
+		Usage:          estimateUsage{PromptTokens: in, MaxCompletionTokens: out},
+		CostUSD:        estimateCost{Input: inputCost, Output: outputCost, Total: inputCost + outputCost},
+		RateLimit: estimateRateLimit{
+			Allowed:           allowed,
+			TokensRemaining:   headroom.TokensRemaining,
+			TokensLimit:       headroom.TokensLimit,
+			RequestsRemaining: headroom.RequestsRemaining,
+			RequestsLimit:     headroom.RequestsLimit,
+		},
+		Budget: h.checkBudget(ctx, tenantID, settings),
+	})
+}
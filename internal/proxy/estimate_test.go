@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestHandleComplete_DryRun(t *testing.T) {
+	// Any upstream call fails, so a 200 means none was made.
+	p := &MockProvider{name: "test-provider", cost: 1, supportedModels: []string{"gpt-4"}, completeErr: errors.New("upstream called")}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{DailyBudgetUSD: 5}}),
+		WithSpendLimits(&mockSpendCounter{spend: billing.Spend{DayUSD: 5.01}}),
+	)
+
+	body := `{"model":"gpt-4","max_tokens":50,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions?dry_run=true", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var resp estimate
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode estimate: %v", err)
+	}
+	if resp.Provider != "test-provider" || resp.Model != "gpt-4" {
+		t.Errorf("Expected the routed provider and model, got %+v", resp)
+	}
+	if resp.Usage.PromptTokens == 0 || resp.Usage.MaxCompletionTokens != 50 {
+		t.Errorf("Expected the prompt counted and max_tokens of output, got %+v", resp.Usage)
+	}
+	if resp.CostUSD.Input != float64(resp.Usage.PromptTokens) || resp.CostUSD.Total != resp.CostUSD.Input+resp.CostUSD.Output {
+		t.Errorf("Expected the prompt priced at the provider's rate, got %+v", resp.CostUSD)
+	}
+	if !resp.RateLimit.Allowed {
+		t.Errorf("Expected the rate limit to admit the request, got %+v", resp.RateLimit)
+	}
+	if resp.Budget.Allowed || !strings.Contains(resp.Budget.Reason, "daily") {
+		t.Errorf("Expected the spent daily budget reported, got %+v", resp.Budget)
+	}
+}
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// headerConversationID tags a completion as a turn of a client-side
+// conversation, so its stored exchanges can be exported together.
+const headerConversationID = "X-Conversation-ID"
+
+const maxConversationIDLen = 128
+
+// conversationIDFrom reads X-Conversation-ID: up to 128 letters, digits and
+// . _ : - characters.
+func conversationIDFrom(r *http.Request) (string, error) {
+	id := r.Header.Get(headerConversationID)
+	if id == "" {
+		return "", nil
+	}
+	if len(id) > maxConversationIDLen {
+		return "", errors.New("invalid X-Conversation-ID: longer than 128 characters")
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == ':', c == '-':
+		default:
+			return "", errors.New("invalid X-Conversation-ID: use letters, digits, '.', '_', ':' and '-'")
+		}
+	}
+	return id, nil
+}
+
+// WithPayloadAudit stores full exchanges for tenants with audit_payloads set
+// and serves them from GET /v1/requests/{request_id}.
+func WithPayloadAudit(logger *audit.PayloadLogger) Option {
+	return func(h *Handler) {
+		h.payloads = logger
+	}
+}
+
+// auditExchange records a completion's request and response, or error, when
+// the tenant opted in.
+func (h *Handler) auditExchange(c *call, providerName, model string, response any, err error) {
+	if h.payloads == nil || !c.settings.AuditPayloads {
+		return
+	}
+	request, marshalErr := json.Marshal(c.req)
+	if marshalErr != nil {
+		return
+	}
+	e := &audit.Exchange{
+		RequestID:      c.requestID,
+		TenantID:       c.tenantID,
+		ConversationID: c.conversationID,
+		Provider:       providerName,
+		Model:          model,
+		Request:        request,
+	}
+	if response != nil {
+		e.Response, _ = json.Marshal(response)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	h.payloads.Record(e, c.settings.AuditRedactions)
+}
+
+// HandleGetRequest serves GET /v1/requests/{request_id}: the stored exchange
+// for one of the tenant's requests.
+func (h *Handler) HandleGetRequest(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.payloads == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "payload auditing is not enabled")
+		return
+	}
+
+	exchange, err := h.payloads.Store().GetExchange(r.Context(), tenantID, chi.URLParam(r, "request_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, audit.ErrExchangeNotFound) {
+			status = http.StatusNotFound
+		}
+		apierror.Write(w, status, "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(exchange)
+}
+
+// turnCall is one upstream call made for a conversation turn: the request's
+// own call, or one a gateway stage such as translation added.
+type turnCall struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Stage        string  `json:"stage,omitempty"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	LatencyMs    int64   `json:"latency_ms"`
+	Cached       bool    `json:"cached,omitempty"`
+	FinishReason string  `json:"finish_reason,omitempty"`
+}
+
+type turnUsage struct {
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	LatencyMs    int64   `json:"latency_ms"`
+}
+
+// conversationTurn is a stored exchange annotated with what it cost. Usage
+// sums every call of the turn; its latency is the request's own call.
+type conversationTurn struct {
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Provider  string          `json:"provider,omitempty"`
+	Model     string          `json:"model,omitempty"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Usage     turnUsage       `json:"usage"`
+	Calls     []turnCall      `json:"calls"`
+}
+
+// HandleExportConversation serves GET /v1/conversations/{conversation_id}/export:
+// every stored turn of one of the tenant's conversations, oldest first, with
+// the provider, model, latency and cost of each.
+func (h *Handler) HandleExportConversation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.payloads == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "payload auditing is not enabled")
+		return
+	}
+
+	conversationID := chi.URLParam(r, "conversation_id")
+	exchanges, err := h.payloads.Store().ListConversation(ctx, tenantID, conversationID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if len(exchanges) == 0 {
+		apierror.Write(w, http.StatusNotFound, "", "conversation not found")
+		return
+	}
+
+	requestIDs := make([]string, len(exchanges))
+	for i, e := range exchanges {
+		requestIDs[i] = e.RequestID
+	}
+	logs, err := h.billing.GetUsageByRequests(ctx, tenantID, requestIDs)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	turns := make([]*conversationTurn, len(exchanges))
+	byRequest := make(map[string]*conversationTurn, len(exchanges))
+	for i, e := range exchanges {
+		turns[i] = &conversationTurn{
+			RequestID: e.RequestID,
+			CreatedAt: e.CreatedAt,
+			Provider:  e.Provider,
+			Model:     e.Model,
+			Request:   e.Request,
+			Response:  e.Response,
+			Error:     e.Error,
+			Calls:     []turnCall{},
+		}
+		byRequest[e.RequestID] = turns[i]
+	}
+	var total turnUsage
+	for _, l := range logs {
+		t := byRequest[l.RequestID]
+		if t == nil {
+			continue
+		}
+		t.Calls = append(t.Calls, turnCall{
+			Provider:     l.Provider,
+			Model:        l.Model,
+			Stage:        l.Stage,
+			InputTokens:  l.InputTokens,
+			OutputTokens: l.OutputTokens,
+			CostUSD:      l.CostUSD,
+			LatencyMs:    l.LatencyMs,
+			Cached:       l.Cached,
+			FinishReason: l.FinishReason,
+		})
+		t.Usage.InputTokens += l.InputTokens
+		t.Usage.OutputTokens += l.OutputTokens
+		t.Usage.CostUSD += l.CostUSD
+		if l.Stage == "" {
+			t.Usage.LatencyMs = l.LatencyMs
+		}
+		total.InputTokens += l.InputTokens
+		total.OutputTokens += l.OutputTokens
+		total.CostUSD += l.CostUSD
+		total.LatencyMs += l.LatencyMs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":          "conversation.export",
+		"conversation_id": conversationID,
+		"tenant_id":       tenantID,
+		"turns":           turns,
+		"total":           total,
+	})
+}
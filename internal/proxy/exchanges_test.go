@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type memExchangeStore struct {
+	mu        sync.Mutex
+	exchanges map[string]*audit.Exchange
+	saved     chan struct{}
+}
+
+func (m *memExchangeStore) SaveExchange(ctx context.Context, e *audit.Exchange) error {
+	m.mu.Lock()
+	m.exchanges[e.TenantID+"/"+e.RequestID] = e
+	m.mu.Unlock()
+	m.saved <- struct{}{}
+	return nil
+}
+
+func (m *memExchangeStore) GetExchange(ctx context.Context, tenantID, requestID string) (*audit.Exchange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.exchanges[tenantID+"/"+requestID]
+	if !ok {
+		return nil, audit.ErrExchangeNotFound
+	}
+	return e, nil
+}
+
+func (m *memExchangeStore) ListConversation(ctx context.Context, tenantID, conversationID string) ([]*audit.Exchange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*audit.Exchange
+	for _, e := range m.exchanges {
+		if e.TenantID == tenantID && e.ConversationID == conversationID {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *memExchangeStore) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func setupExchangesTest(settings *tenant.Settings) (*Handler, *memExchangeStore) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	store := &memExchangeStore{exchanges: map[string]*audit.Exchange{}, saved: make(chan struct{}, 1)}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: settings}),
+		WithPayloadAudit(audit.NewPayloadLogger(store, time.Hour)))
+	return h, store
+}
+
+func getRequest(h *Handler, tenantID, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/v1/requests/"+requestID, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("request_id", requestID)
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), tenantID), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+	h.HandleGetRequest(w, req.WithContext(ctx))
+	return w
+}
+
+func auditedRequest(body, requestID string) *http.Request {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	ctx := auth.WithRequestID(auth.WithTenantID(req.Context(), "tenant-1"), requestID)
+	return req.WithContext(ctx)
+}
+
+func TestHandleComplete_AuditsOptedInTenant(t *testing.T) {
+	h, store := setupExchangesTest(&tenant.Settings{
+		AuditPayloads:   true,
+		AuditRedactions: []audit.RedactionRule{{Pattern: `\d{4}-\d{4}`}},
+	})
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"card 1234-5678"}]}`
+	req := auditedRequest(body, "req-1")
+	h.HandleComplete(httptest.NewRecorder(), req)
+
+	select {
+	case <-store.saved:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the exchange to be stored")
+	}
+
+	w := getRequest(h, "tenant-1", "req-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got audit.Exchange
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Provider != "test-provider" || got.Model != "gpt-4" {
+		t.Errorf("Unexpected exchange: %+v", got)
+	}
+	if strings.Contains(string(got.Request), "1234-5678") || !strings.Contains(string(got.Request), "card [REDACTED]") {
+		t.Errorf("Expected the card number to be redacted, got %s", got.Request)
+	}
+	if !strings.Contains(string(got.Response), `"content":"mock"`) {
+		t.Errorf("Expected the completion in the response, got %s", got.Response)
+	}
+
+	if w := getRequest(h, "other-tenant", "req-1"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another tenant, got %d", w.Code)
+	}
+}
+
+func TestHandleComplete_NoAuditWithoutOptIn(t *testing.T) {
+	h, store := setupExchangesTest(&tenant.Settings{})
+
+	h.HandleComplete(httptest.NewRecorder(), completionRequest("gpt-4"))
+
+	select {
+	case <-store.saved:
+		t.Error("Expected no exchange for a tenant that did not opt in")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func exportConversation(h *Handler, tenantID, conversationID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/v1/conversations/"+conversationID+"/export", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("conversation_id", conversationID)
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), tenantID), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+	h.HandleExportConversation(w, req.WithContext(ctx))
+	return w
+}
+
+func TestHandleExportConversation_AnnotatesTurns(t *testing.T) {
+	h, store := setupExchangesTest(&tenant.Settings{AuditPayloads: true})
+	h.billing = &mockBillingStore{
+		getUsageByRequestsFunc: func(ctx context.Context, tenantID string, requestIDs []string) ([]*billing.UsageLog, error) {
+			if tenantID != "tenant-1" || strings.Join(requestIDs, ",") != "req-1,req-2" {
+				t.Errorf("Unexpected usage lookup: %s %v", tenantID, requestIDs)
+			}
+			return []*billing.UsageLog{
+				{RequestID: "req-1", Provider: "test-provider", Model: "gpt-4", InputTokens: 10, OutputTokens: 5, CostUSD: 0.01, LatencyMs: 120},
+				{RequestID: "req-2", Provider: "test-provider", Model: "gpt-4", InputTokens: 20, OutputTokens: 8, CostUSD: 0.02, LatencyMs: 300},
+				{RequestID: "req-2", Provider: "test-provider", Model: "gpt-4", InputTokens: 4, OutputTokens: 4, CostUSD: 0.005, LatencyMs: 90, Stage: billing.StageTranslation},
+			}, nil
+		},
+	}
+
+	for _, id := range []string{"req-1", "req-2"} {
+		req := auditedRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, id)
+		req.Header.Set("X-Conversation-ID", "conv-1")
+		h.HandleComplete(httptest.NewRecorder(), req)
+		select {
+		case <-store.saved:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the exchange to be stored")
+		}
+	}
+
+	w := exportConversation(h, "tenant-1", "conv-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got struct {
+		ConversationID string `json:"conversation_id"`
+		Turns          []struct {
+			RequestID string          `json:"request_id"`
+			Provider  string          `json:"provider"`
+			Response  json.RawMessage `json:"response"`
+			Usage     turnUsage       `json:"usage"`
+			Calls     []turnCall      `json:"calls"`
+		} `json:"turns"`
+		Total turnUsage `json:"total"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.ConversationID != "conv-1" || len(got.Turns) != 2 {
+		t.Fatalf("Expected both turns of conv-1, got %s", w.Body.String())
+	}
+	if got.Turns[0].RequestID != "req-1" || got.Turns[0].Provider != "test-provider" || len(got.Turns[0].Response) == 0 {
+		t.Errorf("Unexpected first turn: %+v", got.Turns[0])
+	}
+	second := got.Turns[1]
+	if len(second.Calls) != 2 || second.Calls[1].Stage != billing.StageTranslation {
+		t.Errorf("Expected the translation call on the second turn, got %+v", second.Calls)
+	}
+	if second.Usage.InputTokens != 24 || second.Usage.LatencyMs != 300 {
+		t.Errorf("Expected usage summed over calls with the request's own latency, got %+v", second.Usage)
+	}
+	if got.Total.InputTokens != 34 || got.Total.OutputTokens != 17 {
+		t.Errorf("Unexpected totals: %+v", got.Total)
+	}
+
+	if w := exportConversation(h, "other-tenant", "conv-1"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another tenant, got %d", w.Code)
+	}
+}
+
+func TestHandleComplete_RejectsInvalidConversationID(t *testing.T) {
+	h, _ := setupExchangesTest(&tenant.Settings{AuditPayloads: true})
+
+	req := auditedRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, "req-1")
+	req.Header.Set("X-Conversation-ID", "conv 1/../x")
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// WithExtraFields allows more passthrough payload fields per provider name, on
+// top of the fields each provider allows itself, for upstream features the
+// gateway doesn't model yet.
+func WithExtraFields(fields map[string][]string) RouterOption {
+	return func(r *Router) {
+		for name, list := range fields {
+			for _, f := range list {
+				r.allowExtra(name, f)
+			}
+		}
+	}
+}
+
+func (r *Router) allowExtra(providerName, field string) {
+	if r.extraFields == nil {
+		r.extraFields = make(map[string]map[string]bool)
+	}
+	if r.extraFields[providerName] == nil {
+		r.extraFields[providerName] = make(map[string]bool)
+	}
+	r.extraFields[providerName][field] = true
+}
+
+// ValidateExtra checks req's passthrough fields: extra_body against p, the
+// provider routed to, and provider_options against each named provider.
+func (r *Router) ValidateExtra(req *provider.Request, p provider.Provider) error {
+	for field := range req.ExtraBody {
+		if !r.extraFields[p.Name()][field] {
+			return fmt.Errorf("extra_body field %q is not allowed for provider %s", field, p.Name())
+		}
+	}
+	for name, fields := range req.ProviderOptions {
+		if r.breaker(name) == nil {
+			return fmt.Errorf("provider_options: unknown provider %q", name)
+		}
+		for field := range fields {
+			if !r.extraFields[name][field] {
+				return fmt.Errorf("provider_options field %q is not allowed for provider %s", field, name)
+			}
+		}
+	}
+	return nil
+}
+
+// extraFor merges the passthrough fields p receives: the allowed part of
+// extra_body, overridden by provider_options for p. A fallback provider
+// that doesn't allow an extra_body field simply doesn't get it.
+func (r *Router) extraFor(req *provider.Request, p provider.Provider) map[string]json.RawMessage {
+	allowed := r.extraFields[p.Name()]
+	var extra map[string]json.RawMessage
+	set := func(field string, value json.RawMessage) {
+		if !allowed[field] {
+			return
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[field] = value
+	}
+	for field, value := range req.ExtraBody {
+		set(field, value)
+	}
+	for field, value := range req.ProviderOptions[p.Name()] {
+		set(field, value)
+	}
+	return extra
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type extraProvider struct {
+	MockProvider
+	fields []string
+	got    *provider.Request
+}
+
+func (p *extraProvider) ExtraFields() []string { return p.fields }
+
+func (p *extraProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	p.got = req
+	return p.MockProvider.Complete(ctx, req)
+}
+
+func TestValidateExtra(t *testing.T) {
+	openai := &extraProvider{MockProvider: MockProvider{name: "openai"}, fields: []string{"seed"}}
+	claude := &extraProvider{MockProvider: MockProvider{name: "claude"}, fields: []string{"top_k"}}
+	router := NewRouter([]provider.Provider{openai, claude},
+		WithExtraFields(map[string][]string{"openai": {"logit_bias"}}))
+
+	cases := []struct {
+		name    string
+		req     *provider.Request
+		wantErr bool
+	}{
+		{"built-in field", &provider.Request{ExtraBody: map[string]json.RawMessage{"seed": []byte(`1`)}}, false},
+		{"configured field", &provider.Request{ExtraBody: map[string]json.RawMessage{"logit_bias": []byte(`{}`)}}, false},
+		{"other provider's field", &provider.Request{ExtraBody: map[string]json.RawMessage{"top_k": []byte(`5`)}}, true},
+		{"provider option", &provider.Request{ProviderOptions: map[string]map[string]json.RawMessage{
+			"claude": {"top_k": []byte(`5`)},
+		}}, false},
+		{"disallowed provider option", &provider.Request{ProviderOptions: map[string]map[string]json.RawMessage{
+			"claude": {"seed": []byte(`1`)},
+		}}, true},
+		{"unknown provider", &provider.Request{ProviderOptions: map[string]map[string]json.RawMessage{
+			"mistral": {"seed": []byte(`1`)},
+		}}, true},
+	}
+	for _, tc := range cases {
+		err := router.ValidateExtra(tc.req, openai)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestExecute_PassesOnlyAllowedExtraFields(t *testing.T) {
+	openai := &extraProvider{MockProvider: MockProvider{name: "openai"}, fields: []string{"seed"}}
+	claude := &extraProvider{MockProvider: MockProvider{name: "claude"}, fields: []string{"top_k"}}
+	router := NewRouter([]provider.Provider{openai, claude})
+
+	req := &provider.Request{
+		ExtraBody: map[string]json.RawMessage{"seed": []byte(`1`)},
+		ProviderOptions: map[string]map[string]json.RawMessage{
+			"openai": {"seed": []byte(`2`)},
+			"claude": {"top_k": []byte(`5`)},
+		},
+	}
+	if _, err := router.Execute(context.Background(), req, claude); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := claude.got.ExtraBody; len(got) != 1 || string(got["top_k"]) != "5" {
+		t.Errorf("claude got extra %v, want only top_k", got)
+	}
+	if _, err := router.Execute(context.Background(), req, openai); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := openai.got.ExtraBody; len(got) != 1 || string(got["seed"]) != "2" {
+		t.Errorf("openai got extra %v, want provider option seed=2", got)
+	}
+	if len(req.ExtraBody) != 1 || req.ProviderOptions == nil {
+		t.Error("the caller's request must not be modified")
+	}
+}
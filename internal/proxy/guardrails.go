@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// errContentBlocked is returned to clients when a guardrail blocks a request
+// or response.
+const errContentBlocked = "content_blocked"
+
+// WithModeration lets tenants' moderation guardrails classify text with m.
+func WithModeration(m guardrail.Moderator) Option {
+	return func(h *Handler) {
+		h.moderator = m
+	}
+}
+
+// WithViolationWebhooks delivers guardrail events to tenants' security
+// webhooks through n.
+func WithViolationWebhooks(n *guardrail.Notifier) Option {
+	return func(h *Handler) {
+		h.violationHooks = n
+	}
+}
+
+// checkInput compiles the tenant's guardrail rules and runs the input ones
+// over req's messages, redacting them in place. It writes an error and
+// reports false when the rules don't compile or a rule blocks the request;
+// otherwise it returns the pipeline for the response, nil without rules,
+// and the violations to annotate the response with.
+func (h *Handler) checkInput(w http.ResponseWriter, ctx context.Context, settings *tenant.Settings, req *provider.Request) (*guardrail.Pipeline, []guardrail.Violation, bool) {
+	rules, tenantID := settings.Guardrails, req.TenantID
+	if len(rules) == 0 {
+		return nil, nil, true
+	}
+	// Misconfigured rules fail closed: serving the tenant unguarded could
+	// leak exactly what the rules were written to stop.
+	pipeline, err := guardrail.New(rules, h.moderator)
+	if err != nil {
+		logging.FromContext(ctx).Error("guardrail: invalid configuration", "err", err)
+		apierror.Write(w, http.StatusInternalServerError, "", "invalid guardrail configuration")
+		return nil, nil, false
+	}
+	texts := make([]string, len(req.Messages))
+	for i, m := range req.Messages {
+		texts[i] = m.Content
+	}
+	res := pipeline.Check(ctx, guardrail.StageInput, texts)
+	h.recordViolations(ctx, tenantID, res.Violations)
+	h.notifyViolations(settings, tenantID, req.RequestID, guardrail.StageInput, res, texts)
+	if res.Blocked {
+		writeBlocked(w, "request", res.Violations)
+		return nil, nil, false
+	}
+	for i, text := range res.Texts {
+		req.Messages[i].Content = text
+	}
+	return pipeline, res.Violations, true
+}
+
+// checkOutput runs c's output guardrails over resp, redacting its content in
+// place, and reports whether a rule blocked it.
+func (h *Handler) checkOutput(ctx context.Context, c *call, resp *provider.Response) ([]guardrail.Violation, bool) {
+	if c.guardrails == nil || resp.Content == "" {
+		return nil, false
+	}
+	res := c.guardrails.Check(ctx, guardrail.StageOutput, []string{resp.Content})
+	h.recordViolations(ctx, c.tenantID, res.Violations)
+	h.notifyViolations(c.settings, c.tenantID, c.requestID, guardrail.StageOutput, res, []string{resp.Content})
+	if res.Blocked {
+		return res.Violations, true
+	}
+	resp.Content = res.Texts[0]
+	return res.Violations, false
+}
+
+func (h *Handler) recordViolations(ctx context.Context, tenantID string, violations []guardrail.Violation) {
+	for _, v := range violations {
+		h.metrics.recordGuardrailViolation(ctx, tenantID, v)
+	}
+}
+
+// notifyViolations sends the tenant's security webhook an event when res
+// blocked or redacted content.
+func (h *Handler) notifyViolations(settings *tenant.Settings, tenantID, requestID, stage string, res *guardrail.Result, texts []string) {
+	hook := settings.SecurityWebhook
+	if h.violationHooks == nil || hook == nil || hook.URL == "" {
+		return
+	}
+	if ev, ok := guardrail.EventFor(tenantID, requestID, stage, res, texts, hook); ok {
+		h.violationHooks.Notify(hook, ev)
+	}
+}
+
+// writeBlocked returns the structured 400 for content a guardrail blocked.
+// what is "request" or "response".
+func writeBlocked(w http.ResponseWriter, what string, violations []guardrail.Violation) {
+	apierror.WriteError(w, http.StatusBadRequest, apierror.Error{
+		Message: fmt.Sprintf("%s blocked by guardrail %q", what, violations[len(violations)-1].Rule),
+		Code:    errContentBlocked,
+		Details: map[string]any{"violations": violations},
+	})
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// echoProvider answers with the last message it was sent.
+type echoProvider struct {
+	MockProvider
+}
+
+func (p *echoProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	content := req.Messages[len(req.Messages)-1].Content
+	return &provider.Response{Content: content, Provider: p.name, Model: req.Model, InputTokens: 10, OutputTokens: 20}, nil
+}
+
+func completeWithGuardrails(t *testing.T, rules []guardrail.Rule, prompt string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	p := &echoProvider{MockProvider{name: "test-provider", cost: 0.001, supportedModels: []string{"gpt-4"}}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{Guardrails: rules}}))
+
+	payload, _ := json.Marshal(map[string]any{"model": "gpt-4", "messages": []map[string]string{{"role": "user", "content": prompt}}})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(string(payload)))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return w, body
+}
+
+func TestHandleComplete_GuardrailBlocksRequest(t *testing.T) {
+	rules := []guardrail.Rule{{Name: "no-secrets", Type: guardrail.TypeKeywords, Keywords: []string{"password"}, Action: guardrail.ActionBlock, Stages: []string{guardrail.StageInput}}}
+	w, body := completeWithGuardrails(t, rules, "what is the admin PASSWORD?")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if e := decodeAPIError(t, w.Body.Bytes()); e.Error.Code != errContentBlocked {
+		t.Errorf("Expected %q, got %+v", errContentBlocked, e.Error)
+	}
+	violations, _ := body["violations"].([]any)
+	if len(violations) != 1 {
+		t.Fatalf("Expected one violation, got %v", body["violations"])
+	}
+	v := violations[0].(map[string]any)
+	if v["rule"] != "no-secrets" || v["stage"] != guardrail.StageInput || v["message"] != float64(0) {
+		t.Errorf("Expected the rule, stage and message reported, got %v", v)
+	}
+}
+
+func TestHandleComplete_GuardrailRedactsAndAnnotates(t *testing.T) {
+	rules := []guardrail.Rule{
+		{Name: "pii", Type: guardrail.TypePII, PII: []string{guardrail.PIIEmail}, Action: guardrail.ActionRedact},
+		{Name: "competitors", Type: guardrail.TypeKeywords, Keywords: []string{"acme"}, Action: guardrail.ActionAnnotate, Stages: []string{guardrail.StageOutput}},
+	}
+	w, body := completeWithGuardrails(t, rules, "email jane@example.com about Acme")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := messageContent(body); got != "email [REDACTED:EMAIL] about Acme" {
+		t.Errorf("Expected the provider to only see the redacted prompt, got %q", got)
+	}
+	g, _ := body["guardrails"].(map[string]any)
+	violations, _ := g["violations"].([]any)
+	if len(violations) != 2 {
+		t.Fatalf("Expected the redaction and the annotation reported, got %v", body["guardrails"])
+	}
+	if v := violations[1].(map[string]any); v["rule"] != "competitors" || v["stage"] != guardrail.StageOutput {
+		t.Errorf("Expected the output annotation, got %v", v)
+	}
+}
+
+func TestHandleComplete_GuardrailBlocksResponse(t *testing.T) {
+	rules := []guardrail.Rule{{Name: "ssn", Type: guardrail.TypePII, PII: []string{guardrail.PIISSN}, Action: guardrail.ActionBlock, Stages: []string{guardrail.StageOutput}}}
+	w, _ := completeWithGuardrails(t, rules, "repeat after me: 123-45-6789")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "6789") {
+		t.Errorf("Expected the blocked content withheld, got %s", w.Body.String())
+	}
+	if e := decodeAPIError(t, w.Body.Bytes()); !strings.Contains(e.Error.Message, "response blocked") {
+		t.Errorf("Expected the response to be named as blocked, got %+v", e.Error)
+	}
+}
+
+func TestHandleComplete_InvalidGuardrailsFailClosed(t *testing.T) {
+	rules := []guardrail.Rule{{Name: "broken", Type: guardrail.TypeRegex, Pattern: "(", Action: guardrail.ActionBlock}}
+	if w, _ := completeWithGuardrails(t, rules, "hello"); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", w.Code)
+	}
+}
+
+func TestHandleComplete_GuardrailNotifiesSecurityWebhook(t *testing.T) {
+	events := make(chan guardrail.Event, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev guardrail.Event
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	defer hook.Close()
+
+	p := &echoProvider{MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}}
+	settings := &tenant.Settings{
+		Guardrails:      []guardrail.Rule{{Name: "no-secrets", Type: guardrail.TypeKeywords, Keywords: []string{"password"}, Action: guardrail.ActionBlock, Severity: guardrail.SeverityCritical}},
+		SecurityWebhook: &guardrail.Webhook{URL: hook.URL},
+	}
+	sender := webhook.NewSender(webhook.NewGuard([]string{"127.0.0.1"}))
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: settings}), WithViolationWebhooks(guardrail.NewNotifier(sender)))
+
+	payload, _ := json.Marshal(map[string]any{"model": "gpt-4", "messages": []map[string]string{{"role": "user", "content": "the password is hunter2"}}})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(string(payload)))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	h.HandleComplete(httptest.NewRecorder(), req)
+
+	select {
+	case ev := <-events:
+		if ev.TenantID != "test-tenant" || ev.RequestID == "" || ev.Outcome != guardrail.OutcomeBlocked || ev.Severity != guardrail.SeverityCritical {
+			t.Errorf("Unexpected event: %+v", ev)
+		}
+		if len(ev.Violations) != 1 || ev.Violations[0].Rule != "no-secrets" {
+			t.Errorf("Expected the matched rule, got %+v", ev.Violations)
+		}
+		if ev.Content != nil {
+			t.Errorf("Expected no content without include_content, got %v", ev.Content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the security webhook to be notified")
+	}
+}
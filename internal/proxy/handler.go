@@ -3,63 +3,330 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sony/gobreaker"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/archive"
+	"github.com/vnmchuo/llm-gateway/internal/audit"
 	"github.com/vnmchuo/llm-gateway/internal/auth"
 	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/cache"
+	"github.com/vnmchuo/llm-gateway/internal/conversation"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/hooks"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/postprocess"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
 	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/retrieval"
+	"github.com/vnmchuo/llm-gateway/internal/shadow"
+	"github.com/vnmchuo/llm-gateway/internal/telemetry"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/tools"
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
 	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type Handler struct {
-	router  *Router
-	billing billing.Store
-	limiter *ratelimit.Limiter
-	tracer  trace.Tracer
+	router    *Router
+	billing   billing.Store
+	usage     *billing.Recorder
+	limiter   *ratelimit.Limiter
+	tracer    trace.Tracer
+	meter     metric.Meter
+	metrics   *metrics
+	tools     *tools.Runner
+	retrieval *retrieval.Stage
+	tenants   tenant.Store
+	maxTurns  int
+	limits    RequestLimits
+	policies  policy.Store
+	prompts   prompts.Store
+	cache     cache.Cache
+	jobs      worker.Queue
+	jobStore  worker.Store
+	jobBlobs  worker.BlobStore
+	jobURLTTL time.Duration
+	jobEvents worker.Events
+	webhooks  *webhook.Guard
+	payloads  *audit.PayloadLogger
+	archive   TranscriptArchive
+	hooks     hooks.Chain
+	keys      auth.Store
+	shadow    *shadow.Mirror
+	moderator guardrail.Moderator
+	// violationHooks notifies tenants' security webhooks of guardrail events.
+	violationHooks *guardrail.Notifier
+	ttft           *ttftMonitor
+
+	usageTrailers       bool
+	spend               billing.SpendCounter
+	streamLimits        StreamLimits
+	streamSamplePercent float64
+	routing             *policy.RoutingResolver
+	broadcasts          Broadcasts
+	// identities sets jobs' tenant baggage; HTTP requests get theirs from
+	// telemetry's middleware.
+	identities *telemetry.Identities
+
+	batchMaxItems    int
+	batchConcurrency int
+
+	// inflight coalesces identical concurrent completions (see executeCoalesced).
+	inflight singleflight.Group
+}
+
+// HTTP trailers carrying final stream usage (see WithUsageTrailers).
+const (
+	trailerInputTokens  = "X-Usage-Input-Tokens"
+	trailerOutputTokens = "X-Usage-Output-Tokens"
+	trailerCostUSD      = "X-Usage-Cost-USD"
+)
+
+// call carries everything prepare resolved for a single completion request.
+type call struct {
+	tenantID       string
+	requestID      string
+	conversationID string // from X-Conversation-ID; empty when untagged
+	req            *provider.Request
+	provider       provider.Provider
+	settings       *tenant.Settings
+	limit          ratelimit.Subject
+	charged        int          // tokens taken from the rate limit up front
+	log            *slog.Logger // the request's logger, with the routed provider and model
+
+	guardrails *guardrail.Pipeline   // nil when the tenant has no rules
+	violations []guardrail.Violation // non-blocking input violations
+	warnings   limitWarnings         // soft-limit headers for a successful response
+}
+
+// Option configures optional Handler features.
+type Option func(*Handler)
+
+// WithToolRunner enables the managed tool-execution loop for non-streaming completions.
+func WithToolRunner(runner *tools.Runner) Option {
+	return func(h *Handler) {
+		h.tools = runner
+	}
+}
+
+// WithRetrieval enables the RAG stage that augments prompts before routing.
+func WithRetrieval(stage *retrieval.Stage) Option {
+	return func(h *Handler) {
+		h.retrieval = stage
+	}
+}
+
+// WithTenantSettings enables per-tenant features such as output post-processing.
+func WithTenantSettings(store tenant.Store) Option {
+	return func(h *Handler) {
+		h.tenants = store
+	}
+}
+
+// WithMaxTurns caps the number of messages per request; tenants may override it.
+func WithMaxTurns(n int) Option {
+	return func(h *Handler) {
+		h.maxTurns = n
+	}
+}
+
+// WithModelPolicies enforces per-tenant model allow/deny lists.
+func WithModelPolicies(store policy.Store) Option {
+	return func(h *Handler) {
+		h.policies = store
+	}
+}
+
+// WithResponseCache serves repeated identical prompts from cache at zero cost.
+func WithResponseCache(c cache.Cache) Option {
+	return func(h *Handler) {
+		h.cache = c
+	}
+}
+
+// WithUsageRecorder replaces the default usage recorder, e.g. so the caller
+// can flush it on shutdown.
+func WithUsageRecorder(rec *billing.Recorder) Option {
+	return func(h *Handler) {
+		h.usage = rec
+	}
 }
 
-func NewHandler(router *Router, billing billing.Store, limiter *ratelimit.Limiter, tracer trace.Tracer) *Handler {
-	return &Handler{
+// WithUsageTrailers sends final stream usage and cost as HTTP trailers, so
+// metering proxies can read totals without parsing the event stream.
+func WithUsageTrailers() Option {
+	return func(h *Handler) {
+		h.usageTrailers = true
+	}
+}
+
+// WithTraceIdentities puts async jobs' tenant, plan and API key into their
+// trace baggage, as the middleware does for HTTP requests.
+func WithTraceIdentities(ids *telemetry.Identities) Option {
+	return func(h *Handler) {
+		h.identities = ids
+	}
+}
+
+func NewHandler(router *Router, billingStore billing.Store, limiter *ratelimit.Limiter, tracer trace.Tracer, opts ...Option) *Handler {
+	h := &Handler{
 		router:  router,
-		billing: billing,
+		billing: billingStore,
 		limiter: limiter,
 		tracer:  tracer,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.usage == nil {
+		h.usage = billing.NewRecorder(billingStore, 0, 0)
+	}
+	if h.meter == nil {
+		h.meter = otel.Meter("github.com/vnmchuo/llm-gateway/internal/proxy")
+	}
+	h.metrics = newMetrics(h.meter, router, h.ttft)
+	return h
 }
 
 func (h *Handler) HandleComplete(w http.ResponseWriter, r *http.Request) {
-	tenantID, requestID, req, selectedProvider, err := h.prepare(w, r)
-	if err != nil {
+	if dryRun(r) {
+		h.HandleEstimate(w, r)
 		return
 	}
-
-	response, err := h.router.Execute(r.Context(), req, selectedProvider)
+	start := time.Now()
+	c, err := h.prepare(w, r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
-	// Step 9: Log usage asynchronously
-	go func() {
-		_ = h.billing.LogUsage(context.Background(), &billing.UsageLog{
-			TenantID:     tenantID,
-			RequestID:    requestID,
-			Provider:     response.Provider,
-			Model:        response.Model,
-			InputTokens:  response.InputTokens,
-			OutputTokens: response.OutputTokens,
-			CostUSD:      float64(response.InputTokens)*selectedProvider.CostPerInputToken() + float64(response.OutputTokens)*selectedProvider.CostPerOutputToken(),
-			LatencyMs:    response.LatencyMs,
+	var lang *languageOutcome
+	var costUSD float64 // cache hits and coalesced followers aren't billed
+	cacheStatus := cacheMiss
+	response, cached := h.cachedResponse(r, c)
+	if cached {
+		cacheStatus = cacheHit
+		h.usage.Record(r.Context(), &billing.UsageLog{
+			TenantID:        c.tenantID,
+			APIKeyID:        c.req.APIKeyID,
+			RequestID:       c.requestID,
+			Provider:        response.Provider,
+			Model:           response.Model,
+			InputTokens:     response.InputTokens,
+			OutputTokens:    response.OutputTokens,
+			Cached:          true,
+			RetrievedDocIDs: c.req.RetrievedDocIDs,
+			Synthetic:       c.req.Synthetic,
 		})
-	}()
+	} else {
+		done, follower, err := h.executeCoalesced(r.Context(), c)
+		if err != nil {
+			h.metrics.recordRequest(r.Context(), c.tenantID, c.provider.Name(), c.req.Model, statusFor(err), time.Since(start))
+			h.auditExchange(c, c.provider.Name(), c.req.Model, nil, err)
+			h.reconcileTokens(r.Context(), c, 0)
+			logCompletion(r.Context(), accessLine{status: statusFor(err), provider: c.provider.Name(), model: c.req.Model, cache: cacheStatus, start: start})
+			writeUpstreamError(w, err)
+			return
+		}
+		response, lang = done.resp, done.language
+		if follower {
+			cacheStatus = cacheCoalesced
+		} else {
+			costUSD = h.router.cost(done.served, h.router.ModelFor(c.req, done.served), response.InputTokens, response.OutputTokens)
+		}
+
+		// Step 9: Usage was logged with the upstream call. Coalesced
+		// followers cost nothing upstream and are logged like cache hits.
+		if follower {
+			h.usage.Record(r.Context(), &billing.UsageLog{
+				TenantID:        c.tenantID,
+				APIKeyID:        c.req.APIKeyID,
+				RequestID:       c.requestID,
+				Provider:        response.Provider,
+				Model:           response.Model,
+				InputTokens:     response.InputTokens,
+				OutputTokens:    response.OutputTokens,
+				Cached:          true,
+				RetrievedDocIDs: c.req.RetrievedDocIDs,
+				Synthetic:       c.req.Synthetic,
+			})
+		} else {
+			h.storeResponse(r, c, response)
+			h.mirror(c, shadow.Result{
+				Provider:     done.served.Name(),
+				Model:        response.Model,
+				Content:      response.Content,
+				InputTokens:  response.InputTokens,
+				OutputTokens: response.OutputTokens,
+				CostUSD:      costUSD,
+				LatencyMs:    time.Since(start).Milliseconds(),
+			})
+		}
+	}
+
+	used := response.InputTokens + response.OutputTokens
+	if lang != nil {
+		used += lang.InputTokens + lang.OutputTokens
+	}
+
+	// The response may be shared with coalesced followers and the cache, so
+	// guardrails redact a copy.
+	checked := *response
+	response = &checked
+	logged := accessLine{
+		provider:     response.Provider,
+		model:        response.Model,
+		inputTokens:  response.InputTokens,
+		outputTokens: response.OutputTokens,
+		costUSD:      costUSD,
+		cache:        cacheStatus,
+		start:        start,
+	}
+	violations, blocked := h.checkOutput(r.Context(), c, response)
+	if blocked {
+		logged.status = http.StatusBadRequest
+		logCompletion(r.Context(), logged)
+		h.metrics.recordRequest(r.Context(), c.tenantID, response.Provider, response.Model, http.StatusBadRequest, time.Since(start))
+		h.reconcileTokens(r.Context(), c, used)
+		h.auditExchange(c, response.Provider, response.Model, nil, fmt.Errorf("response blocked by guardrail"))
+		writeBlocked(w, "response", violations)
+		return
+	}
+	violations = append(c.violations, violations...)
+
+	if proc := postprocess.New(c.settings); proc != nil {
+		response.Content = proc.Process(response.Content)
+	}
+	if err := h.hooks.OnResponse(r.Context(), c.req, response); err != nil {
+		c.log.Error("hooks: response hook failed", "err", err)
+		logged.status = http.StatusInternalServerError
+		logCompletion(r.Context(), logged)
+		h.metrics.recordRequest(r.Context(), c.tenantID, response.Provider, response.Model, http.StatusInternalServerError, time.Since(start))
+		h.reconcileTokens(r.Context(), c, used)
+		h.auditExchange(c, response.Provider, response.Model, nil, err)
+		apierror.Write(w, http.StatusInternalServerError, "", "response hook failed")
+		return
+	}
+
+	h.metrics.recordRequest(r.Context(), c.tenantID, response.Provider, response.Model, http.StatusOK, time.Since(start))
+	h.reconcileTokens(r.Context(), c, used)
+	logged.status = http.StatusOK
+	logCompletion(r.Context(), logged)
 
 	// Step 10: Return 200 with OpenAI-compatible JSON
 	respID := response.ID
@@ -67,92 +334,550 @@ func (h *Handler) HandleComplete(w http.ResponseWriter, r *http.Request) {
 		respID = uuid.New().String()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": response.Content,
+	}
+	if len(response.ToolCalls) > 0 {
+		message["tool_calls"] = response.ToolCalls
+	}
+	choice := map[string]interface{}{
+		"index":         0,
+		"message":       message,
+		"finish_reason": response.FinishReason,
+	}
+	if response.FinishReason == "" {
+		// Cached before finish reasons were recorded.
+		choice["finish_reason"] = provider.NormalizeFinishReason("", len(response.ToolCalls) > 0)
+	}
+	if response.RawFinishReason != "" {
+		choice["provider_metadata"] = map[string]string{"finish_reason": response.RawFinishReason}
+	}
+
+	body := map[string]interface{}{
 		"id":       respID,
 		"object":   "chat.completion",
 		"model":    response.Model,
 		"provider": response.Provider,
-		"choices": []interface{}{
-			map[string]interface{}{
-				"index": 0,
-				"message": map[string]string{
-					"role":    "assistant",
-					"content": response.Content,
-				},
-				"finish_reason": "stop",
-			},
-		},
+		"cached":   cached,
+		"choices":  []interface{}{choice},
 		"usage": map[string]int{
 			"prompt_tokens":     response.InputTokens,
 			"completion_tokens": response.OutputTokens,
 			"total_tokens":      response.InputTokens + response.OutputTokens,
 		},
+	}
+	if lang != nil {
+		body["output_language"] = lang
+	}
+	if len(violations) > 0 {
+		body["guardrails"] = map[string]any{"violations": violations}
+	}
+	h.auditExchange(c, response.Provider, response.Model, body, nil)
+	h.archiveTranscript(c, &archive.Transcript{
+		Provider:     response.Provider,
+		Model:        response.Model,
+		Content:      response.Content,
+		ToolCalls:    response.ToolCalls,
+		FinishReason: response.FinishReason,
+		InputTokens:  response.InputTokens,
+		OutputTokens: response.OutputTokens,
+	})
+
+	c.warnings.apply(w)
+	h.setQuotaHeaders(r.Context(), w, c)
+	w.Header().Set(headerRequestCost, strconv.FormatFloat(costUSD, 'f', -1, 64))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// statusClientClosedRequest is recorded for streams the client abandoned
+// (nginx's 499; nothing is sent since the client is gone).
+const statusClientClosedRequest = 499
+
+var errClientDisconnected = errors.New("client disconnected")
+
+// statusFor maps routing and upstream errors to the status returned to clients.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrNoProviders), errors.Is(err, ErrNoEmbeddingProviders),
+		errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrModelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, provider.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, provider.ErrContentFiltered), errors.Is(err, provider.ErrBadRequest),
+		errors.As(err, new(*CostCapError)):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// codeFor is the error code sent with statusFor's status.
+func codeFor(err error) string {
+	switch {
+	case errors.Is(err, ErrNoProviders), errors.Is(err, ErrNoEmbeddingProviders),
+		errors.Is(err, gobreaker.ErrOpenState), errors.Is(err, gobreaker.ErrTooManyRequests):
+		return "provider_unavailable"
+	case errors.Is(err, ErrModelNotFound):
+		return "model_not_found"
+	case errors.Is(err, provider.ErrRateLimited):
+		return apierror.CodeRateLimitExceeded
+	case errors.Is(err, provider.ErrContentFiltered):
+		return "content_filter"
+	case errors.Is(err, provider.ErrContextLength):
+		return "context_length_exceeded"
+	case errors.Is(err, provider.ErrBadRequest):
+		return "upstream_bad_request"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errInvalidJSONOutput):
+		return "invalid_json_output"
+	case errors.As(err, new(*CostCapError)):
+		return "cost_cap_exceeded"
+	default:
+		return "upstream_error"
+	}
+}
+
+// upstreamError describes a routing or upstream error for the client. When
+// an upstream rejected the request with an OpenAI-style error naming the
+// offending parameter, its code and param are passed on.
+func upstreamError(err error) apierror.Error {
+	e := apierror.Error{Message: err.Error(), Code: codeFor(err)}
+	var capErr *CostCapError
+	if errors.As(err, &capErr) {
+		e.Param = "max_cost"
+		e.Details = map[string]any{"max_cost_usd": capErr.MaxUSD, "estimated_cost_usd": capErr.CheapestUSD}
+		return e
+	}
+	var apiErr *provider.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, provider.ErrBadRequest) {
+		return e
+	}
+	var body struct {
+		Error struct {
+			Code  string `json:"code"`
+			Param string `json:"param"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(apiErr.Body), &body) == nil {
+		if body.Error.Code != "" {
+			e.Code = body.Error.Code
+		}
+		e.Param = body.Error.Param
+	}
+	return e
+}
+
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	apierror.WriteError(w, statusFor(err), upstreamError(err))
+}
+
+// cachePolicy resolves whether c may read and write the response cache and
+// for how long (0 = RESPONSE_CACHE_TTL). The request's cache options win
+// over the tenant's defaults; Cache-Control: no-cache / no-store still opt out.
+func cachePolicy(r *http.Request, c *call) (read, write bool, ttl time.Duration) {
+	mode, seconds := c.settings.CacheMode, c.settings.CacheTTLSeconds
+	if o := c.req.Cache; o != nil {
+		if o.Mode != "" {
+			mode = o.Mode
+		}
+		if o.TTL > 0 {
+			seconds = o.TTL
+		}
+	}
+	d := cache.ParseDirectives(r.Header)
+	read = mode != provider.CacheOff && !d.NoCache
+	write = mode != provider.CacheOff && mode != provider.CacheReadOnly && !d.NoStore
+	return read, write, time.Duration(seconds) * time.Second
+}
+
+// cachedResponse looks the request up in the response cache when its cache
+// policy allows reads. Cache errors count as misses.
+func (h *Handler) cachedResponse(r *http.Request, c *call) (*provider.Response, bool) {
+	if read, _, _ := cachePolicy(r, c); h.cache == nil || !read {
+		return nil, false
+	}
+	resp, ok, err := h.cache.Get(r.Context(), cache.Key(c.tenantID, c.req))
+	if err != nil {
+		c.log.Warn("cache: lookup failed", "err", err)
+		return nil, false
+	}
+	return resp, ok
+}
+
+// storeResponse caches a fresh response when its cache policy allows
+// writes. Responses that hand tool calls back are not cached.
+func (h *Handler) storeResponse(r *http.Request, c *call, resp *provider.Response) {
+	_, write, ttl := cachePolicy(r, c)
+	if h.cache == nil || !write || len(resp.ToolCalls) > 0 {
+		return
+	}
+	if err := h.cache.Set(r.Context(), cache.Key(c.tenantID, c.req), resp, ttl); err != nil {
+		c.log.Warn("cache: store failed", "err", err)
+	}
+}
+
+// execute runs a non-streaming completion, through the tool loop when enabled
+// for the managed tools the tenant allows, and returns the provider that
+// served the final round.
+func (h *Handler) execute(ctx context.Context, req *provider.Request, p provider.Provider, managedTools []string) (*provider.Response, provider.Provider, error) {
+	if h.tools == nil || len(managedTools) == 0 {
+		return h.router.ExecuteWithFallback(ctx, req, p)
+	}
+	served := p
+	resp, err := h.tools.Run(ctx, req, managedTools, func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		resp, sp, err := h.router.ExecuteWithFallback(ctx, req, p)
+		if err == nil {
+			served = sp
+		}
+		return resp, err
+	})
+	if err != nil {
+		if resp != nil {
+			// Bill the rounds that ran before the loop failed.
+			h.logUsage(ctx, req, served, resp, billing.FinishReasonToolLoopFailed)
+		}
+		return nil, nil, err
+	}
+	return resp, served, nil
+}
+
+// logUsage bills response; finishReason marks responses that didn't run to
+// completion and is empty otherwise.
+func (h *Handler) logUsage(ctx context.Context, req *provider.Request, p provider.Provider, response *provider.Response, finishReason string) {
+	// Price the model that was routed to: upstreams report dated snapshots
+	// such as gpt-4o-2024-08-06.
+	costUSD := h.router.cost(p, h.router.ModelFor(req, p), response.InputTokens, response.OutputTokens)
+	h.metrics.recordUsage(ctx, req.TenantID, p.Name(), response.Model, response.InputTokens, response.OutputTokens, costUSD)
+	h.usage.Record(ctx, &billing.UsageLog{
+		TenantID:        req.TenantID,
+		APIKeyID:        req.APIKeyID,
+		RequestID:       req.RequestID,
+		Provider:        p.Name(),
+		Model:           response.Model,
+		InputTokens:     response.InputTokens,
+		OutputTokens:    response.OutputTokens,
+		CostUSD:         costUSD,
+		LatencyMs:       response.LatencyMs,
+		RetrievedDocIDs: req.RetrievedDocIDs,
+		FinishReason:    finishReason,
+		Synthetic:       req.Synthetic,
 	})
 }
 
 func (h *Handler) HandleCompleteStream(w http.ResponseWriter, r *http.Request) {
-	tenantID, requestID, req, selectedProvider, err := h.prepare(w, r)
+	if dryRun(r) {
+		h.HandleEstimate(w, r)
+		return
+	}
+	c, err := h.prepare(w, r)
 	if err != nil {
 		return
 	}
 
-	ch, err := h.router.ExecuteStream(r.Context(), req, selectedProvider)
+	// Cancel upstream as soon as the client loop exits, whatever the reason,
+	// so provider and router goroutines don't outlive the request.
+	streamCtx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	start := time.Now()
+	check := newStreamCheck(c)
+	upstream, served, err := fitContext(r.Context(), c.req, c.settings.ContextOverflow, func(req *provider.Request) (*Stream, provider.Provider, error) {
+		// Restarts and billing use the request the stream opened with.
+		c.req = req
+		return h.router.ExecuteStreamWithFallback(check.attempt(streamCtx), req, c.provider)
+	})
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadGateway)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		h.metrics.recordRequest(r.Context(), c.tenantID, c.provider.Name(), c.req.Model, statusFor(err), time.Since(start))
+		h.reconcileTokens(r.Context(), c, 0)
+		logCompletion(r.Context(), accessLine{status: statusFor(err), provider: c.provider.Name(), model: c.req.Model, stream: true, start: start})
+		writeUpstreamError(w, err)
 		return
 	}
 
+	c.warnings.apply(w)
+	h.setQuotaHeaders(r.Context(), w, c)
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
+	if h.usageTrailers {
+		w.Header().Set("Trailer", strings.Join([]string{trailerInputTokens, trailerOutputTokens, trailerCostUSD}, ", "))
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		apierror.Write(w, http.StatusInternalServerError, "", "streaming unsupported")
 		return
 	}
 
-	for chunk := range ch {
+	chunks := newChunkWriter(w, flusher, c.requestID, h.router.ModelFor(c.req, served))
+	if chunks.broadcast = h.newBroadcaster(r, c); chunks.broadcast != nil {
+		defer chunks.broadcast.close()
+	}
+	writeDelta := func(delta string) {
+		if delta == "" {
+			return
+		}
+		if delta = h.hooks.OnChunk(r.Context(), c.req, delta); delta == "" {
+			return
+		}
+		chunks.delta(chunkDelta{Content: delta})
+		chunks.flush()
+	}
+
+	// Tool calls are relayed whole, one delta per call, indexed across the
+	// stream as OpenAI clients expect.
+	var toolIndex int
+	writeToolCalls := func(calls []provider.ToolCall) {
+		for _, tc := range calls {
+			chunks.delta(chunkDelta{ToolCalls: []chunkToolCall{{
+				Index:    toolIndex,
+				ID:       tc.ID,
+				Type:     "function",
+				Function: tc.Function,
+			}}})
+			toolIndex++
+		}
+		chunks.flush()
+	}
+
+	var content strings.Builder
+	var last *provider.Chunk // the Done chunk, with the finish reason
+	var done bool
+	var streamErr error
+	var toolCalls []provider.ToolCall
+	var firstToken bool
+	status := http.StatusOK
+
+	var post *postprocess.Stream
+	if proc := postprocess.New(c.settings); proc != nil {
+		post = proc.NewStream()
+	}
+	attemptStart := start
+
+	var deadline <-chan time.Time
+	if d := h.maxStreamDuration(c); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	checkpoints := &streamCheckpoints{h: h, c: c, start: start}
+	var checkpoint <-chan time.Time
+	if h.streamLimits.CheckpointInterval > 0 {
+		ticker := time.NewTicker(h.streamLimits.CheckpointInterval)
+		defer ticker.Stop()
+		checkpoint = ticker.C
+	}
+	var capped bool
+	sample := h.sampleStream()
+	defer sample.finish(r.Context())
+
+stream:
+	for {
+		var chunk *provider.Chunk
+		select {
+		case next, ok := <-upstream.C:
+			if !ok {
+				break stream
+			}
+			chunk = next
+		case <-r.Context().Done():
+			// Stop the upstream now rather than whenever it next notices.
+			cancel()
+			break stream
+		case <-deadline:
+			cancel()
+			capped = true
+			break stream
+		case <-checkpoint:
+			checkpoints.checkpoint(r.Context(), served, content.String())
+			continue
+		}
+
+		if chunk.Err == nil && !chunk.Done {
+			delta, derailed := check.check(chunk.Delta)
+			if derailed != nil {
+				// Stop generating output that can't be used.
+				check.stop()
+				if check.canRestart(toolIndex > 0) {
+					next, nextServed, err := h.restartStream(streamCtx, c, check, served, content.String()+chunk.Delta, attemptStart)
+					if err == nil {
+						upstream, served, attemptStart = next, nextServed, time.Now()
+						chunks.model = h.router.ModelFor(c.req, served)
+						content.Reset()
+						sample.restart()
+						continue
+					}
+					derailed = err
+				} else {
+					h.metrics.recordStreamDerailed(r.Context(), c.tenantID, derailActionFailed)
+					content.WriteString(delta)
+					if post != nil {
+						delta = post.Write(delta)
+					}
+					writeDelta(delta)
+				}
+				chunk = &provider.Chunk{Err: derailed}
+			}
+		}
+
 		if chunk.Err != nil {
-			fmt.Fprintf(w, "event: error\ndata: {\"error\": \"%s\"}\n\n", chunk.Err.Error())
-			flusher.Flush()
-			break
+			streamErr = chunk.Err
+			status = statusFor(chunk.Err)
+			if post != nil {
+				writeDelta(post.Flush())
+			}
+			chunks.fail(apierror.Marshal(status, upstreamError(chunk.Err)))
+			chunks.flush()
+			break stream
 		}
 
 		if chunk.Done {
-			fmt.Fprintf(w, "data: [DONE]\n\n")
-			flusher.Flush()
-			break
+			last = chunk
+			done = true
+			if post != nil {
+				writeDelta(post.Flush())
+			}
+			break stream
+		}
+
+		if !firstToken && (chunk.Delta != "" || len(chunk.ToolCalls) > 0) {
+			firstToken = true
+			h.observeTTFT(r.Context(), served.Name(), h.router.ModelFor(c.req, served), time.Since(start))
 		}
+		if chunk.Delta != "" || len(chunk.ToolCalls) > 0 {
+			sample.chunk(r.Context(), served.Name(), h.router.ModelFor(c.req, served), len(chunk.Delta), time.Now())
+		}
+		if len(chunk.ToolCalls) > 0 {
+			writeToolCalls(chunk.ToolCalls)
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+		}
+		content.WriteString(chunk.Delta)
+		if post != nil {
+			writeDelta(post.Write(chunk.Delta))
+			continue
+		}
+		writeDelta(chunk.Delta)
+	}
 
-		escaped := strings.ReplaceAll(chunk.Delta, `"`, `\"`)
-		escaped = strings.ReplaceAll(escaped, "\n", `\n`)
-		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"},\"index\":0}]}\n\n", escaped)
-		flusher.Flush()
+	// The client went away before the stream finished: bill what was
+	// generated up to that point.
+	finishReason := ""
+	if capped {
+		// Ended like a response cut off by max_tokens, so clients finish it
+		// normally.
+		done = true
+		last = &provider.Chunk{Done: true, FinishReason: provider.FinishLength, RawFinishReason: rawFinishMaxDuration}
+		finishReason = billing.FinishReasonMaxDuration
+		if post != nil {
+			writeDelta(post.Flush())
+		}
+	} else if !done && r.Context().Err() != nil {
+		finishReason = billing.FinishReasonClientDisconnect
+		streamErr = errClientDisconnected
+		status = statusClientClosedRequest
 	}
 
-	go func() {
-		_ = h.billing.LogUsage(context.Background(), &billing.UsageLog{
-			TenantID:  tenantID,
-			RequestID: requestID,
-			Provider:  selectedProvider.Name(),
-			Model:     req.Model,
+	// Bill what the upstream sent, which the router tallied whether or not
+	// the client read it. If the upstream reported no usage (older API,
+	// aborted stream), estimate from the prompt and its deltas.
+	tally := upstream.Tally(streamTallyWait)
+	usage := tally.Usage
+	if usage == nil {
+		usage = &provider.Usage{OutputTokens: tally.OutputTokens()}
+		for _, m := range c.req.Messages {
+			usage.InputTokens += provider.EstimateTokens(m.Content)
+		}
+	}
+
+	model := h.router.ModelFor(c.req, served)
+	costUSD := h.router.cost(served, model, usage.InputTokens, usage.OutputTokens)
+	h.traceStream(r.Context(), served.Name(), model, tally, finishReason)
+	h.reconcileTokens(r.Context(), c, usage.InputTokens+usage.OutputTokens+check.extraTokens())
+
+	if done {
+		// Output guardrails see the stream only once it has been relayed, so
+		// they can annotate it but not block or redact it.
+		violations, _ := h.checkOutput(r.Context(), c, &provider.Response{Content: content.String()})
+		if violations = append(c.violations, violations...); len(violations) > 0 {
+			chunks.write(streamChunk{Guardrails: &chunkGuardrails{Violations: violations}})
+		}
+		chunks.finish(last.FinishReason, last.RawFinishReason)
+		chunks.usage(usage, costUSD)
+		chunks.done()
+		chunks.flush()
+	}
+	if h.usageTrailers {
+		w.Header().Set(trailerInputTokens, strconv.Itoa(usage.InputTokens))
+		w.Header().Set(trailerOutputTokens, strconv.Itoa(usage.OutputTokens))
+		w.Header().Set(trailerCostUSD, strconv.FormatFloat(costUSD, 'f', -1, 64))
+	}
+
+	h.metrics.recordRequest(r.Context(), c.tenantID, served.Name(), model, status, time.Since(start))
+	logCompletion(r.Context(), accessLine{
+		status:       status,
+		provider:     served.Name(),
+		model:        model,
+		inputTokens:  usage.InputTokens,
+		outputTokens: usage.OutputTokens,
+		costUSD:      costUSD,
+		stream:       true,
+		start:        start,
+	})
+	h.auditExchange(c, served.Name(), model, map[string]any{
+		"content":    content.String(),
+		"tool_calls": toolCalls,
+		"usage": map[string]int{
+			"prompt_tokens":     usage.InputTokens,
+			"completion_tokens": usage.OutputTokens,
+			"total_tokens":      usage.InputTokens + usage.OutputTokens,
+		},
+	}, streamErr)
+	if done && streamErr == nil {
+		h.archiveTranscript(c, &archive.Transcript{
+			Provider:     served.Name(),
+			Model:        model,
+			Stream:       true,
+			Content:      content.String(),
+			ToolCalls:    toolCalls,
+			FinishReason: last.FinishReason,
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
 		})
-	}()
+	}
+	unbilled := checkpoints.remainder(usage)
+	h.logUsage(r.Context(), c.req, served, &provider.Response{
+		Model:        model,
+		InputTokens:  unbilled.InputTokens,
+		OutputTokens: unbilled.OutputTokens,
+		LatencyMs:    time.Since(start).Milliseconds(),
+	}, finishReason)
+	if done {
+		h.mirror(c, shadow.Result{
+			Provider:     served.Name(),
+			Model:        model,
+			Content:      content.String(),
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			CostUSD:      costUSD,
+			LatencyMs:    time.Since(start).Milliseconds(),
+		})
+	}
 }
 
-func (h *Handler) prepare(w http.ResponseWriter, r *http.Request) (string, string, *provider.Request, provider.Provider, error) {
+func (h *Handler) prepare(w http.ResponseWriter, r *http.Request) (*call, error) {
 	ctx := r.Context()
 	tenantID := auth.GetTenantID(ctx)
 	if tenantID == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
-		return "", "", nil, nil, fmt.Errorf("unauthorized")
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return nil, fmt.Errorf("unauthorized")
 	}
 
 	requestID := auth.GetRequestID(ctx)
@@ -160,114 +885,448 @@ func (h *Handler) prepare(w http.ResponseWriter, r *http.Request) (string, strin
 		requestID = uuid.New().String()
 	}
 
-	var req provider.Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
-		return "", "", nil, nil, err
+	conversationID, err := conversationIDFrom(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, err
+	}
+	synthetic, err := syntheticTraffic(r)
+	if err != nil {
+		writeSyntheticError(w, err)
+		return nil, err
+	}
+	priority, err := requestPriority(r)
+	if err != nil {
+		writePriorityError(w, err)
+		return nil, err
+	}
+
+	req, systemRef, err := h.decodeRequest(w, r, tenantID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	req.Synthetic, req.Priority = synthetic, priority
+
+	settings := &tenant.Settings{}
+	if h.tenants != nil {
+		s, err := h.tenants.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load tenant settings")
+			return nil, err
+		}
+		settings = s
+	}
+	warnings := limitWarnings{}
+	budgetWarn, ok := h.enforceBudget(w, ctx, tenantID, settings)
+	if !ok {
+		return nil, fmt.Errorf("budget exceeded")
+	}
+	if budgetWarn != "" {
+		warnings[headerBudgetWarning] = budgetWarn
+	}
+	guardrails, violations, ok := h.checkInput(w, ctx, settings, req)
+	if !ok {
+		return nil, fmt.Errorf("guardrails rejected request")
 	}
 
 	_, span := h.tracer.Start(ctx, "proxy.complete")
 	defer span.End()
+	// tenant_id comes from the request's baggage, as the trace records it.
 	span.SetAttributes(
-		attribute.String("tenant_id", tenantID),
 		attribute.String("request_id", requestID),
 		attribute.String("model", req.Model),
 	)
-
-	estimatedTokens := req.MaxTokens
-	if estimatedTokens <= 0 {
-		estimatedTokens = 1000
+	if systemRef != "" {
+		span.SetAttributes(attribute.String("system_ref", systemRef))
 	}
 
-	allowed, err := h.limiter.Allow(ctx, tenantID, estimatedTokens)
+	limit := rateLimitSubject(ctx, tenantID, priority)
+	charged := rateLimitTokens(req)
+	headroom, allowed, err := h.limiter.Admit(ctx, limit, charged)
 	if err != nil || !allowed {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Retry-After", "60s")
-		w.WriteHeader(http.StatusTooManyRequests)
-		_ = json.NewEncoder(w).Encode(map[string]string{
-			"error":       "rate limit exceeded",
-			"retry_after": "60s",
-		})
-		return "", "", nil, nil, fmt.Errorf("rate limit exceeded")
+		h.metrics.recordRateLimited(ctx, tenantID)
+		writeRateLimited(w)
+		return nil, fmt.Errorf("rate limit exceeded")
+	}
+	if warn := rateLimitWarning(headroom); warn != "" {
+		warnings[headerRateLimitWarning] = warn
+	}
+
+	if h.retrieval != nil {
+		if err := h.retrieval.Augment(ctx, req); err != nil {
+			apierror.Write(w, http.StatusBadGateway, "", err.Error())
+			return nil, err
+		}
+		span.SetAttributes(attribute.StringSlice("retrieved_doc_ids", req.RetrievedDocIDs))
+		if req.EmbeddingCache != "" {
+			logging.FromContext(ctx).Info("retrieval: prompt augmented",
+				"docs", len(req.RetrievedDocIDs), "embedding_cache", req.EmbeddingCache)
+			span.SetAttributes(attribute.String("retrieval.embedding_cache", req.EmbeddingCache))
+			h.metrics.recordEmbeddingCache(ctx, tenantID, req.EmbeddingCache)
+		}
 	}
 
-	selectedProvider, err := h.router.Route(ctx, &req)
+	selectedProvider, err := h.routeRequest(w, r, req, settings, warnings, span)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules provider.ConversationRules
+	if rp, ok := selectedProvider.(provider.RuleProvider); ok {
+		rules = rp.ConversationRules()
+	}
+	maxTurns := h.maxTurns
+	if settings.MaxTurns > 0 {
+		maxTurns = settings.MaxTurns
+	}
+	messages, err := conversation.Validate(req.Messages, rules, conversation.Options{
+		MaxTurns: maxTurns,
+		Repair:   settings.RepairConversations,
+	})
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-		return "", "", nil, nil, err
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, err
 	}
+	req.Messages = messages
+
+	return &call{
+		tenantID:       tenantID,
+		requestID:      requestID,
+		conversationID: conversationID,
+		req:            req,
+		provider:       selectedProvider,
+		settings:       settings,
+		limit:          limit,
+		charged:        charged,
+		log:            logging.FromContext(ctx).With("provider", selectedProvider.Name(), "model", req.Model),
 
-	return tenantID, requestID, &req, selectedProvider, nil
+		guardrails: guardrails,
+		violations: violations,
+		warnings:   warnings,
+	}, nil
 }
 
-func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+// decodeRequest reads and validates the completion request in r's body,
+// expands its system_ref and runs the request hooks. It writes the error
+// response when it fails.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, tenantID, requestID string) (*provider.Request, string, error) {
 	ctx := r.Context()
-	tenantID := auth.GetTenantID(ctx)
-	if tenantID == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
-		return
+	if h.limits.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxBodyBytes)
+	}
+	var req provider.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			bodyTooLarge(tooLarge.Limit).write(w)
+			return nil, "", err
+		}
+		apierror.Write(w, http.StatusBadRequest, "", "invalid request body")
+		return nil, "", err
+	}
+	systemRef, err := h.expandSystemRef(ctx, tenantID, &req)
+	if err != nil {
+		writeSystemRefError(w, err)
+		return nil, "", err
+	}
+	if err := h.limits.check(&req); err != nil {
+		err.write(w)
+		return nil, "", err
+	}
+	if err := req.ResponseFormat.Validate(); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, "", err
+	}
+	if err := validateCostCap(req.MaxCost); err != nil {
+		apierror.WriteError(w, http.StatusBadRequest, apierror.Error{Message: err.Error(), Param: "max_cost"})
+		return nil, "", err
+	}
+	if req.Cache == nil {
+		var err error
+		if req.Cache, err = cache.ParseOptions(r.Header); err != nil {
+			apierror.Write(w, http.StatusBadRequest, "", err.Error())
+			return nil, "", err
+		}
+	}
+	if err := req.Cache.Validate(); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, "", err
+	}
+	req.TenantID = tenantID
+	req.APIKeyID = auth.GetAPIKeyID(ctx)
+	req.RequestID = requestID
+	req.NormalizeTools()
+	if err := h.hooks.OnRequest(ctx, &req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, "", err
 	}
 
-	// Parse query parameters
-	now := time.Now()
-	fromStr := r.URL.Query().Get("from")
-	toStr := r.URL.Query().Get("to")
+	return &req, systemRef, nil
+}
 
-	from := now.AddDate(0, 0, -30) // Default: last 30 days
-	to := now
+// checkModel checks model against the tenant's model policy and, when it is
+// an alias, every model it resolves to.
+func (h *Handler) checkModel(mp *policy.ModelPolicy, model string) error {
+	if err := mp.Check(model); err != nil {
+		return err
+	}
+	for _, target := range h.router.AliasTargets(model) {
+		if err := mp.Check(target); err != nil {
+			return fmt.Errorf("%s resolves to a model outside the policy: %w", model, err)
+		}
+	}
+	return nil
+}
 
-	if fromStr != "" {
-		var err error
-		from, err = time.Parse(time.RFC3339, fromStr)
+// routeRequest applies the tenant's model windows, model policy and
+// routing policies to req and picks its provider, recording the choices on
+// span. It writes the error response when req can't be served.
+func (h *Handler) routeRequest(w http.ResponseWriter, r *http.Request, req *provider.Request, settings *tenant.Settings, warnings limitWarnings, span trace.Span) (provider.Provider, error) {
+	ctx := r.Context()
+	tenantID := req.TenantID
+	decision, err := policy.ApplyWindows(settings.ModelWindows, req.Model, time.Now())
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return nil, err
+	}
+	if decision != nil {
+		logging.FromContext(ctx).Info("policy: model window applied",
+			"requested_model", decision.RequestedModel, "model", decision.Model, "reason", decision.Reason)
+		span.SetAttributes(
+			attribute.String("policy.requested_model", decision.RequestedModel),
+			attribute.String("policy.reason", decision.Reason),
+		)
+		if decision.Denied() {
+			apierror.Write(w, http.StatusForbidden, "", decision.Reason)
+			return nil, fmt.Errorf("model policy: %s", decision.Reason)
+		}
+		req.Model = decision.Model
+	}
+	// An alias runs as whichever target is healthy, so every target must be
+	// open. A window's fallback can't stand in for a single target, so a
+	// closed window on any of them refuses the alias.
+	for _, target := range h.router.AliasTargets(req.Model) {
+		decision, err := policy.ApplyWindows(settings.ModelWindows, target, time.Now())
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid 'from' date format (use RFC3339)"})
-			return
+			apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+			return nil, err
+		}
+		if decision != nil {
+			reason := fmt.Sprintf("%s resolves to %s", req.Model, decision.Reason)
+			apierror.Write(w, http.StatusForbidden, "", reason)
+			return nil, fmt.Errorf("model policy: %s", reason)
 		}
 	}
 
-	if toStr != "" {
-		var err error
-		to, err = time.Parse(time.RFC3339, toStr)
+	var mp *policy.ModelPolicy
+	if h.policies != nil {
+		mp, err = h.policies.Get(ctx, tenantID)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid 'to' date format (use RFC3339)"})
-			return
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load model policy")
+			return nil, err
+		}
+		if err := h.checkModel(mp, req.Model); err != nil {
+			apierror.Write(w, http.StatusForbidden, "", err.Error())
+			return nil, err
+		}
+	}
+
+	routing, err := h.effectiveRouting(ctx, settings, req.APIKeyID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", "failed to load routing policy")
+		return nil, err
+	}
+	if routing.Mode == RoutingModeStrict && !h.router.Serves(req.Model) {
+		writeModelNotFound(w, req.Model)
+		return nil, ErrModelNotFound
+	}
+
+	req.RoutingStrategy = routing.Strategy
+	override, oerr := h.routeOverrideFrom(r, settings, req.Model)
+	if oerr != nil {
+		oerr.write(w)
+		return nil, oerr
+	}
+	if override != nil {
+		if override.strategy != "" {
+			req.RoutingStrategy = override.strategy
 		}
+		req.Provider = override.provider
+		logging.FromContext(ctx).Info("proxy: route override",
+			"provider", override.provider, "strategy", override.strategy)
+		span.SetAttributes(
+			attribute.String("route_override.provider", override.provider),
+			attribute.String("route_override.strategy", override.strategy),
+		)
+	}
+	req.MaxCost = effectiveCostCap(req.MaxCost, routing.MaxCost)
+	selectedProvider, requested, err := h.route(ctx, req, mp)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return nil, err
+	}
+	if requested != "" {
+		warnings[headerModelSubstituted] = requested
+		span.SetAttributes(attribute.String("cost_cap.requested_model", requested))
+	}
+	if err := h.router.ValidateExtra(req, selectedProvider); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return nil, err
+	}
+
+	return selectedProvider, nil
+}
+
+func (h *Handler) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
 	}
 
-	logs, err := h.billing.GetUsageByTenant(ctx, tenantID, from, to)
+	from, to, err := usagePeriod(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
 		return
 	}
 
-	totalCost, err := h.billing.GetTotalCostByTenant(ctx, tenantID, from, to)
+	asOf, err := usageAsOf(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	limit, cursor, paginated, err := usagePage(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	if paginated {
+		h.writeUsagePage(w, r, tenantID, from, to, asOf, limit, cursor)
+		return
+	}
+
+	logs, err := h.billing.GetUsageByTenant(ctx, tenantID, from, to, asOf)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	totalCost, err := h.billing.GetTotalCostByTenant(ctx, tenantID, from, to, asOf)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	body := map[string]interface{}{
 		"tenant_id":      tenantID,
 		"total_requests": len(logs),
 		"total_cost_usd": totalCost,
 		"logs":           logs,
 		"from":           from,
 		"to":             to,
-	})
+	}
+	if !asOf.IsZero() {
+		body["as_of"] = asOf
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// usagePeriod reads the ?from= and ?to= RFC3339 bounds, defaulting to the
+// last 30 days.
+func usagePeriod(r *http.Request) (from, to time.Time, err error) {
+	now := time.Now()
+	from = now.AddDate(0, 0, -30)
+	to = now
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return from, to, errors.New("invalid 'from' date format (use RFC3339)")
+		}
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return from, to, errors.New("invalid 'to' date format (use RFC3339)")
+		}
+	}
+	return from, to, nil
+}
+
+// usageAsOf reads the optional ?as_of= RFC3339 time usage is reported as of,
+// so figures a customer was invoiced on can be reproduced after later
+// backfills and corrections. Zero means now.
+func usageAsOf(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid 'as_of' date format (use RFC3339)")
+	}
+	return asOf, nil
+}
+
+// HandleUsageForecast projects the tenant's end-of-month spend from daily
+// rollups. ?method=seasonal weights remaining days by weekday; default linear.
+func (h *Handler) HandleUsageForecast(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = billing.ForecastLinear
+	}
+	if method != billing.ForecastLinear && method != billing.ForecastSeasonal {
+		apierror.Write(w, http.StatusBadRequest, "", "invalid 'method' (use linear or seasonal)")
+		return
+	}
+
+	var budget float64
+	if h.tenants != nil {
+		settings, err := h.tenants.Get(ctx, tenantID)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to load tenant settings")
+			return
+		}
+		budget = settings.MonthlyBudgetUSD
+	}
+
+	now := time.Now().UTC()
+	daily, err := h.billing.GetDailyCostByTenant(ctx, tenantID, billing.HistoryStart(now), now)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	forecast, err := billing.ProjectMonth(daily, now, method, budget)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{
+		"tenant_id":         tenantID,
+		"method":            forecast.Method,
+		"month_start":       forecast.MonthStart,
+		"month_end":         forecast.MonthEnd,
+		"month_to_date_usd": forecast.MonthToDateUSD,
+		"projected_usd":     forecast.ProjectedUSD,
+	}
+	if budget > 0 {
+		resp["budget_usd"] = budget
+		resp["projected_over_budget"] = forecast.ProjectedUSD > budget
+		resp["budget_exhausted_at"] = forecast.BudgetExhaustedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
 }
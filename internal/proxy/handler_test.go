@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/vnmchuo/llm-gateway/internal/auth"
 	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
 	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
 	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
 	extratelimit "github.com/vnmchuo/ratelimiter"
 	"go.opentelemetry.io/otel/trace/noop"
@@ -20,9 +24,15 @@ import (
 
 // Mock Billing Store
 type mockBillingStore struct {
-	logUsageFunc         func(ctx context.Context, log *billing.UsageLog) error
-	getUsageByTenantFunc func(ctx context.Context, tenantID string, from, to time.Time) ([]*billing.UsageLog, error)
-	getTotalCostFunc     func(ctx context.Context, tenantID string, from, to time.Time) (float64, error)
+	logUsageFunc           func(ctx context.Context, log *billing.UsageLog) error
+	getUsageByTenantFunc   func(ctx context.Context, tenantID string, from, to time.Time) ([]*billing.UsageLog, error)
+	getTotalCostFunc       func(ctx context.Context, tenantID string, from, to time.Time) (float64, error)
+	getDailyCostFunc       func(ctx context.Context, tenantID string, from, to time.Time) ([]billing.DailyCost, error)
+	getUsageByKeyFunc      func(ctx context.Context, tenantID string, from, to time.Time) ([]billing.KeyUsage, error)
+	getUsagePageFunc       func(ctx context.Context, tenantID, after string, limit int) ([]*billing.UsageLog, error)
+	getUsageSummaryFunc    func(ctx context.Context, tenantID string, groupBy []string) ([]billing.UsageSummary, error)
+	getUsageByRequestsFunc func(ctx context.Context, tenantID string, requestIDs []string) ([]*billing.UsageLog, error)
+	asOf                   time.Time // last asOf usage was read at
 }
 
 func (m *mockBillingStore) LogUsage(ctx context.Context, log *billing.UsageLog) error {
@@ -32,20 +42,66 @@ func (m *mockBillingStore) LogUsage(ctx context.Context, log *billing.UsageLog)
 	return nil
 }
 
-func (m *mockBillingStore) GetUsageByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]*billing.UsageLog, error) {
+func (m *mockBillingStore) GetUsageByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]*billing.UsageLog, error) {
+	m.asOf = asOf
 	if m.getUsageByTenantFunc != nil {
 		return m.getUsageByTenantFunc(ctx, tenantID, from, to)
 	}
 	return nil, nil
 }
 
-func (m *mockBillingStore) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to time.Time) (float64, error) {
+func (m *mockBillingStore) GetTotalCostByTenant(ctx context.Context, tenantID string, from, to, asOf time.Time) (float64, error) {
 	if m.getTotalCostFunc != nil {
 		return m.getTotalCostFunc(ctx, tenantID, from, to)
 	}
 	return 0, nil
 }
 
+func (m *mockBillingStore) GetUsagePage(ctx context.Context, tenantID string, from, to, asOf time.Time, after string, limit int) ([]*billing.UsageLog, error) {
+	m.asOf = asOf
+	if m.getUsagePageFunc != nil {
+		return m.getUsagePageFunc(ctx, tenantID, after, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetUsageSummary(ctx context.Context, tenantID string, from, to, asOf time.Time, groupBy []string) ([]billing.UsageSummary, error) {
+	m.asOf = asOf
+	if m.getUsageSummaryFunc != nil {
+		return m.getUsageSummaryFunc(ctx, tenantID, groupBy)
+	}
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetUsageByRequests(ctx context.Context, tenantID string, requestIDs []string) ([]*billing.UsageLog, error) {
+	if m.getUsageByRequestsFunc != nil {
+		return m.getUsageByRequestsFunc(ctx, tenantID, requestIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetDailyCostByTenant(ctx context.Context, tenantID string, from, to time.Time) ([]billing.DailyCost, error) {
+	if m.getDailyCostFunc != nil {
+		return m.getDailyCostFunc(ctx, tenantID, from, to)
+	}
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetUsageByKey(ctx context.Context, tenantID string, from, to, asOf time.Time) ([]billing.KeyUsage, error) {
+	if m.getUsageByKeyFunc != nil {
+		return m.getUsageByKeyFunc(ctx, tenantID, from, to)
+	}
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetDailyUsageByModel(ctx context.Context, provider string, from, to time.Time) ([]billing.ModelDayUsage, error) {
+	return nil, nil
+}
+
+func (m *mockBillingStore) GetDailyUsageByTenants(ctx context.Context, from, to time.Time) ([]billing.TenantDayUsage, error) {
+	return nil, nil
+}
+
 // Mock Limiter Store
 type mockLimiterStore struct {
 	allowed bool
@@ -64,6 +120,25 @@ func (m *mockLimiterStore) Status(ctx context.Context, key string) (*extratelimi
 	return &extratelimit.Result{Allowed: m.allowed}, m.err
 }
 
+// apiErrorBody is the OpenAI-style error envelope handlers respond with.
+type apiErrorBody struct {
+	Error struct {
+		Message string  `json:"message"`
+		Type    string  `json:"type"`
+		Code    string  `json:"code"`
+		Param   *string `json:"param"`
+	} `json:"error"`
+}
+
+func decodeAPIError(t *testing.T, body []byte) apiErrorBody {
+	t.Helper()
+	var e apiErrorBody
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("Expected an error body, got %s: %v", body, err)
+	}
+	return e
+}
+
 // Test Suite
 func setupTest(providers []provider.Provider, limiterAllowed bool) (*Handler, *mockBillingStore) {
 	router := NewRouter(providers)
@@ -85,10 +160,9 @@ func TestHandleComplete_Unauthorized(t *testing.T) {
 		t.Errorf("Expected 401, got %d", w.Code)
 	}
 
-	var resp map[string]string
-	_ = json.Unmarshal(w.Body.Bytes(), &resp)
-	if resp["error"] != "unauthorized" {
-		t.Errorf("Expected unauthorized error, got %v", resp["error"])
+	resp := decodeAPIError(t, w.Body.Bytes())
+	if resp.Error.Message != "unauthorized" || resp.Error.Type != "authentication_error" {
+		t.Errorf("Expected unauthorized error, got %+v", resp.Error)
 	}
 }
 
@@ -105,10 +179,9 @@ func TestHandleComplete_InvalidBody(t *testing.T) {
 		t.Errorf("Expected 400, got %d", w.Code)
 	}
 
-	var resp map[string]string
-	_ = json.Unmarshal(w.Body.Bytes(), &resp)
-	if resp["error"] != "invalid request body" {
-		t.Errorf("Expected invalid request body error, got %v", resp["error"])
+	resp := decodeAPIError(t, w.Body.Bytes())
+	if resp.Error.Message != "invalid request body" || resp.Error.Type != "invalid_request_error" {
+		t.Errorf("Expected invalid request body error, got %+v", resp.Error)
 	}
 }
 
@@ -125,10 +198,9 @@ func TestHandleComplete_RateLimited(t *testing.T) {
 		t.Errorf("Expected 429, got %d", w.Code)
 	}
 
-	var resp map[string]string
-	_ = json.Unmarshal(w.Body.Bytes(), &resp)
-	if resp["error"] != "rate limit exceeded" {
-		t.Errorf("Expected rate limit exceeded error, got %v", resp["error"])
+	resp := decodeAPIError(t, w.Body.Bytes())
+	if resp.Error.Message != "rate limit exceeded" || resp.Error.Code != "rate_limit_exceeded" {
+		t.Errorf("Expected rate limit exceeded error, got %+v", resp.Error)
 	}
 	if w.Header().Get("Retry-After") != "60s" {
 		t.Errorf("Expected Retry-After: 60s header, got %s", w.Header().Get("Retry-After"))
@@ -149,10 +221,9 @@ func TestHandleComplete_ProviderUnavailable(t *testing.T) {
 		t.Errorf("Expected 503, got %d", w.Code)
 	}
 
-	var resp map[string]string
-	_ = json.Unmarshal(w.Body.Bytes(), &resp)
-	if resp["error"] == "" {
-		t.Errorf("Expected error message, got empty")
+	resp := decodeAPIError(t, w.Body.Bytes())
+	if resp.Error.Message == "" || resp.Error.Code != "provider_unavailable" {
+		t.Errorf("Expected provider_unavailable with a message, got %+v", resp.Error)
 	}
 }
 
@@ -285,17 +356,104 @@ func TestHandleCompleteStream_Success(t *testing.T) {
 	}
 
 	body := w.Body.String()
-	if !strings.Contains(body, "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"},\"index\":0}]}") {
-		t.Errorf("Body missing first chunk: %s", body)
+	chunks := decodeStream(t, body)
+	if len(chunks) != 4 {
+		t.Fatalf("Expected 2 content chunks, a finish chunk and a usage chunk, got %s", body)
+	}
+	for _, c := range chunks {
+		if c.ID != chunks[0].ID || c.ID == "" || c.Object != "chat.completion.chunk" || c.Created == 0 || c.Model != "gpt-4" {
+			t.Errorf("Expected every chunk to carry the stream's id, object, created and model, got %+v", c)
+		}
+	}
+	if d := chunks[0].Choices[0].Delta; d.Role != "assistant" || d.Content != "hello" || chunks[0].Choices[0].FinishReason != nil {
+		t.Errorf("Unexpected first chunk: %+v", chunks[0])
+	}
+	if d := chunks[1].Choices[0].Delta; d.Role != "" || d.Content != " world" {
+		t.Errorf("Unexpected second chunk: %+v", chunks[1])
 	}
-	if !strings.Contains(body, "data: {\"choices\":[{\"delta\":{\"content\":\" world\"},\"index\":0}]}") {
-		t.Errorf("Body missing second chunk: %s", body)
+	if fr := chunks[2].Choices[0].FinishReason; fr == nil || *fr != "stop" {
+		t.Errorf("Expected a finish chunk, got %+v", chunks[2])
+	}
+	if u := chunks[3].Usage; len(chunks[3].Choices) != 0 || u == nil || u.CompletionTokens != 3 || u.TotalTokens != 3 {
+		t.Errorf("Expected a final usage chunk, got %+v", chunks[3])
 	}
 	if !strings.Contains(body, "data: [DONE]") {
 		t.Errorf("Body missing DONE marker: %s", body)
 	}
 }
 
+func TestHandleCompleteStream_EscapesContent(t *testing.T) {
+	content := "C:\\path\\n \"quoted\"\n\ttab </script>"
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: content}, {Done: true}},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4","stream":true}`))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleCompleteStream(w, req)
+
+	chunks := decodeStream(t, w.Body.String())
+	if len(chunks) == 0 || len(chunks[0].Choices) == 0 || chunks[0].Choices[0].Delta.Content != content {
+		t.Errorf("Expected the content to round-trip, got %s", w.Body.String())
+	}
+}
+
+// decodeStream decodes a stream's data frames up to [DONE].
+func decodeStream(t *testing.T, body string) []streamChunk {
+	t.Helper()
+	var chunks []streamChunk
+	for _, frame := range strings.Split(body, "\n\n") {
+		data, ok := strings.CutPrefix(frame, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var c streamChunk
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatalf("Malformed frame %q: %v", data, err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+func TestHandleCompleteStream_ToolCalls(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{
+			name:            "test-provider",
+			supportedModels: []string{"gpt-4"},
+		},
+		chunks: []*provider.Chunk{
+			{ToolCalls: []provider.ToolCall{{ID: "call_1", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{ToolCalls: []provider.ToolCall{{ID: "call_2", Type: "function", Function: provider.ToolCallFunction{Name: "get_time", Arguments: `{}`}}}},
+			{Done: true},
+		},
+	}
+
+	h, _ := setupTest([]provider.Provider{p}, true)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"model":     "gpt-4",
+		"stream":    true,
+		"functions": []map[string]string{{"name": "get_weather"}, {"name": "get_time"}},
+	})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(reqBody))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleCompleteStream(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]`) {
+		t.Errorf("Body missing first tool call: %s", body)
+	}
+	if !strings.Contains(body, `"index":1,"id":"call_2"`) {
+		t.Errorf("Body missing second tool call at index 1: %s", body)
+	}
+}
+
 type MockStreamProvider struct {
 	MockProvider
 	chunks          []*provider.Chunk
@@ -398,3 +556,610 @@ func TestHandleUsage_DefaultDates(t *testing.T) {
 		t.Errorf("Expected from/to dates in response")
 	}
 }
+
+func TestHandleUsage_AsOf(t *testing.T) {
+	h, b := setupTest(nil, true)
+	req := httptest.NewRequest("GET", "/v1/usage?as_of=2024-02-01T00:00:00Z", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleUsage(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !b.asOf.Equal(want) {
+		t.Errorf("Expected usage read as of %v, got %v", want, b.asOf)
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["as_of"] != "2024-02-01T00:00:00Z" {
+		t.Errorf("Expected as_of echoed, got %v", resp["as_of"])
+	}
+
+	req = httptest.NewRequest("GET", "/v1/usage?as_of=yesterday", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w = httptest.NewRecorder()
+	h.HandleUsage(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid as_of, got %d", w.Code)
+	}
+}
+
+type mockTenantStore struct {
+	settings *tenant.Settings
+}
+
+func (m *mockTenantStore) Get(ctx context.Context, tenantID string) (*tenant.Settings, error) {
+	return m.settings, nil
+}
+
+func (m *mockTenantStore) Put(ctx context.Context, tenantID string, settings *tenant.Settings) error {
+	m.settings = settings
+	return nil
+}
+
+func TestHandleCompleteStream_PostProcessPlainText(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks: []*provider.Chunk{
+			{Delta: "**bo"},
+			{Delta: "ld** "},
+			{Delta: "done"},
+			{Done: true},
+		},
+	}
+	router := NewRouter([]provider.Provider{p})
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	tenants := &mockTenantStore{settings: &tenant.Settings{OutputFormat: "plain_text"}}
+	h := NewHandler(router, &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"), WithTenantSettings(tenants))
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"model": "gpt-4", "stream": true})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(reqBody))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleCompleteStream(w, req)
+
+	body := w.Body.String()
+	if strings.Contains(body, "**") {
+		t.Errorf("Expected markdown to be stripped across chunks: %s", body)
+	}
+	if !strings.Contains(body, `"content":"bold "`) || !strings.Contains(body, `"content":"done"`) {
+		t.Errorf("Body missing post-processed chunks: %s", body)
+	}
+	if !strings.Contains(body, "data: [DONE]") {
+		t.Errorf("Body missing DONE marker: %s", body)
+	}
+}
+
+func TestHandleUsageForecast_InvalidMethod(t *testing.T) {
+	h, _ := setupTest(nil, true)
+	req := httptest.NewRequest("GET", "/v1/usage/forecast?method=magic", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleUsageForecast(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleUsageForecast_OverBudget(t *testing.T) {
+	_, b := setupTest(nil, true)
+	b.getDailyCostFunc = func(ctx context.Context, tenantID string, from, to time.Time) ([]billing.DailyCost, error) {
+		var days []billing.DailyCost
+		for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+			days = append(days, billing.DailyCost{Day: d, CostUSD: 10})
+		}
+		return days, nil
+	}
+	tenants := &mockTenantStore{settings: &tenant.Settings{MonthlyBudgetUSD: 1}}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	h := NewHandler(NewRouter(nil), b, limiter, noop.NewTracerProvider().Tracer("test"), WithTenantSettings(tenants))
+
+	req := httptest.NewRequest("GET", "/v1/usage/forecast", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleUsageForecast(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["projected_over_budget"] != true {
+		t.Errorf("Expected projected_over_budget, got %v", resp)
+	}
+}
+
+type mockEmbeddingsProvider struct {
+	MockProvider
+}
+
+func (m *mockEmbeddingsProvider) Embed(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	embeddings := make([][]float32, len(req.Input))
+	for i := range embeddings {
+		embeddings[i] = []float32{float32(i)}
+	}
+	return &provider.EmbeddingResponse{Embeddings: embeddings, InputTokens: 8, Model: req.Model, Provider: m.name}, nil
+}
+
+func (m *mockEmbeddingsProvider) EmbeddingModels() []string      { return []string{"text-embedding-3-small"} }
+func (m *mockEmbeddingsProvider) CostPerEmbeddingToken() float64 { return 0.5 }
+
+func TestHandleEmbeddings_Success(t *testing.T) {
+	p := &mockEmbeddingsProvider{MockProvider: MockProvider{name: "embedder"}}
+	h, b := setupTest([]provider.Provider{&MockProvider{name: "chat-only"}, p}, true)
+
+	logged := make(chan *billing.UsageLog, 1)
+	b.logUsageFunc = func(ctx context.Context, log *billing.UsageLog) error {
+		logged <- log
+		return nil
+	}
+
+	body := `{"model":"text-embedding-3-small","input":["a","b"]}`
+	req := httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleEmbeddings(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if data := resp["data"].([]interface{}); len(data) != 2 {
+		t.Errorf("Expected 2 embeddings, got %d", len(data))
+	}
+
+	select {
+	case log := <-logged:
+		if log.Provider != "embedder" || log.InputTokens != 8 || log.CostUSD != 4 {
+			t.Errorf("Unexpected usage log: %+v", log)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected usage to be logged")
+	}
+}
+
+func TestHandleEmbeddings_EmptyInput(t *testing.T) {
+	h, _ := setupTest(nil, true)
+	req := httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(`{"model":"m","input":[]}`))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleEmbeddings(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+type mockPolicyStore struct {
+	policy *policy.ModelPolicy
+}
+
+func (m *mockPolicyStore) Get(ctx context.Context, tenantID string) (*policy.ModelPolicy, error) {
+	return m.policy, nil
+}
+func (m *mockPolicyStore) Put(ctx context.Context, p *policy.ModelPolicy) error { return nil }
+func (m *mockPolicyStore) Delete(ctx context.Context, tenantID string) error   { return nil }
+
+func TestHandleComplete_ModelDeniedByPolicy(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4o"}}
+	router := NewRouter([]provider.Provider{p})
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	policies := &mockPolicyStore{policy: &policy.ModelPolicy{Deny: []string{"gpt-4o"}}}
+	h := NewHandler(router, &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"), WithModelPolicies(policies))
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "trial-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "not allowed") {
+		t.Errorf("Expected clear policy error, got %s", w.Body.String())
+	}
+}
+
+func TestHandleCompleteStream_BillsUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		done       *provider.Chunk
+		wantInput  int
+		wantOutput int
+	}{
+		{"provider reported", &provider.Chunk{Done: true, Usage: &provider.Usage{InputTokens: 40, OutputTokens: 7}}, 40, 7},
+		// "12345678" is 2 estimated tokens; "hello world" is 3.
+		{"estimated", &provider.Chunk{Done: true}, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &MockStreamProvider{
+				MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+				chunks:       []*provider.Chunk{{Delta: "hello"}, {Delta: " world"}, tt.done},
+			}
+			h, b := setupTest([]provider.Provider{p}, true)
+			logged := make(chan *billing.UsageLog, 1)
+			b.logUsageFunc = func(ctx context.Context, log *billing.UsageLog) error {
+				logged <- log
+				return nil
+			}
+
+			body := `{"model":"gpt-4","messages":[{"role":"user","content":"12345678"}]}`
+			req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+			req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+			h.HandleCompleteStream(httptest.NewRecorder(), req)
+
+			select {
+			case log := <-logged:
+				if log.InputTokens != tt.wantInput || log.OutputTokens != tt.wantOutput {
+					t.Errorf("Expected %d/%d tokens, got %d/%d", tt.wantInput, tt.wantOutput, log.InputTokens, log.OutputTokens)
+				}
+				if want := float64(tt.wantInput) * 0.5; log.CostUSD != want {
+					t.Errorf("Expected cost %v, got %v", want, log.CostUSD)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected usage to be logged")
+			}
+		})
+	}
+}
+
+type mockCache struct {
+	entries map[string]*provider.Response
+	ttls    map[string]time.Duration
+}
+
+func (m *mockCache) Get(ctx context.Context, key string) (*provider.Response, bool, error) {
+	resp, ok := m.entries[key]
+	return resp, ok, nil
+}
+
+func (m *mockCache) Set(ctx context.Context, key string, resp *provider.Response, ttl time.Duration) error {
+	m.entries[key] = resp
+	if m.ttls != nil {
+		m.ttls[key] = ttl
+	}
+	return nil
+}
+
+func TestHandleComplete_ResponseCache(t *testing.T) {
+	p := &MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}}
+	router := NewRouter([]provider.Provider{p})
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	logged := make(chan *billing.UsageLog, 3)
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		logged <- log
+		return nil
+	}}
+	h := NewHandler(router, b, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithResponseCache(&mockCache{entries: map[string]*provider.Response{}}))
+
+	send := func(cacheControl string) map[string]interface{} {
+		body := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Cache-Control", cacheControl)
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		h.HandleComplete(w, req)
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if resp := send(""); resp["cached"] != false {
+		t.Errorf("Expected first response to be a miss, got %v", resp["cached"])
+	}
+	if resp := send("no-cache"); resp["cached"] != false {
+		t.Errorf("Expected no-cache to bypass the cache, got %v", resp["cached"])
+	}
+	if resp := send(""); resp["cached"] != true {
+		t.Errorf("Expected repeated prompt to hit the cache, got %v", resp["cached"])
+	}
+
+	hits := 0
+	for i := 0; i < 3; i++ {
+		select {
+		case log := <-logged:
+			if log.Cached {
+				hits++
+				if log.CostUSD != 0 {
+					t.Errorf("Expected cache hit billed at zero, got %v", log.CostUSD)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected usage to be logged")
+		}
+	}
+	if hits != 1 {
+		t.Errorf("Expected 1 cached usage log, got %d", hits)
+	}
+}
+
+func TestHandleComplete_MapsUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		want  int
+		code  string
+		param string // passed on from the upstream's error
+	}{
+		{"rate limited", provider.NewAPIError("test-provider", http.StatusTooManyRequests, []byte("slow down")), http.StatusTooManyRequests, "rate_limit_exceeded", ""},
+		{"content filtered", fmt.Errorf("blocked: %w", provider.ErrContentFiltered), http.StatusBadRequest, "content_filter", ""},
+		{"upstream failure", provider.NewAPIError("test-provider", http.StatusInternalServerError, nil), http.StatusBadGateway, "upstream_error", ""},
+		{"invalid parameter", provider.NewAPIError("test-provider", http.StatusBadRequest, []byte(`{"error":{"message":"bad","code":"invalid_value","param":"temperature"}}`)), http.StatusBadRequest, "invalid_value", "temperature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}, completeErr: tt.err}
+			h, _ := setupTest([]provider.Provider{p}, true)
+
+			body := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+			req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+			w := httptest.NewRecorder()
+
+			h.HandleComplete(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, w.Code)
+			}
+			resp := decodeAPIError(t, w.Body.Bytes())
+			if resp.Error.Code != tt.code {
+				t.Errorf("Expected code %q, got %+v", tt.code, resp.Error)
+			}
+			if tt.param != "" && (resp.Error.Param == nil || *resp.Error.Param != tt.param) {
+				t.Errorf("Expected param %q, got %+v", tt.param, resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleComplete_StrictRoutingRejectsUnknownModel(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	tenants := &mockTenantStore{settings: &tenant.Settings{RoutingMode: RoutingModeStrict}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"), WithTenantSettings(tenants))
+
+	for _, model := range []string{"gpt-5-preview", ""} {
+		body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"hi"}]}`, model)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "passthrough-tenant"))
+		w := httptest.NewRecorder()
+
+		h.HandleComplete(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("model %q: expected 404, got %d", model, w.Code)
+		}
+		resp := decodeAPIError(t, w.Body.Bytes())
+		if resp.Error.Code != "model_not_found" || resp.Error.Param == nil || *resp.Error.Param != "model" {
+			t.Errorf("model %q: expected model_not_found, got %+v", model, resp.Error)
+		}
+	}
+}
+
+func TestHandleCompleteStream_UsageTrailers(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "hi"}, {Done: true, Usage: &provider.Usage{InputTokens: 4, OutputTokens: 2}}},
+	}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"), WithUsageTrailers())
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleCompleteStream(w, req)
+
+	if !strings.Contains(w.Body.String(), `"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}`) {
+		t.Errorf("Body missing usage frame: %s", w.Body.String())
+	}
+	trailer := w.Result().Trailer
+	if trailer.Get("X-Usage-Input-Tokens") != "4" || trailer.Get("X-Usage-Output-Tokens") != "2" {
+		t.Errorf("Expected usage trailers 4/2, got %v", trailer)
+	}
+	if trailer.Get("X-Usage-Cost-USD") != "2" {
+		t.Errorf("Expected cost trailer 2, got %q", trailer.Get("X-Usage-Cost-USD"))
+	}
+}
+
+// stallingStreamProvider sends one delta, then stalls until ctx is done.
+type stallingStreamProvider struct {
+	MockProvider
+}
+
+func (m *stallingStreamProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk)
+	go func() {
+		defer close(ch)
+		select {
+		case ch <- &provider.Chunk{Delta: "hello world"}:
+		case <-ctx.Done():
+			return
+		}
+		<-ctx.Done()
+	}()
+	return ch, nil
+}
+
+// firstWriteRecorder signals once the handler has written to the client.
+type firstWriteRecorder struct {
+	*httptest.ResponseRecorder
+	written chan struct{}
+	once    sync.Once
+}
+
+func (r *firstWriteRecorder) Write(b []byte) (int, error) {
+	defer r.once.Do(func() { close(r.written) })
+	return r.ResponseRecorder.Write(b)
+}
+
+func TestHandleCompleteStream_ClientDisconnectBillsPartialUsage(t *testing.T) {
+	p := &stallingStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+	}
+	h, b := setupTest([]provider.Provider{p}, true)
+	logged := make(chan *billing.UsageLog, 1)
+	b.logUsageFunc = func(ctx context.Context, log *billing.UsageLog) error {
+		logged <- log
+		return nil
+	}
+
+	ctx, disconnect := context.WithCancel(auth.WithTenantID(context.Background(), "test-tenant"))
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"12345678"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body)).WithContext(ctx)
+	w := &firstWriteRecorder{ResponseRecorder: httptest.NewRecorder(), written: make(chan struct{})}
+	go func() {
+		<-w.written
+		disconnect()
+	}()
+
+	finished := make(chan struct{})
+	go func() {
+		h.HandleCompleteStream(w, req)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the handler to return once the client disconnected")
+	}
+
+	select {
+	case log := <-logged:
+		if log.FinishReason != billing.FinishReasonClientDisconnect {
+			t.Errorf("Expected finish reason %q, got %q", billing.FinishReasonClientDisconnect, log.FinishReason)
+		}
+		// "hello world" is 3 estimated tokens.
+		if log.OutputTokens != 3 {
+			t.Errorf("Expected 3 output tokens generated before the disconnect, got %d", log.OutputTokens)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected partial usage to be logged")
+	}
+}
+
+// truncatingProvider stops at its token limit, reporting it the Anthropic way.
+type truncatingProvider struct {
+	MockProvider
+}
+
+func (p *truncatingProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	return &provider.Response{Content: "partial", Provider: p.name, Model: req.Model, RawFinishReason: "max_tokens"}, nil
+}
+
+func (p *truncatingProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk, 2)
+	ch <- &provider.Chunk{Delta: "partial"}
+	ch <- &provider.Chunk{Done: true, RawFinishReason: "max_tokens"}
+	close(ch)
+	return ch, nil
+}
+
+func TestHandleComplete_NormalizesFinishReason(t *testing.T) {
+	p := &truncatingProvider{MockProvider{name: "claude", supportedModels: []string{"claude-3-5-haiku-20241022"}}}
+	h, _ := setupTest([]provider.Provider{p}, true)
+	body := `{"model":"claude-3-5-haiku-20241022","max_tokens":5,"messages":[{"role":"user","content":"hello"}]}`
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+
+	var resp struct {
+		Choices []struct {
+			FinishReason     string            `json:"finish_reason"`
+			ProviderMetadata map[string]string `json:"provider_metadata"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Choices) != 1 {
+		t.Fatalf("Failed to decode response: %v %s", err, w.Body.String())
+	}
+	if resp.Choices[0].FinishReason != "length" || resp.Choices[0].ProviderMetadata["finish_reason"] != "max_tokens" {
+		t.Errorf("Expected length with the raw reason kept, got %+v", resp.Choices[0])
+	}
+
+	req = httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w = httptest.NewRecorder()
+	h.HandleCompleteStream(w, req)
+
+	if !strings.Contains(w.Body.String(), `"finish_reason":"length","provider_metadata":{"finish_reason":"max_tokens"}`) {
+		t.Errorf("Expected a final frame with the normalized finish reason, got %s", w.Body.String())
+	}
+}
+
+func TestCachePolicy_RequestOverridesTenant(t *testing.T) {
+	tests := []struct {
+		name         string
+		settings     tenant.Settings
+		opts         *provider.CacheOptions
+		cacheControl string
+		read, write  bool
+		ttl          time.Duration
+	}{
+		{"default", tenant.Settings{}, nil, "", true, true, 0},
+		{"tenant off", tenant.Settings{CacheMode: "off"}, nil, "", false, false, 0},
+		{"request reenables", tenant.Settings{CacheMode: "off", CacheTTLSeconds: 60}, &provider.CacheOptions{Mode: "read_write"}, "", true, true, time.Minute},
+		{"request read only", tenant.Settings{}, &provider.CacheOptions{Mode: "read_only"}, "", true, false, 0},
+		{"request ttl", tenant.Settings{CacheTTLSeconds: 60}, &provider.CacheOptions{TTL: 3600}, "", true, true, time.Hour},
+		{"cache-control still opts out", tenant.Settings{}, &provider.CacheOptions{Mode: "read_write"}, "no-store", true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			r.Header.Set("Cache-Control", tt.cacheControl)
+			c := &call{settings: &tt.settings, req: &provider.Request{Cache: tt.opts}}
+			read, write, ttl := cachePolicy(r, c)
+			if read != tt.read || write != tt.write || ttl != tt.ttl {
+				t.Errorf("Expected read=%v write=%v ttl=%v, got %v %v %v", tt.read, tt.write, tt.ttl, read, write, ttl)
+			}
+		})
+	}
+}
+
+func TestHandleComplete_CacheOptionsFromHeaders(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	mc := &mockCache{entries: map[string]*provider.Response{}, ttls: map[string]time.Duration{}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithResponseCache(mc))
+
+	send := func(mode, ttl string) int {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("X-Cache-Mode", mode)
+		req.Header.Set("X-Cache-TTL", ttl)
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		h.HandleComplete(w, req)
+		return w.Code
+	}
+
+	if code := send("read_only", ""); code != http.StatusOK || len(mc.entries) != 0 {
+		t.Errorf("Expected read_only not to store, got %d with %d entries", code, len(mc.entries))
+	}
+	if code := send("", "120"); code != http.StatusOK || len(mc.entries) != 1 {
+		t.Fatalf("Expected the response to be stored, got %d with %d entries", code, len(mc.entries))
+	}
+	for _, ttl := range mc.ttls {
+		if ttl != 2*time.Minute {
+			t.Errorf("Expected the header TTL, got %v", ttl)
+		}
+	}
+	if code := send("sometimes", ""); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mode, got %d", code)
+	}
+}
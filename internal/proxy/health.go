@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+)
+
+// Health levels reported by GET /v1/status.
+const (
+	HealthGreen  = "green"  // serving normally
+	HealthYellow = "yellow" // degraded: failing or slow more than usual, or recovering
+	HealthRed    = "red"    // not taking requests
+)
+
+// ProviderHealth is a provider's health as tenants see it.
+type ProviderHealth struct {
+	Name   string        `json:"name"`
+	Health string        `json:"health"`
+	Models []ModelHealth `json:"models"`
+}
+
+// ModelHealth is the health of one model on a provider. Latency and error
+// rate are the model's own once it has enough calls, the provider's before.
+type ModelHealth struct {
+	Model     string  `json:"model"`
+	Health    string  `json:"health"`
+	LatencyMs float64 `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// Health scores every provider and its models, in configuration order. A
+// provider whose breaker is open, that an operator disabled or that the
+// capacity calendar takes out is red; one recovering (half-open), degraded
+// or scheduled for reduced capacity is yellow, and so are its models in the
+// same state.
+func (r *Router) Health() []ProviderHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ProviderHealth, 0, len(r.providers))
+	for _, p := range r.providers {
+		ph := ProviderHealth{Name: p.Name(), Health: r.healthOf(p.Name(), "", HealthGreen), Models: []ModelHealth{}}
+
+		models := slices.Clone(r.modelsOf(p))
+		slices.Sort(models)
+		for _, model := range slices.Compact(models) {
+			stats := r.latency.stats(p.Name(), model)
+			mh := ModelHealth{
+				Model:     model,
+				Health:    ph.Health,
+				LatencyMs: math.Round(stats.LatencyMs*10) / 10,
+				ErrorRate: math.Round(stats.ErrorRate*1000) / 1000,
+			}
+			if mh.Health != HealthRed {
+				mh.Health = r.healthOf(p.Name(), model, mh.Health)
+			}
+			ph.Models = append(ph.Models, mh)
+		}
+		out = append(out, ph)
+	}
+	return out
+}
+
+// healthOf scores name, or its model when model is set, starting from
+// health. Callers hold r.mu.
+func (r *Router) healthOf(name, model, health string) string {
+	scheduled := r.scheduledCapacity(name, model)
+	switch {
+	case model == "" && (r.disabled[name] || r.breakers[name].State() == gobreaker.StateOpen):
+		return HealthRed
+	case scheduled != nil && scheduled.Capacity == policy.CapacityNone:
+		return HealthRed
+	case model == "" && r.breakers[name].State() == gobreaker.StateHalfOpen:
+		return HealthYellow
+	case scheduled != nil || r.isDegraded(name, model):
+		return HealthYellow
+	}
+	return health
+}
+
+// isDegraded is latencyTracker.degraded under the router's thresholds.
+func (r *Router) isDegraded(name, model string) bool {
+	return r.degradation != (Degradation{}) && r.latency.degraded(name, model, r.degradation)
+}
+
+// overallHealth is green when every provider is, red when none serves and
+// yellow otherwise.
+func overallHealth(providers []ProviderHealth) string {
+	red, green := 0, 0
+	for _, p := range providers {
+		switch p.Health {
+		case HealthRed:
+			red++
+		case HealthGreen:
+			green++
+		}
+	}
+	switch {
+	case red == len(providers):
+		return HealthRed
+	case green == len(providers):
+		return HealthGreen
+	}
+	return HealthYellow
+}
+
+// HandleStatus serves GET /v1/status: a health indicator per provider and
+// model, so clients can adapt, e.g. pick another model, while one degrades.
+func (h *Handler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	providers := h.router.Health()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":     overallHealth(providers),
+		"providers":  providers,
+		"updated_at": time.Now().UTC(),
+	})
+}
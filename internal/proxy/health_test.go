@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestHandleStatus(t *testing.T) {
+	router := NewRouter([]provider.Provider{
+		&MockProvider{name: "openai", supportedModels: []string{"gpt-4o", "gpt-4o-mini"}},
+		&MockProvider{name: "claude", supportedModels: []string{"claude-3-5-haiku"}},
+		&MockProvider{name: "gemini", supportedModels: []string{"gemini-2.0-flash"}},
+	}, WithDegradation(Degradation{ErrorRate: 0.5}))
+	// gpt-4o keeps failing while the provider's other model recovers it.
+	for i := 0; i < degradedMinSamples; i++ {
+		router.latency.record("openai", "gpt-4o", 0, true)
+	}
+	for i := 0; i < 2*degradedMinSamples; i++ {
+		router.latency.record("openai", "gpt-4o-mini", 100*time.Millisecond, false)
+	}
+	_ = router.DisableProvider("claude")
+	h := NewHandler(router, nil, nil, noop.NewTracerProvider().Tracer("test"))
+
+	w := httptest.NewRecorder()
+	h.HandleStatus(w, httptest.NewRequest("GET", "/v1/status", nil))
+
+	var resp struct {
+		Status    string           `json:"status"`
+		Providers []ProviderHealth `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Providers) != 3 {
+		t.Fatalf("Failed to decode status: %v %s", err, w.Body.String())
+	}
+	if resp.Status != HealthYellow {
+		t.Errorf("Expected yellow overall with one provider down, got %q", resp.Status)
+	}
+	want := map[string]string{"openai": HealthGreen, "claude": HealthRed, "gemini": HealthGreen}
+	models := map[string]string{"gpt-4o": HealthYellow, "gpt-4o-mini": HealthGreen, "claude-3-5-haiku": HealthRed, "gemini-2.0-flash": HealthGreen}
+	for _, p := range resp.Providers {
+		if p.Health != want[p.Name] {
+			t.Errorf("Expected %s %s, got %s", p.Name, want[p.Name], p.Health)
+		}
+		for _, m := range p.Models {
+			if m.Health != models[m.Model] {
+				t.Errorf("Expected %s %s, got %+v", m.Model, models[m.Model], m)
+			}
+		}
+	}
+}
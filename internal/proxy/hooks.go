@@ -0,0 +1,13 @@
+package proxy
+
+import (
+	"github.com/vnmchuo/llm-gateway/internal/hooks"
+)
+
+// WithHooks runs hs, in order, on every completion request, response and
+// streamed delta, after the tenant's own output rewriting.
+func WithHooks(hs ...hooks.Hook) Option {
+	return func(h *Handler) {
+		h.hooks = append(h.hooks, hs...)
+	}
+}
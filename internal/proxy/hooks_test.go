@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/hooks"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestHooks(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "hello"}, {Delta: " world"}, {Done: true, FinishReason: provider.FinishStop}},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+	var tenant string
+	WithHooks(hooks.Funcs{
+		HookName: "watermark",
+		Request: func(ctx context.Context, req *provider.Request) error {
+			tenant = req.TenantID
+			if req.Model == "blocked" {
+				return errors.New("model blocked by hook")
+			}
+			return nil
+		},
+		Response: func(ctx context.Context, req *provider.Request, resp *provider.Response) error {
+			resp.Content += " [generated]"
+			return nil
+		},
+		Chunk: func(ctx context.Context, req *provider.Request, delta string) string {
+			return strings.ToUpper(delta)
+		},
+	})(h)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, completionRequest("gpt-4"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"content":"mock [generated]"`) {
+		t.Errorf("Expected the response hook applied, got %d %s", w.Code, w.Body.String())
+	}
+	if tenant != "tenant-1" {
+		t.Errorf("Expected the request hook to see the tenant, got %q", tenant)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleComplete(w, completionRequest("blocked"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "watermark: model blocked by hook") {
+		t.Errorf("Expected 400 from the rejecting hook, got %d %s", w.Code, w.Body.String())
+	}
+
+	body := []byte(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req = req.WithContext(auth.WithRequestID(auth.WithTenantID(req.Context(), "tenant-1"), "req-1"))
+	w = httptest.NewRecorder()
+	h.HandleCompleteStream(w, req)
+	if !strings.Contains(w.Body.String(), `"HELLO"`) || !strings.Contains(w.Body.String(), `" WORLD"`) {
+		t.Errorf("Expected the chunk hook applied to each delta, got %s", w.Body.String())
+	}
+}
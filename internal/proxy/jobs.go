@@ -0,0 +1,329 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+)
+
+// WithJobs enables the async job endpoints.
+func WithJobs(queue worker.Queue, store worker.Store) Option {
+	return func(h *Handler) {
+		h.jobs = queue
+		h.jobStore = store
+	}
+}
+
+// WithWebhookGuard checks callback URLs against g when jobs are created.
+// Without it, callbacks must still be https and avoid private hosts.
+func WithWebhookGuard(g *webhook.Guard) Option {
+	return func(h *Handler) {
+		h.webhooks = g
+	}
+}
+
+// WithJobResultURLs serves offloaded job results as signed download URLs
+// valid for ttl.
+func WithJobResultURLs(blobs worker.BlobStore, ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.jobBlobs = blobs
+		h.jobURLTTL = ttl
+	}
+}
+
+// jobEventsKeepalive is how often an idle job event stream sends a comment so
+// proxies don't time it out.
+const jobEventsKeepalive = 15 * time.Second
+
+// WithJobEvents serves GET /v1/jobs/{id}/events from events published by
+// the workers.
+func WithJobEvents(events worker.Events) Option {
+	return func(h *Handler) {
+		h.jobEvents = events
+	}
+}
+
+// HandleCreateJob accepts a chat completion body plus an optional
+// callback_url, enqueues it and returns 202 with the job ID.
+func (h *Handler) HandleCreateJob(w http.ResponseWriter, r *http.Request) {
+	if h.jobs == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "async jobs are not enabled")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", "invalid request body")
+		return
+	}
+	var extra struct {
+		CallbackURL string `json:"callback_url"`
+	}
+	_ = json.Unmarshal(body, &extra)
+	if extra.CallbackURL != "" {
+		if err := h.webhooks.CheckURL(extra.CallbackURL); err != nil {
+			apierror.WriteError(w, http.StatusBadRequest, apierror.Error{Message: err.Error(), Param: "callback_url"})
+			return
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	c, err := h.prepare(w, r)
+	if err != nil {
+		return
+	}
+
+	job := &worker.AsyncJob{
+		ID:          uuid.New().String(),
+		TenantID:    c.tenantID,
+		Request:     c.req,
+		CallbackURL: extra.CallbackURL,
+	}
+	if err := h.jobs.Enqueue(r.Context(), job); err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	c.warnings.apply(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         job.ID,
+		"status":     job.Status,
+		"created_at": job.CreatedAt,
+	})
+}
+
+func (h *Handler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.jobStore == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "async jobs are not enabled")
+		return
+	}
+
+	job, err := h.jobStore.Get(r.Context(), tenantID, chi.URLParam(r, "id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, worker.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		apierror.Write(w, status, "", err.Error())
+		return
+	}
+
+	body := map[string]interface{}{
+		"id":           job.ID,
+		"status":       job.Status,
+		"result":       job.Result,
+		"error":        job.Error,
+		"progress":     job.Progress,
+		"created_at":   job.CreatedAt,
+		"updated_at":   job.UpdatedAt,
+		"completed_at": job.CompletedAt,
+	}
+	// Offloaded results are fetched from object storage with a signed URL;
+	// result then only carries the model and token counts.
+	if job.Result != nil && job.Result.ObjectKey != "" && h.jobBlobs != nil {
+		url, err := h.jobBlobs.SignedURL(r.Context(), job.Result.ObjectKey, h.jobURLTTL)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "", "failed to sign result URL")
+			return
+		}
+		body["result_url"] = url
+		body["result_url_expires_at"] = time.Now().Add(h.jobURLTTL).UTC()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// ExecuteJob is the worker.Executor for async jobs: it re-routes the request
+// (provider health may have changed since enqueue), runs it and logs usage.
+func (h *Handler) ExecuteJob(ctx context.Context, job *worker.AsyncJob) (*provider.Response, error) {
+	req := job.Request
+	settings := &tenant.Settings{}
+	if h.tenants != nil {
+		s, err := h.tenants.Get(ctx, req.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		settings = s
+	}
+	if h.identities != nil {
+		ctx = h.identities.WithBaggage(ctx, req.TenantID, settings.Plan, req.APIKeyID)
+	}
+	routing, err := h.effectiveRouting(ctx, settings, req.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	req.RoutingStrategy = routing.Strategy
+	req.MaxCost = effectiveCostCap(req.MaxCost, routing.MaxCost)
+	var mp *policy.ModelPolicy
+	if h.policies != nil {
+		if mp, err = h.policies.Get(ctx, req.TenantID); err != nil {
+			return nil, err
+		}
+	}
+	p, _, err := h.route(ctx, req, mp)
+	if err != nil {
+		return nil, err
+	}
+	response, served, err := fitContext(ctx, req, settings.ContextOverflow, func(req *provider.Request) (*provider.Response, provider.Provider, error) {
+		if (h.tools == nil || len(settings.ManagedTools) == 0) && req.ResponseFormat == nil {
+			return h.executeStreamed(ctx, req, p)
+		}
+		return h.execute(ctx, req, p, settings.ManagedTools)
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.logUsage(ctx, req, served, response, "")
+	return response, nil
+}
+
+// executeStreamed runs a job's completion as a stream so the worker can
+// report chunks as they arrive. Tool loops and JSON mode need the whole
+// response and run through execute instead.
+func (h *Handler) executeStreamed(ctx context.Context, req *provider.Request, p provider.Provider) (*provider.Response, provider.Provider, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	stream, served, err := h.router.ExecuteStreamWithFallback(streamCtx, req, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var toolCalls []provider.ToolCall
+	chunks := 0
+	for chunk := range stream.C {
+		if chunk.Err != nil {
+			return nil, nil, chunk.Err
+		}
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+		if chunk.Done {
+			break
+		}
+		chunks++
+		worker.ReportProgress(ctx, worker.JobProgress{ChunksGenerated: chunks})
+	}
+	tally := stream.Tally(streamTallyWait)
+	usage := tally.Usage
+	if usage == nil {
+		usage = &provider.Usage{OutputTokens: tally.OutputTokens()}
+		for _, m := range req.Messages {
+			usage.InputTokens += provider.EstimateTokens(m.Content)
+		}
+	}
+
+	return &provider.Response{
+		Content:      tally.Output,
+		ToolCalls:    toolCalls,
+		Model:        h.router.ModelFor(req, served),
+		Provider:     served.Name(),
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		LatencyMs:    time.Since(start).Milliseconds(),
+	}, served, nil
+}
+
+// HandleJobEvents serves GET /v1/jobs/{id}/events: server-sent events with
+// the job's current state, then each status change and progress update,
+// ending once the job is done or failed.
+func (h *Handler) HandleJobEvents(w http.ResponseWriter, r *http.Request) {
+	tenantID := auth.GetTenantID(r.Context())
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.jobStore == nil || h.jobEvents == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "async jobs are not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, http.StatusInternalServerError, "", "streaming unsupported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Subscribe before reading the job so no transition falls in between.
+	jobID := chi.URLParam(r, "id")
+	events, err := h.jobEvents.Subscribe(ctx, jobID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	job, err := h.jobStore.Get(ctx, tenantID, jobID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, worker.ErrJobNotFound) {
+			status = http.StatusNotFound
+		}
+		apierror.Write(w, status, "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Jobs can outlive the server's write timeout; the stream ends with the job.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	writeEvent := func(ev worker.JobEvent) bool {
+		frame, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, frame)
+		flusher.Flush()
+		return ev.Status == worker.JobStatusDone || ev.Status == worker.JobStatusFailed
+	}
+
+	if writeEvent(worker.JobEvent{
+		Type:     worker.EventStatus,
+		JobID:    job.ID,
+		Status:   job.Status,
+		Progress: job.Progress,
+		Error:    job.Error,
+		At:       job.UpdatedAt,
+	}) {
+		return
+	}
+
+	keepalive := time.NewTicker(jobEventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok || writeEvent(ev) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type mockQueue struct {
+	enqueued []*worker.AsyncJob
+}
+
+func (m *mockQueue) Enqueue(ctx context.Context, job *worker.AsyncJob) error {
+	job.Status = worker.JobStatusPending
+	m.enqueued = append(m.enqueued, job)
+	return nil
+}
+
+func (m *mockQueue) Process(ctx context.Context) error { return nil }
+
+type mockJobStore struct {
+	jobs map[string]*worker.AsyncJob
+}
+
+func (m *mockJobStore) Create(ctx context.Context, job *worker.AsyncJob) error {
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *mockJobStore) Get(ctx context.Context, tenantID, jobID string) (*worker.AsyncJob, error) {
+	job, ok := m.jobs[jobID]
+	if !ok || job.TenantID != tenantID {
+		return nil, worker.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (m *mockJobStore) GetByID(ctx context.Context, jobID string) (*worker.AsyncJob, error) {
+	job, ok := m.jobs[jobID]
+	if !ok {
+		return nil, worker.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (m *mockJobStore) UpdateStatus(ctx context.Context, jobID string, status worker.JobStatus, result *worker.JobResult, errMsg string) error {
+	return nil
+}
+
+func (m *mockJobStore) UpdateProgress(ctx context.Context, jobID string, progress *worker.JobProgress) error {
+	if job, ok := m.jobs[jobID]; ok {
+		job.Progress = progress
+	}
+	return nil
+}
+
+func (m *mockJobStore) DeleteExpired(ctx context.Context, limit int) ([]string, int, error) {
+	return nil, 0, nil
+}
+
+func setupJobsTest() (*Handler, *mockQueue, *mockJobStore) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	queue := &mockQueue{}
+	store := &mockJobStore{jobs: map[string]*worker.AsyncJob{}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"),
+		WithJobs(queue, store))
+	return h, queue, store
+}
+
+func TestHandleCreateJob_Enqueues(t *testing.T) {
+	h, queue, _ := setupJobsTest()
+
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"model":        "gpt-4",
+		"messages":     []map[string]string{{"role": "user", "content": "hi"}},
+		"callback_url": "https://example.com/hook",
+	})
+	req := httptest.NewRequest("POST", "/v1/jobs", bytes.NewReader(reqBody))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleCreateJob(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(queue.enqueued) != 1 {
+		t.Fatalf("Expected 1 enqueued job, got %d", len(queue.enqueued))
+	}
+	job := queue.enqueued[0]
+	if job.CallbackURL != "https://example.com/hook" || job.TenantID != "test-tenant" || job.Request.Model != "gpt-4" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+}
+
+func TestHandleCreateJob_RejectsPrivateCallbackURL(t *testing.T) {
+	h, queue, _ := setupJobsTest()
+
+	for _, callback := range []string{"http://example.com/hook", "https://169.254.169.254/latest/meta-data", "https://localhost:8081/admin"} {
+		reqBody, _ := json.Marshal(map[string]interface{}{
+			"model":        "gpt-4",
+			"messages":     []map[string]string{{"role": "user", "content": "hi"}},
+			"callback_url": callback,
+		})
+		req := httptest.NewRequest("POST", "/v1/jobs", bytes.NewReader(reqBody))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+
+		h.HandleCreateJob(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", callback, w.Code)
+		}
+		if e := decodeAPIError(t, w.Body.Bytes()); e.Error.Param == nil || *e.Error.Param != "callback_url" {
+			t.Errorf("%s: expected the error on callback_url, got %+v", callback, e.Error)
+		}
+	}
+	if len(queue.enqueued) != 0 {
+		t.Errorf("Expected nothing enqueued, got %d jobs", len(queue.enqueued))
+	}
+}
+
+func TestHandleGetJob_OtherTenantNotFound(t *testing.T) {
+	h, _, store := setupJobsTest()
+	store.jobs["job-1"] = &worker.AsyncJob{ID: "job-1", TenantID: "other-tenant", Status: worker.JobStatusDone}
+
+	req := httptest.NewRequest("GET", "/v1/jobs/job-1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "job-1")
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), "test-tenant"), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+
+	h.HandleGetJob(w, req.WithContext(ctx))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+type mockBlobStore struct{}
+
+func (mockBlobStore) Put(ctx context.Context, key string, data []byte) error { return nil }
+func (mockBlobStore) Delete(ctx context.Context, key string) error           { return nil }
+func (mockBlobStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://blobs.example.com/" + key + "?sig=abc", nil
+}
+
+func TestHandleGetJob_OffloadedResultURL(t *testing.T) {
+	h, _, store := setupJobsTest()
+	WithJobResultURLs(mockBlobStore{}, 15*time.Minute)(h)
+	store.jobs["job-1"] = &worker.AsyncJob{ID: "job-1", TenantID: "test-tenant", Status: worker.JobStatusDone,
+		Result: &worker.JobResult{ObjectKey: "jobs/job-1/result.json", Model: "gpt-4", OutputTokens: 90000}}
+
+	req := httptest.NewRequest("GET", "/v1/jobs/job-1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "job-1")
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), "test-tenant"), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+
+	h.HandleGetJob(w, req.WithContext(ctx))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var body struct {
+		ResultURL          string    `json:"result_url"`
+		ResultURLExpiresAt time.Time `json:"result_url_expires_at"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.ResultURL != "https://blobs.example.com/jobs/job-1/result.json?sig=abc" {
+		t.Errorf("unexpected result_url %q", body.ResultURL)
+	}
+	if body.ResultURLExpiresAt.Before(time.Now().Add(14 * time.Minute)) {
+		t.Errorf("unexpected result_url_expires_at %v", body.ResultURLExpiresAt)
+	}
+}
+
+func TestExecuteJob_RoutesAndReturnsResponse(t *testing.T) {
+	h, _, _ := setupJobsTest()
+
+	resp, err := h.ExecuteJob(context.Background(), &worker.AsyncJob{
+		ID:      "job-1",
+		Request: &provider.Request{Model: "gpt-4", TenantID: "test-tenant"},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteJob failed: %v", err)
+	}
+	if resp.Content != "mock" || resp.Provider != "test-provider" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+type mockJobEvents struct {
+	events chan worker.JobEvent
+}
+
+func (m *mockJobEvents) Subscribe(ctx context.Context, jobID string) (<-chan worker.JobEvent, error) {
+	return m.events, nil
+}
+
+func TestHandleJobEvents_StreamsUntilDone(t *testing.T) {
+	h, _, store := setupJobsTest()
+	events := &mockJobEvents{events: make(chan worker.JobEvent, 2)}
+	WithJobEvents(events)(h)
+	store.jobs["job-1"] = &worker.AsyncJob{ID: "job-1", TenantID: "test-tenant", Status: worker.JobStatusPending}
+	events.events <- worker.JobEvent{Type: worker.EventProgress, JobID: "job-1", Status: worker.JobStatusRunning,
+		Progress: &worker.JobProgress{ChunksGenerated: 12}}
+	events.events <- worker.JobEvent{Type: worker.EventStatus, JobID: "job-1", Status: worker.JobStatusDone}
+
+	req := httptest.NewRequest("GET", "/v1/jobs/job-1/events", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "job-1")
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), "test-tenant"), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+
+	h.HandleJobEvents(w, req.WithContext(ctx))
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q: %s", ct, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`event: status` + "\n" + `data: {"type":"status","job_id":"job-1","status":"pending"`,
+		`event: progress` + "\n" + `data: {"type":"progress","job_id":"job-1","status":"running","progress":{"chunks_generated":12}`,
+		`"status":"done"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in stream:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandleJobEvents_OtherTenantNotFound(t *testing.T) {
+	h, _, store := setupJobsTest()
+	WithJobEvents(&mockJobEvents{events: make(chan worker.JobEvent)})(h)
+	store.jobs["job-1"] = &worker.AsyncJob{ID: "job-1", TenantID: "other-tenant", Status: worker.JobStatusRunning}
+
+	req := httptest.NewRequest("GET", "/v1/jobs/job-1/events", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "job-1")
+	ctx := context.WithValue(auth.WithTenantID(req.Context(), "test-tenant"), chi.RouteCtxKey, rctx)
+	w := httptest.NewRecorder()
+
+	h.HandleJobEvents(w, req.WithContext(ctx))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// WithAPIKeys enables GET /v1/keys, which lists the calling tenant's keys.
+func WithAPIKeys(store auth.Store) Option {
+	return func(h *Handler) {
+		h.keys = store
+	}
+}
+
+// keyUsage is one key's usage over the requested period.
+type keyUsage struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+type keySummary struct {
+	ID           string     `json:"id"`
+	Key          string     `json:"key"`
+	Active       bool       `json:"active"`
+	RateLimit    int64      `json:"rate_limit"`
+	RateLimitRPM int64      `json:"rate_limit_rpm"`
+	Scopes       []string   `json:"scopes,omitempty"` // absent: full access
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	Usage        keyUsage   `json:"usage"`
+}
+
+// HandleListKeys returns the tenant's keys with masked secrets and usage over
+// ?from= and ?to= (default: the last 30 days), as of ?as_of= when given.
+func (h *Handler) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+	if h.keys == nil {
+		apierror.Write(w, http.StatusNotImplemented, "", "key listing is not enabled")
+		return
+	}
+
+	from, to, err := usagePeriod(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	asOf, err := usageAsOf(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	keys, err := h.keys.ListByTenant(ctx, tenantID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	usage, err := h.billing.GetUsageByKey(ctx, tenantID, from, to, asOf)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	byKey := make(map[string]keyUsage, len(usage))
+	for _, u := range usage {
+		byKey[u.APIKeyID] = keyUsage{
+			Requests:     u.Requests,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			CostUSD:      u.CostUSD,
+		}
+	}
+
+	summaries := make([]keySummary, 0, len(keys))
+	for _, k := range keys {
+		summaries = append(summaries, keySummary{
+			ID:           k.ID,
+			Key:          k.Masked(),
+			Active:       k.Active,
+			RateLimit:    k.RateLimit,
+			RateLimitRPM: k.RateLimitRPM,
+			Scopes:       k.Scopes,
+			CreatedAt:    k.CreatedAt,
+			LastUsedAt:   k.LastUsedAt,
+			Usage:        byKey[k.ID],
+		})
+	}
+
+	body := map[string]interface{}{
+		"keys": summaries,
+		"from": from,
+		"to":   to,
+	}
+	if !asOf.IsZero() {
+		body["as_of"] = asOf
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
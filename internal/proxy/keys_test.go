@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type mockAuthStore struct {
+	auth.Store
+	keys []*auth.APIKey
+}
+
+func (m *mockAuthStore) ListByTenant(ctx context.Context, tenantID string) ([]*auth.APIKey, error) {
+	return m.keys, nil
+}
+
+func TestHandleListKeys_MasksKeysAndAddsUsage(t *testing.T) {
+	used := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	keys := &mockAuthStore{keys: []*auth.APIKey{
+		{ID: "key-1", TenantID: "tenant-1", KeyHash: "secret-hash", KeyHint: "abcd", Active: true, LastUsedAt: &used},
+		{ID: "key-2", TenantID: "tenant-1", KeyHash: "other-hash"},
+	}}
+	billingStore := &mockBillingStore{
+		getUsageByKeyFunc: func(ctx context.Context, tenantID string, from, to time.Time) ([]billing.KeyUsage, error) {
+			return []billing.KeyUsage{{APIKeyID: "key-1", Requests: 3, InputTokens: 30, OutputTokens: 12, CostUSD: 0.5}}, nil
+		},
+	}
+	h := NewHandler(NewRouter(nil), billingStore, ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"), WithAPIKeys(keys))
+
+	req := httptest.NewRequest("GET", "/v1/keys", nil)
+	req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+	w := httptest.NewRecorder()
+	h.HandleListKeys(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Keys []keySummary `json:"keys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(resp.Keys))
+	}
+	first := resp.Keys[0]
+	if first.Key != "****abcd" || first.LastUsedAt == nil || !first.LastUsedAt.Equal(used) {
+		t.Errorf("Unexpected first key: %+v", first)
+	}
+	if first.Usage.Requests != 3 || first.Usage.CostUSD != 0.5 {
+		t.Errorf("Expected usage for key-1, got %+v", first.Usage)
+	}
+	if resp.Keys[1].Usage.Requests != 0 || resp.Keys[1].LastUsedAt != nil {
+		t.Errorf("Expected an unused second key, got %+v", resp.Keys[1])
+	}
+	if body := w.Body.String(); strings.Contains(body, "secret-hash") {
+		t.Errorf("Key hashes must not be returned: %s", body)
+	}
+}
+
+func TestHandleListKeys_Unauthorized(t *testing.T) {
+	h, _ := setupTest(nil, true)
+	w := httptest.NewRecorder()
+	h.HandleListKeys(w, httptest.NewRequest("GET", "/v1/keys", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
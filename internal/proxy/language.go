@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/language"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Language enforcement actions reported to clients.
+const (
+	languageActionRetried    = "retried"
+	languageActionTranslated = "translated"
+)
+
+// languageOutcome reports what enforcing the tenant's output language did
+// to a response, with the extra upstream usage it cost.
+type languageOutcome struct {
+	Required     string  `json:"required"`
+	Detected     string  `json:"detected"`
+	Action       string  `json:"action"`
+	Final        string  `json:"final,omitempty"` // language after the action, when detected
+	InputTokens  int     `json:"extra_prompt_tokens"`
+	OutputTokens int     `json:"extra_completion_tokens"`
+	CostUSD      float64 `json:"extra_cost_usd"`
+}
+
+// enforceLanguage checks resp against c's tenant's required output language
+// and, when it doesn't match, retries or translates it. Each extra upstream
+// call is billed to the tenant as its own usage row marked with its stage.
+// On failure the original response is kept. outcome is nil when nothing was
+// done.
+func (h *Handler) enforceLanguage(ctx context.Context, c *call, resp *provider.Response) (*provider.Response, *languageOutcome) {
+	policy := c.settings.OutputLanguage
+	if policy == nil || resp.Content == "" {
+		return resp, nil
+	}
+	if err := policy.Validate(); err != nil {
+		c.log.Warn("language: invalid policy", "err", err)
+		return resp, nil
+	}
+	detected := language.Detect(resp.Content)
+	if detected == "" || detected == policy.Language {
+		return resp, nil
+	}
+
+	out := &languageOutcome{Required: policy.Language, Detected: detected}
+	var fixed *provider.Response
+	var err error
+	if policy.Mode == language.ModeTranslate {
+		out.Action = languageActionTranslated
+		fixed, err = h.translate(ctx, c, policy, resp, out)
+	} else {
+		out.Action = languageActionRetried
+		fixed, err = h.retryInLanguage(ctx, c, policy, out)
+	}
+	if err != nil {
+		c.log.Warn("language: enforcement failed", "mode", policy.Mode, "err", err)
+		return resp, nil
+	}
+	out.Final = language.Detect(fixed.Content)
+	h.metrics.recordLanguageEnforced(ctx, c.tenantID, out.Action)
+	return fixed, out
+}
+
+// retryInLanguage re-runs c's request once with an instruction to answer in
+// the required language.
+func (h *Handler) retryInLanguage(ctx context.Context, c *call, policy *language.Policy, out *languageOutcome) (*provider.Response, error) {
+	req := *c.req
+	req.Messages = withSystemInstruction(c.req.Messages, language.Instruction(policy.Language))
+	start := time.Now()
+	resp, served, err := h.execute(ctx, &req, c.provider, c.settings.ManagedTools)
+	if err != nil {
+		return nil, err
+	}
+	h.logStageUsage(ctx, c, billing.StageLanguageRetry, served, h.router.ModelFor(&req, served), resp, time.Since(start), out)
+	return resp, nil
+}
+
+// translate passes resp's content through the policy's translation model.
+// Everything else about resp, such as tool calls, is kept.
+func (h *Handler) translate(ctx context.Context, c *call, policy *language.Policy, resp *provider.Response, out *languageOutcome) (*provider.Response, error) {
+	req := &provider.Request{
+		Model: policy.TranslationModel,
+		Messages: []provider.Message{
+			{Role: "system", Content: language.TranslationPrompt(policy.Language)},
+			{Role: "user", Content: resp.Content},
+		},
+		TenantID:  c.req.TenantID,
+		APIKeyID:  c.req.APIKeyID,
+		RequestID: c.req.RequestID,
+	}
+	p, err := h.router.Route(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	translated, served, err := h.router.ExecuteWithFallback(ctx, req, p)
+	if err != nil {
+		return nil, err
+	}
+	h.logStageUsage(ctx, c, billing.StageTranslation, served, h.router.ModelFor(req, served), translated, time.Since(start), out)
+
+	fixed := *resp
+	fixed.Content = translated.Content
+	return &fixed, nil
+}
+
+// logStageUsage bills an extra upstream call made for c by stage and adds
+// it to out, when given.
+func (h *Handler) logStageUsage(ctx context.Context, c *call, stage string, p provider.Provider, model string, resp *provider.Response, latency time.Duration, out *languageOutcome) {
+	costUSD := h.router.cost(p, model, resp.InputTokens, resp.OutputTokens)
+	if out != nil {
+		out.InputTokens += resp.InputTokens
+		out.OutputTokens += resp.OutputTokens
+		out.CostUSD += costUSD
+	}
+	h.metrics.recordUsage(ctx, c.tenantID, p.Name(), resp.Model, resp.InputTokens, resp.OutputTokens, costUSD)
+	h.usage.Record(ctx, &billing.UsageLog{
+		TenantID:     c.tenantID,
+		APIKeyID:     c.req.APIKeyID,
+		RequestID:    c.requestID,
+		Provider:     p.Name(),
+		Model:        resp.Model,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		CostUSD:      costUSD,
+		LatencyMs:    latency.Milliseconds(),
+		Stage:        stage,
+		Synthetic:    c.req.Synthetic,
+	})
+}
+
+// withSystemInstruction returns messages with instruction appended to the
+// leading system message, or prepended as one when there is none.
+func withSystemInstruction(messages []provider.Message, instruction string) []provider.Message {
+	out := make([]provider.Message, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		first := messages[0]
+		first.Content += "\n\n" + instruction
+		return append(append(out, first), messages[1:]...)
+	}
+	out = append(out, provider.Message{Role: "system", Content: instruction})
+	return append(out, messages...)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/language"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	spanishAnswer = "La respuesta corta es que puedes cancelar el pedido desde la página de tu cuenta."
+	englishAnswer = "The short answer is that you can cancel the order from your account page."
+)
+
+// spanishProvider answers in Spanish unless told which language to use;
+// its translation model answers in English.
+type spanishProvider struct {
+	MockProvider
+}
+
+func (p *spanishProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	content := spanishAnswer
+	if req.Model == "translator" || strings.Contains(req.Messages[0].Content, "respond only in English") {
+		content = englishAnswer
+	}
+	return &provider.Response{Content: content, Provider: p.name, Model: req.Model, InputTokens: 10, OutputTokens: 20}, nil
+}
+
+func completeWithLanguage(t *testing.T, policy *language.Policy) (map[string]any, []*billing.UsageLog) {
+	t.Helper()
+	p := &spanishProvider{MockProvider{name: "test-provider", cost: 0.001, supportedModels: []string{"gpt-4", "translator"}}}
+	var mu sync.Mutex
+	var logs []*billing.UsageLog
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, log)
+		return nil
+	}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), b,
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{OutputLanguage: policy}}))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"¿Cómo cancelo mi pedido?"}]}`))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+	_ = h.usage.Flush(context.Background())
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return body, logs
+}
+
+func messageContent(body map[string]any) string {
+	return body["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)["content"].(string)
+}
+
+func TestHandleComplete_RetriesInRequiredLanguage(t *testing.T) {
+	body, logs := completeWithLanguage(t, &language.Policy{Language: "en"})
+	if got := messageContent(body); got != englishAnswer {
+		t.Errorf("Expected the retried English answer, got %q", got)
+	}
+	out, _ := body["output_language"].(map[string]any)
+	if out["action"] != languageActionRetried || out["detected"] != "es" || out["extra_cost_usd"].(float64) == 0 {
+		t.Errorf("Expected the retry reported with its cost, got %v", body["output_language"])
+	}
+	if len(logs) != 2 {
+		t.Fatalf("Expected the request and its retry billed, got %d usage logs", len(logs))
+	}
+	stages := map[string]bool{logs[0].Stage: true, logs[1].Stage: true}
+	if !stages[""] || !stages[billing.StageLanguageRetry] {
+		t.Errorf("Expected one retry row, got %+v and %+v", logs[0], logs[1])
+	}
+}
+
+func TestHandleComplete_TranslatesIntoRequiredLanguage(t *testing.T) {
+	body, logs := completeWithLanguage(t, &language.Policy{Language: "en", Mode: language.ModeTranslate, TranslationModel: "translator"})
+	if got := messageContent(body); got != englishAnswer {
+		t.Errorf("Expected the translated answer, got %q", got)
+	}
+	if out, _ := body["output_language"].(map[string]any); out["action"] != languageActionTranslated {
+		t.Errorf("Expected a translation, got %v", body["output_language"])
+	}
+	var translation *billing.UsageLog
+	for _, l := range logs {
+		if l.Stage == billing.StageTranslation {
+			translation = l
+		}
+	}
+	if len(logs) != 2 || translation == nil || translation.Model != "translator" {
+		t.Errorf("Expected the translation billed separately, got %d logs", len(logs))
+	}
+}
+
+func TestHandleComplete_KeepsMatchingLanguage(t *testing.T) {
+	body, logs := completeWithLanguage(t, &language.Policy{Language: "es"})
+	if _, ok := body["output_language"]; ok || messageContent(body) != spanishAnswer {
+		t.Errorf("Expected the answer untouched, got %v", body)
+	}
+	if len(logs) != 1 {
+		t.Errorf("Expected no extra calls, got %d usage logs", len(logs))
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// RequestLimits bound what a completion request may ask for before it costs
+// anything upstream. Zero values disable a limit. Message counts are capped
+// separately by WithMaxTurns.
+type RequestLimits struct {
+	MaxBodyBytes int64 // request body size
+	MaxChars     int   // characters across message contents and tool call arguments
+	MaxTokens    int   // ceiling on max_tokens
+}
+
+// WithRequestLimits rejects completions over limits: 413 for oversized
+// bodies, 400 for the rest.
+func WithRequestLimits(limits RequestLimits) Option {
+	return func(h *Handler) {
+		h.limits = limits
+	}
+}
+
+// limitError is a request over one of the RequestLimits. Code and the
+// numbers let clients tell which limit they hit without parsing Message.
+type limitError struct {
+	Code    string
+	Message string
+	Param   string
+	Limit   int64
+	Actual  int64 // unknown for oversized bodies
+	status  int
+}
+
+func (e *limitError) Error() string { return e.Message }
+
+func (e *limitError) write(w http.ResponseWriter) {
+	details := map[string]any{"limit": e.Limit}
+	if e.Actual > 0 {
+		details["actual"] = e.Actual
+	}
+	apierror.WriteError(w, e.status, apierror.Error{
+		Message: e.Message,
+		Code:    e.Code,
+		Param:   e.Param,
+		Details: details,
+	})
+}
+
+func bodyTooLarge(limit int64) *limitError {
+	return &limitError{
+		Code:    "request_too_large",
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+		Limit:   limit,
+		status:  http.StatusRequestEntityTooLarge,
+	}
+}
+
+// check applies the limits on a decoded request's contents.
+func (l RequestLimits) check(req *provider.Request) *limitError {
+	if l.MaxTokens > 0 && req.MaxTokens > l.MaxTokens {
+		return &limitError{
+			Code:    "max_tokens_too_large",
+			Message: fmt.Sprintf("max_tokens is %d, maximum is %d", req.MaxTokens, l.MaxTokens),
+			Param:   "max_tokens",
+			Limit:   int64(l.MaxTokens),
+			Actual:  int64(req.MaxTokens),
+			status:  http.StatusBadRequest,
+		}
+	}
+	if l.MaxChars > 0 {
+		if n := promptChars(req); n > l.MaxChars {
+			return &limitError{
+				Code:    "prompt_too_long",
+				Message: fmt.Sprintf("messages hold %d characters, maximum is %d", n, l.MaxChars),
+				Param:   "messages",
+				Limit:   int64(l.MaxChars),
+				Actual:  int64(n),
+				status:  http.StatusBadRequest,
+			}
+		}
+	}
+	return nil
+}
+
+// promptChars counts the characters of req's message contents and tool call
+// arguments.
+func promptChars(req *provider.Request) int {
+	n := 0
+	for _, m := range req.Messages {
+		n += utf8.RuneCountInString(m.Content)
+		for _, tc := range m.ToolCalls {
+			n += utf8.RuneCountInString(tc.Function.Arguments)
+		}
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func setupLimitsTest(limits RequestLimits) *Handler {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"),
+		WithRequestLimits(limits))
+	return h
+}
+
+func limitedRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	return req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+}
+
+func TestHandleComplete_RequestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits RequestLimits
+		body   string
+		status int
+		code   string
+		actual int64
+	}{
+		{
+			name:   "body too large",
+			limits: RequestLimits{MaxBodyBytes: 64},
+			body:   `{"model":"gpt-4","messages":[{"role":"user","content":"` + strings.Repeat("a", 100) + `"}]}`,
+			status: http.StatusRequestEntityTooLarge,
+			code:   "request_too_large",
+		},
+		{
+			name:   "too many characters",
+			limits: RequestLimits{MaxChars: 10},
+			body:   `{"model":"gpt-4","messages":[{"role":"system","content":"héllo"},{"role":"user","content":"world!"}]}`,
+			status: http.StatusBadRequest,
+			code:   "prompt_too_long",
+			actual: 11,
+		},
+		{
+			name:   "max_tokens over ceiling",
+			limits: RequestLimits{MaxTokens: 4096},
+			body:   `{"model":"gpt-4","max_tokens":8192,"messages":[{"role":"user","content":"hi"}]}`,
+			status: http.StatusBadRequest,
+			code:   "max_tokens_too_large",
+			actual: 8192,
+		},
+		{
+			name:   "within limits",
+			limits: RequestLimits{MaxBodyBytes: 1024, MaxChars: 10, MaxTokens: 4096},
+			body:   `{"model":"gpt-4","max_tokens":4096,"messages":[{"role":"user","content":"hi"}]}`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := setupLimitsTest(tt.limits)
+			w := httptest.NewRecorder()
+			h.HandleComplete(w, limitedRequest(tt.body))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.code == "" {
+				return
+			}
+			var got struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+				Limit  int64 `json:"limit"`
+				Actual int64 `json:"actual"`
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &got)
+			if got.Error.Code != tt.code || got.Limit == 0 || got.Actual != tt.actual || got.Error.Message == "" {
+				t.Errorf("Unexpected error body: %s", w.Body.String())
+			}
+		})
+	}
+}
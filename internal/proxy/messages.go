@@ -0,0 +1,478 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// messagesRequest is an Anthropic Messages API request.
+type messagesRequest struct {
+	Model       string          `json:"model"`
+	MaxTokens   int             `json:"max_tokens"`
+	System      json.RawMessage `json:"system,omitempty"` // a string or text blocks
+	Messages    []messagesTurn  `json:"messages"`
+	Temperature float64         `json:"temperature,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+	Tools       []messagesTool  `json:"tools,omitempty"`
+	ToolChoice  *struct {
+		Type string `json:"type"` // auto, any, tool or none
+		Name string `json:"name,omitempty"`
+	} `json:"tool_choice,omitempty"`
+}
+
+type messagesTurn struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // a string or content blocks
+}
+
+// messagesBlock is a content block: text, tool_use (ID, Name, Input) or
+// tool_result (ToolUseID, Content).
+type messagesBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+}
+
+type messagesTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// HandleMessages serves the Anthropic Messages API. Requests are converted
+// to chat completions and go through the same pipeline, so they can be
+// served by any provider; responses, streamed or not, and errors are
+// converted back.
+func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
+	mw := &messagesWriter{w: w}
+	defer mw.finish()
+	if f, ok := w.(http.Flusher); ok {
+		mw.flusher = f
+	}
+
+	if h.limits.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxBodyBytes)
+	}
+	var in messagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			bodyTooLarge(tooLarge.Limit).write(mw)
+			return
+		}
+		apierror.Write(mw, http.StatusBadRequest, "", "invalid request body")
+		return
+	}
+	req, err := in.toRequest()
+	if err != nil {
+		apierror.Write(mw, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	body, _ := json.Marshal(req)
+	r.Body, r.ContentLength = io.NopCloser(bytes.NewReader(body)), int64(len(body))
+
+	if in.Stream {
+		h.HandleCompleteStream(mw, r)
+		return
+	}
+	h.HandleComplete(mw, r)
+}
+
+// toRequest converts m to a chat completion request. Tool results, which
+// Anthropic sends as user turn blocks, become tool messages.
+func (m *messagesRequest) toRequest() (*provider.Request, error) {
+	if m.MaxTokens <= 0 {
+		return nil, fmt.Errorf("max_tokens is required")
+	}
+	req := &provider.Request{Model: m.Model, MaxTokens: m.MaxTokens, Temperature: m.Temperature, Stream: m.Stream}
+
+	if len(m.System) > 0 {
+		system, err := blocksText(m.System)
+		if err != nil {
+			return nil, fmt.Errorf("system: %w", err)
+		}
+		req.Messages = append(req.Messages, provider.Message{Role: "system", Content: system})
+	}
+	for i, turn := range m.Messages {
+		if turn.Role != "user" && turn.Role != "assistant" {
+			return nil, fmt.Errorf("messages.%d: invalid role %q: want user or assistant", i, turn.Role)
+		}
+		blocks, err := decodeBlocks(turn.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages.%d: %w", i, err)
+		}
+		msg := provider.Message{Role: turn.Role}
+		var text []string
+		for _, b := range blocks {
+			switch {
+			case b.Type == "text":
+				text = append(text, b.Text)
+			case b.Type == "tool_use" && turn.Role == "assistant":
+				args := "{}"
+				if len(b.Input) > 0 {
+					args = string(b.Input)
+				}
+				msg.ToolCalls = append(msg.ToolCalls, provider.ToolCall{
+					ID:       b.ID,
+					Type:     "function",
+					Function: provider.ToolCallFunction{Name: b.Name, Arguments: args},
+				})
+			case b.Type == "tool_result" && turn.Role == "user":
+				result, err := blocksText(b.Content)
+				if err != nil {
+					return nil, fmt.Errorf("messages.%d: tool_result: %w", i, err)
+				}
+				req.Messages = append(req.Messages, provider.Message{Role: "tool", Content: result, ToolCallID: b.ToolUseID})
+			default:
+				return nil, fmt.Errorf("messages.%d: unsupported %s content block %q", i, turn.Role, b.Type)
+			}
+		}
+		msg.Content = strings.Join(text, "\n\n")
+		if msg.Content != "" || len(msg.ToolCalls) > 0 {
+			req.Messages = append(req.Messages, msg)
+		}
+	}
+
+	for _, t := range m.Tools {
+		req.Tools = append(req.Tools, provider.Tool{
+			Type:     "function",
+			Function: provider.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.InputSchema},
+		})
+	}
+	if tc := m.ToolChoice; tc != nil {
+		var choice any
+		switch tc.Type {
+		case "auto", "none":
+			choice = tc.Type
+		case "any":
+			choice = "required"
+		case "tool":
+			choice = map[string]any{"type": "function", "function": map[string]string{"name": tc.Name}}
+		default:
+			return nil, fmt.Errorf("invalid tool_choice type %q", tc.Type)
+		}
+		req.ToolChoice, _ = json.Marshal(choice)
+	}
+	return req, nil
+}
+
+// decodeBlocks reads content that is either a string or content blocks.
+func decodeBlocks(raw json.RawMessage) ([]messagesBlock, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return []messagesBlock{{Type: "text", Text: s}}, nil
+	}
+	var blocks []messagesBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil, fmt.Errorf("content must be a string or content blocks")
+	}
+	return blocks, nil
+}
+
+// blocksText joins content that may only hold text.
+func blocksText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	blocks, err := decodeBlocks(raw)
+	if err != nil {
+		return "", err
+	}
+	text := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type != "text" {
+			return "", fmt.Errorf("unsupported content block %q", b.Type)
+		}
+		text = append(text, b.Text)
+	}
+	return strings.Join(text, "\n\n"), nil
+}
+
+// stopReasons maps finish reasons to Anthropic stop reasons.
+var stopReasons = map[string]string{
+	provider.FinishStop:          "end_turn",
+	provider.FinishLength:        "max_tokens",
+	provider.FinishToolCalls:     "tool_use",
+	provider.FinishContentFilter: "refusal",
+}
+
+func stopReason(finish string) string {
+	if reason, ok := stopReasons[finish]; ok {
+		return reason
+	}
+	return "end_turn"
+}
+
+// messagesErrorType maps an error sent with status to Anthropic's types.
+func messagesErrorType(status int, openAIType string) string {
+	switch {
+	case status == http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case status == http.StatusServiceUnavailable:
+		return "overloaded_error"
+	}
+	switch openAIType {
+	case apierror.TypeServer:
+		return "api_error"
+	case apierror.TypeInsufficientQuota:
+		return "billing_error"
+	case "":
+		return "api_error"
+	}
+	return openAIType
+}
+
+// messagesError converts an apierror envelope to an Anthropic error.
+func messagesError(status int, envelope []byte) map[string]any {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(envelope, &e)
+	return map[string]any{
+		"type":  "error",
+		"error": map[string]string{"type": messagesErrorType(status, e.Error.Type), "message": e.Error.Message},
+	}
+}
+
+// messagesContent converts a completion's reply to content blocks.
+func messagesContent(content string, toolCalls []provider.ToolCall) []messagesBlock {
+	blocks := []messagesBlock{}
+	if content != "" {
+		blocks = append(blocks, messagesBlock{Type: "text", Text: content})
+	}
+	for _, tc := range toolCalls {
+		input := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, messagesBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+	}
+	return blocks
+}
+
+// messagesWriter converts what the completion handlers write to the
+// Messages API: a buffered JSON body when the handler finishes, or each
+// frame of an event stream as it's written.
+type messagesWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	status  int
+	body    bytes.Buffer
+	stream  *messagesStream // set once a stream starts
+}
+
+func (m *messagesWriter) Header() http.Header {
+	return m.w.Header()
+}
+
+func (m *messagesWriter) WriteHeader(status int) {
+	if m.status != 0 {
+		return
+	}
+	m.status = status
+	if status == http.StatusOK && m.w.Header().Get("Content-Type") == "text/event-stream" {
+		m.stream = &messagesStream{w: m.w, block: -1}
+		m.w.WriteHeader(status)
+	}
+}
+
+func (m *messagesWriter) Write(b []byte) (int, error) {
+	if m.status == 0 {
+		m.WriteHeader(http.StatusOK)
+	}
+	if m.stream != nil {
+		m.stream.write(b)
+		return len(b), nil
+	}
+	return m.body.Write(b)
+}
+
+func (m *messagesWriter) Flush() {
+	if m.stream != nil && m.flusher != nil {
+		m.flusher.Flush()
+	}
+}
+
+func (m *messagesWriter) Unwrap() http.ResponseWriter {
+	return m.w
+}
+
+// finish sends the converted body of a response that wasn't streamed.
+func (m *messagesWriter) finish() {
+	if m.stream != nil || m.status == 0 {
+		return
+	}
+	out := messagesError(m.status, m.body.Bytes())
+	if m.status == http.StatusOK {
+		var resp struct {
+			ID      string `json:"id"`
+			Model   string `json:"model"`
+			Choices []struct {
+				Message struct {
+					Content   string              `json:"content"`
+					ToolCalls []provider.ToolCall `json:"tool_calls"`
+				} `json:"message"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage chunkUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(m.body.Bytes(), &resp); err != nil || len(resp.Choices) == 0 {
+			m.status = http.StatusInternalServerError
+			out = messagesError(m.status, apierror.Marshal(m.status, apierror.Error{Message: "invalid completion response"}))
+		} else {
+			choice := resp.Choices[0]
+			out = map[string]any{
+				"id":            resp.ID,
+				"type":          "message",
+				"role":          "assistant",
+				"model":         resp.Model,
+				"content":       messagesContent(choice.Message.Content, choice.Message.ToolCalls),
+				"stop_reason":   stopReason(choice.FinishReason),
+				"stop_sequence": nil,
+				"usage":         map[string]int{"input_tokens": resp.Usage.PromptTokens, "output_tokens": resp.Usage.CompletionTokens},
+			}
+		}
+	}
+	m.w.Header().Set("Content-Type", "application/json")
+	m.w.WriteHeader(m.status)
+	_ = json.NewEncoder(m.w).Encode(out)
+}
+
+// messagesStream converts chat.completion.chunk frames to Messages API
+// stream events: message_start, then a content block per text run or tool
+// call, then message_delta with the stop reason and usage, and
+// message_stop.
+type messagesStream struct {
+	w       io.Writer
+	pending []byte // a partial frame
+	started bool
+	block   int    // the open content block's index, -1 when none is
+	text    bool   // whether the open block is text
+	next    int    // the next block's index
+	stop    string // the finish reason, once sent
+	usage   chunkUsage
+}
+
+func (s *messagesStream) write(b []byte) {
+	s.pending = append(s.pending, b...)
+	for {
+		i := bytes.Index(s.pending, []byte("\n\n"))
+		if i < 0 {
+			return
+		}
+		frame := string(s.pending[:i])
+		s.pending = s.pending[i+2:]
+		s.frame(frame)
+	}
+}
+
+func (s *messagesStream) frame(frame string) {
+	var event, data string
+	for line := range strings.SplitSeq(frame, "\n") {
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+	switch {
+	case event == "error":
+		s.emit("error", messagesError(0, []byte(data)))
+	case data == "[DONE]":
+		s.closeBlock()
+		s.emit("message_delta", map[string]any{
+			"type":  "message_delta",
+			"delta": map[string]any{"stop_reason": stopReason(s.stop), "stop_sequence": nil},
+			"usage": map[string]int{"input_tokens": s.usage.PromptTokens, "output_tokens": s.usage.CompletionTokens},
+		})
+		s.emit("message_stop", map[string]string{"type": "message_stop"})
+	case data != "":
+		var chunk streamChunk
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			return
+		}
+		s.chunk(&chunk)
+	}
+}
+
+func (s *messagesStream) chunk(chunk *streamChunk) {
+	if !s.started {
+		s.started = true
+		s.emit("message_start", map[string]any{
+			"type": "message_start",
+			"message": map[string]any{
+				"id":            chunk.ID,
+				"type":          "message",
+				"role":          "assistant",
+				"model":         chunk.Model,
+				"content":       []any{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         map[string]int{"input_tokens": 0, "output_tokens": 0},
+			},
+		})
+	}
+	if chunk.Usage != nil {
+		s.usage = *chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" {
+			if s.block < 0 || !s.text {
+				s.openBlock(map[string]any{"type": "text", "text": ""})
+			}
+			s.emit("content_block_delta", map[string]any{
+				"type":  "content_block_delta",
+				"index": s.block,
+				"delta": map[string]string{"type": "text_delta", "text": choice.Delta.Content},
+			})
+		}
+		// Tool calls arrive whole, so each is a block of its own.
+		for _, tc := range choice.Delta.ToolCalls {
+			s.openBlock(map[string]any{"type": "tool_use", "id": tc.ID, "name": tc.Function.Name, "input": map[string]any{}})
+			s.emit("content_block_delta", map[string]any{
+				"type":  "content_block_delta",
+				"index": s.block,
+				"delta": map[string]string{"type": "input_json_delta", "partial_json": tc.Function.Arguments},
+			})
+			s.closeBlock()
+		}
+		if choice.FinishReason != nil {
+			s.stop = *choice.FinishReason
+		}
+	}
+}
+
+func (s *messagesStream) openBlock(block map[string]any) {
+	s.closeBlock()
+	s.block, s.text = s.next, block["type"] == "text"
+	s.next++
+	s.emit("content_block_start", map[string]any{"type": "content_block_start", "index": s.block, "content_block": block})
+}
+
+func (s *messagesStream) closeBlock() {
+	if s.block < 0 {
+		return
+	}
+	s.emit("content_block_stop", map[string]any{"type": "content_block_stop", "index": s.block})
+	s.block = -1
+}
+
+func (s *messagesStream) emit(event string, payload any) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestMessagesRequest_ToRequest(t *testing.T) {
+	var in messagesRequest
+	body := `{
+		"model": "claude-sonnet-4",
+		"max_tokens": 256,
+		"system": [{"type": "text", "text": "Be brief."}],
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "Sunny"},
+				{"type": "text", "text": "Thanks"}
+			]}
+		],
+		"tools": [{"name": "get_weather", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "any"}
+	}`
+	if err := json.Unmarshal([]byte(body), &in); err != nil {
+		t.Fatal(err)
+	}
+	req, err := in.toRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []provider.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", Content: "Checking.", ToolCalls: []provider.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city": "Paris"}`}},
+		}},
+		{Role: "tool", Content: "Sunny", ToolCallID: "toolu_1"},
+		{Role: "user", Content: "Thanks"},
+	}
+	if !reflect.DeepEqual(req.Messages, want) {
+		t.Errorf("Expected messages %+v, got %+v", want, req.Messages)
+	}
+	if req.MaxTokens != 256 || len(req.Tools) != 1 || req.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Expected max_tokens and tools to carry over, got %+v", req)
+	}
+	if mode, _ := req.ToolChoiceMode(); mode != "required" {
+		t.Errorf("Expected tool_choice any to become required, got %q", mode)
+	}
+
+	for _, body := range []string{
+		`{"model": "m", "messages": [{"role": "user", "content": "hi"}]}`,
+		`{"model": "m", "max_tokens": 1, "messages": [{"role": "user", "content": [{"type": "image"}]}]}`,
+		`{"model": "m", "max_tokens": 1, "messages": [{"role": "system", "content": "hi"}]}`,
+	} {
+		var in messagesRequest
+		_ = json.Unmarshal([]byte(body), &in)
+		if _, err := in.toRequest(); err == nil {
+			t.Errorf("Expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestHandleMessages(t *testing.T) {
+	h, _ := setupTest([]provider.Provider{&MockProvider{name: "openai", cost: 0.5, supportedModels: []string{"gpt-4"}}}, true)
+
+	body := `{"model":"gpt-4","max_tokens":64,"messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Type       string          `json:"type"`
+		Role       string          `json:"role"`
+		Model      string          `json:"model"`
+		Content    []messagesBlock `json:"content"`
+		StopReason string          `json:"stop_reason"`
+		Usage      map[string]int  `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != "message" || resp.Role != "assistant" || resp.Model != "gpt-4" || resp.StopReason != "end_turn" {
+		t.Errorf("Expected an assistant message, got %s", w.Body.String())
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "text" || resp.Content[0].Text != "mock" {
+		t.Errorf("Expected one text block, got %+v", resp.Content)
+	}
+	if resp.Usage["input_tokens"] != 10 || resp.Usage["output_tokens"] != 20 {
+		t.Errorf("Expected the completion's usage, got %v", resp.Usage)
+	}
+}
+
+func TestHandleMessages_Stream(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "openai", cost: 0.5, supportedModels: []string{"gpt-4"}},
+		chunks: []*provider.Chunk{
+			{Delta: "Hello"},
+			{Delta: " world"},
+			{ToolCalls: []provider.ToolCall{{ID: "call_1", Type: "function", Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}},
+			{Done: true, FinishReason: provider.FinishToolCalls, Usage: &provider.Usage{InputTokens: 5, OutputTokens: 7}},
+		},
+	}
+	h, _ := setupTest([]provider.Provider{p}, true)
+
+	body := `{"model":"gpt-4","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleMessages(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q: %s", ct, w.Body.String())
+	}
+	var events []string
+	var data []map[string]any
+	for frame := range strings.SplitSeq(strings.TrimSpace(w.Body.String()), "\n\n") {
+		event, payload, _ := strings.Cut(frame, "\n")
+		events = append(events, strings.TrimPrefix(event, "event: "))
+		var d map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(payload, "data: ")), &d); err != nil {
+			t.Fatalf("Expected JSON data, got %q", payload)
+		}
+		data = append(data, d)
+	}
+	want := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("Expected events %v, got %v", want, events)
+	}
+	if text := data[3]["delta"].(map[string]any)["text"]; text != " world" {
+		t.Errorf("Expected text deltas, got %v", data[3])
+	}
+	if block := data[5]["content_block"].(map[string]any); block["type"] != "tool_use" || block["name"] != "get_weather" || data[5]["index"] != 1.0 {
+		t.Errorf("Expected a tool_use block at index 1, got %v", data[5])
+	}
+	if args := data[6]["delta"].(map[string]any)["partial_json"]; args != `{"city":"Paris"}` {
+		t.Errorf("Expected the tool call's input, got %v", data[6])
+	}
+	delta := data[8]
+	if delta["delta"].(map[string]any)["stop_reason"] != "tool_use" || delta["usage"].(map[string]any)["output_tokens"] != 7.0 {
+		t.Errorf("Expected the stop reason and usage, got %v", delta)
+	}
+}
+
+func TestHandleMessages_Errors(t *testing.T) {
+	h, _ := setupTest([]provider.Provider{&MockProvider{name: "openai", supportedModels: []string{"gpt-4"}}}, true)
+	tests := []struct {
+		name   string
+		tenant string
+		body   string
+		status int
+		typ    string
+	}{
+		{"unauthorized", "", `{"model":"gpt-4","max_tokens":1,"messages":[]}`, http.StatusUnauthorized, "authentication_error"},
+		{"no max_tokens", "test-tenant", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"malformed", "test-tenant", `{`, http.StatusBadRequest, "invalid_request_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(tt.body))
+			if tt.tenant != "" {
+				req = req.WithContext(auth.WithTenantID(req.Context(), tt.tenant))
+			}
+			w := httptest.NewRecorder()
+			h.HandleMessages(w, req)
+
+			var resp struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != tt.status || resp.Type != "error" || resp.Error.Type != tt.typ || resp.Error.Message == "" {
+				t.Errorf("Expected %d %s, got %d: %s", tt.status, tt.typ, w.Code, w.Body.String())
+			}
+		})
+	}
+}
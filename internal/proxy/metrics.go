@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WithMeter records gateway metrics through meter instead of the global
+// meter provider.
+func WithMeter(meter metric.Meter) Option {
+	return func(h *Handler) {
+		h.meter = meter
+	}
+}
+
+// metrics holds the instruments behind /metrics. Everything is labeled by
+// tenant, provider and model so provider error spikes can be alerted on.
+type metrics struct {
+	requests    metric.Int64Counter
+	duration    metric.Float64Histogram
+	tokens      metric.Int64Counter
+	cost        metric.Float64Counter
+	rateLimited metric.Int64Counter
+	coalesced   metric.Int64Counter
+	language    metric.Int64Counter
+	derailed    metric.Int64Counter
+	guardrails  metric.Int64Counter
+	embeddings  metric.Int64Counter
+	ttft        metric.Float64Histogram
+	ttftAlerts  metric.Int64Counter
+	chunkGap    metric.Float64Histogram
+	chunkSize   metric.Int64Histogram
+	maxChunkGap metric.Float64Histogram
+}
+
+// chunkGapBuckets (seconds) resolve normal token cadence, tens of
+// milliseconds, as well as stalls of several seconds.
+var chunkGapBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+func newMetrics(meter metric.Meter, router *Router, ttft *ttftMonitor) *metrics {
+	m := &metrics{}
+	var err error
+	if m.requests, err = meter.Int64Counter("gateway.requests",
+		metric.WithDescription("Completion requests by response status")); err != nil {
+		slog.Warn("metrics: failed to create request counter", "err", err)
+	}
+	if m.duration, err = meter.Float64Histogram("gateway.request.duration",
+		metric.WithDescription("Completion request latency"),
+		metric.WithUnit("s")); err != nil {
+		slog.Warn("metrics: failed to create latency histogram", "err", err)
+	}
+	if m.tokens, err = meter.Int64Counter("gateway.tokens",
+		metric.WithDescription("Tokens billed, by direction")); err != nil {
+		slog.Warn("metrics: failed to create token counter", "err", err)
+	}
+	if m.cost, err = meter.Float64Counter("gateway.cost_usd",
+		metric.WithDescription("Upstream cost in USD")); err != nil {
+		slog.Warn("metrics: failed to create cost counter", "err", err)
+	}
+	if m.rateLimited, err = meter.Int64Counter("gateway.rate_limit.rejections",
+		metric.WithDescription("Requests rejected by the tenant rate limiter")); err != nil {
+		slog.Warn("metrics: failed to create rate-limit counter", "err", err)
+	}
+	if m.coalesced, err = meter.Int64Counter("gateway.requests.coalesced",
+		metric.WithDescription("Completions served from an identical request's upstream call")); err != nil {
+		slog.Warn("metrics: failed to create coalesced counter", "err", err)
+	}
+	if m.language, err = meter.Int64Counter("gateway.language.enforced",
+		metric.WithDescription("Responses retried or translated into the tenant's required language")); err != nil {
+		slog.Warn("metrics: failed to create language counter", "err", err)
+	}
+	if m.derailed, err = meter.Int64Counter("gateway.stream.derailed",
+		metric.WithDescription("Structured-output streams stopped for output that can't match the response_format")); err != nil {
+		slog.Warn("metrics: failed to create derailed stream counter", "err", err)
+	}
+	if m.guardrails, err = meter.Int64Counter("gateway.guardrail.violations",
+		metric.WithDescription("Guardrail rule matches on requests and responses")); err != nil {
+		slog.Warn("metrics: failed to create guardrail counter", "err", err)
+	}
+	if m.embeddings, err = meter.Int64Counter("gateway.retrieval.embedding_cache",
+		metric.WithDescription("Retrieval query embedding cache lookups, by result")); err != nil {
+		slog.Warn("metrics: failed to create embedding cache counter", "err", err)
+	}
+	if m.ttft, err = meter.Float64Histogram("gateway.stream.time_to_first_token",
+		metric.WithDescription("Time from request to the first streamed token"),
+		metric.WithUnit("s")); err != nil {
+		slog.Warn("metrics: failed to create time-to-first-token histogram", "err", err)
+	}
+	if m.ttftAlerts, err = meter.Int64Counter("gateway.stream.ttft_slo_alerts",
+		metric.WithDescription("Windows in which a provider/model fell below its time-to-first-token SLO")); err != nil {
+		slog.Warn("metrics: failed to create time-to-first-token alert counter", "err", err)
+	}
+	if m.chunkGap, err = meter.Float64Histogram("gateway.stream.chunk_gap",
+		metric.WithDescription("Time between consecutive chunks of sampled streams"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(chunkGapBuckets...)); err != nil {
+		slog.Warn("metrics: failed to create chunk gap histogram", "err", err)
+	}
+	if m.chunkSize, err = meter.Int64Histogram("gateway.stream.chunk_size",
+		metric.WithDescription("Content bytes per chunk of sampled streams"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096)); err != nil {
+		slog.Warn("metrics: failed to create chunk size histogram", "err", err)
+	}
+	if m.maxChunkGap, err = meter.Float64Histogram("gateway.stream.max_chunk_gap",
+		metric.WithDescription("Longest time between chunks of each sampled stream"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(chunkGapBuckets...)); err != nil {
+		slog.Warn("metrics: failed to create max chunk gap histogram", "err", err)
+	}
+	if ttft != nil {
+		_, err = meter.Int64ObservableGauge("gateway.stream.ttft_slo_breached",
+			metric.WithDescription("1 while a provider/model is below its time-to-first-token SLO in the current window"),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				for key, breached := range ttft.breached(time.Now()) {
+					var v int64
+					if breached {
+						v = 1
+					}
+					o.Observe(v, metric.WithAttributes(attribute.String("provider", key.provider), attribute.String("model", key.model)))
+				}
+				return nil
+			}))
+		if err != nil {
+			slog.Warn("metrics: failed to create time-to-first-token SLO gauge", "err", err)
+		}
+	}
+
+	// Stream goroutines by what pumps them: a provider's name or "router".
+	// Active ones that never finish are leaks.
+	_, err = meter.Int64ObservableGauge("gateway.stream.goroutines",
+		metric.WithDescription("Running stream goroutines"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for name, c := range provider.Streams.Snapshot() {
+				o.Observe(c.Active, metric.WithAttributes(attribute.String("name", name)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Warn("metrics: failed to create stream goroutine gauge", "err", err)
+	}
+	_, err = meter.Int64ObservableCounter("gateway.stream.goroutines.started",
+		metric.WithDescription("Stream goroutines started"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for name, c := range provider.Streams.Snapshot() {
+				o.Observe(c.Started, metric.WithAttributes(attribute.String("name", name)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Warn("metrics: failed to create started stream goroutine counter", "err", err)
+	}
+	_, err = meter.Int64ObservableCounter("gateway.stream.goroutines.finished",
+		metric.WithDescription("Stream goroutines finished"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for name, c := range provider.Streams.Snapshot() {
+				o.Observe(c.Finished, metric.WithAttributes(attribute.String("name", name)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Warn("metrics: failed to create finished stream goroutine counter", "err", err)
+	}
+
+	// 0 closed, 1 half-open, 2 open, matching gobreaker.State.
+	_, err = meter.Int64ObservableGauge("gateway.circuit_breaker.state",
+		metric.WithDescription("Provider circuit breaker state: 0 closed, 1 half-open, 2 open"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for name, state := range router.BreakerStates() {
+				o.Observe(int64(state), metric.WithAttributes(attribute.String("provider", name)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Warn("metrics: failed to create circuit breaker gauge", "err", err)
+	}
+	return m
+}
+
+func (m *metrics) recordRequest(ctx context.Context, tenantID, providerName, model string, status int, elapsed time.Duration) {
+	attrs := metric.WithAttributes(
+		attribute.String("tenant", tenantID),
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+		attribute.String("status", strconv.Itoa(status)),
+	)
+	m.requests.Add(ctx, 1, attrs)
+	m.duration.Record(ctx, elapsed.Seconds(), attrs)
+}
+
+func (m *metrics) recordUsage(ctx context.Context, tenantID, providerName, model string, inputTokens, outputTokens int, costUSD float64) {
+	base := []attribute.KeyValue{
+		attribute.String("tenant", tenantID),
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	}
+	m.tokens.Add(ctx, int64(inputTokens), metric.WithAttributes(append(base, attribute.String("direction", "input"))...))
+	m.tokens.Add(ctx, int64(outputTokens), metric.WithAttributes(append(base, attribute.String("direction", "output"))...))
+	m.cost.Add(ctx, costUSD, metric.WithAttributes(base...))
+}
+
+func (m *metrics) recordTTFT(ctx context.Context, providerName, model string, ttft time.Duration) {
+	m.ttft.Record(ctx, ttft.Seconds(), metric.WithAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	))
+}
+
+func (m *metrics) recordChunkGap(ctx context.Context, providerName, model string, gap time.Duration) {
+	m.chunkGap.Record(ctx, gap.Seconds(), metric.WithAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	))
+}
+
+func (m *metrics) recordChunkSize(ctx context.Context, providerName, model string, size int) {
+	m.chunkSize.Record(ctx, int64(size), metric.WithAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	))
+}
+
+func (m *metrics) recordMaxChunkGap(ctx context.Context, providerName, model string, gap time.Duration) {
+	m.maxChunkGap.Record(ctx, gap.Seconds(), metric.WithAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	))
+}
+
+func (m *metrics) recordTTFTAlert(ctx context.Context, providerName, model string) {
+	m.ttftAlerts.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+	))
+}
+
+func (m *metrics) recordRateLimited(ctx context.Context, tenantID string) {
+	m.rateLimited.Add(ctx, 1, metric.WithAttributes(attribute.String("tenant", tenantID)))
+}
+
+func (m *metrics) recordCoalesced(ctx context.Context, tenantID, model string) {
+	m.coalesced.Add(ctx, 1, metric.WithAttributes(attribute.String("tenant", tenantID), attribute.String("model", model)))
+}
+
+func (m *metrics) recordLanguageEnforced(ctx context.Context, tenantID, action string) {
+	m.language.Add(ctx, 1, metric.WithAttributes(attribute.String("tenant", tenantID), attribute.String("action", action)))
+}
+
+func (m *metrics) recordStreamDerailed(ctx context.Context, tenantID, action string) {
+	m.derailed.Add(ctx, 1, metric.WithAttributes(attribute.String("tenant", tenantID), attribute.String("action", action)))
+}
+
+func (m *metrics) recordEmbeddingCache(ctx context.Context, tenantID, result string) {
+	m.embeddings.Add(ctx, 1, metric.WithAttributes(attribute.String("tenant", tenantID), attribute.String("result", result)))
+}
+
+func (m *metrics) recordGuardrailViolation(ctx context.Context, tenantID string, v guardrail.Violation) {
+	m.guardrails.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("tenant", tenantID),
+		attribute.String("rule", v.Rule),
+		attribute.String("stage", v.Stage),
+		attribute.String("action", v.Action),
+	))
+}
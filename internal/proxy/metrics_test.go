@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func setupMetricsTest(t *testing.T, p provider.Provider, allowed bool) (*Handler, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: allowed})
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithMeter(mp.Meter("test")))
+	return h, reader
+}
+
+func completionRequest(model string) *http.Request {
+	body, _ := json.Marshal(map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": "hello"}},
+	})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	return req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+}
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	out := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+func attr(set attribute.Set, key attribute.Key) string {
+	v, _ := set.Value(key)
+	return v.Emit()
+}
+
+func TestMetrics_Completion(t *testing.T) {
+	p := &MockProvider{name: "test-provider", cost: 0.01, supportedModels: []string{"gpt-4"}}
+	h, reader := setupMetricsTest(t, p, true)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, completionRequest("gpt-4"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	got := collect(t, reader)
+
+	requests := got["gateway.requests"].(metricdata.Sum[int64])
+	if len(requests.DataPoints) != 1 || requests.DataPoints[0].Value != 1 {
+		t.Fatalf("requests = %+v", requests.DataPoints)
+	}
+	dp := requests.DataPoints[0]
+	for key, want := range map[attribute.Key]string{"tenant": "tenant-1", "provider": "test-provider", "model": "gpt-4", "status": "200"} {
+		if v := attr(dp.Attributes, key); v != want {
+			t.Errorf("request %s = %q, want %q", key, v, want)
+		}
+	}
+
+	if hist := got["gateway.request.duration"].(metricdata.Histogram[float64]); len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Errorf("duration = %+v", hist.DataPoints)
+	}
+
+	tokens := map[string]int64{}
+	for _, dp := range got["gateway.tokens"].(metricdata.Sum[int64]).DataPoints {
+		tokens[attr(dp.Attributes, "direction")] = dp.Value
+	}
+	if tokens["input"] != 10 || tokens["output"] != 20 {
+		t.Errorf("tokens = %v, want input 10 output 20", tokens)
+	}
+
+	cost := got["gateway.cost_usd"].(metricdata.Sum[float64])
+	if len(cost.DataPoints) != 1 || cost.DataPoints[0].Value != 0.1 {
+		t.Errorf("cost = %+v, want 0.1", cost.DataPoints)
+	}
+
+	breakers := got["gateway.circuit_breaker.state"].(metricdata.Gauge[int64])
+	if len(breakers.DataPoints) != 1 || breakers.DataPoints[0].Value != 0 || attr(breakers.DataPoints[0].Attributes, "provider") != "test-provider" {
+		t.Errorf("breaker state = %+v, want closed for test-provider", breakers.DataPoints)
+	}
+}
+
+func TestMetrics_UpstreamError(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}, completeErr: errors.New("upstream down")}
+	h, reader := setupMetricsTest(t, p, true)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, completionRequest("gpt-4"))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", w.Code)
+	}
+
+	requests := collect(t, reader)["gateway.requests"].(metricdata.Sum[int64])
+	if len(requests.DataPoints) != 1 || attr(requests.DataPoints[0].Attributes, "status") != "502" {
+		t.Errorf("requests = %+v, want one 502", requests.DataPoints)
+	}
+}
+
+func TestMetrics_RateLimited(t *testing.T) {
+	p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+	h, reader := setupMetricsTest(t, p, false)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, completionRequest("gpt-4"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+
+	rejections := collect(t, reader)["gateway.rate_limit.rejections"].(metricdata.Sum[int64])
+	if len(rejections.DataPoints) != 1 || rejections.DataPoints[0].Value != 1 ||
+		attr(rejections.DataPoints[0].Attributes, "tenant") != "tenant-1" {
+		t.Errorf("rejections = %+v, want one for tenant-1", rejections.DataPoints)
+	}
+}
+
+func TestMetrics_StreamGoroutines(t *testing.T) {
+	_, reader := setupMetricsTest(t, &MockProvider{name: "test-provider"}, true)
+
+	release, done := make(chan struct{}), make(chan struct{})
+	provider.Streams.Go("metrics-test", func() {
+		<-release
+		close(done)
+	})
+	counts := func() (active, started, finished int64) {
+		got := collect(t, reader)
+		value := func(dps []metricdata.DataPoint[int64]) int64 {
+			for _, dp := range dps {
+				if attr(dp.Attributes, "name") == "metrics-test" {
+					return dp.Value
+				}
+			}
+			return -1
+		}
+		return value(got["gateway.stream.goroutines"].(metricdata.Gauge[int64]).DataPoints),
+			value(got["gateway.stream.goroutines.started"].(metricdata.Sum[int64]).DataPoints),
+			value(got["gateway.stream.goroutines.finished"].(metricdata.Sum[int64]).DataPoints)
+	}
+
+	if active, started, finished := counts(); active != 1 || started != 1 || finished != 0 {
+		t.Errorf("Expected 1 running goroutine, got active %d, started %d, finished %d", active, started, finished)
+	}
+	close(release)
+	<-done
+	deadline := time.Now().Add(time.Second)
+	for provider.Streams.Snapshot()["metrics-test"].Active != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if active, started, finished := counts(); active != 0 || started != 1 || finished != 1 {
+		t.Errorf("Expected the goroutine finished, got active %d, started %d, finished %d", active, started, finished)
+	}
+}
@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/catalog"
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// WithProviderRegions records the regions each provider's models are
+// available in, for model metadata.
+func WithProviderRegions(regions map[string][]string) RouterOption {
+	return func(r *Router) {
+		r.regions = regions
+	}
+}
+
+// ModelPricing is a provider's price for a model in USD per million tokens.
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"input_per_million_tokens"`
+	OutputPerMillion float64 `json:"output_per_million_tokens"`
+}
+
+// ModelOffer is one provider serving a model.
+type ModelOffer struct {
+	Provider  string       `json:"provider"`
+	Model     string       `json:"model"` // differs from the requested ID for aliases
+	Pricing   ModelPricing `json:"pricing"`
+	Regions   []string     `json:"regions,omitempty"`
+	Available bool         `json:"available"` // the provider is routable: breaker not open, not disabled
+}
+
+// ModelMetadata describes a model across the providers that serve it. Limits
+// and capabilities are the ones every provider guarantees, so a client can
+// rely on them whichever provider the router picks.
+type ModelMetadata struct {
+	ID              string       `json:"id"`
+	Object          string       `json:"object"`
+	ContextWindow   int          `json:"context_window,omitempty"`
+	MaxOutputTokens int          `json:"max_output_tokens,omitempty"`
+	Capabilities    []string     `json:"capabilities"`
+	Providers       []ModelOffer `json:"providers"`
+	Regions         []string     `json:"regions"`
+	Deprecated      bool         `json:"deprecated"`
+	RetiresOn       string       `json:"retires_on,omitempty"`
+	Replacement     string       `json:"replacement,omitempty"`
+}
+
+// DescribeModel aggregates metadata for model, which may be an alias, from
+// the providers serving it. It reports false when no provider does.
+func (r *Router) DescribeModel(model string) (*ModelMetadata, bool) {
+	meta := &ModelMetadata{ID: model, Object: "model", Capabilities: []string{}, Regions: []string{}}
+	var infos []provider.ModelInfo
+	for _, p := range r.providers {
+		concrete, ok := r.servedAs(p, model)
+		if !ok {
+			continue
+		}
+		offer := ModelOffer{
+			Provider:  p.Name(),
+			Model:     concrete,
+			Pricing:   perMillion(r.Price(p, concrete)),
+			Regions:   r.regions[p.Name()],
+			Available: r.available(p.Name()),
+		}
+		meta.Providers = append(meta.Providers, offer)
+		for _, region := range offer.Regions {
+			if !slices.Contains(meta.Regions, region) {
+				meta.Regions = append(meta.Regions, region)
+			}
+		}
+		if mp, ok := p.(provider.ModelInfoProvider); ok {
+			if info, ok := mp.ModelInfo(concrete); ok {
+				infos = append(infos, info)
+			}
+		}
+	}
+	if len(meta.Providers) == 0 {
+		return nil, false
+	}
+	slices.Sort(meta.Regions)
+
+	for i, info := range infos {
+		if i == 0 {
+			meta.ContextWindow, meta.MaxOutputTokens = info.ContextWindow, info.MaxOutputTokens
+			meta.Capabilities = slices.Clone(info.Capabilities)
+		} else {
+			meta.ContextWindow = min(meta.ContextWindow, info.ContextWindow)
+			meta.MaxOutputTokens = min(meta.MaxOutputTokens, info.MaxOutputTokens)
+			meta.Capabilities = slices.DeleteFunc(meta.Capabilities, func(c string) bool {
+				return !slices.Contains(info.Capabilities, c)
+			})
+		}
+		// The earliest announced retirement is the one clients must plan for.
+		if info.RetiresOn != "" && (meta.RetiresOn == "" || info.RetiresOn < meta.RetiresOn) {
+			meta.Deprecated, meta.RetiresOn, meta.Replacement = true, info.RetiresOn, info.Replacement
+		}
+	}
+	return meta, true
+}
+
+// WithCatalog takes providers' models from c, so models discovered from
+// upstreams are routed to.
+func WithCatalog(c *catalog.Catalog) RouterOption {
+	return func(r *Router) {
+		r.catalog = c
+	}
+}
+
+// modelsOf returns the models p serves.
+func (r *Router) modelsOf(p provider.Provider) []string {
+	return r.catalog.Models(p)
+}
+
+// servedAs reports whether p serves model, directly or as an alias target,
+// and under which concrete name.
+func (r *Router) servedAs(p provider.Provider, model string) (string, bool) {
+	if targets, ok := r.aliases[model]; ok {
+		for _, t := range targets {
+			if t.Provider == p.Name() {
+				return t.Model, true
+			}
+		}
+		return "", false
+	}
+	if slices.Contains(r.modelsOf(p), model) {
+		return model, true
+	}
+	if ep, ok := p.(provider.EmbeddingsProvider); ok && slices.Contains(ep.EmbeddingModels(), model) {
+		return model, true
+	}
+	return "", false
+}
+
+// perMillion converts a per-token price, rounding away float noise.
+func perMillion(price pricing.Price) ModelPricing {
+	return ModelPricing{
+		InputPerMillion:  math.Round(price.Input*1e12) / 1e6,
+		OutputPerMillion: math.Round(price.Output*1e12) / 1e6,
+	}
+}
+
+// ListModels describes every model and alias the gateway serves, by ID.
+func (r *Router) ListModels() []*ModelMetadata {
+	var ids []string
+	for alias := range r.aliases {
+		ids = append(ids, alias)
+	}
+	for _, p := range r.providers {
+		ids = append(ids, r.modelsOf(p)...)
+		if ep, ok := p.(provider.EmbeddingsProvider); ok {
+			ids = append(ids, ep.EmbeddingModels()...)
+		}
+	}
+	slices.Sort(ids)
+
+	models := make([]*ModelMetadata, 0, len(ids))
+	for _, id := range slices.Compact(ids) {
+		if meta, ok := r.DescribeModel(id); ok {
+			models = append(models, meta)
+		}
+	}
+	return models
+}
+
+// HandleListModels serves GET /v1/models: HandleGetModel's description of
+// every model and alias, as an OpenAI-style list.
+func (h *Handler) HandleListModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"data":   h.router.ListModels(),
+	})
+}
+
+// HandleGetModel serves GET /v1/models/{id}: limits, pricing, capabilities,
+// providers, regions and deprecation status of one model or alias.
+func (h *Handler) HandleGetModel(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	meta, ok := h.router.DescribeModel(id)
+	if !ok {
+		writeModelNotFound(w, id)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(meta)
+}
+
+func writeModelNotFound(w http.ResponseWriter, model string) {
+	apierror.WriteError(w, http.StatusNotFound, apierror.Error{
+		Message: fmt.Sprintf("model %q is not served by this gateway", model),
+		Code:    ErrModelNotFound.Error(),
+		Param:   "model",
+	})
+}
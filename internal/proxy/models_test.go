@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/internal/catalog"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type infoProvider struct {
+	MockProvider
+	info map[string]provider.ModelInfo
+}
+
+func (p *infoProvider) ModelInfo(model string) (provider.ModelInfo, bool) {
+	info, ok := p.info[model]
+	return info, ok
+}
+
+func getModel(t *testing.T, router *Router, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{router: router}
+	req := httptest.NewRequest(http.MethodGet, "/v1/models/"+id, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	h.HandleGetModel(w, req)
+	return w
+}
+
+func TestHandleGetModel_AggregatesProviders(t *testing.T) {
+	openai := &infoProvider{
+		MockProvider: MockProvider{name: "openai", cost: 0.0000025, supportedModels: []string{"gpt-4o-mini"}},
+		info: map[string]provider.ModelInfo{"gpt-4o-mini": {
+			ContextWindow: 128000, MaxOutputTokens: 16384,
+			Capabilities: []string{provider.CapabilityChat, provider.CapabilityTools, provider.CapabilityVision},
+		}},
+	}
+	gemini := &infoProvider{
+		MockProvider: MockProvider{name: "gemini", cost: 0.000000075, supportedModels: []string{"gemini-1.5-flash"}},
+		info: map[string]provider.ModelInfo{"gemini-1.5-flash": {
+			ContextWindow: 1048576, MaxOutputTokens: 8192,
+			Capabilities: []string{provider.CapabilityChat, provider.CapabilityTools},
+			RetiresOn:    "2025-09-24", Replacement: "gemini-2.0-flash",
+		}},
+	}
+	router := NewRouter([]provider.Provider{openai, gemini},
+		WithModelAliases(map[string][]ModelTarget{
+			"fast": {{Provider: "openai", Model: "gpt-4o-mini"}, {Provider: "gemini", Model: "gemini-1.5-flash"}},
+		}),
+		WithProviderRegions(map[string][]string{"openai": {"us", "eu"}, "gemini": {"us"}}),
+	)
+
+	w := getModel(t, router, "fast")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var meta ModelMetadata
+	if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if meta.ContextWindow != 128000 || meta.MaxOutputTokens != 8192 {
+		t.Errorf("Expected the limits every target guarantees, got %d/%d", meta.ContextWindow, meta.MaxOutputTokens)
+	}
+	if len(meta.Capabilities) != 2 {
+		t.Errorf("Expected only shared capabilities, got %v", meta.Capabilities)
+	}
+	if len(meta.Providers) != 2 || meta.Providers[1].Model != "gemini-1.5-flash" {
+		t.Fatalf("Expected both targets with their concrete models, got %+v", meta.Providers)
+	}
+	if got := meta.Providers[0].Pricing.InputPerMillion; got != 2.5 {
+		t.Errorf("Expected $2.50 per million input tokens, got %v", got)
+	}
+	if len(meta.Regions) != 2 || meta.Regions[0] != "eu" {
+		t.Errorf("Expected the union of regions, got %v", meta.Regions)
+	}
+	if !meta.Deprecated || meta.RetiresOn != "2025-09-24" || meta.Replacement != "gemini-2.0-flash" {
+		t.Errorf("Expected the target's retirement, got %+v", meta)
+	}
+}
+
+func TestHandleGetModel_UnknownModel(t *testing.T) {
+	router := NewRouter([]provider.Provider{&MockProvider{name: "openai", supportedModels: []string{"gpt-4o"}}})
+	if w := getModel(t, router, "gpt-9"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+	if w := getModel(t, router, "gpt-4o"); w.Code != http.StatusOK {
+		t.Errorf("Expected models without published info to be listed, got %d", w.Code)
+	}
+}
+
+// listingProvider discovers its models from the upstream.
+type listingProvider struct {
+	MockProvider
+	listed []string
+}
+
+func (p *listingProvider) ListModels(ctx context.Context) ([]string, error) {
+	return p.listed, nil
+}
+
+type modelListCache map[string][]string
+
+func (c modelListCache) Get(ctx context.Context, provider string) ([]string, bool, error) {
+	models, ok := c[provider]
+	return models, ok, nil
+}
+
+func (c modelListCache) Set(ctx context.Context, provider string, models []string) error {
+	c[provider] = models
+	return nil
+}
+
+func TestHandleListModels_DiscoveredModels(t *testing.T) {
+	openai := &MockProvider{name: "openai", supportedModels: []string{"gpt-4o-mini"}}
+	local := &listingProvider{MockProvider: MockProvider{name: "ollama"}, listed: []string{"llama3.1:8b"}}
+	providers := []provider.Provider{openai, local}
+	models := catalog.New(providers, modelListCache{}, time.Minute)
+	if err := models.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	router := NewRouter(providers, WithCatalog(models))
+
+	h := &Handler{router: router}
+	w := httptest.NewRecorder()
+	h.HandleListModels(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	var list struct {
+		Object string          `json:"object"`
+		Data   []ModelMetadata `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Object != "list" || len(list.Data) != 2 || list.Data[0].ID != "gpt-4o-mini" || list.Data[1].ID != "llama3.1:8b" {
+		t.Fatalf("Expected both providers' models by ID, got %s", w.Body.String())
+	}
+	if p, err := router.Route(context.Background(), &provider.Request{Model: "llama3.1:8b"}); err != nil || p.Name() != "ollama" {
+		t.Errorf("Expected the discovered model to be routed, got %v, %v", p, err)
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vnmchuo/llm-gateway/internal/conversation"
+	"github.com/vnmchuo/llm-gateway/internal/logging"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// fitContext runs req through run and, when the upstream rejects it for
+// overflowing the model's context window and the tenant has an overflow
+// policy, retries it shrunk by the policy. Each adjustment is logged. The
+// last error reaches the caller once the policy's retries run out or the
+// request can't shrink any further.
+//
+// run returns a response or, for streams, the opened stream: upstreams
+// reject an oversized stream when it opens, before any chunk is relayed.
+func fitContext[T any](ctx context.Context, req *provider.Request, policy *conversation.OverflowPolicy,
+	run func(req *provider.Request) (T, provider.Provider, error)) (T, provider.Provider, error) {
+	resp, served, err := run(req)
+	if policy == nil || !errors.Is(err, provider.ErrContextLength) {
+		return resp, served, err
+	}
+	log := logging.FromContext(ctx)
+	for retry := 1; retry <= policy.MaxRetries() && errors.Is(err, provider.ErrContextLength); retry++ {
+		shrunk, change, ok := policy.Shrink(req)
+		if !ok {
+			log.Info("context overflow: request can't shrink further", "strategy", policy.Strategy)
+			break
+		}
+		log.Info("context overflow: retrying shrunk request",
+			"strategy", policy.Strategy, "change", change, "retry", retry)
+		req = shrunk
+		resp, served, err = run(req)
+	}
+	return resp, served, err
+}
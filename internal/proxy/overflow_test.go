@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/conversation"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+)
+
+// contextWindowProvider rejects requests asking for more than window
+// max_tokens, as an OpenAI context-length error.
+type contextWindowProvider struct {
+	MockProvider
+	window int
+
+	mu        sync.Mutex
+	maxTokens []int
+}
+
+func (p *contextWindowProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	p.mu.Lock()
+	p.maxTokens = append(p.maxTokens, req.MaxTokens)
+	p.mu.Unlock()
+	if req.MaxTokens > p.window {
+		return nil, provider.NewAPIError(p.name, http.StatusBadRequest, []byte(`{"error":{"code":"context_length_exceeded"}}`))
+	}
+	return p.MockProvider.Complete(ctx, req)
+}
+
+// CompleteStream rejects oversized streams as they open, like Complete.
+func (p *contextWindowProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	p.mu.Lock()
+	p.maxTokens = append(p.maxTokens, req.MaxTokens)
+	p.mu.Unlock()
+	if req.MaxTokens > p.window {
+		return nil, provider.NewAPIError(p.name, http.StatusBadRequest, []byte(`{"error":{"code":"context_length_exceeded"}}`))
+	}
+	ch := make(chan *provider.Chunk, 2)
+	ch <- &provider.Chunk{Delta: "fits"}
+	ch <- &provider.Chunk{Done: true}
+	close(ch)
+	return ch, nil
+}
+
+func completeWithOverflowPolicy(policy *conversation.OverflowPolicy, window int) (*httptest.ResponseRecorder, *contextWindowProvider) {
+	p := &contextWindowProvider{MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}, window: window}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{ContextOverflow: policy}}))
+
+	payload, _ := json.Marshal(map[string]any{
+		"model":      "gpt-4",
+		"max_tokens": 4000,
+		"messages":   []map[string]string{{"role": "user", "content": "hello"}},
+	})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(string(payload)))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+	return w, p
+}
+
+func TestHandleComplete_ContextOverflowRetriesWithLowerMaxTokens(t *testing.T) {
+	w, p := completeWithOverflowPolicy(&conversation.OverflowPolicy{Strategy: conversation.OverflowReduceMaxTokens}, 1000)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after shrinking, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := []int{4000, 2000, 1000}; !slices.Equal(p.maxTokens, want) {
+		t.Errorf("expected max_tokens %v upstream, got %v", want, p.maxTokens)
+	}
+}
+
+func TestHandleComplete_ContextOverflowSurfacesWhenRetriesRunOut(t *testing.T) {
+	w, p := completeWithOverflowPolicy(&conversation.OverflowPolicy{Strategy: conversation.OverflowReduceMaxTokens, Retries: 1}, 100)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected the upstream's 400, got %d", w.Code)
+	}
+	if code := decodeAPIError(t, w.Body.Bytes()).Error.Code; code != "context_length_exceeded" {
+		t.Errorf("expected context_length_exceeded, got %q", code)
+	}
+	if len(p.maxTokens) != 2 {
+		t.Errorf("expected one retry, got %d attempts", len(p.maxTokens))
+	}
+}
+
+func TestHandleComplete_ContextOverflowWithoutPolicyIsNotRetried(t *testing.T) {
+	w, p := completeWithOverflowPolicy(nil, 1000)
+
+	if w.Code != http.StatusBadRequest || len(p.maxTokens) != 1 {
+		t.Errorf("expected a single rejected attempt, got %d after %d attempts", w.Code, len(p.maxTokens))
+	}
+}
+
+func TestHandleCompleteStream_ContextOverflowRetriesBeforeStreaming(t *testing.T) {
+	p := &contextWindowProvider{MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}, window: 1000}
+	policy := &conversation.OverflowPolicy{Strategy: conversation.OverflowReduceMaxTokens}
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{ContextOverflow: policy}}))
+
+	body := `{"model":"gpt-4","max_tokens":4000,"messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+	w := httptest.NewRecorder()
+	h.HandleCompleteStream(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "fits") {
+		t.Fatalf("expected the shrunk stream, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := []int{4000, 2000, 1000}; !slices.Equal(p.maxTokens, want) {
+		t.Errorf("expected max_tokens %v upstream, got %v", want, p.maxTokens)
+	}
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// Per-request routing overrides, for clients that need a given provider or
+// strategy. Tenants must allow them (see tenant.Settings.AllowRouteOverrides).
+const (
+	// headerProvider pins the request to one provider; no other is tried.
+	headerProvider = "X-LLM-Provider"
+	// headerRouteStrategy is cheapest, fastest or specific (with
+	// X-LLM-Provider), or the name of any routing strategy.
+	headerRouteStrategy = "X-Route-Strategy"
+)
+
+// routeStrategyAliases maps X-Route-Strategy hints to routing strategies.
+var routeStrategyAliases = map[string]string{
+	"cheapest": "cost",
+	"fastest":  "latency",
+}
+
+const codeRouteOverrideNotAllowed = "route_override_not_allowed"
+
+// routeOverride is what a request's override headers ask for.
+type routeOverride struct {
+	provider string
+	strategy string
+}
+
+// overrideError is a rejected override, sent as a 400 or 403.
+type overrideError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *overrideError) Error() string { return e.message }
+
+func (e *overrideError) write(w http.ResponseWriter) {
+	apierror.WriteError(w, e.status, apierror.Error{Message: e.message, Code: e.code})
+}
+
+// routeOverrideFrom reads r's override headers and checks them against the
+// tenant's settings and what the router serves. It returns nil when r sets
+// none.
+func (h *Handler) routeOverrideFrom(r *http.Request, settings *tenant.Settings, model string) (*routeOverride, *overrideError) {
+	name := strings.TrimSpace(r.Header.Get(headerProvider))
+	hint := strings.ToLower(strings.TrimSpace(r.Header.Get(headerRouteStrategy)))
+	if name == "" && hint == "" {
+		return nil, nil
+	}
+	if !settings.AllowRouteOverrides {
+		return nil, &overrideError{http.StatusForbidden, codeRouteOverrideNotAllowed,
+			fmt.Sprintf("routing overrides (%s, %s) are not enabled for this tenant", headerProvider, headerRouteStrategy)}
+	}
+
+	o := &routeOverride{}
+	switch {
+	case hint == "specific" || (hint == "" && name != ""):
+		if name == "" {
+			return nil, &overrideError{status: http.StatusBadRequest,
+				message: fmt.Sprintf("%s: specific requires %s", headerRouteStrategy, headerProvider)}
+		}
+	case name != "":
+		return nil, &overrideError{status: http.StatusBadRequest,
+			message: fmt.Sprintf("%s can only be combined with %s: specific", headerProvider, headerRouteStrategy)}
+	default:
+		o.strategy = hint
+		if alias, ok := routeStrategyAliases[hint]; ok {
+			o.strategy = alias
+		}
+		if !slices.Contains(policy.Strategies, o.strategy) {
+			return nil, &overrideError{status: http.StatusBadRequest,
+				message: fmt.Sprintf("invalid %s %q: want cheapest, fastest, specific or one of %v", headerRouteStrategy, hint, policy.Strategies)}
+		}
+		return o, nil
+	}
+
+	p, ok := h.router.providerNamed(name)
+	if !ok {
+		return nil, &overrideError{status: http.StatusBadRequest, message: fmt.Sprintf("unknown provider %q", name)}
+	}
+	if len(settings.OverrideProviders) > 0 && !slices.Contains(settings.OverrideProviders, name) {
+		return nil, &overrideError{http.StatusForbidden, codeRouteOverrideNotAllowed,
+			fmt.Sprintf("provider %s is not in this tenant's override providers %v", name, settings.OverrideProviders)}
+	}
+	if _, ok := h.router.servedAs(p, model); model != "" && !ok {
+		return nil, &overrideError{status: http.StatusBadRequest, message: fmt.Sprintf("provider %s does not serve model %s", name, model)}
+	}
+	o.provider = name
+	return o, nil
+}
+
+// pinned narrows candidates to the provider req is pinned to, if any.
+func pinned(req *provider.Request, candidates []provider.Provider) []provider.Provider {
+	if req.Provider == "" {
+		return candidates
+	}
+	var out []provider.Provider
+	for _, p := range candidates {
+		if p.Name() == req.Provider {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+func overrideRequest(headers map[string]string) *http.Request {
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hello"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+}
+
+func TestHandleComplete_ProviderOverride(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 0.1, supportedModels: []string{"gpt-4"}}
+	pricey := &MockProvider{name: "pricey", cost: 10, supportedModels: []string{"gpt-4"}}
+	other := &MockProvider{name: "other", cost: 1, supportedModels: []string{"claude-3"}}
+	h, _ := setupTest([]provider.Provider{cheap, pricey, other}, true)
+	WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{AllowRouteOverrides: true}})(h)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, overrideRequest(map[string]string{"X-LLM-Provider": "pricey"}))
+	var resp struct {
+		Provider string `json:"provider"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Provider != "pricey" {
+		t.Fatalf("Expected the pinned provider to serve, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"specific without provider", map[string]string{"X-Route-Strategy": "specific"}, http.StatusBadRequest},
+		{"provider with another strategy", map[string]string{"X-LLM-Provider": "pricey", "X-Route-Strategy": "cheapest"}, http.StatusBadRequest},
+		{"unknown strategy", map[string]string{"X-Route-Strategy": "random"}, http.StatusBadRequest},
+		{"unknown provider", map[string]string{"X-LLM-Provider": "nope"}, http.StatusBadRequest},
+		{"provider without the model", map[string]string{"X-LLM-Provider": "other"}, http.StatusBadRequest},
+		{"fastest", map[string]string{"X-Route-Strategy": "fastest"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleComplete(w, overrideRequest(tt.headers))
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleComplete_ProviderOverrideEnforcesTenantSettings(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 0.1, supportedModels: []string{"gpt-4"}}
+	pricey := &MockProvider{name: "pricey", cost: 10, supportedModels: []string{"gpt-4"}}
+	h, _ := setupTest([]provider.Provider{cheap, pricey}, true)
+	tenants := &mockTenantStore{settings: &tenant.Settings{}}
+	WithTenantSettings(tenants)(h)
+
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, overrideRequest(map[string]string{"X-LLM-Provider": "pricey"}))
+	if resp := decodeAPIError(t, w.Body.Bytes()); w.Code != http.StatusForbidden || resp.Error.Code != codeRouteOverrideNotAllowed {
+		t.Errorf("Expected 403 %s without allow_route_overrides, got %d: %s", codeRouteOverrideNotAllowed, w.Code, w.Body.String())
+	}
+
+	tenants.settings = &tenant.Settings{AllowRouteOverrides: true, OverrideProviders: []string{"cheap"}}
+	w = httptest.NewRecorder()
+	h.HandleComplete(w, overrideRequest(map[string]string{"X-LLM-Provider": "pricey"}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a provider outside override_providers, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Requests without override headers route as usual.
+	w = httptest.NewRecorder()
+	h.HandleComplete(w, overrideRequest(nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
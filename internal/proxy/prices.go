@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"slices"
+
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// WithPrices prices models from prices, for routing and billing. Models it
+// has no price for cost their provider's built-in per-token price.
+func WithPrices(prices *pricing.Registry) RouterOption {
+	return func(r *Router) {
+		r.prices = prices
+	}
+}
+
+// Price returns what model costs on p.
+func (r *Router) Price(p provider.Provider, model string) pricing.Price {
+	if price, ok := r.prices.Lookup(p.Name(), model); ok {
+		return price
+	}
+	if ep, ok := p.(provider.EmbeddingsProvider); ok && slices.Contains(ep.EmbeddingModels(), model) {
+		return pricing.Price{Input: ep.CostPerEmbeddingToken()}
+	}
+	return pricing.Price{Input: p.CostPerInputToken(), Output: p.CostPerOutputToken()}
+}
+
+// cost is what inputTokens and outputTokens of model cost on p.
+func (r *Router) cost(p provider.Provider, model string, inputTokens, outputTokens int) float64 {
+	price := r.Price(p, model)
+	return float64(inputTokens)*price.Input + float64(outputTokens)*price.Output
+}
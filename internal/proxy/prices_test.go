@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type priceList []pricing.ModelPrice
+
+func (l priceList) List(ctx context.Context) ([]pricing.ModelPrice, error) { return l, nil }
+
+func TestRouter_PricesPerModel(t *testing.T) {
+	// Built-in prices say openai is cheaper; the registry knows better for llama-3.
+	openai := &MockProvider{name: "openai", cost: 1, supportedModels: []string{"llama-3", "gpt-4o"}}
+	groq := &MockProvider{name: "groq", cost: 5, supportedModels: []string{"llama-3"}}
+	prices := pricing.NewRegistry(priceList{
+		{Provider: "openai", Model: "llama-3", Price: pricing.Price{Input: 0.9, Output: 0.9}},
+		{Provider: "groq", Model: "llama-3", Price: pricing.Price{Input: 0.2, Output: 0.4}},
+	}, time.Minute)
+	if err := prices.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	router := NewRouter([]provider.Provider{openai, groq}, WithPrices(prices))
+
+	p, err := router.Route(context.Background(), &provider.Request{Model: "llama-3"})
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if p.Name() != "groq" {
+		t.Errorf("Expected the cheaper price for the model, got %s", p.Name())
+	}
+	if got := router.cost(groq, "llama-3", 10, 5); got != 4 {
+		t.Errorf("Expected 10*0.2 + 5*0.4 = 4, got %v", got)
+	}
+	if got := router.cost(openai, "gpt-4o", 10, 5); got != 10 {
+		t.Errorf("Expected the provider's built-in price for unlisted models, got %v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// headerPriority is X-Priority: interactive (the default) or batch. Batch
+// requests are rate limited in a pool of their own, so a tenant's batch jobs
+// don't throttle its interactive users.
+const headerPriority = "X-Priority"
+
+var errPriorityNotAllowed = errors.New("API key may not send this " + headerPriority)
+
+// requestPriority reads X-Priority. A key granted priority scopes may only
+// send the priorities they name, and defaults to batch when it isn't
+// granted interactive; other keys may send either.
+func requestPriority(r *http.Request) (string, error) {
+	granted := auth.GetScopes(r.Context())
+	allowed := func(p string) bool {
+		scoped := slices.ContainsFunc(granted, func(g string) bool { return strings.HasPrefix(g, "priority:") })
+		return !scoped || auth.HasScope(granted, "priority:"+p)
+	}
+
+	p := r.Header.Get(headerPriority)
+	switch p {
+	case "":
+		if allowed(provider.PriorityInteractive) {
+			return provider.PriorityInteractive, nil
+		}
+		p = provider.PriorityBatch
+	case provider.PriorityInteractive, provider.PriorityBatch:
+	default:
+		return "", errors.New("invalid " + headerPriority + ": must be interactive or batch")
+	}
+	if !allowed(p) {
+		return "", errPriorityNotAllowed
+	}
+	return p, nil
+}
+
+// writePriorityError rejects a request whose X-Priority was malformed (400)
+// or not allowed for its key (403).
+func writePriorityError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errPriorityNotAllowed) {
+		apierror.Write(w, http.StatusForbidden, apierror.CodeInsufficientScope, err.Error())
+		return
+	}
+	apierror.Write(w, http.StatusBadRequest, "", err.Error())
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestRequestPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		header string
+		want   string
+		err    error
+	}{
+		{name: "default", want: provider.PriorityInteractive},
+		{name: "batch from an unscoped key", header: "batch", want: provider.PriorityBatch},
+		{name: "wildcard key", scopes: []string{auth.ScopeAll}, header: "interactive", want: provider.PriorityInteractive},
+		{name: "key without priority scopes", scopes: []string{auth.ScopeChatWrite}, header: "batch", want: provider.PriorityBatch},
+		{name: "batch key defaults to batch", scopes: []string{auth.ScopeChatWrite, auth.ScopePriorityBatch}, want: provider.PriorityBatch},
+		{name: "batch key sending interactive", scopes: []string{auth.ScopeChatWrite, auth.ScopePriorityBatch}, header: "interactive", err: errPriorityNotAllowed},
+		{name: "priority wildcard", scopes: []string{"priority:*"}, header: "interactive", want: provider.PriorityInteractive},
+		{name: "malformed header", header: "urgent", err: errors.New("invalid X-Priority: must be interactive or batch")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			if tt.header != "" {
+				req.Header.Set(headerPriority, tt.header)
+			}
+			req = req.WithContext(auth.WithScopes(req.Context(), tt.scopes))
+
+			got, err := requestPriority(req)
+			if tt.err != nil {
+				if err == nil || err.Error() != tt.err.Error() {
+					t.Fatalf("Expected error %v, got %q, %v", tt.err, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// WithSystemPrompts expands system_ref from the tenant's prompt library.
+func WithSystemPrompts(store prompts.Store) Option {
+	return func(h *Handler) {
+		h.prompts = store
+	}
+}
+
+var errSystemPromptsDisabled = errors.New("system_ref is not supported by this gateway")
+
+// expandSystemRef renders the prompt req's system_ref names with its
+// system_vars and puts it at the start of the system message, where it is
+// a stable prefix for providers that cache prompts. It returns the
+// resolved "id@version", or "" when req has no system_ref.
+func (h *Handler) expandSystemRef(ctx context.Context, tenantID string, req *provider.Request) (string, error) {
+	if req.SystemRef == "" {
+		return "", nil
+	}
+	if h.prompts == nil {
+		return "", errSystemPromptsDisabled
+	}
+	id, version, err := prompts.ParseRef(req.SystemRef)
+	if err != nil {
+		return "", err
+	}
+	p, err := h.prompts.Get(ctx, tenantID, id, version)
+	if err != nil {
+		return "", err
+	}
+	content, err := p.Render(req.SystemVars)
+	if err != nil {
+		return "", err
+	}
+	req.Messages = withSystemPrefix(req.Messages, content)
+	req.SystemRef, req.SystemVars = "", nil
+	return fmt.Sprintf("%s@%d", p.ID, p.Version), nil
+}
+
+// writeSystemRefError rejects a request whose system_ref couldn't be expanded.
+func writeSystemRefError(w http.ResponseWriter, err error) {
+	e := apierror.Error{Message: err.Error(), Param: "system_ref"}
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, prompts.ErrNotFound):
+		e.Code = "system_prompt_not_found"
+	case errors.Is(err, prompts.ErrMissingVariable):
+		e.Code, e.Param = "missing_prompt_variable", "system_vars"
+	case errors.Is(err, prompts.ErrInvalidRef), errors.Is(err, errSystemPromptsDisabled):
+	default:
+		status = http.StatusInternalServerError
+		e = apierror.Error{Message: "failed to load system prompt"}
+	}
+	apierror.WriteError(w, status, e)
+}
+
+// withSystemPrefix returns messages with prefix at the start of the
+// leading system message, or prepended as one when there is none.
+func withSystemPrefix(messages []provider.Message, prefix string) []provider.Message {
+	out := make([]provider.Message, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		first := messages[0]
+		first.Content = prefix + "\n\n" + first.Content
+		return append(append(out, first), messages[1:]...)
+	}
+	out = append(out, provider.Message{Role: "system", Content: prefix})
+	return append(out, messages...)
+}
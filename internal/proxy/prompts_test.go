@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// stubPromptStore serves one prompt in two versions.
+type stubPromptStore struct {
+	prompts.Store
+}
+
+func (stubPromptStore) Get(ctx context.Context, tenantID, id string, version int) (*prompts.Prompt, error) {
+	if tenantID != "test-tenant" || id != "support" || version > 2 {
+		return nil, prompts.ErrNotFound
+	}
+	if version == 1 {
+		return &prompts.Prompt{ID: id, Version: 1, Content: "You are a support agent."}, nil
+	}
+	return &prompts.Prompt{ID: id, Version: 2, Content: "You support {{product}} users.", Defaults: map[string]string{"product": "Acme"}}, nil
+}
+
+func TestHandleComplete_ExpandsSystemRef(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		status int
+		code   string
+		system string
+	}{
+		{"latest with defaults", `"system_ref":"support"`, http.StatusOK, "", "You support Acme users.\n\nBe brief."},
+		{"variables", `"system_ref":"support","system_vars":{"product":"Widgets"}`, http.StatusOK, "", "You support Widgets users.\n\nBe brief."},
+		{"pinned version", `"system_ref":"support@1"`, http.StatusOK, "", "You are a support agent.\n\nBe brief."},
+		{"unknown prompt", `"system_ref":"sales"`, http.StatusBadRequest, "system_prompt_not_found", ""},
+		{"malformed ref", `"system_ref":"support@latest"`, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &extraProvider{MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}}
+			h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{},
+				ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+				WithSystemPrompts(stubPromptStore{}))
+
+			body := `{"model":"gpt-4",` + tt.fields + `,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+			req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+			w := httptest.NewRecorder()
+			h.HandleComplete(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if e := decodeAPIError(t, w.Body.Bytes()); e.Error.Code != tt.code {
+					t.Errorf("Expected code %q, got %+v", tt.code, e.Error)
+				}
+				return
+			}
+			if len(p.got.Messages) != 2 || p.got.Messages[0].Content != tt.system {
+				t.Errorf("Expected system message %q, got %+v", tt.system, p.got.Messages)
+			}
+			if p.got.SystemRef != "" || p.got.SystemVars != nil {
+				t.Errorf("Expected the reference not to reach the upstream, got %q %v", p.got.SystemRef, p.got.SystemVars)
+			}
+		})
+	}
+}
+
+func TestHandleComplete_SystemRefMissingVariable(t *testing.T) {
+	h := NewHandler(NewRouter(nil), &mockBillingStore{}, ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}),
+		noop.NewTracerProvider().Tracer("test"), WithSystemPrompts(missingVarStore{}))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4","system_ref":"support","messages":[{"role":"user","content":"hi"}]}`))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+
+	e := decodeAPIError(t, w.Body.Bytes())
+	if w.Code != http.StatusBadRequest || e.Error.Code != "missing_prompt_variable" || e.Error.Param == nil || *e.Error.Param != "system_vars" {
+		t.Errorf("Expected a missing_prompt_variable error, got %d %+v", w.Code, e.Error)
+	}
+}
+
+// missingVarStore serves a prompt whose variable has no default.
+type missingVarStore struct {
+	prompts.Store
+}
+
+func (missingVarStore) Get(ctx context.Context, tenantID, id string, version int) (*prompts.Prompt, error) {
+	return &prompts.Prompt{ID: id, Version: 1, Content: "You support {{product}} users."}, nil
+}
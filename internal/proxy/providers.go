@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// ErrUnknownProvider means no configured provider has the given name.
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// WithKeyPools reports the pooled upstream API keys of each provider that
+// has them in ProviderStatuses.
+func WithKeyPools(pools map[string]*provider.KeyPool) RouterOption {
+	return func(r *Router) {
+		r.keyPools = pools
+	}
+}
+
+// WithEndpoints reports the endpoints of each provider configured with
+// several base URLs in ProviderStatuses.
+func WithEndpoints(endpoints map[string]*provider.Failover) RouterOption {
+	return func(r *Router) {
+		r.endpoints = endpoints
+	}
+}
+
+func newBreaker(name string) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: 3,
+		Interval:    5 * time.Second,
+		Timeout:     30 * time.Second,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 3
+		},
+		// A rejected prompt says nothing about the provider's health.
+		IsSuccessful: func(err error) bool {
+			return err == nil || isClientError(err)
+		},
+	})
+}
+
+// breaker returns name's circuit breaker, or nil for an unknown provider.
+func (r *Router) breaker(name string) *gobreaker.CircuitBreaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breakers[name]
+}
+
+// available reports whether name may be routed to: its breaker isn't open
+// and no operator disabled it.
+func (r *Router) available(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cb, ok := r.breakers[name]
+	return ok && !r.disabled[name] && cb.State() != gobreaker.StateOpen
+}
+
+// ProviderStatus is a provider's health as seen by the router.
+type ProviderStatus struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`    // circuit breaker: closed, half-open or open
+	Disabled bool   `json:"disabled"` // drained by an operator
+	// Counts cover the breaker's current window: the last 5s while closed,
+	// the trial requests while half-open.
+	Requests            uint32 `json:"requests"`
+	Failures            uint32 `json:"failures"`
+	ConsecutiveFailures uint32 `json:"consecutive_failures"`
+	// LatencyMs is the moving average of successful completions; 0 until
+	// the first one. ErrorRate is the moving average share of failed calls,
+	// and Degraded whether they make the router try it after the others.
+	LatencyMs float64 `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	Degraded  bool    `json:"degraded"`
+	// Scheduled is the capacity calendar window limiting the whole
+	// provider now, if any.
+	Scheduled *policy.CapacityWindow `json:"scheduled,omitempty"`
+	// Keys lists the provider's pooled upstream API keys, if it has a pool.
+	Keys []provider.KeyStatus `json:"keys,omitempty"`
+	// Endpoints lists the provider's base URLs, each with its own breaker,
+	// if it has several.
+	Endpoints []provider.EndpointStatus `json:"endpoints,omitempty"`
+}
+
+// ProviderStatuses reports every configured provider in configuration order.
+func (r *Router) ProviderStatuses() []ProviderStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := make([]ProviderStatus, 0, len(r.providers))
+	for _, p := range r.providers {
+		cb := r.breakers[p.Name()]
+		counts := cb.Counts()
+		status := ProviderStatus{
+			Name:                p.Name(),
+			State:               cb.State().String(),
+			Disabled:            r.disabled[p.Name()],
+			Requests:            counts.Requests,
+			Failures:            counts.TotalFailures,
+			ConsecutiveFailures: counts.ConsecutiveFailures,
+		}
+		stats := r.latency.stats(p.Name(), "")
+		status.LatencyMs = math.Round(stats.LatencyMs*10) / 10
+		status.ErrorRate = math.Round(stats.ErrorRate*1000) / 1000
+		status.Degraded = r.isDegraded(p.Name(), "")
+		status.Scheduled = r.scheduledCapacity(p.Name(), "")
+		if pool := r.keyPools[p.Name()]; pool != nil {
+			status.Keys = pool.Statuses()
+		}
+		if failover := r.endpoints[p.Name()]; failover != nil {
+			status.Endpoints = failover.Statuses()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// DisableProvider drains name: no new requests are routed to it until
+// EnableProvider. Requests already running on it finish.
+func (r *Router) DisableProvider(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.breakers[name]; !ok {
+		return ErrUnknownProvider
+	}
+	r.disabled[name] = true
+	return nil
+}
+
+// EnableProvider restores name to routing with a fresh, closed circuit
+// breaker, so an operator can bring a recovered provider back without
+// waiting out the breaker's timeout.
+func (r *Router) EnableProvider(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.breakers[name]; !ok {
+		return ErrUnknownProvider
+	}
+	delete(r.disabled, name)
+	r.breakers[name] = newBreaker(name)
+	return nil
+}
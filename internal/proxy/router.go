@@ -4,47 +4,224 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/sony/gobreaker"
+	"github.com/vnmchuo/llm-gateway/internal/catalog"
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
 	"github.com/vnmchuo/llm-gateway/internal/provider"
 )
 
+var (
+	// ErrNoProviders means no healthy provider serves the requested model.
+	ErrNoProviders = errors.New("all providers unavailable")
+	// ErrNoEmbeddingProviders means no healthy provider serves the requested embedding model.
+	ErrNoEmbeddingProviders = errors.New("no embedding provider available")
+	// ErrModelNotFound means strict routing rejected a model no provider serves.
+	ErrModelNotFound = errors.New("model_not_found")
+)
+
+// RoutingModeStrict is the tenant routing mode that rejects unknown models
+// instead of falling back to the cheapest provider.
+const RoutingModeStrict = "strict"
+
 type Router struct {
-	providers []provider.Provider
-	breakers  map[string]*gobreaker.CircuitBreaker
+	providers      []provider.Provider
+	mu             sync.RWMutex // guards breakers, disabled, strategies and strategy
+	breakers       map[string]*gobreaker.CircuitBreaker
+	disabled       map[string]bool // drained by an operator
+	maxAttempts    int
+	attemptTimeout time.Duration
+	strategies     map[string]RoutingStrategy
+	strategy       string
+	latency        *latencyTracker
+	degradation    Degradation
+	sync           *statsSync // shares latency with other replicas
+	aliases        map[string][]ModelTarget
+	extraFields    map[string]map[string]bool // provider -> allowed passthrough fields
+	regions        map[string][]string        // provider -> regions its models are available in
+	prices         *pricing.Registry
+	keyPools       map[string]*provider.KeyPool  // provider -> pooled upstream keys
+	endpoints      map[string]*provider.Failover // provider -> regional endpoints
+	calendar       CapacitySchedule
+	catalog        *catalog.Catalog
 }
 
-func NewRouter(providers []provider.Provider) *Router {
+// RouterOption configures optional Router behaviour.
+type RouterOption func(*Router)
+
+// WithFallback retries a failed request on the next healthy candidate, up to
+// maxAttempts providers in total. Each non-streaming interactive attempt is
+// bounded by attemptTimeout (0 = only the request context applies).
+func WithFallback(maxAttempts int, attemptTimeout time.Duration) RouterOption {
+	return func(r *Router) {
+		if maxAttempts > 0 {
+			r.maxAttempts = maxAttempts
+		}
+		r.attemptTimeout = attemptTimeout
+	}
+}
+
+// WithRoutingStrategy registers s under name, replacing the built-in
+// strategy of that name if there is one.
+func WithRoutingStrategy(name string, s RoutingStrategy) RouterOption {
+	return func(r *Router) {
+		r.strategies[name] = s
+	}
+}
+
+// WithDefaultStrategy selects the strategy used for tenants that don't pick
+// one. The default is StrategyCost.
+func WithDefaultStrategy(name string) RouterOption {
+	return func(r *Router) {
+		r.strategy = name
+	}
+}
+
+func NewRouter(providers []provider.Provider, opts ...RouterOption) *Router {
 	breakers := make(map[string]*gobreaker.CircuitBreaker)
 	for _, p := range providers {
-		settings := gobreaker.Settings{
-			Name:        p.Name(),
-			MaxRequests: 3,
-			Interval:    5 * time.Second,
-			Timeout:     30 * time.Second,
-			ReadyToTrip: func(counts gobreaker.Counts) bool {
-				return counts.ConsecutiveFailures >= 3
-			},
+		breakers[p.Name()] = newBreaker(p.Name())
+	}
+	latency := newLatencyTracker()
+	r := &Router{
+		providers:   providers,
+		breakers:    breakers,
+		disabled:    make(map[string]bool),
+		maxAttempts: 1,
+		strategy:    StrategyCost,
+		latency:     latency,
+	}
+	r.strategies = map[string]RoutingStrategy{
+		StrategyCost:     costStrategy{price: r.Price},
+		StrategyLatency:  latencyStrategy{tracker: latency},
+		StrategyWeighted: NewWeightedStrategy(nil),
+		StrategyPriority: NewPriorityStrategy(nil),
+	}
+	for _, p := range providers {
+		if ep, ok := p.(provider.ExtraFieldsProvider); ok {
+			for _, f := range ep.ExtraFields() {
+				r.allowExtra(p.Name(), f)
+			}
 		}
-		breakers[p.Name()] = gobreaker.NewCircuitBreaker(settings)
 	}
-	return &Router{
-		providers: providers,
-		breakers:  breakers,
+	for _, opt := range opts {
+		opt(r)
 	}
+	if _, ok := r.strategies[r.strategy]; !ok {
+		log.Printf("router: unknown routing strategy %q, using %s", r.strategy, StrategyCost)
+		r.strategy = StrategyCost
+	}
+	for alias, targets := range r.aliases {
+		for _, t := range targets {
+			if r.breaker(t.Provider) == nil {
+				log.Printf("router: alias %q targets unconfigured provider %q", alias, t.Provider)
+			}
+		}
+	}
+	return r
+}
+
+// SetRoutingStrategy is WithRoutingStrategy for a router that is already
+// serving, e.g. to apply reloaded weights.
+func (r *Router) SetRoutingStrategy(name string, s RoutingStrategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies[name] = s
 }
 
+// SetDefaultStrategy is WithDefaultStrategy for a router that is already
+// serving. Unknown names are rejected and the current default kept.
+func (r *Router) SetDefaultStrategy(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.strategies[name]; !ok {
+		return fmt.Errorf("unknown routing strategy %q", name)
+	}
+	r.strategy = name
+	return nil
+}
+
+// Route picks the provider for req. When req's cost cap rules out every
+// provider able to serve it, the error is a *CostCapError.
 func (r *Router) Route(ctx context.Context, req *provider.Request) (provider.Provider, error) {
+	candidates := r.candidates(req)
+	if len(candidates) > 0 {
+		return candidates[0], nil
+	}
+	if req.MaxCost == nil {
+		return nil, ErrNoProviders
+	}
+	return nil, r.costCapError(req)
+}
+
+// Serves reports whether any configured provider, healthy or not, supports
+// model, or model is an alias.
+func (r *Router) Serves(model string) bool {
+	if _, ok := r.aliases[model]; ok {
+		return true
+	}
+	for _, p := range r.providers {
+		for _, m := range r.modelsOf(p) {
+			if m == model {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// strategyFor returns the strategy the request's tenant selected, or the
+// gateway default when it selected none or an unknown one.
+func (r *Router) strategyFor(req *provider.Request) RoutingStrategy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.strategies[req.RoutingStrategy]; ok {
+		return s
+	}
+	return r.strategies[r.strategy]
+}
+
+// served feeds a successful attempt back to the strategies that learn from it.
+func (r *Router) served(req *provider.Request, p provider.Provider) {
+	if o, ok := r.strategyFor(req).(servedObserver); ok {
+		o.served(p.Name())
+	}
+}
+
+// BreakerStates reports each provider's circuit breaker state.
+func (r *Router) BreakerStates() map[string]gobreaker.State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	states := make(map[string]gobreaker.State, len(r.breakers))
+	for name, cb := range r.breakers {
+		states[name] = cb.State()
+	}
+	return states
+}
+
+// candidates returns the healthy providers able to serve req in the order
+// of its routing strategy, without those over its cost cap.
+func (r *Router) candidates(req *provider.Request) []provider.Provider {
+	return r.underCostCap(req, pinned(req, r.eligible(req)))
+}
+
+// eligible is candidates before the cost cap.
+func (r *Router) eligible(req *provider.Request) []provider.Provider {
+	if targets, ok := r.aliases[req.Model]; ok {
+		return r.applyCalendar(req, r.demoteDegraded(req, r.aliasCandidates(targets)))
+	}
+
 	var candidates []provider.Provider
 	for _, p := range r.providers {
-		cb := r.breakers[p.Name()]
-		if cb.State() == gobreaker.StateOpen {
+		if !r.available(p.Name()) {
 			continue
 		}
 
 		if req.Model != "" {
-			for _, m := range p.SupportedModels() {
+			for _, m := range r.modelsOf(p) {
 				if m == req.Model {
 					candidates = append(candidates, p)
 					break
@@ -55,64 +232,212 @@ func (r *Router) Route(ctx context.Context, req *provider.Request) (provider.Pro
 		}
 	}
 
-	if len(candidates) == 0 {
-		return nil, errors.New("all providers unavailable")
+	s := r.strategyFor(req)
+	if ms, ok := s.(modelStrategy); ok {
+		return r.applyCalendar(req, r.demoteDegraded(req, ms.OrderModel(req.Model, candidates)))
 	}
+	return r.applyCalendar(req, r.demoteDegraded(req, s.Order(candidates)))
+}
 
-	if req.Model != "" {
-		return candidates[0], nil
+// fallbacks returns the attempt order for a request already routed to first.
+func (r *Router) fallbacks(req *provider.Request, first provider.Provider) []provider.Provider {
+	attempts := []provider.Provider{first}
+	for _, p := range r.candidates(req) {
+		if len(attempts) >= r.maxAttempts {
+			break
+		}
+		if p.Name() != first.Name() {
+			attempts = append(attempts, p)
+		}
 	}
+	return attempts
+}
 
-	best := candidates[0]
-	for _, p := range candidates[1:] {
-		if p.CostPerInputToken() < best.CostPerInputToken() {
-			best = p
+// ExecuteWithFallback runs req on first and, if it fails or its breaker opens,
+// on the next candidates that serve the same model. It returns the provider
+// that produced the response.
+func (r *Router) ExecuteWithFallback(ctx context.Context, req *provider.Request, first provider.Provider) (*provider.Response, provider.Provider, error) {
+	var errs []error
+	for _, p := range r.fallbacks(req, first) {
+		attemptCtx, cancel := r.attemptContext(ctx, req)
+		resp, err := r.Execute(attemptCtx, req, p)
+		cancel()
+		if err == nil {
+			return resp, p, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if ctx.Err() != nil || isClientError(err) {
+			break
+		}
+	}
+	if len(errs) == 1 {
+		return nil, nil, errors.Unwrap(errs[0])
+	}
+	return nil, nil, fmt.Errorf("all attempts failed: %w", errors.Join(errs...))
+}
+
+// isClientError reports errors another provider would fail the same way.
+func isClientError(err error) bool {
+	return errors.Is(err, provider.ErrBadRequest) || errors.Is(err, provider.ErrContentFiltered)
+}
+
+// providerFailure reports whether err counts against the provider's error
+// rate: not the caller's fault, not the caller going away, and not a call
+// the breaker refused to make.
+func providerFailure(ctx context.Context, err error) bool {
+	if isClientError(err) || errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return false
+	}
+	return !errors.Is(ctx.Err(), context.Canceled)
+}
+
+// RouteEmbeddings picks the first healthy provider that serves the requested
+// embedding model, or the first embeddings-capable provider if none is given.
+func (r *Router) RouteEmbeddings(ctx context.Context, req *provider.EmbeddingRequest) (provider.Provider, error) {
+	for _, p := range r.providers {
+		ep, ok := p.(provider.EmbeddingsProvider)
+		if !ok || !r.available(p.Name()) {
+			continue
+		}
+		if req.Model == "" {
+			return p, nil
+		}
+		for _, m := range ep.EmbeddingModels() {
+			if m == req.Model {
+				return p, nil
+			}
 		}
 	}
-	return best, nil
+	return nil, ErrNoEmbeddingProviders
+}
+
+func (r *Router) ExecuteEmbeddings(ctx context.Context, req *provider.EmbeddingRequest, p provider.Provider) (*provider.EmbeddingResponse, error) {
+	ep, ok := p.(provider.EmbeddingsProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not serve embeddings", p.Name())
+	}
+	if req.Model == "" {
+		req.Model = ep.EmbeddingModels()[0]
+	}
+	cb := r.breaker(p.Name())
+	result, err := cb.Execute(func() (interface{}, error) {
+		return ep.Embed(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*provider.EmbeddingResponse), nil
 }
 
 func (r *Router) Execute(ctx context.Context, req *provider.Request, p provider.Provider) (*provider.Response, error) {
-	cb := r.breakers[p.Name()]
+	cb := r.breaker(p.Name())
+	start := time.Now()
+	resolved := r.resolve(req, p)
 	result, err := cb.Execute(func() (interface{}, error) {
-		return p.Complete(ctx, req)
+		return p.Complete(ctx, resolved)
 	})
 	if err != nil {
+		if providerFailure(ctx, err) {
+			r.latency.record(p.Name(), resolved.Model, 0, true)
+		}
 		return nil, err
 	}
-	return result.(*provider.Response), nil
+	r.latency.record(p.Name(), resolved.Model, time.Since(start), false)
+	r.served(req, p)
+	resp := result.(*provider.Response)
+	resp.FinishReason = provider.NormalizeFinishReason(resp.RawFinishReason, len(resp.ToolCalls) > 0)
+	return resp, nil
 }
 
-func (r *Router) ExecuteStream(ctx context.Context, req *provider.Request, p provider.Provider) (<-chan *provider.Chunk, error) {
-	cb := r.breakers[p.Name()]
+// ExecuteStream opens a stream on p. Chunks are relayed on the returned
+// Stream's channel and tallied as they arrive, until the upstream closes the
+// stream even if the caller has stopped reading.
+func (r *Router) ExecuteStream(ctx context.Context, req *provider.Request, p provider.Provider) (*Stream, error) {
+	cb := r.breaker(p.Name())
 	if cb.State() == gobreaker.StateOpen {
 		return nil, fmt.Errorf("circuit breaker is open for provider: %s", p.Name())
 	}
 
-	origCh, err := p.CompleteStream(ctx, req)
+	resolved := r.resolve(req, p)
+	tally := newStreamTally(time.Now())
+	origCh, err := p.CompleteStream(ctx, resolved)
 	if err != nil {
 		_, _ = cb.Execute(func() (interface{}, error) {
 			return nil, err
 		})
+		if providerFailure(ctx, err) {
+			r.latency.record(p.Name(), resolved.Model, 0, true)
+		}
 		return nil, err
 	}
+	r.served(req, p)
 
 	wrappedCh := make(chan *provider.Chunk)
-	go func() {
+	provider.Streams.Go("router", func() {
 		defer close(wrappedCh)
+		defer tally.end()
+		var toolCalls bool
+		var abandoned bool // the caller stopped reading
 		for chunk := range origCh {
+			toolCalls = toolCalls || len(chunk.ToolCalls) > 0
+			if chunk.Done {
+				chunk.FinishReason = provider.NormalizeFinishReason(chunk.RawFinishReason, toolCalls)
+			}
+			// What the upstream sends after the caller left was still
+			// generated, so it is tallied too.
+			tally.add(chunk, time.Now())
+			if abandoned {
+				// Drain so a provider that ignores ctx is never left
+				// blocked on send.
+				continue
+			}
 			if chunk.Err != nil {
 				_, _ = cb.Execute(func() (interface{}, error) {
 					return nil, chunk.Err
 				})
+				if providerFailure(ctx, chunk.Err) {
+					r.latency.record(p.Name(), resolved.Model, 0, true)
+				}
+			} else if chunk.Done {
+				r.latency.record(p.Name(), resolved.Model, 0, false)
 			}
 			select {
 			case wrappedCh <- chunk:
 			case <-ctx.Done():
-				return
+				abandoned = true
 			}
 		}
-	}()
+	})
+
+	return &Stream{C: wrappedCh, tally: tally}, nil
+}
+
+// attemptContext bounds one attempt at req by the attempt timeout. Batch
+// requests would rather wait than start over elsewhere, so only the request
+// context bounds theirs.
+func (r *Router) attemptContext(ctx context.Context, req *provider.Request) (context.Context, context.CancelFunc) {
+	if r.attemptTimeout > 0 && req.Priority != provider.PriorityBatch {
+		return context.WithTimeout(ctx, r.attemptTimeout)
+	}
+	return context.WithCancel(ctx)
+}
 
-	return wrappedCh, nil
+// ExecuteStreamWithFallback falls back only while opening the stream; once
+// chunks flow, a mid-stream failure is reported to the client as-is.
+func (r *Router) ExecuteStreamWithFallback(ctx context.Context, req *provider.Request, first provider.Provider) (*Stream, provider.Provider, error) {
+	var errs []error
+	for _, p := range r.fallbacks(req, first) {
+		stream, err := r.ExecuteStream(ctx, req, p)
+		if err == nil {
+			return stream, p, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 1 {
+		return nil, nil, errors.Unwrap(errs[0])
+	}
+	return nil, nil, fmt.Errorf("all attempts failed: %w", errors.Join(errs...))
 }
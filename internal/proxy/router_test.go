@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.uber.org/goleak"
 )
 
 type MockProvider struct {
@@ -105,7 +107,126 @@ func TestRoute_AllProvidersDown(t *testing.T) {
 	}
 	
 	_, err := router.Route(context.Background(), &provider.Request{})
-	if err == nil || err.Error() != "all providers unavailable" {
-		t.Errorf("Expected 'all providers unavailable' error, got %v", err)
+	if !errors.Is(err, ErrNoProviders) {
+		t.Errorf("Expected ErrNoProviders, got %v", err)
+	}
+}
+
+// blockingStreamProvider emits chunks forever and ignores ctx, simulating a
+// misbehaving provider whose consumer walks away mid-stream.
+type blockingStreamProvider struct {
+	MockProvider
+}
+
+func (m *blockingStreamProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 100; i++ {
+			ch <- &provider.Chunk{Delta: "x"}
+		}
+	}()
+	return ch, nil
+}
+
+func TestExecuteStream_ClientDisconnectDoesNotLeak(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	p := &blockingStreamProvider{MockProvider: MockProvider{name: "blocking"}}
+	router := NewRouter([]provider.Provider{p})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := router.ExecuteStream(ctx, &provider.Request{}, p)
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+
+	// Read a single chunk then abandon the stream like a disconnecting client.
+	<-stream.C
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for provider.Streams.Active() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := provider.Streams.Active(); n != 0 {
+		t.Errorf("Expected 0 active stream goroutines, got %d (%v)", n, provider.Streams.Snapshot())
+	}
+}
+
+func TestExecuteWithFallback_UsesNextCandidate(t *testing.T) {
+	p1 := &MockProvider{name: "primary", supportedModels: []string{"gpt-4"}, completeErr: errors.New("upstream 500")}
+	p2 := &MockProvider{name: "secondary", supportedModels: []string{"gpt-4"}}
+	p3 := &MockProvider{name: "other-family", supportedModels: []string{"claude-3"}}
+
+	router := NewRouter([]provider.Provider{p1, p2, p3}, WithFallback(3, time.Second))
+
+	req := &provider.Request{Model: "gpt-4"}
+	resp, served, err := router.ExecuteWithFallback(context.Background(), req, p1)
+	if err != nil {
+		t.Fatalf("ExecuteWithFallback failed: %v", err)
+	}
+	if served.Name() != "secondary" || resp.Provider != "secondary" {
+		t.Errorf("Expected secondary to serve the request, got %s", served.Name())
+	}
+}
+
+func TestExecuteWithFallback_DisabledByDefault(t *testing.T) {
+	p1 := &MockProvider{name: "primary", completeErr: errors.New("upstream 500")}
+	p2 := &MockProvider{name: "secondary"}
+
+	router := NewRouter([]provider.Provider{p1, p2})
+
+	_, _, err := router.ExecuteWithFallback(context.Background(), &provider.Request{}, p1)
+	if err == nil || err.Error() != "upstream 500" {
+		t.Errorf("Expected primary error without fallback, got %v", err)
+	}
+}
+
+func TestExecuteWithFallback_AllAttemptsFail(t *testing.T) {
+	p1 := &MockProvider{name: "p1", completeErr: errors.New("fail 1")}
+	p2 := &MockProvider{name: "p2", completeErr: errors.New("fail 2")}
+
+	router := NewRouter([]provider.Provider{p1, p2}, WithFallback(2, 0))
+
+	_, _, err := router.ExecuteWithFallback(context.Background(), &provider.Request{}, p1)
+	if err == nil {
+		t.Fatal("Expected error when every attempt fails")
+	}
+}
+
+func TestRouter_DisableAndEnableProvider(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 0.001, supportedModels: []string{"gpt-4"}}
+	pricey := &MockProvider{name: "pricey", cost: 0.01, supportedModels: []string{"gpt-4"}}
+	router := NewRouter([]provider.Provider{cheap, pricey})
+
+	if err := router.DisableProvider("cheap"); err != nil {
+		t.Fatalf("DisableProvider: %v", err)
+	}
+	p, err := router.Route(context.Background(), &provider.Request{Model: "gpt-4"})
+	if err != nil || p.Name() != "pricey" {
+		t.Fatalf("Expected the drained provider to be skipped, got %v, %v", p, err)
+	}
+
+	// Trip the breaker, then check that enabling resets it.
+	cheap.completeErr = errors.New("upstream down")
+	cb := router.breaker("cheap")
+	for i := 0; i < 3; i++ {
+		_, _ = cb.Execute(func() (interface{}, error) { return nil, cheap.completeErr })
+	}
+	if err := router.EnableProvider("cheap"); err != nil {
+		t.Fatalf("EnableProvider: %v", err)
+	}
+	p, _ = router.Route(context.Background(), &provider.Request{Model: "gpt-4"})
+	if p.Name() != "cheap" {
+		t.Errorf("Expected the restored provider to be routed to, got %s", p.Name())
+	}
+
+	statuses := router.ProviderStatuses()
+	if len(statuses) != 2 || statuses[0].State != "closed" || statuses[0].Disabled {
+		t.Errorf("Unexpected statuses %+v", statuses)
+	}
+	if err := router.DisableProvider("mistral"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
 	}
 }
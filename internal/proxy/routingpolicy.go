@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+// WithRoutingPolicies resolves each request's routing strategy, mode and
+// cost cap from the global, plan, tenant and key routing policies. Without
+// it only the tenant's settings apply.
+func WithRoutingPolicies(resolver *policy.RoutingResolver) Option {
+	return func(h *Handler) {
+		h.routing = resolver
+	}
+}
+
+// effectiveRouting is the routing policy for a request made with keyID by a
+// tenant with settings.
+func (h *Handler) effectiveRouting(ctx context.Context, settings *tenant.Settings, keyID string) (*policy.EffectiveRouting, error) {
+	if h.routing == nil {
+		return policy.MergeRouting(settings.Routing()), nil
+	}
+	return h.routing.Resolve(ctx, settings.Plan, settings.Routing(), keyID)
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/shadow"
+)
+
+// WithShadowTraffic replays the share of completions set in each tenant's
+// shadow settings on a second provider/model and stores both answers.
+func WithShadowTraffic(m *shadow.Mirror) Option {
+	return func(h *Handler) {
+		h.shadow = m
+	}
+}
+
+// ExecuteShadow runs req on p outside production routing: it isn't retried,
+// isn't counted by p's circuit breaker, latency tracking or routing
+// strategies, and is skipped while p is unavailable (see available).
+func (r *Router) ExecuteShadow(ctx context.Context, req *provider.Request, p provider.Provider) (*provider.Response, error) {
+	if !r.available(p.Name()) {
+		return nil, fmt.Errorf("provider is unavailable: %s", p.Name())
+	}
+	return p.Complete(ctx, r.resolve(req, p))
+}
+
+// providerNamed returns the configured provider called name.
+func (r *Router) providerNamed(name string) (provider.Provider, bool) {
+	for _, p := range r.providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// mirror samples c for its tenant's shadow traffic and, when picked, replays
+// it in the background next to primary, the answer the client got. Shadow
+// calls aren't rate limited or billed to the tenant.
+func (h *Handler) mirror(c *call, primary shadow.Result) {
+	if h.shadow == nil || !c.settings.Shadow.Sampled() {
+		return
+	}
+	cfg := c.settings.Shadow
+	p, ok := h.router.providerNamed(cfg.Provider)
+	if !ok {
+		c.log.Warn("shadow: mirroring to unknown provider", "shadow_provider", cfg.Provider)
+		return
+	}
+	req := *c.req
+	req.Model = cfg.Model
+	req.Stream = false
+	req.Cache = nil
+	h.shadow.Run(&shadow.Comparison{
+		RequestID: c.requestID,
+		TenantID:  c.tenantID,
+		Primary:   primary,
+		Shadow:    shadow.Result{Provider: p.Name(), Model: cfg.Model},
+	}, func(ctx context.Context) (*provider.Response, float64, error) {
+		resp, err := h.router.ExecuteShadow(ctx, &req, p)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp, h.router.cost(p, cfg.Model, resp.InputTokens, resp.OutputTokens), nil
+	})
+}
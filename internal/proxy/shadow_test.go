@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/shadow"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type mockShadowStore struct {
+	mu    sync.Mutex
+	saved []*shadow.Comparison
+}
+
+func (s *mockShadowStore) SaveComparison(ctx context.Context, c *shadow.Comparison) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, c)
+	return nil
+}
+
+func TestHandleComplete_MirrorsToShadowModel(t *testing.T) {
+	primary := &MockProvider{name: "openai", cost: 0.00000015, supportedModels: []string{"gpt-4o-mini"}}
+	secondary := &MockProvider{name: "gemini", cost: 0.000000075, supportedModels: []string{"gemini-1.5-flash"}}
+	var mu sync.Mutex
+	var logs []*billing.UsageLog
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, log)
+		return nil
+	}}
+	store := &mockShadowStore{}
+	mirror := shadow.NewMirror(store, 0, 0)
+	h := NewHandler(NewRouter([]provider.Provider{primary, secondary}), b,
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithShadowTraffic(mirror),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{
+			Shadow: &shadow.Config{Provider: "gemini", Model: "gemini-1.5-flash", Percent: 100},
+		}}))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hi"}]}`))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"provider":"openai"`) {
+		t.Errorf("Expected the client to get the primary answer, got %s", w.Body.String())
+	}
+	mirror.Close()
+	_ = h.usage.Flush(context.Background())
+
+	if len(store.saved) != 1 {
+		t.Fatalf("Expected one comparison, got %d", len(store.saved))
+	}
+	c := store.saved[0]
+	if c.Primary.Provider != "openai" || c.Shadow.Provider != "gemini" || c.Shadow.Model != "gemini-1.5-flash" {
+		t.Errorf("Unexpected comparison %+v", c)
+	}
+	if c.Shadow.Content != "mock" || c.Shadow.CostUSD == 0 {
+		t.Errorf("Expected the shadow answer and its cost, got %+v", c.Shadow)
+	}
+	if len(logs) != 1 || logs[0].Provider != "openai" {
+		t.Errorf("Expected only the primary request to be billed, got %+v", logs)
+	}
+}
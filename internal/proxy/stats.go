@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+const (
+	// baselineAlpha weights the newest sample in the slower latency EWMA
+	// that stands for a provider's normal latency.
+	baselineAlpha = 0.02
+	// degradedMinSamples are the calls seen before a provider or model can
+	// be judged degraded.
+	degradedMinSamples = 10
+	// statsWeightCap bounds how much one replica's history weighs when
+	// statistics are combined, so long-running replicas don't drown out
+	// what others see now.
+	statsWeightCap = 50
+	// sharedStatsKey is the Redis hash with one field per replica.
+	sharedStatsKey = "routing:stats"
+)
+
+// statsKey is a provider, or one of its models.
+type statsKey struct {
+	provider, model string
+}
+
+// routeStats are rolling statistics of the calls to a provider or model.
+type routeStats struct {
+	LatencyMs  float64 `json:"latency_ms"`  // EWMA of successful completions
+	BaselineMs float64 `json:"baseline_ms"` // slower EWMA of the same
+	ErrorRate  float64 `json:"error_rate"`  // EWMA of failed calls, 0 to 1
+	Samples    int64   `json:"samples"`
+}
+
+func (s routeStats) weight() float64 {
+	return float64(min(s.Samples, statsWeightCap))
+}
+
+// combine averages a and b weighted by their samples. Latencies only
+// average where both have seen a success.
+func combine(a, b routeStats) routeStats {
+	wa, wb := a.weight(), b.weight()
+	if wa+wb == 0 {
+		return a
+	}
+	mix := func(x, y float64) float64 {
+		switch {
+		case x == 0:
+			return y
+		case y == 0:
+			return x
+		}
+		return (x*wa + y*wb) / (wa + wb)
+	}
+	return routeStats{
+		LatencyMs:  mix(a.LatencyMs, b.LatencyMs),
+		BaselineMs: mix(a.BaselineMs, b.BaselineMs),
+		ErrorRate:  (a.ErrorRate*wa + b.ErrorRate*wb) / (wa + wb),
+		Samples:    a.Samples + b.Samples,
+	}
+}
+
+// Degradation is when a provider counts as degraded and is tried after the
+// healthy candidates, before its circuit breaker opens.
+type Degradation struct {
+	ErrorRate     float64 // recent share of failed calls; 0 disables
+	LatencyFactor float64 // recent latency over its normal latency; 0 disables
+}
+
+// latencyTracker keeps rolling latency and error rate per provider and per
+// provider and model, optionally combined with other replicas'.
+type latencyTracker struct {
+	mu     sync.Mutex
+	local  map[statsKey]*routeStats
+	remote map[statsKey]routeStats // other replicas, combined
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{local: make(map[statsKey]*routeStats), remote: make(map[statsKey]routeStats)}
+}
+
+// record adds a call to name running model. A zero d counts toward the error
+// rate only, e.g. for streams, whose duration isn't comparable.
+func (t *latencyTracker) record(name, model string, d time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(statsKey{name, ""}, d, failed)
+	if model != "" {
+		t.add(statsKey{name, model}, d, failed)
+	}
+}
+
+func (t *latencyTracker) add(k statsKey, d time.Duration, failed bool) {
+	s := t.local[k]
+	if s == nil {
+		s = &routeStats{}
+		t.local[k] = s
+	}
+	fail := 0.0
+	if failed {
+		fail = 1
+	}
+	if s.Samples == 0 {
+		s.ErrorRate = fail
+	} else {
+		s.ErrorRate = latencyAlpha*fail + (1-latencyAlpha)*s.ErrorRate
+	}
+	s.Samples++
+	if failed || d <= 0 {
+		return
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	if s.LatencyMs == 0 {
+		s.LatencyMs, s.BaselineMs = ms, ms
+		return
+	}
+	s.LatencyMs = latencyAlpha*ms + (1-latencyAlpha)*s.LatencyMs
+	s.BaselineMs = baselineAlpha*ms + (1-baselineAlpha)*s.BaselineMs
+}
+
+// stats returns what is known about name running model: the model's own
+// statistics once it has enough samples, the provider's otherwise.
+func (t *latencyTracker) stats(name, model string) routeStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if model != "" {
+		if s := t.get(statsKey{name, model}); s.Samples >= degradedMinSamples {
+			return s
+		}
+	}
+	return t.get(statsKey{name, ""})
+}
+
+func (t *latencyTracker) get(k statsKey) routeStats {
+	var s routeStats
+	if local := t.local[k]; local != nil {
+		s = *local
+	}
+	if remote, ok := t.remote[k]; ok {
+		s = combine(s, remote)
+	}
+	return s
+}
+
+// degraded reports whether name's recent calls for model fail or slow down
+// enough to route around it.
+func (t *latencyTracker) degraded(name, model string, d Degradation) bool {
+	s := t.stats(name, model)
+	if s.Samples < degradedMinSamples {
+		return false
+	}
+	if d.ErrorRate > 0 && s.ErrorRate >= d.ErrorRate {
+		return true
+	}
+	return d.LatencyFactor > 0 && s.BaselineMs > 0 && s.LatencyMs >= d.LatencyFactor*s.BaselineMs
+}
+
+// demoteDegraded moves degraded providers after the healthy ones, keeping
+// the order within each group.
+func (r *Router) demoteDegraded(req *provider.Request, ordered []provider.Provider) []provider.Provider {
+	if r.degradation == (Degradation{}) || len(ordered) < 2 {
+		return ordered
+	}
+	healthy := make([]provider.Provider, 0, len(ordered))
+	var degraded []provider.Provider
+	for _, p := range ordered {
+		if r.latency.degraded(p.Name(), r.ModelFor(req, p), r.degradation) {
+			degraded = append(degraded, p)
+		} else {
+			healthy = append(healthy, p)
+		}
+	}
+	return append(healthy, degraded...)
+}
+
+// WithDegradation tries degraded providers last (default: off).
+func WithDegradation(d Degradation) RouterOption {
+	return func(r *Router) {
+		r.degradation = d
+	}
+}
+
+// statsSync shares routing statistics between replicas through Redis.
+type statsSync struct {
+	rdb      *redis.Client
+	instance string
+	interval time.Duration
+}
+
+// sharedStats is one replica's statistics as published to Redis.
+type sharedStats struct {
+	UpdatedAt time.Time          `json:"updated_at"`
+	Stats     []sharedStatsEntry `json:"stats"`
+}
+
+type sharedStatsEntry struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	routeStats
+}
+
+// WithSharedStats combines routing statistics with the other replicas that
+// publish to rdb; RunStatsSync exchanges them every interval. instance
+// names this replica.
+func WithSharedStats(rdb *redis.Client, instance string, interval time.Duration) RouterOption {
+	return func(r *Router) {
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		r.sync = &statsSync{rdb: rdb, instance: instance, interval: interval}
+	}
+}
+
+// RunStatsSync publishes this replica's statistics and takes in the others'
+// every interval until ctx is cancelled. Without WithSharedStats it returns
+// at once.
+func (r *Router) RunStatsSync(ctx context.Context) {
+	if r.sync == nil {
+		return
+	}
+	ticker := time.NewTicker(r.sync.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.syncStats(ctx); err != nil {
+				log.Printf("router: failed to share routing stats: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Router) syncStats(ctx context.Context) error {
+	now := time.Now()
+	own, err := json.Marshal(r.latency.export(now))
+	if err != nil {
+		return err
+	}
+	if err := r.sync.rdb.HSet(ctx, sharedStatsKey, r.sync.instance, own).Err(); err != nil {
+		return err
+	}
+	all, err := r.sync.rdb.HGetAll(ctx, sharedStatsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	remote := make(map[statsKey]routeStats)
+	var stale []string
+	for instance, raw := range all {
+		if instance == r.sync.instance {
+			continue
+		}
+		var s sharedStats
+		if err := json.Unmarshal([]byte(raw), &s); err != nil || now.Sub(s.UpdatedAt) > 3*r.sync.interval {
+			stale = append(stale, instance)
+			continue
+		}
+		for _, e := range s.Stats {
+			k := statsKey{e.Provider, e.Model}
+			remote[k] = combine(remote[k], e.routeStats)
+		}
+	}
+	if len(stale) > 0 {
+		_ = r.sync.rdb.HDel(ctx, sharedStatsKey, stale...).Err()
+	}
+	r.latency.setRemote(remote)
+	return nil
+}
+
+func (t *latencyTracker) export(now time.Time) sharedStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := sharedStats{UpdatedAt: now, Stats: make([]sharedStatsEntry, 0, len(t.local))}
+	for k, s := range t.local {
+		out.Stats = append(out.Stats, sharedStatsEntry{Provider: k.provider, Model: k.model, routeStats: *s})
+	}
+	return out
+}
+
+func (t *latencyTracker) setRemote(remote map[statsKey]routeStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remote = remote
+}
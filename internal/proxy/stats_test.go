@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestRouter_DemotesDegradedProvider(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 1}
+	pricey := &MockProvider{name: "pricey", cost: 5}
+	router := NewRouter([]provider.Provider{cheap, pricey},
+		WithDegradation(Degradation{ErrorRate: 0.5, LatencyFactor: 3}),
+	)
+
+	for i := 0; i < degradedMinSamples; i++ {
+		router.latency.record("cheap", "gpt-4o", 100*time.Millisecond, i%3 != 0)
+	}
+	p, _ := router.Route(context.Background(), &provider.Request{})
+	if p.Name() != "pricey" {
+		t.Errorf("Expected the failing provider to be tried last, got %s", p.Name())
+	}
+	for _, s := range router.ProviderStatuses() {
+		if s.Degraded != (s.Name == "cheap") {
+			t.Errorf("Unexpected degraded flag: %+v", s)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		router.latency.record("cheap", "gpt-4o", 100*time.Millisecond, false)
+	}
+	if p, _ := router.Route(context.Background(), &provider.Request{}); p.Name() != "cheap" {
+		t.Errorf("Expected the recovered provider back first, got %s", p.Name())
+	}
+}
+
+func TestLatencyTracker_DegradedBySlowdown(t *testing.T) {
+	tracker := newLatencyTracker()
+	d := Degradation{LatencyFactor: 3}
+	for i := 0; i < 50; i++ {
+		tracker.record("openai", "gpt-4o", 200*time.Millisecond, false)
+	}
+	if tracker.degraded("openai", "gpt-4o", d) {
+		t.Fatal("Expected steady latency not to count as degraded")
+	}
+	for i := 0; i < 10; i++ {
+		tracker.record("openai", "gpt-4o", 2*time.Second, false)
+	}
+	if !tracker.degraded("openai", "gpt-4o", d) {
+		t.Errorf("Expected a slowdown to count as degraded, got %+v", tracker.stats("openai", "gpt-4o"))
+	}
+}
+
+func TestLatencyStrategy_PerModel(t *testing.T) {
+	a := &MockProvider{name: "a"}
+	b := &MockProvider{name: "b"}
+	tracker := newLatencyTracker()
+	for i := 0; i < degradedMinSamples; i++ {
+		// a is faster overall but slow at the big model.
+		tracker.record("a", "small", 50*time.Millisecond, false)
+		tracker.record("a", "big", 900*time.Millisecond, false)
+		tracker.record("b", "big", 300*time.Millisecond, false)
+	}
+
+	s := latencyStrategy{tracker: tracker}
+	if got := names(s.OrderModel("big", []provider.Provider{a, b})); got[0] != "b" {
+		t.Errorf("Expected b first for the big model, got %v", got)
+	}
+	if got := names(s.OrderModel("small", []provider.Provider{b, a})); got[0] != "a" {
+		t.Errorf("Expected a first for the small model, got %v", got)
+	}
+}
+
+func TestCombine_WeighsReplicasBySamples(t *testing.T) {
+	local := routeStats{LatencyMs: 100, ErrorRate: 0, Samples: 30}
+	remote := routeStats{LatencyMs: 400, ErrorRate: 0.5, Samples: 10}
+	got := combine(local, remote)
+	if math.Abs(got.LatencyMs-175) > 1e-9 || math.Abs(got.ErrorRate-0.125) > 1e-9 || got.Samples != 40 {
+		t.Errorf("Unexpected combined stats: %+v", got)
+	}
+	// A replica with only failures has no latency to average in.
+	if got := combine(local, routeStats{ErrorRate: 1, Samples: 10}); got.LatencyMs != 100 {
+		t.Errorf("Expected latency from successes only, got %+v", got)
+	}
+}
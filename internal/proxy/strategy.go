@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Built-in routing strategies, selected by ROUTING_STRATEGY and overridable
+// per tenant.
+const (
+	StrategyCost     = "cost"
+	StrategyLatency  = "latency"
+	StrategyWeighted = "weighted"
+	StrategyPriority = "priority"
+)
+
+// latencyAlpha weights the newest sample in the latency EWMA.
+const latencyAlpha = 0.2
+
+// RoutingStrategy orders the healthy providers able to serve a request, most
+// preferred first. The router routes to the first and falls back down the
+// list. Order must not modify candidates.
+type RoutingStrategy interface {
+	Order(candidates []provider.Provider) []provider.Provider
+}
+
+// servedObserver is implemented by strategies whose order depends on which
+// provider served the requests they routed.
+type servedObserver interface {
+	served(name string)
+}
+
+// modelStrategy is implemented by strategies whose order depends on the
+// requested model.
+type modelStrategy interface {
+	OrderModel(model string, candidates []provider.Provider) []provider.Provider
+}
+
+// costStrategy prefers the cheapest input price for the requested model;
+// ties keep configuration order.
+type costStrategy struct {
+	price func(p provider.Provider, model string) pricing.Price
+}
+
+func (s costStrategy) Order(candidates []provider.Provider) []provider.Provider {
+	return s.OrderModel("", candidates)
+}
+
+func (s costStrategy) OrderModel(model string, candidates []provider.Provider) []provider.Provider {
+	out := slices.Clone(candidates)
+	sort.SliceStable(out, func(i, j int) bool {
+		return s.price(out[i], model).Input < s.price(out[j], model).Input
+	})
+	return out
+}
+
+// latencyStrategy prefers the lowest observed latency. Providers without
+// samples sort first so they get measured.
+type latencyStrategy struct {
+	tracker *latencyTracker
+}
+
+func (s latencyStrategy) Order(candidates []provider.Provider) []provider.Provider {
+	return s.OrderModel("", candidates)
+}
+
+// OrderModel ranks by the requested model's latency on each provider, or the
+// provider's overall latency until the model has enough samples.
+func (s latencyStrategy) OrderModel(model string, candidates []provider.Provider) []provider.Provider {
+	latency := make(map[string]float64, len(candidates))
+	for _, p := range candidates {
+		latency[p.Name()] = s.tracker.stats(p.Name(), model).LatencyMs
+	}
+	out := slices.Clone(candidates)
+	sort.SliceStable(out, func(i, j int) bool {
+		return latency[out[i].Name()] < latency[out[j].Name()]
+	})
+	return out
+}
+
+// weightedStrategy spreads traffic in proportion to provider weights with
+// smooth weighted round-robin. Order ranks by who is due next; the rotation
+// only advances when a provider actually serves a request.
+type weightedStrategy struct {
+	weights map[string]int
+
+	mu      sync.Mutex
+	current map[string]int
+}
+
+// NewWeightedStrategy routes by weighted round-robin. Providers missing from
+// weights count as 1; a weight of 0 keeps a provider for fallback only.
+func NewWeightedStrategy(weights map[string]int) RoutingStrategy {
+	return &weightedStrategy{weights: weights, current: make(map[string]int)}
+}
+
+func (s *weightedStrategy) weight(name string) int {
+	if w, ok := s.weights[name]; ok {
+		return w
+	}
+	return 1
+}
+
+func (s *weightedStrategy) Order(candidates []provider.Provider) []provider.Provider {
+	s.mu.Lock()
+	score := make(map[string]int, len(candidates))
+	for _, p := range candidates {
+		if _, ok := s.current[p.Name()]; !ok {
+			s.current[p.Name()] = 0
+		}
+		score[p.Name()] = s.current[p.Name()] + s.weight(p.Name())
+	}
+	s.mu.Unlock()
+
+	out := slices.Clone(candidates)
+	sort.SliceStable(out, func(i, j int) bool {
+		return score[out[i].Name()] > score[out[j].Name()]
+	})
+	return out
+}
+
+func (s *weightedStrategy) served(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.current[name]; !ok {
+		s.current[name] = 0
+	}
+	total := 0
+	for n := range s.current {
+		s.current[n] += s.weight(n)
+		total += s.weight(n)
+	}
+	s.current[name] -= total
+}
+
+// priorityStrategy follows a fixed provider list; unlisted providers come
+// after it in configuration order.
+type priorityStrategy struct {
+	rank map[string]int
+}
+
+// NewPriorityStrategy routes to providers in the order of names.
+func NewPriorityStrategy(names []string) RoutingStrategy {
+	rank := make(map[string]int, len(names))
+	for i, n := range names {
+		rank[n] = i
+	}
+	return priorityStrategy{rank: rank}
+}
+
+func (s priorityStrategy) Order(candidates []provider.Provider) []provider.Provider {
+	rankOf := func(p provider.Provider) int {
+		if r, ok := s.rank[p.Name()]; ok {
+			return r
+		}
+		return len(s.rank)
+	}
+	out := slices.Clone(candidates)
+	sort.SliceStable(out, func(i, j int) bool {
+		return rankOf(out[i]) < rankOf(out[j])
+	})
+	return out
+}
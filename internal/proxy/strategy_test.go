@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func names(ps []provider.Provider) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.Name()
+	}
+	return out
+}
+
+func TestRoute_CostOrdersExplicitModel(t *testing.T) {
+	p1 := &MockProvider{name: "expensive", cost: 10, supportedModels: []string{"llama-3"}}
+	p2 := &MockProvider{name: "cheap", cost: 1, supportedModels: []string{"llama-3"}}
+
+	router := NewRouter([]provider.Provider{p1, p2})
+
+	p, err := router.Route(context.Background(), &provider.Request{Model: "llama-3"})
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if p.Name() != "cheap" {
+		t.Errorf("Expected cheap, got %s", p.Name())
+	}
+}
+
+func TestLatencyStrategy_PrefersLowestEWMA(t *testing.T) {
+	slow := &MockProvider{name: "slow"}
+	fast := &MockProvider{name: "fast"}
+	fresh := &MockProvider{name: "fresh"}
+
+	tracker := newLatencyTracker()
+	tracker.record("slow", "", 900*time.Millisecond, false)
+	tracker.record("fast", "", 100*time.Millisecond, false)
+	// One slow sample only nudges the average.
+	tracker.record("fast", "", 500*time.Millisecond, false)
+
+	got := names(latencyStrategy{tracker: tracker}.Order([]provider.Provider{slow, fast, fresh}))
+	want := []string{"fresh", "fast", "slow"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWeightedStrategy_SplitsByWeight(t *testing.T) {
+	a := &MockProvider{name: "a"}
+	b := &MockProvider{name: "b"}
+	router := NewRouter([]provider.Provider{a, b},
+		WithRoutingStrategy(StrategyWeighted, NewWeightedStrategy(map[string]int{"a": 3, "b": 1})),
+		WithDefaultStrategy(StrategyWeighted),
+	)
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		req := &provider.Request{}
+		p, err := router.Route(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Route failed: %v", err)
+		}
+		if _, _, err := router.ExecuteWithFallback(context.Background(), req, p); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		counts[p.Name()]++
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("counts = %v, want a:6 b:2", counts)
+	}
+}
+
+func TestPriorityStrategy_ListedFirst(t *testing.T) {
+	a := &MockProvider{name: "a"}
+	b := &MockProvider{name: "b"}
+	c := &MockProvider{name: "c"}
+
+	got := names(NewPriorityStrategy([]string{"c", "a"}).Order([]provider.Provider{a, b, c}))
+	want := []string{"c", "a", "b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRoute_TenantStrategyOverride(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 1}
+	preferred := &MockProvider{name: "preferred", cost: 5}
+	router := NewRouter([]provider.Provider{cheap, preferred},
+		WithRoutingStrategy(StrategyPriority, NewPriorityStrategy([]string{"preferred"})),
+	)
+
+	p, _ := router.Route(context.Background(), &provider.Request{})
+	if p.Name() != "cheap" {
+		t.Errorf("Expected default cost strategy to pick cheap, got %s", p.Name())
+	}
+	p, _ = router.Route(context.Background(), &provider.Request{RoutingStrategy: StrategyPriority})
+	if p.Name() != "preferred" {
+		t.Errorf("Expected tenant priority strategy to pick preferred, got %s", p.Name())
+	}
+	p, _ = router.Route(context.Background(), &provider.Request{RoutingStrategy: "unknown"})
+	if p.Name() != "cheap" {
+		t.Errorf("Expected unknown strategy to fall back to default, got %s", p.Name())
+	}
+}
+
+func TestRouter_ReconfiguresStrategies(t *testing.T) {
+	cheap := &MockProvider{name: "cheap", cost: 1}
+	preferred := &MockProvider{name: "preferred", cost: 5}
+	router := NewRouter([]provider.Provider{cheap, preferred})
+
+	router.SetRoutingStrategy(StrategyPriority, NewPriorityStrategy([]string{"preferred"}))
+	if err := router.SetDefaultStrategy(StrategyPriority); err != nil {
+		t.Fatalf("SetDefaultStrategy: %v", err)
+	}
+	if p, _ := router.Route(context.Background(), &provider.Request{}); p.Name() != "preferred" {
+		t.Errorf("Expected the new default to pick preferred, got %s", p.Name())
+	}
+	if err := router.SetDefaultStrategy("unknown"); err == nil {
+		t.Error("Expected an unknown strategy to be rejected")
+	}
+	if p, _ := router.Route(context.Background(), &provider.Request{}); p.Name() != "preferred" {
+		t.Errorf("Expected the default to be kept, got %s", p.Name())
+	}
+}
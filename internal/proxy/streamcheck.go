@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/jsonstream"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Derailed stream actions, as recorded in metrics.
+const (
+	derailActionRetried = "retried"
+	derailActionFailed  = "failed"
+)
+
+var errInvalidJSONOutput = errors.New("output does not match response_format")
+
+// streamCheck validates a structured-output stream against its
+// response_format as it is relayed (see tenant.Settings.StreamValidation).
+type streamCheck struct {
+	schema      json.RawMessage
+	validator   *jsonstream.Validator
+	attempts    int // streams started so far
+	maxAttempts int
+	stop        context.CancelFunc // stops the current attempt
+	// abortedTokens were used by restarted attempts; they count toward
+	// the request's rate limit charge.
+	abortedTokens int
+}
+
+// newStreamCheck returns a check for c's stream, or nil when its tenant
+// doesn't validate streams or it didn't ask for JSON. json_object output
+// is checked as any JSON object.
+func newStreamCheck(c *call) *streamCheck {
+	policy := c.settings.StreamValidation
+	if policy == nil || !c.req.ResponseFormat.WantsJSON() {
+		return nil
+	}
+	if err := policy.Validate(); err != nil {
+		c.log.Warn("jsonstream: invalid policy", "err", err)
+		return nil
+	}
+	schema := c.req.ResponseFormat.Schema()
+	if schema == nil {
+		schema = json.RawMessage(`{"type":"object"}`)
+	}
+	return &streamCheck{
+		schema:      schema,
+		validator:   jsonstream.NewValidator(schema),
+		attempts:    1,
+		maxAttempts: policy.MaxAttempts(),
+	}
+}
+
+// attempt returns the context for an upstream attempt, a child of ctx
+// that s.stop cancels.
+func (s *streamCheck) attempt(ctx context.Context) context.Context {
+	if s == nil {
+		return ctx
+	}
+	ctx, s.stop = context.WithCancel(ctx)
+	return ctx
+}
+
+// check validates the next delta, returning the part of it that may be
+// relayed and, once the output has derailed, an error wrapping
+// errInvalidJSONOutput.
+func (s *streamCheck) check(delta string) (string, error) {
+	if s == nil {
+		return delta, nil
+	}
+	n, err := s.validator.WriteString(delta)
+	if err != nil {
+		return delta[:n], fmt.Errorf("%w: %v", errInvalidJSONOutput, err)
+	}
+	return delta, nil
+}
+
+// canRestart reports whether a derailed stream can start over without the
+// client noticing: retries are left and nothing but whitespace was relayed.
+func (s *streamCheck) canRestart(toolCallsRelayed bool) bool {
+	return s.attempts < s.maxAttempts && !s.validator.Started() && !toolCallsRelayed
+}
+
+// extraTokens is what restarted attempts used, 0 for unchecked streams.
+func (s *streamCheck) extraTokens() int {
+	if s == nil {
+		return 0
+	}
+	return s.abortedTokens
+}
+
+// restartStream starts c's stream again under ctx after the caller stopped
+// a derailed attempt, and bills that attempt for its prompt and output. If
+// the restart fails the attempt is left for the caller to bill.
+func (h *Handler) restartStream(ctx context.Context, c *call, s *streamCheck, served provider.Provider, output string, started time.Time) (*Stream, provider.Provider, error) {
+	stream, next, err := h.router.ExecuteStreamWithFallback(s.attempt(ctx), c.req, c.provider)
+	if err != nil {
+		s.stop()
+		return nil, nil, err
+	}
+
+	resp := &provider.Response{Model: h.router.ModelFor(c.req, served), OutputTokens: provider.EstimateTokens(output)}
+	for _, m := range c.req.Messages {
+		resp.InputTokens += provider.EstimateTokens(m.Content)
+	}
+	h.logStageUsage(context.WithoutCancel(ctx), c, billing.StageJSONRetry, served, resp.Model, resp, time.Since(started), nil)
+	h.metrics.recordStreamDerailed(ctx, c.tenantID, derailActionRetried)
+	s.abortedTokens += resp.InputTokens + resp.OutputTokens
+
+	s.attempts++
+	s.validator = jsonstream.NewValidator(s.schema)
+	return stream, next, nil
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/jsonstream"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// scriptedStreamProvider streams attempts[i] on its i-th call, stopping
+// when the stream is cancelled.
+type scriptedStreamProvider struct {
+	MockProvider
+	attempts [][]string
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *scriptedStreamProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	p.mu.Lock()
+	deltas := p.attempts[p.calls]
+	p.calls++
+	p.mu.Unlock()
+
+	ch := make(chan *provider.Chunk)
+	go func() {
+		defer close(ch)
+		for _, d := range append(deltas, "") {
+			chunk := &provider.Chunk{Delta: d, Done: d == "", FinishReason: "stop"}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func streamWithValidation(t *testing.T, attempts ...[]string) (string, *scriptedStreamProvider, []*billing.UsageLog) {
+	t.Helper()
+	p := &scriptedStreamProvider{MockProvider: MockProvider{name: "test-provider", cost: 0.001, supportedModels: []string{"gpt-4"}}, attempts: attempts}
+	var mu sync.Mutex
+	var logs []*billing.UsageLog
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, log)
+		return nil
+	}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), b,
+		ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true}), noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(&mockTenantStore{settings: &tenant.Settings{StreamValidation: &jsonstream.Policy{}}}))
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"who?"}],"response_format":{"type":"json_schema","json_schema":{"name":"person","schema":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}}}}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleCompleteStream(w, req)
+	_ = h.usage.Flush(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	return w.Body.String(), p, logs
+}
+
+func TestHandleCompleteStream_RetriesDerailedJSON(t *testing.T) {
+	out, p, logs := streamWithValidation(t,
+		[]string{"Sure! ", `{"name": "Ada"}`},
+		[]string{`{"name": `, `"Ada"}`},
+	)
+
+	if p.calls != 2 {
+		t.Fatalf("Expected the stream restarted once, got %d calls: %s", p.calls, out)
+	}
+	if strings.Contains(out, "Sure") || !strings.Contains(out, `\"Ada\"}`) || !strings.Contains(out, "[DONE]") {
+		t.Errorf("Expected only the retried output relayed, got %s", out)
+	}
+	stages := map[string]bool{}
+	for _, l := range logs {
+		stages[l.Stage] = true
+	}
+	if len(logs) != 2 || !stages[""] || !stages[billing.StageJSONRetry] {
+		t.Errorf("Expected the aborted attempt billed as %s, got %+v", billing.StageJSONRetry, logs)
+	}
+}
+
+func TestHandleCompleteStream_FailsJSONDerailedMidDocument(t *testing.T) {
+	out, p, _ := streamWithValidation(t, []string{`{"name": `, `42}`})
+
+	if p.calls != 1 {
+		t.Fatalf("Expected no restart once output was relayed, got %d calls", p.calls)
+	}
+	if !strings.Contains(out, `{\"name\": `) || strings.Contains(out, "42}") {
+		t.Errorf("Expected the valid prefix relayed and nothing after it, got %s", out)
+	}
+	if !strings.Contains(out, "event: error") || !strings.Contains(out, `"code":"invalid_json_output"`) || strings.Contains(out, "[DONE]") {
+		t.Errorf("Expected the stream to end with an invalid_json_output error, got %s", out)
+	}
+}
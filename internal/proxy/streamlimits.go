@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// rawFinishMaxDuration is the raw finish reason of streams ended by their
+// duration cap.
+const rawFinishMaxDuration = "max_stream_duration"
+
+// StreamLimits bound long streamed completions. Zero values disable them.
+type StreamLimits struct {
+	// MaxDuration ends streams that run longer, as if the model had hit
+	// max_tokens; tenants may set their own with max_stream_seconds.
+	MaxDuration time.Duration
+	// CheckpointInterval logs the usage of a stream still running this
+	// often, so a crash late in a long stream loses little of its usage.
+	CheckpointInterval time.Duration
+}
+
+// WithStreamLimits caps stream durations and bills long streams as they go.
+func WithStreamLimits(limits StreamLimits) Option {
+	return func(h *Handler) {
+		h.streamLimits = limits
+	}
+}
+
+// maxStreamDuration is how long c's stream may run, 0 for no limit.
+func (h *Handler) maxStreamDuration(c *call) time.Duration {
+	if c.settings.MaxStreamSeconds > 0 {
+		return time.Duration(c.settings.MaxStreamSeconds) * time.Second
+	}
+	return h.streamLimits.MaxDuration
+}
+
+// streamCheckpoints bills a stream's usage while it runs. Each checkpoint
+// logs the estimated tokens since the last one; the final usage log then
+// only carries what the checkpoints haven't.
+type streamCheckpoints struct {
+	h      *Handler
+	c      *call
+	start  time.Time
+	billed provider.Usage
+}
+
+// checkpoint logs the usage of the stream so far, estimated from the prompt
+// and the content relayed, less what was already billed.
+func (s *streamCheckpoints) checkpoint(ctx context.Context, served provider.Provider, content string) {
+	var estimate provider.Usage
+	for _, m := range s.c.req.Messages {
+		estimate.InputTokens += provider.EstimateTokens(m.Content)
+	}
+	estimate.OutputTokens = provider.EstimateTokens(content)
+	delta := s.remainder(&estimate)
+	if delta.InputTokens == 0 && delta.OutputTokens == 0 {
+		return
+	}
+	s.billed.InputTokens += delta.InputTokens
+	s.billed.OutputTokens += delta.OutputTokens
+
+	model := s.h.router.ModelFor(s.c.req, served)
+	costUSD := s.h.router.cost(served, model, delta.InputTokens, delta.OutputTokens)
+	s.h.metrics.recordUsage(ctx, s.c.tenantID, served.Name(), model, delta.InputTokens, delta.OutputTokens, costUSD)
+	s.h.usage.Record(ctx, &billing.UsageLog{
+		TenantID:        s.c.tenantID,
+		APIKeyID:        s.c.req.APIKeyID,
+		RequestID:       s.c.requestID,
+		Provider:        served.Name(),
+		Model:           model,
+		InputTokens:     delta.InputTokens,
+		OutputTokens:    delta.OutputTokens,
+		CostUSD:         costUSD,
+		LatencyMs:       time.Since(s.start).Milliseconds(),
+		RetrievedDocIDs: s.c.req.RetrievedDocIDs,
+		Stage:           billing.StageStreamCheckpoint,
+		Synthetic:       s.c.req.Synthetic,
+	})
+}
+
+// remainder is what of usage the checkpoints haven't billed. Checkpoints
+// are estimates, so a stream can end having used less than they billed;
+// nothing is refunded then.
+func (s *streamCheckpoints) remainder(usage *provider.Usage) provider.Usage {
+	return provider.Usage{
+		InputTokens:  max(usage.InputTokens-s.billed.InputTokens, 0),
+		OutputTokens: max(usage.OutputTokens-s.billed.OutputTokens, 0),
+	}
+}
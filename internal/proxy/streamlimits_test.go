@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestHandleCompleteStream_DurationCapAndCheckpoints(t *testing.T) {
+	p := &stallingStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", cost: 0.5, supportedModels: []string{"gpt-4"}},
+	}
+	var mu sync.Mutex
+	var logs []*billing.UsageLog
+	b := &mockBillingStore{logUsageFunc: func(ctx context.Context, log *billing.UsageLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		logs = append(logs, log)
+		return nil
+	}}
+	limiter := ratelimit.NewTestLimiter(&mockLimiterStore{allowed: true})
+	// The tenant's cap wins over the gateway's.
+	tenants := &mockTenantStore{settings: &tenant.Settings{MaxStreamSeconds: 1}}
+	h := NewHandler(NewRouter([]provider.Provider{p}), b, limiter, noop.NewTracerProvider().Tracer("test"),
+		WithTenantSettings(tenants),
+		WithStreamLimits(StreamLimits{MaxDuration: time.Hour, CheckpointInterval: 100 * time.Millisecond}))
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"12345678"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions/stream", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+
+	finished := make(chan struct{})
+	go func() {
+		h.HandleCompleteStream(w, req)
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end at its duration cap")
+	}
+	_ = h.usage.Flush(context.Background())
+
+	out := w.Body.String()
+	if !strings.Contains(out, `"finish_reason":"length"`) || !strings.Contains(out, rawFinishMaxDuration) || !strings.Contains(out, "data: [DONE]") {
+		t.Errorf("Expected the stream to close as truncated, got %s", out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var checkpoints int
+	var total provider.Usage
+	for _, l := range logs {
+		total.InputTokens += l.InputTokens
+		total.OutputTokens += l.OutputTokens
+		if l.Stage == billing.StageStreamCheckpoint {
+			checkpoints++
+		}
+	}
+	if checkpoints == 0 {
+		t.Errorf("Expected usage checkpoints while the stream ran, got %+v", logs)
+	}
+	if last := logs[len(logs)-1]; last.Stage != "" || last.FinishReason != billing.FinishReasonMaxDuration {
+		t.Errorf("Expected a final log marked %q, got %+v", billing.FinishReasonMaxDuration, last)
+	}
+	// "12345678" is 2 estimated tokens and "hello world" 3, billed once
+	// across the checkpoints and the final log.
+	if total != (provider.Usage{InputTokens: 2, OutputTokens: 3}) {
+		t.Errorf("Expected usage to add up to the stream's, got %+v", total)
+	}
+}
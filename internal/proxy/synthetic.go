@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// headerSynthetic marks a request as synthetic monitoring traffic, e.g. a
+// canary or red-team probe. It runs through the full pipeline but its usage
+// is kept out of the tenant's usage reports, spend and bills.
+const headerSynthetic = "X-Synthetic-Traffic"
+
+var errSyntheticNotAllowed = errors.New("API key may not send synthetic traffic")
+
+// syntheticTraffic reads X-Synthetic-Traffic. Only keys explicitly granted
+// the traffic:synthetic scope may set it, so customers can't opt out of
+// billing with a header.
+func syntheticTraffic(r *http.Request) (bool, error) {
+	raw := r.Header.Get(headerSynthetic)
+	if raw == "" {
+		return false, nil
+	}
+	synthetic, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("invalid " + headerSynthetic + ": must be true or false")
+	}
+	if synthetic && !auth.GrantedExactly(auth.GetScopes(r.Context()), auth.ScopeSynthetic) {
+		return false, errSyntheticNotAllowed
+	}
+	return synthetic, nil
+}
+
+// writeSyntheticError rejects a request whose X-Synthetic-Traffic was
+// malformed (400) or not allowed for its key (403).
+func writeSyntheticError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errSyntheticNotAllowed) {
+		status = http.StatusForbidden
+	}
+	apierror.Write(w, status, "", err.Error())
+}
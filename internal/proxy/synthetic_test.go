@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func syntheticRequest(body string, scopes []string, header string) *http.Request {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	if header != "" {
+		req.Header.Set("X-Synthetic-Traffic", header)
+	}
+	ctx := auth.WithScopes(auth.WithTenantID(req.Context(), "test-tenant"), scopes)
+	return req.WithContext(ctx)
+}
+
+func TestHandleComplete_SyntheticTraffic(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		header    string
+		body      string
+		status    int
+		synthetic bool
+	}{
+		{
+			name:      "granted key",
+			scopes:    []string{auth.ScopeChatWrite, auth.ScopeSynthetic},
+			header:    "true",
+			status:    http.StatusOK,
+			synthetic: true,
+		},
+		{
+			name:   "wildcard key",
+			scopes: []string{auth.ScopeAll},
+			header: "true",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "key without scopes",
+			header: "true",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "malformed header",
+			scopes: []string{auth.ScopeSynthetic},
+			header: "yes please",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "flag in the body",
+			scopes: []string{auth.ScopeSynthetic},
+			body:   `{"model":"gpt-4","Synthetic":true,"messages":[{"role":"user","content":"hi"}]}`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}}
+			h, b := setupTest([]provider.Provider{p}, true)
+			logged := make(chan *billing.UsageLog, 1)
+			b.logUsageFunc = func(ctx context.Context, log *billing.UsageLog) error {
+				logged <- log
+				return nil
+			}
+
+			body := tt.body
+			if body == "" {
+				body = `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+			}
+			w := httptest.NewRecorder()
+			h.HandleComplete(w, syntheticRequest(body, tt.scopes, tt.header))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			select {
+			case log := <-logged:
+				if log.Synthetic != tt.synthetic {
+					t.Errorf("Expected synthetic=%v, got %v", tt.synthetic, log.Synthetic)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected usage to be logged")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stream is an upstream stream opened by the router. The caller reads
+// chunks from C; the router tees every chunk the upstream sends into a
+// tally, including those sent after the caller stopped reading, so billing
+// and tracing don't depend on how the caller's loop ended.
+type Stream struct {
+	C     <-chan *provider.Chunk
+	tally *streamTally
+}
+
+// StreamTally is what an upstream stream produced.
+type StreamTally struct {
+	Output    string // content deltas, concatenated
+	ToolCalls int
+	// Usage is what the upstream reported with its final chunk; nil when it
+	// reported none or didn't finish.
+	Usage           *provider.Usage
+	FinishReason    string // normalized; empty unless the stream finished
+	RawFinishReason string
+	Err             error
+	Started         time.Time     // when the stream was opened
+	TTFT            time.Duration // until the first content or tool call; 0 without any
+	Latency         time.Duration // until the last chunk
+	// Ended is false when Tally gave up waiting and returned what the
+	// upstream had sent so far.
+	Ended bool
+}
+
+// OutputTokens is the output the upstream reported, or an estimate from the
+// deltas when it reported none.
+func (t StreamTally) OutputTokens() int {
+	if t.Usage != nil {
+		return t.Usage.OutputTokens
+	}
+	return provider.EstimateTokens(t.Output)
+}
+
+type streamTally struct {
+	mu     sync.Mutex
+	t      StreamTally
+	output strings.Builder
+	ended  chan struct{}
+}
+
+func newStreamTally(started time.Time) *streamTally {
+	return &streamTally{t: StreamTally{Started: started}, ended: make(chan struct{})}
+}
+
+func (s *streamTally) add(chunk *provider.Chunk, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.TTFT == 0 && (chunk.Delta != "" || len(chunk.ToolCalls) > 0) {
+		s.t.TTFT = now.Sub(s.t.Started)
+	}
+	s.output.WriteString(chunk.Delta)
+	s.t.ToolCalls += len(chunk.ToolCalls)
+	s.t.Latency = now.Sub(s.t.Started)
+	switch {
+	case chunk.Err != nil:
+		s.t.Err = chunk.Err
+	case chunk.Done:
+		s.t.Usage = chunk.Usage
+		s.t.FinishReason, s.t.RawFinishReason = chunk.FinishReason, chunk.RawFinishReason
+	}
+}
+
+// end marks the upstream stream closed.
+func (s *streamTally) end() {
+	close(s.ended)
+}
+
+func (s *streamTally) snapshot(ended bool) StreamTally {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.t
+	t.Output = s.output.String()
+	t.Ended = ended
+	return t
+}
+
+// Tally waits up to wait for the upstream to close the stream and returns
+// everything it sent. An upstream that ignores cancellation can't hold the
+// caller up longer than wait; the tally then has what arrived until then.
+func (s *Stream) Tally(wait time.Duration) StreamTally {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-s.tally.ended:
+		return s.tally.snapshot(true)
+	case <-timer.C:
+		return s.tally.snapshot(false)
+	}
+}
+
+// streamTallyWait bounds how long a finished or abandoned stream's handler
+// waits for the upstream to close it before billing what it has.
+const streamTallyWait = 5 * time.Second
+
+// traceStream records a stream's upstream side as a span covering it from
+// opening to its last chunk. cutShort is the billing finish reason when the
+// gateway ended the stream early, e.g. because the client disconnected.
+func (h *Handler) traceStream(ctx context.Context, providerName, model string, t StreamTally, cutShort string) {
+	_, span := h.tracer.Start(ctx, "proxy.stream", trace.WithTimestamp(t.Started))
+	span.SetAttributes(
+		attribute.String("provider", providerName),
+		attribute.String("model", model),
+		attribute.Int("output_tokens", t.OutputTokens()),
+		attribute.Int64("ttft_ms", t.TTFT.Milliseconds()),
+		attribute.String("finish_reason", t.FinishReason),
+		attribute.Bool("upstream_ended", t.Ended),
+	)
+	if cutShort != "" {
+		span.SetAttributes(attribute.String("cut_short", cutShort))
+	}
+	if t.Err != nil {
+		span.RecordError(t.Err)
+		span.SetStatus(codes.Error, t.Err.Error())
+	}
+	span.End(trace.WithTimestamp(t.Started.Add(t.Latency)))
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestExecuteStream_TalliesChunksTheCallerNeverRead(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks: []*provider.Chunk{
+			{Delta: "hello"},
+			{Delta: " world"},
+			{Done: true, RawFinishReason: "stop", Usage: &provider.Usage{InputTokens: 3, OutputTokens: 2}},
+		},
+	}
+	router := NewRouter([]provider.Provider{p})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := router.ExecuteStream(ctx, &provider.Request{Model: "gpt-4"}, p)
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	// The client reads one chunk and goes away.
+	<-stream.C
+	cancel()
+
+	tally := stream.Tally(time.Second)
+	if !tally.Ended {
+		t.Fatal("Expected the upstream to have closed the stream")
+	}
+	if tally.Output != "hello world" || tally.Usage == nil || tally.Usage.OutputTokens != 2 {
+		t.Errorf("Expected everything the upstream sent, got %+v", tally)
+	}
+	if tally.FinishReason != provider.FinishStop {
+		t.Errorf("Expected the finish reason, got %q", tally.FinishReason)
+	}
+	if tally.TTFT <= 0 || tally.Latency < tally.TTFT {
+		t.Errorf("Expected time to first token within the latency, got %v and %v", tally.TTFT, tally.Latency)
+	}
+}
+
+func TestStream_TallyStopsWaiting(t *testing.T) {
+	tally := newStreamTally(time.Now())
+	tally.add(&provider.Chunk{Delta: "partial"}, time.Now())
+	got := (&Stream{tally: tally}).Tally(10 * time.Millisecond)
+	if got.Ended || got.Output != "partial" || got.OutputTokens() == 0 {
+		t.Errorf("Expected what arrived so far from a stream still open, got %+v", got)
+	}
+}
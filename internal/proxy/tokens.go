@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	"github.com/vnmchuo/llm-gateway/pkg/tokenizer"
+)
+
+// defaultOutputTokens is charged for output when the client sets no
+// max_tokens; reconciliation settles the difference once the reply is in.
+const defaultOutputTokens = 1000
+
+// promptTokens counts req's prompt with its model's tokenizer: message
+// contents, tool call arguments and tool definitions, plus chat framing.
+func promptTokens(req *provider.Request) int {
+	tk := tokenizer.ForModel(req.Model)
+	n := tokenizer.PerReply
+	for _, m := range req.Messages {
+		n += tokenizer.PerMessage + tk.Count(m.Role) + tk.Count(m.Content)
+		for _, tc := range m.ToolCalls {
+			n += tk.Count(tc.Function.Name) + tk.Count(tc.Function.Arguments)
+		}
+	}
+	for _, t := range req.Tools {
+		n += tk.Count(t.Function.Name) + tk.Count(t.Function.Description) + tk.Count(string(t.Function.Parameters))
+	}
+	return n
+}
+
+// rateLimitTokens is what a request is charged up front: its prompt plus the
+// most output it may produce.
+func rateLimitTokens(req *provider.Request) int {
+	output := req.MaxTokens
+	if output <= 0 {
+		output = defaultOutputTokens
+	}
+	return promptTokens(req) + output
+}
+
+// Quota headers on completion responses, so clients can back off and show
+// costs without calling /v1/usage.
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"     // tokens per window
+	headerRateLimitRemaining = "X-RateLimit-Remaining" // tokens left in the window
+	headerRateLimitReset     = "X-RateLimit-Reset"     // seconds until the window frees up
+	headerRequestCost        = "X-Request-Cost-USD"
+)
+
+// setQuotaHeaders reports c's token window as it stands after c was
+// charged. The headers are left out when the limiter can't say.
+func (h *Handler) setQuotaHeaders(ctx context.Context, w http.ResponseWriter, c *call) {
+	status, err := h.limiter.Status(ctx, c.limit)
+	if err != nil || status == nil || status.Limit <= 0 {
+		return
+	}
+	w.Header().Set(headerRateLimitLimit, strconv.Itoa(status.Limit))
+	w.Header().Set(headerRateLimitRemaining, strconv.FormatInt(max(status.Remaining, 0), 10))
+	w.Header().Set(headerRateLimitReset, strconv.FormatInt(int64(math.Ceil(status.ResetAfter.Seconds())), 10))
+}
+
+// writeRateLimited rejects a request over its rate limit.
+func writeRateLimited(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "60s")
+	apierror.WriteError(w, http.StatusTooManyRequests, apierror.Error{
+		Message: "rate limit exceeded",
+		Code:    apierror.CodeRateLimitExceeded,
+		Details: map[string]any{"retry_after": "60s"},
+	})
+}
+
+// rateLimitSubject limits requests per API key, at the key's own limits, or
+// per tenant when the request carries no key; batch priority requests in
+// the batch pool.
+func rateLimitSubject(ctx context.Context, tenantID, priority string) ratelimit.Subject {
+	limits := auth.GetRateLimits(ctx)
+	return ratelimit.Subject{
+		TenantID: tenantID,
+		KeyID:    auth.GetAPIKeyID(ctx),
+		TPM:      limits.TPM,
+		RPM:      limits.RPM,
+		Batch:    priority == provider.PriorityBatch,
+	}
+}
+
+// reconcileTokens settles c's up-front charge against the tokens it really
+// used. Failures only leave the estimate in place, so they are logged.
+func (h *Handler) reconcileTokens(ctx context.Context, c *call, actual int) {
+	if err := h.limiter.Reconcile(context.WithoutCancel(ctx), c.limit, c.charged, actual); err != nil {
+		c.log.Warn("ratelimit: failed to reconcile", "err", err)
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+	extratelimit "github.com/vnmchuo/ratelimiter"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRateLimitTokens_CountsPromptPlusOutput(t *testing.T) {
+	req := &provider.Request{
+		Model:     "gpt-4",
+		Messages:  []provider.Message{{Role: "user", Content: "hello world"}},
+		MaxTokens: 50,
+	}
+	// 3 reply priming + 3 message framing + "user" (1) + "hello world" (2).
+	if got := rateLimitTokens(req); got != 59 {
+		t.Errorf("Expected 59 tokens, got %d", got)
+	}
+
+	req.MaxTokens = 0
+	if got := rateLimitTokens(req); got != 9+defaultOutputTokens {
+		t.Errorf("Expected default output allowance, got %d", got)
+	}
+
+	long := &provider.Request{Model: "gpt-4", Messages: []provider.Message{
+		{Role: "user", Content: "hello world, this prompt is a fair bit longer than the first one"},
+	}}
+	if rateLimitTokens(long) <= rateLimitTokens(req) {
+		t.Error("Expected a longer prompt to be charged more")
+	}
+}
+
+func TestRateLimitSubject_UsesKeyLimits(t *testing.T) {
+	ctx := auth.WithAPIKeyID(context.Background(), "k1")
+	ctx = auth.WithRateLimits(ctx, auth.RateLimits{TPM: 5000, RPM: 10})
+
+	got := rateLimitSubject(ctx, "t1", provider.PriorityInteractive)
+	want := ratelimit.Subject{TenantID: "t1", KeyID: "k1", TPM: 5000, RPM: 10}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got := rateLimitSubject(context.Background(), "t1", provider.PriorityInteractive); got != (ratelimit.Subject{TenantID: "t1"}) {
+		t.Errorf("Expected tenant subject without a key, got %+v", got)
+	}
+	if got := rateLimitSubject(ctx, "t1", provider.PriorityBatch); !got.Batch {
+		t.Errorf("Expected batch requests in the batch pool, got %+v", got)
+	}
+}
+
+// windowLimiterStore reports a token window that is partly used.
+type windowLimiterStore struct {
+	mockLimiterStore
+}
+
+func (m *windowLimiterStore) Status(ctx context.Context, key string) (*extratelimit.Result, error) {
+	return &extratelimit.Result{Allowed: true, Limit: 10000, Remaining: 9200, ResetAfter: 41500 * time.Millisecond}, nil
+}
+
+func TestHandleComplete_QuotaHeaders(t *testing.T) {
+	p := &MockProvider{name: "test-provider", cost: 0.001, supportedModels: []string{"gpt-4"}}
+	limiter := ratelimit.NewTestLimiter(&windowLimiterStore{mockLimiterStore{allowed: true}})
+	h := NewHandler(NewRouter([]provider.Provider{p}), &mockBillingStore{}, limiter, noop.NewTracerProvider().Tracer("test"))
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hello"}]}`
+	for _, handle := range []http.HandlerFunc{h.HandleComplete, h.HandleCompleteStream} {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+		w := httptest.NewRecorder()
+		handle(w, req)
+
+		if w.Header().Get("X-RateLimit-Limit") != "10000" || w.Header().Get("X-RateLimit-Remaining") != "9200" || w.Header().Get("X-RateLimit-Reset") != "42" {
+			t.Errorf("Expected the token window in the headers, got %v", w.Header())
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+	w := httptest.NewRecorder()
+	h.HandleComplete(w, req)
+	var resp struct {
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	// MockProvider costs 0.001 per input token and nothing per output token.
+	want := strconv.FormatFloat(float64(resp.Usage.PromptTokens)*0.001, 'f', -1, 64)
+	if got := w.Header().Get("X-Request-Cost-USD"); got != want || resp.Usage.PromptTokens == 0 {
+		t.Errorf("Expected X-Request-Cost-USD %s, got %q", want, got)
+	}
+}
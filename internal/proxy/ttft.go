@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// ttftMinSamples is how many streams a provider/model needs in a window
+// before its SLO is judged, so a single slow stream doesn't raise an alert.
+const ttftMinSamples = 20
+
+// TTFTSLO is a time-to-first-token objective: Target of the streams served
+// by each provider and model must start within Threshold, or the model's
+// entry in Models, over every Window.
+type TTFTSLO struct {
+	Threshold time.Duration
+	Models    map[string]time.Duration // per-model thresholds
+	Target    float64                  // e.g. 0.95
+	Window    time.Duration
+}
+
+// WithTTFTSLO alerts when streams miss slo. Time to first token is recorded
+// for every stream regardless.
+func WithTTFTSLO(slo TTFTSLO) Option {
+	return func(h *Handler) {
+		h.ttft = newTTFTMonitor(slo)
+	}
+}
+
+func (s TTFTSLO) threshold(model string) time.Duration {
+	if t, ok := s.Models[model]; ok {
+		return t
+	}
+	return s.Threshold
+}
+
+type ttftKey struct{ provider, model string }
+
+// ttftWindow counts one provider/model's streams since start.
+type ttftWindow struct {
+	start    time.Time
+	total    int
+	slow     int
+	breached bool
+}
+
+// ttftMonitor tracks SLO compliance per provider and model in fixed windows.
+// A window that falls below target logs an alert once and reports breached
+// until the next window starts.
+type ttftMonitor struct {
+	slo TTFTSLO
+
+	mu      sync.Mutex
+	windows map[ttftKey]*ttftWindow
+}
+
+func newTTFTMonitor(slo TTFTSLO) *ttftMonitor {
+	return &ttftMonitor{slo: slo, windows: make(map[ttftKey]*ttftWindow)}
+}
+
+// observe records a stream's time to first token and reports whether it
+// tipped its provider/model into breach.
+func (m *ttftMonitor) observe(providerName, model string, ttft time.Duration, now time.Time) bool {
+	threshold := m.slo.threshold(model)
+	if threshold <= 0 {
+		return false
+	}
+	key := ttftKey{providerName, model}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.windows[key]
+	if w == nil || now.Sub(w.start) >= m.slo.Window {
+		w = &ttftWindow{start: now}
+		m.windows[key] = w
+	}
+	w.total++
+	if ttft > threshold {
+		w.slow++
+	}
+	if w.breached || w.total < ttftMinSamples {
+		return false
+	}
+	met := float64(w.total-w.slow) / float64(w.total)
+	if met >= m.slo.Target {
+		return false
+	}
+	w.breached = true
+	log.Printf("slo: time to first token for %s/%s below target: %.1f%% of %d streams within %s since %s (target %.1f%%)",
+		providerName, model, met*100, w.total, threshold, w.start.UTC().Format(time.RFC3339), m.slo.Target*100)
+	return true
+}
+
+// breached reports each provider/model's state in its current window; ones
+// whose window has lapsed are reported healthy.
+func (m *ttftMonitor) breached(now time.Time) map[ttftKey]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[ttftKey]bool, len(m.windows))
+	for key, w := range m.windows {
+		out[key] = w.breached && now.Sub(w.start) < m.slo.Window
+	}
+	return out
+}
+
+// observeTTFT records a stream's time to first token and checks it against
+// the SLO, when one is configured.
+func (h *Handler) observeTTFT(ctx context.Context, providerName, model string, ttft time.Duration) {
+	h.metrics.recordTTFT(ctx, providerName, model, ttft)
+	if h.ttft != nil && h.ttft.observe(providerName, model, ttft, time.Now()) {
+		h.metrics.recordTTFTAlert(ctx, providerName, model)
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTTFTMonitor_AlertsOncePerWindow(t *testing.T) {
+	m := newTTFTMonitor(TTFTSLO{
+		Threshold: time.Second,
+		Models:    map[string]time.Duration{"gpt-4o": 3 * time.Second},
+		Target:    0.9,
+		Window:    time.Minute,
+	})
+	now := time.Now()
+
+	// 18 fast and 2 slow streams meet a 90% target exactly.
+	for i := 0; i < ttftMinSamples; i++ {
+		ttft := 500 * time.Millisecond
+		if i < 2 {
+			ttft = 2 * time.Second
+		}
+		if m.observe("openai", "gpt-4", ttft, now) {
+			t.Fatalf("Expected no alert at stream %d", i)
+		}
+	}
+	if !m.observe("openai", "gpt-4", 2*time.Second, now) {
+		t.Fatal("Expected an alert once compliance drops below target")
+	}
+	if m.observe("openai", "gpt-4", 2*time.Second, now) {
+		t.Error("Expected a single alert per window")
+	}
+	if !m.breached(now)[ttftKey{"openai", "gpt-4"}] {
+		t.Error("Expected the provider/model to be reported breached")
+	}
+	if m.breached(now.Add(time.Minute))[ttftKey{"openai", "gpt-4"}] {
+		t.Error("Expected the breach to clear with the window")
+	}
+
+	// The per-model threshold applies instead of the default.
+	for i := 0; i < 2*ttftMinSamples; i++ {
+		if m.observe("openai", "gpt-4o", 2*time.Second, now) {
+			t.Fatal("Expected gpt-4o streams within its own threshold to meet the SLO")
+		}
+	}
+}
+
+func TestHandleCompleteStream_RecordsTTFT(t *testing.T) {
+	p := &MockStreamProvider{
+		MockProvider: MockProvider{name: "test-provider", supportedModels: []string{"gpt-4"}},
+		chunks:       []*provider.Chunk{{Delta: "hello"}, {Delta: " world"}, {Done: true}},
+	}
+	h, reader := setupMetricsTest(t, p, true)
+
+	body, _ := json.Marshal(map[string]any{"model": "gpt-4", "stream": true})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "tenant-1"))
+	h.HandleCompleteStream(httptest.NewRecorder(), req)
+
+	hist := collect(t, reader)["gateway.stream.time_to_first_token"].(metricdata.Histogram[float64])
+	if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Fatalf("Expected one observation per stream, got %+v", hist.DataPoints)
+	}
+	dp := hist.DataPoints[0]
+	if attr(dp.Attributes, "provider") != "test-provider" || attr(dp.Attributes, "model") != "gpt-4" {
+		t.Errorf("Unexpected attributes: %v", dp.Attributes)
+	}
+}
@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vnmchuo/llm-gateway/internal/apierror"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+)
+
+// Page sizes for raw usage logs.
+const (
+	defaultUsagePageSize = 100
+	maxUsagePageSize     = 1000
+)
+
+// usagePage reads ?limit= and ?cursor= for paginated usage logs. paginated
+// is false when the client asked for neither, for clients that predate
+// pagination.
+func usagePage(r *http.Request) (limit int, cursor string, paginated bool, err error) {
+	q := r.URL.Query()
+	rawLimit, cursor := q.Get("limit"), q.Get("cursor")
+	if rawLimit == "" && cursor == "" {
+		return 0, "", false, nil
+	}
+	limit = defaultUsagePageSize
+	if rawLimit != "" {
+		if limit, err = strconv.Atoi(rawLimit); err != nil || limit <= 0 || limit > maxUsagePageSize {
+			return 0, "", true, errors.New("invalid 'limit' (use 1-" + strconv.Itoa(maxUsagePageSize) + ")")
+		}
+	}
+	if cursor != "" {
+		if _, err := uuid.Parse(cursor); err != nil {
+			return 0, "", true, errors.New("invalid 'cursor'")
+		}
+	}
+	return limit, cursor, true, nil
+}
+
+// usageGroupBy reads ?group_by=, a comma-separated list of day, model and
+// provider; the default is day.
+func usageGroupBy(r *http.Request) ([]string, error) {
+	raw := r.URL.Query().Get("group_by")
+	if raw == "" {
+		return []string{billing.GroupByDay}, nil
+	}
+	var groupBy []string
+	for _, g := range strings.Split(raw, ",") {
+		g = strings.TrimSpace(g)
+		switch g {
+		case billing.GroupByDay, billing.GroupByModel, billing.GroupByProvider:
+		default:
+			return nil, errors.New("invalid 'group_by' (use day, model and/or provider)")
+		}
+		if !slices.Contains(groupBy, g) {
+			groupBy = append(groupBy, g)
+		}
+	}
+	return groupBy, nil
+}
+
+type usageGroup struct {
+	Day          string  `json:"day,omitempty"` // YYYY-MM-DD, UTC
+	Model        string  `json:"model,omitempty"`
+	Provider     string  `json:"provider,omitempty"`
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// HandleUsageSummary serves GET /v1/usage/summary: token and cost totals
+// over ?from= and ?to= grouped by ?group_by=, as of ?as_of= when given.
+func (h *Handler) HandleUsageSummary(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+
+	from, to, err := usagePeriod(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	asOf, err := usageAsOf(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	groupBy, err := usageGroupBy(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	summary, err := h.billing.GetUsageSummary(ctx, tenantID, from, to, asOf, groupBy)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	groups := make([]usageGroup, 0, len(summary))
+	var total usageGroup
+	for _, s := range summary {
+		g := usageGroup{
+			Model:        s.Model,
+			Provider:     s.Provider,
+			Requests:     s.Requests,
+			InputTokens:  s.InputTokens,
+			OutputTokens: s.OutputTokens,
+			TotalTokens:  s.InputTokens + s.OutputTokens,
+			CostUSD:      s.CostUSD,
+		}
+		if !s.Day.IsZero() {
+			g.Day = s.Day.Format(time.DateOnly)
+		}
+		groups = append(groups, g)
+		total.Requests += g.Requests
+		total.InputTokens += g.InputTokens
+		total.OutputTokens += g.OutputTokens
+		total.TotalTokens += g.TotalTokens
+		total.CostUSD += g.CostUSD
+	}
+
+	body := map[string]interface{}{
+		"tenant_id": tenantID,
+		"from":      from,
+		"to":        to,
+		"group_by":  groupBy,
+		"groups":    groups,
+		"total":     total,
+	}
+	if !asOf.IsZero() {
+		body["as_of"] = asOf
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// usageCSVHeader names the columns of GET /v1/usage/export.
+var usageCSVHeader = []string{
+	"id", "created_at", "request_id", "api_key_id", "provider", "model",
+	"input_tokens", "output_tokens", "cost_usd", "latency_ms", "cached", "finish_reason", "stage",
+}
+
+// HandleUsageExport serves GET /v1/usage/export: the raw usage logs over
+// ?from= and ?to= as CSV, newest first, as of ?as_of= when given. Logs are
+// read and written a page at a time, so exports of any size stream.
+func (h *Handler) HandleUsageExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := auth.GetTenantID(ctx)
+	if tenantID == "" {
+		apierror.Write(w, http.StatusUnauthorized, "", "unauthorized")
+		return
+	}
+
+	from, to, err := usagePeriod(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+	asOf, err := usageAsOf(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	logs, err := h.billing.GetUsagePage(ctx, tenantID, from, to, asOf, "", maxUsagePageSize)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(usageCSVHeader)
+	for {
+		for _, l := range logs {
+			_ = cw.Write([]string{
+				l.ID,
+				l.CreatedAt.UTC().Format(time.RFC3339Nano),
+				l.RequestID,
+				l.APIKeyID,
+				l.Provider,
+				l.Model,
+				strconv.Itoa(l.InputTokens),
+				strconv.Itoa(l.OutputTokens),
+				strconv.FormatFloat(l.CostUSD, 'f', -1, 64),
+				strconv.FormatInt(l.LatencyMs, 10),
+				strconv.FormatBool(l.Cached),
+				l.FinishReason,
+				l.Stage,
+			})
+		}
+		cw.Flush()
+		if len(logs) < maxUsagePageSize {
+			return
+		}
+		// The status is already sent, so a failure part way only cuts the
+		// export short.
+		logs, err = h.billing.GetUsagePage(ctx, tenantID, from, to, asOf, logs[len(logs)-1].ID, maxUsagePageSize)
+		if err != nil {
+			log.Printf("usage: export for tenant %s stopped: %v", tenantID, err)
+			return
+		}
+	}
+}
+
+// writeUsagePage writes one page of the /v1/usage logs. Totals cover the whole
+// period; next_cursor is set while more logs follow.
+func (h *Handler) writeUsagePage(w http.ResponseWriter, r *http.Request, tenantID string, from, to, asOf time.Time, limit int, cursor string) {
+	ctx := r.Context()
+	// One extra row tells whether another page follows.
+	logs, err := h.billing.GetUsagePage(ctx, tenantID, from, to, asOf, cursor, limit+1)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	totals, err := h.billing.GetUsageSummary(ctx, tenantID, from, to, asOf, nil)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	var total billing.UsageSummary
+	if len(totals) > 0 {
+		total = totals[0]
+	}
+
+	var next *string
+	if len(logs) > limit {
+		logs = logs[:limit]
+		next = &logs[limit-1].ID
+	}
+	if logs == nil {
+		logs = []*billing.UsageLog{}
+	}
+	body := map[string]interface{}{
+		"tenant_id":      tenantID,
+		"total_requests": total.Requests,
+		"total_cost_usd": total.CostUSD,
+		"logs":           logs,
+		"next_cursor":    next,
+		"from":           from,
+		"to":             to,
+	}
+	if !asOf.IsZero() {
+		body["as_of"] = asOf
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+)
+
+func usageRequest(target string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	return req.WithContext(auth.WithTenantID(req.Context(), "test-tenant"))
+}
+
+func TestHandleUsageSummary_GroupsAndTotals(t *testing.T) {
+	h, b := setupTest(nil, true)
+	var gotGroupBy []string
+	b.getUsageSummaryFunc = func(ctx context.Context, tenantID string, groupBy []string) ([]billing.UsageSummary, error) {
+		gotGroupBy = groupBy
+		day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		return []billing.UsageSummary{
+			{Day: day, Model: "gpt-4o", Requests: 3, InputTokens: 100, OutputTokens: 50, CostUSD: 0.25},
+			{Day: day, Model: "claude-3-haiku", Requests: 1, InputTokens: 10, OutputTokens: 5, CostUSD: 0.01},
+		}, nil
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleUsageSummary(w, usageRequest("/v1/usage/summary?group_by=day,model"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(gotGroupBy) != 2 || gotGroupBy[0] != billing.GroupByDay || gotGroupBy[1] != billing.GroupByModel {
+		t.Errorf("Expected grouping by day and model, got %v", gotGroupBy)
+	}
+	var resp struct {
+		Groups []usageGroup `json:"groups"`
+		Total  usageGroup   `json:"total"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Groups) != 2 || resp.Groups[0].Day != "2024-03-01" || resp.Groups[0].TotalTokens != 150 {
+		t.Errorf("Unexpected groups: %+v", resp.Groups)
+	}
+	if resp.Total.Requests != 4 || resp.Total.TotalTokens != 165 {
+		t.Errorf("Unexpected total: %+v", resp.Total)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleUsageSummary(w, usageRequest("/v1/usage/summary?group_by=tenant"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown grouping, got %d", w.Code)
+	}
+}
+
+func TestHandleUsage_Paginated(t *testing.T) {
+	h, b := setupTest(nil, true)
+	ids := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+	var gotAfter string
+	b.getUsagePageFunc = func(ctx context.Context, tenantID, after string, limit int) ([]*billing.UsageLog, error) {
+		gotAfter = after
+		var logs []*billing.UsageLog
+		for _, id := range ids[:min(limit, len(ids))] {
+			logs = append(logs, &billing.UsageLog{ID: id})
+		}
+		return logs, nil
+	}
+	b.getUsageSummaryFunc = func(ctx context.Context, tenantID string, groupBy []string) ([]billing.UsageSummary, error) {
+		return []billing.UsageSummary{{Requests: 3, CostUSD: 0.3}}, nil
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleUsage(w, usageRequest("/v1/usage?limit=2&cursor="+ids[0]))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotAfter != ids[0] {
+		t.Errorf("Expected the page to continue after the cursor, got %q", gotAfter)
+	}
+	var resp struct {
+		Logs          []billing.UsageLog `json:"logs"`
+		NextCursor    *string            `json:"next_cursor"`
+		TotalRequests int                `json:"total_requests"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Logs) != 2 || resp.NextCursor == nil || *resp.NextCursor != ids[1] {
+		t.Errorf("Expected two logs and a cursor at the second, got %+v", resp)
+	}
+	if resp.TotalRequests != 3 {
+		t.Errorf("Expected totals over the whole period, got %d", resp.TotalRequests)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleUsage(w, usageRequest("/v1/usage?limit=5000"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized page, got %d", w.Code)
+	}
+}
+
+func TestHandleUsageExport_StreamsAllPages(t *testing.T) {
+	h, b := setupTest(nil, true)
+	total := maxUsagePageSize + 5
+	b.getUsagePageFunc = func(ctx context.Context, tenantID, after string, limit int) ([]*billing.UsageLog, error) {
+		start := 0
+		if after != "" {
+			_, _ = fmt.Sscanf(after, "log-%d", &start)
+			start++
+		}
+		var logs []*billing.UsageLog
+		for i := start; i < total && len(logs) < limit; i++ {
+			logs = append(logs, &billing.UsageLog{ID: fmt.Sprintf("log-%d", i), Model: "gpt-4o", InputTokens: i})
+		}
+		return logs, nil
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleUsageExport(w, usageRequest("/v1/usage/export"))
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("Expected a CSV, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(records) != total+1 || records[0][0] != "id" {
+		t.Fatalf("Expected a header and %d rows, got %d", total, len(records))
+	}
+	if last := records[total]; last[0] != fmt.Sprintf("log-%d", total-1) || last[5] != "gpt-4o" {
+		t.Errorf("Unexpected last row: %v", last)
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+)
+
+// warnFraction is how close to a limit, as a share of it, a tenant must be
+// before successful responses warn about it.
+const warnFraction = 0.1
+
+// Soft-limit headers, so clients can back off before a 429 or 402.
+const (
+	headerRateLimitWarning = "X-RateLimit-Warning"
+	headerBudgetWarning    = "X-Budget-Warning"
+	// headerModelSubstituted names the model a request asked for when its
+	// cost cap had it served by another.
+	headerModelSubstituted = "X-Model-Substituted"
+)
+
+// limitWarnings maps warning headers to their values. They are only set on
+// successful responses; errors carry their own status.
+type limitWarnings map[string]string
+
+func (lw limitWarnings) apply(w http.ResponseWriter) {
+	for k, v := range lw {
+		w.Header().Set(k, v)
+	}
+}
+
+// rateLimitWarning describes the windows h is nearly out of, e.g.
+// "tokens; remaining=4000; limit=50000", or "" when none are.
+func rateLimitWarning(h ratelimit.Headroom) string {
+	var parts []string
+	if near(float64(h.TokensRemaining), float64(h.TokensLimit)) {
+		parts = append(parts, fmt.Sprintf("tokens; remaining=%d; limit=%d", h.TokensRemaining, h.TokensLimit))
+	}
+	if near(float64(h.RequestsRemaining), float64(h.RequestsLimit)) {
+		parts = append(parts, fmt.Sprintf("requests; remaining=%d; limit=%d", h.RequestsRemaining, h.RequestsLimit))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// budgetWarning describes the budgets s has nearly spent, e.g.
+// "daily; remaining_usd=0.85; limit_usd=10.00", or "" when none are.
+func budgetWarning(b billing.Budget, s billing.Spend) string {
+	var parts []string
+	if near(b.DailyUSD-s.DayUSD, b.DailyUSD) {
+		parts = append(parts, fmt.Sprintf("daily; remaining_usd=%.2f; limit_usd=%.2f", b.DailyUSD-s.DayUSD, b.DailyUSD))
+	}
+	if near(b.MonthlyUSD-s.MonthUSD, b.MonthlyUSD) {
+		parts = append(parts, fmt.Sprintf("monthly; remaining_usd=%.2f; limit_usd=%.2f", b.MonthlyUSD-s.MonthUSD, b.MonthlyUSD))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// near reports whether remaining is within warnFraction of a set limit.
+func near(remaining, limit float64) bool {
+	return limit > 0 && remaining <= limit*warnFraction
+}
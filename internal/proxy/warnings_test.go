@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+)
+
+func TestRateLimitWarning(t *testing.T) {
+	got := rateLimitWarning(ratelimit.Headroom{TokensRemaining: 4000, TokensLimit: 50000, RequestsRemaining: 30, RequestsLimit: 60})
+	if got != "tokens; remaining=4000; limit=50000" {
+		t.Errorf("Expected a tokens warning only, got %q", got)
+	}
+	if got := rateLimitWarning(ratelimit.Headroom{}); got != "" {
+		t.Errorf("Expected no warning without reported limits, got %q", got)
+	}
+}
+
+func TestBudgetWarning(t *testing.T) {
+	b := billing.Budget{DailyUSD: 10, MonthlyUSD: 100}
+	got := budgetWarning(b, billing.Spend{DayUSD: 9.15, MonthUSD: 95})
+	want := "daily; remaining_usd=0.85; limit_usd=10.00, monthly; remaining_usd=5.00; limit_usd=100.00"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := budgetWarning(b, billing.Spend{DayUSD: 5, MonthUSD: 50}); got != "" {
+		t.Errorf("Expected no warning well within budget, got %q", got)
+	}
+}
+
+func TestHandleComplete_BudgetWarning(t *testing.T) {
+	h := setupBudgetTest(&tenant.Settings{DailyBudgetUSD: 10}, billing.Spend{DayUSD: 9.5})
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req = req.WithContext(auth.WithTenantID(req.Context(), "capped-tenant"))
+	w := httptest.NewRecorder()
+
+	h.HandleComplete(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(headerBudgetWarning); !strings.HasPrefix(got, "daily; remaining_usd=0.50") {
+		t.Errorf("Expected a daily budget warning, got %q", got)
+	}
+	if got := w.Header().Get(headerRateLimitWarning); got != "" {
+		t.Errorf("Expected no rate limit warning, got %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Results of an embedding cache lookup, recorded on the request.
+const (
+	EmbeddingCacheHit  = "hit"
+	EmbeddingCacheMiss = "miss"
+)
+
+// EmbeddingCache keeps query embeddings so repeated questions aren't
+// embedded, and paid for, again.
+type EmbeddingCache interface {
+	Get(ctx context.Context, key string) ([]float32, bool, error)
+	Set(ctx context.Context, key string, embedding []float32) error
+}
+
+// EmbeddingKey identifies a query's embedding by tenant and embedding
+// model. Queries differing only in case or whitespace share it.
+func EmbeddingKey(tenantID, model, query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	sum := sha256.Sum256([]byte(model + "\x00" + normalized))
+	return "rag:embedding:" + tenantID + ":" + hex.EncodeToString(sum[:])
+}
+
+type RedisEmbeddingCache struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewRedisEmbeddingCache(rdb *redis.Client, ttl time.Duration) *RedisEmbeddingCache {
+	return &RedisEmbeddingCache{rdb: rdb, ttl: ttl}
+}
+
+func (c *RedisEmbeddingCache) Get(ctx context.Context, key string) ([]float32, bool, error) {
+	raw, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	if len(raw)%4 != 0 {
+		return nil, false, fmt.Errorf("failed to decode cached embedding: %d bytes", len(raw))
+	}
+	embedding := make([]float32, len(raw)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return embedding, true, nil
+}
+
+// Set stores embedding as little-endian float32s, a quarter of its JSON size.
+func (c *RedisEmbeddingCache) Set(ctx context.Context, key string, embedding []float32) error {
+	raw := make([]byte, 0, len(embedding)*4)
+	for _, v := range embedding {
+		raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+	}
+	if err := c.rdb.Set(ctx, key, raw, c.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	return nil
+}
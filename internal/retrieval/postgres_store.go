@@ -0,0 +1,86 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// PostgresStore searches pgvector collections in the rag_documents table.
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	query := `
+		SELECT tenant_id, collection, top_k, template, embedding_model, enabled
+		FROM rag_configs
+		WHERE tenant_id = $1
+	`
+	var c Config
+	err := s.db.QueryRow(ctx, query, tenantID).Scan(
+		&c.TenantID, &c.Collection, &c.TopK, &c.Template, &c.EmbeddingModel, &c.Enabled,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotConfigured
+		}
+		return nil, fmt.Errorf("failed to get retrieval config: %w", err)
+	}
+	return &c, nil
+}
+
+func (s *PostgresStore) Search(ctx context.Context, tenantID, collection string, embedding []float32, k int) ([]*Document, error) {
+	query := `
+		SELECT id, content, embedding <=> $3::vector AS distance
+		FROM rag_documents
+		WHERE tenant_id = $1 AND collection = $2
+		ORDER BY distance
+		LIMIT $4
+	`
+	rows, err := s.db.Query(ctx, query, tenantID, collection, vectorLiteral(embedding), k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []*Document
+	for rows.Next() {
+		var d Document
+		if err := rows.Scan(&d.ID, &d.Content, &d.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		docs = append(docs, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating documents: %w", err)
+	}
+	return docs, nil
+}
+
+// vectorLiteral formats an embedding in pgvector's text input format.
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"text/template"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+var ErrNotConfigured = errors.New("retrieval not configured for tenant")
+
+// Config is a tenant's retrieval settings.
+type Config struct {
+	TenantID       string
+	Collection     string
+	TopK           int
+	Template       string // text/template rendered with .Query and .Documents
+	EmbeddingModel string
+	Enabled        bool
+}
+
+type Document struct {
+	ID      string
+	Content string
+	Score   float64 // cosine distance, lower is closer
+}
+
+type Store interface {
+	GetConfig(ctx context.Context, tenantID string) (*Config, error)
+	Search(ctx context.Context, tenantID, collection string, embedding []float32, k int) ([]*Document, error)
+}
+
+// DefaultTemplate is used when a tenant config leaves Template empty.
+const DefaultTemplate = `Use the following context to answer the user's question. If the context is not relevant, ignore it.
+{{range .Documents}}
+[{{.ID}}]
+{{.Content}}
+{{end}}`
+
+// Stage embeds the latest user message, fetches the top-k closest documents
+// from the tenant's collection and injects them as a system message.
+type Stage struct {
+	store    Store
+	embedder provider.Embedder
+	cache    EmbeddingCache
+}
+
+type Option func(*Stage)
+
+// WithEmbeddingCache reuses the embeddings of queries a tenant asked before.
+func WithEmbeddingCache(cache EmbeddingCache) Option {
+	return func(s *Stage) {
+		s.cache = cache
+	}
+}
+
+func NewStage(store Store, embedder provider.Embedder, opts ...Option) *Stage {
+	s := &Stage{store: store, embedder: embedder}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Augment mutates req in place and records the retrieved document IDs on it.
+// Tenants without an enabled config are left untouched.
+func (s *Stage) Augment(ctx context.Context, req *provider.Request) error {
+	cfg, err := s.store.GetConfig(ctx, req.TenantID)
+	if errors.Is(err, ErrNotConfigured) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	query := lastUserMessage(req.Messages)
+	if query == "" {
+		return nil
+	}
+
+	embedding, err := s.embed(ctx, req, cfg.EmbeddingModel, query)
+	if err != nil {
+		return err
+	}
+
+	k := cfg.TopK
+	if k <= 0 {
+		k = 4
+	}
+	docs, err := s.store.Search(ctx, req.TenantID, cfg.Collection, embedding, k)
+	if err != nil {
+		return fmt.Errorf("retrieval: search: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	prompt, err := render(cfg.Template, query, docs)
+	if err != nil {
+		return fmt.Errorf("retrieval: render template: %w", err)
+	}
+
+	// Merge into an existing system prompt so providers that accept a single
+	// system message don't reject the augmented conversation.
+	if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+		messages := append([]provider.Message{}, req.Messages...)
+		messages[0].Content = prompt + "\n\n" + messages[0].Content
+		req.Messages = messages
+	} else {
+		req.Messages = append([]provider.Message{{Role: "system", Content: prompt}}, req.Messages...)
+	}
+	for _, d := range docs {
+		req.RetrievedDocIDs = append(req.RetrievedDocIDs, d.ID)
+	}
+	return nil
+}
+
+// embed returns query's embedding, from the cache when the tenant asked it
+// before, and records on req whether it was cached. Cache errors count as
+// misses rather than failing the request.
+func (s *Stage) embed(ctx context.Context, req *provider.Request, model, query string) ([]float32, error) {
+	var key string
+	if s.cache != nil {
+		key = EmbeddingKey(req.TenantID, model, query)
+		embedding, ok, err := s.cache.Get(ctx, key)
+		if err != nil {
+			log.Printf("retrieval: %v", err)
+		}
+		if ok {
+			req.EmbeddingCache = EmbeddingCacheHit
+			return embedding, nil
+		}
+	}
+
+	emb, err := s.embedder.Embed(ctx, &provider.EmbeddingRequest{Model: model, Input: []string{query}})
+	if err != nil {
+		return nil, fmt.Errorf("retrieval: embed query: %w", err)
+	}
+	if s.cache != nil {
+		req.EmbeddingCache = EmbeddingCacheMiss
+		if err := s.cache.Set(ctx, key, emb.Embeddings[0]); err != nil {
+			log.Printf("retrieval: %v", err)
+		}
+	}
+	return emb.Embeddings[0], nil
+}
+
+func render(tmpl, query string, docs []*Document) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("retrieval").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct {
+		Query     string
+		Documents []*Document
+	}{query, docs})
+	return buf.String(), err
+}
+
+func lastUserMessage(messages []provider.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
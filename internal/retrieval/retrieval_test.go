@@ -0,0 +1,144 @@
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type mockStore struct {
+	cfg  *Config
+	docs []*Document
+}
+
+func (m *mockStore) GetConfig(ctx context.Context, tenantID string) (*Config, error) {
+	if m.cfg == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.cfg, nil
+}
+
+func (m *mockStore) Search(ctx context.Context, tenantID, collection string, embedding []float32, k int) ([]*Document, error) {
+	if len(m.docs) > k {
+		return m.docs[:k], nil
+	}
+	return m.docs, nil
+}
+
+type mockEmbedder struct {
+	input []string
+	calls int
+}
+
+func (m *mockEmbedder) Embed(ctx context.Context, req *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	m.input = req.Input
+	m.calls++
+	return &provider.EmbeddingResponse{Embeddings: [][]float32{{0.1, 0.2}}}, nil
+}
+
+func TestAugment_InjectsDocumentsAndRecordsIDs(t *testing.T) {
+	store := &mockStore{
+		cfg: &Config{Collection: "docs", TopK: 2, Enabled: true},
+		docs: []*Document{
+			{ID: "doc-1", Content: "Refunds take 5 days."},
+			{ID: "doc-2", Content: "Support is 24/7."},
+			{ID: "doc-3", Content: "Unused."},
+		},
+	}
+	embedder := &mockEmbedder{}
+	stage := NewStage(store, embedder)
+
+	req := &provider.Request{
+		TenantID: "t1",
+		Messages: []provider.Message{
+			{Role: "user", Content: "old question"},
+			{Role: "assistant", Content: "old answer"},
+			{Role: "user", Content: "how long do refunds take?"},
+		},
+	}
+	if err := stage.Augment(context.Background(), req); err != nil {
+		t.Fatalf("Augment failed: %v", err)
+	}
+
+	if len(embedder.input) != 1 || embedder.input[0] != "how long do refunds take?" {
+		t.Errorf("Expected latest user message to be embedded, got %v", embedder.input)
+	}
+	if len(req.Messages) != 4 || req.Messages[0].Role != "system" {
+		t.Fatalf("Expected system context message to be prepended, got %+v", req.Messages)
+	}
+	if !strings.Contains(req.Messages[0].Content, "Refunds take 5 days.") || strings.Contains(req.Messages[0].Content, "Unused.") {
+		t.Errorf("Unexpected context: %s", req.Messages[0].Content)
+	}
+	if strings.Join(req.RetrievedDocIDs, ",") != "doc-1,doc-2" {
+		t.Errorf("Expected doc-1,doc-2, got %v", req.RetrievedDocIDs)
+	}
+}
+
+func TestAugment_UnconfiguredTenantIsUntouched(t *testing.T) {
+	stage := NewStage(&mockStore{}, &mockEmbedder{})
+	req := &provider.Request{Messages: []provider.Message{{Role: "user", Content: "hi"}}}
+
+	if err := stage.Augment(context.Background(), req); err != nil {
+		t.Fatalf("Augment failed: %v", err)
+	}
+	if len(req.Messages) != 1 || len(req.RetrievedDocIDs) != 0 {
+		t.Errorf("Expected request to be untouched, got %+v", req)
+	}
+}
+
+func TestAugment_CustomTemplate(t *testing.T) {
+	store := &mockStore{
+		cfg:  &Config{Enabled: true, Template: "Q={{.Query}}{{range .Documents}};{{.ID}}{{end}}"},
+		docs: []*Document{{ID: "a"}, {ID: "b"}},
+	}
+	stage := NewStage(store, &mockEmbedder{})
+	req := &provider.Request{Messages: []provider.Message{{Role: "user", Content: "hi"}}}
+
+	if err := stage.Augment(context.Background(), req); err != nil {
+		t.Fatalf("Augment failed: %v", err)
+	}
+	if req.Messages[0].Content != "Q=hi;a;b" {
+		t.Errorf("Expected rendered template, got %q", req.Messages[0].Content)
+	}
+}
+
+type memEmbeddingCache map[string][]float32
+
+func (c memEmbeddingCache) Get(ctx context.Context, key string) ([]float32, bool, error) {
+	e, ok := c[key]
+	return e, ok, nil
+}
+
+func (c memEmbeddingCache) Set(ctx context.Context, key string, embedding []float32) error {
+	c[key] = embedding
+	return nil
+}
+
+func TestAugment_CachesQueryEmbeddings(t *testing.T) {
+	store := &mockStore{cfg: &Config{Enabled: true, EmbeddingModel: "text-embedding-3-small"}, docs: []*Document{{ID: "a"}}}
+	embedder := &mockEmbedder{}
+	stage := NewStage(store, embedder, WithEmbeddingCache(memEmbeddingCache{}))
+
+	ask := func(tenantID, question string) *provider.Request {
+		req := &provider.Request{TenantID: tenantID, Messages: []provider.Message{{Role: "user", Content: question}}}
+		if err := stage.Augment(context.Background(), req); err != nil {
+			t.Fatalf("Augment failed: %v", err)
+		}
+		return req
+	}
+	if req := ask("t1", "How long do refunds take?"); req.EmbeddingCache != EmbeddingCacheMiss {
+		t.Errorf("Expected a miss for a new question, got %q", req.EmbeddingCache)
+	}
+	// Case and whitespace don't make a question new.
+	if req := ask("t1", "  how long do  REFUNDS take? "); req.EmbeddingCache != EmbeddingCacheHit || len(req.RetrievedDocIDs) != 1 {
+		t.Errorf("Expected a hit for a repeated question, got %+v", req)
+	}
+	if embedder.calls != 1 {
+		t.Errorf("Expected one embedding call, got %d", embedder.calls)
+	}
+	if req := ask("t2", "How long do refunds take?"); req.EmbeddingCache != EmbeddingCacheMiss {
+		t.Errorf("Expected tenants not to share cached embeddings, got %q", req.EmbeddingCache)
+	}
+}
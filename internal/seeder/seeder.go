@@ -4,7 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"log"
+	"log/slog"
 
 	"github.com/vnmchuo/llm-gateway/internal/auth"
 )
@@ -22,16 +22,15 @@ func SeedTestAPIKey(ctx context.Context, store auth.Store) {
 	apiKey := &auth.APIKey{
 		TenantID:  TestTenantID,
 		KeyHash:   keyHash,
+		KeyHint:   auth.Hint(TestAPIKey),
 		RateLimit: 1000000,
 		Active:    true,
 	}
 
 	err := store.Create(ctx, apiKey)
 	if err != nil {
-		log.Printf("[Seeder] API key may already exist, skipping: %v", err)
+		slog.InfoContext(ctx, "seeder: API key may already exist, skipping", "err", err)
 		return
 	}
-	log.Printf("[Seeder] Test API key created successfully")
-	log.Printf("[Seeder] Key: %s", TestAPIKey)
-	log.Printf("[Seeder] TenantID: %s", TestTenantID)
+	slog.InfoContext(ctx, "seeder: test API key created", "key", TestAPIKey, "tenant_id", TestTenantID)
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/config"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/telemetry"
+)
+
+// mountMetrics serves /metrics on ops when it is the admin listener. On the
+// main listener r, whatever the role, it requires METRICS_TOKEN or
+// ADMIN_TOKEN, since metrics labels carry tenant IDs and spend; with
+// neither, /metrics isn't served.
+func mountMetrics(r, ops chi.Router, cfg *config.Config) {
+	switch {
+	case ops != r:
+		ops.Handle("/metrics", telemetry.MetricsHandler())
+	case cfg.MetricsToken != "" || cfg.AdminToken != "":
+		token := cfg.MetricsToken
+		if token == "" {
+			token = cfg.AdminToken
+		}
+		r.With(auth.NewAdminMiddleware(token)).Handle("/metrics", telemetry.MetricsHandler())
+	default:
+		slog.Warn("not serving /metrics: set ADMIN_PORT, METRICS_TOKEN or ADMIN_TOKEN")
+	}
+}
+
+// adminTLSConfig is the admin listener's TLS configuration: its certificate
+// and, with cfg.AdminClientCA, verification of client certificates. It is
+// nil when the listener serves plain HTTP.
+func adminTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.AdminTLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.AdminTLSCert, cfg.AdminTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("admin listener certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.AdminClientCA != "" {
+		pem, err := os.ReadFile(cfg.AdminClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("admin client CA: no certificates in %s", cfg.AdminClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
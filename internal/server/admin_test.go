@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vnmchuo/llm-gateway/config"
+)
+
+// issue creates a key and a certificate for it signed by parent (self-signed
+// when parent is nil).
+func issue(t *testing.T, tmpl *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdminTLSConfig_RequiresClientCertificate(t *testing.T) {
+	now := time.Now()
+	ca, caKey := issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ops CA"},
+		NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	serverCert, serverKey := issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "gateway"},
+		NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour),
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	clientCert, clientKey := issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "operator"},
+		NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		AdminTLSCert:  filepath.Join(dir, "server.crt"),
+		AdminTLSKey:   filepath.Join(dir, "server.key"),
+		AdminClientCA: filepath.Join(dir, "ca.crt"),
+	}
+	writePEM(t, cfg.AdminTLSCert, "CERTIFICATE", serverCert.Raw)
+	keyDER, _ := x509.MarshalECPrivateKey(serverKey)
+	writePEM(t, cfg.AdminTLSKey, "EC PRIVATE KEY", keyDER)
+	writePEM(t, cfg.AdminClientCA, "CERTIFICATE", ca.Raw)
+
+	tlsCfg, err := adminTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("adminTLSConfig: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	if resp, err := client().Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected a client without a certificate to be refused")
+	}
+	resp, err := client(tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the operator's certificate to be accepted: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminTLSConfig_PlainWithoutCertificate(t *testing.T) {
+	tlsCfg, err := adminTLSConfig(&config.Config{AdminPort: "9090"})
+	if err != nil || tlsCfg != nil {
+		t.Errorf("Expected no TLS without a certificate, got %v, %v", tlsCfg, err)
+	}
+}
+
+func TestMountMetrics(t *testing.T) {
+	get := func(r http.Handler, token string) int {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without ADMIN_PORT, as in a worker process, the token is required.
+	r := chi.NewRouter()
+	mountMetrics(r, r, &config.Config{MetricsToken: "m-token"})
+	if code := get(r, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+	if code := get(r, "m-token"); code != http.StatusOK {
+		t.Errorf("Expected 200 with the metrics token, got %d", code)
+	}
+
+	r = chi.NewRouter()
+	mountMetrics(r, r, &config.Config{})
+	if code := get(r, ""); code != http.StatusNotFound {
+		t.Errorf("Expected /metrics not served without a token configured, got %d", code)
+	}
+
+	// The admin listener serves it without one.
+	r, ops := chi.NewRouter(), chi.NewRouter()
+	mountMetrics(r, ops, &config.Config{})
+	if code := get(ops, ""); code != http.StatusOK {
+		t.Errorf("Expected 200 on the admin listener, got %d", code)
+	}
+	if code := get(r, ""); code != http.StatusNotFound {
+		t.Errorf("Expected /metrics off the main listener, got %d", code)
+	}
+}
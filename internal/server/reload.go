@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/vnmchuo/llm-gateway/config"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/proxy"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+)
+
+// live is what a config reload changes in the running gateway.
+type live struct {
+	providers []provider.Provider // built from the config; nil when injected
+	keyPools  map[string]*provider.KeyPool
+	router    *proxy.Router
+	limiter   *ratelimit.Limiter
+	prices    *pricing.Registry
+	routing   *policy.RoutingResolver
+}
+
+// apply takes a reloaded config's provider keys, routing and default rate
+// limit, and reloads model prices.
+func (l *live) apply(ctx context.Context, cfg *config.Config) {
+	keys := map[string]string{
+		"gemini": cfg.GeminiAPIKey,
+		"openai": cfg.OpenAIAPIKey,
+		"claude": cfg.AnthropicAPIKey,
+		"cohere": cfg.CohereAPIKey,
+		"ollama": cfg.OllamaAPIKey,
+	}
+	for _, p := range l.providers {
+		if ks, ok := p.(provider.KeySetter); ok {
+			ks.SetAPIKey(keys[p.Name()])
+		}
+	}
+	for name, pool := range l.keyPools {
+		if err := pool.SetKeys(cfg.ProviderAPIKeys[name]); err != nil {
+			slog.Warn("config: keeping the key pool until restart", "provider", name, "err", err)
+		}
+	}
+	for name := range cfg.ProviderAPIKeys {
+		if _, ok := l.keyPools[name]; !ok && l.providers != nil {
+			slog.Warn("config: new key pool takes effect on restart", "provider", name)
+		}
+	}
+
+	l.router.SetRoutingStrategy(proxy.StrategyWeighted, proxy.NewWeightedStrategy(cfg.RoutingWeights))
+	l.router.SetRoutingStrategy(proxy.StrategyPriority, proxy.NewPriorityStrategy(cfg.RoutingPriority))
+	if err := l.router.SetDefaultStrategy(cfg.RoutingStrategy); err != nil {
+		slog.Warn("config: keeping the default routing strategy", "err", err)
+	} else {
+		l.routing.SetDefaultStrategy(cfg.RoutingStrategy)
+	}
+	l.limiter.SetDefaultTPM(cfg.DefaultRateLimitTPM)
+
+	if err := l.prices.Reload(ctx); err != nil {
+		slog.Warn("pricing: failed to reload model prices", "err", err)
+	}
+}
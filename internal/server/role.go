@@ -0,0 +1,34 @@
+package server
+
+import "fmt"
+
+// Role selects which parts of the gateway a process runs, so job processing
+// can be scaled separately from the API tier.
+type Role string
+
+const (
+	// RoleAll serves the APIs and runs job workers in one process.
+	RoleAll Role = "all"
+	// RoleAPI serves the tenant and admin APIs; jobs are only enqueued.
+	RoleAPI Role = "api"
+	// RoleWorker runs job workers and usage flushing; only /healthz is served.
+	RoleWorker Role = "worker"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(s); r {
+	case RoleAll, RoleAPI, RoleWorker:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q (want all, api or worker)", s)
+}
+
+// WithRole enables the routes and workers for role; it overrides earlier
+// WithPublicAPI, WithAdminAPI and WithWorkers options.
+func WithRole(role Role) Option {
+	return func(s *Server) {
+		s.publicAPI = role != RoleWorker
+		s.adminAPI = role != RoleWorker
+		s.workers = role != RoleAPI
+	}
+}
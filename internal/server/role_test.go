@@ -0,0 +1,30 @@
+package server
+
+import "testing"
+
+func TestWithRole(t *testing.T) {
+	tests := []struct {
+		role                         Role
+		publicAPI, adminAPI, workers bool
+	}{
+		{RoleAll, true, true, true},
+		{RoleAPI, true, true, false},
+		{RoleWorker, false, false, true},
+	}
+	for _, tt := range tests {
+		s := &Server{}
+		WithRole(tt.role)(s)
+		if s.publicAPI != tt.publicAPI || s.adminAPI != tt.adminAPI || s.workers != tt.workers {
+			t.Errorf("WithRole(%s) = public %v, admin %v, workers %v", tt.role, s.publicAPI, s.adminAPI, s.workers)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if r, err := ParseRole("worker"); err != nil || r != RoleWorker {
+		t.Errorf("ParseRole(worker) = %q, %v", r, err)
+	}
+	if _, err := ParseRole("scheduler"); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+}
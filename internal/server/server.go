@@ -0,0 +1,957 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/vnmchuo/llm-gateway/config"
+	"github.com/vnmchuo/llm-gateway/internal/admin"
+	"github.com/vnmchuo/llm-gateway/internal/archive"
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/cache"
+	"github.com/vnmchuo/llm-gateway/internal/catalog"
+	"github.com/vnmchuo/llm-gateway/internal/dryrun"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/health"
+	"github.com/vnmchuo/llm-gateway/internal/hooks"
+	"github.com/vnmchuo/llm-gateway/internal/migrate"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/pricing"
+	"github.com/vnmchuo/llm-gateway/internal/prompts"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/provider/claude"
+	"github.com/vnmchuo/llm-gateway/internal/provider/cohere"
+	"github.com/vnmchuo/llm-gateway/internal/provider/gemini"
+	"github.com/vnmchuo/llm-gateway/internal/provider/ollama"
+	"github.com/vnmchuo/llm-gateway/internal/provider/openai"
+	"github.com/vnmchuo/llm-gateway/internal/proxy"
+	"github.com/vnmchuo/llm-gateway/internal/retrieval"
+	"github.com/vnmchuo/llm-gateway/internal/shadow"
+	"github.com/vnmchuo/llm-gateway/internal/telemetry"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"github.com/vnmchuo/llm-gateway/internal/tiering"
+	"github.com/vnmchuo/llm-gateway/internal/tools"
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+	"github.com/vnmchuo/llm-gateway/internal/worker"
+	"github.com/vnmchuo/llm-gateway/migrations"
+	"github.com/vnmchuo/llm-gateway/pkg/ratelimit"
+)
+
+// Server is the fully wired gateway: storage connections, background workers
+// and HTTP routes. Binaries choose which parts run through Options; tests
+// drive Handler directly.
+type Server struct {
+	cfg       *config.Config
+	pool      *pgxpool.Pool
+	rdb       *redis.Client
+	globalRdb *redis.Client // shared across regions, in regional state mode
+	authStore auth.Store
+	usage     *billing.Recorder
+	webhooks  *webhook.Sender
+	routes    http.Handler
+	config    *config.Watcher
+	// adminRoutes and adminTLS serve the admin listener when cfg.AdminPort
+	// is set; routes then has no operator endpoints.
+	adminRoutes http.Handler
+	adminTLS    *tls.Config
+
+	providers       []provider.Provider
+	hooks           []hooks.Hook
+	publicAPI       bool
+	adminAPI        bool
+	workers         bool
+	shutdownTimeout time.Duration
+
+	// Run in reverse order on Close: background work is stopped before usage
+	// and webhooks are flushed, connections are closed after.
+	background []func()
+	closers    []func()
+}
+
+type Option func(*Server)
+
+// WithProviders replaces the upstream providers configured in cfg.
+func WithProviders(providers []provider.Provider) Option {
+	return func(s *Server) {
+		s.providers = providers
+	}
+}
+
+// WithHooks runs hs on every completion, whatever the provider (see
+// package hooks).
+func WithHooks(hs ...hooks.Hook) Option {
+	return func(s *Server) {
+		s.hooks = append(s.hooks, hs...)
+	}
+}
+
+// WithPublicAPI mounts the tenant-facing /v1 routes (default: true).
+func WithPublicAPI(enabled bool) Option {
+	return func(s *Server) {
+		s.publicAPI = enabled
+	}
+}
+
+// WithAdminAPI mounts /admin when cfg.AdminToken or cfg.AdminClientCA is
+// set (default: true).
+func WithAdminAPI(enabled bool) Option {
+	return func(s *Server) {
+		s.adminAPI = enabled
+	}
+}
+
+// WithWorkers runs async job workers in this process (default: true).
+func WithWorkers(enabled bool) Option {
+	return func(s *Server) {
+		s.workers = enabled
+	}
+}
+
+// WithShutdownTimeout bounds draining HTTP connections and, separately,
+// flushing usage logs on shutdown (default: 10s).
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.shutdownTimeout = d
+		}
+	}
+}
+
+// New connects to Postgres and Redis and wires every component.
+func New(ctx context.Context, cfg *config.Config, opts ...Option) (_ *Server, err error) {
+	s := &Server{
+		cfg:             cfg,
+		publicAPI:       true,
+		adminAPI:        true,
+		workers:         true,
+		shutdownTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	defer func() {
+		if err != nil {
+			_ = s.Close(context.Background())
+		}
+	}()
+
+	s.pool, err = pgxpool.New(ctx, cfg.PostgresDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect postgres: %w", err)
+	}
+	s.onClose(s.pool.Close)
+	if err := s.pool.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+	slog.Info("postgres connected")
+	if err := s.checkSchema(ctx, cfg.MigrateOnStart); err != nil {
+		return nil, err
+	}
+
+	s.rdb = redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	s.onClose(func() { _ = s.rdb.Close() })
+	if err := s.rdb.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+	slog.Info("redis connected")
+	if cfg.StateMode == "regional" {
+		// The shared Redis is reconciled with off the request path, so it
+		// isn't pinged: regions keep serving while it is unreachable.
+		s.globalRdb = redis.NewClient(&redis.Options{Addr: cfg.GlobalRedisAddr})
+		s.onClose(func() { _ = s.globalRdb.Close() })
+	}
+
+	tenantSettings := tenant.NewPostgresStore(s.pool)
+	tenantStore := tenant.NewCachedStore(tenantSettings, 30*time.Second)
+
+	s.authStore = auth.NewPostgresStore(s.pool)
+	lastUsed := auth.NewLastUsedTracker(s.authStore, cfg.KeyLastUsedInterval, auth.WithRedisBuffer(s.rdb))
+	authOpts := []auth.MiddlewareOption{
+		auth.WithLastUsed(lastUsed),
+		auth.WithTenantStatus(func(ctx context.Context, tenantID string) (bool, error) {
+			settings, err := tenantSettings.Get(ctx, tenantID)
+			if err != nil {
+				return false, err
+			}
+			return !settings.Suspended, nil
+		}),
+	}
+	if cfg.OIDCJWKSURL != "" {
+		if err := auth.ValidateScopes(cfg.OIDCDefaultScopes); err != nil {
+			return nil, fmt.Errorf("invalid OIDC_DEFAULT_SCOPES: %w", err)
+		}
+		authOpts = append(authOpts, auth.WithJWT(newJWTVerifier(cfg)))
+	}
+	authMiddleware := auth.NewMiddleware(s.authStore, s.rdb, authOpts...)
+	if s.publicAPI {
+		s.goBackground(lastUsed.Run)
+	}
+	billingStore := billing.NewPostgresStore(s.pool)
+
+	// Audit trail for reads of billing/usage data
+	accessLogger := audit.NewAccessLogger(audit.NewPostgresStore(s.pool))
+
+	limiter := s.newLimiter(cfg)
+
+	// Per-model prices; until the first load succeeds, providers' built-in
+	// prices apply.
+	prices := pricing.NewRegistry(pricing.NewPostgresStore(s.pool), cfg.ModelPricesRefresh)
+	if err := prices.Reload(ctx); err != nil {
+		slog.Warn("pricing: failed to load model prices, using provider defaults", "err", err)
+	}
+	s.goBackground(prices.Run)
+
+	providers := s.providers
+	var keyPools map[string]*provider.KeyPool
+	var endpoints map[string]*provider.Failover
+	if providers == nil {
+		if providers, keyPools, endpoints, err = Providers(cfg); err != nil {
+			return nil, err
+		}
+	}
+	// Models discovered from upstreams, looked up once per TTL across
+	// replicas; until the first lookup succeeds, providers serve none.
+	modelCatalog := catalog.New(providers, catalog.NewRedisCache(s.rdb, cfg.ModelCatalogTTL), cfg.ModelCatalogTTL/2)
+	if err := modelCatalog.Refresh(ctx); err != nil {
+		slog.Warn("catalog: failed to list provider models", "err", err)
+	}
+	s.goBackground(modelCatalog.Run)
+
+	// Scheduled provider capacity changes, shared by replicas through Postgres.
+	calendar := policy.NewCalendar(policy.NewPostgresCalendarStore(s.pool), 30*time.Second)
+	if err := calendar.Reload(ctx); err != nil {
+		slog.Warn("policy: failed to load capacity calendar", "err", err)
+	}
+	s.goBackground(calendar.Run)
+
+	routerOpts := []proxy.RouterOption{
+		proxy.WithKeyPools(keyPools),
+		proxy.WithEndpoints(endpoints),
+		proxy.WithFallback(cfg.RouterMaxAttempts, cfg.RouterAttemptTimeout),
+		proxy.WithRoutingStrategy(proxy.StrategyWeighted, proxy.NewWeightedStrategy(cfg.RoutingWeights)),
+		proxy.WithRoutingStrategy(proxy.StrategyPriority, proxy.NewPriorityStrategy(cfg.RoutingPriority)),
+		proxy.WithDefaultStrategy(cfg.RoutingStrategy),
+		proxy.WithModelAliases(modelAliases(cfg.ModelAliases)),
+		proxy.WithExtraFields(cfg.ProviderExtraFields),
+		proxy.WithProviderRegions(cfg.ProviderRegions),
+		proxy.WithPrices(prices),
+		proxy.WithCatalog(modelCatalog),
+		proxy.WithCapacityCalendar(calendar),
+		proxy.WithDegradation(proxy.Degradation{
+			ErrorRate:     cfg.RoutingDegradedErrorRate,
+			LatencyFactor: cfg.RoutingDegradedLatencyFactor,
+		}),
+	}
+	if cfg.RoutingStatsShared {
+		routerOpts = append(routerOpts, proxy.WithSharedStats(s.rdb, instanceID(), cfg.RoutingStatsSyncInterval))
+	}
+	router := proxy.NewRouter(providers, routerOpts...)
+	s.goBackground(router.RunStatsSync)
+
+	routingStore := policy.NewCachedRoutingStore(policy.NewPostgresRoutingStore(s.pool), 30*time.Second)
+	routing := policy.NewRoutingResolver(routingStore, cfg.RoutingStrategy)
+
+	reload := &live{keyPools: keyPools, router: router, limiter: limiter, prices: prices, routing: routing}
+	if s.providers == nil {
+		reload.providers = providers
+	}
+	s.config = config.NewWatcher(cfg, reload.apply)
+	s.goBackground(s.config.Run)
+
+	tracer := otel.GetTracerProvider().Tracer("llm-gateway")
+	identities, err := telemetry.NewIdentities(cfg.TraceIdentities, cfg.TraceIdentitySalt)
+	if err != nil {
+		return nil, err
+	}
+	tierer := tiering.NewEngine(tieringRules(cfg.TieringRules), billingStore, tenantSettings, tenantStore,
+		tiering.NewPostgresStore(s.pool), cfg.TieringInterval)
+	if s.workers && len(cfg.TieringRules) > 0 {
+		s.goBackground(tierer.Run)
+		slog.Info("usage-based tiering enabled", "rules", len(cfg.TieringRules), "interval", cfg.TieringInterval)
+	}
+	policyStore := policy.NewCachedStore(policy.NewPostgresStore(s.pool), 30*time.Second)
+	promptStore := prompts.NewCachedStore(prompts.NewPostgresStore(s.pool), 30*time.Second)
+	var spend billing.SpendCounter = billing.NewRedisSpendCounter(s.rdb, billing.WithSeed(billingStore))
+	if s.globalRdb != nil {
+		regional := billing.NewRegionalSpendCounter(billing.NewRedisSpendCounter(s.rdb), s.globalRdb, cfg.Region,
+			billingStore, cfg.ReconcileInterval)
+		s.goBackground(regional.Run)
+		spend = regional
+	}
+	mirror := shadow.NewMirror(shadow.NewPostgresStore(s.pool), cfg.ShadowMaxInFlight, cfg.ShadowTimeout)
+	s.onClose(mirror.Close)
+	s.usage = billing.NewRecorder(billingStore, cfg.UsageMaxInFlight, cfg.UsageWriteTimeout, billing.WithSpendCounter(spend))
+	webhookGuard := webhook.NewGuard(cfg.WebhookAllowedHosts)
+	s.webhooks = webhook.NewSender(webhookGuard)
+	handlerOpts := []proxy.Option{
+		proxy.WithUsageRecorder(s.usage),
+		proxy.WithSpendLimits(spend),
+		proxy.WithTenantSettings(tenantStore),
+		proxy.WithModelPolicies(policyStore),
+		proxy.WithRoutingPolicies(routing),
+		proxy.WithSystemPrompts(promptStore),
+		proxy.WithMaxTurns(cfg.MaxConversationTurns),
+		proxy.WithRequestLimits(proxy.RequestLimits{
+			MaxBodyBytes: cfg.MaxRequestBytes,
+			MaxChars:     cfg.MaxPromptChars,
+			MaxTokens:    cfg.MaxOutputTokens,
+		}),
+		proxy.WithAPIKeys(s.authStore),
+		proxy.WithShadowTraffic(mirror),
+		proxy.WithBatchLimits(cfg.BatchMaxItems, cfg.BatchConcurrency),
+		proxy.WithStreamLimits(proxy.StreamLimits{
+			MaxDuration:        cfg.MaxStreamDuration,
+			CheckpointInterval: cfg.StreamCheckpointInterval,
+		}),
+		proxy.WithTTFTSLO(proxy.TTFTSLO{
+			Threshold: cfg.TTFTSLOThreshold,
+			Models:    cfg.TTFTSLOModels,
+			Target:    cfg.TTFTSLOTarget,
+			Window:    cfg.TTFTSLOWindow,
+		}),
+		proxy.WithStreamSampling(cfg.StreamMetricsSamplePercent),
+		proxy.WithHooks(s.hooks...),
+		proxy.WithViolationWebhooks(guardrail.NewNotifier(s.webhooks)),
+		proxy.WithWebhookGuard(webhookGuard),
+		proxy.WithTraceIdentities(identities),
+	}
+	if cfg.OpenAIAPIKey != "" {
+		handlerOpts = append(handlerOpts, proxy.WithModeration(guardrail.NewOpenAIModerator(cfg.OpenAIAPIKey)))
+	}
+	if len(cfg.ToolHandlers) > 0 {
+		registry := tools.NewRegistry()
+		for name, url := range cfg.ToolHandlers {
+			registry.Register(name, tools.NewHTTPHandler(url, cfg.ToolSigningSecret))
+		}
+		handlerOpts = append(handlerOpts, proxy.WithToolRunner(tools.NewRunner(registry, cfg.ToolMaxIterations)))
+	}
+	if cfg.RAGEnabled {
+		embedder, ok := ragEmbedder(providers)
+		if !ok {
+			return nil, fmt.Errorf("RAG_ENABLED requires a provider that serves embeddings")
+		}
+		var stageOpts []retrieval.Option
+		if cfg.RAGEmbeddingCacheTTL > 0 {
+			stageOpts = append(stageOpts, retrieval.WithEmbeddingCache(retrieval.NewRedisEmbeddingCache(s.rdb, cfg.RAGEmbeddingCacheTTL)))
+		}
+		handlerOpts = append(handlerOpts, proxy.WithRetrieval(retrieval.NewStage(retrieval.NewPostgresStore(s.pool), embedder, stageOpts...)))
+	}
+	var replayOpts []dryrun.Option
+	if cfg.AuditPayloadStore != "" {
+		var exchanges audit.ExchangeStore = audit.NewPostgresStore(s.pool)
+		if cfg.AuditPayloadStore == "s3" {
+			exchanges, err = audit.NewS3ExchangeStore(cfg.AuditS3Endpoint, cfg.AuditS3AccessKey, cfg.AuditS3SecretKey,
+				cfg.AuditS3Bucket, !cfg.AuditS3Insecure, cfg.AuditPayloadTTL)
+			if err != nil {
+				return nil, err
+			}
+		}
+		handlerOpts = append(handlerOpts, proxy.WithPayloadAudit(audit.NewPayloadLogger(exchanges, cfg.AuditPayloadTTL)))
+		replayOpts = append(replayOpts, dryrun.WithExchanges(exchanges))
+		if s.workers {
+			s.goBackground(audit.NewPurger(exchanges, time.Hour).Run)
+		}
+		slog.Info("payload auditing enabled", "store", cfg.AuditPayloadStore, "ttl", cfg.AuditPayloadTTL)
+	}
+	if len(cfg.ArchiveSinks) > 0 {
+		sinks, err := s.archiveSinks(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transcripts := archive.NewQueue(s.rdb, sinks, 5*time.Minute, cfg.ArchiveMaxDeliveries)
+		handlerOpts = append(handlerOpts, proxy.WithTranscriptArchive(transcripts))
+		if s.workers {
+			s.goBackground(transcripts.Process)
+		}
+		slog.Info("transcript archiving enabled", "sinks", cfg.ArchiveSinks)
+	}
+	if cfg.StreamUsageTrailers {
+		handlerOpts = append(handlerOpts, proxy.WithUsageTrailers())
+	}
+	handlerOpts = append(handlerOpts, proxy.WithBroadcasts(proxy.NewRedisBroadcasts(s.rdb, cfg.BroadcastStreamTTL)))
+	if cfg.ResponseCacheEnabled {
+		handlerOpts = append(handlerOpts, proxy.WithResponseCache(cache.NewRedisCache(s.rdb, cfg.ResponseCacheTTL)))
+	}
+	// Async jobs: the handler both enqueues and executes them, so the queue's
+	// executor is bound after the handler exists.
+	jobStore := worker.NewPostgresStore(s.pool, worker.WithRetention(cfg.JobResultTTL))
+	var executeJob worker.Executor
+	poolOpts := []worker.PoolOption{
+		worker.WithConcurrency(cfg.JobWorkers),
+		worker.WithVisibilityTimeout(cfg.JobVisibilityTimeout),
+		worker.WithMaxDeliveries(cfg.JobMaxDeliveries),
+		worker.WithNotifier(worker.NewNotifier(cfg.JobCallbackSecret, s.webhooks)),
+	}
+	var resultBlobs worker.BlobStore
+	if cfg.JobResultS3Bucket != "" {
+		blobs, err := worker.NewS3BlobStore(cfg.JobResultS3Endpoint, cfg.JobResultS3AccessKey, cfg.JobResultS3SecretKey,
+			cfg.JobResultS3Bucket, !cfg.JobResultS3Insecure)
+		if err != nil {
+			return nil, err
+		}
+		resultBlobs = blobs
+		poolOpts = append(poolOpts, worker.WithResultOffload(blobs, cfg.JobResultOffloadBytes))
+		handlerOpts = append(handlerOpts, proxy.WithJobResultURLs(blobs, cfg.JobResultURLTTL))
+		slog.Info("offloading job results", "over_bytes", cfg.JobResultOffloadBytes, "bucket", cfg.JobResultS3Bucket)
+	}
+	jobQueue := worker.NewWorkerPool(s.rdb, jobStore,
+		func(ctx context.Context, job *worker.AsyncJob) (*provider.Response, error) {
+			return executeJob(ctx, job)
+		},
+		poolOpts...,
+	)
+	handlerOpts = append(handlerOpts, proxy.WithJobs(jobQueue, jobStore), proxy.WithJobEvents(jobQueue))
+	handler := proxy.NewHandler(router, billingStore, limiter, tracer, handlerOpts...)
+	executeJob = handler.ExecuteJob
+
+	if s.workers {
+		s.goBackground(func(ctx context.Context) {
+			if err := jobQueue.Process(ctx); err != nil {
+				slog.Error("job workers stopped", "err", err)
+			}
+		})
+		if cfg.JobResultTTL > 0 {
+			s.goBackground(worker.NewSweeper(jobStore, resultBlobs, 10*time.Minute).Run)
+		}
+	}
+
+	ready := health.New(cfg.ReadinessTimeout, s.readinessChecks(cfg, providers)...)
+	r := newRouter(ready)
+	// Operator routes share the public listener unless ADMIN_PORT gives
+	// them their own.
+	ops := r
+	if cfg.AdminPort != "" {
+		if s.adminTLS, err = adminTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+		ops = newRouter(ready)
+		s.adminRoutes = ops
+	}
+	mountMetrics(r, ops, cfg)
+
+	// Protected routes
+	if s.publicAPI {
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Use(identities.Middleware(func(ctx context.Context, tenantID string) (string, error) {
+				settings, err := tenantStore.Get(ctx, tenantID)
+				if err != nil {
+					return "", err
+				}
+				return settings.Plan, nil
+			}))
+			chat := auth.RequireScope(auth.ScopeChatWrite)
+			r.With(chat).Post("/v1/chat/completions", handler.HandleComplete)
+			r.With(chat).Post("/v1/chat/completions/stream", handler.HandleCompleteStream)
+			r.With(chat).Get("/v1/chat/completions/stream/{request_id}", handler.HandleSubscribeStream)
+			r.With(chat).Post("/v1/chat/completions/batch", handler.HandleCompleteBatch)
+			r.With(chat).Post("/v1/estimate", handler.HandleEstimate)
+			r.With(chat).Post("/v1/embeddings", handler.HandleEmbeddings)
+			r.With(chat).Post("/v1/messages", handler.HandleMessages)
+			r.With(chat).Post("/v1/jobs", handler.HandleCreateJob)
+			r.With(auth.RequireScope(auth.ScopeModelsRead)).Get("/v1/models", handler.HandleListModels)
+			r.With(auth.RequireScope(auth.ScopeModelsRead)).Get("/v1/models/{id}", handler.HandleGetModel)
+			r.With(auth.RequireScope(auth.ScopeModelsRead)).Get("/v1/status", handler.HandleStatus)
+			usage := auth.RequireScope(auth.ScopeUsageRead)
+			r.With(usage, accessLogger.Middleware("usage", nil)).Get("/v1/usage", handler.HandleUsage)
+			r.With(usage, accessLogger.Middleware("usage_summary", nil)).Get("/v1/usage/summary", handler.HandleUsageSummary)
+			r.With(usage, accessLogger.Middleware("usage_export", nil)).Get("/v1/usage/export", handler.HandleUsageExport)
+			r.With(usage, accessLogger.Middleware("usage_forecast", nil)).Get("/v1/usage/forecast", handler.HandleUsageForecast)
+			r.With(usage, accessLogger.Middleware("budget", nil)).Get("/v1/budget", handler.HandleBudget)
+			r.With(auth.RequireScope(auth.ScopeKeysRead), accessLogger.Middleware("api_keys", nil)).Get("/v1/keys", handler.HandleListKeys)
+			jobs := auth.RequireScope(auth.ScopeJobsRead)
+			r.With(jobs).Get("/v1/jobs/{id}", handler.HandleGetJob)
+			r.With(jobs).Get("/v1/jobs/{id}/events", handler.HandleJobEvents)
+			r.With(auth.RequireScope(auth.ScopeRequestsRead), accessLogger.Middleware("request_payloads", nil)).Get("/v1/requests/{request_id}", handler.HandleGetRequest)
+			r.With(auth.RequireScope(auth.ScopeRequestsRead), accessLogger.Middleware("conversation_export", nil)).Get("/v1/conversations/{conversation_id}/export", handler.HandleExportConversation)
+		})
+	}
+
+	// Operator routes
+	if s.adminAPI && (cfg.AdminToken != "" || cfg.AdminClientCA != "") {
+		adminHandler := admin.NewHandler(s.authStore,
+			admin.WithModelPolicies(policyStore),
+			admin.WithRoutingPolicies(routingStore, routing, tenantStore),
+			admin.WithTenantSettings(tenantStore),
+			admin.WithSystemPrompts(promptStore),
+			admin.WithDeadLetters(jobQueue),
+			admin.WithProviders(router),
+			admin.WithBilling(billingStore),
+			admin.WithConfig(s.config),
+			admin.WithCapacityCalendar(calendar),
+			admin.WithTiering(tierer),
+			admin.WithKeyCache(auth.NewRedisKeyCache(s.rdb)),
+			admin.WithDryRun(dryrun.NewReplayer(billingStore, tenantStore, router, routing, policyStore, replayOpts...)),
+		)
+		ops.Route("/admin", func(r chi.Router) {
+			// Without a token, client certificates checked by the admin
+			// listener authenticate operators.
+			if cfg.AdminToken != "" {
+				r.Use(auth.NewAdminMiddleware(cfg.AdminToken))
+			}
+			// Every operator call is audited, with the tenant it names.
+			r.Use(accessLogger.Middleware("admin", func(r *http.Request) string {
+				return chi.URLParam(r, "tenantID")
+			}))
+			r.Get("/keys/export", adminHandler.HandleExportKeys)
+			r.Post("/keys/import", adminHandler.HandleImportKeys)
+			r.Post("/keys", adminHandler.HandleCreateKey)
+			r.Put("/keys/{id}/scopes", adminHandler.HandleSetKeyScopes)
+			r.Get("/keys/stale", adminHandler.HandleStaleKeys)
+			r.Get("/tenants/{tenantID}/model-policy", adminHandler.HandleGetModelPolicy)
+			r.Put("/tenants/{tenantID}/model-policy", adminHandler.HandlePutModelPolicy)
+			r.Delete("/tenants/{tenantID}/model-policy", adminHandler.HandleDeleteModelPolicy)
+			r.Get("/tenants/{tenantID}/settings", adminHandler.HandleGetTenantSettings)
+			r.Put("/tenants/{tenantID}/settings", adminHandler.HandlePutTenantSettings)
+			r.Get("/routing-policies", adminHandler.HandleListRoutingPolicies)
+			r.Put("/routing-policies/global", adminHandler.HandlePutRoutingPolicy)
+			r.Delete("/routing-policies/global", adminHandler.HandleDeleteRoutingPolicy)
+			r.Put("/routing-policies/{level}/{id}", adminHandler.HandlePutRoutingPolicy)
+			r.Delete("/routing-policies/{level}/{id}", adminHandler.HandleDeleteRoutingPolicy)
+			r.Get("/keys/{id}/routing-policy", adminHandler.HandleEffectiveRoutingPolicy)
+			r.Get("/tenants/{tenantID}/prompts", adminHandler.HandleListPrompts)
+			r.Get("/tenants/{tenantID}/prompts/{promptID}", adminHandler.HandleGetPrompt)
+			r.Post("/tenants/{tenantID}/prompts/{promptID}", adminHandler.HandleSavePrompt)
+			r.Delete("/tenants/{tenantID}/prompts/{promptID}", adminHandler.HandleDeletePrompt)
+			r.Get("/v1/jobs/dead", adminHandler.HandleListDeadLetters)
+			r.Post("/v1/jobs/{id}/retry", adminHandler.HandleRetryJob)
+			r.Get("/providers", adminHandler.HandleListProviders)
+			r.Post("/providers/{name}/disable", adminHandler.HandleDisableProvider)
+			r.Post("/providers/{name}/enable", adminHandler.HandleEnableProvider)
+			r.Get("/provider-calendar", adminHandler.HandleListCapacityWindows)
+			r.Post("/provider-calendar", adminHandler.HandleAddCapacityWindow)
+			r.Delete("/provider-calendar/{id}", adminHandler.HandleDeleteCapacityWindow)
+			r.Post("/billing/reconcile", adminHandler.HandleReconcile)
+			r.Get("/tier-changes", adminHandler.HandleListTierChanges)
+			r.Post("/tier-changes/{id}/approve", adminHandler.HandleApproveTierChange)
+			r.Post("/tier-changes/{id}/reject", adminHandler.HandleRejectTierChange)
+			r.Get("/config", adminHandler.HandleGetConfig)
+			r.Post("/config/reload", adminHandler.HandleReloadConfig)
+		})
+	}
+	s.routes = r
+
+	return s, nil
+}
+
+// archiveSinks builds the compliance archive sinks cfg names.
+func (s *Server) archiveSinks(cfg *config.Config) ([]archive.Sink, error) {
+	var sinks []archive.Sink
+	for _, name := range cfg.ArchiveSinks {
+		switch name {
+		case "s3":
+			sink, err := archive.NewS3Sink(cfg.ArchiveS3Endpoint, cfg.ArchiveS3AccessKey, cfg.ArchiveS3SecretKey,
+				cfg.ArchiveS3Bucket, !cfg.ArchiveS3Insecure)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sink := archive.NewKafkaSink(cfg.ArchiveKafkaBrokers, cfg.ArchiveKafkaTopic)
+			s.onClose(func() { _ = sink.Close() })
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks, nil
+}
+
+// readinessChecks are the dependencies GET /readyz verifies.
+func (s *Server) readinessChecks(cfg *config.Config, providers []provider.Provider) []health.Check {
+	checks := []health.Check{
+		{Name: "postgres", Probe: s.pool.Ping},
+		{Name: "redis", Probe: func(ctx context.Context) error { return s.rdb.Ping(ctx).Err() }},
+	}
+	if cfg.ReadinessProbeProviders {
+		probes := make(map[string]func(context.Context) error)
+		for _, p := range providers {
+			if pinger, ok := p.(provider.Pinger); ok {
+				probes[p.Name()] = pinger.Ping
+			}
+		}
+		if len(probes) > 0 {
+			checks = append(checks, health.Check{Name: "providers", Probe: health.AnyOf(probes)})
+		}
+	}
+	return checks
+}
+
+// newRouter returns a router with the gateway's common middleware, /healthz
+// and the Kubernetes probes /livez and /readyz.
+func newRouter(ready *health.Checker) chi.Router {
+	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	r.Use(chimiddleware.Logger)
+	r.Use(chimiddleware.Recoverer)
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"status":"ok","service":"llm-gateway"}`))
+	})
+	r.Get("/livez", health.HandleLive)
+	r.Get("/readyz", ready.HandleReady)
+	return r
+}
+
+func modelAliases(aliases map[string][]config.ModelTarget) map[string][]proxy.ModelTarget {
+	out := make(map[string][]proxy.ModelTarget, len(aliases))
+	for alias, targets := range aliases {
+		for _, t := range targets {
+			out[alias] = append(out[alias], proxy.ModelTarget{Provider: t.Provider, Model: t.Model})
+		}
+	}
+	return out
+}
+
+func tieringRules(rules []config.TieringRule) []tiering.Rule {
+	out := make([]tiering.Rule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, tiering.Rule{
+			From:      r.From,
+			To:        r.To,
+			Metric:    r.Metric,
+			Op:        r.Op,
+			Threshold: r.Threshold,
+			Days:      r.Days,
+			Action:    r.Action,
+		})
+	}
+	return out
+}
+
+// credentials is where each provider's API expects its key.
+var credentials = map[string]provider.Credential{
+	"gemini": provider.QueryKey("key"),
+	"openai": provider.BearerToken,
+	"claude": provider.HeaderKey("x-api-key"),
+	"cohere": provider.BearerToken,
+	"ollama": provider.BearerToken,
+}
+
+// Providers builds the upstream providers configured in cfg, with the key
+// pools and endpoint failovers of those that have them.
+func Providers(cfg *config.Config) ([]provider.Provider, map[string]*provider.KeyPool, map[string]*provider.Failover, error) {
+	clients := make(map[string]*http.Client)
+	baseURLs := make(map[string]string)
+	keyPools := make(map[string]*provider.KeyPool)
+	endpoints := make(map[string]*provider.Failover)
+	for _, name := range []string{"gemini", "openai", "claude", "cohere", "ollama"} {
+		egress := providerEgress(cfg.ProviderEgress, name)
+		egress.DNSCacheTTL = cfg.DNSCacheTTL
+		egress.ConnectTimeout = cfg.ProviderConnectTimeout
+		egress.ReadTimeout = cfg.ProviderReadTimeout
+		egress.MaxIdleConnsPerHost = cfg.ProviderMaxIdleConns
+		egress.IdleConnTimeout = cfg.ProviderIdleConnTimeout
+		client, err := egress.HTTPClient()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("egress for %s: %w", name, err)
+		}
+		// Traced innermost, so each retry, pooled key and endpoint is a span
+		client.Transport = provider.NewTracing(otel.GetTracerProvider().Tracer("llm-gateway"), name, client.Transport)
+		if egress.ProxyURL != "" || egress.BindIP != "" {
+			slog.Info("provider egress", "provider", name, "proxy", egress.ProxyURL, "bind_ip", egress.BindIP)
+		}
+		if urls := cfg.ProviderEndpoints[name]; len(urls) > 0 {
+			failover, err := provider.NewFailover(urls, cfg.EndpointCooldown, client.Transport)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("endpoints for %s: %w", name, err)
+			}
+			client = &http.Client{Transport: failover}
+			endpoints[name] = failover
+			baseURLs[name] = failover.Primary()
+			slog.Info("provider endpoints", "provider", name, "endpoints", urls)
+		}
+		if keys := cfg.ProviderAPIKeys[name]; len(keys) > 0 {
+			pool, err := provider.NewKeyPool(keys, credentials[name], provider.KeyPoolConfig{
+				Strategy:    cfg.ProviderKeyStrategy,
+				MaxFailures: cfg.ProviderKeyMaxFailures,
+				Cooldown:    cfg.ProviderKeyCooldown,
+			}, client.Transport)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("api keys for %s: %w", name, err)
+			}
+			client = &http.Client{Transport: pool}
+			keyPools[name] = pool
+			slog.Info("provider key pool", "provider", name, "keys", len(keys), "strategy", cfg.ProviderKeyStrategy)
+		}
+		if cfg.ProviderMaxRetries > 0 {
+			client = &http.Client{Transport: provider.NewRetry(provider.RetryPolicy{
+				MaxRetries: cfg.ProviderMaxRetries,
+				Backoff:    cfg.ProviderRetryBackoff,
+				MaxWait:    cfg.ProviderRetryMaxWait,
+			}, client.Transport)}
+		}
+		clients[name] = client
+	}
+
+	providers := []provider.Provider{
+		gemini.New(cfg.GeminiAPIKey,
+			gemini.WithStreamMode(gemini.StreamMode(cfg.GeminiStreamMode)),
+			gemini.WithHTTPClient(clients["gemini"]),
+			gemini.WithBaseURL(baseURLs["gemini"]),
+		),
+		openai.New(cfg.OpenAIAPIKey,
+			openai.WithHTTPClient(clients["openai"]),
+			openai.WithBaseURL(baseURLs["openai"]),
+		),
+		claude.New(cfg.AnthropicAPIKey,
+			claude.WithHTTPClient(clients["claude"]),
+			claude.WithBaseURL(baseURLs["claude"]),
+		),
+	}
+	if cfg.CohereAPIKey != "" || len(cfg.ProviderAPIKeys["cohere"]) > 0 {
+		providers = append(providers, cohere.New(cfg.CohereAPIKey,
+			cohere.WithHTTPClient(clients["cohere"]),
+			cohere.WithBaseURL(baseURLs["cohere"]),
+		))
+		slog.Info("cohere provider enabled")
+	}
+	if cfg.OllamaBaseURL != "" {
+		baseURL := cfg.OllamaBaseURL
+		if primary := baseURLs["ollama"]; primary != "" {
+			baseURL = primary
+		}
+		providers = append(providers, ollama.New(baseURL, cfg.OllamaModels,
+			ollama.WithAPIMode(ollama.APIMode(cfg.OllamaAPIMode)),
+			ollama.WithAPIKey(cfg.OllamaAPIKey),
+			ollama.WithHTTPClient(clients["ollama"]),
+		))
+		slog.Info("ollama provider enabled", "base_url", cfg.OllamaBaseURL, "api_mode", cfg.OllamaAPIMode, "models", cfg.OllamaModels)
+	}
+	return providers, keyPools, endpoints, nil
+}
+
+// providerEgress takes each egress setting from the provider's own entry,
+// falling back to the "*" entry.
+func providerEgress(egress map[string]config.Egress, name string) provider.Egress {
+	own, all := egress[name], egress["*"]
+	e := provider.Egress{ProxyURL: own.ProxyURL, CABundle: own.CABundle, BindIP: own.BindIP}
+	if e.ProxyURL == "" {
+		e.ProxyURL = all.ProxyURL
+	}
+	if e.CABundle == "" {
+		e.CABundle = all.CABundle
+	}
+	if e.BindIP == "" {
+		e.BindIP = all.BindIP
+	}
+	return e
+}
+
+// Handler returns the gateway's HTTP routes.
+func (s *Server) Handler() http.Handler {
+	return s.routes
+}
+
+// AdminHandler returns the admin listener's routes, or nil when operator
+// endpoints are served by Handler.
+func (s *Server) AdminHandler() http.Handler {
+	return s.adminRoutes
+}
+
+// Run serves Handler on cfg.Port, and AdminHandler on cfg.AdminPort if set,
+// until ctx is cancelled, then drains connections and closes the Server.
+func (s *Server) Run(ctx context.Context) error {
+	srv := s.httpServer(s.cfg.Port, s.routes)
+	servers := []*http.Server{srv}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("listening", "port", s.cfg.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+	if s.adminRoutes != nil {
+		adminSrv := s.httpServer(s.cfg.AdminPort, s.adminRoutes)
+		adminSrv.TLSConfig = s.adminTLS
+		servers = append(servers, adminSrv)
+		go func() {
+			if adminSrv.TLSConfig == nil {
+				slog.Info("admin listener listening", "port", s.cfg.AdminPort)
+				serveErr <- adminSrv.ListenAndServe()
+				return
+			}
+			slog.Info("admin listener listening", "port", s.cfg.AdminPort, "tls", true,
+				"client_certificates", adminSrv.TLSConfig.ClientCAs != nil)
+			serveErr <- adminSrv.ListenAndServeTLS("", "")
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		for _, srv := range servers {
+			_ = srv.Close()
+		}
+		_ = s.Close(context.Background())
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down gracefully")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	var shutdownErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer flushCancel()
+	closeErr := s.Close(flushCtx)
+
+	if shutdownErr != nil {
+		return fmt.Errorf("forced shutdown: %w", shutdownErr)
+	}
+	return closeErr
+}
+
+func (s *Server) httpServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:           ":" + port,
+		Handler:        handler,
+		ReadTimeout:    30 * time.Second,
+		WriteTimeout:   90 * time.Second,
+		IdleTimeout:    120 * time.Second,
+		MaxHeaderBytes: s.cfg.MaxHeaderBytes,
+	}
+}
+
+// AuthStore exposes API key storage, e.g. for seeding.
+func (s *Server) AuthStore() auth.Store {
+	return s.authStore
+}
+
+// Close stops background work, flushes pending usage logs and webhook
+// deliveries within ctx and closes connections.
+func (s *Server) Close(ctx context.Context) error {
+	runReverse(s.background)
+	s.background = nil
+
+	var err error
+	if s.usage != nil {
+		if flushErr := s.usage.Flush(ctx); flushErr != nil {
+			err = fmt.Errorf("usage logs still in flight at shutdown: %w", flushErr)
+		}
+	}
+	if s.webhooks != nil {
+		if flushErr := s.webhooks.Flush(ctx); flushErr != nil && err == nil {
+			err = fmt.Errorf("webhook deliveries still in flight at shutdown: %w", flushErr)
+		}
+	}
+
+	runReverse(s.closers)
+	s.closers = nil
+	return err
+}
+
+func (s *Server) newLimiter(cfg *config.Config) *ratelimit.Limiter {
+	limiterOpts := []ratelimit.Option{ratelimit.WithBatchShare(cfg.BatchRateLimitShare)}
+	if s.globalRdb != nil {
+		reconciler := ratelimit.NewReconciler(s.globalRdb, cfg.Region, cfg.DefaultRateLimitTPM, cfg.ReconcileInterval)
+		s.goBackground(reconciler.Run)
+		limiterOpts = append(limiterOpts, ratelimit.WithReconciler(reconciler))
+		slog.Info("regional state mode", "region", cfg.Region, "reconcile_interval", cfg.ReconcileInterval)
+	}
+	return ratelimit.NewLimiter(s.rdb, cfg.DefaultRateLimitTPM, limiterOpts...)
+}
+
+// checkSchema makes sure the database has every migration this build
+// embeds, applying pending ones when apply is set.
+func (s *Server) checkSchema(ctx context.Context, apply bool) error {
+	migrator, err := migrate.New(s.pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+	if !apply {
+		return migrator.Check(ctx)
+	}
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		slog.Info("migration applied", "version", m.Version, "name", m.Name)
+	}
+	return err
+}
+
+// newJWTVerifier accepts the JWTs of the identity provider cfg names.
+func newJWTVerifier(cfg *config.Config) *auth.JWTVerifier {
+	tiers := make(map[string]auth.RateLimits, len(cfg.OIDCRateLimitTiers))
+	for name, t := range cfg.OIDCRateLimitTiers {
+		tiers[name] = auth.RateLimits{TPM: t.TPM, RPM: t.RPM}
+	}
+	return auth.NewJWTVerifier(auth.JWTConfig{
+		JWKSURL:       cfg.OIDCJWKSURL,
+		Issuer:        cfg.OIDCIssuer,
+		Audience:      cfg.OIDCAudience,
+		TenantClaim:   cfg.OIDCTenantClaim,
+		TierClaim:     cfg.OIDCTierClaim,
+		Tiers:         tiers,
+		ScopeClaim:    cfg.OIDCScopeClaim,
+		DefaultScopes: cfg.OIDCDefaultScopes,
+		JWKSRefresh:   cfg.OIDCJWKSRefresh,
+	})
+}
+
+// instanceID names this process among the gateway's replicas.
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// goBackground runs fn until Close cancels its context and waits for it.
+func (s *Server) goBackground(fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	s.background = append(s.background, func() {
+		cancel()
+		<-done
+	})
+}
+
+func (s *Server) onClose(fn func()) {
+	s.closers = append(s.closers, fn)
+}
+
+func runReverse(fns []func()) {
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i]()
+	}
+}
+
+// ragEmbedder picks the embedder for retrieval queries. Existing collections
+// were indexed with OpenAI models, so OpenAI wins when it is configured.
+func ragEmbedder(providers []provider.Provider) (provider.Embedder, bool) {
+	var fallback provider.Embedder
+	for _, p := range providers {
+		e, ok := p.(provider.Embedder)
+		if !ok {
+			continue
+		}
+		if p.Name() == "openai" {
+			return e, true
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+	return fallback, fallback != nil
+}
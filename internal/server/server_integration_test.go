@@ -0,0 +1,206 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+
+	"github.com/vnmchuo/llm-gateway/config"
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+	"github.com/vnmchuo/llm-gateway/internal/migrate"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/migrations"
+)
+
+const (
+	testAPIKey   = "integration-key"
+	testTenantID = "00000000-0000-0000-0000-0000000000aa"
+)
+
+type stubProvider struct{}
+
+func (stubProvider) Complete(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+	return &provider.Response{Content: "stubbed", Model: req.Model, Provider: "stub", InputTokens: 10, OutputTokens: 5}, nil
+}
+
+func (stubProvider) CompleteStream(ctx context.Context, req *provider.Request) (<-chan *provider.Chunk, error) {
+	ch := make(chan *provider.Chunk, 2)
+	ch <- &provider.Chunk{Delta: "stubbed"}
+	ch <- &provider.Chunk{Done: true, Usage: &provider.Usage{InputTokens: 10, OutputTokens: 5}}
+	close(ch)
+	return ch, nil
+}
+
+func (stubProvider) Name() string                { return "stub" }
+func (stubProvider) CostPerInputToken() float64  { return 0.001 }
+func (stubProvider) CostPerOutputToken() float64 { return 0.002 }
+func (stubProvider) SupportedModels() []string   { return []string{"stub-model"} }
+
+// startGateway runs Postgres and Redis in containers, applies migrations and
+// returns a wired Server plus a pool for asserting on stored rows.
+func startGateway(t *testing.T) (*Server, *pgxpool.Pool) {
+	t.Helper()
+	ctx := context.Background()
+
+	pg, err := tcpostgres.Run(ctx, "pgvector/pgvector:pg17",
+		tcpostgres.WithDatabase("llm_gateway"),
+		tcpostgres.WithUsername("postgres"),
+		tcpostgres.WithPassword("postgres"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, pg)
+	if err != nil {
+		t.Fatalf("failed to start postgres: %v", err)
+	}
+	dsn, err := pg.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get postgres dsn: %v", err)
+	}
+
+	rc, err := tcredis.Run(ctx, "redis:alpine")
+	testcontainers.CleanupContainer(t, rc)
+	if err != nil {
+		t.Fatalf("failed to start redis: %v", err)
+	}
+	redisAddr, err := rc.Endpoint(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to get redis address: %v", err)
+	}
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	applyMigrations(t, pool)
+
+	cfg := &config.Config{
+		PostgresDSN:          dsn,
+		RedisAddr:            redisAddr,
+		StateMode:            "global",
+		DefaultRateLimitTPM:  100000,
+		RouterMaxAttempts:    1,
+		UsageMaxInFlight:     16,
+		UsageWriteTimeout:    5 * time.Second,
+		MaxConversationTurns: 100,
+		JobWorkers:           1,
+	}
+	gateway, err := New(ctx, cfg, WithProviders([]provider.Provider{stubProvider{}}), WithWorkers(false))
+	if err != nil {
+		t.Fatalf("failed to start gateway: %v", err)
+	}
+	t.Cleanup(func() { _ = gateway.Close(context.Background()) })
+
+	sum := sha256.Sum256([]byte(testAPIKey))
+	err = gateway.AuthStore().Create(ctx, &auth.APIKey{
+		TenantID:  testTenantID,
+		KeyHash:   hex.EncodeToString(sum[:]),
+		RateLimit: 100000,
+		Active:    true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create api key: %v", err)
+	}
+	return gateway, pool
+}
+
+func applyMigrations(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+	migrator, err := migrate.New(pool, migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		t.Fatalf("Expected the schema to be current after migrating: %v", err)
+	}
+}
+
+func TestIntegration_CompletionIsAuthenticatedServedAndBilled(t *testing.T) {
+	gateway, pool := startGateway(t)
+	srv := httptest.NewServer(gateway.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/readyz")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected /readyz 200 with Postgres and Redis up, got %d", resp.StatusCode)
+	}
+
+	body := `{"model":"stub-model","messages":[{"role":"user","content":"hi"}]}`
+
+	resp, err = http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a key, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Choices) == 0 || out.Choices[0].Message.Content != "stubbed" {
+		t.Errorf("Expected stubbed completion, got %+v", out)
+	}
+
+	// Close flushes the background usage writes.
+	if err := gateway.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	var providerName string
+	var inputTokens, outputTokens int
+	var cost float64
+	err = pool.QueryRow(context.Background(),
+		`SELECT provider, input_tokens, output_tokens, cost_usd::float8 FROM usage_logs WHERE tenant_id = $1`, testTenantID,
+	).Scan(&providerName, &inputTokens, &outputTokens, &cost)
+	if err != nil {
+		t.Fatalf("Expected a usage log row: %v", err)
+	}
+	if providerName != "stub" || inputTokens != 10 || outputTokens != 5 || cost != 0.02 {
+		t.Errorf("Unexpected usage log: provider=%s tokens=%d/%d cost=%v", providerName, inputTokens, outputTokens, cost)
+	}
+
+	// Close also flushes the key's buffered last use.
+	var lastUsed *time.Time
+	err = pool.QueryRow(context.Background(),
+		`SELECT last_used_at FROM api_keys WHERE tenant_id = $1`, testTenantID,
+	).Scan(&lastUsed)
+	if err != nil || lastUsed == nil {
+		t.Errorf("Expected last_used_at to be set, got %v (err %v)", lastUsed, err)
+	}
+}
@@ -0,0 +1,44 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) SaveComparison(ctx context.Context, c *Comparison) error {
+	query := `
+		INSERT INTO shadow_comparisons (
+			request_id, tenant_id,
+			primary_provider, primary_model, primary_content, primary_input_tokens,
+			primary_output_tokens, primary_cost_usd, primary_latency_ms,
+			shadow_provider, shadow_model, shadow_content, shadow_input_tokens,
+			shadow_output_tokens, shadow_cost_usd, shadow_latency_ms, shadow_error,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	`
+	p, sh := c.Primary, c.Shadow
+	_, err := s.db.Exec(ctx, query,
+		c.RequestID, c.TenantID,
+		p.Provider, p.Model, p.Content, p.InputTokens, p.OutputTokens, p.CostUSD, p.LatencyMs,
+		sh.Provider, sh.Model, sh.Content, sh.InputTokens, sh.OutputTokens, sh.CostUSD, sh.LatencyMs, sh.Error,
+		c.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save shadow comparison: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package shadow
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Config duplicates Percent of a tenant's completions to Model on Provider.
+type Config struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Percent  float64 `json:"percent"` // 0-100
+}
+
+// Sampled reports whether this request should be mirrored.
+func (c *Config) Sampled() bool {
+	return c != nil && c.Provider != "" && c.Model != "" && rand.Float64()*100 < c.Percent
+}
+
+// Result is one side of a comparison.
+type Result struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Content      string  `json:"content"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	LatencyMs    int64   `json:"latency_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// Comparison pairs the response a client got with the shadow provider's
+// answer to the same request, for offline quality comparison.
+type Comparison struct {
+	RequestID string    `json:"request_id"`
+	TenantID  string    `json:"tenant_id"`
+	Primary   Result    `json:"primary"`
+	Shadow    Result    `json:"shadow"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Store interface {
+	SaveComparison(ctx context.Context, c *Comparison) error
+}
+
+// Call runs the shadow request and prices what it used.
+type Call func(ctx context.Context) (resp *provider.Response, costUSD float64, err error)
+
+// Mirror runs shadow requests in the background. Shadow traffic must never
+// slow down or fail the real request, so when every slot is busy the
+// request is not mirrored at all.
+type Mirror struct {
+	store   Store
+	timeout time.Duration
+	slots   chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewMirror(store Store, maxInFlight int, timeout time.Duration) *Mirror {
+	if maxInFlight <= 0 {
+		maxInFlight = 32
+	}
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mirror{store: store, timeout: timeout, slots: make(chan struct{}, maxInFlight), ctx: ctx, cancel: cancel}
+}
+
+// Run executes call off the request path and stores it next to c.Primary.
+// It reports false when the request was dropped for lack of a slot.
+func (m *Mirror) Run(c *Comparison, call Call) bool {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		log.Printf("shadow: dropped request %s for tenant %s, %d already in flight", c.RequestID, c.TenantID, cap(m.slots))
+		return false
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-m.slots }()
+
+		ctx, cancel := context.WithTimeout(m.ctx, m.timeout)
+		defer cancel()
+		start := time.Now()
+		resp, cost, err := call(ctx)
+		c.Shadow.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			c.Shadow.Error = err.Error()
+		} else {
+			c.Shadow.Content = resp.Content
+			c.Shadow.InputTokens = resp.InputTokens
+			c.Shadow.OutputTokens = resp.OutputTokens
+			c.Shadow.CostUSD = cost
+		}
+		c.CreatedAt = time.Now().UTC()
+
+		// Saved even when the shadow call was cut short by shutdown.
+		saveCtx, cancelSave := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelSave()
+		if err := m.store.SaveComparison(saveCtx, c); err != nil {
+			log.Printf("shadow: failed to store comparison for request %s: %v", c.RequestID, err)
+		}
+	}()
+	return true
+}
+
+// Close abandons in-flight shadow requests and waits for them to finish.
+func (m *Mirror) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
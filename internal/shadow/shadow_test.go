@@ -0,0 +1,98 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+type memStore struct {
+	mu    sync.Mutex
+	saved []*Comparison
+}
+
+func (s *memStore) SaveComparison(ctx context.Context, c *Comparison) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, c)
+	return nil
+}
+
+func TestMirror_StoresBothAnswers(t *testing.T) {
+	store := &memStore{}
+	m := NewMirror(store, 0, 0)
+	m.Run(&Comparison{RequestID: "ok", Primary: Result{Content: "primary"}}, func(ctx context.Context) (*provider.Response, float64, error) {
+		return &provider.Response{Content: "shadow", InputTokens: 3, OutputTokens: 4}, 0.5, nil
+	})
+	m.Run(&Comparison{RequestID: "failed"}, func(ctx context.Context) (*provider.Response, float64, error) {
+		return nil, 0, errors.New("upstream down")
+	})
+	m.Close()
+
+	if len(store.saved) != 2 {
+		t.Fatalf("Expected 2 comparisons, got %d", len(store.saved))
+	}
+	for _, c := range store.saved {
+		switch c.RequestID {
+		case "ok":
+			if c.Primary.Content != "primary" || c.Shadow.Content != "shadow" || c.Shadow.CostUSD != 0.5 || c.Shadow.OutputTokens != 4 {
+				t.Errorf("Unexpected comparison %+v", c)
+			}
+		case "failed":
+			if c.Shadow.Error != "upstream down" {
+				t.Errorf("Expected the shadow error to be stored, got %+v", c.Shadow)
+			}
+		}
+	}
+}
+
+func TestMirror_DropsWhenFull(t *testing.T) {
+	store := &memStore{}
+	m := NewMirror(store, 1, time.Second)
+	release := make(chan struct{})
+	m.Run(&Comparison{RequestID: "a"}, func(ctx context.Context) (*provider.Response, float64, error) {
+		<-release
+		return &provider.Response{}, 0, nil
+	})
+	if m.Run(&Comparison{RequestID: "b"}, func(ctx context.Context) (*provider.Response, float64, error) {
+		t.Error("Expected the second request not to be mirrored")
+		return &provider.Response{}, 0, nil
+	}) {
+		t.Error("Expected Run to report the dropped request")
+	}
+	close(release)
+	m.Close()
+	if len(store.saved) != 1 {
+		t.Errorf("Expected 1 comparison, got %d", len(store.saved))
+	}
+}
+
+func TestMirror_CloseAbandonsShadowRequests(t *testing.T) {
+	store := &memStore{}
+	m := NewMirror(store, 1, time.Minute)
+	m.Run(&Comparison{RequestID: "a"}, func(ctx context.Context) (*provider.Response, float64, error) {
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	})
+	m.Close()
+	if len(store.saved) != 1 || store.saved[0].Shadow.Error == "" {
+		t.Errorf("Expected the cut-off request to be stored with its error, got %+v", store.saved)
+	}
+}
+
+func TestConfig_Sampled(t *testing.T) {
+	var none *Config
+	if none.Sampled() {
+		t.Error("Expected no sampling without a config")
+	}
+	if (&Config{Provider: "gemini", Model: "gemini-1.5-flash"}).Sampled() {
+		t.Error("Expected no sampling at 0%")
+	}
+	if !(&Config{Provider: "gemini", Model: "gemini-1.5-flash", Percent: 100}).Sampled() {
+		t.Error("Expected every request sampled at 100%")
+	}
+}
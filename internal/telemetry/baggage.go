@@ -0,0 +1,130 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+// Baggage members identifying who a request is for. BaggageProcessor copies
+// them onto every span started under the request, under the same names.
+const (
+	BaggageTenantID = "tenant_id"
+	BaggagePlan     = "plan"
+	BaggageAPIKeyID = "api_key_id"
+)
+
+var baggageKeys = []string{BaggageTenantID, BaggagePlan, BaggageAPIKeyID}
+
+// How tenant and API key IDs appear in traces.
+const (
+	IdentitiesPlain = "plain"
+	IdentitiesHash  = "hash" // HMAC-SHA256 with a salt, so IDs can't be guessed back
+)
+
+// Identities turns tenant and API key IDs into what traces record.
+type Identities struct {
+	mode string
+	salt []byte
+}
+
+// NewIdentities records IDs as they are ("plain") or as salted hashes
+// ("hash"), which stay stable per salt so spans can still be grouped.
+func NewIdentities(mode, salt string) (*Identities, error) {
+	switch mode {
+	case IdentitiesPlain:
+	case IdentitiesHash:
+		if salt == "" {
+			return nil, fmt.Errorf("hashed identities need a salt")
+		}
+	default:
+		return nil, fmt.Errorf("unknown identities mode %q", mode)
+	}
+	return &Identities{mode: mode, salt: []byte(salt)}, nil
+}
+
+// ID is id as traces record it.
+func (i *Identities) ID(id string) string {
+	if i.mode != IdentitiesHash || id == "" {
+		return id
+	}
+	mac := hmac.New(sha256.New, i.salt)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// WithBaggage sets ctx's baggage to the tenant, its plan and the API key,
+// replacing any the client sent. Empty values are left out.
+func (i *Identities) WithBaggage(ctx context.Context, tenantID, plan, apiKeyID string) context.Context {
+	bag := baggage.FromContext(ctx)
+	for _, kv := range [][2]string{
+		{BaggageTenantID, i.ID(tenantID)},
+		{BaggagePlan, plan},
+		{BaggageAPIKeyID, i.ID(apiKeyID)},
+	} {
+		bag = bag.DeleteMember(kv[0])
+		if kv[1] == "" {
+			continue
+		}
+		m, err := baggage.NewMemberRaw(kv[0], kv[1])
+		if err != nil {
+			continue
+		}
+		if b, err := bag.SetMember(m); err == nil {
+			bag = b
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// PlanFunc looks up a tenant's plan; empty when it has none.
+type PlanFunc func(ctx context.Context, tenantID string) (string, error)
+
+// Middleware puts the authenticated tenant, its plan and the API key into
+// the request's baggage. It runs after authentication; requests without a
+// tenant pass through untouched, and a failed plan lookup only leaves the
+// plan out.
+func (i *Identities) Middleware(plan PlanFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			tenantID := auth.GetTenantID(ctx)
+			if tenantID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			name, _ := plan(ctx, tenantID)
+			ctx = i.WithBaggage(ctx, tenantID, name, auth.GetAPIKeyID(ctx))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// BaggageProcessor copies the tenant, plan and API key baggage members onto
+// each span as it starts, so every span of a request can be sliced by them
+// without each caller setting them.
+type BaggageProcessor struct{}
+
+var _ sdktrace.SpanProcessor = BaggageProcessor{}
+
+func (BaggageProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	bag := baggage.FromContext(ctx)
+	for _, key := range baggageKeys {
+		if m := bag.Member(key); m.Value() != "" {
+			span.SetAttributes(attribute.String(key, m.Value()))
+		}
+	}
+}
+
+func (BaggageProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (BaggageProcessor) Shutdown(context.Context) error   { return nil }
+func (BaggageProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/vnmchuo/llm-gateway/internal/auth"
+)
+
+func TestIdentities_HashesIDsWithTheSalt(t *testing.T) {
+	plain, _ := NewIdentities(IdentitiesPlain, "")
+	if got := plain.ID("tenant-1"); got != "tenant-1" {
+		t.Errorf("expected plain IDs unchanged, got %q", got)
+	}
+
+	a, _ := NewIdentities(IdentitiesHash, "salt-a")
+	b, _ := NewIdentities(IdentitiesHash, "salt-b")
+	if got := a.ID("tenant-1"); got == "tenant-1" || len(got) != 16 || got != a.ID("tenant-1") {
+		t.Errorf("expected a stable 16-char pseudonym, got %q", got)
+	}
+	if a.ID("tenant-1") == b.ID("tenant-1") {
+		t.Error("expected different salts to give different pseudonyms")
+	}
+	if _, err := NewIdentities(IdentitiesHash, ""); err == nil {
+		t.Error("expected hashing without a salt to be rejected")
+	}
+}
+
+func TestMiddleware_CopiesBaggageOntoSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(BaggageProcessor{}),
+		sdktrace.WithSpanProcessor(recorder),
+	).Tracer("test")
+	ids, _ := NewIdentities(IdentitiesHash, "salt")
+
+	h := ids.Middleware(func(ctx context.Context, tenantID string) (string, error) {
+		return "pro", nil
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Start(r.Context(), "child")
+		span.End()
+	}))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	ctx := auth.WithAPIKeyID(auth.WithTenantID(req.Context(), "tenant-1"), "key-1")
+	h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	got := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		got[string(kv.Key)] = kv.Value.AsString()
+	}
+	want := map[string]string{
+		BaggageTenantID: ids.ID("tenant-1"),
+		BaggagePlan:     "pro",
+		BaggageAPIKeyID: ids.ID("key-1"),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %s=%q on the span, got %q", k, v, got[k])
+		}
+	}
+}
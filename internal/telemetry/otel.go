@@ -3,13 +3,19 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"github.com/vnmchuo/llm-gateway/config"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
@@ -37,19 +43,13 @@ func InitTracer(serviceName string, cfg *config.Config) (func(), error) {
 		}
 	}
 
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			"", // Use empty schema URL to avoid conflicts with Default()
-			semconv.ServiceNameKey.String(serviceName),
-			semconv.ServiceVersionKey.String("0.1.0"),
-		),
-	)
+	res, err := newResource(serviceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, err
 	}
 
 	tp := trace.NewTracerProvider(
+		trace.WithSpanProcessor(BaggageProcessor{}),
 		trace.WithBatcher(exporter),
 		trace.WithResource(res),
 	)
@@ -66,3 +66,64 @@ func InitTracer(serviceName string, cfg *config.Config) (func(), error) {
 
 	return shutdown, nil
 }
+
+// InitMeter sets up OpenTelemetry metrics and returns a shutdown function.
+// Metrics are always exposed for Prometheus scraping via MetricsHandler;
+// with the OTLP exporter they are pushed to the collector as well.
+func InitMeter(serviceName string, cfg *config.Config) (func(), error) {
+	promExporter, err := prometheus.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus metric exporter: %w", err)
+	}
+	readers := []sdkmetric.Option{sdkmetric.WithReader(promExporter)}
+
+	if cfg.OTELExporterType == "otlp" {
+		exporter, err := otlpmetricgrpc.New(context.Background(),
+			otlpmetricgrpc.WithEndpoint(cfg.OTELExporterEndpoint),
+			otlpmetricgrpc.WithInsecure(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		readers = append(readers, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+	}
+
+	res, err := newResource(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	mp := sdkmetric.NewMeterProvider(append(readers, sdkmetric.WithResource(res))...)
+	otel.SetMeterProvider(mp)
+
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := mp.Shutdown(ctx); err != nil {
+			fmt.Printf("failed to shutdown MeterProvider: %v\n", err)
+		}
+	}
+
+	return shutdown, nil
+}
+
+// MetricsHandler serves the metrics registered by InitMeter in the
+// Prometheus text format.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+func newResource(serviceName string) (*resource.Resource, error) {
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			"", // Use empty schema URL to avoid conflicts with Default()
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String("0.1.0"),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,85 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, tenantID string) (*Settings, error) {
+	query := `SELECT settings FROM tenant_settings WHERE tenant_id = $1`
+
+	var raw []byte
+	err := s.db.QueryRow(ctx, query, tenantID).Scan(&raw)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &Settings{}, nil
+		}
+		return nil, fmt.Errorf("failed to get tenant settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode tenant settings: %w", err)
+	}
+	return &settings, nil
+}
+
+func (s *PostgresStore) Put(ctx context.Context, tenantID string, settings *Settings) error {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode tenant settings: %w", err)
+	}
+
+	query := `
+		INSERT INTO tenant_settings (tenant_id, settings, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (tenant_id) DO UPDATE SET settings = EXCLUDED.settings, updated_at = NOW()
+	`
+	if _, err := s.db.Exec(ctx, query, tenantID, raw); err != nil {
+		return fmt.Errorf("failed to save tenant settings: %w", err)
+	}
+	return nil
+}
+
+// ListPlans returns the plan of every tenant that has one.
+func (s *PostgresStore) ListPlans(ctx context.Context) (map[string]string, error) {
+	query := `SELECT tenant_id, settings->>'plan' FROM tenant_settings WHERE COALESCE(settings->>'plan', '') <> ''`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant plans: %w", err)
+	}
+	defer rows.Close()
+
+	plans := make(map[string]string)
+	for rows.Next() {
+		var tenantID, plan string
+		if err := rows.Scan(&tenantID, &plan); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant plan: %w", err)
+		}
+		plans[tenantID] = plan
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenant plans: %w", err)
+	}
+	return plans, nil
+}
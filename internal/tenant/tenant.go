@@ -0,0 +1,181 @@
+package tenant
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/audit"
+	"github.com/vnmchuo/llm-gateway/internal/conversation"
+	"github.com/vnmchuo/llm-gateway/internal/guardrail"
+	"github.com/vnmchuo/llm-gateway/internal/jsonstream"
+	"github.com/vnmchuo/llm-gateway/internal/language"
+	"github.com/vnmchuo/llm-gateway/internal/policy"
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+	"github.com/vnmchuo/llm-gateway/internal/shadow"
+)
+
+// Settings holds per-tenant feature switches. It is stored as a single JSONB
+// document so new features can add fields without a migration.
+type Settings struct {
+	// Suspended shuts the tenant out: its API keys and tokens are rejected
+	// with 401 until it is cleared.
+	Suspended bool `json:"suspended,omitempty"`
+	// OutputFormat "plain_text" strips markdown from responses.
+	OutputFormat string `json:"output_format,omitempty"`
+	// CitationStyle "brackets" rewrites footnote/fullwidth citations to [n].
+	CitationStyle string `json:"citation_style,omitempty"`
+	// OutputLanguage requires non-streaming responses in one language,
+	// retrying or translating those that aren't; see language.Policy.
+	OutputLanguage *language.Policy `json:"output_language,omitempty"`
+	// Guardrails block, redact or annotate prompts and responses matching
+	// blocklists, PII or moderation categories; see guardrail.Rule.
+	Guardrails []guardrail.Rule `json:"guardrails,omitempty"`
+	// SecurityWebhook is notified whenever guardrails block or redact
+	// content; see guardrail.Event.
+	SecurityWebhook *guardrail.Webhook `json:"security_webhook,omitempty"`
+	// StreamValidation checks streamed JSON output against the request's
+	// response_format as it arrives, stopping and retrying generations
+	// that derail; see jsonstream.Policy.
+	StreamValidation *jsonstream.Policy `json:"stream_validation,omitempty"`
+	// RepairConversations fixes malformed message lists instead of rejecting them.
+	RepairConversations bool `json:"repair_conversations,omitempty"`
+	// ContextOverflow retries requests that overflow the model's context
+	// window with less history or a lower max_tokens; see
+	// conversation.OverflowPolicy.
+	ContextOverflow *conversation.OverflowPolicy `json:"context_overflow,omitempty"`
+	// MaxTurns overrides the gateway-wide message limit when non-zero.
+	MaxTurns int `json:"max_turns,omitempty"`
+	// MaxStreamSeconds overrides MAX_STREAM_DURATION when non-zero.
+	MaxStreamSeconds int `json:"max_stream_seconds,omitempty"`
+	// Plan names the tenant's plan, whose routing policy the tenant's own
+	// routing settings override; see policy.RoutingResolver.
+	Plan string `json:"plan,omitempty"`
+	// RoutingMode "strict" rejects models no provider serves with 404 instead
+	// of routing to the cheapest provider.
+	RoutingMode string `json:"routing_mode,omitempty"`
+	// RoutingStrategy overrides the gateway's ROUTING_STRATEGY (cost,
+	// latency, weighted or priority) for this tenant.
+	RoutingStrategy string `json:"routing_strategy,omitempty"`
+	// AllowRouteOverrides lets requests pick a provider (X-LLM-Provider) or
+	// routing strategy (X-Route-Strategy) instead of automatic routing.
+	AllowRouteOverrides bool `json:"allow_route_overrides,omitempty"`
+	// OverrideProviders limits the providers X-LLM-Provider may name; empty
+	// allows any.
+	OverrideProviders []string `json:"override_providers,omitempty"`
+	// MaxCost caps each request's estimated cost. Requests may set a lower
+	// max_cost of their own, not a higher one.
+	MaxCost *provider.CostCap `json:"max_cost,omitempty"`
+	// MonthlyBudgetUSD caps spend per UTC calendar month (requests get 402
+	// once reached) and is what the usage forecast is checked against.
+	MonthlyBudgetUSD float64 `json:"monthly_budget_usd,omitempty"`
+	// DailyBudgetUSD caps spend per UTC day.
+	DailyBudgetUSD float64 `json:"daily_budget_usd,omitempty"`
+	// ModelWindows limits expensive models to time windows, with optional fallback.
+	ModelWindows []policy.ModelWindow `json:"model_windows,omitempty"`
+	// CacheMode is the response cache mode for requests that don't set one:
+	// read_write (the default), read_only or off.
+	CacheMode string `json:"cache_mode,omitempty"`
+	// CacheTTLSeconds overrides RESPONSE_CACHE_TTL when non-zero.
+	CacheTTLSeconds int `json:"cache_ttl_seconds,omitempty"`
+	// ManagedTools names the TOOL_HANDLERS tools the gateway executes for
+	// this tenant; calls to any other tool are returned to the client.
+	ManagedTools []string `json:"managed_tools,omitempty"`
+	// CoalesceRequests makes identical concurrent completions share one
+	// upstream call.
+	CoalesceRequests bool `json:"coalesce_requests,omitempty"`
+	// Shadow replays a sample of completions on another provider/model for
+	// offline comparison; see shadow.Config.
+	Shadow *shadow.Config `json:"shadow,omitempty"`
+	// AuditPayloads stores full prompts and completions, readable through
+	// /v1/requests/{request_id} until AUDIT_PAYLOAD_TTL passes.
+	AuditPayloads bool `json:"audit_payloads,omitempty"`
+	// AuditRedactions mask fields or patterns before payloads are stored.
+	AuditRedactions []audit.RedactionRule `json:"audit_redactions,omitempty"`
+	// ArchiveTranscripts sends every completed response, with a hash of its
+	// prompt, to the compliance archive sinks (ARCHIVE_SINKS).
+	ArchiveTranscripts bool `json:"archive_transcripts,omitempty"`
+}
+
+// Validate reports policies in s the gateway can't act on, for rejecting
+// them when settings are written.
+func (s *Settings) Validate() error {
+	if s.OutputLanguage != nil {
+		if err := s.OutputLanguage.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.StreamValidation != nil {
+		if err := s.StreamValidation.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.ContextOverflow != nil {
+		if err := s.ContextOverflow.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Routing is the tenant level of the routing policy hierarchy.
+func (s *Settings) Routing() policy.RoutingPolicy {
+	return policy.RoutingPolicy{
+		Level:    policy.LevelTenant,
+		Strategy: s.RoutingStrategy,
+		Mode:     s.RoutingMode,
+		MaxCost:  s.MaxCost,
+	}
+}
+
+type Store interface {
+	// Get returns the tenant's settings, or zero-valued settings if none are stored.
+	Get(ctx context.Context, tenantID string) (*Settings, error)
+	Put(ctx context.Context, tenantID string, settings *Settings) error
+}
+
+// CachedStore keeps settings in memory for ttl to keep lookups off the hot path.
+type CachedStore struct {
+	store Store
+	ttl   time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	settings  *Settings
+	expiresAt time.Time
+}
+
+func NewCachedStore(store Store, ttl time.Duration) *CachedStore {
+	return &CachedStore{store: store, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+func (c *CachedStore) Get(ctx context.Context, tenantID string) (*Settings, error) {
+	c.mu.RLock()
+	e, ok := c.entries[tenantID]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.settings, nil
+	}
+
+	s, err := c.store.Get(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[tenantID] = cacheEntry{settings: s, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return s, nil
+}
+
+func (c *CachedStore) Put(ctx context.Context, tenantID string, settings *Settings) error {
+	if err := c.store.Put(ctx, tenantID, settings); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.entries, tenantID)
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,104 @@
+package tiering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) Store {
+	return &PostgresStore{db: db}
+}
+
+const changeColumns = `id, tenant_id, from_plan, to_plan, rule, reason, status, created_at, decided_at`
+
+func (s *PostgresStore) Record(ctx context.Context, c *Change) (bool, error) {
+	query := `
+		INSERT INTO tier_changes (tenant_id, from_plan, to_plan, rule, reason, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id) WHERE status = 'pending' DO NOTHING
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRow(ctx, query, c.TenantID, c.FromPlan, c.ToPlan, c.Rule, c.Reason, c.Status).Scan(&c.ID, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save tier change: %w", err)
+	}
+	return true, nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id int64) (*Change, error) {
+	query := `SELECT ` + changeColumns + ` FROM tier_changes WHERE id = $1`
+	c, err := scanChange(s.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrChangeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tier change: %w", err)
+	}
+	return c, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, status string, limit int) ([]*Change, error) {
+	query := `
+		SELECT ` + changeColumns + `
+		FROM tier_changes
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($2, 0)
+	`
+	rows, err := s.db.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tier changes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Change
+	for rows.Next() {
+		c, err := scanChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tier change: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresStore) Decide(ctx context.Context, id int64, status string) (*Change, error) {
+	query := `
+		UPDATE tier_changes SET status = $2, decided_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING ` + changeColumns
+	c, err := scanChange(s.db.QueryRow(ctx, query, id, status))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrChangeDecided
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decide tier change: %w", err)
+	}
+	return c, nil
+}
+
+func scanChange(row pgx.Row) (*Change, error) {
+	var c Change
+	if err := row.Scan(&c.ID, &c.TenantID, &c.FromPlan, &c.ToPlan, &c.Rule, &c.Reason, &c.Status, &c.CreatedAt, &c.DecidedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
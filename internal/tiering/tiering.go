@@ -0,0 +1,323 @@
+// Package tiering moves tenants between plans when their usage stays above
+// or below a plan's thresholds, or proposes the move for an operator to
+// confirm.
+package tiering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrChangeNotFound means no tier change has the given ID.
+	ErrChangeNotFound = errors.New("tier change not found")
+	// ErrChangeDecided means the change was already approved, rejected or
+	// applied.
+	ErrChangeDecided = errors.New("tier change is not pending")
+	// ErrPlanChanged means the tenant left the change's FromPlan since it was
+	// proposed.
+	ErrPlanChanged = errors.New("tenant is no longer on the proposed change's plan")
+)
+
+// Usage metrics a rule measures, per UTC day.
+const (
+	MetricSpendUSD = "spend_usd"
+	MetricRequests = "requests"
+	MetricTokens   = "tokens" // input and output
+)
+
+// What a matching rule does.
+const (
+	ActionMove    = "move"    // change the tenant's plan at once
+	ActionConfirm = "confirm" // propose the change; an operator approves it
+)
+
+// Tier change statuses.
+const (
+	StatusApplied  = "applied" // made by a move rule
+	StatusPending  = "pending" // proposed by a confirm rule
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Rule moves tenants on plan From to plan To once the metric compared with
+// Threshold by Op (>=, >, <= or <) held on each of the last Days full UTC
+// days. Days without usage count as zero.
+type Rule struct {
+	From      string
+	To        string
+	Metric    string
+	Op        string
+	Threshold float64
+	Days      int
+	Action    string
+}
+
+// Name identifies the rule in tier changes, e.g. "free>pro".
+func (r Rule) Name() string {
+	return r.From + ">" + r.To
+}
+
+// Matches reports whether usage, keyed by UTC day, met the rule on each of
+// the Days days before end, a UTC midnight.
+func (r Rule) Matches(usage map[time.Time]billing.TenantDayUsage, end time.Time) bool {
+	for i := 1; i <= r.Days; i++ {
+		if !r.holds(r.value(usage[end.AddDate(0, 0, -i)])) {
+			return false
+		}
+	}
+	return r.Days > 0
+}
+
+func (r Rule) value(u billing.TenantDayUsage) float64 {
+	switch r.Metric {
+	case MetricRequests:
+		return float64(u.Requests)
+	case MetricTokens:
+		return float64(u.Tokens)
+	}
+	return u.CostUSD
+}
+
+func (r Rule) holds(v float64) bool {
+	switch r.Op {
+	case ">":
+		return v > r.Threshold
+	case "<=":
+		return v <= r.Threshold
+	case "<":
+		return v < r.Threshold
+	}
+	return v >= r.Threshold
+}
+
+// reason describes why the rule matched, e.g. "spend_usd >= 20 on each of
+// the last 7 days".
+func (r Rule) reason() string {
+	return fmt.Sprintf("%s %s %g on each of the last %d days", r.Metric, r.Op, r.Threshold, r.Days)
+}
+
+// Change is a plan change the engine made or proposed.
+type Change struct {
+	ID        int64      `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	FromPlan  string     `json:"from_plan"`
+	ToPlan    string     `json:"to_plan"`
+	Rule      string     `json:"rule"`
+	Reason    string     `json:"reason"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// Store keeps tier changes.
+type Store interface {
+	// Record saves c, setting its ID and CreatedAt. It returns false and
+	// saves nothing for a pending change when the tenant already has one.
+	Record(ctx context.Context, c *Change) (bool, error)
+	Get(ctx context.Context, id int64) (*Change, error)
+	// List returns up to limit changes with status, newest first; an empty
+	// status lists every change.
+	List(ctx context.Context, status string, limit int) ([]*Change, error)
+	// Decide moves a pending change to status, returning ErrChangeDecided
+	// when it isn't pending any more.
+	Decide(ctx context.Context, id int64, status string) (*Change, error)
+}
+
+// UsageSource totals tenants' usage per day.
+type UsageSource interface {
+	GetDailyUsageByTenants(ctx context.Context, from, to time.Time) ([]billing.TenantDayUsage, error)
+}
+
+// PlanLister lists the tenants that have a plan.
+type PlanLister interface {
+	ListPlans(ctx context.Context) (map[string]string, error)
+}
+
+// Engine evaluates tiering rules against tenants' daily usage.
+type Engine struct {
+	rules    []Rule
+	usage    UsageSource
+	plans    PlanLister
+	tenants  tenant.Store
+	changes  Store
+	interval time.Duration
+	recorded metric.Int64Counter
+}
+
+// NewEngine checks rules, in order, every interval; the first that matches
+// a tenant's plan and usage applies.
+func NewEngine(rules []Rule, usage UsageSource, plans PlanLister, tenants tenant.Store, changes Store, interval time.Duration) *Engine {
+	e := &Engine{rules: rules, usage: usage, plans: plans, tenants: tenants, changes: changes, interval: interval}
+	var err error
+	e.recorded, err = otel.Meter("github.com/vnmchuo/llm-gateway/internal/tiering").Int64Counter("gateway.tier_changes",
+		metric.WithDescription("Tenant plan changes made or proposed by the tiering engine"))
+	if err != nil {
+		log.Printf("tiering: failed to create tier change counter: %v", err)
+	}
+	return e
+}
+
+// Evaluate checks every tenant with a plan against the rules over the full
+// UTC days before now, skipping tenants with a pending proposal. It returns
+// the changes it made or proposed.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) ([]*Change, error) {
+	if len(e.rules) == 0 {
+		return nil, nil
+	}
+	end := now.UTC().Truncate(24 * time.Hour)
+	days := 0
+	for _, r := range e.rules {
+		days = max(days, r.Days)
+	}
+	rows, err := e.usage.GetDailyUsageByTenants(ctx, end.AddDate(0, 0, -days), end)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]map[time.Time]billing.TenantDayUsage)
+	for _, u := range rows {
+		if usage[u.TenantID] == nil {
+			usage[u.TenantID] = make(map[time.Time]billing.TenantDayUsage)
+		}
+		usage[u.TenantID][u.Day] = u
+	}
+	plans, err := e.plans.ListPlans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := e.changes.List(ctx, StatusPending, 0)
+	if err != nil {
+		return nil, err
+	}
+	proposed := make(map[string]bool, len(pending))
+	for _, c := range pending {
+		proposed[c.TenantID] = true
+	}
+
+	var out []*Change
+	for tenantID, plan := range plans {
+		if proposed[tenantID] {
+			continue
+		}
+		for _, r := range e.rules {
+			if r.From != plan || !r.Matches(usage[tenantID], end) {
+				continue
+			}
+			c, err := e.change(ctx, tenantID, r)
+			if err != nil {
+				log.Printf("tiering: rule %s for tenant %s: %v", r.Name(), tenantID, err)
+			} else if c != nil {
+				out = append(out, c)
+			}
+			break
+		}
+	}
+	return out, nil
+}
+
+// change applies or proposes r for tenantID; it returns nil when another
+// replica proposed a change first.
+func (e *Engine) change(ctx context.Context, tenantID string, r Rule) (*Change, error) {
+	c := &Change{TenantID: tenantID, FromPlan: r.From, ToPlan: r.To, Rule: r.Name(), Reason: r.reason(), Status: StatusPending}
+	if r.Action == ActionMove {
+		if err := e.setPlan(ctx, tenantID, r.From, r.To); err != nil {
+			return nil, err
+		}
+		c.Status = StatusApplied
+	}
+	ok, err := e.changes.Record(ctx, c)
+	if err != nil || !ok {
+		return nil, err
+	}
+	e.emit(ctx, c)
+	return c, nil
+}
+
+// setPlan moves tenantID from plan from to plan to.
+func (e *Engine) setPlan(ctx context.Context, tenantID, from, to string) error {
+	settings, err := e.tenants.Get(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if settings.Plan != from {
+		return ErrPlanChanged
+	}
+	updated := *settings
+	updated.Plan = to
+	return e.tenants.Put(ctx, tenantID, &updated)
+}
+
+// emit logs c and counts it by rule and status.
+func (e *Engine) emit(ctx context.Context, c *Change) {
+	switch c.Status {
+	case StatusApplied:
+		log.Printf("tiering: moved tenant %s from plan %s to %s (rule %s: %s)", c.TenantID, c.FromPlan, c.ToPlan, c.Rule, c.Reason)
+	case StatusPending:
+		log.Printf("tiering: proposed moving tenant %s from plan %s to %s (change %d, rule %s: %s)", c.TenantID, c.FromPlan, c.ToPlan, c.ID, c.Rule, c.Reason)
+	default:
+		log.Printf("tiering: change %d for tenant %s %s", c.ID, c.TenantID, c.Status)
+	}
+	if e.recorded != nil {
+		e.recorded.Add(ctx, 1, metric.WithAttributes(attribute.String("rule", c.Rule), attribute.String("status", c.Status)))
+	}
+}
+
+// List returns up to limit changes with status, newest first.
+func (e *Engine) List(ctx context.Context, status string, limit int) ([]*Change, error) {
+	return e.changes.List(ctx, status, limit)
+}
+
+// Approve applies the pending change id. It fails with ErrPlanChanged,
+// leaving the change pending, if the tenant's plan moved on meanwhile.
+func (e *Engine) Approve(ctx context.Context, id int64) (*Change, error) {
+	c, err := e.changes.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != StatusPending {
+		return nil, ErrChangeDecided
+	}
+	if err := e.setPlan(ctx, c.TenantID, c.FromPlan, c.ToPlan); err != nil {
+		return nil, err
+	}
+	if c, err = e.changes.Decide(ctx, id, StatusApproved); err != nil {
+		return nil, err
+	}
+	e.emit(ctx, c)
+	return c, nil
+}
+
+// Reject closes the pending change id without changing the tenant's plan.
+func (e *Engine) Reject(ctx context.Context, id int64) (*Change, error) {
+	c, err := e.changes.Decide(ctx, id, StatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	e.emit(ctx, c)
+	return c, nil
+}
+
+// Run evaluates the rules every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := e.Evaluate(ctx, time.Now()); err != nil {
+				log.Printf("tiering: failed to evaluate rules: %v", err)
+			}
+		}
+	}
+}
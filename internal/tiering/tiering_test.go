@@ -0,0 +1,193 @@
+package tiering
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/billing"
+	"github.com/vnmchuo/llm-gateway/internal/tenant"
+)
+
+type memStore struct {
+	changes []*Change
+}
+
+func (m *memStore) Record(ctx context.Context, c *Change) (bool, error) {
+	for _, existing := range m.changes {
+		if c.Status == StatusPending && existing.Status == StatusPending && existing.TenantID == c.TenantID {
+			return false, nil
+		}
+	}
+	c.ID = int64(len(m.changes) + 1)
+	m.changes = append(m.changes, c)
+	return true, nil
+}
+
+func (m *memStore) Get(ctx context.Context, id int64) (*Change, error) {
+	for _, c := range m.changes {
+		if c.ID == id {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, ErrChangeNotFound
+}
+
+func (m *memStore) List(ctx context.Context, status string, limit int) ([]*Change, error) {
+	var out []*Change
+	for _, c := range m.changes {
+		if status == "" || c.Status == status {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) Decide(ctx context.Context, id int64, status string) (*Change, error) {
+	for _, c := range m.changes {
+		if c.ID == id {
+			if c.Status != StatusPending {
+				return nil, ErrChangeDecided
+			}
+			c.Status = status
+			return c, nil
+		}
+	}
+	return nil, ErrChangeNotFound
+}
+
+type memTenants map[string]*tenant.Settings
+
+func (m memTenants) Get(ctx context.Context, tenantID string) (*tenant.Settings, error) {
+	if s, ok := m[tenantID]; ok {
+		return s, nil
+	}
+	return &tenant.Settings{}, nil
+}
+
+func (m memTenants) Put(ctx context.Context, tenantID string, s *tenant.Settings) error {
+	m[tenantID] = s
+	return nil
+}
+
+func (m memTenants) ListPlans(ctx context.Context) (map[string]string, error) {
+	plans := make(map[string]string)
+	for id, s := range m {
+		if s.Plan != "" {
+			plans[id] = s.Plan
+		}
+	}
+	return plans, nil
+}
+
+type fixedUsage []billing.TenantDayUsage
+
+func (f fixedUsage) GetDailyUsageByTenants(ctx context.Context, from, to time.Time) ([]billing.TenantDayUsage, error) {
+	return f, nil
+}
+
+var today = time.Date(2025, 6, 10, 15, 0, 0, 0, time.UTC)
+
+// daily returns usage on each of the n days before today.
+func daily(tenantID string, n int, costUSD float64) []billing.TenantDayUsage {
+	var out []billing.TenantDayUsage
+	for i := 1; i <= n; i++ {
+		day := today.Truncate(24*time.Hour).AddDate(0, 0, -i)
+		out = append(out, billing.TenantDayUsage{TenantID: tenantID, Day: day, Requests: 100, CostUSD: costUSD})
+	}
+	return out
+}
+
+func TestRule_MatchesSustainedUsageOnly(t *testing.T) {
+	end := today.Truncate(24 * time.Hour)
+	usage := make(map[time.Time]billing.TenantDayUsage)
+	for _, u := range daily("t", 3, 25) {
+		usage[u.Day] = u
+	}
+	upgrade := Rule{From: "free", To: "pro", Metric: MetricSpendUSD, Op: ">=", Threshold: 20, Days: 3}
+	if !upgrade.Matches(usage, end) {
+		t.Error("Expected three days over the threshold to match")
+	}
+	upgrade.Days = 4
+	if upgrade.Matches(usage, end) {
+		t.Error("Expected a day without usage to break the streak")
+	}
+	idle := Rule{From: "pro", To: "free", Metric: MetricRequests, Op: "<", Threshold: 10, Days: 5}
+	if !idle.Matches(nil, end) {
+		t.Error("Expected days without usage to count as zero")
+	}
+}
+
+func TestEngine_MovesOrProposes(t *testing.T) {
+	tenants := memTenants{
+		"busy": {Plan: "free"},
+		"idle": {Plan: "pro", OutputFormat: "plain_text"},
+		"calm": {Plan: "free"},
+	}
+	var usage fixedUsage
+	usage = append(usage, daily("busy", 7, 30)...)
+	usage = append(usage, daily("calm", 7, 1)...)
+	store := &memStore{}
+	rules := []Rule{
+		{From: "free", To: "pro", Metric: MetricSpendUSD, Op: ">=", Threshold: 20, Days: 7, Action: ActionConfirm},
+		{From: "pro", To: "free", Metric: MetricRequests, Op: "<", Threshold: 10, Days: 30, Action: ActionMove},
+	}
+	e := NewEngine(rules, usage, tenants, tenants, store, time.Hour)
+
+	changes, err := e.Evaluate(context.Background(), today)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+	if tenants["idle"].Plan != "free" || tenants["idle"].OutputFormat != "plain_text" {
+		t.Errorf("Expected the idle tenant moved to free with its settings kept, got %+v", tenants["idle"])
+	}
+	if tenants["busy"].Plan != "free" {
+		t.Errorf("Expected the upgrade to wait for confirmation, got plan %q", tenants["busy"].Plan)
+	}
+	pending, _ := store.List(context.Background(), StatusPending, 0)
+	if len(pending) != 1 || pending[0].TenantID != "busy" || pending[0].ToPlan != "pro" {
+		t.Fatalf("Expected one pending upgrade for busy, got %+v", pending)
+	}
+
+	// A tenant with a pending proposal isn't proposed again.
+	if changes, _ := e.Evaluate(context.Background(), today); len(changes) != 0 {
+		t.Errorf("Expected no new changes, got %+v", changes)
+	}
+
+	if _, err := e.Approve(context.Background(), pending[0].ID); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if tenants["busy"].Plan != "pro" {
+		t.Errorf("Expected the approved upgrade to apply, got plan %q", tenants["busy"].Plan)
+	}
+	if _, err := e.Approve(context.Background(), pending[0].ID); !errors.Is(err, ErrChangeDecided) {
+		t.Errorf("Expected ErrChangeDecided approving twice, got %v", err)
+	}
+}
+
+func TestEngine_ApproveRefusesStaleProposal(t *testing.T) {
+	tenants := memTenants{"t": {Plan: "free"}}
+	store := &memStore{}
+	rules := []Rule{{From: "free", To: "pro", Metric: MetricSpendUSD, Op: ">=", Threshold: 20, Days: 2, Action: ActionConfirm}}
+	e := NewEngine(rules, fixedUsage(daily("t", 2, 50)), tenants, tenants, store, time.Hour)
+	changes, err := e.Evaluate(context.Background(), today)
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("Expected one proposal, got %+v, %v", changes, err)
+	}
+
+	tenants["t"] = &tenant.Settings{Plan: "enterprise"}
+	if _, err := e.Approve(context.Background(), changes[0].ID); !errors.Is(err, ErrPlanChanged) {
+		t.Fatalf("Expected ErrPlanChanged, got %v", err)
+	}
+	if tenants["t"].Plan != "enterprise" {
+		t.Errorf("Expected the plan untouched, got %q", tenants["t"].Plan)
+	}
+	if _, err := e.Reject(context.Background(), changes[0].ID); err != nil {
+		t.Errorf("Reject failed: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+const (
+	SignatureHeader = "X-Gateway-Signature"
+	TimestampHeader = "X-Gateway-Timestamp"
+)
+
+// HTTPHandler executes tool calls by POSTing them to a tenant-operated
+// callback. Requests are signed with HMAC-SHA256 over "<timestamp>.<body>".
+type HTTPHandler struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewHTTPHandler(url, secret string) *HTTPHandler {
+	return &HTTPHandler{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type callbackRequest struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+	TenantID   string `json:"tenant_id"`
+	RequestID  string `json:"request_id"`
+}
+
+type callbackResponse struct {
+	Content string `json:"content"`
+}
+
+func (h *HTTPHandler) Execute(ctx context.Context, req *provider.Request, call provider.ToolCall) (string, error) {
+	body, err := json.Marshal(callbackRequest{
+		ToolCallID: call.ID,
+		Name:       call.Function.Name,
+		Arguments:  call.Function.Arguments,
+		TenantID:   req.TenantID,
+		RequestID:  req.RequestID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", h.url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(TimestampHeader, ts)
+	httpReq.Header.Set(SignatureHeader, Sign(h.secret, ts, body))
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("tool %s callback error (status %d): %s", call.Function.Name, resp.StatusCode, string(respBody))
+	}
+
+	var out callbackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("tool %s callback returned invalid body: %w", call.Function.Name, err)
+	}
+	return out.Content, nil
+}
+
+// Sign returns the signature header value callbacks should verify.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
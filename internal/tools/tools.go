@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+// Handler executes a single tool call and returns the content fed back to the model.
+type Handler interface {
+	Execute(ctx context.Context, req *provider.Request, call provider.ToolCall) (string, error)
+}
+
+// Registry maps tool (function) names to the handlers that execute them.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+func (r *Registry) Register(name string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = h
+}
+
+func (r *Registry) Lookup(name string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[name]
+	return h, ok
+}
+
+// CompleteFunc performs one model round trip.
+type CompleteFunc func(ctx context.Context, req *provider.Request) (*provider.Response, error)
+
+// Runner drives a bounded agent loop: while the model asks for tools that are
+// all registered and allowed for the tenant, execute them and send the
+// results back.
+type Runner struct {
+	registry      *Registry
+	maxIterations int
+}
+
+func NewRunner(registry *Registry, maxIterations int) *Runner {
+	if maxIterations <= 0 {
+		maxIterations = 5
+	}
+	return &Runner{registry: registry, maxIterations: maxIterations}
+}
+
+// Run calls complete until the model stops requesting managed tools or the
+// iteration budget is spent. Only the registered tools named in allowed, the
+// tenant's opt-in, are managed; other tool calls are returned to the client
+// untouched. Token counts are summed across all rounds so the caller bills
+// the whole loop. When a later round fails, Run returns the error with the
+// previous round's response, its counts summed over the rounds that
+// completed, so those can still be billed.
+func (r *Runner) Run(ctx context.Context, req *provider.Request, allowed []string, complete CompleteFunc) (*provider.Response, error) {
+	resp, err := complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	inputTokens, outputTokens := resp.InputTokens, resp.OutputTokens
+	for i := 0; i < r.maxIterations && r.managed(resp.ToolCalls, allowed); i++ {
+		next := *req
+		next.Messages = append(append([]provider.Message{}, req.Messages...), provider.Message{
+			Role:      "assistant",
+			Content:   resp.Content,
+			ToolCalls: resp.ToolCalls,
+		})
+
+		for _, call := range resp.ToolCalls {
+			h, _ := r.registry.Lookup(call.Function.Name)
+			result, err := h.Execute(ctx, req, call)
+			if err != nil {
+				// Let the model see the failure and decide how to proceed.
+				result = "error: " + err.Error()
+			}
+			next.Messages = append(next.Messages, provider.Message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
+
+		req = &next
+		prev := resp
+		resp, err = complete(ctx, req)
+		if err != nil {
+			prev.InputTokens, prev.OutputTokens = inputTokens, outputTokens
+			return prev, err
+		}
+		inputTokens += resp.InputTokens
+		outputTokens += resp.OutputTokens
+	}
+
+	resp.InputTokens, resp.OutputTokens = inputTokens, outputTokens
+	return resp, nil
+}
+
+func (r *Runner) managed(calls []provider.ToolCall, allowed []string) bool {
+	if len(calls) == 0 {
+		return false
+	}
+	for _, c := range calls {
+		if !slices.Contains(allowed, c.Function.Name) {
+			return false
+		}
+		if _, ok := r.registry.Lookup(c.Function.Name); !ok {
+			return false
+		}
+	}
+	return true
+}
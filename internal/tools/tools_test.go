@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vnmchuo/llm-gateway/internal/provider"
+)
+
+func TestRun_ExecutesRegisteredToolAndFeedsBackResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), Sign("secret", r.Header.Get(TimestampHeader), body); got != want {
+			t.Errorf("Expected signature %s, got %s", want, got)
+		}
+		var req callbackRequest
+		_ = json.Unmarshal(body, &req)
+		if req.Name != "get_weather" || req.Arguments != `{"city":"Jakarta"}` {
+			t.Errorf("Unexpected callback payload: %+v", req)
+		}
+		_ = json.NewEncoder(w).Encode(callbackResponse{Content: "sunny"})
+	}))
+	defer server.Close()
+
+	registry := NewRegistry()
+	registry.Register("get_weather", NewHTTPHandler(server.URL, "secret"))
+	runner := NewRunner(registry, 3)
+
+	calls := 0
+	complete := func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		calls++
+		if calls == 1 {
+			return &provider.Response{
+				InputTokens:  10,
+				OutputTokens: 5,
+				ToolCalls: []provider.ToolCall{{
+					ID:       "call_1",
+					Type:     "function",
+					Function: provider.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Jakarta"}`},
+				}},
+			}, nil
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role != "tool" || last.ToolCallID != "call_1" || last.Content != "sunny" {
+			t.Errorf("Expected tool result message, got %+v", last)
+		}
+		return &provider.Response{Content: "It is sunny", InputTokens: 20, OutputTokens: 4}, nil
+	}
+
+	resp, err := runner.Run(context.Background(), &provider.Request{
+		Messages: []provider.Message{{Role: "user", Content: "weather?"}},
+	}, []string{"get_weather"}, complete)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 model calls, got %d", calls)
+	}
+	if resp.Content != "It is sunny" {
+		t.Errorf("Expected final content, got %q", resp.Content)
+	}
+	if resp.InputTokens != 30 || resp.OutputTokens != 9 {
+		t.Errorf("Expected summed usage 30/9, got %d/%d", resp.InputTokens, resp.OutputTokens)
+	}
+}
+
+func TestRun_UnregisteredToolIsReturnedToClient(t *testing.T) {
+	runner := NewRunner(NewRegistry(), 3)
+
+	calls := 0
+	resp, err := runner.Run(context.Background(), &provider.Request{}, []string{"client_side"}, func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		calls++
+		return &provider.Response{ToolCalls: []provider.ToolCall{{ID: "c", Function: provider.ToolCallFunction{Name: "client_side"}}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 model call, got %d", calls)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Errorf("Expected tool call to be passed through, got %+v", resp.ToolCalls)
+	}
+}
+
+type loopingHandler struct{}
+
+func (loopingHandler) Execute(ctx context.Context, req *provider.Request, call provider.ToolCall) (string, error) {
+	return "again", nil
+}
+
+func TestRun_StopsAtMaxIterations(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("loop", loopingHandler{})
+	runner := NewRunner(registry, 2)
+
+	calls := 0
+	_, err := runner.Run(context.Background(), &provider.Request{}, []string{"loop"}, func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		calls++
+		return &provider.Response{ToolCalls: []provider.ToolCall{{ID: "c", Function: provider.ToolCallFunction{Name: "loop"}}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected initial call plus 2 iterations, got %d", calls)
+	}
+}
+
+func TestRun_ToolNotAllowedForTenantIsReturnedToClient(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("loop", loopingHandler{})
+	runner := NewRunner(registry, 3)
+
+	calls := 0
+	resp, err := runner.Run(context.Background(), &provider.Request{}, []string{"other"}, func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		calls++
+		return &provider.Response{ToolCalls: []provider.ToolCall{{ID: "c", Function: provider.ToolCallFunction{Name: "loop"}}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if calls != 1 || len(resp.ToolCalls) != 1 {
+		t.Errorf("Expected the registered but unallowed tool call passed through after 1 call, got %d calls, %+v", calls, resp.ToolCalls)
+	}
+}
+
+func TestRun_LaterRoundFailureKeepsEarlierUsage(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("loop", loopingHandler{})
+	runner := NewRunner(registry, 3)
+
+	calls := 0
+	resp, err := runner.Run(context.Background(), &provider.Request{}, []string{"loop"}, func(ctx context.Context, req *provider.Request) (*provider.Response, error) {
+		calls++
+		if calls == 3 {
+			return nil, errors.New("upstream down")
+		}
+		return &provider.Response{InputTokens: 10, OutputTokens: 2, ToolCalls: []provider.ToolCall{{ID: "c", Function: provider.ToolCallFunction{Name: "loop"}}}}, nil
+	})
+	if err == nil {
+		t.Fatal("Expected the failed round's error")
+	}
+	if resp == nil || resp.InputTokens != 20 || resp.OutputTokens != 4 {
+		t.Errorf("Expected the 2 completed rounds' usage 20/4, got %+v", resp)
+	}
+}
@@ -0,0 +1,113 @@
+// Package webhook sends signed webhooks to tenant-supplied URLs and guards
+// them, so tenants can't point the gateway at its own network: loopback,
+// private, link-local and metadata addresses are refused when a URL is
+// accepted and again when a delivery dials out, which catches names that
+// resolve somewhere else later.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenURL is returned for URLs deliveries may not go to.
+var ErrForbiddenURL = errors.New("webhook URL not allowed")
+
+// Guard decides where webhooks may be delivered. Hosts on the operator's
+// allowlist skip the checks, for internal receivers; a nil Guard has an
+// empty allowlist.
+type Guard struct {
+	allowed map[string]bool
+}
+
+// NewGuard returns a Guard exempting allowedHosts (host names or IPs,
+// without ports).
+func NewGuard(allowedHosts []string) *Guard {
+	g := &Guard{allowed: map[string]bool{}}
+	for _, h := range allowedHosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			g.allowed[h] = true
+		}
+	}
+	return g
+}
+
+func (g *Guard) allows(host string) bool {
+	return g != nil && g.allowed[strings.ToLower(host)]
+}
+
+// CheckURL reports whether raw may receive deliveries: it must be https and
+// not name a loopback, private or link-local host. Host names are checked
+// again when a delivery dials them.
+func (g *Guard) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute URL", ErrForbiddenURL, raw)
+	}
+	host := u.Hostname()
+	if g.allows(host) {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: %q must use https", ErrForbiddenURL, raw)
+	}
+	if h := strings.ToLower(strings.TrimSuffix(host, ".")); h == "localhost" || strings.HasSuffix(h, ".localhost") {
+		return fmt.Errorf("%w: %q is a loopback host", ErrForbiddenURL, raw)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && forbidden(ip) {
+		return fmt.Errorf("%w: %q is a private address", ErrForbiddenURL, raw)
+	}
+	return nil
+}
+
+// forbidden reports whether ip is somewhere deliveries may not go.
+func forbidden(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is carrier-grade NAT space (RFC 6598), private in
+// practice though IsPrivate leaves it out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Client returns an HTTP client with the given timeout whose connections to
+// hosts off the allowlist refuse forbidden addresses at dial time. It
+// doesn't follow redirects or use proxies, which would sidestep the check.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	open := &net.Dialer{Timeout: timeout}
+	guarded := &net.Dialer{Timeout: timeout, Control: func(network, address string, _ syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenURL, address)
+		}
+		if forbidden(ap.Addr()) {
+			return fmt.Errorf("%w: %s is a private address", ErrForbiddenURL, ap.Addr())
+		}
+		return nil
+	}}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && g.allows(host) {
+			return open.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGuard_CheckURL(t *testing.T) {
+	g := NewGuard([]string{"hooks.internal"})
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://example.com/hook", false},
+		{"https://127.0.0.1/hook", false},
+		{"https://localhost:8080/hook", false},
+		{"https://10.0.0.5/hook", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://[::1]/hook", false},
+		{"https://[::ffff:192.168.1.1]/hook", false},
+		{"https://100.64.0.1/hook", false},
+		{"/relative", false},
+		{"http://hooks.internal:9000/hook", true},
+	}
+	for _, tt := range tests {
+		err := g.CheckURL(tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%q) = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrForbiddenURL) {
+			t.Errorf("CheckURL(%q) = %v, want ErrForbiddenURL", tt.url, err)
+		}
+	}
+}
+
+func TestGuard_ClientRefusesPrivateAddressesAtDialTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if _, err := (*Guard)(nil).Client(time.Second).Get(srv.URL); !errors.Is(err, ErrForbiddenURL) {
+		t.Errorf("Expected ErrForbiddenURL dialing loopback, got %v", err)
+	}
+
+	resp, err := NewGuard([]string{"127.0.0.1"}).Client(time.Second).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected an allowlisted host to be reachable, got %v", err)
+	}
+	resp.Body.Close()
+}
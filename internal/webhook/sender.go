@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sender posts signed JSON webhooks in the background. When a secret is
+// given, requests carry X-Gateway-Timestamp and X-Gateway-Signature:
+// sha256=HMAC(secret, "<timestamp>.<body>"). Failed deliveries are retried
+// with doubling backoff; Flush waits for those in flight, so shutdown
+// doesn't drop them.
+type Sender struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewSender returns a Sender whose deliveries dial through guard.
+func NewSender(guard *Guard) *Sender {
+	return &Sender{
+		client:  guard.Client(10 * time.Second),
+		retries: 3,
+		backoff: time.Second,
+	}
+}
+
+// Send posts body to url in the background, signed with secret unless it
+// is empty. what names the delivery in logs, e.g. "callback for job 42".
+func (s *Sender) Send(url, secret string, body []byte, what string) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		backoff := s.backoff
+		for attempt := 1; attempt <= s.retries; attempt++ {
+			err := s.post(url, secret, body)
+			if err == nil {
+				return
+			}
+			slog.Warn("webhook: delivery failed", "delivery", what, "attempt", attempt, "attempts", s.retries, "err", err)
+			if attempt < s.retries {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// Flush waits for deliveries in flight, retries included, giving up when
+// ctx is done.
+func (s *Sender) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sender) post(url, secret string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		req.Header.Set("X-Gateway-Timestamp", ts)
+		req.Header.Set("X-Gateway-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSender_FlushWaitsForRetries(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSender(NewGuard([]string{"127.0.0.1"}))
+	s.backoff = 50 * time.Millisecond
+	s.Send(srv.URL, "", []byte(`{}`), "test delivery")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("Expected Flush to wait for the third attempt, got %d attempts", n)
+	}
+}
+
+func TestSender_FlushGivesUpWithContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := NewSender(NewGuard([]string{"127.0.0.1"}))
+	s.Send(srv.URL, "", []byte(`{}`), "test delivery")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Flush(ctx); err == nil {
+		t.Error("Expected Flush to give up while a retry is waiting")
+	}
+}
@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrJobNotRetryable is returned when retrying a job that hasn't failed.
+var ErrJobNotRetryable = errors.New("only failed jobs can be retried")
+
+// DeadLetter is a job that failed or exhausted its deliveries.
+type DeadLetter struct {
+	ID         string    `json:"id"` // dead-letter stream entry ID
+	JobID      string    `json:"job_id"`
+	Reason     string    `json:"reason"`
+	Deliveries int64     `json:"deliveries"`
+	DeadAt     time.Time `json:"dead_at"`
+}
+
+// DeadLetters lets operators inspect and requeue permanently failed jobs.
+type DeadLetters interface {
+	// List returns up to count entries, oldest first, after the entry ID
+	// after ("" starts from the beginning).
+	List(ctx context.Context, after string, count int64) ([]DeadLetter, error)
+	// Depth is the number of dead-lettered jobs.
+	Depth(ctx context.Context) (int64, error)
+	// Retry puts a failed job back on the queue as pending and drops its
+	// dead-letter entry.
+	Retry(ctx context.Context, jobID string) error
+}
+
+// registerMetrics exports the dead-letter depth, read from Redis on each
+// collection, and a counter of jobs dead-lettered by this process.
+func (p *WorkerPool) registerMetrics() {
+	meter := otel.Meter("github.com/vnmchuo/llm-gateway/internal/worker")
+
+	var err error
+	p.deadLettered, err = meter.Int64Counter("jobs.dead_lettered",
+		metric.WithDescription("Async jobs moved to the dead-letter stream"))
+	if err != nil {
+		log.Printf("worker: failed to create dead-letter counter: %v", err)
+	}
+
+	_, err = meter.Int64ObservableGauge("jobs.dead_letter.depth",
+		metric.WithDescription("Async jobs waiting in the dead-letter stream"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			depth, err := p.Depth(ctx)
+			if err != nil {
+				return err
+			}
+			o.Observe(depth)
+			return nil
+		}))
+	if err != nil {
+		log.Printf("worker: failed to create dead-letter depth gauge: %v", err)
+	}
+}
+
+// deadLetter records a failed job. The index maps job IDs to entries so
+// Retry can drop the entry without scanning the stream.
+func (p *WorkerPool) deadLetter(ctx context.Context, jobID, reason string, deliveries int64) {
+	dlCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	entryID, err := p.rdb.XAdd(dlCtx, &redis.XAddArgs{
+		Stream: deadLetterKey,
+		MaxLen: deadLetterMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"job_id":     jobID,
+			"reason":     reason,
+			"deliveries": deliveries,
+		},
+	}).Result()
+	if err != nil {
+		log.Printf("worker: failed to dead-letter job %s: %v", jobID, err)
+		return
+	}
+	if err := p.rdb.HSet(dlCtx, deadLetterIndexKey, jobID, entryID).Err(); err != nil {
+		log.Printf("worker: failed to index dead-lettered job %s: %v", jobID, err)
+	}
+	if p.deadLettered != nil {
+		p.deadLettered.Add(dlCtx, 1)
+	}
+}
+
+// List implements DeadLetters.
+func (p *WorkerPool) List(ctx context.Context, after string, count int64) ([]DeadLetter, error) {
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+	msgs, err := p.rdb.XRangeN(ctx, deadLetterKey, start, "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	letters := make([]DeadLetter, len(msgs))
+	for i, msg := range msgs {
+		letters[i] = toDeadLetter(msg)
+	}
+	return letters, nil
+}
+
+// Depth implements DeadLetters.
+func (p *WorkerPool) Depth(ctx context.Context) (int64, error) {
+	n, err := p.rdb.XLen(ctx, deadLetterKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read dead-letter depth: %w", err)
+	}
+	return n, nil
+}
+
+// Retry implements DeadLetters. Any failed job can be retried, dead-lettered
+// or not; it restarts with a fresh delivery count.
+func (p *WorkerPool) Retry(ctx context.Context, jobID string) error {
+	job, err := p.store.GetByID(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != JobStatusFailed {
+		return ErrJobNotRetryable
+	}
+	if err := p.store.UpdateStatus(ctx, jobID, JobStatusPending, nil, ""); err != nil {
+		return err
+	}
+
+	entryID, err := p.rdb.HGet(ctx, deadLetterIndexKey, jobID).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to read dead-letter index: %w", err)
+	}
+	pipe := p.rdb.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		Values: map[string]interface{}{"job_id": jobID},
+	})
+	if entryID != "" {
+		pipe.XDel(ctx, deadLetterKey, entryID)
+		pipe.HDel(ctx, deadLetterIndexKey, jobID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	return nil
+}
+
+func toDeadLetter(msg redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: msg.ID}
+	letter.JobID, _ = msg.Values["job_id"].(string)
+	letter.Reason, _ = msg.Values["reason"].(string)
+	if v, ok := msg.Values["deliveries"].(string); ok {
+		letter.Deliveries, _ = strconv.ParseInt(v, 10, 64)
+	}
+	// Stream IDs start with the entry's Unix time in milliseconds.
+	if ms, err := strconv.ParseInt(strings.SplitN(msg.ID, "-", 2)[0], 10, 64); err == nil {
+		letter.DeadAt = time.UnixMilli(ms).UTC()
+	}
+	return letter
+}
@@ -2,22 +2,321 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	streamKey     = "jobs:stream"
+	deadLetterKey = "jobs:dead"
+	// deadLetterIndexKey maps job IDs to their dead-letter entry IDs.
+	deadLetterIndexKey = "jobs:dead:index"
+	consumerGroup      = "workers"
+
+	// deadLetterMaxLen roughly caps the dead-letter stream so a failure storm
+	// can't grow it without bound.
+	deadLetterMaxLen = 10000
+
+	// writeTimeout bounds status writes and acks made after a job finishes.
+	writeTimeout = 5 * time.Second
 )
 
+// WorkerPool is a Queue backed by a Redis Stream consumer group. Jobs are
+// persisted in Store before being published so their status survives restarts.
+//
+// A job is acked only after its outcome is saved. Jobs left pending longer
+// than the visibility timeout, because their worker crashed or stalled, are
+// reclaimed and run again; after maxDeliveries attempts they are moved to a
+// dead-letter stream, as are jobs whose completion failed.
 type WorkerPool struct {
-	// TODO: add internal queue and worker configuration
+	rdb           *redis.Client
+	store         Store
+	exec          Executor
+	notifier      *Notifier
+	concurrency   int
+	consumer      string
+	visibility    time.Duration
+	maxDeliveries int64
+	deadLettered  metric.Int64Counter
+	blobs         BlobStore
+	offloadBytes  int
+}
+
+type PoolOption func(*WorkerPool)
+
+// WithConcurrency sets how many jobs this process runs at once (default: 4).
+func WithConcurrency(n int) PoolOption {
+	return func(p *WorkerPool) {
+		if n > 0 {
+			p.concurrency = n
+		}
+	}
+}
+
+// WithVisibilityTimeout sets how long a job may stay unacked before another
+// worker reclaims it (default: 5m). It must exceed the longest job.
+func WithVisibilityTimeout(d time.Duration) PoolOption {
+	return func(p *WorkerPool) {
+		if d > 0 {
+			p.visibility = d
+		}
+	}
 }
 
-func NewWorkerPool() Queue {
-	return &WorkerPool{}
+// WithMaxDeliveries sets how many times a job is handed to a worker before
+// it is dead-lettered (default: 3).
+func WithMaxDeliveries(n int) PoolOption {
+	return func(p *WorkerPool) {
+		if n > 0 {
+			p.maxDeliveries = int64(n)
+		}
+	}
+}
+
+// WithNotifier enables completion webhooks to AsyncJob.CallbackURL.
+func WithNotifier(n *Notifier) PoolOption {
+	return func(p *WorkerPool) {
+		p.notifier = n
+	}
+}
+
+func NewWorkerPool(rdb *redis.Client, store Store, exec Executor, opts ...PoolOption) *WorkerPool {
+	host, _ := os.Hostname()
+	p := &WorkerPool{
+		rdb:           rdb,
+		store:         store,
+		exec:          exec,
+		concurrency:   4,
+		consumer:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		visibility:    5 * time.Minute,
+		maxDeliveries: 3,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.registerMetrics()
+	return p
 }
 
 func (p *WorkerPool) Enqueue(ctx context.Context, job *AsyncJob) error {
-	// TODO: implement
+	job.Status = JobStatusPending
+	if err := p.store.Create(ctx, job); err != nil {
+		return err
+	}
+
+	err := p.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		Values: map[string]interface{}{"job_id": job.ID},
+	}).Err()
+	if err != nil {
+		_ = p.store.UpdateStatus(ctx, job.ID, JobStatusFailed, nil, "failed to enqueue job")
+		return fmt.Errorf("failed to publish job: %w", err)
+	}
 	return nil
 }
 
+// Process consumes jobs until ctx is cancelled, then waits for in-flight jobs.
 func (p *WorkerPool) Process(ctx context.Context) error {
-	// TODO: implement worker loop
+	err := p.rdb.XGroupCreateMkStream(ctx, streamKey, consumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.loop(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.reclaimLoop(ctx)
+	}()
+	wg.Wait()
 	return nil
 }
+
+func (p *WorkerPool) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		streams, err := p.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    consumerGroup,
+			Consumer: p.consumer,
+			Streams:  []string{streamKey, ">"},
+			Count:    1,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("worker: read error: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				p.handle(ctx, msg)
+			}
+		}
+	}
+}
+
+// reclaimLoop periodically takes over jobs whose worker stopped acking them.
+func (p *WorkerPool) reclaimLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.visibility / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := "0-0"
+		for ctx.Err() == nil {
+			msgs, next, err := p.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   streamKey,
+				Group:    consumerGroup,
+				Consumer: p.consumer,
+				MinIdle:  p.visibility,
+				Start:    start,
+				Count:    10,
+			}).Result()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("worker: reclaim error: %v", err)
+				}
+				break
+			}
+			for _, msg := range msgs {
+				p.handle(ctx, msg)
+			}
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// handle runs one stream message's job and acks it once the outcome, or
+// the dead-letter entry, is saved. Messages left unacked are delivered again
+// after the visibility timeout.
+func (p *WorkerPool) handle(ctx context.Context, msg redis.XMessage) {
+	jobID, _ := msg.Values["job_id"].(string)
+
+	job, err := p.store.GetByID(ctx, jobID)
+	if err != nil {
+		log.Printf("worker: failed to load job %s: %v", jobID, err)
+		if errors.Is(err, ErrJobNotFound) {
+			p.ack(ctx, msg.ID, jobID)
+		}
+		return
+	}
+	if job.Status == JobStatusDone || job.Status == JobStatusFailed {
+		p.ack(ctx, msg.ID, jobID)
+		return
+	}
+
+	deliveries := p.deliveries(ctx, msg.ID)
+	if deliveries > p.maxDeliveries {
+		job.Status = JobStatusFailed
+		job.Error = fmt.Sprintf("job was delivered %d times without completing", deliveries-1)
+		p.finish(ctx, job)
+		p.deadLetter(ctx, job.ID, job.Error, deliveries-1)
+		p.ack(ctx, msg.ID, jobID)
+		return
+	}
+
+	if !p.run(ctx, job) {
+		return
+	}
+	if job.Status == JobStatusFailed {
+		p.deadLetter(ctx, job.ID, job.Error, deliveries)
+	}
+	p.ack(ctx, msg.ID, jobID)
+}
+
+// deliveries reports how many times the message has been handed out,
+// including this delivery.
+func (p *WorkerPool) deliveries(ctx context.Context, msgID string) int64 {
+	pending, err := p.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: streamKey,
+		Group:  consumerGroup,
+		Start:  msgID,
+		End:    msgID,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return pending[0].RetryCount
+}
+
+func (p *WorkerPool) ack(ctx context.Context, msgID, jobID string) {
+	ackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if err := p.rdb.XAck(ackCtx, streamKey, consumerGroup, msgID).Err(); err != nil {
+		log.Printf("worker: failed to ack job %s: %v", jobID, err)
+	}
+}
+
+// run executes job and saves its outcome. It returns false when shutdown
+// interrupted the job, leaving it to be redelivered.
+func (p *WorkerPool) run(ctx context.Context, job *AsyncJob) bool {
+	_ = p.store.UpdateStatus(ctx, job.ID, JobStatusRunning, nil, "")
+	p.publish(ctx, JobEvent{Type: EventStatus, JobID: job.ID, Status: JobStatusRunning})
+
+	reporter := &progressReporter{pool: p, jobID: job.ID}
+	resp, err := p.exec(context.WithValue(ctx, progressKey{}, reporter), job)
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+	reporter.flush(ctx)
+	if err != nil {
+		job.Status, job.Error = JobStatusFailed, err.Error()
+	} else {
+		job.Status = JobStatusDone
+		job.Result = &JobResult{
+			Content:      resp.Content,
+			ToolCalls:    resp.ToolCalls,
+			Model:        resp.Model,
+			Provider:     resp.Provider,
+			InputTokens:  resp.InputTokens,
+			OutputTokens: resp.OutputTokens,
+		}
+	}
+
+	p.finish(ctx, job)
+	return true
+}
+
+// finish saves a finished job's outcome, announces it and fires its webhook.
+func (p *WorkerPool) finish(ctx context.Context, job *AsyncJob) {
+	p.offload(context.WithoutCancel(ctx), job)
+
+	// Persist the outcome even if shutdown cancelled ctx after the job
+	// finished, but don't let a slow database hold the worker forever.
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if err := p.store.UpdateStatus(saveCtx, job.ID, job.Status, job.Result, job.Error); err != nil {
+		log.Printf("worker: failed to save job %s: %v", job.ID, err)
+	}
+	p.publish(saveCtx, JobEvent{Type: EventStatus, JobID: job.ID, Status: job.Status, Error: job.Error})
+
+	if p.notifier != nil && job.CallbackURL != "" {
+		p.notifier.Notify(job)
+	}
+}
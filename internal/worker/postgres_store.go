@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type PostgresStore struct {
+	db        DB
+	retention time.Duration
+}
+
+type StoreOption func(*PostgresStore)
+
+// WithRetention deletes finished jobs this long after they complete
+// (default: kept forever).
+func WithRetention(d time.Duration) StoreOption {
+	return func(s *PostgresStore) {
+		s.retention = d
+	}
+}
+
+func NewPostgresStore(db DB, opts ...StoreOption) Store {
+	s := &PostgresStore{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *PostgresStore) Create(ctx context.Context, job *AsyncJob) error {
+	reqJSON, err := json.Marshal(job.Request)
+	if err != nil {
+		return fmt.Errorf("failed to encode job request: %w", err)
+	}
+
+	query := `
+		INSERT INTO async_jobs (id, tenant_id, request, callback_url, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at
+	`
+	err = s.db.QueryRow(ctx, query,
+		job.ID, job.TenantID, reqJSON, job.CallbackURL, job.Status,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// selectJob hides expired jobs the sweeper hasn't deleted yet.
+const selectJob = `
+	SELECT id, tenant_id, request, callback_url, status, result, error, progress, created_at, updated_at, completed_at
+	FROM async_jobs
+	WHERE (expires_at IS NULL OR expires_at > NOW())
+`
+
+func (s *PostgresStore) Get(ctx context.Context, tenantID, jobID string) (*AsyncJob, error) {
+	return s.scan(s.db.QueryRow(ctx, selectJob+`AND id = $1 AND tenant_id = $2`, jobID, tenantID))
+}
+
+func (s *PostgresStore) GetByID(ctx context.Context, jobID string) (*AsyncJob, error) {
+	return s.scan(s.db.QueryRow(ctx, selectJob+`AND id = $1`, jobID))
+}
+
+func (s *PostgresStore) scan(row pgx.Row) (*AsyncJob, error) {
+	var job AsyncJob
+	var reqJSON, resultJSON, progressJSON []byte
+	var errMsg *string
+	err := row.Scan(
+		&job.ID, &job.TenantID, &reqJSON, &job.CallbackURL, &job.Status,
+		&resultJSON, &errMsg, &progressJSON, &job.CreatedAt, &job.UpdatedAt, &job.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if err := json.Unmarshal(reqJSON, &job.Request); err != nil {
+		return nil, fmt.Errorf("failed to decode job request: %w", err)
+	}
+	if resultJSON != nil {
+		if err := json.Unmarshal(resultJSON, &job.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode job result: %w", err)
+		}
+	}
+	if progressJSON != nil {
+		if err := json.Unmarshal(progressJSON, &job.Progress); err != nil {
+			return nil, fmt.Errorf("failed to decode job progress: %w", err)
+		}
+	}
+	if errMsg != nil {
+		job.Error = *errMsg
+	}
+	return &job, nil
+}
+
+func (s *PostgresStore) UpdateStatus(ctx context.Context, jobID string, status JobStatus, result *JobResult, errMsg string) error {
+	var resultJSON []byte
+	if result != nil {
+		var err error
+		if resultJSON, err = json.Marshal(result); err != nil {
+			return fmt.Errorf("failed to encode job result: %w", err)
+		}
+	}
+
+	// A zero retention leaves expires_at NULL.
+	query := `
+		UPDATE async_jobs
+		SET status = $2, result = $3, error = NULLIF($4, ''), updated_at = NOW(),
+		    completed_at = CASE WHEN $2 IN ('done', 'failed') THEN NOW() ELSE NULL END,
+		    expires_at = CASE WHEN $2 IN ('done', 'failed') AND $5::float8 > 0 THEN NOW() + make_interval(secs => $5::float8) ELSE NULL END
+		WHERE id = $1
+	`
+	tag, err := s.db.Exec(ctx, query, jobID, status, resultJSON, errMsg, s.retention.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateProgress(ctx context.Context, jobID string, progress *JobProgress) error {
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to encode job progress: %w", err)
+	}
+	tag, err := s.db.Exec(ctx, `UPDATE async_jobs SET progress = $2, updated_at = NOW() WHERE id = $1`, jobID, progressJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteExpired(ctx context.Context, limit int) ([]string, int, error) {
+	query := `
+		DELETE FROM async_jobs
+		WHERE id IN (
+			SELECT id FROM async_jobs WHERE expires_at <= NOW() LIMIT $1
+		)
+		RETURNING COALESCE(result->>'object_key', '')
+	`
+	rows, err := s.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to delete expired jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	deleted := 0
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan expired job: %w", err)
+		}
+		deleted++
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to delete expired jobs: %w", err)
+	}
+	return keys, deleted, nil
+}
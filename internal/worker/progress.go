@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// jobEventsPrefix + job ID is the pub/sub channel carrying a job's events.
+	jobEventsPrefix = "jobs:events:"
+
+	// progressInterval throttles progress writes for a running job.
+	progressInterval = time.Second
+)
+
+// JobProgress reports how far a running job has got. Chunk counts come from
+// streamed completions; line counts from batch jobs.
+type JobProgress struct {
+	ChunksGenerated int     `json:"chunks_generated,omitempty"`
+	LinesCompleted  int     `json:"lines_completed,omitempty"`
+	LinesTotal      int     `json:"lines_total,omitempty"`
+	Percent         float64 `json:"percent,omitempty"`
+}
+
+// Event types.
+const (
+	EventStatus   = "status"
+	EventProgress = "progress"
+)
+
+// JobEvent is a status transition or progress update for one job.
+type JobEvent struct {
+	Type     string       `json:"type"`
+	JobID    string       `json:"job_id"`
+	Status   JobStatus    `json:"status"`
+	Progress *JobProgress `json:"progress,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	At       time.Time    `json:"at"`
+}
+
+// Events lets API processes follow jobs that workers elsewhere are running.
+type Events interface {
+	// Subscribe delivers the job's events until ctx is done. Events published
+	// before Subscribe returns are missed, so callers read the job after it.
+	Subscribe(ctx context.Context, jobID string) (<-chan JobEvent, error)
+}
+
+type progressKey struct{}
+
+// ReportProgress records progress for the job running under ctx. Executors
+// call it as output arrives; writes are throttled to one per second, and it
+// does nothing outside a worker.
+func ReportProgress(ctx context.Context, progress JobProgress) {
+	if r, ok := ctx.Value(progressKey{}).(*progressReporter); ok {
+		r.report(ctx, progress, false)
+	}
+}
+
+type progressReporter struct {
+	pool  *WorkerPool
+	jobID string
+
+	mu     sync.Mutex
+	last   time.Time
+	latest *JobProgress
+	saved  bool
+}
+
+func (r *progressReporter) report(ctx context.Context, progress JobProgress, force bool) {
+	if progress.LinesTotal > 0 {
+		progress.Percent = float64(progress.LinesCompleted) * 100 / float64(progress.LinesTotal)
+	}
+
+	r.mu.Lock()
+	r.latest, r.saved = &progress, false
+	if !force && time.Since(r.last) < progressInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.last, r.saved = time.Now(), true
+	r.mu.Unlock()
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if err := r.pool.store.UpdateProgress(saveCtx, r.jobID, &progress); err != nil {
+		log.Printf("worker: failed to save progress for job %s: %v", r.jobID, err)
+		return
+	}
+	r.pool.publish(saveCtx, JobEvent{Type: EventProgress, JobID: r.jobID, Status: JobStatusRunning, Progress: &progress})
+}
+
+// flush saves the latest progress if throttling held it back.
+func (r *progressReporter) flush(ctx context.Context) {
+	r.mu.Lock()
+	latest, saved := r.latest, r.saved
+	r.mu.Unlock()
+	if latest != nil && !saved {
+		r.report(ctx, *latest, true)
+	}
+}
+
+// publish announces a job event to subscribers. Delivery is best effort:
+// subscribers reconcile with the stored job when they connect.
+func (p *WorkerPool) publish(ctx context.Context, ev JobEvent) {
+	ev.At = time.Now().UTC()
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if err := p.rdb.Publish(ctx, jobEventsPrefix+ev.JobID, payload).Err(); err != nil {
+		log.Printf("worker: failed to publish event for job %s: %v", ev.JobID, err)
+	}
+}
+
+func (p *WorkerPool) Subscribe(ctx context.Context, jobID string) (<-chan JobEvent, error) {
+	sub := p.rdb.Subscribe(ctx, jobEventsPrefix+jobID)
+	// Wait for the subscription to be confirmed so no later event is missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("failed to subscribe to job events: %w", err)
+	}
+
+	events := make(chan JobEvent)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var ev JobEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+					continue
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// BlobStore holds job results too large to keep in Postgres.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a download URL for key that expires after ttl.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+func resultKey(jobID string) string {
+	return fmt.Sprintf("jobs/%s/result.json", jobID)
+}
+
+// WithResultOffload moves results larger than thresholdBytes (encoded as
+// JSON) to blobs. Postgres then keeps only the object key and token counts.
+func WithResultOffload(blobs BlobStore, thresholdBytes int) PoolOption {
+	return func(p *WorkerPool) {
+		p.blobs = blobs
+		p.offloadBytes = thresholdBytes
+	}
+}
+
+// offload uploads job's result when it is over the threshold. Upload errors
+// keep the result inline: a bloated row beats a lost result.
+func (p *WorkerPool) offload(ctx context.Context, job *AsyncJob) {
+	if p.blobs == nil || job.Result == nil {
+		return
+	}
+	data, err := json.Marshal(job.Result)
+	if err != nil || len(data) <= p.offloadBytes {
+		return
+	}
+
+	key := resultKey(job.ID)
+	if err := p.blobs.Put(ctx, key, data); err != nil {
+		log.Printf("worker: failed to offload result of job %s (%d bytes), storing inline: %v", job.ID, len(data), err)
+		return
+	}
+	job.Result = &JobResult{
+		ObjectKey:    key,
+		Model:        job.Result.Model,
+		Provider:     job.Result.Provider,
+		InputTokens:  job.Result.InputTokens,
+		OutputTokens: job.Result.OutputTokens,
+	}
+}
+
+// Sweeper deletes jobs, and their offloaded results, once past retention.
+type Sweeper struct {
+	store    Store
+	blobs    BlobStore
+	interval time.Duration
+}
+
+// NewSweeper sweeps every interval; blobs may be nil when results are
+// never offloaded.
+func NewSweeper(store Store, blobs BlobStore, interval time.Duration) *Sweeper {
+	return &Sweeper{store: store, blobs: blobs, interval: interval}
+}
+
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+const sweepBatch = 500
+
+func (s *Sweeper) sweep(ctx context.Context) {
+	for ctx.Err() == nil {
+		keys, deleted, err := s.store.DeleteExpired(ctx, sweepBatch)
+		if err != nil {
+			log.Printf("worker: failed to delete expired jobs: %v", err)
+			return
+		}
+		for _, key := range keys {
+			if s.blobs == nil {
+				break
+			}
+			if err := s.blobs.Delete(ctx, key); err != nil {
+				log.Printf("worker: failed to delete job result %s: %v", key, err)
+			}
+		}
+		if deleted < sweepBatch {
+			return
+		}
+	}
+}
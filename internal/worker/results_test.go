@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memBlobStore struct {
+	objects map[string][]byte
+}
+
+func (m *memBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	m.objects[key] = data
+	return nil
+}
+
+func (m *memBlobStore) Delete(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memBlobStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://blobs.example.com/" + key, nil
+}
+
+func TestOffload_LargeResultsOnly(t *testing.T) {
+	blobs := &memBlobStore{objects: map[string][]byte{}}
+	p := &WorkerPool{}
+	WithResultOffload(blobs, 1024)(p)
+
+	small := &AsyncJob{ID: "small", Result: &JobResult{Content: "short", Model: "gpt-4"}}
+	p.offload(context.Background(), small)
+	if small.Result.ObjectKey != "" || small.Result.Content != "short" {
+		t.Errorf("small result should stay inline, got %+v", small.Result)
+	}
+
+	large := &AsyncJob{ID: "large", Result: &JobResult{Content: strings.Repeat("x", 4096), Model: "gpt-4", OutputTokens: 1000}}
+	p.offload(context.Background(), large)
+	if large.Result.ObjectKey != "jobs/large/result.json" || large.Result.Content != "" || large.Result.OutputTokens != 1000 {
+		t.Errorf("large result should be offloaded, got %+v", large.Result)
+	}
+	if !strings.Contains(string(blobs.objects["jobs/large/result.json"]), strings.Repeat("x", 4096)) {
+		t.Error("expected the full result in the blob store")
+	}
+}
+
+type expiringStore struct {
+	Store
+	batches [][]string
+}
+
+func (s *expiringStore) DeleteExpired(ctx context.Context, limit int) ([]string, int, error) {
+	if len(s.batches) == 0 {
+		return nil, 0, nil
+	}
+	keys := s.batches[0]
+	s.batches = s.batches[1:]
+	return keys, len(keys), nil
+}
+
+func TestSweeper_DeletesOffloadedResults(t *testing.T) {
+	blobs := &memBlobStore{objects: map[string][]byte{
+		"jobs/a/result.json": nil,
+		"jobs/b/result.json": nil,
+	}}
+	store := &expiringStore{batches: [][]string{{"jobs/a/result.json"}}}
+
+	NewSweeper(store, blobs, time.Minute).sweep(context.Background())
+
+	if _, ok := blobs.objects["jobs/a/result.json"]; ok {
+		t.Error("expected the expired job's result to be deleted")
+	}
+	if _, ok := blobs.objects["jobs/b/result.json"]; !ok {
+		t.Error("expected unexpired results to be kept")
+	}
+}
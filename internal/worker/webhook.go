@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/vnmchuo/llm-gateway/internal/webhook"
+)
+
+// Notifier delivers job completion callbacks, signed with secret when one
+// is configured; see webhook.Sender.
+type Notifier struct {
+	secret string
+	sender *webhook.Sender
+}
+
+func NewNotifier(secret string, sender *webhook.Sender) *Notifier {
+	return &Notifier{secret: secret, sender: sender}
+}
+
+// callback is what a job's callback URL receives. It carries no prompt or
+// result; clients fetch those from GET /v1/jobs/{id} with their API key.
+type callback struct {
+	ID     string    `json:"id"`
+	Status JobStatus `json:"status"`
+}
+
+// Notify posts the job's ID and status to its callback URL in the
+// background, retrying with backoff.
+func (n *Notifier) Notify(job *AsyncJob) {
+	body, err := json.Marshal(callback{ID: job.ID, Status: job.Status})
+	if err != nil {
+		log.Printf("worker: failed to encode callback for job %s: %v", job.ID, err)
+		return
+	}
+	n.sender.Send(job.CallbackURL, n.secret, body, "callback for job "+job.ID)
+}
@@ -2,11 +2,14 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/vnmchuo/llm-gateway/internal/provider"
 )
 
+var ErrJobNotFound = errors.New("job not found")
+
 type JobStatus string
 
 const (
@@ -17,15 +20,49 @@ const (
 )
 
 type AsyncJob struct {
-	ID          string
-	TenantID    string
-	Request     *provider.Request
-	CallbackURL string
-	Status      JobStatus
-	CreatedAt   time.Time
+	ID          string            `json:"id"`
+	TenantID    string            `json:"tenant_id"`
+	Request     *provider.Request `json:"request"`
+	CallbackURL string            `json:"callback_url,omitempty"`
+	Status      JobStatus         `json:"status"`
+	Result      *JobResult        `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Progress    *JobProgress      `json:"progress,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+}
+
+// JobResult is the completion produced for a finished job. Large results
+// are offloaded to a BlobStore: Content and ToolCalls are then empty and
+// ObjectKey names the full result.
+type JobResult struct {
+	ObjectKey    string              `json:"object_key,omitempty"`
+	Content      string              `json:"content"`
+	ToolCalls    []provider.ToolCall `json:"tool_calls,omitempty"`
+	Model        string              `json:"model"`
+	Provider     string              `json:"provider"`
+	InputTokens  int                 `json:"input_tokens"`
+	OutputTokens int                 `json:"output_tokens"`
 }
 
 type Queue interface {
 	Enqueue(ctx context.Context, job *AsyncJob) error
 	Process(ctx context.Context) error // starts the worker loop
 }
+
+// Store persists job status and results.
+type Store interface {
+	Create(ctx context.Context, job *AsyncJob) error
+	Get(ctx context.Context, tenantID, jobID string) (*AsyncJob, error)
+	// GetByID looks a job up without tenant scoping, for workers.
+	GetByID(ctx context.Context, jobID string) (*AsyncJob, error)
+	UpdateStatus(ctx context.Context, jobID string, status JobStatus, result *JobResult, errMsg string) error
+	UpdateProgress(ctx context.Context, jobID string, progress *JobProgress) error
+	// DeleteExpired removes up to limit jobs past their retention, returning
+	// the object keys of their offloaded results and how many were deleted.
+	DeleteExpired(ctx context.Context, limit int) (objectKeys []string, deleted int, err error)
+}
+
+// Executor runs the completion for a job.
+type Executor func(ctx context.Context, job *AsyncJob) (*provider.Response, error)
@@ -0,0 +1,24 @@
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS rag_configs (
+    tenant_id        UUID PRIMARY KEY,
+    collection       TEXT NOT NULL,
+    top_k            INT NOT NULL DEFAULT 4,
+    template         TEXT NOT NULL DEFAULT '',
+    embedding_model  TEXT NOT NULL DEFAULT 'text-embedding-3-small',
+    enabled          BOOLEAN NOT NULL DEFAULT true,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS rag_documents (
+    id          TEXT NOT NULL,
+    tenant_id   UUID NOT NULL,
+    collection  TEXT NOT NULL,
+    content     TEXT NOT NULL,
+    embedding   vector(1536) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, collection, id)
+);
+CREATE INDEX idx_rag_documents_embedding ON rag_documents USING hnsw (embedding vector_cosine_ops);
+
+ALTER TABLE usage_logs ADD COLUMN IF NOT EXISTS retrieved_doc_ids TEXT[];
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS tenant_settings (
+    tenant_id   UUID PRIMARY KEY,
+    settings    JSONB NOT NULL DEFAULT '{}',
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
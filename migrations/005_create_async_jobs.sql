@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS async_jobs (
+    id            UUID PRIMARY KEY,
+    tenant_id     UUID NOT NULL,
+    request       JSONB NOT NULL,
+    callback_url  TEXT NOT NULL DEFAULT '',
+    status        TEXT NOT NULL,
+    result        JSONB,
+    error         TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMPTZ
+);
+CREATE INDEX idx_async_jobs_tenant_id ON async_jobs(tenant_id);
+CREATE INDEX idx_async_jobs_status ON async_jobs(status);
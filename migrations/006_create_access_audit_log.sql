@@ -0,0 +1,21 @@
+-- Append-only record of who read which tenant's billing/usage data.
+CREATE TABLE IF NOT EXISTS access_audit_log (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_key_id      UUID,
+    actor_tenant_id   UUID,
+    target_tenant_id  UUID,
+    resource          TEXT NOT NULL,
+    action            TEXT NOT NULL,
+    purpose           TEXT NOT NULL,
+    request_id        TEXT NOT NULL DEFAULT '',
+    method            TEXT NOT NULL,
+    path              TEXT NOT NULL,
+    query             TEXT NOT NULL DEFAULT '',
+    remote_ip         TEXT NOT NULL DEFAULT '',
+    status_code       INT NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_access_audit_log_target_tenant_id ON access_audit_log(target_tenant_id);
+CREATE INDEX idx_access_audit_log_created_at ON access_audit_log(created_at);
+
+REVOKE UPDATE, DELETE ON access_audit_log FROM PUBLIC;
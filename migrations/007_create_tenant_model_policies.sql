@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS tenant_model_policies (
+    tenant_id       UUID PRIMARY KEY,
+    allowed_models  TEXT[] NOT NULL DEFAULT '{}',
+    denied_models   TEXT[] NOT NULL DEFAULT '{}',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- Responses served from the response cache are logged at zero cost.
+ALTER TABLE usage_logs ADD COLUMN IF NOT EXISTS cached BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+-- Finished jobs are deleted once expires_at passes; NULL keeps them forever.
+ALTER TABLE async_jobs ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_async_jobs_expires_at ON async_jobs(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Latest progress reported by the worker running the job.
+ALTER TABLE async_jobs ADD COLUMN IF NOT EXISTS progress JSONB;
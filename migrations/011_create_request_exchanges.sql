@@ -0,0 +1,15 @@
+-- Full prompts and completions for tenants that opt in to payload auditing.
+-- Rows are purged once expires_at passes.
+CREATE TABLE IF NOT EXISTS request_exchanges (
+    request_id  TEXT NOT NULL,
+    tenant_id   UUID NOT NULL,
+    provider    TEXT NOT NULL DEFAULT '',
+    model       TEXT NOT NULL DEFAULT '',
+    request     JSONB NOT NULL,
+    response    JSONB,
+    error       TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tenant_id, request_id)
+);
+CREATE INDEX IF NOT EXISTS idx_request_exchanges_expires_at ON request_exchanges(expires_at);
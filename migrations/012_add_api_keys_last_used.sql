@@ -0,0 +1,8 @@
+-- Last four characters of the key, shown masked to tenants listing their keys.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_hint TEXT NOT NULL DEFAULT '';
+-- Updated in batches by the gateway, so it may trail the last request slightly.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+-- Key that made the request; NULL for rows logged before keys were tracked.
+ALTER TABLE usage_logs ADD COLUMN IF NOT EXISTS api_key_id UUID;
+CREATE INDEX IF NOT EXISTS idx_usage_logs_api_key_id ON usage_logs(api_key_id) WHERE api_key_id IS NOT NULL;
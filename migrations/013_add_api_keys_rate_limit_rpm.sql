@@ -0,0 +1,3 @@
+-- Requests per minute allowed per key, alongside rate_limit (tokens per
+-- minute); 0 means no request limit.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rate_limit_rpm BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Why a response ended early, e.g. 'client_disconnect' for streams billed
+-- up to the point the client went away; empty for completed responses.
+ALTER TABLE usage_logs ADD COLUMN IF NOT EXISTS finish_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,29 @@
+-- Per-model prices in USD per million tokens. The gateway reloads this table
+-- periodically (MODEL_PRICES_REFRESH), so edits apply without a restart;
+-- models without a row fall back to their provider's built-in price.
+CREATE TABLE IF NOT EXISTS model_prices (
+    provider                TEXT NOT NULL,
+    model                   TEXT NOT NULL,
+    input_usd_per_million   NUMERIC(12, 6) NOT NULL,
+    output_usd_per_million  NUMERIC(12, 6) NOT NULL DEFAULT 0,
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, model)
+);
+
+INSERT INTO model_prices (provider, model, input_usd_per_million, output_usd_per_million) VALUES
+    ('openai', 'gpt-4o', 2.50, 10.00),
+    ('openai', 'gpt-4o-mini', 0.15, 0.60),
+    ('openai', 'gpt-4', 30.00, 60.00),
+    ('openai', 'gpt-3.5-turbo', 0.50, 1.50),
+    ('openai', 'text-embedding-3-small', 0.02, 0),
+    ('openai', 'text-embedding-3-large', 0.13, 0),
+    ('openai', 'text-embedding-ada-002', 0.10, 0),
+    ('claude', 'claude-3-5-sonnet-20241022', 3.00, 15.00),
+    ('claude', 'claude-3-5-haiku-20241022', 0.80, 4.00),
+    ('claude', 'claude-3-opus-20240229', 15.00, 75.00),
+    ('claude', 'claude-3-sonnet-20240229', 3.00, 15.00),
+    ('claude', 'claude-3-haiku-20240307', 0.25, 1.25),
+    ('gemini', 'gemini-1.5-pro', 1.25, 5.00),
+    ('gemini', 'gemini-1.5-flash', 0.075, 0.30),
+    ('gemini', 'gemini-2.0-flash', 0.10, 0.40)
+ON CONFLICT (provider, model) DO NOTHING;
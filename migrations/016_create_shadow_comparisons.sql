@@ -0,0 +1,25 @@
+-- Shadow traffic: a sample of a tenant's completions replayed on a second
+-- provider/model, stored next to the response the client got for offline
+-- quality comparison. Shadow calls are not billed to the tenant.
+CREATE TABLE IF NOT EXISTS shadow_comparisons (
+    id                     BIGSERIAL PRIMARY KEY,
+    request_id             TEXT NOT NULL,
+    tenant_id              UUID NOT NULL,
+    primary_provider       TEXT NOT NULL,
+    primary_model          TEXT NOT NULL,
+    primary_content        TEXT NOT NULL DEFAULT '',
+    primary_input_tokens   INTEGER NOT NULL DEFAULT 0,
+    primary_output_tokens  INTEGER NOT NULL DEFAULT 0,
+    primary_cost_usd       NUMERIC(12, 8) NOT NULL DEFAULT 0,
+    primary_latency_ms     BIGINT NOT NULL DEFAULT 0,
+    shadow_provider        TEXT NOT NULL,
+    shadow_model           TEXT NOT NULL,
+    shadow_content         TEXT NOT NULL DEFAULT '',
+    shadow_input_tokens    INTEGER NOT NULL DEFAULT 0,
+    shadow_output_tokens   INTEGER NOT NULL DEFAULT 0,
+    shadow_cost_usd        NUMERIC(12, 8) NOT NULL DEFAULT 0,
+    shadow_latency_ms      BIGINT NOT NULL DEFAULT 0,
+    shadow_error           TEXT NOT NULL DEFAULT '',
+    created_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_shadow_comparisons_tenant_created ON shadow_comparisons(tenant_id, created_at);
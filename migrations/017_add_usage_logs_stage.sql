@@ -0,0 +1,3 @@
+-- Which gateway stage made an extra upstream call for a request, e.g.
+-- 'language_retry' or 'translation'; empty for the request's own call.
+ALTER TABLE usage_logs ADD COLUMN IF NOT EXISTS stage TEXT NOT NULL DEFAULT '';
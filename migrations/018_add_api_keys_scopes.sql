@@ -0,0 +1,3 @@
+-- Scopes granted to each key (e.g. chat:write, usage:read); NULL means the
+-- key predates scopes and keeps full access.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[];
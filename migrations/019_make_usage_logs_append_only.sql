@@ -0,0 +1,34 @@
+-- usage_logs is append-only: customers are invoiced on it, so backfills and
+-- corrections are new rows, never edits. recorded_at is when a row was
+-- written (created_at is when the usage happened, which a backfill sets in
+-- the past), so usage can be read as it stood on any date.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'usage_logs' AND column_name = 'recorded_at') THEN
+        ALTER TABLE usage_logs ADD COLUMN recorded_at TIMESTAMPTZ;
+        UPDATE usage_logs SET recorded_at = created_at;
+        ALTER TABLE usage_logs ALTER COLUMN recorded_at SET DEFAULT NOW();
+        ALTER TABLE usage_logs ALTER COLUMN recorded_at SET NOT NULL;
+    END IF;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION usage_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'usage_logs is append-only; record a correction as a new row';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS usage_logs_no_update ON usage_logs;
+CREATE TRIGGER usage_logs_no_update BEFORE UPDATE OR DELETE ON usage_logs
+    FOR EACH ROW EXECUTE FUNCTION usage_logs_append_only();
+DROP TRIGGER IF EXISTS usage_logs_no_truncate ON usage_logs;
+CREATE TRIGGER usage_logs_no_truncate BEFORE TRUNCATE ON usage_logs
+    FOR EACH STATEMENT EXECUTE FUNCTION usage_logs_append_only();
+
+-- usage_logs_as_of is the table as it stood at as_of: rows recorded since
+-- are left out. NULL means now.
+CREATE OR REPLACE FUNCTION usage_logs_as_of(as_of TIMESTAMPTZ) RETURNS SETOF usage_logs AS $$
+    SELECT * FROM usage_logs WHERE recorded_at <= COALESCE(as_of, 'infinity')
+$$ LANGUAGE sql STABLE;
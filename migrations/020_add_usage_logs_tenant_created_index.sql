@@ -0,0 +1,3 @@
+-- Usage pages and summaries read one tenant's rows newest first; this index
+-- serves both without sorting.
+CREATE INDEX IF NOT EXISTS idx_usage_logs_tenant_created ON usage_logs (tenant_id, created_at DESC, id DESC);
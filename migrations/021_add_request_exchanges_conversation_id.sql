@@ -0,0 +1,6 @@
+-- Clients tag the turns of a conversation with X-Conversation-ID so support
+-- can export the whole conversation.
+ALTER TABLE request_exchanges ADD COLUMN IF NOT EXISTS conversation_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_request_exchanges_conversation
+    ON request_exchanges (tenant_id, conversation_id, created_at)
+    WHERE conversation_id IS NOT NULL;
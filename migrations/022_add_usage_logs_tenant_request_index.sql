@@ -0,0 +1,2 @@
+-- Conversation exports look up the usage of each exchanged request.
+CREATE INDEX IF NOT EXISTS idx_usage_logs_tenant_request ON usage_logs (tenant_id, request_id);
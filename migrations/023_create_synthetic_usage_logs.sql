@@ -0,0 +1,22 @@
+-- Synthetic monitoring traffic (canaries, red-team probes) runs through the
+-- full pipeline under a customer tenant but must not show up in its usage or
+-- bills. Its usage is kept here instead of usage_logs; provider invoice
+-- reconciliation still counts it.
+CREATE TABLE IF NOT EXISTS synthetic_usage_logs (
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id         UUID NOT NULL,
+    api_key_id        UUID,
+    request_id        TEXT NOT NULL,
+    provider          TEXT NOT NULL,
+    model             TEXT NOT NULL,
+    input_tokens      INT NOT NULL DEFAULT 0,
+    output_tokens     INT NOT NULL DEFAULT 0,
+    cost_usd          NUMERIC(12, 8) NOT NULL DEFAULT 0,
+    latency_ms        BIGINT NOT NULL DEFAULT 0,
+    cached            BOOLEAN NOT NULL DEFAULT false,
+    retrieved_doc_ids TEXT[],
+    finish_reason     TEXT NOT NULL DEFAULT '',
+    stage             TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_synthetic_usage_logs_provider_created ON synthetic_usage_logs(provider, created_at);
@@ -0,0 +1,11 @@
+-- Tenants' reusable system prompts, referenced from requests as system_ref.
+-- Saving a prompt adds a version; old versions stay for pinned references.
+CREATE TABLE IF NOT EXISTS system_prompts (
+    tenant_id   UUID NOT NULL,
+    prompt_id   TEXT NOT NULL,
+    version     INT NOT NULL,
+    content     TEXT NOT NULL,
+    defaults    JSONB NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, prompt_id, version)
+);
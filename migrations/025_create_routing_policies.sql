@@ -0,0 +1,12 @@
+-- Routing policies set gateway-wide, per plan and per API key. Tenants'
+-- own live in tenant_settings; see policy.MergeRouting for precedence.
+CREATE TABLE IF NOT EXISTS routing_policies (
+    level         TEXT NOT NULL,
+    id            TEXT NOT NULL DEFAULT '',
+    strategy      TEXT NOT NULL DEFAULT '',
+    mode          TEXT NOT NULL DEFAULT '',
+    max_cost_usd  NUMERIC(12, 8),
+    substitute    BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (level, id)
+);
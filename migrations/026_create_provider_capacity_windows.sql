@@ -0,0 +1,18 @@
+-- Known provider capacity changes the router plans around: one-off periods
+-- (starts_at/ends_at) or weekly ones (days, start_time, end_time in
+-- timezone). See policy.CapacityWindow.
+CREATE TABLE IF NOT EXISTS provider_capacity_windows (
+    id            BIGSERIAL PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    model         TEXT NOT NULL DEFAULT '',
+    capacity      TEXT NOT NULL,
+    reason        TEXT NOT NULL DEFAULT '',
+    starts_at     TIMESTAMPTZ,
+    ends_at       TIMESTAMPTZ,
+    days          TEXT[] NOT NULL DEFAULT '{}',
+    start_time    TEXT NOT NULL DEFAULT '',
+    end_time      TEXT NOT NULL DEFAULT '',
+    timezone      TEXT NOT NULL DEFAULT '',
+    lead_minutes  INT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
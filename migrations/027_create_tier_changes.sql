@@ -0,0 +1,16 @@
+-- Plan changes the tiering engine made or proposed from sustained usage.
+-- A tenant has at most one pending proposal at a time.
+CREATE TABLE IF NOT EXISTS tier_changes (
+    id          BIGSERIAL PRIMARY KEY,
+    tenant_id   TEXT NOT NULL,
+    from_plan   TEXT NOT NULL,
+    to_plan     TEXT NOT NULL,
+    rule        TEXT NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_tier_changes_status ON tier_changes (status, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tier_changes_pending ON tier_changes (tenant_id) WHERE status = 'pending';
@@ -0,0 +1,7 @@
+-- Prices for the Cohere provider's Command R models, in USD per million tokens.
+INSERT INTO model_prices (provider, model, input_usd_per_million, output_usd_per_million) VALUES
+    ('cohere', 'command-r-plus-08-2024', 2.50, 10.00),
+    ('cohere', 'command-r-plus', 2.50, 10.00),
+    ('cohere', 'command-r-08-2024', 0.15, 0.60),
+    ('cohere', 'command-r', 0.15, 0.60)
+ON CONFLICT (provider, model) DO NOTHING;
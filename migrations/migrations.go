@@ -0,0 +1,10 @@
+// Package migrations embeds the schema migrations so the gateway binary can
+// apply them itself (see internal/migrate).
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.sql files in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -3,6 +3,8 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,31 +13,315 @@ import (
 
 // Limiter is a thin wrapper around github.com/vnmchuo/ratelimiter
 type Limiter struct {
-	store extratelimit.Limiter
+	store      extratelimit.Limiter // at defaultTPM
+	reconciler *Reconciler
+	rdb        *redis.Client
+	window     time.Duration
+	defaultTPM int64
+	batchShare float64 // of a subject's limits its batch pool gets; 0 is all
+
+	// newStore builds the store for another per-minute limit; the library
+	// fixes the limit per store, so keys with their own limits share one
+	// store per distinct value.
+	newStore func(limit int64) extratelimit.Limiter
+	mu       sync.Mutex // guards store, defaultTPM and stores
+	stores   map[int64]extratelimit.Limiter
 }
 
-func NewLimiter(rdb *redis.Client, defaultTPM int64) *Limiter {
-	store := extratelimit.NewRedisStore(rdb,
-		extratelimit.WithLimit(int(defaultTPM)),
-		extratelimit.WithWindow(time.Minute),
-	)
-	return &Limiter{store: store}
+// Subject is what a request is limited as: an API key with its own limits,
+// or the tenant under the default limit when there is no key.
+type Subject struct {
+	TenantID string
+	KeyID    string
+	TPM      int64 // tokens per minute; 0 uses the default
+	RPM      int64 // requests per minute; 0 is unlimited
+	// Batch draws from the subject's batch pool: windows of their own, at
+	// the limiter's batch share of TPM and RPM, so batch traffic can't use
+	// up what interactive traffic needs.
+	Batch bool
+}
+
+// id names the subject in Redis keys and regional totals.
+func (s Subject) id() string {
+	id := s.TenantID
+	if s.KeyID != "" {
+		id = "key:" + s.KeyID
+	}
+	if s.Batch {
+		id += ":batch"
+	}
+	return id
+}
+
+func (s Subject) tokensKey() string {
+	key := fmt.Sprintf("ratelimit:tenant:%s", s.TenantID)
+	if s.KeyID != "" {
+		key = fmt.Sprintf("ratelimit:key:%s", s.KeyID)
+	}
+	if s.Batch {
+		key += ":batch"
+	}
+	return key
+}
+
+func (s Subject) requestsKey() string {
+	return s.tokensKey() + ":requests"
+}
+
+type Option func(*Limiter)
+
+// WithReconciler enables regional mode: rdb is the region-local Redis and the
+// reconciler converges usage with other regions asynchronously.
+func WithReconciler(r *Reconciler) Option {
+	return func(l *Limiter) {
+		l.reconciler = r
+	}
+}
+
+// WithBatchShare sizes batch pools at share (0 < share <= 1) of their
+// subject's limits (default: 1, as large as the interactive pool).
+func WithBatchShare(share float64) Option {
+	return func(l *Limiter) {
+		if share > 0 && share <= 1 {
+			l.batchShare = share
+		}
+	}
 }
 
+func NewLimiter(rdb *redis.Client, defaultTPM int64, opts ...Option) *Limiter {
+	newStore := func(limit int64) extratelimit.Limiter {
+		return extratelimit.NewRedisStore(rdb,
+			extratelimit.WithLimit(int(limit)),
+			extratelimit.WithWindow(time.Minute),
+		)
+	}
+	l := &Limiter{
+		store:      newStore(defaultTPM),
+		rdb:        rdb,
+		window:     time.Minute,
+		defaultTPM: defaultTPM,
+		newStore:   newStore,
+		stores:     make(map[int64]extratelimit.Limiter),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// NewTestLimiter uses store for every limit.
 func NewTestLimiter(store extratelimit.Limiter) *Limiter {
 	return &Limiter{store: store}
 }
 
-func (l *Limiter) Allow(ctx context.Context, tenantID string, tokens int) (bool, error) {
-	key := fmt.Sprintf("ratelimit:tenant:%s", tenantID)
-	res, err := l.store.AllowN(ctx, key, tokens)
+// SetDefaultTPM changes the limit of subjects without their own, e.g. on a
+// config reload. Windows carry over: the store of the old default stays
+// with keys whose own limit has that value.
+func (l *Limiter) SetDefaultTPM(tpm int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if tpm == l.defaultTPM {
+		return
+	}
+	if l.newStore != nil {
+		l.stores[l.defaultTPM] = l.store
+		store, ok := l.stores[tpm]
+		if !ok {
+			store = l.newStore(tpm)
+		}
+		delete(l.stores, tpm)
+		l.store = store
+	}
+	l.defaultTPM = tpm
+	if l.reconciler != nil {
+		l.reconciler.setDefaultLimit(tpm)
+	}
+}
+
+// DefaultTPM is the limit of subjects without their own.
+func (l *Limiter) DefaultTPM() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.defaultTPM
+}
+
+// tpmFor is s's per-minute token limit.
+func (l *Limiter) tpmFor(s Subject) int64 {
+	tpm := s.TPM
+	if tpm <= 0 {
+		tpm = l.DefaultTPM()
+	}
+	return l.pooled(s, tpm)
+}
+
+// rpmFor is s's per-minute request limit, 0 when it has none.
+func (l *Limiter) rpmFor(s Subject) int64 {
+	if s.RPM <= 0 {
+		return 0
+	}
+	return l.pooled(s, s.RPM)
+}
+
+// pooled scales limit down to s's batch pool, leaving it at least 1.
+func (l *Limiter) pooled(s Subject, limit int64) int64 {
+	if !s.Batch || l.batchShare <= 0 || l.batchShare >= 1 {
+		return limit
+	}
+	return max(int64(float64(limit)*l.batchShare), 1)
+}
+
+func (l *Limiter) storeFor(limit int64) extratelimit.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.newStore == nil || limit == l.defaultTPM {
+		return l.store
+	}
+	store, ok := l.stores[limit]
+	if !ok {
+		store = l.newStore(limit)
+		l.stores[limit] = store
+	}
+	return store
+}
+
+// adjustScript corrects a window after the fact: after a request's real token
+// count is known, or to return a request slot. The store keeps one sorted-set
+// member per unit, so overspend adds members (even past the limit: the tokens
+// were already used) and a refund pops the newest ones.
+// KEYS[1]: the rate limit key
+// ARGV[1]: current timestamp in milliseconds
+// ARGV[2]: window size in milliseconds
+// ARGV[3]: units to add (positive) or refund (negative)
+// ARGV[4]: random suffix for member uniqueness
+var adjustScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local diff = tonumber(ARGV[3])
+if diff > 0 then
+	for i = 0, diff - 1 do
+		redis.call('ZADD', key, now, now .. ':a' .. i .. ':' .. ARGV[4])
+	end
+	redis.call('PEXPIRE', key, ARGV[2])
+elseif diff < 0 then
+	redis.call('ZPOPMAX', key, -diff)
+end
+return 0
+`)
+
+// Headroom is what a subject has left in the current window once a request
+// is admitted. A zero limit means the window isn't limited (or the store
+// didn't report it).
+type Headroom struct {
+	TokensRemaining   int64
+	TokensLimit       int64
+	RequestsRemaining int64
+	RequestsLimit     int64
+}
+
+// Allow admits one request of tokens for s: a request slot first when s has
+// an RPM limit, then the tokens against its TPM limit.
+func (l *Limiter) Allow(ctx context.Context, s Subject, tokens int) (bool, error) {
+	_, ok, err := l.Admit(ctx, s, tokens)
+	return ok, err
+}
+
+// Admit is Allow that also reports the headroom left after admission.
+func (l *Limiter) Admit(ctx context.Context, s Subject, tokens int) (Headroom, bool, error) {
+	var h Headroom
+	tpm := l.tpmFor(s)
+	id := s.id()
+	if l.reconciler != nil && l.reconciler.Exhausted(id) {
+		return h, false, nil
+	}
+
+	if rpm := l.rpmFor(s); rpm > 0 {
+		res, err := l.storeFor(rpm).AllowN(ctx, s.requestsKey(), 1)
+		if err != nil {
+			return h, false, err
+		}
+		if !res.Allowed {
+			return h, false, nil
+		}
+		h.RequestsRemaining, h.RequestsLimit = res.Remaining, int64(res.Limit)
+	}
+
+	res, err := l.storeFor(tpm).AllowN(ctx, s.tokensKey(), tokens)
+	if err != nil {
+		return h, false, err
+	}
+	if !res.Allowed {
+		// The request was turned away after all, so it gives its slot back.
+		if s.RPM > 0 {
+			_ = l.adjust(ctx, s.requestsKey(), -1)
+		}
+		return h, false, nil
+	}
+	h.TokensRemaining, h.TokensLimit = res.Remaining, int64(res.Limit)
+	if l.reconciler != nil {
+		l.reconciler.Record(id, tokens)
+		l.reconciler.setLimit(id, tpm)
+	}
+	return h, true, nil
+}
+
+// Check reports whether Admit would admit tokens for s now, and the
+// headroom s has, without taking anything from its windows. A store that
+// reports no limit is taken at its word.
+func (l *Limiter) Check(ctx context.Context, s Subject, tokens int) (Headroom, bool, error) {
+	var h Headroom
+	if l.reconciler != nil && l.reconciler.Exhausted(s.id()) {
+		return h, false, nil
+	}
+
+	if rpm := l.rpmFor(s); rpm > 0 {
+		res, err := l.storeFor(rpm).Status(ctx, s.requestsKey())
+		if err != nil {
+			return h, false, err
+		}
+		h.RequestsRemaining, h.RequestsLimit = res.Remaining, int64(res.Limit)
+		if !fits(res, 1) {
+			return h, false, nil
+		}
+	}
+
+	res, err := l.storeFor(l.tpmFor(s)).Status(ctx, s.tokensKey())
 	if err != nil {
-		return false, err
+		return h, false, err
+	}
+	h.TokensRemaining, h.TokensLimit = res.Remaining, int64(res.Limit)
+	return h, fits(res, tokens), nil
+}
+
+// fits reports whether n more units fit in the window res describes.
+func fits(res *extratelimit.Result, n int) bool {
+	if res.Limit <= 0 {
+		return res.Allowed
+	}
+	return res.Remaining >= int64(n)
+}
+
+// Reconcile settles a request admitted with charged tokens once it is known to
+// have used actual: the difference is added to or refunded from the
+// subject's window, and from its regional total.
+func (l *Limiter) Reconcile(ctx context.Context, s Subject, charged, actual int) error {
+	diff := actual - charged
+	if diff == 0 {
+		return nil
+	}
+	if l.reconciler != nil {
+		l.reconciler.Record(s.id(), diff)
+	}
+	return l.adjust(ctx, s.tokensKey(), diff)
+}
+
+func (l *Limiter) adjust(ctx context.Context, key string, diff int) error {
+	if l.rdb == nil {
+		return nil
 	}
-	return res.Allowed, nil
+	return adjustScript.Run(ctx, l.rdb, []string{key},
+		time.Now().UnixMilli(), l.window.Milliseconds(), diff, rand.Int63()).Err()
 }
 
-func (l *Limiter) Status(ctx context.Context, tenantID string) (*extratelimit.Result, error) {
-	key := fmt.Sprintf("ratelimit:tenant:%s", tenantID)
-	return l.store.Status(ctx, key)
+func (l *Limiter) Status(ctx context.Context, s Subject) (*extratelimit.Result, error) {
+	return l.storeFor(l.tpmFor(s)).Status(ctx, s.tokensKey())
 }
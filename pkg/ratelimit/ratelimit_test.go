@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+
+	extratelimit "github.com/vnmchuo/ratelimiter"
+)
+
+// countingStore is an in-memory store with a fixed limit per key; windows
+// never slide.
+type countingStore struct {
+	limit int64
+	used  map[string]int64
+}
+
+func (s *countingStore) AllowN(ctx context.Context, key string, n int) (*extratelimit.Result, error) {
+	if s.used[key]+int64(n) > s.limit {
+		return &extratelimit.Result{Allowed: false}, nil
+	}
+	s.used[key] += int64(n)
+	return &extratelimit.Result{Allowed: true, Remaining: s.limit - s.used[key], Limit: int(s.limit)}, nil
+}
+func (s *countingStore) Allow(ctx context.Context, key string) (*extratelimit.Result, error) {
+	return s.AllowN(ctx, key, 1)
+}
+func (s *countingStore) Status(ctx context.Context, key string) (*extratelimit.Result, error) {
+	return &extratelimit.Result{Allowed: s.used[key] < s.limit, Remaining: s.limit - s.used[key], Limit: int(s.limit)}, nil
+}
+
+func newCountingLimiter(defaultTPM int64) *Limiter {
+	used := make(map[string]int64)
+	newStore := func(limit int64) extratelimit.Limiter {
+		return &countingStore{limit: limit, used: used}
+	}
+	return &Limiter{
+		store:      newStore(defaultTPM),
+		defaultTPM: defaultTPM,
+		newStore:   newStore,
+		stores:     make(map[int64]extratelimit.Limiter),
+	}
+}
+
+func TestLimiter_AppliesPerKeyLimits(t *testing.T) {
+	l := newCountingLimiter(100)
+	ctx := context.Background()
+
+	big := Subject{TenantID: "t1", KeyID: "k1", TPM: 1000}
+	if ok, _ := l.Allow(ctx, big, 500); !ok {
+		t.Error("Expected key with its own TPM to exceed the default")
+	}
+	// Another key of the same tenant has its own window.
+	small := Subject{TenantID: "t1", KeyID: "k2"}
+	if ok, _ := l.Allow(ctx, small, 100); !ok {
+		t.Error("Expected second key to get the default TPM")
+	}
+	if ok, _ := l.Allow(ctx, small, 1); ok {
+		t.Error("Expected second key to be limited at the default TPM")
+	}
+}
+
+func TestLimiter_EnforcesRequestsPerMinute(t *testing.T) {
+	l := newCountingLimiter(1000)
+	ctx := context.Background()
+	s := Subject{TenantID: "t1", KeyID: "k1", RPM: 2}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(ctx, s, 1); !ok {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	if ok, _ := l.Allow(ctx, s, 1); ok {
+		t.Error("Expected third request in the minute to be rejected")
+	}
+	if ok, _ := l.Allow(ctx, Subject{TenantID: "t1", KeyID: "k2", RPM: 2}, 1); !ok {
+		t.Error("Expected other keys to be unaffected")
+	}
+}
+
+func TestLimiter_AdmitReportsHeadroom(t *testing.T) {
+	l := newCountingLimiter(1000)
+	ctx := context.Background()
+	s := Subject{TenantID: "t1", KeyID: "k1", RPM: 10}
+
+	h, ok, err := l.Admit(ctx, s, 950)
+	if err != nil || !ok {
+		t.Fatalf("Expected admission, got %v, %v", ok, err)
+	}
+	want := Headroom{TokensRemaining: 50, TokensLimit: 1000, RequestsRemaining: 9, RequestsLimit: 10}
+	if h != want {
+		t.Errorf("Expected %+v, got %+v", want, h)
+	}
+}
+
+func TestLimiter_CheckTakesNothing(t *testing.T) {
+	l := newCountingLimiter(1000)
+	ctx := context.Background()
+	s := Subject{TenantID: "t1", KeyID: "k1", RPM: 10}
+
+	for i := 0; i < 2; i++ {
+		h, ok, err := l.Check(ctx, s, 1000)
+		if err != nil || !ok || h.TokensRemaining != 1000 || h.RequestsRemaining != 10 {
+			t.Fatalf("Check %d: got %+v, %v, %v", i, h, ok, err)
+		}
+	}
+	if ok, _ := l.Allow(ctx, s, 600); !ok {
+		t.Fatal("Expected checks to leave the window untouched")
+	}
+	if _, ok, _ := l.Check(ctx, s, 500); ok {
+		t.Error("Expected a check over the remaining tokens to fail")
+	}
+}
+
+func TestLimiter_BatchPool(t *testing.T) {
+	l := newCountingLimiter(1000)
+	WithBatchShare(0.25)(l)
+	ctx := context.Background()
+	interactive := Subject{TenantID: "t1", KeyID: "k1"}
+	batch := Subject{TenantID: "t1", KeyID: "k1", Batch: true}
+
+	if ok, _ := l.Allow(ctx, batch, 250); !ok {
+		t.Fatal("Expected the batch pool to admit its share")
+	}
+	if ok, _ := l.Allow(ctx, batch, 1); ok {
+		t.Error("Expected the batch pool to be limited at its share")
+	}
+	if ok, _ := l.Allow(ctx, interactive, 1000); !ok {
+		t.Error("Expected batch traffic to leave the interactive pool untouched")
+	}
+}
+
+func TestLimiter_SetDefaultTPM(t *testing.T) {
+	l := newCountingLimiter(100)
+	ctx := context.Background()
+	tenant := Subject{TenantID: "t1"}
+
+	if ok, _ := l.Allow(ctx, tenant, 100); !ok {
+		t.Fatal("Expected the tenant to use the default TPM")
+	}
+	l.SetDefaultTPM(150)
+	if ok, _ := l.Allow(ctx, tenant, 50); !ok {
+		t.Error("Expected the raised default to apply to the same window")
+	}
+	if ok, _ := l.Allow(ctx, tenant, 1); ok {
+		t.Error("Expected the tenant to be limited at the new default")
+	}
+	if ok, _ := l.Allow(ctx, Subject{TenantID: "t1", KeyID: "k1", TPM: 100}, 100); !ok {
+		t.Error("Expected a key limited at the old default to keep its limit")
+	}
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reconciler lets each region rate-limit against its own Redis while still
+// converging on a global limit per tenant or API key. Allowed tokens are
+// counted locally and flushed to a shared Redis every interval; once a
+// subject's global total for the current minute reaches its limit, every
+// region rejects it until the minute rolls over.
+//
+// The shared Redis is never on the request path. Overspend is bounded by what
+// the other regions admit during one interval (plus the interval itself if the
+// shared Redis is unreachable, since regions then fall back to local limits).
+type Reconciler struct {
+	global   *redis.Client
+	region   string
+	limit    int64
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	pending   map[string]int64     // subject -> tokens not yet flushed
+	exhausted map[string]time.Time // subject -> minute its global limit was hit
+	limits    map[string]int64     // subjects whose limit differs from limit
+}
+
+func NewReconciler(global *redis.Client, region string, limit int64, interval time.Duration) *Reconciler {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Reconciler{
+		global:    global,
+		region:    region,
+		limit:     limit,
+		interval:  interval,
+		now:       time.Now,
+		pending:   make(map[string]int64),
+		exhausted: make(map[string]time.Time),
+		limits:    make(map[string]int64),
+	}
+}
+
+// Exhausted reports whether the subject (a tenant, or "key:" plus an API key
+// ID) hit its global limit this minute.
+func (r *Reconciler) Exhausted(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	minute, ok := r.exhausted[id]
+	return ok && minute.Equal(r.now().Truncate(time.Minute))
+}
+
+// Record adds locally admitted tokens to the next flush.
+func (r *Reconciler) Record(id string, tokens int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[id] += int64(tokens)
+}
+
+// setLimit records a subject's own per-minute limit.
+func (r *Reconciler) setLimit(id string, limit int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit == r.limit {
+		delete(r.limits, id)
+		return
+	}
+	r.limits[id] = limit
+}
+
+// setDefaultLimit changes the limit of subjects without their own.
+func (r *Reconciler) setDefaultLimit(limit int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = limit
+	for id, l := range r.limits {
+		if l == limit {
+			delete(r.limits, id)
+		}
+	}
+}
+
+// Run flushes on every interval until ctx is cancelled, then flushes once more.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), r.interval)
+			r.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+func (r *Reconciler) flush(ctx context.Context) {
+	minute := r.now().Truncate(time.Minute)
+	pending := r.take()
+
+	for id, tokens := range pending {
+		key := fmt.Sprintf("ratelimit:global:%s:%d", id, minute.Unix())
+		pipe := r.global.TxPipeline()
+		pipe.HIncrBy(ctx, key, r.region, tokens)
+		pipe.Expire(ctx, key, 2*time.Minute)
+		totals := pipe.HVals(ctx, key)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("ratelimit: reconcile for %s failed: %v", id, err)
+			r.Record(id, int(tokens))
+			continue
+		}
+
+		var total int64
+		for _, v := range totals.Val() {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			total += n
+		}
+		r.observe(id, minute, total)
+	}
+}
+
+func (r *Reconciler) take() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := r.pending
+	r.pending = make(map[string]int64)
+	return pending
+}
+
+// observe records the global total seen for a subject in minute.
+func (r *Reconciler) observe(id string, minute time.Time, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limit, ok := r.limits[id]
+	if !ok {
+		limit = r.limit
+	}
+	if total >= limit {
+		r.exhausted[id] = minute
+		return
+	}
+	delete(r.exhausted, id)
+}
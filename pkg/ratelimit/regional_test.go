@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	extratelimit "github.com/vnmchuo/ratelimiter"
+)
+
+type allowAllStore struct{}
+
+func (allowAllStore) AllowN(ctx context.Context, key string, n int) (*extratelimit.Result, error) {
+	return &extratelimit.Result{Allowed: true}, nil
+}
+func (allowAllStore) Allow(ctx context.Context, key string) (*extratelimit.Result, error) {
+	return &extratelimit.Result{Allowed: true}, nil
+}
+func (allowAllStore) Status(ctx context.Context, key string) (*extratelimit.Result, error) {
+	return &extratelimit.Result{Allowed: true}, nil
+}
+
+func TestLimiter_RejectsTenantExhaustedGlobally(t *testing.T) {
+	now := time.Date(2024, 6, 5, 10, 0, 30, 0, time.UTC)
+	r := NewReconciler(nil, "eu", 100, time.Second)
+	r.now = func() time.Time { return now }
+	l := &Limiter{store: allowAllStore{}, reconciler: r}
+
+	if ok, _ := l.Allow(context.Background(), Subject{TenantID: "t1"}, 60); !ok {
+		t.Fatal("Expected first request to be allowed locally")
+	}
+	if got := r.take()["t1"]; got != 60 {
+		t.Errorf("Expected 60 pending tokens, got %d", got)
+	}
+
+	// Another region pushed the global total over the limit.
+	r.observe("t1", now.Truncate(time.Minute), 120)
+	if ok, _ := l.Allow(context.Background(), Subject{TenantID: "t1"}, 1); ok {
+		t.Error("Expected tenant to be rejected after global limit is reached")
+	}
+	if ok, _ := l.Allow(context.Background(), Subject{TenantID: "t2"}, 1); !ok {
+		t.Error("Expected other tenants to be unaffected")
+	}
+
+	// The block lifts when the minute rolls over.
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow(context.Background(), Subject{TenantID: "t1"}, 1); !ok {
+		t.Error("Expected tenant to be allowed in the next minute")
+	}
+}
+
+func TestLimiter_ReconcileCorrectsRegionalTotal(t *testing.T) {
+	r := NewReconciler(nil, "eu", 100, time.Second)
+	l := &Limiter{store: allowAllStore{}, reconciler: r}
+
+	if ok, _ := l.Allow(context.Background(), Subject{TenantID: "t1"}, 1200); !ok {
+		t.Fatal("Expected request to be allowed")
+	}
+	if err := l.Reconcile(context.Background(), Subject{TenantID: "t1"}, 1200, 450); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if got := r.take()["t1"]; got != 450 {
+		t.Errorf("Expected 450 pending tokens after refund, got %d", got)
+	}
+
+	_ = l.Reconcile(context.Background(), Subject{TenantID: "t1"}, 100, 300)
+	if got := r.take()["t1"]; got != 200 {
+		t.Errorf("Expected 200 pending tokens after overspend, got %d", got)
+	}
+}
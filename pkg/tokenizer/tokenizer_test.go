@@ -0,0 +1,33 @@
+package tokenizer
+
+import "testing"
+
+func TestCount_OpenAIEncodings(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4", "hello world", 2},
+		{"gpt-3.5-turbo", "tiktoken is great!", 6},
+		{"gpt-4o-mini", "hello world", 2},
+		{"openai/gpt-4o", "hello world", 2},
+		{"gpt-4", "", 0},
+	}
+	for _, tc := range tests {
+		if got := Count(tc.model, tc.text); got != tc.want {
+			t.Errorf("Count(%q, %q) = %d, want %d", tc.model, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestForModel_ScalesApproximatedFamilies(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog, again and again and again."
+	base := Count("gpt-4", text)
+	if got := Count("claude-3-5-sonnet", text); got <= base {
+		t.Errorf("Expected claude count above cl100k's %d, got %d", base, got)
+	}
+	if got := Count("some-unknown-model", text); got != base {
+		t.Errorf("Expected unknown models to use cl100k (%d), got %d", base, got)
+	}
+}